@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+
+	"github.com/tyrese-r/go-home/internal/config"
+)
+
+// version and commit identify the build, set with
+// -ldflags "-X main.version=1.2.0 -X main.commit=abc1234"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildCommit returns the commit the binary was built from: the one given at link time, else
+// the one the Go toolchain stamped from the checkout, else "unknown"
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// logStartup logs what the instance is running and with which settings, so an operator can
+// tell from the log which database and features it uses, then every effective setting, to
+// confirm which environment variables took effect. Database locations go through
+// config.RedactDSN and secrets are redacted, so credentials are never logged.
+func logStartup(cfg *config.Config, schemaVersion int, journalMode, synchronous string) {
+	log.Printf("Starting go-home version=%s commit=%s", version, buildCommit())
+
+	addresses := strings.Split(cfg.ServerAddress, ",")
+	for i, addr := range addresses {
+		addresses[i] = strings.TrimSpace(addr)
+	}
+	log.Printf("Listen addresses=%s", strings.Join(addresses, ","))
+
+	replica := "none"
+	if cfg.ReadDBDSN != "" {
+		replica = config.RedactDSN(cfg.ReadDBDSN)
+	}
+	log.Printf("Database driver=sqlite path=%s read_replica=%s schema_version=%d journal_mode=%s synchronous=%s",
+		config.RedactDSN(cfg.DBPath), replica, schemaVersion, journalMode, synchronous)
+
+	features := []struct {
+		name    string
+		enabled bool
+	}{
+		{"admin_auth", cfg.AdminToken != ""},
+		{"gzip", cfg.GzipEnabled},
+		{"stats_cache", cfg.StatsRefreshInterval > 0},
+		{"incident_grouping", cfg.IncidentGroupingEnabled},
+		{"alarm_escalation", len(cfg.AlarmEscalationRules()) > 0 && cfg.AlarmEscalationInterval > 0},
+		{"slow_query_log", cfg.SlowQueryThreshold > 0},
+		{"debug_body_logging", cfg.DebugBodyLogging},
+		{"name_normalization", cfg.NormalizeDeviceNames},
+		{"delete_confirmation", cfg.RequireDeleteConfirmation},
+		{"device_type_lock", cfg.LockDeviceTypes},
+		{"strict_owners", cfg.StrictOwners},
+		{"strict_alarm_reasons", cfg.StrictAlarmReasons},
+	}
+	fields := make([]string, len(features))
+	for i, feature := range features {
+		fields[i] = fmt.Sprintf("%s=%t", feature.name, feature.enabled)
+	}
+	log.Printf("Features %s", strings.Join(fields, " "))
+
+	maxConcurrent := "unlimited"
+	if cfg.MaxConcurrentRequests > 0 {
+		maxConcurrent = fmt.Sprint(cfg.MaxConcurrentRequests)
+	}
+	log.Printf("Limits default_page_size=%d max_page_size=%d max_concurrent_requests=%s json_max_depth=%d max_view_window=%s max_recent_devices=%d",
+		cfg.DefaultPageSize, cfg.MaxPageSize, maxConcurrent, cfg.JSONMaxDepth, cfg.MaxViewWindow, cfg.MaxRecentDevices)
+
+	log.Printf("Config %s", strings.Join(cfg.EffectiveFields(), " "))
+}
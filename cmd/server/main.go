@@ -1,21 +1,47 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	// Embedded so DISPLAY_TIMEZONE and ?tz work in images without a system zoneinfo database
+	_ "time/tzdata"
 
 	"github.com/tyrese-r/go-home/internal/config"
 	"github.com/tyrese-r/go-home/internal/handlers"
-	"github.com/tyrese-r/go-home/internal/repository"
-	"github.com/tyrese-r/go-home/internal/service"
 	"github.com/tyrese-r/go-home/pkg/database"
+	"github.com/tyrese-r/go-home/pkg/repository"
+	"github.com/tyrese-r/go-home/pkg/service"
+	"github.com/tyrese-r/go-home/pkg/systemd"
 )
 
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the effective configuration as JSON, with secrets redacted, and exit")
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if *printConfig {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(cfg.Effective()); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+	if cfg.CursorSecret == "" {
+		log.Printf("CURSOR_SECRET is not set; list cursors will not survive a restart")
+	}
 
-	// Initialize database
-	db, err := database.NewSQLiteDB(cfg.DBPath)
+	// Initialize database. The manager reopens it when its file is replaced, as by a restore.
+	db, err := database.NewManager(cfg.DBPath, database.WithWAL(cfg.DBWAL))
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -24,22 +50,166 @@ func main() {
 			log.Printf("clError closing database: %v", clErr)
 		}
 	}()
+	schemaVersion, err := database.AppliedSchemaVersion(db.DB())
+	if err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+	journalMode, synchronous, err := database.JournalSettings(db.DB())
+	if err != nil {
+		log.Fatalf("Failed to read journal mode: %v", err)
+	}
+	logStartup(cfg, schemaVersion, journalMode, synchronous)
+
 	// Initialize repositories
-	deviceRepo := repository.NewDeviceRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db, repository.WithAlarmPresence(cfg.AlarmPresenceByType))
+	incidentRepo := repository.NewIncidentRepository(db)
+	commandRepo := repository.NewCommandRepository(db)
+	changeRepo := repository.NewChangeRepository(db)
+	reasonRepo := repository.NewAlarmReasonRepository(db)
+	if cfg.SlowQueryThreshold > 0 {
+		deviceRepo = repository.NewSlowQueryDeviceRepository(deviceRepo, cfg.SlowQueryThreshold)
+		incidentRepo = repository.NewSlowQueryIncidentRepository(incidentRepo, cfg.SlowQueryThreshold)
+		commandRepo = repository.NewSlowQueryCommandRepository(commandRepo, cfg.SlowQueryThreshold)
+		changeRepo = repository.NewSlowQueryChangeRepository(changeRepo, cfg.SlowQueryThreshold)
+		reasonRepo = repository.NewSlowQueryAlarmReasonRepository(reasonRepo, cfg.SlowQueryThreshold)
+	}
 
 	// Initialize services
-	deviceService := service.NewDeviceService(deviceRepo)
+	var deviceOpts []service.Option
+	if cfg.ReadDBDSN != "" {
+		// An unreachable replica is not fatal; reads simply stay on the primary
+		replica, err := database.NewSQLiteReadDB(cfg.ReadDBDSN)
+		if err != nil {
+			log.Printf("Read replica unavailable, reading from primary: %v", err)
+		} else {
+			defer func() {
+				if clErr := replica.Close(); clErr != nil {
+					log.Printf("Error closing read replica: %v", clErr)
+				}
+			}()
+			replicaRepo := repository.NewDeviceRepository(replica)
+			if cfg.SlowQueryThreshold > 0 {
+				replicaRepo = repository.NewSlowQueryDeviceRepository(replicaRepo, cfg.SlowQueryThreshold)
+			}
+			reader := repository.NewFallbackDeviceReader(replicaRepo, deviceRepo)
+			deviceOpts = append(deviceOpts, service.WithReader(reader))
+		}
+	}
+	if cfg.IncidentGroupingEnabled {
+		deviceOpts = append(deviceOpts, service.WithIncidentGrouping(incidentRepo, cfg.IncidentWindow))
+	}
+	if cfg.StaleThreshold > 0 {
+		deviceOpts = append(deviceOpts, service.WithStaleThreshold(cfg.StaleThreshold))
+	}
+	deviceOpts = append(deviceOpts, service.WithHealthPolicy(service.HealthPolicy{
+		OnlineWeight:    cfg.HealthWeightOnline,
+		HeartbeatWeight: cfg.HealthWeightHeartbeat,
+		AlarmWeight:     cfg.HealthWeightAlarms,
+		HeartbeatWindow: cfg.HealthHeartbeatWindow,
+		AlarmWindow:     cfg.HealthAlarmWindow,
+		AlarmLimit:      cfg.HealthAlarmLimit,
+	}))
+	var statsCache *service.DeviceStatsCache
+	if cfg.StatsRefreshInterval > 0 {
+		statsCache = service.NewDeviceStatsCache(deviceRepo)
+		deviceOpts = append(deviceOpts, service.WithStatsCache(statsCache))
+	}
+	if cfg.NormalizeDeviceNames {
+		deviceOpts = append(deviceOpts, service.WithNameNormalization())
+	}
+	if cfg.LockDeviceTypes {
+		deviceOpts = append(deviceOpts, service.WithDeviceTypeLock())
+	}
+	if cfg.AlwaysWriteUpdates {
+		deviceOpts = append(deviceOpts, service.WithAlwaysWriteUpdates())
+	}
+	onlineDebounce := service.OnlineDebounce{Default: cfg.OnlineDebounce, ByType: cfg.OnlineDebounceByType}
+	deviceOpts = append(deviceOpts, service.WithOnlineDebounce(onlineDebounce))
+	deviceOpts = append(deviceOpts, service.WithAlarmLevels(cfg.AlarmLevelRegistry()))
+	deviceOpts = append(deviceOpts, service.WithReconnector(db))
+	deviceOpts = append(deviceOpts, service.WithAlarmReasons(reasonRepo, cfg.StrictAlarmReasons))
+	deviceService := service.NewDeviceService(deviceRepo, deviceOpts...)
+	if err := deviceService.CheckAlarmLevels(cfg.AlarmLevelRetention); err != nil {
+		log.Fatalf("ALARM_LEVELS: %v; keep the level until it falls outside ALARM_LEVEL_RETENTION", err)
+	}
+	incidentService := service.NewIncidentService(incidentRepo)
+	commandService := service.NewCommandService(commandRepo, deviceRepo)
+	changeService := service.NewChangeService(changeRepo)
+
+	// Start background jobs. They and the server stop on SIGINT or SIGTERM.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if cfg.DBReconnectCheckInterval > 0 {
+		go db.Watch(ctx, cfg.DBReconnectCheckInterval)
+	}
+
+	// The sweeper also ends expired maintenance windows, so it runs even without alarm TTLs
+	if cfg.AlarmSweepInterval > 0 {
+		sweeper := service.NewAlarmSweeper(deviceRepo, cfg.AlarmTTLs(), cfg.AlarmEventTTL, cfg.AlarmSweepSkipAcknowledged)
+		go sweeper.Run(ctx, cfg.AlarmSweepInterval)
+	}
+
+	if rules := cfg.AlarmEscalationRules(); len(rules) > 0 && cfg.AlarmEscalationInterval > 0 {
+		var incidents repository.IncidentRepository
+		if cfg.IncidentGroupingEnabled {
+			incidents = incidentRepo
+		}
+		escalator := service.NewAlarmEscalator(deviceRepo, rules, incidents, cfg.IncidentWindow)
+		go escalator.Run(ctx, cfg.AlarmEscalationInterval)
+	}
+
+	// Devices can keep their own alarm retention, so the pruner runs even without a global one
+	if cfg.AlarmHistoryPruneInterval > 0 {
+		pruner := service.NewAlarmHistoryPruner(deviceRepo, cfg.AlarmHistoryRetention)
+		go pruner.Run(ctx, cfg.AlarmHistoryPruneInterval)
+	}
+
+	if cfg.OnlineCheckInterval > 0 {
+		watchdog := service.NewOnlineWatchdog(deviceRepo, onlineDebounce)
+		go watchdog.Run(ctx, cfg.OnlineCheckInterval)
+	}
+
+	if statsCache != nil {
+		go statsCache.Run(ctx, cfg.StatsRefreshInterval)
+	}
+
+	if cfg.ChangeRetention > 0 && cfg.ChangeCompactionInterval > 0 {
+		compactor := service.NewChangeCompactor(changeRepo, cfg.ChangeRetention)
+		go compactor.Run(ctx, cfg.ChangeCompactionInterval)
+	}
 
 	// Initialize HTTP handlers
-	h := handlers.New(deviceService)
+	h := handlers.New(deviceService, incidentService, commandService, changeService, cfg)
 
 	// Start HTTP server
-	err = h.StartServer(cfg.ServerAddress)
+	// Under systemd, readiness is reported once the database is migrated and the listeners accept
+	// connections, and the watchdog is pinged only while the database still answers
+	interval, err := systemd.WatchdogInterval()
 	if err != nil {
-		log.Printf("Server failed: %v", err)
-		if closeErr := db.Close(); closeErr != nil {
-			log.Printf("Error closing database: %v", closeErr)
+		log.Printf("Watchdog disabled: %v", err)
+	}
+	onReady := func() {
+		if _, err := systemd.Notify(systemd.Ready); err != nil {
+			log.Printf("Error notifying systemd of readiness: %v", err)
+		}
+		if interval > 0 {
+			go systemd.RunWatchdog(ctx, interval/2, func(ctx context.Context) error {
+				pingCtx, cancel := context.WithTimeout(ctx, interval/2)
+				defer cancel()
+				var one int
+				return db.QueryRowContext(pingCtx, "SELECT 1").Scan(&one)
+			})
 		}
+	}
+
+	err = h.StartServer(ctx, cfg.ServerAddress, onReady)
+	if _, notifyErr := systemd.Notify(systemd.Stopping); notifyErr != nil {
+		log.Printf("Error notifying systemd of shutdown: %v", notifyErr)
+	}
+	if err != nil {
+		log.Printf("Server failed: %v", err)
 		return
 	}
+	log.Printf("Server stopped")
 }
@@ -0,0 +1,56 @@
+// Package embedded_test shows go-home's device management used as a library, without the HTTP
+// server. It only imports the pkg/ packages an external module can import.
+package embedded_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/tyrese-r/go-home/pkg/database"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+	"github.com/tyrese-r/go-home/pkg/service"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "go-home-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// NewSQLiteDB creates and migrates the schema
+	db, err := database.NewSQLiteDB(filepath.Join(dir, "devices.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	devices := service.NewDeviceService(repository.NewDeviceRepository(db))
+
+	// The service stores what it is given; validate input from outside the program first
+	create := &models.DeviceCreate{Name: "FrontDoor", DeviceType: models.DeviceTypeLock, OwnedBy: "alice"}
+	if result := validation.ValidateDeviceCreate(create, nil); !result.Valid() {
+		log.Fatal(result.Errors)
+	}
+	device, err := devices.CreateDevice(create)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	alarm := &models.AlarmRequest{Level: "CRITICAL", Reason: "Forced open", TriggeredBy: "door-sensor"}
+	if err := devices.TriggerAlarm(device.ID, alarm); err != nil {
+		log.Fatal(err)
+	}
+
+	device, err = devices.GetDeviceByID(device.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(device.Name, device.AlarmActive, device.LastAlarmLevel, device.LastAlarmTriggeredBy)
+
+	// Output: FrontDoor true CRITICAL door-sensor
+}
@@ -1,13 +1,210 @@
 package config
 
 import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
 )
 
 // Config holds application configuration
 type Config struct {
+	// ServerAddress is a comma-separated list of addresses to serve on: host:port for TCP, or
+	// unix:/path/to.sock for a Unix domain socket
 	ServerAddress string
-	DBPath        string
+	// SocketMode is the file mode Unix domain sockets are created with
+	SocketMode os.FileMode
+	DBPath     string
+	// ReadDBDSN optionally points device reads at a read replica; empty reads from DBPath
+	ReadDBDSN string
+	// DBWAL opens the database in WAL mode with synchronous=NORMAL, so reads do not wait for
+	// writes. Disabling it leaves a database already in WAL mode as it is.
+	DBWAL bool
+	// DBReconnectCheckInterval is how often the database file is checked for having been replaced,
+	// as by a Litestream restore, so the connection pool is reopened on it; zero never checks
+	DBReconnectCheckInterval time.Duration
+	// HealthCheckTimeout bounds how long /health waits for the database to answer before
+	// reporting it unavailable; zero waits as long as the request lasts
+	HealthCheckTimeout time.Duration
+
+	// GzipEnabled turns on gzip compression of responses
+	GzipEnabled bool
+	// GzipMinSize is the smallest response body, in bytes, that gets compressed
+	GzipMinSize int
+	// GzipListOnly restricts compression to list/export routes
+	GzipListOnly bool
+
+	// DefaultPageSize is the number of items any list endpoint returns when no limit is requested
+	DefaultPageSize int
+	// MaxPageSize is the ceiling requested limits are clamped to on every list endpoint
+	MaxPageSize int
+	// DefaultDeviceSortBy and DefaultDeviceSortOrder order the device list when no sort_by is given
+	DefaultDeviceSortBy    string
+	DefaultDeviceSortOrder string
+
+	// IncidentGroupingEnabled attaches alarms to shared incidents
+	IncidentGroupingEnabled bool
+	// IncidentWindow is how long an incident accepts new alarms of the same level
+	IncidentWindow time.Duration
+
+	// AlarmLevels lists the alarm levels from least to most severe, adding levels around the
+	// built-in INFO, WARNING and CRITICAL, which must keep their order; empty uses the built-in
+	// levels alone
+	AlarmLevels []string
+	// AlarmLevelRetention is how far back the alarm history is checked at startup for levels
+	// missing from AlarmLevels; a level still in use is not allowed to be removed. Zero checks
+	// the whole history.
+	AlarmLevelRetention time.Duration
+
+	// AlarmTTLInfo, AlarmTTLWarning and AlarmTTLCritical are how long an alarm of each
+	// level stays active before being cleared automatically; zero means never
+	AlarmTTLInfo     time.Duration
+	AlarmTTLWarning  time.Duration
+	AlarmTTLCritical time.Duration
+	// AlarmTTLLevels holds the TTLs of the added alarm levels, read from ALARM_TTL_<LEVEL>
+	AlarmTTLLevels map[string]time.Duration
+	// AlarmSweepInterval is how often expired alarms are cleared
+	AlarmSweepInterval time.Duration
+	// AlarmSweepSkipAcknowledged leaves acknowledged alarms active past their TTL, so they stay
+	// until they are cleared by hand
+	AlarmSweepSkipAcknowledged bool
+	// AlarmEscalateInfo and AlarmEscalateWarning escalate active alarms of each level that go
+	// unacknowledged, written as DELAY:TARGET[:renotify] such as "15m:CRITICAL:renotify";
+	// empty never escalates
+	AlarmEscalateInfo    string
+	AlarmEscalateWarning string
+	// AlarmEscalateLevels holds the escalation rules of CRITICAL and the added alarm levels,
+	// read from ALARM_ESCALATE_<LEVEL>
+	AlarmEscalateLevels map[string]string
+	// AlarmEscalationInterval is how often alarms due to escalate are looked for
+	AlarmEscalationInterval time.Duration
+	// AlarmEventTTL is how long the event id of a processed alarm is remembered, so a retry
+	// within it is not recorded again; zero remembers them forever
+	AlarmEventTTL time.Duration
+	// AlarmHistoryRetention is how long alarm history is kept for devices without their own
+	// alarm_retention_days; zero keeps it forever
+	AlarmHistoryRetention time.Duration
+	// AlarmHistoryPruneInterval is how often expired alarm history is deleted; zero never does
+	AlarmHistoryPruneInterval time.Duration
+
+	// SlowQueryThreshold logs repository operations that take at least this long; zero disables it
+	SlowQueryThreshold time.Duration
+
+	// ChangeRetention is how long change feed entries are kept; zero keeps them forever
+	ChangeRetention time.Duration
+	// ChangeCompactionInterval is how often expired change feed entries are deleted
+	ChangeCompactionInterval time.Duration
+
+	// DisplayTimezone is the IANA timezone device timestamps are also rendered in when a
+	// request gives no ?tz; empty renders UTC only
+	DisplayTimezone string
+
+	// WSHeartbeatTimeout closes a device WebSocket that sends nothing for this long; zero disables it
+	WSHeartbeatTimeout time.Duration
+	// StaleThreshold flags devices last seen longer ago than this as stale; zero disables it
+	StaleThreshold time.Duration
+	// OnlineDebounce is how long a device must go unheard from before it is marked offline,
+	// instead of as soon as its connection drops; zero disables debouncing
+	OnlineDebounce time.Duration
+	// OnlineDebounceByType overrides OnlineDebounce for some device types
+	OnlineDebounceByType map[models.DeviceType]time.Duration
+	// OnlineCheckInterval is how often quiet devices are looked for when debouncing
+	OnlineCheckInterval time.Duration
+	// AlarmPresenceByType makes alarms count as devices of some types being heard from, for
+	// devices that only contact the server to alarm: "seen" updates their last_seen, "online"
+	// also marks them online. Types not listed are "off", leaving presence to heartbeats.
+	AlarmPresenceByType models.AlarmPresencePolicy
+	// StatsRefreshInterval is how often the cached device stats are recomputed besides after
+	// each change; zero computes them on every request instead
+	StatsRefreshInterval time.Duration
+	// MaxViewWindow caps the durations accepted by the recently alarmed and stale device views
+	MaxViewWindow time.Duration
+	// MaxRecentDevices caps the limit of the recently updated devices view; zero leaves it
+	// capped by MaxPageSize alone
+	MaxRecentDevices int
+
+	// HealthWeightOnline, HealthWeightHeartbeat and HealthWeightAlarms weight the components
+	// of device health scores; see service.HealthPolicy for the formula
+	HealthWeightOnline    float64
+	HealthWeightHeartbeat float64
+	HealthWeightAlarms    float64
+	// HealthHeartbeatWindow is how long after a device was last seen its heartbeat scores zero;
+	// zero leaves the heartbeat out of the score
+	HealthHeartbeatWindow time.Duration
+	// HealthAlarmWindow is how far back alarms count against a device's health, and
+	// HealthAlarmLimit the number within it that scores zero; zero for either leaves alarms out
+	HealthAlarmWindow time.Duration
+	HealthAlarmLimit  int
+
+	// NormalizeDeviceNames trims device names and collapses their internal whitespace before
+	// they are validated, stored or searched for
+	NormalizeDeviceNames bool
+
+	// RequireDeleteConfirmation makes deleting a device require ?confirm= with its current name,
+	// so a mistyped id cannot delete the wrong device
+	RequireDeleteConfirmation bool
+
+	// LockDeviceTypes refuses to change the type of an existing device, so a device in
+	// production cannot silently become something else; other fields still update
+	LockDeviceTypes bool
+
+	// AlwaysWriteUpdates writes device updates that change nothing, bumping updated_at and the
+	// version and adding to the change feed, instead of answering them with X-No-Change
+	AlwaysWriteUpdates bool
+
+	// StrictOwners rejects creating or reassigning a device to an owner no device belongs to yet,
+	// so a typo cannot create an orphan owner. Admin requests may still introduce new owners.
+	StrictOwners bool
+
+	// StrictAlarmReasons refuses alarms that give no reason code, so every recorded reason comes
+	// from the alarm reason catalog. Otherwise free-text reasons are accepted alongside codes.
+	StrictAlarmReasons bool
+
+	// AllowedDeviceTypes narrows the device types devices may be created with or changed to;
+	// empty allows every type
+	AllowedDeviceTypes []models.DeviceType
+	// DeprecatedDeviceTypes lists device types being retired: existing devices of them are still
+	// served, but no device may be created with them or changed to them
+	DeprecatedDeviceTypes []models.DeviceType
+
+	// CursorSecret signs list cursors. When empty a random key is used, so cursors stop
+	// working when the server restarts.
+	CursorSecret string
+
+	// TrustedProxies are the addresses and CIDR ranges of reverse proxies whose X-Forwarded-For
+	// and X-Real-IP headers are believed; empty trusts none and uses the connection's address
+	TrustedProxies []string
+
+	// AdminToken is the bearer token that grants admin access; empty disables admin access
+	AdminToken string
+
+	// MaxConcurrentRequests caps how many requests are handled at once, answering the rest with
+	// 503 and a Retry-After of ConcurrencyRetryAfter; zero disables the limit. Health checks and
+	// websockets are not counted.
+	MaxConcurrentRequests int
+	ConcurrencyRetryAfter time.Duration
+
+	// JSONMaxDepth limits how deeply objects and arrays may nest in JSON request bodies; zero
+	// disables the limit
+	JSONMaxDepth int
+	// ValidationErrorStatus is the status of responses to requests that parse but fail
+	// validation, 422 or 400; malformed requests are always 400
+	ValidationErrorStatus int
+
+	// DebugBodyLogging logs the request and response bodies of every request. Without it,
+	// bodies are logged only for admin requests sending X-Debug: true.
+	DebugBodyLogging bool
+	// DebugBodyMaxBytes is how much of each body is logged
+	DebugBodyMaxBytes int
+	// DebugRedactKeys are the body field names, matched ignoring case as substrings, whose
+	// values are redacted from logged bodies
+	DebugRedactKeys []string
 }
 
 // New returns a Config with values from environment variables or defaults
@@ -22,8 +219,493 @@ func New() *Config {
 		dbPath = "./data.db"
 	}
 
+	sortBy, sortOrder, _ := strings.Cut(os.Getenv("DEFAULT_DEVICE_SORT"), ":")
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	if sortOrder == "" {
+		sortOrder = models.SortDesc
+	}
+
+	alarmLevels := getEnvList("ALARM_LEVELS", nil)
+	escalateLevels := make(map[string]string)
+	ttlLevels := make(map[string]time.Duration)
+	for i, level := range alarmLevels {
+		alarmLevels[i] = strings.ToUpper(level)
+		if alarmLevels[i] == models.AlarmLevelInfo || alarmLevels[i] == models.AlarmLevelWarning {
+			continue
+		}
+		if spec := os.Getenv("ALARM_ESCALATE_" + alarmLevels[i]); spec != "" {
+			escalateLevels[alarmLevels[i]] = spec
+		}
+		if alarmLevels[i] == models.AlarmLevelCritical {
+			continue
+		}
+		if ttl := getEnvDuration("ALARM_TTL_"+alarmLevels[i], 0); ttl != 0 {
+			ttlLevels[alarmLevels[i]] = ttl
+		}
+	}
+
 	return &Config{
 		ServerAddress: serverAddr,
+		SocketMode:    getEnvFileMode("SERVER_SOCKET_MODE", 0o660),
 		DBPath:        dbPath,
+		ReadDBDSN:     os.Getenv("READ_DB_DSN"),
+		GzipEnabled:   getEnvBool("GZIP_ENABLED", false),
+		GzipMinSize:   getEnvInt("GZIP_MIN_SIZE", 1024),
+		GzipListOnly:  getEnvBool("GZIP_LIST_ONLY", false),
+
+		DefaultPageSize: getEnvInt("DEFAULT_PAGE_SIZE", 100),
+		MaxPageSize:     getEnvInt("MAX_PAGE_SIZE", 1000),
+
+		DefaultDeviceSortBy:    sortBy,
+		DefaultDeviceSortOrder: strings.ToLower(sortOrder),
+
+		IncidentGroupingEnabled: getEnvBool("INCIDENT_GROUPING_ENABLED", false),
+		IncidentWindow:          getEnvDuration("INCIDENT_WINDOW", 5*time.Minute),
+
+		AlarmLevels:         alarmLevels,
+		AlarmLevelRetention: getEnvDuration("ALARM_LEVEL_RETENTION", 30*24*time.Hour),
+
+		AlarmTTLInfo:               getEnvDuration("ALARM_TTL_INFO", time.Hour),
+		AlarmTTLWarning:            getEnvDuration("ALARM_TTL_WARNING", 24*time.Hour),
+		AlarmTTLCritical:           getEnvDuration("ALARM_TTL_CRITICAL", 0),
+		AlarmTTLLevels:             ttlLevels,
+		AlarmSweepInterval:         getEnvDuration("ALARM_SWEEP_INTERVAL", time.Minute),
+		AlarmSweepSkipAcknowledged: getEnvBool("ALARM_SWEEP_SKIP_ACKNOWLEDGED", false),
+		AlarmEventTTL:              getEnvDuration("ALARM_EVENT_TTL", 24*time.Hour),
+
+		AlarmHistoryRetention:     getEnvDuration("ALARM_HISTORY_RETENTION", 0),
+		AlarmHistoryPruneInterval: getEnvDuration("ALARM_HISTORY_PRUNE_INTERVAL", time.Hour),
+
+		AlarmEscalateInfo:       os.Getenv("ALARM_ESCALATE_INFO"),
+		AlarmEscalateWarning:    os.Getenv("ALARM_ESCALATE_WARNING"),
+		AlarmEscalateLevels:     escalateLevels,
+		AlarmEscalationInterval: getEnvDuration("ALARM_ESCALATION_INTERVAL", 30*time.Second),
+
+		SlowQueryThreshold: getEnvDuration("SLOW_QUERY_THRESHOLD", 0),
+
+		DBWAL:                    getEnvBool("DB_WAL", true),
+		DBReconnectCheckInterval: getEnvDuration("DB_RECONNECT_CHECK_INTERVAL", 5*time.Second),
+		HealthCheckTimeout:       getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+
+		ChangeRetention:          getEnvDuration("CHANGE_RETENTION", 30*24*time.Hour),
+		ChangeCompactionInterval: getEnvDuration("CHANGE_COMPACTION_INTERVAL", time.Hour),
+
+		DisplayTimezone: os.Getenv("DISPLAY_TIMEZONE"),
+
+		WSHeartbeatTimeout: getEnvDuration("WS_HEARTBEAT_TIMEOUT", 90*time.Second),
+		StaleThreshold:     getEnvDuration("STALE_THRESHOLD", 5*time.Minute),
+		MaxViewWindow:      getEnvDuration("MAX_VIEW_WINDOW", 90*24*time.Hour),
+		MaxRecentDevices:   getEnvInt("MAX_RECENT_DEVICES", 50),
+
+		StatsRefreshInterval: getEnvDuration("STATS_REFRESH_INTERVAL", time.Minute),
+
+		OnlineDebounce:       getEnvDuration("ONLINE_DEBOUNCE", 0),
+		OnlineDebounceByType: getEnvDeviceTypeDurations("ONLINE_DEBOUNCE_BY_TYPE"),
+		OnlineCheckInterval:  getEnvDuration("ONLINE_CHECK_INTERVAL", 15*time.Second),
+
+		AlarmPresenceByType: getEnvAlarmPresence("ALARM_PRESENCE_BY_TYPE"),
+
+		HealthWeightOnline:    getEnvFloat("HEALTH_WEIGHT_ONLINE", 40),
+		HealthWeightHeartbeat: getEnvFloat("HEALTH_WEIGHT_HEARTBEAT", 30),
+		HealthWeightAlarms:    getEnvFloat("HEALTH_WEIGHT_ALARMS", 30),
+		HealthHeartbeatWindow: getEnvDuration("HEALTH_HEARTBEAT_WINDOW", time.Hour),
+		HealthAlarmWindow:     getEnvDuration("HEALTH_ALARM_WINDOW", 24*time.Hour),
+		HealthAlarmLimit:      getEnvInt("HEALTH_ALARM_LIMIT", 10),
+
+		NormalizeDeviceNames:      getEnvBool("NORMALIZE_DEVICE_NAMES", false),
+		RequireDeleteConfirmation: getEnvBool("REQUIRE_DELETE_CONFIRMATION", false),
+		LockDeviceTypes:           getEnvBool("LOCK_DEVICE_TYPES", false),
+		AlwaysWriteUpdates:        getEnvBool("ALWAYS_WRITE_UPDATES", false),
+		StrictOwners:              getEnvBool("STRICT_OWNERS", false),
+		StrictAlarmReasons:        getEnvBool("STRICT_ALARM_REASONS", false),
+
+		AllowedDeviceTypes:    getEnvDeviceTypes("ALLOWED_DEVICE_TYPES"),
+		DeprecatedDeviceTypes: getEnvDeviceTypes("DEPRECATED_DEVICE_TYPES"),
+
+		CursorSecret: os.Getenv("CURSOR_SECRET"),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", nil),
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+
+		MaxConcurrentRequests: getEnvInt("MAX_CONCURRENT_REQUESTS", 0),
+		ConcurrencyRetryAfter: getEnvDuration("CONCURRENCY_RETRY_AFTER", time.Second),
+
+		JSONMaxDepth:          getEnvInt("JSON_MAX_DEPTH", 32),
+		ValidationErrorStatus: getEnvInt("VALIDATION_ERROR_STATUS", http.StatusUnprocessableEntity),
+
+		DebugBodyLogging:  getEnvBool("DEBUG_BODY_LOGGING", false),
+		DebugBodyMaxBytes: getEnvInt("DEBUG_BODY_MAX_BYTES", 4096),
+		DebugRedactKeys:   getEnvList("DEBUG_REDACT_KEYS", []string{"password", "token", "secret", "authorization", "api_key"}),
+	}
+}
+
+// Validate checks settings that cannot fall back to a default
+func (c *Config) Validate() error {
+	// New always sets an address, so only a list with an empty entry is rejected
+	if c.ServerAddress != "" {
+		for _, addr := range strings.Split(c.ServerAddress, ",") {
+			if addr = strings.TrimSpace(addr); addr == "" || addr == "unix:" {
+				return fmt.Errorf("SERVER_ADDRESS: empty address in %q", c.ServerAddress)
+			}
+		}
+	}
+	if c.MaxPageSize < 1 {
+		return fmt.Errorf("MAX_PAGE_SIZE: must be at least 1, got %d", c.MaxPageSize)
+	}
+	if c.DefaultPageSize < 1 || c.DefaultPageSize > c.MaxPageSize {
+		return fmt.Errorf("DEFAULT_PAGE_SIZE: must be between 1 and MAX_PAGE_SIZE (%d), got %d", c.MaxPageSize, c.DefaultPageSize)
+	}
+	if c.MaxRecentDevices < 0 {
+		return fmt.Errorf("MAX_RECENT_DEVICES: must not be negative, got %d", c.MaxRecentDevices)
+	}
+	if !models.IsValidDeviceSortField(c.DefaultDeviceSortBy) {
+		return fmt.Errorf("DEFAULT_DEVICE_SORT: cannot sort by %q, must be one of: %s",
+			c.DefaultDeviceSortBy, strings.Join(models.DeviceSortFields, ", "))
+	}
+	if !models.IsValidSortOrder(c.DefaultDeviceSortOrder) {
+		return fmt.Errorf("DEFAULT_DEVICE_SORT: order must be asc or desc, got %q", c.DefaultDeviceSortOrder)
+	}
+	for _, dt := range c.AllowedDeviceTypes {
+		if !dt.IsValid() {
+			return fmt.Errorf("ALLOWED_DEVICE_TYPES: unknown device type %q", dt)
+		}
+	}
+	for _, dt := range c.DeprecatedDeviceTypes {
+		if !dt.IsValid() {
+			return fmt.Errorf("DEPRECATED_DEVICE_TYPES: unknown device type %q", dt)
+		}
+	}
+	levels, err := models.NewAlarmLevels(c.AlarmLevels)
+	if err != nil {
+		return fmt.Errorf("ALARM_LEVELS: %w", err)
+	}
+	if c.AlarmLevelRetention < 0 {
+		return fmt.Errorf("ALARM_LEVEL_RETENTION: must not be negative, got %s", c.AlarmLevelRetention)
+	}
+	if c.DBReconnectCheckInterval < 0 {
+		return fmt.Errorf("DB_RECONNECT_CHECK_INTERVAL: must not be negative, got %s", c.DBReconnectCheckInterval)
+	}
+	if c.HealthCheckTimeout < 0 {
+		return fmt.Errorf("HEALTH_CHECK_TIMEOUT: must not be negative, got %s", c.HealthCheckTimeout)
+	}
+	if c.AlarmHistoryRetention < 0 {
+		return fmt.Errorf("ALARM_HISTORY_RETENTION: must not be negative, got %s", c.AlarmHistoryRetention)
+	}
+	for level, ttl := range c.AlarmTTLLevels {
+		if !levels.IsValid(level) {
+			return fmt.Errorf("ALARM_TTL_%s: unknown alarm level %s", level, level)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("ALARM_TTL_%s: must not be negative, got %s", level, ttl)
+		}
+	}
+	for level, spec := range c.alarmEscalationSpecs() {
+		if !levels.IsValid(level) {
+			return fmt.Errorf("ALARM_ESCALATE_%s: unknown alarm level %s", level, level)
+		}
+		if _, err := parseEscalationRule(levels, level, spec); err != nil {
+			return fmt.Errorf("ALARM_ESCALATE_%s: %w", level, err)
+		}
+	}
+	if c.StatsRefreshInterval < 0 {
+		return fmt.Errorf("STATS_REFRESH_INTERVAL: must not be negative, got %s", c.StatsRefreshInterval)
+	}
+	if c.OnlineDebounce < 0 {
+		return fmt.Errorf("ONLINE_DEBOUNCE: must not be negative, got %s", c.OnlineDebounce)
+	}
+	for dt, period := range c.OnlineDebounceByType {
+		if !dt.IsValid() {
+			return fmt.Errorf("ONLINE_DEBOUNCE_BY_TYPE: unknown device type %q", dt)
+		}
+		if period < 0 {
+			return fmt.Errorf("ONLINE_DEBOUNCE_BY_TYPE: %s must not be negative, got %s", dt, period)
+		}
+	}
+	for dt, presence := range c.AlarmPresenceByType {
+		if !dt.IsValid() {
+			return fmt.Errorf("ALARM_PRESENCE_BY_TYPE: unknown device type %q", dt)
+		}
+		if !presence.IsValid() {
+			return fmt.Errorf("ALARM_PRESENCE_BY_TYPE: %s must be off, seen or online, got %q", dt, presence)
+		}
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("TRUSTED_PROXIES: %q is not an IP address or CIDR range", proxy)
+		}
+	}
+	for name, weight := range map[string]float64{
+		"HEALTH_WEIGHT_ONLINE":    c.HealthWeightOnline,
+		"HEALTH_WEIGHT_HEARTBEAT": c.HealthWeightHeartbeat,
+		"HEALTH_WEIGHT_ALARMS":    c.HealthWeightAlarms,
+	} {
+		if weight < 0 {
+			return fmt.Errorf("%s: must not be negative, got %g", name, weight)
+		}
+	}
+	if c.HealthHeartbeatWindow < 0 {
+		return fmt.Errorf("HEALTH_HEARTBEAT_WINDOW: must not be negative, got %s", c.HealthHeartbeatWindow)
+	}
+	if c.HealthAlarmWindow < 0 {
+		return fmt.Errorf("HEALTH_ALARM_WINDOW: must not be negative, got %s", c.HealthAlarmWindow)
+	}
+	if c.HealthAlarmLimit < 0 {
+		return fmt.Errorf("HEALTH_ALARM_LIMIT: must not be negative, got %d", c.HealthAlarmLimit)
+	}
+	if c.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_REQUESTS: must not be negative, got %d", c.MaxConcurrentRequests)
 	}
+	if c.ConcurrencyRetryAfter < 0 {
+		return fmt.Errorf("CONCURRENCY_RETRY_AFTER: must not be negative, got %s", c.ConcurrencyRetryAfter)
+	}
+	switch c.ValidationErrorStatus {
+	case 0, http.StatusBadRequest, http.StatusUnprocessableEntity:
+	default:
+		return fmt.Errorf("VALIDATION_ERROR_STATUS: must be 400 or 422, got %d", c.ValidationErrorStatus)
+	}
+	if c.DisplayTimezone != "" {
+		if _, err := time.LoadLocation(c.DisplayTimezone); err != nil {
+			return fmt.Errorf("DISPLAY_TIMEZONE: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// AlarmTTL returns the auto-clear TTL of an alarm level; zero means its alarms never expire
+func (c *Config) AlarmTTL(level string) time.Duration {
+	switch level {
+	case models.AlarmLevelInfo:
+		return c.AlarmTTLInfo
+	case models.AlarmLevelWarning:
+		return c.AlarmTTLWarning
+	case models.AlarmLevelCritical:
+		return c.AlarmTTLCritical
+	}
+	return c.AlarmTTLLevels[level]
+}
+
+// AlarmTTLs returns the auto-clear TTL for each configured alarm level, omitting levels that
+// never expire
+func (c *Config) AlarmTTLs() map[string]time.Duration {
+	ttls := make(map[string]time.Duration)
+	for _, level := range c.AlarmLevelRegistry().IDs() {
+		if ttl := c.AlarmTTL(level); ttl > 0 {
+			ttls[level] = ttl
+		}
+	}
+
+	return ttls
+}
+
+// AlarmLevelRegistry returns the configured alarm levels. Levels that fail to parse give the
+// built-in levels; Validate reports them.
+func (c *Config) AlarmLevelRegistry() *models.AlarmLevels {
+	levels, err := models.NewAlarmLevels(c.AlarmLevels)
+	if err != nil {
+		return models.DefaultAlarmLevels()
+	}
+	return levels
+}
+
+// AlarmEscalationRules returns the escalation rule of each alarm level that escalates. Rules that
+// fail to parse are left out; Validate reports them.
+func (c *Config) AlarmEscalationRules() map[string]models.EscalationRule {
+	levels := c.AlarmLevelRegistry()
+	rules := make(map[string]models.EscalationRule)
+	for level, spec := range c.alarmEscalationSpecs() {
+		if rule, err := parseEscalationRule(levels, level, spec); err == nil {
+			rules[level] = rule
+		}
+	}
+
+	return rules
+}
+
+// alarmEscalationSpecs returns the escalation rule, as written, of each alarm level that has one
+func (c *Config) alarmEscalationSpecs() map[string]string {
+	specs := make(map[string]string, len(c.AlarmEscalateLevels)+2)
+	for level, spec := range c.AlarmEscalateLevels {
+		specs[level] = spec
+	}
+	if c.AlarmEscalateInfo != "" {
+		specs[models.AlarmLevelInfo] = c.AlarmEscalateInfo
+	}
+	if c.AlarmEscalateWarning != "" {
+		specs[models.AlarmLevelWarning] = c.AlarmEscalateWarning
+	}
+
+	return specs
+}
+
+// parseEscalationRule parses a DELAY:TARGET[:renotify] escalation rule for alarms of level, whose
+// target must be a more severe level in levels
+func parseEscalationRule(levels *models.AlarmLevels, level, spec string) (models.EscalationRule, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return models.EscalationRule{}, fmt.Errorf("%q must be DELAY:TARGET or DELAY:TARGET:renotify", spec)
+	}
+
+	delay, err := time.ParseDuration(parts[0])
+	if err != nil || delay <= 0 {
+		return models.EscalationRule{}, fmt.Errorf("delay %q must be a positive duration", parts[0])
+	}
+	rule := models.EscalationRule{Level: level, Target: strings.ToUpper(parts[1]), Delay: delay}
+	if !levels.IsMoreSevere(rule.Target, level) {
+		return models.EscalationRule{}, fmt.Errorf("target %q must be a more severe level than %s", parts[1], level)
+	}
+	if len(parts) == 3 {
+		if parts[2] != "renotify" {
+			return models.EscalationRule{}, fmt.Errorf("unknown option %q, expected renotify", parts[2])
+		}
+		rule.Renotify = true
+	}
+
+	return rule, nil
+}
+
+// getEnvInt reads an integer environment variable, falling back to def when unset or invalid
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %d", value, key, def)
+		return def
+	}
+
+	return parsed
+}
+
+// getEnvFloat reads a floating point environment variable, falling back to def when unset or invalid
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %g", value, key, def)
+		return def
+	}
+
+	return parsed
+}
+
+// getEnvBool reads a boolean environment variable, falling back to def when unset or invalid
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %t", value, key, def)
+		return def
+	}
+
+	return parsed
+}
+
+// getEnvFileMode reads an octal file mode environment variable such as "0660", falling back to def
+// when unset or invalid
+func getEnvFileMode(key string, def os.FileMode) os.FileMode {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || parsed > 0o777 {
+		log.Printf("Invalid value %q for %s, using default %#o", value, key, def)
+		return def
+	}
+
+	return os.FileMode(parsed)
+}
+
+// getEnvList reads a comma-separated environment variable, falling back to def when unset.
+// Entries are trimmed and empty ones dropped.
+func getEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
+
+// getEnvDeviceTypes reads a comma-separated list of device types, which are upper case
+func getEnvDeviceTypes(key string) []models.DeviceType {
+	var types []models.DeviceType
+	for _, entry := range getEnvList(key, nil) {
+		types = append(types, models.DeviceType(strings.ToUpper(entry)))
+	}
+
+	return types
+}
+
+// getEnvDeviceTypeDurations reads a comma separated list of TYPE=duration entries, such as
+// "CAMERA=5m,LOCK=30s", with device types upper-cased. Malformed entries are logged and skipped.
+func getEnvDeviceTypeDurations(key string) map[models.DeviceType]time.Duration {
+	durations := make(map[models.DeviceType]time.Duration)
+	for _, entry := range getEnvList(key, nil) {
+		name, value, ok := strings.Cut(entry, "=")
+		parsed, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil {
+			log.Printf("Invalid entry %q in %s, ignoring it", entry, key)
+			continue
+		}
+		durations[models.DeviceType(strings.ToUpper(strings.TrimSpace(name)))] = parsed
+	}
+
+	return durations
+}
+
+// getEnvAlarmPresence reads a comma separated list of TYPE=mode entries, such as
+// "MOTION_SENSOR=online,LOCK=seen", with device types upper-cased and modes lower-cased.
+// Entries without a mode are logged and skipped.
+func getEnvAlarmPresence(key string) models.AlarmPresencePolicy {
+	policy := make(models.AlarmPresencePolicy)
+	for _, entry := range getEnvList(key, nil) {
+		name, mode, ok := strings.Cut(entry, "=")
+		if !ok {
+			log.Printf("Invalid entry %q in %s, ignoring it", entry, key)
+			continue
+		}
+		policy[models.DeviceType(strings.ToUpper(strings.TrimSpace(name)))] = models.AlarmPresence(strings.ToLower(strings.TrimSpace(mode)))
+	}
+
+	return policy
+}
+
+// getEnvDuration reads a duration environment variable such as "5m", falling back to def when unset or invalid
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %s", value, key, def)
+		return def
+	}
+
+	return parsed
 }
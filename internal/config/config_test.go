@@ -0,0 +1,390 @@
+package config
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestNewDefaultDeviceSort(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           string
+		expectedSort  string
+		expectedOrder string
+	}{
+		{"Unset", "", "created_at", "desc"},
+		{"Field and order", "name:asc", "name", "asc"},
+		{"Field only", "updated_at", "updated_at", "desc"},
+		{"Uppercase order", "name:ASC", "name", "asc"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_DEVICE_SORT", tc.env)
+
+			cfg := New()
+			if cfg.DefaultDeviceSortBy != tc.expectedSort || cfg.DefaultDeviceSortOrder != tc.expectedOrder {
+				t.Errorf("DEFAULT_DEVICE_SORT=%q gave %s %s; expected %s %s", tc.env,
+					cfg.DefaultDeviceSortBy, cfg.DefaultDeviceSortOrder, tc.expectedSort, tc.expectedOrder)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		sortBy      string
+		sortOrder   string
+		timezone    string
+		defaultSize int
+		maxSize     int
+		expectError bool
+	}{
+		{"Valid", "name", "asc", "", 100, 1000, false},
+		{"Unknown sort field", "password", "asc", "", 100, 1000, true},
+		{"Invalid sort order", "name", "up", "", 100, 1000, true},
+		{"Display timezone", "name", "asc", "Europe/London", 100, 1000, false},
+		{"Unknown display timezone", "name", "asc", "Mars/Olympus", 100, 1000, true},
+		{"Default page size equal to maximum", "name", "asc", "", 50, 50, false},
+		{"Default page size above maximum", "name", "asc", "", 100, 50, true},
+		{"Zero default page size", "name", "asc", "", 0, 1000, true},
+		{"Zero maximum page size", "name", "asc", "", 0, 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				DefaultDeviceSortBy:    tc.sortBy,
+				DefaultDeviceSortOrder: tc.sortOrder,
+				DisplayTimezone:        tc.timezone,
+				DefaultPageSize:        tc.defaultSize,
+				MaxPageSize:            tc.maxSize,
+			}
+
+			err := cfg.Validate()
+			if tc.expectError && err == nil {
+				t.Errorf("Expected an error but got nil")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetEnvList(t *testing.T) {
+	def := []string{"password"}
+
+	t.Setenv("TEST_LIST", "")
+	if got := getEnvList("TEST_LIST", def); len(got) != 1 || got[0] != "password" {
+		t.Errorf("Expected the default when unset, got %v", got)
+	}
+
+	t.Setenv("TEST_LIST", " token, ,secret ")
+	if got := getEnvList("TEST_LIST", def); len(got) != 2 || got[0] != "token" || got[1] != "secret" {
+		t.Errorf("Expected [token secret], got %v", got)
+	}
+}
+
+func TestAllowedDeviceTypes(t *testing.T) {
+	t.Setenv("ALLOWED_DEVICE_TYPES", "camera, LOCK")
+	cfg := New()
+	if len(cfg.AllowedDeviceTypes) != 2 || cfg.AllowedDeviceTypes[0] != models.DeviceTypeCamera || cfg.AllowedDeviceTypes[1] != models.DeviceTypeLock {
+		t.Fatalf("Expected CAMERA and LOCK, got %v", cfg.AllowedDeviceTypes)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+
+	cfg.AllowedDeviceTypes = append(cfg.AllowedDeviceTypes, "TOASTER")
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown device type")
+	}
+}
+
+func TestHealthWeights(t *testing.T) {
+	t.Setenv("HEALTH_WEIGHT_ONLINE", "0.5")
+	t.Setenv("HEALTH_WEIGHT_ALARMS", "-1")
+	cfg := New()
+	if cfg.HealthWeightOnline != 0.5 || cfg.HealthWeightHeartbeat != 30 {
+		t.Errorf("Expected weights 0.5 and the default 30, got %g and %g", cfg.HealthWeightOnline, cfg.HealthWeightHeartbeat)
+	}
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "HEALTH_WEIGHT_ALARMS") {
+		t.Errorf("Expected a negative weight error, got %v", err)
+	}
+}
+
+func TestOnlineDebounceByType(t *testing.T) {
+	t.Setenv("ONLINE_DEBOUNCE_BY_TYPE", "camera=5m, LOCK=0s, THERMOSTAT, DOORBELL=10m")
+	cfg := New()
+	if len(cfg.OnlineDebounceByType) != 3 || cfg.OnlineDebounceByType[models.DeviceTypeCamera] != 5*time.Minute {
+		t.Fatalf("Expected three overrides with CAMERA at 5m, got %v", cfg.OnlineDebounceByType)
+	}
+	if _, ok := cfg.OnlineDebounceByType[models.DeviceTypeLock]; !ok {
+		t.Errorf("Expected a zero override for LOCK, got %v", cfg.OnlineDebounceByType)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "DOORBELL") {
+		t.Errorf("Expected an unknown device type error, got %v", err)
+	}
+}
+
+func TestAlarmPresenceByType(t *testing.T) {
+	t.Setenv("ALARM_PRESENCE_BY_TYPE", "motion_sensor=Online, LOCK=seen, CAMERA")
+	cfg := New()
+	if len(cfg.AlarmPresenceByType) != 2 || cfg.AlarmPresenceByType.For(models.DeviceTypeMotionSensor) != models.AlarmPresenceOnline {
+		t.Fatalf("Expected two entries with MOTION_SENSOR online, got %v", cfg.AlarmPresenceByType)
+	}
+	if presence := cfg.AlarmPresenceByType.For(models.DeviceTypeCamera); presence != models.AlarmPresenceOff {
+		t.Errorf("Expected an unlisted type to be off, got %q", presence)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the policy to be valid, got %v", err)
+	}
+
+	t.Setenv("ALARM_PRESENCE_BY_TYPE", "LOCK=always")
+	if err := New().Validate(); err == nil || !strings.HasPrefix(err.Error(), "ALARM_PRESENCE_BY_TYPE") {
+		t.Errorf("Expected an unknown mode error, got %v", err)
+	}
+}
+
+func TestValidationErrorStatusValidate(t *testing.T) {
+	for status, valid := range map[int]bool{0: true, 400: true, 422: true, 409: false} {
+		cfg := &Config{DefaultDeviceSortBy: "name", DefaultDeviceSortOrder: "asc", DefaultPageSize: 100, MaxPageSize: 1000, ValidationErrorStatus: status}
+		if err := cfg.Validate(); (err == nil) != valid {
+			t.Errorf("Status %d: expected valid %t, got %v", status, valid, err)
+		}
+	}
+}
+
+func TestMaxRecentDevices(t *testing.T) {
+	if cfg := New(); cfg.MaxRecentDevices != 50 {
+		t.Errorf("Expected a cap of 50 by default, got %d", cfg.MaxRecentDevices)
+	}
+
+	t.Setenv("MAX_RECENT_DEVICES", "-1")
+	if err := New().Validate(); err == nil || !strings.HasPrefix(err.Error(), "MAX_RECENT_DEVICES") {
+		t.Errorf("Expected a negative cap error, got %v", err)
+	}
+}
+
+func TestDBReconnectCheckInterval(t *testing.T) {
+	if cfg := New(); cfg.DBReconnectCheckInterval != 5*time.Second {
+		t.Errorf("Expected the database file checked every 5s by default, got %s", cfg.DBReconnectCheckInterval)
+	}
+
+	t.Setenv("DB_RECONNECT_CHECK_INTERVAL", "-1s")
+	if err := New().Validate(); err == nil || !strings.HasPrefix(err.Error(), "DB_RECONNECT_CHECK_INTERVAL") {
+		t.Errorf("Expected a negative interval error, got %v", err)
+	}
+}
+
+func TestAlarmHistoryRetention(t *testing.T) {
+	if cfg := New(); cfg.AlarmHistoryRetention != 0 || cfg.AlarmHistoryPruneInterval != time.Hour {
+		t.Errorf("Expected history kept forever and pruned hourly by default, got %s and %s", cfg.AlarmHistoryRetention, cfg.AlarmHistoryPruneInterval)
+	}
+
+	t.Setenv("ALARM_HISTORY_RETENTION", "-1h")
+	if err := New().Validate(); err == nil || !strings.HasPrefix(err.Error(), "ALARM_HISTORY_RETENTION") {
+		t.Errorf("Expected a negative retention error, got %v", err)
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	cfg := New()
+	if cfg.MaxConcurrentRequests != 0 || cfg.ConcurrencyRetryAfter != time.Second {
+		t.Errorf("Expected no limit and a 1s retry by default, got %d and %s", cfg.MaxConcurrentRequests, cfg.ConcurrencyRetryAfter)
+	}
+
+	t.Setenv("MAX_CONCURRENT_REQUESTS", "-1")
+	cfg = New()
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "MAX_CONCURRENT_REQUESTS") {
+		t.Errorf("Expected a negative limit error, got %v", err)
+	}
+}
+
+func TestAlarmLevels(t *testing.T) {
+	t.Setenv("ALARM_LEVELS", "info, warning, critical, emergency")
+	t.Setenv("ALARM_ESCALATE_CRITICAL", "10m:emergency")
+	t.Setenv("ALARM_TTL_EMERGENCY", "2h")
+	cfg := New()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !cfg.AlarmLevelRegistry().IsValid("EMERGENCY") {
+		t.Errorf("Expected EMERGENCY to be a level, got %v", cfg.AlarmLevelRegistry().IDs())
+	}
+	if rule := cfg.AlarmEscalationRules()[models.AlarmLevelCritical]; rule.Target != "EMERGENCY" || rule.Delay != 10*time.Minute {
+		t.Errorf("Expected CRITICAL to escalate to EMERGENCY, got %+v", rule)
+	}
+	if ttls := cfg.AlarmTTLs(); ttls["EMERGENCY"] != 2*time.Hour || ttls[models.AlarmLevelInfo] != time.Hour {
+		t.Errorf("Expected EMERGENCY alarms to expire after 2h alongside the built-in TTLs, got %v", ttls)
+	}
+
+	t.Setenv("ALARM_TTL_EMERGENCY", "-1h")
+	if err := New().Validate(); err == nil || !strings.HasPrefix(err.Error(), "ALARM_TTL_EMERGENCY") {
+		t.Errorf("Expected a negative TTL error, got %v", err)
+	}
+	t.Setenv("ALARM_TTL_EMERGENCY", "")
+
+	t.Setenv("ALARM_LEVELS", "EMERGENCY,INFO,CRITICAL")
+	if err := New().Validate(); err == nil || !strings.HasPrefix(err.Error(), "ALARM_LEVELS") {
+		t.Errorf("Expected a missing built-in level error, got %v", err)
+	}
+}
+
+func TestParseEscalationRule(t *testing.T) {
+	rule, err := parseEscalationRule(nil, models.AlarmLevelWarning, "15m:critical:renotify")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if rule.Target != models.AlarmLevelCritical || rule.Delay != 15*time.Minute || !rule.Renotify {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+
+	for _, spec := range []string{"15m:INFO", "15m:CRITICAL:page", "soon:CRITICAL", "0s:CRITICAL", "15m"} {
+		if _, err := parseEscalationRule(nil, models.AlarmLevelWarning, spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestTrustedProxiesValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		proxies     []string
+		expectError bool
+	}{
+		{"None", nil, false},
+		{"Addresses and ranges", []string{"10.0.0.1", "172.16.0.0/12", "::1"}, false},
+		{"Hostname", []string{"nginx"}, true},
+		{"Malformed range", []string{"10.0.0.0/33"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				DefaultDeviceSortBy:    "name",
+				DefaultDeviceSortOrder: "asc",
+				DefaultPageSize:        100,
+				MaxPageSize:            1000,
+				TrustedProxies:         tc.proxies,
+			}
+
+			err := cfg.Validate()
+			if tc.expectError && err == nil {
+				t.Errorf("Expected an error but got nil")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestServerAddressAndSocketMode(t *testing.T) {
+	t.Setenv("SERVER_ADDRESS", ":8080,unix:/run/gohome.sock")
+	t.Setenv("SERVER_SOCKET_MODE", "0666")
+	cfg := New()
+	if cfg.SocketMode != 0o666 {
+		t.Errorf("Expected socket mode 0666, got %#o", cfg.SocketMode)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+
+	t.Setenv("SERVER_SOCKET_MODE", "rw-rw----")
+	if cfg := New(); cfg.SocketMode != 0o660 {
+		t.Errorf("Expected an invalid mode to fall back to 0660, got %#o", cfg.SocketMode)
+	}
+
+	for _, addr := range []string{":8080,", "unix:"} {
+		cfg.ServerAddress = addr
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected an error for SERVER_ADDRESS %q", addr)
+		}
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		expected string
+	}{
+		{"./data.db", "./data.db"},
+		{"file:replica.db?mode=ro", "file:replica.db?mode=ro"},
+		{"file:replica.db?_auth_user=admin&_auth_pass=hunter2", "file:replica.db?_auth_user=admin&_auth_pass=REDACTED"},
+		{"postgres://app:hunter2@db:5432/home?sslmode=disable", "postgres://app:REDACTED@db:5432/home?sslmode=disable"},
+		{"host=db user=app password=hunter2 dbname=home", "host=db user=app password=REDACTED dbname=home"},
+	}
+
+	for _, tc := range tests {
+		if got := RedactDSN(tc.dsn); got != tc.expected {
+			t.Errorf("RedactDSN(%q) = %q, expected %q", tc.dsn, got, tc.expected)
+		}
+	}
+}
+
+func TestEffective(t *testing.T) {
+	cfg := &Config{
+		ServerAddress:        ":8080",
+		SocketMode:           0o660,
+		ReadDBDSN:            "postgres://app:hunter2@db/home",
+		AdminToken:           "hunter2",
+		CursorSecret:         "",
+		StaleThreshold:       5 * time.Minute,
+		OnlineDebounceByType: map[models.DeviceType]time.Duration{models.DeviceTypeCamera: time.Minute},
+	}
+
+	settings := cfg.Effective()
+	if settings["AdminToken"] != Redacted {
+		t.Errorf("Expected the admin token redacted, got %v", settings["AdminToken"])
+	}
+	if settings["CursorSecret"] != "" {
+		t.Errorf("Expected an unset secret shown as unset, got %v", settings["CursorSecret"])
+	}
+	if settings["ReadDBDSN"] != "postgres://app:REDACTED@db/home" {
+		t.Errorf("Expected the replica password redacted, got %v", settings["ReadDBDSN"])
+	}
+	if settings["StaleThreshold"] != "5m0s" || settings["SocketMode"] != "0660" {
+		t.Errorf("Expected readable durations and modes, got %v and %v", settings["StaleThreshold"], settings["SocketMode"])
+	}
+	if byType, ok := settings["OnlineDebounceByType"].(map[string]any); !ok || byType["CAMERA"] != "1m0s" {
+		t.Errorf("Expected per-type durations by type, got %v", settings["OnlineDebounceByType"])
+	}
+	if _, ok := settings["ServerAddress"]; !ok || len(settings) < 10 {
+		t.Errorf("Expected every setting listed, got %d", len(settings))
+	}
+}
+
+func TestEffectiveFields(t *testing.T) {
+	cfg := &Config{ServerAddress: ":8080", AdminToken: "hunter2", StaleThreshold: 5 * time.Minute}
+
+	fields := cfg.EffectiveFields()
+	if !sort.StringsAreSorted(fields) || len(fields) != len(cfg.Effective()) {
+		t.Fatalf("Expected every setting, sorted by name, got %v", fields)
+	}
+	for _, expected := range []string{`AdminToken="REDACTED"`, `ServerAddress=":8080"`, `StaleThreshold="5m0s"`, `GzipEnabled=false`} {
+		if !slices.Contains(fields, expected) {
+			t.Errorf("Expected %s among the fields", expected)
+		}
+	}
+}
+
+func TestIsSecretSetting(t *testing.T) {
+	for _, name := range []string{"AdminToken", "CursorSecret", "MQTTPassword", "WebhookAPIKey"} {
+		if !IsSecretSetting(name) {
+			t.Errorf("Expected %s to be secret", name)
+		}
+	}
+	for _, name := range []string{"DBPath", "DebugRedactKeys", "ServerAddress"} {
+		if IsSecretSetting(name) {
+			t.Errorf("Expected %s not to be secret", name)
+		}
+	}
+}
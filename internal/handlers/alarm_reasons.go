@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+// getAlarmReasons handles GET /api/admin/alarm-reasons, listing the alarm reason catalog by code
+func (h *Handler) getAlarmReasons(c *gin.Context) {
+	reasons, err := h.deviceService.ListAlarmReasons()
+	if err != nil {
+		respondAlarmReasonError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reasons)
+}
+
+// putAlarmReason handles PUT /api/admin/alarm-reasons/:code, adding the reason with 201 or
+// rewording an existing one with 200. Alarms already recorded keep the text they were given.
+func (h *Handler) putAlarmReason(c *gin.Context) {
+	var req models.AlarmReasonRequest
+	if bindErr := h.bindStrictJSON(c, &req); bindErr != nil {
+		respondError(c, http.StatusBadRequest, bindErr.Error())
+		return
+	}
+
+	reason := &models.AlarmReason{Code: c.Param("code"), Text: req.Text, LevelHint: req.LevelHint}
+	if !h.validated(c, validation.ValidateAlarmReason(reason, h.alarmLevels)) {
+		return
+	}
+
+	saved, created, err := h.deviceService.SaveAlarmReason(reason)
+	if err != nil {
+		respondAlarmReasonError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, saved)
+}
+
+// deleteAlarmReason handles DELETE /api/admin/alarm-reasons/:code. Alarms giving the code are
+// refused from then on; those already recorded keep it.
+func (h *Handler) deleteAlarmReason(c *gin.Context) {
+	if err := h.deviceService.DeleteAlarmReason(c.Param("code")); err != nil {
+		respondAlarmReasonError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondAlarmReasonError writes the response for a failed alarm reason catalog operation
+func respondAlarmReasonError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrAlarmReasonNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrAlarmReasonsUnsupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,824 @@
+package handlers_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/internal/testutil/apitest"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/service"
+)
+
+func TestAPI_ListDevicesByOwner(t *testing.T) {
+	server := apitest.New(t, nil)
+	server.Seed(9)
+
+	w := server.Do(http.MethodGet, "/api/devices?owned_by=bob", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var devices []models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &devices); err != nil {
+		t.Fatalf("Failed to decode devices: %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("Expected bob's 3 devices, got %d", len(devices))
+	}
+	for _, device := range devices {
+		if device.OwnedBy != "bob" {
+			t.Errorf("Expected only bob's devices, got %s owned by %s", device.Name, device.OwnedBy)
+		}
+	}
+}
+
+func TestAPI_TriggerAlarm(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(3)
+	target := devices[1]
+
+	w := server.Do(http.MethodPost, "/api/devices/"+strconv.FormatInt(target.ID, 10)+"/alarm", `{"reason":"[WARNING] Smoke detected","level":"WARNING"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = server.Do(http.MethodGet, "/api/alarms/active", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var active []models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &active); err != nil {
+		t.Fatalf("Failed to decode active alarms: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != target.ID || active[0].LastAlarmLevel != models.AlarmLevelWarning {
+		t.Errorf("Expected only %s alarmed at WARNING, got %+v", target.Name, active)
+	}
+
+	// The alarm is stored, not just reported
+	stored, err := server.Repo.GetByID(target.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !stored.AlarmActive {
+		t.Errorf("Expected the stored device to have an active alarm")
+	}
+
+	w = server.Do(http.MethodPost, "/api/devices/"+strconv.FormatInt(target.ID, 10)+"/alarm", `{"reason":`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"error":`) {
+		t.Errorf("Expected status 400 with an error for a malformed body, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPI_TriggerAlarmUnknownDevice(t *testing.T) {
+	server := apitest.New(t, nil)
+
+	w := server.Do(http.MethodPost, "/api/devices/42/alarm", `{"reason":"[INFO] Door open","level":"INFO"}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPI_TriggerAlarmClientEventID(t *testing.T) {
+	server := apitest.New(t, nil)
+	target := server.Seed(1)[0]
+	path := "/api/devices/" + strconv.FormatInt(target.ID, 10) + "/alarm"
+	const eventID = "3f2b8c1e-9a4d-4e6f-8b1a-2c3d4e5f6a7b"
+
+	w := server.Do(http.MethodPost, path, `{"reason":"Smoke detected","level":"WARNING","client_event_id":"`+eventID+`"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A resubmission is answered with the alarm already recorded, even with a different body
+	w = server.Do(http.MethodPost, path, `{"reason":"Smoke detected again","level":"CRITICAL","client_event_id":"`+eventID+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var duplicate struct {
+		Duplicate bool               `json:"duplicate"`
+		Alarm     models.AlarmRecord `json:"alarm"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &duplicate); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !duplicate.Duplicate || duplicate.Alarm.ClientEventID != eventID || duplicate.Alarm.Level != models.AlarmLevelWarning {
+		t.Errorf("Expected the first WARNING alarm back, got %+v", duplicate)
+	}
+
+	w = server.Do(http.MethodGet, "/api/devices/"+strconv.FormatInt(target.ID, 10)+"/alarms?client_event_id="+eventID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var found []models.AlarmRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &found); err != nil {
+		t.Fatalf("Failed to decode alarms: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != duplicate.Alarm.ID {
+		t.Errorf("Expected the one alarm for the client event, got %+v", found)
+	}
+
+	if w := server.Do(http.MethodGet, "/api/devices/"+strconv.FormatInt(target.ID, 10)+"/alarms?client_event_id=a%20b", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid client_event_id, got %d", w.Code)
+	}
+	if w := server.Do(http.MethodPost, path, `{"reason":"Smoke detected","level":"WARNING","client_event_id":"a b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an invalid client_event_id, got %d", w.Code)
+	}
+}
+
+func TestAPI_CreateDevice(t *testing.T) {
+	server := apitest.New(t, nil)
+	create := testutil.NewDevice().WithName("FrontDoor").WithType(models.DeviceTypeLock).WithOwner("alice").BuildCreate()
+
+	body, err := json.Marshal(create)
+	if err != nil {
+		t.Fatalf("Failed to encode device: %v", err)
+	}
+	w := server.Do(http.MethodPost, "/api/devices", string(body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var created models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if created.ID == 0 || created.Name != "FrontDoor" || created.DeviceType != models.DeviceTypeLock {
+		t.Errorf("Unexpected created device %+v", created)
+	}
+}
+
+// decodeKeys decodes a JSON object and returns its keys, sorted
+func decodeKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		t.Fatalf("Failed to decode object: %v", err)
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestAPI_ListDevicesFields(t *testing.T) {
+	server := apitest.New(t, nil)
+	server.Seed(9)
+
+	// Field selection composes with filters and cursor pagination
+	path := "/api/devices?owned_by=bob&fields=name,is_online&limit=2&sort_by=created_at"
+	var names []string
+	for pages := 0; path != ""; pages++ {
+		if pages > 2 {
+			t.Fatalf("Expected 2 pages, still paging at %s", path)
+		}
+		w := server.Do(http.MethodGet, path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var objects []json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &objects); err != nil {
+			t.Fatalf("Failed to decode devices: %v", err)
+		}
+		for _, object := range objects {
+			if keys := decodeKeys(t, object); !slices.Equal(keys, []string{"id", "is_online", "name"}) {
+				t.Errorf("Expected only id, is_online and name, got %v", keys)
+			}
+			var device models.Device
+			if err := json.Unmarshal(object, &device); err != nil {
+				t.Fatalf("Failed to decode device: %v", err)
+			}
+			names = append(names, device.Name)
+		}
+
+		path = ""
+		if cursor := w.Header().Get("X-Next-Cursor"); cursor != "" {
+			path = "/api/devices?owned_by=bob&fields=name,is_online&limit=2&after=" + cursor
+		}
+	}
+	if !slices.Equal(names, []string{"Device2", "Device5", "Device8"}) {
+		t.Errorf("Expected bob's devices in creation order, got %v", names)
+	}
+
+	// Fuzzy search ranks by name even when names are not selected
+	w := server.Do(http.MethodGet, "/api/devices?name=Devce4&fuzzy=true&fields=owned_by", "")
+	var ranked []models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &ranked); err != nil {
+		t.Fatalf("Failed to decode devices: %v", err)
+	}
+	if len(ranked) == 0 || ranked[0].Name != "" || ranked[0].OwnedBy != "alice" {
+		t.Errorf("Expected Device4's owner alice first without its name, got %+v", ranked)
+	}
+
+	// Streamed devices are limited the same way
+	w = server.Do(http.MethodGet, "/api/devices?fields=device_type&stream=true", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 9 {
+		t.Fatalf("Expected 9 streamed devices, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if keys := decodeKeys(t, []byte(line)); !slices.Equal(keys, []string{"device_type", "id"}) {
+			t.Errorf("Expected only device_type and id, got %v", keys)
+		}
+	}
+}
+
+func TestAPI_GetDeviceFields(t *testing.T) {
+	server := apitest.New(t, nil)
+	target := server.Seed(2)[1]
+	path := "/api/devices/" + strconv.FormatInt(target.ID, 10)
+
+	if _, err := server.Repo.TriggerAlarm(target.ID, models.AlarmLevelWarning, "Smoke", "sensor", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	// The expansion and local renderings are kept, the latter limited to the selected fields
+	w := server.Do(http.MethodGet, path+"?fields=name,created_at&with_alarm_count=true&tz=Europe/London", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if keys := decodeKeys(t, w.Body.Bytes()); !slices.Equal(keys, []string{"alarm_count", "created_at", "id", "local", "name"}) {
+		t.Errorf("Unexpected fields %v", keys)
+	}
+	var device struct {
+		models.Device
+		Local json.RawMessage `json:"local"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &device); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if device.ID != target.ID || device.Name != target.Name || device.AlarmCount == nil || *device.AlarmCount != 1 {
+		t.Errorf("Unexpected device %+v", device.Device)
+	}
+	if keys := decodeKeys(t, device.Local); !slices.Equal(keys, []string{"created_at", "timezone"}) {
+		t.Errorf("Expected local created_at and timezone, got %v", keys)
+	}
+
+	// Selecting alarm_count loads it without with_alarm_count
+	w = server.Do(http.MethodGet, path+"?fields=alarm_count", "")
+	if keys := decodeKeys(t, w.Body.Bytes()); !slices.Equal(keys, []string{"alarm_count", "id"}) {
+		t.Errorf("Expected only alarm_count and id, got %v", keys)
+	}
+
+	if w := server.Do(http.MethodGet, "/api/devices/999?fields=name", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", w.Code)
+	}
+}
+
+func TestAPI_InvalidFields(t *testing.T) {
+	server := apitest.New(t, nil)
+	target := server.Seed(1)[0]
+
+	for _, path := range []string{
+		"/api/devices?fields=name,secret",
+		"/api/devices/" + strconv.FormatInt(target.ID, 10) + "?fields=local",
+	} {
+		w := server.Do(http.MethodGet, path, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, w.Code)
+			continue
+		}
+		if !strings.Contains(w.Body.String(), "is_online") {
+			t.Errorf("%s: expected the valid fields to be listed, got %s", path, w.Body.String())
+		}
+	}
+}
+
+func TestAPI_ListOwners(t *testing.T) {
+	server := apitest.New(t, nil)
+	server.Seed(6)
+
+	owners := func(query string) []string {
+		t.Helper()
+		w := server.Do(http.MethodGet, "/api/owners"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var owners []string
+		if err := json.Unmarshal(w.Body.Bytes(), &owners); err != nil {
+			t.Fatalf("Failed to decode owners: %v", err)
+		}
+		return owners
+	}
+
+	if listed := owners(""); !slices.Equal(listed, []string{"alice", "bob", "carol"}) {
+		t.Errorf("Expected each owner once, got %v", listed)
+	}
+	if listed := owners("?limit=1&offset=1"); !slices.Equal(listed, []string{"bob"}) {
+		t.Errorf("Expected the second owner, got %v", listed)
+	}
+	if listed := owners("?search=CA"); !slices.Equal(listed, []string{"carol"}) {
+		t.Errorf("Expected owners starting with ca, got %v", listed)
+	}
+	if listed := owners("?search=zed"); listed == nil || len(listed) != 0 {
+		t.Errorf("Expected an empty list, got %v", listed)
+	}
+}
+
+func TestAPI_DeviceTypeLock(t *testing.T) {
+	server := apitest.New(t, nil, service.WithDeviceTypeLock())
+	devices := server.Seed(1)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	if w := server.Do(http.MethodPut, path, `{"device_type":"LOCK"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPut, path, `{"description":"Landing"}`); w.Code != http.StatusNoContent {
+		t.Errorf("Expected other fields to update, got %d: %s", w.Code, w.Body.String())
+	}
+
+	device, err := server.Repo.GetByID(devices[0].ID)
+	if err != nil || device.DeviceType != devices[0].DeviceType || device.Description != "Landing" {
+		t.Errorf("Expected the type kept and the description updated, got %+v, %v", device, err)
+	}
+}
+
+func TestAPI_RepeatedUpdate(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(1)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	// A triple-click sends the same update three times; only the first changes the device
+	for i, expected := range []string{"", "true", "true"} {
+		w := server.Do(http.MethodPut, path, `{"description":"Landing"}`)
+		if w.Code != http.StatusNoContent || w.Header().Get("X-No-Change") != expected {
+			t.Errorf("Request %d: expected 204 with X-No-Change %q, got %d with %q", i, expected, w.Code, w.Header().Get("X-No-Change"))
+		}
+	}
+
+	device, err := server.Repo.GetByID(devices[0].ID)
+	if err != nil || device.Description != "Landing" || device.Version != devices[0].Version+1 {
+		t.Errorf("Expected the description updated by a single change, got %+v, %v", device, err)
+	}
+}
+
+func TestAPI_StrictOwners(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.StrictOwners = true
+	server := apitest.New(t, cfg)
+	devices := server.Seed(3)
+
+	tests := []struct {
+		owner    string
+		expected int
+		message  string
+	}{
+		{"bob", http.StatusCreated, ""},
+		{"Bob", http.StatusUnprocessableEntity, "did you mean: bob"},
+		{"alcie", http.StatusUnprocessableEntity, "did you mean: alice"},
+		{"zed", http.StatusUnprocessableEntity, "is not a known owner"},
+	}
+	for _, tc := range tests {
+		w := server.Do(http.MethodPost, "/api/devices", `{"name":"Porch","device_type":"CAMERA","owned_by":"`+tc.owner+`"}`)
+		if w.Code != tc.expected || !strings.Contains(w.Body.String(), tc.message) {
+			t.Errorf("%s: expected status %d with %q, got %d: %s", tc.owner, tc.expected, tc.message, w.Code, w.Body.String())
+		}
+	}
+
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+	if w := server.Do(http.MethodPut, path, `{"owned_by":"carl"}`); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "carol") {
+		t.Errorf("Expected reassigning to an unknown owner refused with a suggestion, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPut, path, `{"owned_by":"carol"}`); w.Code != http.StatusNoContent {
+		t.Errorf("Expected reassigning to a known owner, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPI_CreateDeviceOnline(t *testing.T) {
+	server := apitest.New(t, nil)
+
+	for body, expected := range map[string]bool{
+		`{"name":"Porch","device_type":"CAMERA","owned_by":"alice","is_online":true}`: true,
+		`{"name":"Hall","device_type":"LOCK","owned_by":"alice"}`:                     false,
+	} {
+		w := server.Do(http.MethodPost, "/api/devices", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var created models.Device
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Failed to decode device: %v", err)
+		}
+		stored, err := server.Repo.GetByID(created.ID)
+		if err != nil || created.IsOnline != expected || stored.IsOnline != expected {
+			t.Errorf("%s: expected is_online %t, got %t in the response and %t stored (%v)", created.Name, expected, created.IsOnline, stored.IsOnline, err)
+		}
+	}
+}
+
+func TestAPI_DeleteDeviceConfirmation(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.RequireDeleteConfirmation = true
+	server := apitest.New(t, cfg)
+	devices := server.Seed(2)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	for _, query := range []string{"", "?confirm=", "?confirm=Device2", "?confirm=device1"} {
+		if w := server.Do(http.MethodDelete, path+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d: %s", query, w.Code, w.Body.String())
+		}
+	}
+	if w := server.Do(http.MethodDelete, "/api/devices/9999?confirm=Device1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", w.Code)
+	}
+	if device, _ := server.Repo.GetByID(devices[0].ID); device == nil {
+		t.Fatal("Expected the device to survive unconfirmed deletes")
+	}
+
+	if w := server.Do(http.MethodDelete, path+"?confirm=Device1", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if device, _ := server.Repo.GetByID(devices[0].ID); device != nil {
+		t.Error("Expected the confirmed delete to remove the device")
+	}
+}
+
+func TestAPI_RecentDevices(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.MaxRecentDevices = 3
+	server := apitest.New(t, cfg)
+	server.Seed(5)
+
+	recent := func(query string) (int, string) {
+		t.Helper()
+		w := server.Do(http.MethodGet, "/api/devices/recent"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var devices []models.Device
+		if err := json.Unmarshal(w.Body.Bytes(), &devices); err != nil {
+			t.Fatalf("Failed to decode devices: %v", err)
+		}
+		return len(devices), w.Header().Get("X-Page-Clamped")
+	}
+
+	if n, _ := recent("?limit=2"); n != 2 {
+		t.Errorf("Expected 2 devices, got %d", n)
+	}
+	// The default of 10 is above the cap, so the cap applies without clamping
+	if n, clamped := recent(""); n != 3 || clamped != "" {
+		t.Errorf("Expected the 3 devices of the cap, got %d (clamped %q)", n, clamped)
+	}
+	if n, clamped := recent("?limit=100"); n != 3 || clamped != "true" {
+		t.Errorf("Expected the limit clamped to 3, got %d (clamped %q)", n, clamped)
+	}
+	if w := server.Do(http.MethodGet, "/api/devices/recent?limit=0", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a zero limit, got %d", w.Code)
+	}
+}
+
+func TestAPI_ResetDevice(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(1)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	if w := server.Do(http.MethodPut, path, `{"description":"Old tenant's lock","is_online":true}`); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 updating the device, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPost, path+"/alarm", `{"reason":"Forced","level":"WARNING"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 triggering an alarm, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := server.Do(http.MethodPost, path+"/reset", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var reset models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &reset); err != nil {
+		t.Fatalf("Failed to decode the device: %v", err)
+	}
+	if reset.AlarmActive || reset.LastAlarmReason != "" || reset.IsOnline || reset.Description != "" {
+		t.Errorf("Expected the mutable state wiped, got %+v", reset)
+	}
+	if reset.ID != devices[0].ID || reset.Name != devices[0].Name || reset.DeviceType != devices[0].DeviceType || reset.OwnedBy != devices[0].OwnedBy {
+		t.Errorf("Expected the identity kept, got %+v", reset)
+	}
+
+	if w := server.Do(http.MethodPost, "/api/devices/9999/reset", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", w.Code)
+	}
+}
+
+func TestAPI_AlarmExport(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(2)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	for _, body := range []string{
+		`{"reason":"Door open, then \"shut\"","level":"INFO","triggered_by":"-2.3"}`,
+		`{"reason":"Tamper","level":"WARNING","triggered_by":"@panel"}`,
+	} {
+		if w := server.Do(http.MethodPost, path+"/alarm", body); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204 triggering an alarm, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := server.Do(http.MethodGet, path+"/alarms/export?format=csv", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, fmt.Sprintf(`filename="device-%d-alarms.csv"`, devices[0].ID)) {
+		t.Errorf("Expected a download filename, got %q", disposition)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse the export: %v", err)
+	}
+	if len(rows) != 3 || rows[0][3] != "reason" {
+		t.Fatalf("Expected a header and 2 alarms, got %v", rows)
+	}
+	if rows[1][3] != `[INFO] Door open, then "shut"` {
+		t.Errorf("Expected the oldest alarm first with its comma and quotes kept, got %q", rows[1][3])
+	}
+	if rows[1][7] != models.AlarmEventTriggered {
+		t.Errorf("Expected the alarm's event in the last column, got %q", rows[1][7])
+	}
+	if rows[1][4] != "'-2.3" || rows[2][4] != "'@panel" {
+		t.Errorf("Expected formulas neutralized, got %q and %q", rows[1][4], rows[2][4])
+	}
+
+	// The range is half-open, so ending it at the oldest alarm leaves only the header
+	oldest := url.QueryEscape(rows[1][6])
+	if w := server.Do(http.MethodGet, path+"/alarms/export?to="+oldest, ""); w.Code != http.StatusOK || strings.Count(w.Body.String(), "\n") != 1 {
+		t.Errorf("Expected only the header before the first alarm, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for query, status := range map[string]int{
+		"?format=json":    http.StatusBadRequest,
+		"?from=yesterday": http.StatusBadRequest,
+		"?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z": http.StatusBadRequest,
+	} {
+		if w := server.Do(http.MethodGet, path+"/alarms/export"+query, ""); w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", query, status, w.Code)
+		}
+	}
+	if w := server.Do(http.MethodGet, "/api/devices/9999/alarms/export", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", w.Code)
+	}
+}
+
+func TestAPI_AutoClearedAlarms(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(1)
+
+	w := server.Do(http.MethodPost, "/api/devices/"+strconv.FormatInt(devices[0].ID, 10)+"/alarm", `{"reason":"Door open","level":"INFO"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 triggering an alarm, got %d: %s", w.Code, w.Body.String())
+	}
+	if cleared, err := server.Repo.ClearExpiredAlarms(models.AlarmLevelInfo, time.Now().Add(time.Hour), false); err != nil || cleared != 1 {
+		t.Fatalf("Expected the alarm cleared automatically, got %d, %v", cleared, err)
+	}
+
+	// The clear is in the history, but is not an alarm that fired
+	for query, expected := range map[string]string{
+		"":                                    `"count":1`,
+		"?event=auto_cleared":                 `"count":1`,
+		"?event=triggered&event=auto_cleared": `"count":2`,
+	} {
+		if w := server.Do(http.MethodGet, "/api/alarms/count"+query, ""); !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("%q: expected %s, got %d: %s", query, expected, w.Code, w.Body.String())
+		}
+	}
+
+	var dashboard models.Dashboard
+	if err := json.Unmarshal(server.Do(http.MethodGet, "/api/dashboard", "").Body.Bytes(), &dashboard); err != nil {
+		t.Fatalf("Failed to decode the dashboard: %v", err)
+	}
+	if len(dashboard.RecentAlarms) != 1 || dashboard.RecentAlarms[0].Event != models.AlarmEventTriggered {
+		t.Errorf("Expected only the triggered alarm among the recent alarms, got %+v", dashboard.RecentAlarms)
+	}
+}
+
+func TestAPI_DeviceChildren(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(3)
+	parent := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+	child := "/api/devices/" + strconv.FormatInt(devices[1].ID, 10)
+
+	for body, status := range map[string]int{
+		`{"parent_id": 0}`:                              http.StatusUnprocessableEntity,
+		`{"parent_id": 9999}`:                           http.StatusUnprocessableEntity,
+		fmt.Sprintf(`{"parent_id": %d}`, devices[1].ID): http.StatusConflict,
+		fmt.Sprintf(`{"parent_id": %d}`, devices[0].ID): http.StatusNoContent,
+	} {
+		if w := server.Do(http.MethodPut, child, body); w.Code != status {
+			t.Errorf("%s: expected status %d, got %d: %s", body, status, w.Code, w.Body.String())
+		}
+	}
+
+	w := server.Do(http.MethodGet, parent+"/children", "")
+	var children []models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &children); err != nil {
+		t.Fatalf("Failed to decode children: %v: %s", err, w.Body.String())
+	}
+	if len(children) != 1 || children[0].ID != devices[1].ID || *children[0].ParentID != devices[0].ID {
+		t.Errorf("Expected the child listed under its parent, got %+v", children)
+	}
+	if limit := w.Header().Get("X-Page-Limit"); limit == "" {
+		t.Errorf("Expected the page served in X-Page-Limit")
+	}
+	var rest []models.Device
+	if err := json.Unmarshal(server.Do(http.MethodGet, parent+"/children?offset=1", "").Body.Bytes(), &rest); err != nil || len(rest) != 0 {
+		t.Errorf("Expected no children past the first, got %+v, %v", rest, err)
+	}
+	var device models.Device
+	if err := json.Unmarshal(server.Do(http.MethodGet, parent, "").Body.Bytes(), &device); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if device.ChildCount != 1 {
+		t.Errorf("Expected child_count 1, got %d", device.ChildCount)
+	}
+	if w := server.Do(http.MethodGet, "/api/devices/9999/children", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", w.Code)
+	}
+
+	if w := server.Do(http.MethodDelete, parent, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 deleting a parent, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodDelete, parent+"?detach=true", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for detach without cascade, got %d", w.Code)
+	}
+	if w := server.Do(http.MethodDelete, parent+"?cascade=true", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, id := range []int64{devices[0].ID, devices[1].ID} {
+		if device, _ := server.Repo.GetByID(id); device != nil {
+			t.Errorf("Expected device %d deleted", id)
+		}
+	}
+}
+
+func TestAPI_ArchiveDevice(t *testing.T) {
+	server := apitest.New(t, nil)
+	devices := server.Seed(3)
+	pool := devices[0]
+	path := "/api/devices/" + strconv.FormatInt(pool.ID, 10)
+
+	if w := server.Do(http.MethodPost, path+"/archive", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Left out of lists and counts unless asked for
+	listed := func(query string) int {
+		w := server.Do(http.MethodGet, "/api/devices"+query, "")
+		var devices []models.Device
+		if err := json.Unmarshal(w.Body.Bytes(), &devices); err != nil {
+			t.Fatalf("Failed to decode devices: %v", err)
+		}
+		return len(devices)
+	}
+	if n := listed(""); n != 2 {
+		t.Errorf("Expected 2 devices listed by default, got %d", n)
+	}
+	if n := listed("?include_archived=true"); n != 3 {
+		t.Errorf("Expected 3 devices with include_archived, got %d", n)
+	}
+	if w := server.Do(http.MethodGet, "/api/devices/count", ""); !strings.Contains(w.Body.String(), `"count":2`) {
+		t.Errorf("Expected a count of 2, got %s", w.Body.String())
+	}
+
+	// Still readable by id
+	w := server.Do(http.MethodGet, path, "")
+	var device models.Device
+	if err := json.Unmarshal(w.Body.Bytes(), &device); err != nil || !device.Archived {
+		t.Errorf("Expected the archived device by id, got %s", w.Body.String())
+	}
+
+	// Alarms and connections are refused with a code to match on
+	for _, refused := range []*httptest.ResponseRecorder{
+		server.Do(http.MethodPost, path+"/alarm", `{"reason":"[INFO] Cold","level":"INFO"}`),
+		server.Do(http.MethodGet, path+"/ws", ""),
+	} {
+		var body struct{ Code string }
+		if err := json.Unmarshal(refused.Body.Bytes(), &body); err != nil || refused.Code != http.StatusConflict || body.Code != "DEVICE_ARCHIVED" {
+			t.Errorf("Expected a 409 with code DEVICE_ARCHIVED, got %d: %s", refused.Code, refused.Body.String())
+		}
+	}
+
+	if w := server.Do(http.MethodPost, path+"/unarchive", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPost, path+"/alarm", `{"reason":"[INFO] Warm","level":"INFO"}`); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the unarchived device to take alarms, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPost, "/api/devices/999/archive", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", w.Code)
+	}
+}
+
+func TestAPI_AlarmReasonCatalog(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "secret"
+	server := apitest.New(t, cfg)
+	devices := server.Seed(1)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	if w := server.Do(http.MethodPut, "/api/admin/alarm-reasons/smoke", `{"text":"Smoke detected"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", w.Code)
+	}
+	if w := server.DoAdmin(http.MethodPut, "/api/admin/alarm-reasons/smoke", `{"text":"Smoke detected","level_hint":"CRITICAL"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.DoAdmin(http.MethodPut, "/api/admin/alarm-reasons/smoke", `{"text":"Smoke detected in the room"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 rewording a reason, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.DoAdmin(http.MethodPut, "/api/admin/alarm-reasons/Smoke", `{"text":"Smoke detected"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an uppercase code, got %d", w.Code)
+	}
+	if w := server.DoAdmin(http.MethodPut, "/api/admin/alarm-reasons/smoke", `{"text":"Smoke detected","colour":"red"}`); w.Code != http.StatusBadRequest ||
+		!strings.Contains(w.Body.String(), `"error":`) {
+		t.Errorf("Expected status 400 with an error for an unknown field, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := server.Do(http.MethodGet, "/api/admin/alarm-reasons", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 listing reasons without the admin token, got %d", w.Code)
+	}
+	w := server.DoAdmin(http.MethodGet, "/api/admin/alarm-reasons", "")
+	var reasons []models.AlarmReason
+	if err := json.Unmarshal(w.Body.Bytes(), &reasons); err != nil {
+		t.Fatalf("Failed to decode reasons: %v", err)
+	}
+	if len(reasons) != 1 || reasons[0].Text != "Smoke detected in the room" || reasons[0].LevelHint != "" {
+		t.Fatalf("Expected the reworded reason, got %+v", reasons)
+	}
+
+	// The catalog's text replaces whatever the device sent
+	if w := server.Do(http.MethodPost, path+"/alarm", `{"reason":"smoek","level":"CRITICAL","reason_code":"smoke"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPost, path+"/alarm", `{"reason":"Door open","level":"INFO"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected free text to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	w = server.Do(http.MethodPost, path+"/alarm", `{"level":"CRITICAL","reason_code":"fire"}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "is not in the alarm reason catalog") {
+		t.Errorf("Expected status 422 for an unknown code, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = server.Do(http.MethodGet, path+"/alarms?reason_code=smoke", "")
+	var history []models.AlarmRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("Failed to decode alarms: %v", err)
+	}
+	if len(history) != 1 || history[0].Reason != "[CRITICAL] Smoke detected in the room" || history[0].ReasonCode != "smoke" {
+		t.Errorf("Expected the alarm recorded with the catalog's text and code, got %+v", history)
+	}
+	if w := server.Do(http.MethodGet, "/api/alarms/count?reason_code=smoke", ""); !strings.Contains(w.Body.String(), `"count":1`) {
+		t.Errorf("Expected one alarm counted for the code, got %s", w.Body.String())
+	}
+	if w := server.Do(http.MethodGet, "/api/alarms/count?group_by=reason_code", ""); w.Body.String() != `{"by_reason_code":{"smoke":1},"count":2}` {
+		t.Errorf("Expected the alarms grouped by code, with the free text one only in the total, got %s", w.Body.String())
+	}
+	if w := server.Do(http.MethodGet, "/api/alarms/count?reason_code=a%20b", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid reason_code filter, got %d", w.Code)
+	}
+
+	if w := server.DoAdmin(http.MethodDelete, "/api/admin/alarm-reasons/smoke", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	if w := server.DoAdmin(http.MethodDelete, "/api/admin/alarm-reasons/smoke", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", w.Code)
+	}
+	// Alarms recorded with a deleted reason keep it
+	w = server.Do(http.MethodGet, path+"/alarms?reason_code=smoke", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil || len(history) != 1 {
+		t.Errorf("Expected the recorded alarm to keep its code, got %s", w.Body.String())
+	}
+}
+
+func TestAPI_StrictAlarmReasons(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.StrictAlarmReasons = true
+	cfg.AdminToken = "secret"
+	server := apitest.New(t, cfg)
+	devices := server.Seed(1)
+	path := "/api/devices/" + strconv.FormatInt(devices[0].ID, 10) + "/alarm"
+
+	if w := server.DoAdmin(http.MethodPut, "/api/admin/alarm-reasons/smoke", `{"text":"Smoke detected"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := server.Do(http.MethodPost, path, `{"reason":"Smoke detected","level":"CRITICAL"}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"reason_code"`) {
+		t.Errorf("Expected free text to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := server.Do(http.MethodPost, path, `{"level":"CRITICAL","reason_code":"smoke"}`); w.Code != http.StatusNoContent {
+		t.Errorf("Expected a coded alarm to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// codeDeviceArchived is the code of errors refusing a heartbeat or alarm from an archived device,
+// for clients and device firmware to match on
+const codeDeviceArchived = "DEVICE_ARCHIVED"
+
+// respondArchived writes the 409 refusing a request from an archived device
+func respondArchived(c *gin.Context, err error) {
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": codeDeviceArchived})
+}
+
+// archiveDevice handles POST /api/devices/:id/archive, hiding a device such as a seasonal sensor
+// from default views without deleting it
+func (h *Handler) archiveDevice(c *gin.Context) {
+	h.setDeviceArchived(c, true)
+}
+
+// unarchiveDevice handles POST /api/devices/:id/unarchive
+func (h *Handler) unarchiveDevice(c *gin.Context) {
+	h.setDeviceArchived(c, false)
+}
+
+// setDeviceArchived archives or unarchives the device in the path. Either is idempotent.
+func (h *Handler) setDeviceArchived(c *gin.Context, archived bool) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deviceService.SetArchived(id, archived); err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
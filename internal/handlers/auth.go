@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// isAdmin reports whether the request carries the configured admin bearer token. Without a
+// configured token no request is an admin.
+func (h *Handler) isAdmin(c *gin.Context) bool {
+	if h.config.AdminToken == "" {
+		return false
+	}
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminToken)) == 1
+}
+
+// requireAdmin rejects requests that do not carry the admin token
+func (h *Handler) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.isAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// adminActor is the identity recorded for requests authenticated with the admin token
+const adminActor = "admin"
+
+// identity returns the authenticated identity of the request and whether it is an admin. An
+// unauthenticated request has no identity.
+func (h *Handler) identity(c *gin.Context) (string, bool) {
+	if h.isAdmin(c) {
+		return adminActor, true
+	}
+	return "", false
+}
+
+// resolveActor applies the triggered_by precedence rule: an authenticated identity wins over
+// the client-supplied value unless the caller is an admin, whose supplied value is kept and
+// only defaulted to their identity.
+func resolveActor(identity string, admin bool, supplied string) string {
+	if identity == "" || (admin && supplied != "") {
+		return supplied
+	}
+	return identity
+}
+
+// applyActor fills in the alarm's triggered_by from the request's authenticated identity
+func (h *Handler) applyActor(c *gin.Context, alarm *models.AlarmRequest) {
+	identity, admin := h.identity(c)
+	alarm.TriggeredBy = resolveActor(identity, admin, alarm.TriggeredBy)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// getChanges handles GET /api/changes. It returns changes with a sequence number greater than
+// ?since in order; clients pass next_since back as since to continue. A 410 means changes
+// after since have been compacted and the client must resync from a full listing.
+func (h *Handler) getChanges(c *gin.Context) {
+	since, ok := parseIntQuery(c, "since", 0, 0, math.MaxInt)
+	if !ok {
+		return
+	}
+
+	// The feed pages by sequence number, so it takes a limit but no offset
+	limit, _, ok := parseLimit(c, h.config.DefaultPageSize, h.config.MaxPageSize)
+	if !ok {
+		return
+	}
+
+	feed, err := h.changeService.GetChanges(int64(since), limit)
+	if err != nil {
+		if errors.Is(err, models.ErrChangesCompacted) {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestGetChanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		serviceErr    error
+		expectedCode  int
+		expectedSince int64
+		expectedLimit int
+	}{
+		{"Defaults", "", nil, http.StatusOK, 0, 100},
+		{"Since and limit", "?since=42&limit=10", nil, http.StatusOK, 42, 10},
+		{"Limit capped at maximum", "?limit=100000", nil, http.StatusOK, 0, 1000},
+		{"Negative since", "?since=-1", nil, http.StatusBadRequest, 0, 0},
+		{"Non-numeric since", "?since=abc", nil, http.StatusBadRequest, 0, 0},
+		{"Zero limit", "?limit=0", nil, http.StatusBadRequest, 0, 0},
+		{"Compacted", "?since=1", models.ErrChangesCompacted, http.StatusGone, 1, 100},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotSince int64
+			var gotLimit int
+			changeSvc := &MockChangeService{
+				getChangesFunc: func(since int64, limit int) (*models.ChangeFeed, error) {
+					gotSince, gotLimit = since, limit
+					if tc.serviceErr != nil {
+						return nil, tc.serviceErr
+					}
+					return &models.ChangeFeed{
+						Changes: []*models.Change{
+							{Seq: since + 1, Entity: models.ChangeEntityDevice, EntityID: 7, Operation: models.ChangeOperationDelete, Version: 3},
+						},
+						LatestSeq: since + 1,
+						NextSince: since + 1,
+					}, nil
+				},
+			}
+			router := newTestServerWithChanges(changeSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/changes"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode == http.StatusBadRequest {
+				return
+			}
+			if gotSince != tc.expectedSince || gotLimit != tc.expectedLimit {
+				t.Errorf("Expected since %d and limit %d, got %d and %d", tc.expectedSince, tc.expectedLimit, gotSince, gotLimit)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var feed models.ChangeFeed
+			if err := json.Unmarshal(recorder.Body.Bytes(), &feed); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(feed.Changes) != 1 || feed.Changes[0].Operation != models.ChangeOperationDelete || feed.NextSince != tc.expectedSince+1 {
+				t.Errorf("Unexpected feed: %+v", feed)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// getDeviceChildren handles GET /api/devices/:id/children, listing the devices that are
+// physically part of the one in the path
+func (h *Handler) getDeviceChildren(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+
+	children, err := h.deviceService.GetChildren(id, page.Limit, page.Offset)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, children)
+}
+
+// parseChildPolicy reads what deleting a device does to its children: with ?cascade=true they
+// are deleted, or detached when ?detach=true is given as well. Without cascade, a device with
+// children is not deleted. On failure it writes a 400 response and returns false.
+func parseChildPolicy(c *gin.Context) (models.ChildPolicy, bool) {
+	cascade, ok := parseBoolQuery(c, "cascade", false)
+	if !ok {
+		return "", false
+	}
+	detach, ok := parseBoolQuery(c, "detach", false)
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case detach && !cascade:
+		respondError(c, http.StatusBadRequest, "detach requires cascade=true")
+		return "", false
+	case detach:
+		return models.ChildrenDetach, true
+	case cascade:
+		return models.ChildrenDelete, true
+	}
+	return models.ChildrenRefuse, true
+}
+
+// respondParentError writes the response for an error assigning a device's parent: 422 for a
+// parent that does not exist and 409 for one that would make a cycle. It returns false for any
+// other error, leaving the response to the caller.
+func respondParentError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, models.ErrParentNotFound):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrParentCycle):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
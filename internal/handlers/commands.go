@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// enqueueDeviceCommand handles POST /api/devices/:id/commands
+func (h *Handler) enqueueDeviceCommand(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.CommandRequest
+	if bindErr := h.bindStrictJSON(c, &req); bindErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
+		return
+	}
+
+	command, err := h.commandService.EnqueueCommand(id, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Connected devices get the command straight away; otherwise it waits for the next poll or reconnect
+	if ws := h.conns.get(id); ws != nil {
+		if err := h.pushCommand(ws, command); err != nil {
+			log.Printf("Error delivering command %d to device %d, leaving it queued: %v", command.ID, id, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, command)
+}
+
+// pollDeviceCommands handles GET /api/devices/:id/commands, returning a page of the device's
+// unacknowledged commands. Only the pending commands on the page are marked delivered.
+func (h *Handler) pollDeviceCommands(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+
+	commands, err := h.commandService.PollCommands(id, page.Limit, page.Offset)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+// ackDeviceCommand handles POST /api/devices/:id/commands/:command_id/ack
+func (h *Handler) ackDeviceCommand(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	commandID, ok := parseCommandIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.commandService.AckCommand(id, commandID)
+	if err != nil {
+		if errors.Is(err, models.ErrCommandNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
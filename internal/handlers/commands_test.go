@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestEnqueueDeviceCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		deviceID     string
+		body         string
+		enqueueErr   error
+		expectedCode int
+	}{
+		{"Queued for disconnected device", "1", `{"command": "silence", "payload": {"seconds": 30}}`, nil, http.StatusCreated},
+		{"Missing command", "1", `{}`, nil, http.StatusBadRequest},
+		{"Unknown field", "1", `{"command": "silence", "priority": 1}`, nil, http.StatusBadRequest},
+		{"Payload nested too deep", "1", `{"command": "silence", "payload": ` + strings.Repeat("[", 40) + strings.Repeat("]", 40) + `}`, nil, http.StatusBadRequest},
+		{"Invalid device ID", "abc", `{"command": "silence"}`, nil, http.StatusBadRequest},
+		{"Device not found", "99", `{"command": "silence"}`, fmt.Errorf("%w with ID: 99", models.ErrDeviceNotFound), http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			commandSvc := &MockCommandService{
+				enqueueFunc: func(deviceID int64, req *models.CommandRequest) (*models.DeviceCommand, error) {
+					if tc.enqueueErr != nil {
+						return nil, tc.enqueueErr
+					}
+					return &models.DeviceCommand{ID: 1, DeviceID: deviceID, Command: req.Command, Payload: req.Payload, Status: models.CommandStatusPending}, nil
+				},
+			}
+			router := newTestServerWithCommands(&MockDeviceService{}, commandSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("POST", "/api/devices/"+tc.deviceID+"/commands", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusCreated {
+				return
+			}
+
+			var command models.DeviceCommand
+			if err := json.Unmarshal(recorder.Body.Bytes(), &command); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			// Nobody is connected, so the command stays pending until the device polls
+			if command.Status != models.CommandStatusPending || command.Command != "silence" {
+				t.Errorf("Unexpected command: %+v", command)
+			}
+		})
+	}
+}
+
+func TestPollDeviceCommands(t *testing.T) {
+	tests := []struct {
+		name          string
+		deviceID      string
+		pollErr       error
+		expectedCode  int
+		expectedCount int
+	}{
+		{"Commands returned", "1", nil, http.StatusOK, 2},
+		{"Device not found", "99", fmt.Errorf("%w with ID: 99", models.ErrDeviceNotFound), http.StatusNotFound, 0},
+		{"Invalid device ID", "abc", nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			commandSvc := &MockCommandService{
+				pollFunc: func(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error) {
+					if tc.pollErr != nil {
+						return nil, tc.pollErr
+					}
+					return []*models.DeviceCommand{
+						{ID: 1, DeviceID: deviceID, Command: "reboot", Status: models.CommandStatusDelivered},
+						{ID: 2, DeviceID: deviceID, Command: "silence", Status: models.CommandStatusDelivered},
+					}, nil
+				},
+			}
+			router := newTestServerWithCommands(&MockDeviceService{}, commandSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices/"+tc.deviceID+"/commands", nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var commands []models.DeviceCommand
+			if err := json.Unmarshal(recorder.Body.Bytes(), &commands); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(commands) != tc.expectedCount {
+				t.Errorf("Expected %d commands, got %d", tc.expectedCount, len(commands))
+			}
+		})
+	}
+}
+
+func TestAckDeviceCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		ackErr       error
+		expectedCode int
+	}{
+		{"Acknowledged", "/api/devices/1/commands/5/ack", nil, http.StatusNoContent},
+		{"Command not found", "/api/devices/1/commands/99/ack", fmt.Errorf("%w with ID: 99", models.ErrCommandNotFound), http.StatusNotFound},
+		{"Invalid command ID", "/api/devices/1/commands/abc/ack", nil, http.StatusBadRequest},
+		{"Invalid device ID", "/api/devices/abc/commands/5/ack", nil, http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotDevice, gotCommand int64
+			commandSvc := &MockCommandService{
+				ackFunc: func(deviceID, id int64) error {
+					gotDevice, gotCommand = deviceID, id
+					return tc.ackErr
+				},
+			}
+			router := newTestServerWithCommands(&MockDeviceService{}, commandSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("POST", tc.path, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode == http.StatusNoContent && (gotDevice != 1 || gotCommand != 5) {
+				t.Errorf("AckCommand called with device %d, command %d; expected 1, 5", gotDevice, gotCommand)
+			}
+		})
+	}
+}
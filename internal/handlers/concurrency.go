@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unlimitedRoutes do not count against the concurrency limit: health checks must keep answering
+// under load, and a websocket would hold its slot for as long as the device stays connected
+var unlimitedRoutes = map[string]bool{
+	"/health":             true,
+	"/api/devices/:id/ws": true,
+}
+
+// concurrencyLimitMiddleware caps the number of requests handled at once at max. A request
+// arriving when every slot is taken is turned away with 503 and a Retry-After of retryAfter,
+// rounded up to whole seconds, rather than queued.
+func concurrencyLimitMiddleware(max int, retryAfter time.Duration) gin.HandlerFunc {
+	slots := make(chan struct{}, max)
+	seconds := strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds()))))
+
+	return func(c *gin.Context) {
+		if unlimitedRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			c.Header("Retry-After", seconds)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Server is busy, try again later"})
+			return
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
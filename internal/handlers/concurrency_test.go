@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(concurrencyLimitMiddleware(1, 1500*time.Millisecond))
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("/slow").Code }()
+	<-entered
+
+	busy := serve("/fast")
+	if busy.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code %d while the slot is taken, got %d", http.StatusServiceUnavailable, busy.Code)
+	}
+	if got := busy.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After rounded up to 2, got %q", got)
+	}
+	if code := serve("/health").Code; code != http.StatusOK {
+		t.Errorf("Expected health check to bypass the limit, got status code %d", code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusNoContent {
+		t.Errorf("Expected the slow request to finish with %d, got %d", http.StatusNoContent, code)
+	}
+	if code := serve("/fast").Code; code != http.StatusNoContent {
+		t.Errorf("Expected the slot to be freed, got status code %d", code)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTP dates, and the timestamps they are derived from, only have second resolution, so a
+// resource can change again within the second its Last-Modified names. To stay safe a change
+// stored at second s is treated as happening at the end of that second, s+1: that is the
+// Last-Modified sent once s+1 has passed, and only an If-Modified-Since of s+1 or later counts
+// as unmodified. A response served during second s itself sends s instead, which cannot be in
+// the future and never matches, so the client revalidates until the second is over.
+
+// lastModifiedAt returns the Last-Modified value for a resource changed at modified, as of now
+func lastModifiedAt(modified, now time.Time) time.Time {
+	roundedUp := modified.Truncate(time.Second).Add(time.Second)
+	if now.Before(roundedUp) {
+		return modified.Truncate(time.Second)
+	}
+
+	return roundedUp
+}
+
+// notModified sets Last-Modified for a resource changed at modified. If the request's
+// If-Modified-Since shows the client already has this version, it writes a 304 without a
+// body and returns true. A zero modified, such as an empty list, sets no header.
+func notModified(c *gin.Context, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+
+	c.Header("Last-Modified", lastModifiedAt(modified, time.Now()).UTC().Format(http.TimeFormat))
+
+	header := c.GetHeader("If-Modified-Since")
+	if header == "" {
+		return false
+	}
+	// An unparseable date is ignored, as if the header were absent
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	if since.Before(modified.Truncate(time.Second).Add(time.Second)) {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
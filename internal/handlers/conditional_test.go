@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestLastModifiedAt(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{"Within the modified second", modified.Add(500 * time.Millisecond), modified},
+		{"Second has passed", modified.Add(time.Second), modified.Add(time.Second)},
+		{"Long after", modified.Add(time.Hour), modified.Add(time.Second)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := lastModifiedAt(modified, tc.now); !got.Equal(tc.expected) {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetDeviceByIDIfModifiedSince(t *testing.T) {
+	updated := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	lastModified := updated.Add(time.Second).Format(http.TimeFormat)
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		expectedCode    int
+		expectedHeader  string
+	}{
+		{"No condition", "", http.StatusOK, lastModified},
+		{"Unchanged since Last-Modified", lastModified, http.StatusNotModified, lastModified},
+		{"Unchanged since later", updated.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified, lastModified},
+		// The device may have changed again later in the second it was updated
+		{"Same second as update", updated.Format(http.TimeFormat), http.StatusOK, lastModified},
+		{"Changed since", updated.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK, lastModified},
+		{"Unparseable date", "yesterday", http.StatusOK, lastModified},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				getByIDFunc: func(id int64) (*models.Device, error) {
+					return testutil.NewDevice().WithID(id).WithName("Detector").WithUpdatedAt(updated).Build(), nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices/1", nil)
+			if tc.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tc.ifModifiedSince)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if got := recorder.Header().Get("Last-Modified"); got != tc.expectedHeader {
+				t.Errorf("Expected Last-Modified %q, got %q", tc.expectedHeader, got)
+			}
+			if tc.expectedCode == http.StatusNotModified && recorder.Body.Len() != 0 {
+				t.Errorf("Expected no body with 304, got %q", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetDeviceByIDUpdatedWithinSameSecond(t *testing.T) {
+	// The device is updated in the current second, so it could change again before the second is out
+	updated := time.Now().UTC().Truncate(time.Second)
+	mockSvc := &MockDeviceService{
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			return testutil.NewDevice().WithID(id).WithName("Detector").WithUpdatedAt(updated).Build(), nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/api/devices/1", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	lastModified := recorder.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("Expected a Last-Modified header")
+	}
+	if parsed, err := http.ParseTime(lastModified); err != nil || parsed.After(time.Now()) {
+		t.Errorf("Expected a Last-Modified that is not in the future, got %q", lastModified)
+	}
+
+	// Revalidating with that Last-Modified must not be answered with 304, as the device
+	// may have been updated again within the same second
+	req, _ = http.NewRequest("GET", "/api/devices/1", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestGetAllDevicesIfModifiedSince(t *testing.T) {
+	updated := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name            string
+		lastModified    time.Time
+		ifModifiedSince string
+		expectedCode    int
+	}{
+		{"Unchanged", updated, updated.Add(time.Second).Format(http.TimeFormat), http.StatusNotModified},
+		{"Changed", updated, updated.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"No devices ever", time.Time{}, updated.Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			listed := false
+			mockSvc := &MockDeviceService{
+				lastModifiedFunc: func() (time.Time, error) { return tc.lastModified, nil },
+				listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+					listed = true
+					return []*models.Device{}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices", nil)
+			req.Header.Set("If-Modified-Since", tc.ifModifiedSince)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode == http.StatusNotModified && listed {
+				t.Errorf("Expected the list not to be queried for a 304")
+			}
+		})
+	}
+}
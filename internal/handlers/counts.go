@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// countDevices handles GET /api/devices/count, counting the devices matching the filters of
+// GET /api/devices without loading them
+func (h *Handler) countDevices(c *gin.Context) {
+	var opts models.DeviceListOptions
+	if !parseDeviceFilters(c, &opts) {
+		return
+	}
+
+	count, err := h.deviceService.CountDevices(&opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// countOnlineDevices handles GET /api/devices/online-count, telling how many devices are online
+// and offline for a status summary. Archived devices are not counted.
+func (h *Handler) countOnlineDevices(c *gin.Context) {
+	counts, err := h.deviceService.CountOnlineDevices()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+// countAlarms handles GET /api/alarms/count, counting the alarm history of every device matching
+// the level and after/before filters of GET /api/devices/:id/alarms. Without ?event only alarms
+// that fired are counted, not the entries recording their automatic clearing.
+// ?group_by=reason_code adds the counts per reason code under "by_reason_code"; alarms given
+// without a code are only in the total.
+func (h *Handler) countAlarms(c *gin.Context) {
+	var filter models.AlarmHistoryFilter
+	if !parseAlarmHistoryFilter(c, &filter, h.alarmLevels) {
+		return
+	}
+	if len(filter.Events) == 0 {
+		filter.Events = []string{models.AlarmEventTriggered}
+	}
+
+	switch c.Query("group_by") {
+	case "":
+	case "reason_code":
+		h.countAlarmsByReasonCode(c, &filter)
+		return
+	default:
+		respondError(c, http.StatusBadRequest, "group_by must be reason_code")
+		return
+	}
+
+	count, err := h.deviceService.CountAlarms(&filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// countAlarmsByReasonCode answers GET /api/alarms/count?group_by=reason_code for filter
+func (h *Handler) countAlarmsByReasonCode(c *gin.Context, filter *models.AlarmHistoryFilter) {
+	byCode, err := h.deviceService.CountAlarmsByReasonCode(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	total := 0
+	for _, count := range byCode {
+		total += count
+	}
+	delete(byCode, "")
+
+	c.JSON(http.StatusOK, gin.H{"count": total, "by_reason_code": byCode})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestCountDevices(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		check        func(t *testing.T, opts *models.DeviceListOptions)
+	}{
+		{"No filters", "/api/devices/count", http.StatusOK, func(t *testing.T, opts *models.DeviceListOptions) {
+			if opts.DeviceTypes != nil || opts.Owners != nil || opts.Online != nil || opts.AlarmActive != nil {
+				t.Errorf("Expected no filters, got %+v", opts)
+			}
+		}},
+		{"All filters", "/api/devices/count?device_type=CAMERA&owned_by=alice&online=true&alarm_active=false&maintenance=false&name=door", http.StatusOK, func(t *testing.T, opts *models.DeviceListOptions) {
+			if !reflect.DeepEqual(opts.DeviceTypes, []models.DeviceType{models.DeviceTypeCamera}) || !reflect.DeepEqual(opts.Owners, []string{"alice"}) || !reflect.DeepEqual(opts.Names, []string{"door"}) {
+				t.Errorf("Unexpected filters %+v", opts)
+			}
+			if opts.Online == nil || !*opts.Online || opts.AlarmActive == nil || *opts.AlarmActive || opts.Maintenance == nil || *opts.Maintenance {
+				t.Errorf("Unexpected state filters %+v", opts)
+			}
+		}},
+		{"Exclude unknown", "/api/devices/count?exclude_unknown=true&owned_by=alice", http.StatusOK, func(t *testing.T, opts *models.DeviceListOptions) {
+			if !opts.ExcludeUnknown || !reflect.DeepEqual(opts.Owners, []string{"alice"}) {
+				t.Errorf("Expected unknown devices of alice excluded, got %+v", opts)
+			}
+		}},
+		{"Invalid device type", "/api/devices/count?device_type=TOASTER", http.StatusBadRequest, nil},
+		{"Invalid exclude_unknown", "/api/devices/count?exclude_unknown=sometimes", http.StatusBadRequest, nil},
+		{"Invalid online", "/api/devices/count?online=maybe", http.StatusBadRequest, nil},
+		{"Invalid alarm_active", "/api/devices/count?alarm_active=1x", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			mockSvc := &MockDeviceService{
+				countFunc: func(opts *models.DeviceListOptions) (int, error) {
+					gotOpts = opts
+					return 7, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if recorder.Body.String() != `{"count":7}` {
+				t.Errorf("Expected a count body, got %s", recorder.Body.String())
+			}
+			tc.check(t, gotOpts)
+		})
+	}
+}
+
+func TestCountOnlineDevices(t *testing.T) {
+	tests := []struct {
+		name         string
+		counts       *models.DeviceCounts
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{"Counts", &models.DeviceCounts{Total: 5, Online: 3, Offline: 2}, nil, http.StatusOK, `{"total":5,"online":3,"offline":2}`},
+		{"No devices", &models.DeviceCounts{}, nil, http.StatusOK, `{"total":0,"online":0,"offline":0}`},
+		{"Service error", nil, errors.New("database error"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				onlineCountFunc: func() (*models.DeviceCounts, error) { return tc.counts, tc.err },
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices/online-count", nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedBody != "" && recorder.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestListDevicesSharesCountFilters(t *testing.T) {
+	var gotOpts *models.DeviceListOptions
+	mockSvc := &MockDeviceService{
+		listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+			gotOpts = opts
+			return nil, nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/api/devices?device_type=CAMERA&owned_by=alice&alarm_active=true", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	if !reflect.DeepEqual(gotOpts.DeviceTypes, []models.DeviceType{models.DeviceTypeCamera}) || !reflect.DeepEqual(gotOpts.Owners, []string{"alice"}) || gotOpts.AlarmActive == nil || !*gotOpts.AlarmActive {
+		t.Errorf("Expected the list to apply the count filters, got %+v", gotOpts)
+	}
+}
+
+func TestCountAlarms(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{"No filters", "/api/alarms/count", http.StatusOK, `{"count":3}`},
+		{"Level and range", "/api/alarms/count?level=CRITICAL&after=2024-05-01T00:00:00Z&before=2024-06-01T00:00:00Z", http.StatusOK, `{"count":3}`},
+		{"Grouped by reason code", "/api/alarms/count?group_by=reason_code", http.StatusOK, `{"by_reason_code":{"smoke":2},"count":3}`},
+		{"Invalid group", "/api/alarms/count?group_by=level", http.StatusBadRequest, ""},
+		{"Invalid level", "/api/alarms/count?level=LOUD", http.StatusBadRequest, ""},
+		{"Invalid time", "/api/alarms/count?after=yesterday", http.StatusBadRequest, ""},
+		{"Inverted range", "/api/alarms/count?after=2024-06-01T00:00:00Z&before=2024-05-01T00:00:00Z", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotFilter *models.AlarmHistoryFilter
+			mockSvc := &MockDeviceService{
+				alarmCountsFunc: func(filter *models.AlarmHistoryFilter) (int, error) {
+					gotFilter = filter
+					return 3, nil
+				},
+				alarmCodeCountsFunc: func(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+					gotFilter = filter
+					return map[string]int{"smoke": 2, "": 1}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if recorder.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+			if gotFilter.DeviceID != 0 {
+				t.Errorf("Expected a count across all devices, got device %d", gotFilter.DeviceID)
+			}
+		})
+	}
+}
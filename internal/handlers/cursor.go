@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// nextCursorHeader carries the cursor for the following page of a list sorted by created_at, or of
+// a device's alarm history
+const nextCursorHeader = "X-Next-Cursor"
+
+// errInvalidCursor is returned for cursors that are malformed or were not signed by this server
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Cursor kinds, binding a cursor to the list it was issued by
+const (
+	cursorKindDevices = "d"
+	cursorKindAlarms  = "a"
+)
+
+// cursorPayload is the content of an opaque list cursor. For the alarm history CreatedAt holds
+// the alarm's triggered_at.
+type cursorPayload struct {
+	Kind      string    `json:"k"`
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+	Order     string    `json:"o"`
+}
+
+// newCursorKey returns the key cursors are signed with: the configured secret, or a random
+// key when there is none
+func newCursorKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate cursor key: %v", err)
+	}
+
+	return key
+}
+
+// encodeCursor returns an opaque cursor positioned after device, for a list in the given order.
+// It is the base64 payload and its HMAC, so clients cannot forge or alter it.
+func (h *Handler) encodeCursor(device *models.Device, order string) string {
+	return h.sealCursor(cursorPayload{Kind: cursorKindDevices, CreatedAt: device.CreatedAt, ID: device.ID, Order: order})
+}
+
+// encodeAlarmCursor returns an opaque cursor positioned after record in the newest-first alarm
+// history
+func (h *Handler) encodeAlarmCursor(record *models.AlarmRecord) string {
+	return h.sealCursor(cursorPayload{Kind: cursorKindAlarms, CreatedAt: record.TriggeredAt, ID: record.ID, Order: models.SortDesc})
+}
+
+// sealCursor encodes and signs a cursor payload
+func (h *Handler) sealCursor(cursor cursorPayload) string {
+	payload, _ := json.Marshal(cursor)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(h.signCursor(encoded))
+}
+
+// decodeCursor verifies and decodes a cursor of the given kind. A cursor issued by another list
+// is invalid, even though it is signed with the same key.
+func (h *Handler) decodeCursor(cursor, kind string) (cursorPayload, error) {
+	encoded, signature, found := strings.Cut(cursor, ".")
+	if !found {
+		return cursorPayload{}, errInvalidCursor
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, h.signCursor(encoded)) {
+		return cursorPayload{}, errInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursorPayload{}, errInvalidCursor
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.Kind != kind || !models.IsValidSortOrder(payload.Order) {
+		return cursorPayload{}, errInvalidCursor
+	}
+
+	return payload, nil
+}
+
+// signCursor computes the HMAC of an encoded cursor payload
+func (h *Handler) signCursor(encoded string) []byte {
+	mac := hmac.New(sha256.New, h.cursorKey)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
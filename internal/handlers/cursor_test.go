@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	h := &Handler{cursorKey: []byte("secret")}
+	device := testutil.NewDevice().WithID(7).Build()
+
+	cursor := h.encodeCursor(device, models.SortAsc)
+	payload, err := h.decodeCursor(cursor, cursorKindDevices)
+	if err != nil {
+		t.Fatalf("decodeCursor failed: %v", err)
+	}
+	if payload.ID != 7 || !payload.CreatedAt.Equal(device.CreatedAt) || payload.Order != models.SortAsc {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+
+	encoded, signature, _ := strings.Cut(cursor, ".")
+	forged, _ := json.Marshal(cursorPayload{CreatedAt: device.CreatedAt, ID: 1, Order: models.SortAsc})
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"Garbage", "not-a-cursor"},
+		{"Missing signature", encoded},
+		{"Altered payload", strings.ToUpper(encoded) + "." + signature},
+		{"Forged payload", string(forged) + "." + signature},
+		{"Signed with another key", (&Handler{cursorKey: []byte("other")}).encodeCursor(device, models.SortAsc)},
+		{"Issued by another list", h.encodeAlarmCursor(&models.AlarmRecord{ID: 7, TriggeredAt: device.CreatedAt})},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := h.decodeCursor(tc.cursor, cursorKindDevices); err != errInvalidCursor {
+				t.Errorf("Expected errInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetAllDevicesCursor(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	devices := make([]*models.Device, 5)
+	for i := range devices {
+		devices[i] = testutil.NewDevice().WithID(int64(i + 1)).WithCreatedAt(created).Build()
+	}
+
+	var gotOpts *models.DeviceListOptions
+	mockSvc := &MockDeviceService{
+		listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+			gotOpts = opts
+			start := 0
+			if opts.After != nil {
+				start = int(opts.After.ID)
+			}
+			end := start + opts.Limit
+			if end > len(devices) {
+				end = len(devices)
+			}
+			return devices[start:end], nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.CursorSecret = "secret"
+	router := newTestServer(mockSvc, cfg)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/devices"+query, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Walking the cursors visits every device once and stops with no cursor on the last page
+	var seen int
+	query := "?limit=2&order=asc"
+	for pages := 0; ; pages++ {
+		if pages > len(devices) {
+			t.Fatal("Cursors never reached the last page")
+		}
+		recorder := get(query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		var page []*models.Device
+		if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, device := range page {
+			seen++
+			if device.ID != int64(seen) {
+				t.Errorf("Expected device %d, got %d", seen, device.ID)
+			}
+		}
+
+		next := recorder.Header().Get(nextCursorHeader)
+		if next == "" {
+			break
+		}
+		query = "?limit=2&after=" + url.QueryEscape(next)
+	}
+	if seen != len(devices) {
+		t.Errorf("Expected %d devices, got %d", len(devices), seen)
+	}
+	if gotOpts.SortBy != "created_at" || gotOpts.SortOrder != models.SortAsc {
+		t.Errorf("Expected the cursor to keep created_at asc, got %s %s", gotOpts.SortBy, gotOpts.SortOrder)
+	}
+
+	// Other sorts keep offset paging only
+	if recorder := get("?limit=2&sort_by=name"); recorder.Header().Get(nextCursorHeader) != "" {
+		t.Error("Expected no cursor when sorting by name")
+	}
+
+	// An alarm history cursor is signed with the same key but is not a position in the list
+	alarmCursor := url.QueryEscape((&Handler{cursorKey: []byte("secret")}).encodeAlarmCursor(&models.AlarmRecord{ID: 2, TriggeredAt: created}))
+	cursor := url.QueryEscape(get("?limit=2&order=asc").Header().Get(nextCursorHeader))
+	for _, query := range []string{
+		"?after=tampered",
+		"?after=" + alarmCursor,
+		"?after=" + alarmCursor + "&order=desc",
+		"?after=" + cursor + "&offset=2",
+		"?after=" + cursor + "&sort_by=name",
+		"?after=" + cursor + "&order=desc",
+		"?after=" + cursor + "&name=kitchen&fuzzy=true",
+	} {
+		if recorder := get(query); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
+
+func TestGetDeviceAlarmsCursor(t *testing.T) {
+	triggered := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := make([]*models.AlarmRecord, 5)
+	for i := range records {
+		// Newest first, the way the history is listed
+		records[i] = &models.AlarmRecord{ID: int64(len(records) - i), DeviceID: 1, TriggeredAt: triggered}
+	}
+
+	var filters []models.AlarmHistoryFilter
+	mockSvc := &MockDeviceService{
+		historyFunc: func(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+			filters = append(filters, *filter)
+			start := 0
+			if filter.Cursor != nil {
+				start = len(records) - int(filter.Cursor.ID) + 1
+			}
+			end := min(start+filter.Limit, len(records))
+			return records[start:end], nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.CursorSecret = "secret"
+	router := newTestServer(mockSvc, cfg)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/devices/1/alarms"+query, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	var seen []int64
+	query := "?limit=2&level=INFO"
+	for pages := 0; ; pages++ {
+		if pages > len(records) {
+			t.Fatal("Cursors never reached the last page")
+		}
+		recorder := get(query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		var page []*models.AlarmRecord
+		if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, record := range page {
+			seen = append(seen, record.ID)
+		}
+
+		next := recorder.Header().Get(nextCursorHeader)
+		if next == "" {
+			break
+		}
+		query = "?limit=2&level=INFO&cursor=" + url.QueryEscape(next)
+	}
+	if fmt.Sprint(seen) != "[5 4 3 2 1]" {
+		t.Errorf("Expected every alarm once, newest first, got %v", seen)
+	}
+	last := filters[len(filters)-1]
+	if last.Cursor == nil || last.Cursor.ID != 2 || !last.Cursor.TriggeredAt.Equal(triggered) || last.Levels[0] != "INFO" {
+		t.Errorf("Expected the last page to continue after alarm 2 with the level filter, got %+v", last)
+	}
+
+	// A device list cursor is signed with the same key but is not a position in the history
+	deviceCursor := url.QueryEscape((&Handler{cursorKey: []byte("secret")}).encodeCursor(testutil.NewDevice().Build(), models.SortDesc))
+	cursor := url.QueryEscape(get("?limit=2").Header().Get(nextCursorHeader))
+	for _, query := range []string{
+		"?cursor=tampered",
+		"?cursor=" + cursor + "&offset=2",
+		"?cursor=" + deviceCursor,
+	} {
+		if recorder := get(query); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
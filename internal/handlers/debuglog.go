@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// debugHeader asks for the bodies of a single request to be logged; it needs admin access
+const debugHeader = "X-Debug"
+
+// unloggedBodyRoutes never have their bodies logged: websocket traffic is not a request/response
+// body, and alarm exports stream whole files
+var unloggedBodyRoutes = map[string]bool{
+	"/api/devices/:id/ws":            true,
+	"/api/devices/:id/alarms/export": true,
+}
+
+// textContentTypes are the media types whose bodies are logged; others are logged by size only
+var textContentTypes = []string{"json", "xml", "text/", "x-www-form-urlencoded"}
+
+// debugBodyWriter passes the response through unchanged, keeping a copy of its first bytes.
+// Flush and Hijack reach the underlying writer, so streamed responses are not held back.
+type debugBodyWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+	size  int
+}
+
+func (w *debugBodyWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *debugBodyWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *debugBodyWriter) keep(data []byte) {
+	w.size += len(data)
+	if room := w.limit - w.body.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.body.Write(data)
+	}
+}
+
+// readCloser joins a replacement body reader with the original body's Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// debugBodyMiddleware logs the request and response bodies of a request, tagged with its request
+// id, when enabled for every request or asked for with X-Debug by an admin. At most maxBytes of
+// each body is logged, with the values of fields named like redactKeys replaced.
+func (h *Handler) debugBodyMiddleware(maxBytes int, redactKeys []string) gin.HandlerFunc {
+	redact := newRedactor(redactKeys)
+
+	return func(c *gin.Context) {
+		if unloggedBodyRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		if !h.config.DebugBodyLogging && !(c.GetHeader(debugHeader) == "true" && h.isAdmin(c)) {
+			c.Next()
+			return
+		}
+
+		// Only the logged prefix is read up front; the handler still reads the whole body
+		var requestBody []byte
+		if c.Request.Body != nil {
+			var err error
+			requestBody, err = io.ReadAll(io.LimitReader(c.Request.Body, int64(maxBytes)))
+			if err != nil {
+				log.Printf("[debug] request_id=%s error reading request body: %v", requestID(c), err)
+			}
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(requestBody), c.Request.Body), c.Request.Body}
+		}
+		log.Printf("[debug] request_id=%s %s %s client_ip=%s body=%s", requestID(c), c.Request.Method, c.Request.URL.RequestURI(), c.ClientIP(),
+			describeBody(requestBody, int(c.Request.ContentLength), c.ContentType(), "", redact))
+
+		original := c.Writer
+		writer := &debugBodyWriter{ResponseWriter: original, limit: maxBytes}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		header := original.Header()
+		log.Printf("[debug] request_id=%s status=%d body=%s", requestID(c), original.Status(),
+			describeBody(writer.body.Bytes(), writer.size, header.Get("Content-Type"), header.Get("Content-Encoding"), redact))
+	}
+}
+
+// describeBody renders a body for the debug log. Bodies that are not text, or are compressed,
+// are described by their size alone. size is the full length, or -1 when unknown.
+func describeBody(body []byte, size int, contentType, contentEncoding string, redact func([]byte, bool) []byte) string {
+	if size < len(body) {
+		size = len(body)
+	}
+	if size == 0 {
+		return "(empty)"
+	}
+	if contentEncoding != "" {
+		return fmt.Sprintf("(%d bytes, %s encoded)", size, contentEncoding)
+	}
+	if !isTextContentType(contentType) {
+		return fmt.Sprintf("(%d bytes of %s)", size, contentType)
+	}
+
+	rendered := strconv.Quote(string(redact(body, strings.Contains(contentType, "x-www-form-urlencoded"))))
+	if size > len(body) {
+		rendered += fmt.Sprintf(" (truncated from %d bytes)", size)
+	}
+	return rendered
+}
+
+func isTextContentType(contentType string) bool {
+	for _, text := range textContentTypes {
+		if strings.Contains(contentType, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRedactor returns a function replacing the values of JSON fields, and of form parameters for
+// form bodies, whose name contains one of keys. It works on the raw text, so malformed and
+// truncated bodies, which are what debug logging is for, are redacted too.
+func newRedactor(keys []string) func(body []byte, form bool) []byte {
+	if len(keys) == 0 {
+		return func(body []byte, _ bool) []byte { return body }
+	}
+
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+	names := `(?:` + strings.Join(quoted, `|`) + `)`
+
+	// A JSON field's value is a string, possibly cut off by truncation, or a bare scalar
+	jsonField := regexp.MustCompile(`(?i)("[^"]*` + names + `[^"]*"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	formField := regexp.MustCompile(`(?i)((?:^|&)[^=&]*` + names + `[^=&]*=)[^&]*`)
+
+	return func(body []byte, form bool) []byte {
+		if form {
+			return formField.ReplaceAll(body, []byte(`${1}[REDACTED]`))
+		}
+		return jsonField.ReplaceAll(body, []byte(`${1}"[REDACTED]"`))
+	}
+}
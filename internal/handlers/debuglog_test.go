@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestRedactor(t *testing.T) {
+	redact := newRedactor([]string{"password", "token"})
+
+	tests := []struct {
+		name     string
+		body     string
+		form     bool
+		expected string
+	}{
+		{"JSON string", `{"name":"Hall","password":"hunter2"}`, false, `{"name":"Hall","password":"[REDACTED]"}`},
+		{"Key containing a name, any case", `{"Api_Token": 1234, "n": 1}`, false, `{"Api_Token": "[REDACTED]", "n": 1}`},
+		{"Escaped quote", `{"password":"a\"b","n":1}`, false, `{"password":"[REDACTED]","n":1}`},
+		{"Truncated value", `{"password":"hunt`, false, `{"password":"[REDACTED]"`},
+		{"Value mentioning a name is kept", `{"reason":"token expired"}`, false, `{"reason":"token expired"}`},
+		{"Form", `user=bob&password=hunter2&x=1`, true, `user=bob&password=[REDACTED]&x=1`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(redact([]byte(tc.body), tc.form)); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// captureLog collects the standard logger's output for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestDebugBodyLogging(t *testing.T) {
+	tests := []struct {
+		name      string
+		always    bool
+		debug     bool
+		token     string
+		expectLog bool
+	}{
+		{"Enabled for every request", true, false, "", true},
+		{"Admin asks for it", false, true, "admin-secret", true},
+		{"Non-admin asks for it", false, true, "wrong", false},
+		{"Admin without X-Debug", false, false, "admin-secret", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			cfg := testutil.NewConfig()
+			cfg.AdminToken = "admin-secret"
+			cfg.DebugBodyLogging = tc.always
+			cfg.DebugBodyMaxBytes = 64
+			cfg.DebugRedactKeys = []string{"password"}
+			h := New(&MockDeviceService{}, &MockIncidentService{}, &MockCommandService{}, &MockChangeService{}, cfg)
+
+			var received string
+			h.router.POST("/debug", func(c *gin.Context) {
+				body, _ := io.ReadAll(c.Request.Body)
+				received = string(body)
+				c.JSON(http.StatusOK, gin.H{"password": "hunter2"})
+			})
+			logged := captureLog(t)
+
+			// The body is longer than the cap, and the handler must still see all of it
+			body := `{"name":"Hall","password":"hunter2","padding":"` + strings.Repeat("x", 100) + `"}`
+			req, _ := http.NewRequest("POST", "/debug", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(requestIDHeader, "req-42")
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			if tc.debug {
+				req.Header.Set(debugHeader, "true")
+			}
+			recorder := httptest.NewRecorder()
+			h.router.ServeHTTP(recorder, req)
+
+			if received != body {
+				t.Errorf("Handler received a different body: %s", received)
+			}
+			if recorder.Body.String() != `{"password":"hunter2"}` {
+				t.Errorf("Response was altered: %s", recorder.Body.String())
+			}
+
+			output := logged.String()
+			if !tc.expectLog {
+				if strings.Contains(output, "[debug]") {
+					t.Errorf("Expected no debug log, got %s", output)
+				}
+				return
+			}
+			if strings.Contains(output, "hunter2") {
+				t.Errorf("Expected the password to be redacted, got %s", output)
+			}
+			for _, expected := range []string{"request_id=req-42 POST /debug", fmt.Sprintf("truncated from %d bytes", len(body)), "request_id=req-42 status=200", `\"password\":\"[REDACTED]\"`} {
+				if !strings.Contains(output, expected) {
+					t.Errorf("Expected the log to contain %s, got %s", expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestDebugBodyLoggingStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.NewConfig()
+	cfg.DebugBodyLogging = true
+	cfg.DebugBodyMaxBytes = 16
+	h := New(&MockDeviceService{}, &MockIncidentService{}, &MockCommandService{}, &MockChangeService{}, cfg)
+	captureLog(t)
+
+	flushed := false
+	h.router.GET("/stream", func(c *gin.Context) {
+		w := unbufferedWriter(c)
+		w.Header().Set("Content-Type", ndjsonContentType)
+		_, _ = w.Write([]byte(strings.Repeat(`{"n":1}`+"\n", 10)))
+		w.Flush()
+		flushed = true
+	})
+
+	req, _ := http.NewRequest("GET", "/stream", nil)
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+
+	if !flushed || !recorder.Flushed {
+		t.Error("Expected the stream to be flushed through the debug writer")
+	}
+	if recorder.Body.Len() != 80 {
+		t.Errorf("Expected the whole 80 byte stream, got %d bytes", recorder.Body.Len())
+	}
+}
+
+func TestDebugBodyLoggingSkipsExports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.NewConfig()
+	cfg.DebugBodyLogging = true
+	mockSvc := &MockDeviceService{
+		exportFunc: func(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+			return fn(&models.AlarmRecord{ID: 1, DeviceID: filter.DeviceID, Level: models.AlarmLevelInfo, Reason: "secret reason", Event: models.AlarmEventTriggered})
+		},
+	}
+	h := New(mockSvc, &MockIncidentService{}, &MockCommandService{}, &MockChangeService{}, cfg)
+	logged := captureLog(t)
+
+	req, _ := http.NewRequest("GET", "/api/devices/1/alarms/export", nil)
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "secret reason") {
+		t.Fatalf("Expected the export to be served, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if output := logged.String(); strings.Contains(output, "[debug]") {
+		t.Errorf("Expected the export not to be logged, got %s", output)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	router := newTestServer(&MockDeviceService{}, testutil.NewConfig())
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		reused bool
+	}{
+		{"Client id is reused", "abc-123", true},
+		{"Missing id is generated", "", false},
+		{"Unprintable id is replaced", "bad\x01id", false},
+		{"Overlong id is replaced", strings.Repeat("a", maxRequestIDLength+1), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/admin/stats", nil)
+			req.Header.Set(requestIDHeader, tc.header)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			got := recorder.Header().Get(requestIDHeader)
+			if got == "" || (got == tc.header) != tc.reused {
+				t.Errorf("Unexpected X-Request-ID %q for %q", got, tc.header)
+			}
+		})
+	}
+}
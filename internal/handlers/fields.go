@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// parseFieldsQuery reads ?fields=, the device fields a JSON response is limited to, as in
+// ?fields=name,is_online. On an unknown field, or when XML was negotiated, it writes a 400
+// response and returns false.
+func parseFieldsQuery(c *gin.Context) ([]string, bool) {
+	fields := splitQueryList(c, "fields")
+	if len(fields) == 0 {
+		return nil, true
+	}
+	for _, field := range fields {
+		if !models.IsValidDeviceField(field) {
+			respondError(c, http.StatusBadRequest, "fields must be among: "+strings.Join(models.DeviceFields, ", "))
+			return nil, false
+		}
+	}
+	if wantsXML(c) {
+		respondError(c, http.StatusBadRequest, "fields is only supported for JSON responses")
+		return nil, false
+	}
+
+	return fields, true
+}
+
+// deviceProjection is the set of fields a device response is limited to. A nil projection
+// keeps every field.
+type deviceProjection map[string]bool
+
+// newDeviceProjection returns the projection keeping fields, along with id, which identifies the
+// device, and alarm_count, which is only present when asked for
+func newDeviceProjection(fields []string) deviceProjection {
+	if len(fields) == 0 {
+		return nil
+	}
+	p := deviceProjection{"id": true, "alarm_count": true}
+	for _, field := range fields {
+		p[field] = true
+	}
+	return p
+}
+
+// apply returns the JSON representation of v, a device or a localized device, limited to the
+// projected fields. The local renderings added by ?tz are limited to the same fields.
+func (p deviceProjection) apply(v interface{}) (interface{}, error) {
+	if p == nil {
+		return v, nil
+	}
+
+	var full map[string]json.RawMessage
+	if err := remarshal(v, &full); err != nil {
+		return nil, err
+	}
+	partial := make(map[string]json.RawMessage, len(p)+1)
+	for key, value := range full {
+		if p[key] {
+			partial[key] = value
+		}
+	}
+
+	if raw, ok := full["local"]; ok {
+		var local map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &local); err != nil {
+			return nil, err
+		}
+		for key := range local {
+			if key != "timezone" && !p[key] {
+				delete(local, key)
+			}
+		}
+		if len(local) > 1 {
+			partial["local"], _ = json.Marshal(local)
+		}
+	}
+
+	return partial, nil
+}
+
+// respondProjected writes a list of devices or localized devices as JSON, each limited to p
+func respondProjected[T any](c *gin.Context, p deviceProjection, items []T) {
+	if p == nil {
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	projected := make([]interface{}, len(items))
+	for i, item := range items {
+		var err error
+		if projected[i], err = p.apply(item); err != nil {
+			respondError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, projected)
+}
+
+// remarshal converts v to out through its JSON representation
+func remarshal(v interface{}, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
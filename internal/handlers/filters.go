@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+// Filters given more than once are lists: ?device_type=CAMERA&device_type=LOCK keeps devices of
+// either type. Values of the same filter combine with OR and different filters with AND. Empty
+// values are ignored, so ?device_type= filters nothing, and any invalid value rejects the whole
+// request with a 400 naming it.
+
+// parseDeviceFilters reads the device filters shared by GET /api/devices and GET
+// /api/devices/count into opts, so a count always describes the matching list.
+// On failure it writes a 400 response and returns false.
+func parseDeviceFilters(c *gin.Context, opts *models.DeviceListOptions) bool {
+	var ok bool
+	if opts.Maintenance, ok = parseOptionalBoolQuery(c, "maintenance"); !ok {
+		return false
+	}
+	if opts.Online, ok = parseOptionalBoolQuery(c, "online"); !ok {
+		return false
+	}
+	if opts.AlarmActive, ok = parseOptionalBoolQuery(c, "alarm_active"); !ok {
+		return false
+	}
+	if opts.ExcludeUnknown, ok = parseBoolQuery(c, "exclude_unknown", false); !ok {
+		return false
+	}
+	// Archived devices are left out unless asked for
+	includeArchived, ok := parseBoolQuery(c, "include_archived", false)
+	if !ok {
+		return false
+	}
+	opts.ExcludeArchived = !includeArchived
+	if opts.DeviceTypes, ok = parseDeviceTypesQuery(c, "device_type"); !ok {
+		return false
+	}
+	if opts.IDs, ok = parseIDsQuery(c, "id"); !ok {
+		return false
+	}
+
+	opts.Owners = queryList(c, "owned_by")
+	opts.Names = queryList(c, "name")
+
+	return true
+}
+
+// parseAlarmHistoryFilter reads the level, reason code and after/before time range filters shared
+// by the alarm history and alarm count endpoints into filter, accepting the alarm levels in levels.
+// On failure it writes a 400 response and returns false.
+func parseAlarmHistoryFilter(c *gin.Context, filter *models.AlarmHistoryFilter, levels *models.AlarmLevels) bool {
+	var ok bool
+	if filter.Levels, ok = parseEnumQuery(c, "level", levels.IDs()...); !ok {
+		return false
+	}
+	if filter.Events, ok = parseEnumQuery(c, "event", models.AlarmEventTriggered, models.AlarmEventAutoCleared); !ok {
+		return false
+	}
+	filter.ReasonCodes = splitQueryList(c, "reason_code")
+	for _, code := range filter.ReasonCodes {
+		if !validation.IsValidReasonCode(code) {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid reason_code %q", code))
+			return false
+		}
+	}
+	if filter.After, ok = parseTimeQuery(c, "after"); !ok {
+		return false
+	}
+	if filter.Before, ok = parseTimeQuery(c, "before"); !ok {
+		return false
+	}
+	if !filter.After.IsZero() && !filter.Before.IsZero() && !filter.After.Before(filter.Before) {
+		respondError(c, http.StatusBadRequest, "after must be earlier than before")
+		return false
+	}
+
+	return true
+}
+
+// queryList returns the non-empty values of a query parameter that may be repeated, without
+// duplicates and in the order given, or nil when there are none
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, value := range c.QueryArray(key) {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// splitQueryList returns the values of a query parameter that may be repeated, comma-separated or
+// both, as in ?device_type=CAMERA,MOTION_SENSOR&device_type=LOCK, dropping empty and repeated
+// values. Only parameters whose values cannot hold a comma are read this way.
+func splitQueryList(c *gin.Context, key string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, value := range queryList(c, key) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" || seen[part] {
+				continue
+			}
+			seen[part] = true
+			values = append(values, part)
+		}
+	}
+
+	return values
+}
+
+// parseDeviceTypesQuery parses a repeatable or comma-separated device type query parameter. On
+// failure it writes a 400 response naming the invalid type and returns false.
+func parseDeviceTypesQuery(c *gin.Context, key string) ([]models.DeviceType, bool) {
+	var types []models.DeviceType
+	for _, value := range splitQueryList(c, key) {
+		dt := models.DeviceType(value)
+		if !dt.IsValid() {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid %s %q", key, value))
+			return nil, false
+		}
+		types = append(types, dt)
+	}
+
+	return types, true
+}
+
+// parseIDsQuery parses a repeatable id query parameter. On failure it writes a 400 response naming
+// the invalid id and returns false.
+func parseIDsQuery(c *gin.Context, key string) ([]int64, bool) {
+	var ids []int64
+	for _, value := range queryList(c, key) {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid %s %q, must be a positive integer", key, value))
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, true
+}
+
+// parseEnumQuery parses a repeatable query parameter whose values must be among allowed. On
+// failure it writes a 400 response naming the invalid value and returns false.
+func parseEnumQuery(c *gin.Context, key string, allowed ...string) ([]string, bool) {
+	values := queryList(c, key)
+	for _, value := range values {
+		if !slices.Contains(allowed, value) {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid %s %q, must be one of: %s", key, value, strings.Join(allowed, ", ")))
+			return nil, false
+		}
+	}
+
+	return values, true
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestParseDeviceFilters(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name          string
+		query         string
+		expected      models.DeviceListOptions
+		expectedError string
+	}{
+		{"No filters", "", models.DeviceListOptions{}, ""},
+		{"Single values", "?device_type=CAMERA&owned_by=alice&name=door&id=3&online=true",
+			models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeCamera}, Owners: []string{"alice"}, Names: []string{"door"}, IDs: []int64{3}, Online: &yes}, ""},
+		{"Repeated values", "?device_type=CAMERA&device_type=LOCK&owned_by=alice&owned_by=bob&name=door&name=hall&id=3&id=5",
+			models.DeviceListOptions{
+				DeviceTypes: []models.DeviceType{models.DeviceTypeCamera, models.DeviceTypeLock},
+				Owners:      []string{"alice", "bob"},
+				Names:       []string{"door", "hall"},
+				IDs:         []int64{3, 5},
+			}, ""},
+		{"Comma-separated types", "?device_type=CAMERA,MOTION_SENSOR",
+			models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeCamera, models.DeviceTypeMotionSensor}}, ""},
+		{"Comma-separated and repeated types", "?device_type=CAMERA,%20LOCK,&device_type=LOCK&device_type=THERMOSTAT",
+			models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeCamera, models.DeviceTypeLock, models.DeviceTypeThermostat}}, ""},
+		{"Duplicates dropped", "?device_type=LOCK&device_type=LOCK&owned_by=alice&owned_by=alice",
+			models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeLock}, Owners: []string{"alice"}}, ""},
+		{"Empty values ignored", "?device_type=&owned_by=&name=&id=&online=&exclude_unknown=", models.DeviceListOptions{}, ""},
+		{"Empty values among others", "?device_type=&device_type=LOCK&id=&id=7",
+			models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeLock}, IDs: []int64{7}}, ""},
+		{"Repeated state filter", "?alarm_active=false&alarm_active=false", models.DeviceListOptions{AlarmActive: &no}, ""},
+		{"Both states match everything", "?online=true&online=false", models.DeviceListOptions{}, ""},
+		{"Repeated switch", "?exclude_unknown=true&exclude_unknown=true", models.DeviceListOptions{ExcludeUnknown: true}, ""},
+		{"Archived included", "?include_archived=true", models.DeviceListOptions{}, ""},
+		{"Archived excluded explicitly", "?include_archived=false", models.DeviceListOptions{ExcludeArchived: true}, ""},
+		{"Invalid include_archived", "?include_archived=sometimes", models.DeviceListOptions{}, "include_archived"},
+		{"Invalid device type", "?device_type=TOASTER", models.DeviceListOptions{}, `\"TOASTER\"`},
+		{"Mixed valid and invalid type", "?device_type=CAMERA&device_type=TOASTER&device_type=LOCK", models.DeviceListOptions{}, `\"TOASTER\"`},
+		{"Invalid type in a list", "?device_type=CAMERA,TOASTER", models.DeviceListOptions{}, `\"TOASTER\"`},
+		{"Invalid id", "?id=3&id=abc", models.DeviceListOptions{}, `\"abc\"`},
+		{"Non-positive id", "?id=0", models.DeviceListOptions{}, `\"0\"`},
+		{"Invalid state", "?maintenance=true&maintenance=maybe", models.DeviceListOptions{}, `\"maybe\"`},
+		{"Conflicting switch", "?exclude_unknown=true&exclude_unknown=false", models.DeviceListOptions{}, "exclude_unknown"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext("/api/devices"+tc.query, nil)
+
+			var opts models.DeviceListOptions
+			ok := parseDeviceFilters(c, &opts)
+			if tc.expectedError != "" {
+				if ok || recorder.Code != http.StatusBadRequest {
+					t.Fatalf("Expected a 400, got ok %t and status %d", ok, recorder.Code)
+				}
+				if !strings.Contains(recorder.Body.String(), tc.expectedError) {
+					t.Errorf("Expected the error to name %s, got %s", tc.expectedError, recorder.Body.String())
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Expected the filters to parse, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			// Archived devices are excluded unless the case asks about them
+			expected := tc.expected
+			if !strings.Contains(tc.query, "include_archived") {
+				expected.ExcludeArchived = true
+			}
+			if !reflect.DeepEqual(opts, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, opts)
+			}
+		})
+	}
+}
+
+func TestParseAlarmHistoryFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedLevels []string
+		expectedError  string
+	}{
+		{"No filters", "", nil, ""},
+		{"Repeated levels", "?level=INFO&level=CRITICAL", []string{"INFO", "CRITICAL"}, ""},
+		{"Empty level ignored", "?level=&level=INFO", []string{"INFO"}, ""},
+		{"Mixed valid and invalid level", "?level=INFO&level=LOUD", nil, `\"LOUD\"`},
+		{"Same bound repeated", "?after=2024-05-01T00:00:00Z&after=2024-05-01T00:00:00Z", nil, ""},
+		{"Different bounds", "?after=2024-05-01T00:00:00Z&after=2024-05-02T00:00:00Z", nil, "after may only be given once"},
+		{"Invalid bound", "?before=yesterday", nil, `\"yesterday\"`},
+		{"Event", "?event=auto_cleared", nil, ""},
+		{"Invalid event", "?event=cleared", nil, `\"cleared\"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext("/api/alarms/count"+tc.query, nil)
+
+			var filter models.AlarmHistoryFilter
+			ok := parseAlarmHistoryFilter(c, &filter, nil)
+			if tc.expectedError != "" {
+				if ok || recorder.Code != http.StatusBadRequest {
+					t.Fatalf("Expected a 400, got ok %t and status %d", ok, recorder.Code)
+				}
+				if !strings.Contains(recorder.Body.String(), tc.expectedError) {
+					t.Errorf("Expected the error to mention %s, got %s", tc.expectedError, recorder.Body.String())
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Expected the filter to parse, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if !reflect.DeepEqual(filter.Levels, tc.expectedLevels) {
+				t.Errorf("Expected levels %v, got %v", tc.expectedLevels, filter.Levels)
+			}
+		})
+	}
+}
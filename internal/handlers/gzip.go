@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipWriter buffers the response body so the middleware can decide whether to compress it
+type gzipWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+// unbufferedWriter returns the writer beneath any gzip buffer, so streamed responses reach the
+// client as they are written rather than after the handler returns
+func unbufferedWriter(c *gin.Context) gin.ResponseWriter {
+	if gz, ok := c.Writer.(*gzipWriter); ok {
+		return gz.ResponseWriter
+	}
+	return c.Writer
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip. An explicit gzip entry
+// takes precedence over a "*" wildcard, and either is refused by a q-value of zero.
+func acceptsGzip(header string) bool {
+	gzipWeight, wildcardWeight := -1.0, -1.0
+	for _, entry := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipWeight = weight
+		case "*":
+			wildcardWeight = weight
+		}
+	}
+
+	if gzipWeight >= 0 {
+		return gzipWeight > 0
+	}
+	return wildcardWeight > 0
+}
+
+// gzipMiddleware compresses responses of at least minSize bytes for clients that accept gzip.
+// When routes is non-nil only the listed route paths are compressed. Every response from a
+// route that may be compressed varies on Accept-Encoding, whether or not this one was.
+func gzipMiddleware(minSize int, routes map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if routes != nil && !routes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		writer := &gzipWriter{ResponseWriter: original}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		body := writer.buf.Bytes()
+		if len(body) == 0 {
+			return
+		}
+
+		header := original.Header()
+		if len(body) < minSize || header.Get("Content-Encoding") != "" {
+			if _, err := original.Write(body); err != nil {
+				log.Printf("Error writing response: %v", err)
+			}
+			return
+		}
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(body); err != nil {
+			log.Printf("Error compressing response: %v", err)
+		}
+		if err := gz.Close(); err != nil {
+			log.Printf("Error compressing response: %v", err)
+		}
+
+		header.Set("Content-Encoding", "gzip")
+		header.Set("Content-Length", strconv.Itoa(compressed.Len()))
+		if _, err := original.Write(compressed.Bytes()); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
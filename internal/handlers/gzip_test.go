@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/internal/testutil"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	largeBody := strings.Repeat("a", 2048)
+
+	tests := []struct {
+		name           string
+		routes         map[string]bool
+		path           string
+		acceptEncoding string
+		expectGzip     bool
+		expectVary     bool
+	}{
+		{"Large response compressed", nil, "/large", "gzip", true, true},
+		{"Small response not compressed", nil, "/small", "gzip", false, true},
+		{"Client without gzip support", nil, "/large", "", false, true},
+		{"Gzip refused by q=0", nil, "/large", "gzip;q=0, deflate", false, true},
+		{"Gzip weighted", nil, "/large", "deflate, gzip;q=0.5", true, true},
+		{"Wildcard accepted", nil, "/large", "*", true, true},
+		{"Wildcard overridden by gzip q=0", nil, "/large", "*, gzip;q=0", false, true},
+		{"Listed route compressed", map[string]bool{"/large": true}, "/large", "gzip, deflate", true, true},
+		{"Unlisted route not compressed", map[string]bool{"/other": true}, "/large", "gzip", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(gzipMiddleware(1024, tc.routes))
+			router.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, largeBody) })
+			router.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+			}
+
+			isGzip := recorder.Header().Get("Content-Encoding") == "gzip"
+			if isGzip != tc.expectGzip {
+				t.Fatalf("Expected gzip = %v, got %v", tc.expectGzip, isGzip)
+			}
+			if vary := recorder.Header().Get("Vary") == "Accept-Encoding"; vary != tc.expectVary {
+				t.Errorf("Expected Vary: Accept-Encoding = %v, got %q", tc.expectVary, recorder.Header().Get("Vary"))
+			}
+
+			body := recorder.Body.String()
+			if isGzip {
+				reader, err := gzip.NewReader(recorder.Body)
+				if err != nil {
+					t.Fatalf("Failed to create gzip reader: %v", err)
+				}
+				decoded, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("Failed to decompress body: %v", err)
+				}
+				body = string(decoded)
+			}
+
+			if tc.path == "/large" && body != largeBody {
+				t.Errorf("Unexpected body length %d", len(body))
+			}
+		})
+	}
+}
+
+func TestListRoutesAreRegistered(t *testing.T) {
+	router := newTestServer(&MockDeviceService{}, testutil.NewConfig())
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		if route.Method == http.MethodGet {
+			registered[route.Path] = true
+		}
+	}
+	for path := range listRoutes {
+		if !registered[path] {
+			t.Errorf("List route %s is not a registered GET route", path)
+		}
+	}
+	for _, path := range []string{"/api/changes", "/api/devices/:id/commands"} {
+		if !listRoutes[path] {
+			t.Errorf("Expected %s to be a list route", path)
+		}
+	}
+}
@@ -1,48 +1,191 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/tyrese-r/go-home/internal/validation"
+	"github.com/tyrese-r/go-home/internal/config"
+	"github.com/tyrese-r/go-home/pkg/validation"
 
 	"github.com/gin-gonic/gin"
-	"github.com/tyrese-r/go-home/internal/models"
+	"github.com/tyrese-r/go-home/pkg/models"
 )
 
+// listRoutes are the routes returning collections, used to scope list-only middleware
+var listRoutes = map[string]bool{
+	"/api/devices":                  true,
+	"/api/alarms/active":            true,
+	"/api/incidents":                true,
+	"/api/devices/:id/alarms":       true,
+	"/api/devices/:id/children":     true,
+	"/api/devices/recently-alarmed": true,
+	"/api/devices/stale":            true,
+	"/api/devices/recent":           true,
+	"/api/owners":                   true,
+	"/api/changes":                  true,
+	"/api/devices/:id/commands":     true,
+}
+
 // DeviceServiceInterface defines the interface for the device service
 type DeviceServiceInterface interface {
-	CreateDevice(device *models.DeviceCreate) (int64, error)
+	CreateDevice(device *models.DeviceCreate) (*models.Device, error)
+	ImportDevices(devices []*models.DeviceCreate) error
 	GetDeviceByID(id int64) (*models.Device, error)
+	GetDeviceByName(owner, name string) (*models.Device, error)
+	GetDeviceWithAlarmCount(id int64) (*models.Device, error)
 	GetAllDevices() ([]*models.Device, error)
-	UpdateDevice(id int64, device *models.DeviceUpdate) error
-	DeleteDevice(id int64) error
+	ListDevices(opts *models.DeviceListOptions) ([]*models.Device, error)
+	CountDevices(opts *models.DeviceListOptions) (int, error)
+	CountOnlineDevices() (*models.DeviceCounts, error)
+	FuzzySearchDevices(ctx context.Context, opts *models.DeviceListOptions) ([]*models.Device, error)
+	GetDevicesLastModified() (time.Time, error)
+	StreamDevices(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error
+	UpdateDevice(id int64, device *models.DeviceUpdate) (bool, error)
+	DeleteDevice(id int64, children models.ChildPolicy) error
+	ResetDevice(id int64) (*models.Device, error)
+	GetChildren(id int64, limit, offset int) ([]*models.Device, error)
+	GetRecentDevices(limit int) ([]*models.Device, error)
 	TriggerAlarm(id int64, alarm *models.AlarmRequest) error
+	TriggerAlarmIdempotent(id int64, alarm *models.AlarmRequest) (*models.AlarmRecord, error)
+	TriggerAlarmByType(deviceType models.DeviceType, alarm *models.AlarmRequest) (*models.TypeAlarmResult, error)
+	ClearAlarm(id int64) error
+	AcknowledgeAlarm(id int64) error
+	SetMaintenance(id int64, req *models.MaintenanceRequest) error
+	SetArchived(id int64, archived bool) error
+	GetOwners(opts *models.OwnerListOptions) ([]string, error)
+	CheckOwner(owner string) (known bool, suggestions []string, err error)
+	RenameOwner(req *models.OwnerRename, merge bool) (*models.OwnerRenameResult, error)
+	StartFirmwareUpdate(id int64, req *models.FirmwareUpdateRequest) error
+	ReportFirmwareUpdate(id int64, report *models.FirmwareReport) error
+	RecordDeviceSeen(id int64) error
+	SetDeviceConnected(id int64, connected bool) error
+	GetActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error)
+	GetAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error)
+	ExportAlarmHistory(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error
+	CountAlarms(filter *models.AlarmHistoryFilter) (int, error)
+	CountAlarmsByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error)
+	ListAlarmReasons() ([]*models.AlarmReason, error)
+	SaveAlarmReason(reason *models.AlarmReason) (*models.AlarmReason, bool, error)
+	DeleteAlarmReason(code string) error
+	GetDashboard() (*models.Dashboard, error)
+	GetDeviceStateCounts() ([]*models.DeviceTypeCounts, error)
+	GetDeviceStats() (*models.DeviceStats, error)
+	GetDeviceHealth(id int64) (*models.DeviceHealth, error)
+	VacuumDatabase() (*models.VacuumResult, error)
+	ReconnectDatabase() error
+	DatabaseReconnecting() bool
+	PingDatabase(ctx context.Context) error
+	GetRawDevice(id int64, primary bool) (*models.RawDeviceRow, error)
+	DiffDevices(ctx context.Context, manifest *models.Manifest, apply bool) (*models.DeviceDiff, error)
+}
+
+// IncidentServiceInterface defines the interface for the incident service
+type IncidentServiceInterface interface {
+	ListIncidents(opts *models.IncidentListOptions) ([]*models.Incident, error)
+	ResolveIncident(id int64) error
+}
+
+// CommandServiceInterface defines the interface for the device command queue service
+type CommandServiceInterface interface {
+	EnqueueCommand(deviceID int64, req *models.CommandRequest) (*models.DeviceCommand, error)
+	PollCommands(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error)
+	PendingCommands(deviceID int64) ([]*models.DeviceCommand, error)
+	MarkDelivered(deviceID, id int64) error
+	AckCommand(deviceID, id int64) error
+}
+
+// ChangeServiceInterface defines the interface for the change feed service
+type ChangeServiceInterface interface {
+	GetChanges(since int64, limit int) (*models.ChangeFeed, error)
 }
 
 // Handler handles HTTP requests
 type Handler struct {
-	deviceService DeviceServiceInterface
-	router        *gin.Engine
-	startTime     time.Time
+	deviceService   DeviceServiceInterface
+	incidentService IncidentServiceInterface
+	commandService  CommandServiceInterface
+	changeService   ChangeServiceInterface
+	config          *config.Config
+	router          *gin.Engine
+	startTime       time.Time
+	// cursorKey signs the opaque cursors of paginated lists
+	cursorKey []byte
+	// allowedTypes are the device types devices may be created with or changed to
+	allowedTypes validation.AllowedDeviceTypes
+	// alarmLevels are the levels alarms may be raised with
+	alarmLevels *models.AlarmLevels
+
+	// conns holds the WebSocket connections of currently connected devices
+	conns *deviceConnections
+	// metrics counts requests per route for GET /api/admin/stats
+	metrics *requestMetrics
 }
 
 // New creates a new Handler
-func New(deviceService DeviceServiceInterface) *Handler {
+func New(deviceService DeviceServiceInterface, incidentService IncidentServiceInterface, commandService CommandServiceInterface, changeService ChangeServiceInterface, cfg *config.Config) *Handler {
 	h := &Handler{
-		deviceService: deviceService,
-		router:        gin.Default(),
-		startTime:     time.Now(),
+		deviceService:   deviceService,
+		incidentService: incidentService,
+		commandService:  commandService,
+		changeService:   changeService,
+		config:          cfg,
+		router:          gin.Default(),
+		startTime:       time.Now(),
+		cursorKey:       newCursorKey(cfg.CursorSecret),
+		allowedTypes:    validation.NewAllowedDeviceTypes(cfg.AllowedDeviceTypes, cfg.DeprecatedDeviceTypes),
+		alarmLevels:     cfg.AlarmLevelRegistry(),
+		conns:           newDeviceConnections(),
+		metrics:         newRequestMetrics(),
+	}
+
+	// Routes are registered without a trailing slash, which is the canonical form. Requests
+	// for the other form are redirected to it: 301 for GET and 307 for other methods, so the
+	// method and body are kept.
+	h.router.RedirectTrailingSlash = true
+
+	// Forwarded client addresses are only believed from configured proxies. gin trusts every
+	// proxy by default, which would let any client choose the address it is logged under.
+	if err := h.router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Invalid trusted proxies, trusting none: %v", err)
+		_ = h.router.SetTrustedProxies(nil)
 	}
 
-	// Set up routes
+	// Set up middleware and routes
+	h.setupMiddleware()
 	h.setupRoutes()
 
 	return h
 }
 
+// setupMiddleware configures middleware applied to every route
+func (h *Handler) setupMiddleware() {
+	h.router.Use(requestIDMiddleware())
+	// Registered before the rest so latencies include the work of the other middleware
+	h.router.Use(h.metrics.middleware())
+	// Requests turned away by the limit are still counted by the metrics above
+	if h.config.MaxConcurrentRequests > 0 {
+		h.router.Use(concurrencyLimitMiddleware(h.config.MaxConcurrentRequests, h.config.ConcurrencyRetryAfter))
+	}
+	// Body logging sits outside gzip so it sees gzip's buffer as the handler's writer, keeping
+	// unbufferedWriter able to reach past it for streams
+	if h.config.DebugBodyLogging || h.config.AdminToken != "" {
+		h.router.Use(h.debugBodyMiddleware(h.config.DebugBodyMaxBytes, h.config.DebugRedactKeys))
+	}
+
+	if h.config.GzipEnabled {
+		var routes map[string]bool
+		if h.config.GzipListOnly {
+			routes = listRoutes
+		}
+		h.router.Use(gzipMiddleware(h.config.GzipMinSize, routes))
+	}
+}
+
 // setupRoutes configures the HTTP routes
 func (h *Handler) setupRoutes() {
 	// Health check endpoint
@@ -52,112 +195,583 @@ func (h *Handler) setupRoutes() {
 	{
 		devices := api.Group("/devices")
 		{
-			devices.GET("", h.getAllDevices)
-			devices.GET("/:id", h.getDeviceByID)
+			devices.GET("", negotiate(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2, ndjsonContentType), h.getAllDevices)
+			devices.GET("/:id", negotiate(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2), h.getDeviceByID)
+			devices.GET("/count", h.countDevices)
+			devices.GET("/online-count", h.countOnlineDevices)
+			devices.GET("/by-name", negotiate(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2), h.getDeviceByName)
+			devices.GET("/metrics", h.getDeviceMetrics)
+			devices.GET("/stats", h.getDeviceStats)
+			devices.GET("/recently-alarmed", h.getRecentlyAlarmedDevices)
+			devices.GET("/stale", h.getStaleDevices)
+			devices.GET("/recent", h.getRecentDevices)
 			devices.POST("", h.createDevice)
+			devices.POST("/import", h.importDevices)
+			devices.POST("/diff", h.diffDevices)
 			devices.PUT("/:id", h.updateDevice)
 			devices.DELETE("/:id", h.deleteDevice)
+			devices.POST("/:id/reset", h.resetDevice)
 			devices.POST("/:id/alarm", h.triggerDeviceAlarm)
+			devices.DELETE("/:id/alarm", h.clearDeviceAlarm)
+			devices.POST("/:id/alarm/ack", h.acknowledgeDeviceAlarm)
+			devices.GET("/:id/alarms", h.getDeviceAlarms)
+			devices.GET("/:id/alarms/export", h.exportDeviceAlarms)
+			devices.GET("/:id/health", h.getDeviceHealth)
+			devices.GET("/:id/children", h.getDeviceChildren)
+			devices.POST("/:id/maintenance", h.setDeviceMaintenance)
+			devices.POST("/:id/archive", h.archiveDevice)
+			devices.POST("/:id/unarchive", h.unarchiveDevice)
+			devices.POST("/:id/firmware", h.startFirmwareUpdate)
+			devices.POST("/:id/firmware/status", h.reportFirmwareUpdate)
+			devices.GET("/:id/ws", h.deviceWebSocket)
+			devices.POST("/:id/commands", h.enqueueDeviceCommand)
+			devices.GET("/:id/commands", h.pollDeviceCommands)
+			devices.POST("/:id/commands/:command_id/ack", h.ackDeviceCommand)
+		}
+
+		alarms := api.Group("/alarms")
+		{
+			alarms.GET("/active", h.getActiveAlarms)
+			alarms.GET("/count", h.countAlarms)
+		}
+
+		api.GET("/device-types", negotiate(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2), h.getDeviceTypes)
+		api.POST("/device-types/:type/alarm", h.triggerTypeAlarm)
+		api.GET("/alarm-levels", h.getAlarmLevels)
+		api.GET("/validation-rules", h.getValidationRules)
+		api.GET("/dashboard", h.getDashboard)
+		api.GET("/changes", h.getChanges)
+		api.GET("/owners", h.getOwners)
+
+		settings := api.Group("/settings")
+		{
+			settings.GET("/alarm-ttls", h.getAlarmTTLs)
+			settings.GET("/alarm-escalation", h.getAlarmEscalation)
+			settings.GET("/alarm-presence", h.getAlarmPresence)
+		}
+
+		incidents := api.Group("/incidents")
+		{
+			incidents.GET("", h.getIncidents)
+			incidents.POST("/:id/resolve", h.resolveIncident)
 		}
-	}
-}
 
-// StartServer starts the HTTP server
-func (h *Handler) StartServer(addr string) error {
-	return h.router.Run(addr)
+		admin := api.Group("/admin")
+		{
+			admin.GET("/stats", h.requireAdmin(), h.getRequestStats)
+			admin.DELETE("/stats", h.requireAdmin(), h.resetRequestStats)
+			admin.POST("/vacuum", h.requireAdmin(), h.vacuumDatabase)
+			admin.POST("/db/reconnect", h.requireAdmin(), h.reconnectDatabase)
+			admin.POST("/owners/rename", h.requireAdmin(), h.renameOwner)
+			admin.GET("/alarm-reasons", h.requireAdmin(), h.getAlarmReasons)
+			admin.PUT("/alarm-reasons/:code", h.requireAdmin(), h.putAlarmReason)
+			admin.DELETE("/alarm-reasons/:code", h.requireAdmin(), h.deleteAlarmReason)
+			// Diagnostic: rows as stored, whose shape follows the schema rather than the API
+			admin.GET("/devices/:id/raw", h.requireAdmin(), h.getRawDevice)
+		}
+	}
 }
 
 // getAllDevices handles GET /api/devices
 func (h *Handler) getAllDevices(c *gin.Context) {
-	devices, err := h.deviceService.GetAllDevices()
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+
+	// Without sort_by the configured default ordering applies; an explicit sort_by defaults to ascending
+	sortBy, sortOrder := h.config.DefaultDeviceSortBy, h.config.DefaultDeviceSortOrder
+	if field := c.Query("sort_by"); field != "" {
+		if !models.IsValidDeviceSortField(field) {
+			respondError(c, http.StatusBadRequest, "sort_by must be one of: "+strings.Join(models.DeviceSortFields, ", "))
+			return
+		}
+		sortBy, sortOrder = field, models.SortAsc
+	}
+
+	sortOrder = c.DefaultQuery("order", sortOrder)
+	if !models.IsValidSortOrder(sortOrder) {
+		respondError(c, http.StatusBadRequest, "order must be one of: asc, desc")
+		return
+	}
+
+	opts := models.DeviceListOptions{Limit: page.Limit, Offset: page.Offset, SortBy: sortBy, SortOrder: sortOrder}
+
+	// after continues from a cursor given in X-Next-Cursor, which fixes the ordering to created_at
+	if raw := c.Query("after"); raw != "" {
+		cursor, err := h.decodeCursor(raw, cursorKindDevices)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "after must be a cursor from X-Next-Cursor")
+			return
+		}
+		if page.Offset != 0 {
+			respondError(c, http.StatusBadRequest, "after cannot be combined with offset")
+			return
+		}
+		if field := c.Query("sort_by"); field != "" && field != "created_at" {
+			respondError(c, http.StatusBadRequest, "after requires sort_by=created_at")
+			return
+		}
+		if order := c.Query("order"); order != "" && order != cursor.Order {
+			respondError(c, http.StatusBadRequest, "order must match the cursor's order: "+cursor.Order)
+			return
+		}
+		opts.SortBy, opts.SortOrder = "created_at", cursor.Order
+		opts.After = &models.DeviceCursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID}
+	}
+	if !parseDeviceFilters(c, &opts) {
+		return
+	}
+	if opts.WithAlarmCount, ok = parseBoolQuery(c, "with_alarm_count", false); !ok {
+		return
+	}
+	if opts.Fields, ok = parseFieldsQuery(c); !ok {
+		return
+	}
+	// Selecting alarm_count loads it, as with_alarm_count does
+	if slices.Contains(opts.Fields, "alarm_count") {
+		opts.WithAlarmCount = true
+	}
+
+	// name is an exact substring match; fuzzy=true tolerates typos and orders by similarity instead
+	fuzzy, ok := parseBoolQuery(c, "fuzzy", false)
+	if !ok {
+		return
+	}
+
+	stream, ok := parseBoolQuery(c, "stream", false)
+	if !ok {
+		return
+	}
+	if fuzzy {
+		if len(opts.Names) == 0 {
+			respondError(c, http.StatusBadRequest, "fuzzy requires name")
+			return
+		}
+		if opts.After != nil {
+			respondError(c, http.StatusBadRequest, "fuzzy results cannot be paged with after")
+			return
+		}
+		// Ranking needs every match before the first can be written
+		if stream || c.GetString(formatKey) == ndjsonContentType {
+			respondError(c, http.StatusBadRequest, "fuzzy search cannot be streamed")
+			return
+		}
+	}
+
+	// Any device changing changes some page of the list, so the newest change covers every page
+	lastModified, err := h.deviceService.GetDevicesLastModified()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if notModified(c, lastModified) {
 		return
 	}
 
-	c.JSON(http.StatusOK, devices)
+	loc, ok := h.displayLocation(c)
+	if !ok {
+		return
+	}
+
+	if stream || c.GetString(formatKey) == ndjsonContentType {
+		h.streamDevices(c, &opts, loc)
+		return
+	}
+
+	// Lists sorted by created_at can be continued with a cursor. One extra device is fetched to
+	// learn whether there is a next page, so no cursor is given on the last one.
+	cursorable := !fuzzy && opts.SortBy == "created_at"
+	if cursorable {
+		opts.Limit++
+	}
+
+	var devices []*models.Device
+	if fuzzy {
+		devices, err = h.deviceService.FuzzySearchDevices(c.Request.Context(), &opts)
+	} else {
+		devices, err = h.deviceService.ListDevices(&opts)
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if cursorable && len(devices) > page.Limit {
+		devices = devices[:page.Limit]
+		c.Header(nextCursorHeader, h.encodeCursor(devices[len(devices)-1], opts.SortOrder))
+	}
+
+	// Local renderings and field selection are a JSON convenience; XML keeps to the plain UTC
+	// representation
+	if loc != nil && !wantsXML(c) {
+		respondProjected(c, newDeviceProjection(opts.Fields), localizeDevices(devices, loc))
+		return
+	}
+	if len(opts.Fields) > 0 {
+		respondProjected(c, newDeviceProjection(opts.Fields), devices)
+		return
+	}
+
+	respond(c, http.StatusOK, devices)
 }
 
 // getDeviceByID handles GET /api/devices/:id
 func (h *Handler) getDeviceByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	loc, ok := h.displayLocation(c)
+	if !ok {
+		return
+	}
+	withAlarmCount, ok := parseBoolQuery(c, "with_alarm_count", false)
+	if !ok {
+		return
+	}
+	fields, ok := parseFieldsQuery(c)
+	if !ok {
+		return
+	}
+
+	var device *models.Device
+	var err error
+	switch {
+	case len(fields) > 0:
+		device, err = h.getDeviceFields(id, fields, withAlarmCount)
+	case withAlarmCount:
+		device, err = h.deviceService.GetDeviceWithAlarmCount(id)
+	default:
+		device, err = h.deviceService.GetDeviceByID(id)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device ID"})
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if device == nil {
+		respondError(c, http.StatusNotFound, "device not found")
+		return
+	}
+	if notModified(c, device.UpdatedAt) {
 		return
 	}
 
-	device, err := h.deviceService.GetDeviceByID(id)
+	projection := newDeviceProjection(fields)
+	var body interface{} = device
+	if loc != nil && !wantsXML(c) {
+		body = localizeDevice(device, loc)
+	} else if projection == nil {
+		respond(c, http.StatusOK, device)
+		return
+	}
+
+	projected, err := projection.apply(body)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, projected)
+}
+
+// getDeviceFields reads the device with the given id loading only fields, or nil when there is
+// none. Selecting alarm_count loads it, as withAlarmCount does.
+func (h *Handler) getDeviceFields(id int64, fields []string, withAlarmCount bool) (*models.Device, error) {
+	devices, err := h.deviceService.ListDevices(&models.DeviceListOptions{
+		Limit:          1,
+		IDs:            []int64{id},
+		Fields:         fields,
+		WithAlarmCount: withAlarmCount || slices.Contains(fields, "alarm_count"),
+	})
+	if err != nil || len(devices) == 0 {
+		return nil, err
+	}
+	return devices[0], nil
+}
+
+// getDeviceByName handles GET /api/devices/by-name, looking a device up by owner and name for
+// clients that do not keep device ids
+func (h *Handler) getDeviceByName(c *gin.Context) {
+	owner, name := c.Query("owner"), c.Query("name")
+	if owner == "" || name == "" {
+		respondError(c, http.StatusBadRequest, "owner and name are required")
+		return
+	}
+	loc, ok := h.displayLocation(c)
+	if !ok {
+		return
+	}
+
+	device, err := h.deviceService.GetDeviceByName(owner, name)
+	if err != nil {
+		if errors.Is(err, models.ErrAmbiguousDevice) {
+			respondError(c, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 	if device == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		respondError(c, http.StatusNotFound, "device not found")
+		return
+	}
+	if notModified(c, device.UpdatedAt) {
 		return
 	}
 
-	c.JSON(http.StatusOK, device)
+	if loc != nil && !wantsXML(c) {
+		c.JSON(http.StatusOK, localizeDevice(device, loc))
+		return
+	}
+
+	respond(c, http.StatusOK, device)
+}
+
+// getDeviceTypes handles GET /api/device-types, flagging the deprecated types
+func (h *Handler) getDeviceTypes(c *gin.Context) {
+	types := models.GetAllDeviceTypes()
+	for i := range types {
+		types[i].Deprecated = h.allowedTypes.Deprecated(models.DeviceType(types[i].ID))
+	}
+	respond(c, http.StatusOK, types)
+}
+
+// getAlarmLevels handles GET /api/alarm-levels, listing the levels from least to most severe
+func (h *Handler) getAlarmLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, h.alarmLevels.List())
+}
+
+// getValidationRules handles GET /api/validation-rules, describing the limits device and alarm
+// input is validated against
+func (h *Handler) getValidationRules(c *gin.Context) {
+	c.JSON(http.StatusOK, validation.DescribeRules(h.allowedTypes, h.alarmLevels))
+}
+
+// strictValidationHeader asks for validation warnings to reject the request like errors
+const strictValidationHeader = "X-Validation-Strict"
+
+// noChangeHeader marks an update that was not written because it changed nothing
+const noChangeHeader = "X-No-Change"
+
+// deviceWithWarnings is a created device along with the validation warnings its input raised
+type deviceWithWarnings struct {
+	*models.Device
+	Warnings validation.ValidationErrors `json:"warnings,omitempty"`
+}
+
+// diffWithWarnings is a manifest comparison along with the validation warnings the manifest raised
+type diffWithWarnings struct {
+	*models.DeviceDiff
+	Warnings validation.ValidationErrors `json:"warnings,omitempty"`
+}
+
+// typeAlarmWithWarnings is the result of a type alarm along with the validation warnings its
+// request raised
+type typeAlarmWithWarnings struct {
+	*models.TypeAlarmResult
+	Warnings validation.ValidationErrors `json:"warnings,omitempty"`
+}
+
+// validated applies strict mode to a validation result when the request asks for it, localizes
+// its messages and rejects the request when it holds errors. It reports whether the request may
+// go ahead.
+func (h *Handler) validated(c *gin.Context, result *validation.Result) bool {
+	if c.GetHeader(strictValidationHeader) == "true" {
+		result.Strict()
+	}
+	result.Localize(requestLanguage(c))
+	if !result.Valid() {
+		h.validationFailed(c, result.Errors)
+		return false
+	}
+	return true
+}
+
+// noContentOrWarnings responds 204 to a request that succeeded, or 200 with the validation
+// warnings its input raised, which a 204 could not carry
+func noContentOrWarnings(c *gin.Context, warnings validation.ValidationErrors) {
+	if len(warnings) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"warnings": warnings})
+}
+
+// validationFailed rejects a well-formed request whose fields failed validation. It responds
+// 422 unless the configured status asks for 400; malformed bodies are always 400.
+func (h *Handler) validationFailed(c *gin.Context, validationErrors validation.ValidationErrors) {
+	status := h.config.ValidationErrorStatus
+	if status == 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"errors": validationErrors})
+}
+
+// normalizeName applies the configured device name normalization, so that names are validated
+// as the service will store them
+func (h *Handler) normalizeName(name string) string {
+	if !h.config.NormalizeDeviceNames {
+		return name
+	}
+	return validation.NormalizeDeviceName(name)
 }
 
 // createDevice handles POST /api/devices
 func (h *Handler) createDevice(c *gin.Context) {
 	var deviceCreate models.DeviceCreate
-	if err := c.ShouldBindJSON(&deviceCreate); err != nil {
+	if err := h.bindStrictJSON(c, &deviceCreate); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	validationSuccessful, validationErrors := validation.ValidateDeviceCreate(&deviceCreate)
-	if !validationSuccessful {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
+	deviceCreate.Name = h.normalizeName(deviceCreate.Name)
+	result := validation.ValidateDeviceCreate(&deviceCreate, h.allowedTypes)
+	if err := h.checkOwner(c, result, "owned_by", deviceCreate.OwnedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.validated(c, result) {
 		return
 	}
 
-	id, err := h.deviceService.CreateDevice(&deviceCreate)
+	device, err := h.deviceService.CreateDevice(&deviceCreate)
 	if err != nil {
+		if respondParentError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": id})
+	c.JSON(http.StatusCreated, deviceWithWarnings{Device: device, Warnings: result.Warnings})
 }
 
-// updateDevice handles PUT /api/devices/:id
-func (h *Handler) updateDevice(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// diffDevices handles POST /api/devices/diff, comparing a manifest of the devices that should
+// exist with those stored. It only reads unless ?apply=true, which needs admin access and
+// creates missing devices and updates changed ones, never deleting.
+func (h *Handler) diffDevices(c *gin.Context) {
+	apply, ok := parseBoolQuery(c, "apply", false)
+	if !ok {
+		return
+	}
+	if apply && !h.isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "admin token required to apply a manifest"})
+		return
+	}
+
+	var manifest models.Manifest
+	if err := h.bindStrictJSON(c, &manifest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, device := range manifest.Devices {
+		if device != nil {
+			device.Name = h.normalizeName(device.Name)
+		}
+	}
+	result := validation.ValidateManifest(&manifest, h.allowedTypes)
+	if !h.validated(c, result) {
+		return
+	}
+
+	diff, err := h.deviceService.DiffDevices(c.Request.Context(), &manifest, apply)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device ID"})
+		if errors.Is(err, models.ErrDeviceChanged) || errors.Is(err, models.ErrDeviceTypeLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diffWithWarnings{DeviceDiff: diff, Warnings: result.Warnings})
+}
+
+// updateDevice handles PUT /api/devices/:id. An update that changes nothing is not written and
+// is answered with X-No-Change: true.
+func (h *Handler) updateDevice(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
 		return
 	}
 
 	var deviceUpdate models.DeviceUpdate
-	if bindErr := c.ShouldBindJSON(&deviceUpdate); bindErr != nil {
+	if bindErr := h.bindStrictJSON(c, &deviceUpdate); bindErr != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
 		return
 	}
 
-	err = h.deviceService.UpdateDevice(id, &deviceUpdate)
+	// An update that sets nothing would only bump updated_at
+	if deviceUpdate.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	if deviceUpdate.Name != nil {
+		name := h.normalizeName(*deviceUpdate.Name)
+		deviceUpdate.Name = &name
+	}
+	result := validation.ValidateDeviceUpdate(&deviceUpdate, h.allowedTypes)
+	if deviceUpdate.OwnedBy != nil {
+		if err := h.checkOwner(c, result, "owned_by", *deviceUpdate.OwnedBy); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !h.validated(c, result) {
+		return
+	}
+
+	changed, err := h.deviceService.UpdateDevice(id, &deviceUpdate)
 	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrDeviceTypeLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if respondParentError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	if !changed {
+		c.Header(noChangeHeader, "true")
+	}
+	noContentOrWarnings(c, result.Warnings)
 }
 
-// deleteDevice handles DELETE /api/devices/:id
+// deleteDevice handles DELETE /api/devices/:id. A device with children is only deleted with
+// ?cascade=true, as parseChildPolicy reads it.
 func (h *Handler) deleteDevice(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device ID"})
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	children, ok := parseChildPolicy(c)
+	if !ok {
+		return
+	}
+	if h.config.RequireDeleteConfirmation && !h.deleteConfirmed(c, id) {
 		return
 	}
 
-	err = h.deviceService.DeleteDevice(id)
+	err := h.deviceService.DeleteDevice(id, children)
 	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrDeviceHasChildren) {
+			respondError(c, http.StatusConflict, err.Error()+"; delete with cascade=true to delete them too, or with detach=true as well to keep them")
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -165,10 +779,60 @@ func (h *Handler) deleteDevice(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// healthCheck handles GET /health
+// deleteConfirmed checks that ?confirm= names the device about to be deleted. Otherwise it writes
+// a 404 for an unknown device or a 400, and returns false.
+func (h *Handler) deleteConfirmed(c *gin.Context, id int64) bool {
+	confirm, given := c.GetQuery("confirm")
+	if !given || confirm == "" {
+		respondError(c, http.StatusBadRequest, "confirm must be given the name of the device to delete")
+		return false
+	}
+
+	device, err := h.deviceService.GetDeviceByID(id)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if device == nil {
+		respondError(c, http.StatusNotFound, "device not found")
+		return false
+	}
+	if h.normalizeName(confirm) != device.Name {
+		respondError(c, http.StatusBadRequest, "confirm does not match the name of the device to delete")
+		return false
+	}
+
+	return true
+}
+
+// healthCheck handles GET /health. The database probe gives up after the configured timeout, so
+// a stuck database makes the check fail promptly instead of hanging.
 func (h *Handler) healthCheck(c *gin.Context) {
-	// Dummy request to check db status
-	_, err := h.deviceService.GetAllDevices()
+	// Queries fail until a replaced database file is reopened, so the instance is not ready
+	if h.deviceService.DatabaseReconnecting() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":   "reconnecting",
+			"message":  "Reconnecting to the database",
+			"database": "reconnecting",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if h.config.HealthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.config.HealthCheckTimeout)
+		defer cancel()
+	}
+	err := h.deviceService.PingDatabase(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":   "error",
+			"message":  "Database did not answer within " + h.config.HealthCheckTimeout.String(),
+			"database": "timeout",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"status":  "error",
@@ -189,35 +853,101 @@ func (h *Handler) healthCheck(c *gin.Context) {
 	})
 }
 
-// triggerDeviceAlarm handles POST /api/devices/:id/alarm
+// triggerDeviceAlarm handles POST /api/devices/:id/alarm. A request repeating a client_event_id
+// the device has recorded is answered 200 with the alarm first recorded for it, recording nothing.
 func (h *Handler) triggerDeviceAlarm(c *gin.Context) {
 	// Parse device ID from URL
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device ID"})
+	id, ok := parseIDParam(c)
+	if !ok {
 		return
 	}
 
 	// Parse request body
 	var alarmRequest models.AlarmRequest
-	if bindErr := c.ShouldBindJSON(&alarmRequest); bindErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bindError": bindErr.Error()})
+	if bindErr := h.bindStrictJSON(c, &alarmRequest); bindErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
 		return
 	}
+	h.applyActor(c, &alarmRequest)
 
 	// Validate alarm request
-	validationSuccessful, validationErrors := validation.ValidateAlarmRequest(&alarmRequest)
-	if !validationSuccessful {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
+	validationResult := validation.ValidateAlarmRequest(&alarmRequest, h.alarmLevels)
+	if !h.validated(c, validationResult) {
 		return
 	}
 
 	// Trigger alarm on device
-	err = h.deviceService.TriggerAlarm(id, &alarmRequest)
+	existing, err := h.deviceService.TriggerAlarmIdempotent(id, &alarmRequest)
 	if err != nil {
 		// Handle device not found case specifically
-		if err.Error() == fmt.Sprintf("device not found with ID: %d", id) {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrDeviceArchived) {
+			respondArchived(c, err)
+			return
+		}
+		if refused := validation.ReasonCodeRefused(err); refused != nil {
+			h.validated(c, refused)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusOK, gin.H{"duplicate": true, "alarm": existing})
+		return
+	}
+
+	// Return success with 204 No Content, or the warnings the request raised
+	noContentOrWarnings(c, validationResult.Warnings)
+}
+
+// triggerTypeAlarm handles POST /api/device-types/:type/alarm, triggering the alarm on every
+// device of the type. A type with no devices is not an error; it reports zero triggered.
+func (h *Handler) triggerTypeAlarm(c *gin.Context) {
+	deviceType := models.DeviceType(c.Param("type"))
+	if !models.IsValidDeviceType(deviceType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown device type: " + string(deviceType)})
+		return
+	}
+
+	var alarmRequest models.AlarmRequest
+	if bindErr := h.bindStrictJSON(c, &alarmRequest); bindErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
+		return
+	}
+	h.applyActor(c, &alarmRequest)
+
+	validationResult := validation.ValidateAlarmRequest(&alarmRequest, h.alarmLevels)
+	if !h.validated(c, validationResult) {
+		return
+	}
+
+	result, err := h.deviceService.TriggerAlarmByType(deviceType, &alarmRequest)
+	if err != nil {
+		if refused := validation.ReasonCodeRefused(err); refused != nil {
+			h.validated(c, refused)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, typeAlarmWithWarnings{TypeAlarmResult: result, Warnings: validationResult.Warnings})
+}
+
+// clearDeviceAlarm handles DELETE /api/devices/:id/alarm
+func (h *Handler) clearDeviceAlarm(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.deviceService.ClearAlarm(id)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
@@ -225,6 +955,324 @@ func (h *Handler) triggerDeviceAlarm(c *gin.Context) {
 		return
 	}
 
-	// Return success with 204 No Content
 	c.Status(http.StatusNoContent)
 }
+
+// acknowledgeDeviceAlarm handles POST /api/devices/:id/alarm/ack
+func (h *Handler) acknowledgeDeviceAlarm(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.deviceService.AcknowledgeAlarm(id)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrNoActiveAlarm) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setDeviceMaintenance handles POST /api/devices/:id/maintenance
+func (h *Handler) setDeviceMaintenance(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.MaintenanceRequest
+	if bindErr := h.bindStrictJSON(c, &req); bindErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
+		return
+	}
+
+	if !h.validated(c, validation.ValidateMaintenanceRequest(&req)) {
+		return
+	}
+
+	err := h.deviceService.SetMaintenance(id, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// startFirmwareUpdate handles POST /api/devices/:id/firmware, recording a firmware version pushed
+// to the device as a pending update
+func (h *Handler) startFirmwareUpdate(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.FirmwareUpdateRequest
+	if bindErr := h.bindStrictJSON(c, &req); bindErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
+		return
+	}
+
+	if !h.validated(c, validation.ValidateFirmwareUpdateRequest(&req)) {
+		return
+	}
+
+	err := h.deviceService.StartFirmwareUpdate(id, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// reportFirmwareUpdate handles POST /api/devices/:id/firmware/status, where a device reports
+// whether its pending firmware update succeeded
+func (h *Handler) reportFirmwareUpdate(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var report models.FirmwareReport
+	if bindErr := h.bindStrictJSON(c, &report); bindErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
+		return
+	}
+
+	if !h.validated(c, validation.ValidateFirmwareReport(&report)) {
+		return
+	}
+
+	err := h.deviceService.ReportFirmwareUpdate(id, &report)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDeviceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrNoFirmwareUpdate):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// resetDevice handles POST /api/devices/:id/reset, wiping a device's alarm, online state and
+// description for reprovisioning while keeping its id, name, type and owner
+func (h *Handler) resetDevice(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	device, err := h.deviceService.ResetDevice(id)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// getDeviceAlarms handles GET /api/devices/:id/alarms. Deep pages are read with ?cursor, taken
+// from the X-Next-Cursor of the page before, rather than with offset.
+func (h *Handler) getDeviceAlarms(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	filter := models.AlarmHistoryFilter{DeviceID: id}
+	if !parseAlarmHistoryFilter(c, &filter, h.alarmLevels) {
+		return
+	}
+	// client_event_id looks up the alarm the device recorded for an event of the sender's
+	if filter.ClientEventID = c.Query("client_event_id"); filter.ClientEventID != "" && !validation.IsValidEventID(filter.ClientEventID) {
+		respondError(c, http.StatusBadRequest, "client_event_id is not a valid event id")
+		return
+	}
+
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+	filter.Limit, filter.Offset = page.Limit, page.Offset
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := h.decodeCursor(raw, cursorKindAlarms)
+		if err != nil || cursor.Order != models.SortDesc {
+			respondError(c, http.StatusBadRequest, "cursor must be a cursor from X-Next-Cursor")
+			return
+		}
+		if page.Offset != 0 {
+			respondError(c, http.StatusBadRequest, "cursor cannot be combined with offset")
+			return
+		}
+		filter.Cursor = &models.AlarmCursor{TriggeredAt: cursor.CreatedAt, ID: cursor.ID}
+	}
+
+	// One extra alarm is fetched to learn whether there is a next page, so no cursor is given on
+	// the last one
+	filter.Limit++
+	records, err := h.deviceService.GetAlarmHistory(&filter)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) > page.Limit {
+		records = records[:page.Limit]
+		c.Header(nextCursorHeader, h.encodeAlarmCursor(records[len(records)-1]))
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// getDeviceHealth handles GET /api/devices/:id/health
+func (h *Handler) getDeviceHealth(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	health, err := h.deviceService.GetDeviceHealth(id)
+	if err != nil {
+		if errors.Is(err, models.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// getDashboard handles GET /api/dashboard
+func (h *Handler) getDashboard(c *gin.Context) {
+	dashboard, err := h.deviceService.GetDashboard()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// getDeviceStats handles GET /api/devices/stats
+func (h *Handler) getDeviceStats(c *gin.Context) {
+	stats, err := h.deviceService.GetDeviceStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// vacuumDatabase handles POST /api/admin/vacuum. It holds up writes until the database file
+// has been rebuilt, so it is for maintenance windows rather than routine use.
+func (h *Handler) vacuumDatabase(c *gin.Context) {
+	result, err := h.deviceService.VacuumDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// reconnectDatabase handles POST /api/admin/db/reconnect, reopening the database's connection
+// pool, as after its file was restored while the server ran. Changes are normally picked up on
+// their own; this forces it.
+func (h *Handler) reconnectDatabase(c *gin.Context) {
+	if err := h.deviceService.ReconnectDatabase(); err != nil {
+		if errors.Is(err, models.ErrReconnectUnsupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "reconnected"})
+}
+
+// getRawDevice handles GET /api/admin/devices/:id/raw. It is a diagnostic endpoint for storage
+// and replication problems: columns are the stored text, timestamps unparsed and NULLs as null,
+// so its output changes with the schema and is not for integrations. ?primary=true reads from
+// the primary database when reads are otherwise served by a replica.
+func (h *Handler) getRawDevice(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	primary, ok := parseBoolQuery(c, "primary", false)
+	if !ok {
+		return
+	}
+
+	row, err := h.deviceService.GetRawDevice(id, primary)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if row == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, row)
+}
+
+// getActiveAlarms handles GET /api/alarms/active
+func (h *Handler) getActiveAlarms(c *gin.Context) {
+	var filter models.ActiveAlarmFilter
+	var ok bool
+	if filter.Levels, ok = parseEnumQuery(c, "level", h.alarmLevels.IDs()...); !ok {
+		return
+	}
+	if filter.DeviceTypes, ok = parseDeviceTypesQuery(c, "device_type"); !ok {
+		return
+	}
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+	filter.Limit, filter.Offset = page.Limit, page.Offset
+
+	devices, err := h.deviceService.GetActiveAlarms(&filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
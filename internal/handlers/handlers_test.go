@@ -2,28 +2,79 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/tyrese-r/go-home/internal/models"
+	"github.com/tyrese-r/go-home/internal/config"
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/validation"
 )
 
 // Use the DeviceServiceInterface defined in handlers.go
 
 // Mock implementation of the DeviceService
 type MockDeviceService struct {
-	getByIDFunc      func(id int64) (*models.Device, error)
-	getAllFunc       func() ([]*models.Device, error)
-	createFunc       func(device *models.DeviceCreate) (int64, error)
-	updateFunc       func(id int64, device *models.DeviceUpdate) error
-	deleteFunc       func(id int64) error
-	triggerAlarmFunc func(id int64, alarm *models.AlarmRequest) error
+	getByIDFunc         func(id int64) (*models.Device, error)
+	getByNameFunc       func(owner, name string) (*models.Device, error)
+	alarmCountFunc      func(id int64) (*models.Device, error)
+	getAllFunc          func() ([]*models.Device, error)
+	listFunc            func(opts *models.DeviceListOptions) ([]*models.Device, error)
+	countFunc           func(opts *models.DeviceListOptions) (int, error)
+	onlineCountFunc     func() (*models.DeviceCounts, error)
+	lastModifiedFunc    func() (time.Time, error)
+	fuzzyFunc           func(ctx context.Context, opts *models.DeviceListOptions) ([]*models.Device, error)
+	streamFunc          func(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error
+	createFunc          func(device *models.DeviceCreate) (*models.Device, error)
+	importFunc          func(devices []*models.DeviceCreate) error
+	updateFunc          func(id int64, device *models.DeviceUpdate) error
+	updateUnchanged     bool
+	deleteFunc          func(id int64, children models.ChildPolicy) error
+	resetFunc           func(id int64) (*models.Device, error)
+	childrenFunc        func(id int64, limit, offset int) ([]*models.Device, error)
+	recentFunc          func(limit int) ([]*models.Device, error)
+	triggerAlarmFunc    func(id int64, alarm *models.AlarmRequest) error
+	idempotentFunc      func(id int64, alarm *models.AlarmRequest) (*models.AlarmRecord, error)
+	typeAlarmFunc       func(deviceType models.DeviceType, alarm *models.AlarmRequest) (*models.TypeAlarmResult, error)
+	clearAlarmFunc      func(id int64) error
+	maintenanceFunc     func(id int64, req *models.MaintenanceRequest) error
+	archiveFunc         func(id int64, archived bool) error
+	ownersFunc          func(opts *models.OwnerListOptions) ([]string, error)
+	checkOwnerFunc      func(owner string) (bool, []string, error)
+	renameOwnerFunc     func(req *models.OwnerRename, merge bool) (*models.OwnerRenameResult, error)
+	firmwareFunc        func(id int64, req *models.FirmwareUpdateRequest) error
+	firmwareReportFunc  func(id int64, report *models.FirmwareReport) error
+	seenFunc            func(id int64) error
+	activeAlarmsFunc    func(filter *models.ActiveAlarmFilter) ([]*models.Device, error)
+	historyFunc         func(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error)
+	exportFunc          func(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error
+	alarmCountsFunc     func(filter *models.AlarmHistoryFilter) (int, error)
+	alarmCodeCountsFunc func(filter *models.AlarmHistoryFilter) (map[string]int, error)
+	reasonsFunc         func() ([]*models.AlarmReason, error)
+	saveReasonFunc      func(reason *models.AlarmReason) (*models.AlarmReason, bool, error)
+	deleteReasonFunc    func(code string) error
+	dashboardFunc       func() (*models.Dashboard, error)
+	stateCountsFunc     func() ([]*models.DeviceTypeCounts, error)
+	statsFunc           func() (*models.DeviceStats, error)
+	vacuumFunc          func() (*models.VacuumResult, error)
+	reconnectFunc       func() error
+	reconnecting        bool
+	rawFunc             func(id int64, primary bool) (*models.RawDeviceRow, error)
+	diffFunc            func(manifest *models.Manifest, apply bool) (*models.DeviceDiff, error)
+	healthFunc          func(id int64) (*models.DeviceHealth, error)
+	pingFunc            func(ctx context.Context) error
+	connectedFunc       func(id int64, connected bool) error
+	ackAlarmFunc        func(id int64) error
 }
 
 // Implement the DeviceServiceInterface
@@ -31,26 +82,224 @@ func (m *MockDeviceService) GetDeviceByID(id int64) (*models.Device, error) {
 	return m.getByIDFunc(id)
 }
 
+func (m *MockDeviceService) GetDeviceByName(owner, name string) (*models.Device, error) {
+	return m.getByNameFunc(owner, name)
+}
+
+func (m *MockDeviceService) GetDeviceWithAlarmCount(id int64) (*models.Device, error) {
+	return m.alarmCountFunc(id)
+}
+
+func (m *MockDeviceService) CountDevices(opts *models.DeviceListOptions) (int, error) {
+	return m.countFunc(opts)
+}
+
 func (m *MockDeviceService) GetAllDevices() ([]*models.Device, error) {
 	return m.getAllFunc()
 }
 
-func (m *MockDeviceService) CreateDevice(device *models.DeviceCreate) (int64, error) {
+func (m *MockDeviceService) ListDevices(opts *models.DeviceListOptions) ([]*models.Device, error) {
+	return m.listFunc(opts)
+}
+
+func (m *MockDeviceService) FuzzySearchDevices(ctx context.Context, opts *models.DeviceListOptions) ([]*models.Device, error) {
+	return m.fuzzyFunc(ctx, opts)
+}
+
+// RecordDeviceSeen does nothing unless a test sets seenFunc, so WebSocket tests need not stub it
+func (m *MockDeviceService) RecordDeviceSeen(id int64) error {
+	if m.seenFunc == nil {
+		return nil
+	}
+	return m.seenFunc(id)
+}
+
+// GetDevicesLastModified reports no modification time unless a test sets lastModifiedFunc,
+// so list tests that do not care about conditional requests need not stub it
+func (m *MockDeviceService) GetDevicesLastModified() (time.Time, error) {
+	if m.lastModifiedFunc == nil {
+		return time.Time{}, nil
+	}
+	return m.lastModifiedFunc()
+}
+
+func (m *MockDeviceService) StreamDevices(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	return m.streamFunc(ctx, opts, fn)
+}
+
+func (m *MockDeviceService) CreateDevice(device *models.DeviceCreate) (*models.Device, error) {
 	return m.createFunc(device)
 }
 
-func (m *MockDeviceService) UpdateDevice(id int64, device *models.DeviceUpdate) error {
-	return m.updateFunc(id, device)
+func (m *MockDeviceService) ImportDevices(devices []*models.DeviceCreate) error {
+	return m.importFunc(devices)
+}
+
+// UpdateDevice reports the update as changing the device unless updateUnchanged is set
+func (m *MockDeviceService) UpdateDevice(id int64, device *models.DeviceUpdate) (bool, error) {
+	if err := m.updateFunc(id, device); err != nil {
+		return false, err
+	}
+	return !m.updateUnchanged, nil
+}
+
+// SetDeviceConnected defaults to what the service does without debouncing: an online update
+func (m *MockDeviceService) SetDeviceConnected(id int64, connected bool) error {
+	if m.connectedFunc == nil {
+		return m.updateFunc(id, &models.DeviceUpdate{IsOnline: &connected})
+	}
+	return m.connectedFunc(id, connected)
+}
+
+func (m *MockDeviceService) DeleteDevice(id int64, children models.ChildPolicy) error {
+	return m.deleteFunc(id, children)
+}
+
+func (m *MockDeviceService) ResetDevice(id int64) (*models.Device, error) {
+	return m.resetFunc(id)
 }
 
-func (m *MockDeviceService) DeleteDevice(id int64) error {
-	return m.deleteFunc(id)
+func (m *MockDeviceService) GetChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	return m.childrenFunc(id, limit, offset)
+}
+
+func (m *MockDeviceService) GetRecentDevices(limit int) ([]*models.Device, error) {
+	return m.recentFunc(limit)
 }
 
 func (m *MockDeviceService) TriggerAlarm(id int64, alarm *models.AlarmRequest) error {
 	return m.triggerAlarmFunc(id, alarm)
 }
 
+// TriggerAlarmIdempotent answers with idempotentFunc when set, else records the alarm with
+// triggerAlarmFunc as a new one
+func (m *MockDeviceService) TriggerAlarmIdempotent(id int64, alarm *models.AlarmRequest) (*models.AlarmRecord, error) {
+	if m.idempotentFunc != nil {
+		return m.idempotentFunc(id, alarm)
+	}
+	return nil, m.triggerAlarmFunc(id, alarm)
+}
+
+func (m *MockDeviceService) TriggerAlarmByType(deviceType models.DeviceType, alarm *models.AlarmRequest) (*models.TypeAlarmResult, error) {
+	return m.typeAlarmFunc(deviceType, alarm)
+}
+
+func (m *MockDeviceService) ClearAlarm(id int64) error {
+	return m.clearAlarmFunc(id)
+}
+
+func (m *MockDeviceService) SetMaintenance(id int64, req *models.MaintenanceRequest) error {
+	return m.maintenanceFunc(id, req)
+}
+
+func (m *MockDeviceService) SetArchived(id int64, archived bool) error {
+	return m.archiveFunc(id, archived)
+}
+
+func (m *MockDeviceService) GetOwners(opts *models.OwnerListOptions) ([]string, error) {
+	return m.ownersFunc(opts)
+}
+
+func (m *MockDeviceService) CheckOwner(owner string) (bool, []string, error) {
+	return m.checkOwnerFunc(owner)
+}
+
+func (m *MockDeviceService) RenameOwner(req *models.OwnerRename, merge bool) (*models.OwnerRenameResult, error) {
+	return m.renameOwnerFunc(req, merge)
+}
+
+func (m *MockDeviceService) StartFirmwareUpdate(id int64, req *models.FirmwareUpdateRequest) error {
+	return m.firmwareFunc(id, req)
+}
+
+func (m *MockDeviceService) ReportFirmwareUpdate(id int64, report *models.FirmwareReport) error {
+	return m.firmwareReportFunc(id, report)
+}
+
+func (m *MockDeviceService) GetActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	return m.activeAlarmsFunc(filter)
+}
+
+func (m *MockDeviceService) GetAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	return m.historyFunc(filter)
+}
+
+func (m *MockDeviceService) ExportAlarmHistory(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	return m.exportFunc(ctx, filter, fn)
+}
+
+func (m *MockDeviceService) CountOnlineDevices() (*models.DeviceCounts, error) {
+	return m.onlineCountFunc()
+}
+
+func (m *MockDeviceService) CountAlarms(filter *models.AlarmHistoryFilter) (int, error) {
+	return m.alarmCountsFunc(filter)
+}
+
+func (m *MockDeviceService) CountAlarmsByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	return m.alarmCodeCountsFunc(filter)
+}
+
+func (m *MockDeviceService) ListAlarmReasons() ([]*models.AlarmReason, error) {
+	return m.reasonsFunc()
+}
+
+func (m *MockDeviceService) SaveAlarmReason(reason *models.AlarmReason) (*models.AlarmReason, bool, error) {
+	return m.saveReasonFunc(reason)
+}
+
+func (m *MockDeviceService) DeleteAlarmReason(code string) error {
+	return m.deleteReasonFunc(code)
+}
+
+func (m *MockDeviceService) GetDashboard() (*models.Dashboard, error) {
+	return m.dashboardFunc()
+}
+
+func (m *MockDeviceService) GetDeviceStateCounts() ([]*models.DeviceTypeCounts, error) {
+	return m.stateCountsFunc()
+}
+
+func (m *MockDeviceService) GetDeviceStats() (*models.DeviceStats, error) {
+	return m.statsFunc()
+}
+
+func (m *MockDeviceService) VacuumDatabase() (*models.VacuumResult, error) {
+	return m.vacuumFunc()
+}
+
+func (m *MockDeviceService) ReconnectDatabase() error {
+	return m.reconnectFunc()
+}
+
+func (m *MockDeviceService) DatabaseReconnecting() bool {
+	return m.reconnecting
+}
+
+// PingDatabase answers with pingFunc when set, else reports the database as up
+func (m *MockDeviceService) PingDatabase(ctx context.Context) error {
+	if m.pingFunc == nil {
+		return nil
+	}
+	return m.pingFunc(ctx)
+}
+
+func (m *MockDeviceService) GetRawDevice(id int64, primary bool) (*models.RawDeviceRow, error) {
+	return m.rawFunc(id, primary)
+}
+
+func (m *MockDeviceService) DiffDevices(_ context.Context, manifest *models.Manifest, apply bool) (*models.DeviceDiff, error) {
+	return m.diffFunc(manifest, apply)
+}
+
+func (m *MockDeviceService) AcknowledgeAlarm(id int64) error {
+	return m.ackAlarmFunc(id)
+}
+
+func (m *MockDeviceService) GetDeviceHealth(id int64) (*models.DeviceHealth, error) {
+	return m.healthFunc(id)
+}
+
 // TestHandler implements a minimal handler for testing
 type TestHandler struct {
 	deviceService DeviceServiceInterface
@@ -95,7 +344,7 @@ func (h *TestHandler) triggerDeviceAlarm(c *gin.Context) {
 	// Parse request body
 	var alarmRequest models.AlarmRequest
 	if bindErr := c.ShouldBindJSON(&alarmRequest); bindErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"bindError": bindErr.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": bindErr.Error()})
 		return
 	}
 
@@ -235,3 +484,1683 @@ func TestTriggerDeviceAlarm(t *testing.T) {
 		})
 	}
 }
+
+// MockIncidentService is a mock implementation of IncidentServiceInterface
+type MockIncidentService struct {
+	listFunc    func(opts *models.IncidentListOptions) ([]*models.Incident, error)
+	resolveFunc func(id int64) error
+}
+
+func (m *MockIncidentService) ListIncidents(opts *models.IncidentListOptions) ([]*models.Incident, error) {
+	return m.listFunc(opts)
+}
+
+func (m *MockIncidentService) ResolveIncident(id int64) error {
+	return m.resolveFunc(id)
+}
+
+// MockCommandService is a mock implementation of CommandServiceInterface
+type MockCommandService struct {
+	enqueueFunc   func(deviceID int64, req *models.CommandRequest) (*models.DeviceCommand, error)
+	pollFunc      func(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error)
+	pendingFunc   func(deviceID int64) ([]*models.DeviceCommand, error)
+	deliveredFunc func(deviceID, id int64) error
+	ackFunc       func(deviceID, id int64) error
+}
+
+func (m *MockCommandService) EnqueueCommand(deviceID int64, req *models.CommandRequest) (*models.DeviceCommand, error) {
+	return m.enqueueFunc(deviceID, req)
+}
+
+func (m *MockCommandService) PollCommands(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error) {
+	return m.pollFunc(deviceID, limit, offset)
+}
+
+func (m *MockCommandService) PendingCommands(deviceID int64) ([]*models.DeviceCommand, error) {
+	return m.pendingFunc(deviceID)
+}
+
+func (m *MockCommandService) MarkDelivered(deviceID, id int64) error {
+	return m.deliveredFunc(deviceID, id)
+}
+
+func (m *MockCommandService) AckCommand(deviceID, id int64) error {
+	return m.ackFunc(deviceID, id)
+}
+
+// MockChangeService is a mock implementation of ChangeServiceInterface
+type MockChangeService struct {
+	getChangesFunc func(since int64, limit int) (*models.ChangeFeed, error)
+}
+
+func (m *MockChangeService) GetChanges(since int64, limit int) (*models.ChangeFeed, error) {
+	return m.getChangesFunc(since, limit)
+}
+
+func TestTriggerDeviceAlarmActor(t *testing.T) {
+	var recorded string
+	mockSvc := &MockDeviceService{
+		triggerAlarmFunc: func(id int64, alarm *models.AlarmRequest) error {
+			recorded = alarm.TriggeredBy
+			return nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+	router := newTestServer(mockSvc, cfg)
+
+	tests := []struct {
+		name     string
+		token    string
+		body     string
+		expected string
+	}{
+		{"Anonymous keeps supplied", "", `{"reason":"Smoke","level":"INFO","triggered_by":"sensor-1"}`, "sensor-1"},
+		{"Anonymous without actor", "", `{"reason":"Smoke","level":"INFO"}`, ""},
+		{"Admin keeps supplied", "admin-secret", `{"reason":"Smoke","level":"INFO","triggered_by":"sensor-1"}`, "sensor-1"},
+		{"Admin defaults to identity", "admin-secret", `{"reason":"Smoke","level":"INFO"}`, adminActor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorded = "unset"
+			req, _ := http.NewRequest("POST", "/api/devices/1/alarm", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusNoContent {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusNoContent, recorder.Code, recorder.Body.String())
+			}
+			if recorded != tc.expected {
+				t.Errorf("Expected triggered_by %q, got %q", tc.expected, recorded)
+			}
+		})
+	}
+
+	// A non-admin identity wins over whatever the client supplied
+	if actor := resolveActor("sensor-gateway", false, "someone-else"); actor != "sensor-gateway" {
+		t.Errorf("Expected the authenticated identity to win, got %q", actor)
+	}
+}
+
+// newTestServer creates the real Handler wired to the mock service
+func newTestServer(mockSvc *MockDeviceService, cfg *config.Config) *gin.Engine {
+	return newTestServerWithIncidents(mockSvc, &MockIncidentService{}, cfg)
+}
+
+// newTestServerWithIncidents creates the real Handler wired to the mock services
+func newTestServerWithIncidents(mockSvc *MockDeviceService, incidentSvc *MockIncidentService, cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return New(mockSvc, incidentSvc, &MockCommandService{}, &MockChangeService{}, cfg).router
+}
+
+// newTestServerWithCommands creates the real Handler wired to the mock device and command services
+func newTestServerWithCommands(mockSvc *MockDeviceService, commandSvc *MockCommandService, cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return New(mockSvc, &MockIncidentService{}, commandSvc, &MockChangeService{}, cfg).router
+}
+
+// newTestServerWithChanges creates the real Handler wired to the mock change service
+func newTestServerWithChanges(changeSvc *MockChangeService, cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return New(&MockDeviceService{}, &MockIncidentService{}, &MockCommandService{}, changeSvc, cfg).router
+}
+
+func TestGetAllDevicesPagination(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedCode   int
+		expectedLimit  int
+		expectedOffset int
+	}{
+		{"Default page size", "", http.StatusOK, 100, 0},
+		{"Explicit limit and offset", "?limit=10&offset=20", http.StatusOK, 10, 20},
+		{"Limit capped at maximum", "?limit=100000", http.StatusOK, 1000, 0},
+		{"Non-numeric limit", "?limit=abc", http.StatusBadRequest, 0, 0},
+		{"Zero limit", "?limit=0", http.StatusBadRequest, 0, 0},
+		{"Negative offset", "?offset=-1", http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			mockSvc := &MockDeviceService{
+				listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+					gotOpts = opts
+					return []*models.Device{}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			// The default created_at sort fetches one extra device to know whether a next cursor is due
+			if gotOpts.Limit != tc.expectedLimit+1 {
+				t.Errorf("Expected limit %d, got %d", tc.expectedLimit+1, gotOpts.Limit)
+			}
+			if gotOpts.Offset != tc.expectedOffset {
+				t.Errorf("Expected offset %d, got %d", tc.expectedOffset, gotOpts.Offset)
+			}
+			// The page served is reported so clients can tell when their limit was clamped
+			if got := recorder.Header().Get("X-Page-Limit"); got != strconv.Itoa(tc.expectedLimit) {
+				t.Errorf("Expected X-Page-Limit %d, got %q", tc.expectedLimit, got)
+			}
+			if clamped := recorder.Header().Get("X-Page-Clamped") == "true"; clamped != (tc.name == "Limit capped at maximum") {
+				t.Errorf("Unexpected X-Page-Clamped %t", clamped)
+			}
+		})
+	}
+}
+
+func TestGetActiveAlarms(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedCode   int
+		expectedFilter models.ActiveAlarmFilter
+	}{
+		{"No filters", "", http.StatusOK, models.ActiveAlarmFilter{Limit: 100}},
+		{"Level and type filters", "?level=CRITICAL&device_type=SMOKE_DETECTOR", http.StatusOK,
+			models.ActiveAlarmFilter{Levels: []string{"CRITICAL"}, DeviceTypes: []models.DeviceType{models.DeviceTypeSmokeDetector}, Limit: 100}},
+		{"Repeated filters", "?level=CRITICAL&level=WARNING&level=&device_type=CAMERA&device_type=LOCK", http.StatusOK,
+			models.ActiveAlarmFilter{Levels: []string{"CRITICAL", "WARNING"}, DeviceTypes: []models.DeviceType{models.DeviceTypeCamera, models.DeviceTypeLock}, Limit: 100}},
+		{"Paged", "?limit=5&offset=10", http.StatusOK, models.ActiveAlarmFilter{Limit: 5, Offset: 10}},
+		{"Invalid limit", "?limit=0", http.StatusBadRequest, models.ActiveAlarmFilter{}},
+		{"Invalid level", "?level=LOW", http.StatusBadRequest, models.ActiveAlarmFilter{}},
+		{"One invalid level", "?level=CRITICAL&level=LOW", http.StatusBadRequest, models.ActiveAlarmFilter{}},
+		{"Invalid device type", "?device_type=TOASTER", http.StatusBadRequest, models.ActiveAlarmFilter{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotFilter *models.ActiveAlarmFilter
+			mockSvc := &MockDeviceService{
+				activeAlarmsFunc: func(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+					gotFilter = filter
+					return []*models.Device{}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/alarms/active"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode == http.StatusOK && !reflect.DeepEqual(*gotFilter, tc.expectedFilter) {
+				t.Errorf("Expected filter %+v, got %+v", tc.expectedFilter, *gotFilter)
+			}
+		})
+	}
+}
+
+func TestClearDeviceAlarm(t *testing.T) {
+	tests := []struct {
+		name         string
+		deviceID     string
+		clearErr     error
+		expectedCode int
+	}{
+		{"Cleared", "1", nil, http.StatusNoContent},
+		{"Invalid device ID", "abc", nil, http.StatusBadRequest},
+		{"Device not found", "99", fmt.Errorf("%w with ID: 99", models.ErrDeviceNotFound), http.StatusNotFound},
+		{"Service error", "1", errors.New("internal error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				clearAlarmFunc: func(id int64) error { return tc.clearErr },
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/devices/%s/alarm", tc.deviceID), nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestTriggerTypeAlarm(t *testing.T) {
+	tests := []struct {
+		name         string
+		deviceType   string
+		body         string
+		serviceErr   error
+		expectedCode int
+	}{
+		{"Triggered", "SMOKE_DETECTOR", `{"reason": "Fire", "level": "CRITICAL"}`, nil, http.StatusOK},
+		{"Unknown type", "TOASTER", `{"reason": "Fire", "level": "CRITICAL"}`, nil, http.StatusBadRequest},
+		{"Malformed body", "SMOKE_DETECTOR", `{"reason":`, nil, http.StatusBadRequest},
+		{"Missing reason", "SMOKE_DETECTOR", `{"level": "CRITICAL"}`, nil, http.StatusUnprocessableEntity},
+		{"Invalid level", "SMOKE_DETECTOR", `{"reason": "Fire", "level": "LOUD"}`, nil, http.StatusUnprocessableEntity},
+		{"Service error", "SMOKE_DETECTOR", `{"reason": "Fire", "level": "CRITICAL"}`, errors.New("internal error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			mockSvc := &MockDeviceService{
+				typeAlarmFunc: func(deviceType models.DeviceType, alarm *models.AlarmRequest) (*models.TypeAlarmResult, error) {
+					called = true
+					if tc.serviceErr != nil {
+						return nil, tc.serviceErr
+					}
+					return &models.TypeAlarmResult{DeviceType: deviceType, Triggered: 4, Suppressed: 1}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("POST", "/api/device-types/"+tc.deviceType+"/alarm", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			// Invalid requests are rejected before anything is triggered
+			if (tc.expectedCode == http.StatusBadRequest || tc.expectedCode == http.StatusUnprocessableEntity) && called {
+				t.Errorf("Expected the service not to be called for an invalid request")
+			}
+			if tc.expectedCode == http.StatusBadRequest && !strings.Contains(recorder.Body.String(), `"error":`) {
+				t.Errorf("Expected the failure under error, got %s", recorder.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var result models.TypeAlarmResult
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if result.DeviceType != models.DeviceTypeSmokeDetector || result.Triggered != 4 || result.Suppressed != 1 {
+				t.Errorf("Unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestSetDeviceMaintenance(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name           string
+		deviceID       string
+		body           string
+		serviceErr     error
+		expectedCode   int
+		expectedCalled bool
+	}{
+		{"Enable indefinitely", "1", `{"enabled": true}`, nil, http.StatusNoContent, true},
+		{"Enable until", "1", `{"enabled": true, "until": "` + future + `"}`, nil, http.StatusNoContent, true},
+		{"Disable", "1", `{"enabled": false}`, nil, http.StatusNoContent, true},
+		{"Missing enabled", "1", `{}`, nil, http.StatusBadRequest, false},
+		{"Unknown field", "1", `{"enabled": true, "reason": "repairs"}`, nil, http.StatusBadRequest, false},
+		{"Until in the past", "1", `{"enabled": true, "until": "` + past + `"}`, nil, http.StatusUnprocessableEntity, false},
+		{"Until while disabling", "1", `{"enabled": false, "until": "` + future + `"}`, nil, http.StatusUnprocessableEntity, false},
+		{"Invalid device ID", "abc", `{"enabled": true}`, nil, http.StatusBadRequest, false},
+		{"Device not found", "99", `{"enabled": true}`, fmt.Errorf("%w with ID: 99", models.ErrDeviceNotFound), http.StatusNotFound, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			mockSvc := &MockDeviceService{
+				maintenanceFunc: func(id int64, req *models.MaintenanceRequest) error {
+					called = true
+					return tc.serviceErr
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/api/devices/%s/maintenance", tc.deviceID), bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if called != tc.expectedCalled {
+				t.Errorf("Expected SetMaintenance called = %v, got %v", tc.expectedCalled, called)
+			}
+		})
+	}
+}
+
+func TestGetIncidents(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedCode   int
+		expectedStatus []string
+	}{
+		{"All incidents", "", http.StatusOK, nil},
+		{"Open incidents", "?status=open", http.StatusOK, []string{models.IncidentStatusOpen}},
+		{"Either status", "?status=open&status=resolved", http.StatusOK, []string{models.IncidentStatusOpen, models.IncidentStatusResolved}},
+		{"Invalid status", "?status=closed", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.IncidentListOptions
+			incidentSvc := &MockIncidentService{
+				listFunc: func(opts *models.IncidentListOptions) ([]*models.Incident, error) {
+					gotOpts = opts
+					return []*models.Incident{}, nil
+				},
+			}
+			router := newTestServerWithIncidents(&MockDeviceService{}, incidentSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/incidents"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode == http.StatusOK && !reflect.DeepEqual(gotOpts.Statuses, tc.expectedStatus) {
+				t.Errorf("Expected status filter %q, got %q", tc.expectedStatus, gotOpts.Statuses)
+			}
+		})
+	}
+}
+
+func TestResolveIncident(t *testing.T) {
+	tests := []struct {
+		name         string
+		incidentID   string
+		resolveErr   error
+		expectedCode int
+	}{
+		{"Resolved", "1", nil, http.StatusNoContent},
+		{"Invalid incident ID", "abc", nil, http.StatusBadRequest},
+		{"Incident not found", "99", fmt.Errorf("%w with ID: 99", models.ErrIncidentNotFound), http.StatusNotFound},
+		{"Service error", "1", errors.New("internal error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			incidentSvc := &MockIncidentService{
+				resolveFunc: func(id int64) error { return tc.resolveErr },
+			}
+			router := newTestServerWithIncidents(&MockDeviceService{}, incidentSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/api/incidents/%s/resolve", tc.incidentID), nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestCreateDeviceReturnsDevice(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+			return testutil.NewDevice().WithID(7).WithName(device.Name).WithType(device.DeviceType).WithOwner(device.OwnedBy).Build(), nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	body := `{"name": "FrontDoor", "device_type": "CAMERA", "owned_by": "owner1"}`
+	req, _ := http.NewRequest("POST", "/api/devices", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, recorder.Code)
+	}
+
+	var device models.Device
+	if err := json.Unmarshal(recorder.Body.Bytes(), &device); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if device.ID != 7 || device.Name != "FrontDoor" || device.DeviceType != models.DeviceTypeCamera {
+		t.Errorf("Unexpected device in response: %+v", device)
+	}
+}
+
+func TestCreateDeviceRejectsDisallowedType(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+			t.Fatal("Expected a disallowed type not to be created")
+			return nil, nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AllowedDeviceTypes = []models.DeviceType{models.DeviceTypeLock}
+	router := newTestServer(mockSvc, cfg)
+
+	body := `{"name": "FrontDoor", "device_type": "CAMERA", "owned_by": "owner1"}`
+	req, _ := http.NewRequest("POST", "/api/devices", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusUnprocessableEntity, recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), "must be one of: LOCK") {
+		t.Errorf("Expected the error to list the allowed types, got %s", recorder.Body.String())
+	}
+}
+
+func TestDeprecatedDeviceTypes(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+			t.Fatal("Expected a deprecated type not to be created")
+			return nil, nil
+		},
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			return testutil.NewDevice().WithID(id).WithType(models.DeviceTypeUnknown).Build(), nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.DeprecatedDeviceTypes = []models.DeviceType{models.DeviceTypeUnknown}
+	router := newTestServer(mockSvc, cfg)
+
+	req, _ := http.NewRequest("GET", "/api/device-types", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	var types []models.DeviceTypeInfo
+	if err := json.Unmarshal(recorder.Body.Bytes(), &types); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	for _, info := range types {
+		if info.Deprecated != (info.ID == string(models.DeviceTypeUnknown)) {
+			t.Errorf("Expected only UNKNOWN to be deprecated, got %+v", info)
+		}
+	}
+
+	body := `{"name": "Mystery", "device_type": "UNKNOWN", "owned_by": "owner1"}`
+	req, _ = http.NewRequest("POST", "/api/devices", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusUnprocessableEntity, recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), "UNKNOWN is deprecated") {
+		t.Errorf("Expected a deprecation error, got %s", recorder.Body.String())
+	}
+
+	// Existing devices of the type are still served
+	req, _ = http.NewRequest("GET", "/api/devices/3", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestCreateDeviceNormalizesName(t *testing.T) {
+	tests := []struct {
+		name         string
+		normalize    bool
+		expectedCode int
+	}{
+		{"Normalized before validation", true, http.StatusCreated},
+		{"Validated as given", false, http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var created string
+			mockSvc := &MockDeviceService{
+				createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+					created = device.Name
+					return testutil.NewDevice().WithID(7).WithName(device.Name).Build(), nil
+				},
+			}
+			cfg := testutil.NewConfig()
+			cfg.NormalizeDeviceNames = tc.normalize
+			router := newTestServer(mockSvc, cfg)
+
+			body := `{"name": "  FrontDoor ", "device_type": "CAMERA", "owned_by": "owner1"}`
+			req, _ := http.NewRequest("POST", "/api/devices", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.normalize && created != "FrontDoor" {
+				t.Errorf("Expected the trimmed name to be created, got %q", created)
+			}
+		})
+	}
+}
+
+func TestValidationErrorStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		expectedCode int
+	}{
+		{"Default", 0, `{"name": "Bad name!", "device_type": "CAMERA", "owned_by": "owner1"}`, http.StatusUnprocessableEntity},
+		{"Configured 400", http.StatusBadRequest, `{"name": "Bad name!", "device_type": "CAMERA", "owned_by": "owner1"}`, http.StatusBadRequest},
+		{"Malformed JSON", http.StatusUnprocessableEntity, `{"name":`, http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testutil.NewConfig()
+			cfg.ValidationErrorStatus = tc.status
+			router := newTestServer(&MockDeviceService{}, cfg)
+
+			req, _ := http.NewRequest("POST", "/api/devices", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidationWarnings(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+			return testutil.NewDevice().WithName(device.Name).WithType(device.DeviceType).WithOwner(device.OwnedBy).Build(), nil
+		},
+		updateFunc:       func(id int64, device *models.DeviceUpdate) error { return nil },
+		triggerAlarmFunc: func(id int64, alarm *models.AlarmRequest) error { return nil },
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		strict       bool
+		expectedCode int
+		expectedBody string
+	}{
+		{"Create with email owner", "POST", "/api/devices", `{"name": "Cam1", "device_type": "CAMERA", "owned_by": "jane@example.com"}`, false, http.StatusCreated, `"warnings":{"owned_by":`},
+		{"Create with email owner, strict", "POST", "/api/devices", `{"name": "Cam1", "device_type": "CAMERA", "owned_by": "jane@example.com"}`, true, http.StatusUnprocessableEntity, `"errors":{"owned_by":`},
+		{"Create without warnings", "POST", "/api/devices", `{"name": "Cam1", "device_type": "CAMERA", "owned_by": "jane"}`, false, http.StatusCreated, `"name":"Cam1"`},
+		{"Update with blank description", "PUT", "/api/devices/1", `{"description": "  "}`, false, http.StatusOK, `{"warnings":{"description":`},
+		{"Update without warnings", "PUT", "/api/devices/1", `{"description": "Porch"}`, false, http.StatusNoContent, ``},
+		{"Critical alarm with short reason", "POST", "/api/devices/1/alarm", `{"level": "CRITICAL", "reason": "x"}`, false, http.StatusOK, `{"warnings":{"reason":`},
+		{"Critical alarm with short reason, strict", "POST", "/api/devices/1/alarm", `{"level": "CRITICAL", "reason": "x"}`, true, http.StatusUnprocessableEntity, `"errors":{"reason":`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.strict {
+				req.Header.Set("X-Validation-Strict", "true")
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body containing %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+			if !strings.Contains(tc.expectedBody, "warnings") && strings.Contains(recorder.Body.String(), "warnings") {
+				t.Errorf("Expected no warnings, got %s", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetAlarmTTLs(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.AlarmTTLInfo = time.Hour
+	cfg.AlarmTTLWarning = 24 * time.Hour
+	cfg.AlarmTTLCritical = 0
+	cfg.AlarmLevels = []string{"INFO", "WARNING", "CRITICAL", "EMERGENCY"}
+	cfg.AlarmTTLLevels = map[string]time.Duration{"EMERGENCY": 2 * time.Hour}
+	router := newTestServer(&MockDeviceService{}, cfg)
+
+	req, _ := http.NewRequest("GET", "/api/settings/alarm-ttls", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var body struct {
+		AlarmTTLs map[string]string `json:"alarm_ttls"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+
+	expected := map[string]string{"INFO": "1h0m0s", "WARNING": "24h0m0s", "CRITICAL": "never", "EMERGENCY": "2h0m0s"}
+	for level, ttl := range expected {
+		if body.AlarmTTLs[level] != ttl {
+			t.Errorf("Expected %s TTL %q, got %q", level, ttl, body.AlarmTTLs[level])
+		}
+	}
+}
+
+func TestGetAlarmPresence(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.AlarmPresenceByType = models.AlarmPresencePolicy{models.DeviceTypeMotionSensor: models.AlarmPresenceOnline}
+	router := newTestServer(&MockDeviceService{}, cfg)
+
+	req, _ := http.NewRequest("GET", "/api/settings/alarm-presence", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var body struct {
+		AlarmPresence map[string]string `json:"alarm_presence"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+
+	if len(body.AlarmPresence) != len(models.GetAllDeviceTypes()) {
+		t.Errorf("Expected a mode for every device type, got %v", body.AlarmPresence)
+	}
+	if body.AlarmPresence["MOTION_SENSOR"] != "online" || body.AlarmPresence["CAMERA"] != "off" {
+		t.Errorf("Expected MOTION_SENSOR online and CAMERA off, got %v", body.AlarmPresence)
+	}
+}
+
+func TestGetAllDevicesSorting(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedSort  string
+		expectedOrder string
+	}{
+		{"Configured default", "", http.StatusOK, "created_at", models.SortDesc},
+		{"Explicit field defaults to ascending", "?sort_by=name", http.StatusOK, "name", models.SortAsc},
+		{"Explicit field and order", "?sort_by=updated_at&order=desc", http.StatusOK, "updated_at", models.SortDesc},
+		{"Order only", "?order=asc", http.StatusOK, "created_at", models.SortAsc},
+		{"Unknown field", "?sort_by=password", http.StatusBadRequest, "", ""},
+		{"Invalid order", "?sort_by=name&order=sideways", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			mockSvc := &MockDeviceService{
+				listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+					gotOpts = opts
+					return []*models.Device{}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if gotOpts.SortBy != tc.expectedSort || gotOpts.SortOrder != tc.expectedOrder {
+				t.Errorf("Expected sort %s %s, got %s %s", tc.expectedSort, tc.expectedOrder, gotOpts.SortBy, gotOpts.SortOrder)
+			}
+		})
+	}
+}
+
+func TestGetAllDevicesMaintenanceFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expected     *bool
+	}{
+		{"No filter", "", http.StatusOK, nil},
+		{"In maintenance", "?maintenance=true", http.StatusOK, boolPtr(true)},
+		{"Not in maintenance", "?maintenance=false", http.StatusOK, boolPtr(false)},
+		{"Invalid value", "?maintenance=maybe", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			mockSvc := &MockDeviceService{
+				listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+					gotOpts = opts
+					return []*models.Device{}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if (gotOpts.Maintenance == nil) != (tc.expected == nil) ||
+				(tc.expected != nil && *gotOpts.Maintenance != *tc.expected) {
+				t.Errorf("Unexpected maintenance filter: got %v, expected %v", gotOpts.Maintenance, tc.expected)
+			}
+		})
+	}
+}
+
+func TestGetAllDevicesNameSearch(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedNames []string
+		expectedFuzzy bool
+	}{
+		{"Exact by default", "?name=kitchen", http.StatusOK, []string{"kitchen"}, false},
+		{"Repeated names", "?name=kitchen&name=hall", http.StatusOK, []string{"kitchen", "hall"}, false},
+		{"Fuzzy", "?name=kitchn&fuzzy=true", http.StatusOK, []string{"kitchn"}, true},
+		{"Fuzzy without name", "?fuzzy=true", http.StatusBadRequest, nil, false},
+		{"Fuzzy with empty name", "?name=&fuzzy=true", http.StatusBadRequest, nil, false},
+		{"Fuzzy stream", "?name=kitchn&fuzzy=true&stream=true", http.StatusBadRequest, nil, false},
+		{"Invalid fuzzy", "?name=kitchen&fuzzy=maybe", http.StatusBadRequest, nil, false},
+		{"Conflicting fuzzy", "?name=kitchen&fuzzy=true&fuzzy=false", http.StatusBadRequest, nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			gotFuzzy := false
+			mockSvc := &MockDeviceService{
+				listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+					gotOpts = opts
+					return []*models.Device{}, nil
+				},
+				fuzzyFunc: func(ctx context.Context, opts *models.DeviceListOptions) ([]*models.Device, error) {
+					gotOpts, gotFuzzy = opts, true
+					return []*models.Device{}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/devices"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(gotOpts.Names, tc.expectedNames) || gotFuzzy != tc.expectedFuzzy {
+				t.Errorf("Expected names %q with fuzzy %t, got %q with fuzzy %t", tc.expectedNames, tc.expectedFuzzy, gotOpts.Names, gotFuzzy)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestGetDeviceAlarms(t *testing.T) {
+	after := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Expected limits are one more than the page size, fetched to learn whether there is a next page
+	tests := []struct {
+		name           string
+		path           string
+		serviceErr     error
+		expectedCode   int
+		expectedFilter models.AlarmHistoryFilter
+	}{
+		{"No filters", "/api/devices/1/alarms", nil, http.StatusOK,
+			models.AlarmHistoryFilter{DeviceID: 1, Limit: 101}},
+		{"All filters", "/api/devices/1/alarms?level=WARNING&after=2024-05-01T00:00:00Z&before=2024-06-01T00:00:00Z&limit=10&offset=20", nil, http.StatusOK,
+			models.AlarmHistoryFilter{DeviceID: 1, Levels: []string{"WARNING"}, After: after, Before: before, Limit: 11, Offset: 20}},
+		{"Repeated level", "/api/devices/1/alarms?level=WARNING&level=INFO&level=WARNING", nil, http.StatusOK,
+			models.AlarmHistoryFilter{DeviceID: 1, Levels: []string{"WARNING", "INFO"}, Limit: 101}},
+		{"Repeated after", "/api/devices/1/alarms?after=2024-05-01T00:00:00Z&after=2024-05-02T00:00:00Z", nil, http.StatusBadRequest, models.AlarmHistoryFilter{}},
+		{"Limit capped", "/api/devices/1/alarms?limit=5000", nil, http.StatusOK,
+			models.AlarmHistoryFilter{DeviceID: 1, Limit: 1001}},
+		{"Invalid level", "/api/devices/1/alarms?level=LOUD", nil, http.StatusBadRequest, models.AlarmHistoryFilter{}},
+		{"Invalid after", "/api/devices/1/alarms?after=yesterday", nil, http.StatusBadRequest, models.AlarmHistoryFilter{}},
+		{"Empty range", "/api/devices/1/alarms?after=2024-06-01T00:00:00Z&before=2024-05-01T00:00:00Z", nil, http.StatusBadRequest, models.AlarmHistoryFilter{}},
+		{"Device not found", "/api/devices/99/alarms", fmt.Errorf("%w with ID: 99", models.ErrDeviceNotFound), http.StatusNotFound, models.AlarmHistoryFilter{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotFilter *models.AlarmHistoryFilter
+			mockSvc := &MockDeviceService{
+				historyFunc: func(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+					gotFilter = filter
+					return []*models.AlarmRecord{}, tc.serviceErr
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedCode == http.StatusOK && !reflect.DeepEqual(*gotFilter, tc.expectedFilter) {
+				t.Errorf("Expected filter %+v, got %+v", tc.expectedFilter, *gotFilter)
+			}
+		})
+	}
+}
+
+func TestGetDashboard(t *testing.T) {
+	tests := []struct {
+		name           string
+		dashboard      *models.Dashboard
+		err            error
+		expectedStatus int
+	}{
+		{
+			name: "Success",
+			dashboard: &models.Dashboard{
+				TotalDevices:  2,
+				DevicesByType: map[models.DeviceType]int{models.DeviceTypeCamera: 2},
+				Online:        1,
+				Offline:       1,
+				RecentAlarms:  []*models.AlarmRecord{{ID: 1, DeviceID: 1, Level: models.AlarmLevelInfo}},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Service error",
+			err:            errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				dashboardFunc: func() (*models.Dashboard, error) { return tc.dashboard, tc.err },
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", "/api/dashboard", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if tc.dashboard == nil {
+				return
+			}
+
+			var response models.Dashboard
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.TotalDevices != 2 || response.DevicesByType[models.DeviceTypeCamera] != 2 || len(response.RecentAlarms) != 1 {
+				t.Errorf("Unexpected dashboard: %+v", response)
+			}
+		})
+	}
+}
+
+func TestUpdateDevice(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		updateErr      error
+		expectedStatus int
+		expectedBody   string
+		expectUpdate   bool
+	}{
+		{"Success", `{"name": "Kitchen"}`, nil, http.StatusNoContent, "", true},
+		{"Empty object", `{}`, nil, http.StatusBadRequest, "no fields to update", false},
+		{"Only nulls", `{"name": null, "is_online": null}`, nil, http.StatusBadRequest, "no fields to update", false},
+		{"Invalid name", `{"name": "Bad name!"}`, nil, http.StatusUnprocessableEntity, `"name"`, false},
+		{"Malformed JSON", `{"name":`, nil, http.StatusBadRequest, "error", false},
+		{"Not found", `{"is_online": true}`, fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, 1), http.StatusNotFound, "device not found", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := false
+			mockSvc := &MockDeviceService{
+				updateFunc: func(id int64, device *models.DeviceUpdate) error {
+					updated = true
+					return tc.updateErr
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("PUT", "/api/devices/1", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tc.expectedBody, w.Body.String())
+			}
+			if updated != tc.expectUpdate {
+				t.Errorf("Expected UpdateDevice called = %v, got %v", tc.expectUpdate, updated)
+			}
+		})
+	}
+}
+
+func TestUpdateDeviceNoChange(t *testing.T) {
+	for _, unchanged := range []bool{false, true} {
+		mockSvc := &MockDeviceService{
+			updateFunc:      func(id int64, device *models.DeviceUpdate) error { return nil },
+			updateUnchanged: unchanged,
+		}
+		router := newTestServer(mockSvc, testutil.NewConfig())
+
+		req, _ := http.NewRequest("PUT", "/api/devices/1", bytes.NewBufferString(`{"name": "Kitchen"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Unchanged %t: expected status code %d, got %d", unchanged, http.StatusNoContent, w.Code)
+		}
+		if header := w.Header().Get(noChangeHeader); (header == "true") != unchanged {
+			t.Errorf("Unchanged %t: got %s %q", unchanged, noChangeHeader, header)
+		}
+	}
+}
+
+func TestTrailingSlashRedirects(t *testing.T) {
+	h := New(&MockDeviceService{}, &MockIncidentService{}, &MockCommandService{}, &MockChangeService{}, testutil.NewConfig())
+
+	for _, route := range h.router.Routes() {
+		if strings.HasSuffix(route.Path, "/") {
+			t.Errorf("%s %s is registered with a trailing slash", route.Method, route.Path)
+			continue
+		}
+
+		expectedCode := http.StatusTemporaryRedirect
+		if route.Method == http.MethodGet {
+			expectedCode = http.StatusMovedPermanently
+		}
+		path := strings.NewReplacer(":id", "1", ":command_id", "2", ":type", "LOCK").Replace(route.Path)
+
+		req, _ := http.NewRequest(route.Method, path+"/", nil)
+		recorder := httptest.NewRecorder()
+		h.router.ServeHTTP(recorder, req)
+
+		if recorder.Code != expectedCode || recorder.Header().Get("Location") != path {
+			t.Errorf("%s %s/: expected %d to %s, got %d to %q", route.Method, path, expectedCode, path,
+				recorder.Code, recorder.Header().Get("Location"))
+		}
+	}
+}
+
+func TestWithAlarmCount(t *testing.T) {
+	count := int64(3)
+	var listOpts *models.DeviceListOptions
+	mockSvc := &MockDeviceService{
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			return testutil.NewDevice().WithID(id).Build(), nil
+		},
+		alarmCountFunc: func(id int64) (*models.Device, error) {
+			return testutil.NewDevice().WithID(id).WithAlarmCount(count).Build(), nil
+		},
+		listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+			listOpts = opts
+			return []*models.Device{}, nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectCount  bool
+	}{
+		{"Device with count", "/api/devices/1?with_alarm_count=true", http.StatusOK, true},
+		{"Device without count", "/api/devices/1", http.StatusOK, false},
+		{"Invalid flag", "/api/devices/1?with_alarm_count=maybe", http.StatusBadRequest, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if got := strings.Contains(recorder.Body.String(), `"alarm_count":3`); got != tc.expectCount {
+				t.Errorf("Expected alarm_count present %v, got body %s", tc.expectCount, recorder.Body.String())
+			}
+		})
+	}
+
+	for query, expected := range map[string]bool{"?with_alarm_count=true": true, "": false} {
+		req, _ := http.NewRequest("GET", "/api/devices"+query, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		if listOpts.WithAlarmCount != expected {
+			t.Errorf("%q: expected WithAlarmCount %v", query, expected)
+		}
+	}
+}
+
+func TestAcknowledgeDeviceAlarm(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+	}{
+		{"Acknowledged", "/api/devices/1/alarm/ack", http.StatusNoContent},
+		{"No active alarm", "/api/devices/2/alarm/ack", http.StatusConflict},
+		{"Unknown device", "/api/devices/3/alarm/ack", http.StatusNotFound},
+		{"Invalid ID", "/api/devices/abc/alarm/ack", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				ackAlarmFunc: func(id int64) error {
+					switch id {
+					case 1:
+						return nil
+					case 2:
+						return models.ErrNoActiveAlarm
+					}
+					return fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("POST", tc.path, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetDeviceStats(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		statsFunc: func() (*models.DeviceStats, error) {
+			return &models.DeviceStats{Total: 5, Online: 4, Offline: 1, AlarmActive: 2}, nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/api/devices/stats", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var stats models.DeviceStats
+	if err := json.Unmarshal(recorder.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if stats.Total != 5 || stats.Offline != 1 || stats.AlarmActive != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestVacuumDatabase(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		vacuumFunc: func() (*models.VacuumResult, error) {
+			return &models.VacuumResult{Vacuumed: true, SizeBefore: 8192, SizeAfter: 4096}, nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+	router := newTestServer(mockSvc, cfg)
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedCode int
+	}{
+		{"No token", "", http.StatusUnauthorized},
+		{"Wrong token", "guess", http.StatusUnauthorized},
+		{"Admin", "admin-secret", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/admin/vacuum", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode == http.StatusOK && !strings.Contains(recorder.Body.String(), `"size_after_bytes":4096`) {
+				t.Errorf("Expected the sizes in the body, got %s", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestReconnectDatabase(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+
+	tests := []struct {
+		name         string
+		token        string
+		err          error
+		expectedCode int
+	}{
+		{"No token", "", nil, http.StatusUnauthorized},
+		{"Reconnected", "admin-secret", nil, http.StatusOK},
+		{"Not supported", "admin-secret", models.ErrReconnectUnsupported, http.StatusNotImplemented},
+		{"File missing", "admin-secret", errors.New("database file data.db is missing"), http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newTestServer(&MockDeviceService{reconnectFunc: func() error { return tc.err }}, cfg)
+
+			req, _ := http.NewRequest("POST", "/api/admin/db/reconnect", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedCode, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestHealthCheckReconnecting(t *testing.T) {
+	router := newTestServer(&MockDeviceService{reconnecting: true}, testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/health", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), `"status":"reconnecting"`) {
+		t.Errorf("Expected 503 while reconnecting, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		ping         func(ctx context.Context) error
+		expectedCode int
+		expectedBody string
+	}{
+		{"Healthy", nil, http.StatusOK, `"database":"connected"`},
+		{"Database error", func(context.Context) error { return errors.New("disk I/O error") }, http.StatusServiceUnavailable, "disk I/O error"},
+		{"Database stuck", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, http.StatusServiceUnavailable, `"database":"timeout"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testutil.NewConfig()
+			cfg.HealthCheckTimeout = 20 * time.Millisecond
+			router := newTestServer(&MockDeviceService{pingFunc: tc.ping}, cfg)
+
+			req, _ := http.NewRequest("GET", "/health", nil)
+			recorder := httptest.NewRecorder()
+			start := time.Now()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode || !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected %d with %s, got %d: %s", tc.expectedCode, tc.expectedBody, recorder.Code, recorder.Body.String())
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected the check to answer promptly, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestCreateDeviceStrictOwners(t *testing.T) {
+	var checked bool
+	mockSvc := &MockDeviceService{
+		checkOwnerFunc: func(owner string) (bool, []string, error) {
+			checked = true
+			return false, nil, nil
+		},
+		createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+			return testutil.NewDevice().WithOwner(device.OwnedBy).Build(), nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		strict       bool
+		token        string
+		expectedCode int
+		expectCheck  bool
+	}{
+		{"Not strict", false, "", http.StatusCreated, false},
+		{"Unknown owner", true, "", http.StatusUnprocessableEntity, true},
+		{"Admin introduces owner", true, "admin-secret", http.StatusCreated, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checked = false
+			cfg := testutil.NewConfig()
+			cfg.StrictOwners = tc.strict
+			cfg.AdminToken = "admin-secret"
+			router := newTestServer(mockSvc, cfg)
+
+			req, _ := http.NewRequest("POST", "/api/devices", strings.NewReader(`{"name":"Porch","device_type":"CAMERA","owned_by":"zed"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if checked != tc.expectCheck {
+				t.Errorf("Expected the owner checked: %t, got %t", tc.expectCheck, checked)
+			}
+		})
+	}
+}
+
+func TestRenameOwner(t *testing.T) {
+	var merged bool
+	mockSvc := &MockDeviceService{
+		renameOwnerFunc: func(req *models.OwnerRename, merge bool) (*models.OwnerRenameResult, error) {
+			merged = merge
+			switch {
+			case req.From == "nobody":
+				return nil, models.ErrOwnerNotFound
+			case req.To == "bob" && !merge:
+				return nil, models.ErrOwnerExists
+			}
+			return &models.OwnerRenameResult{From: req.From, To: req.To, Rows: map[string]int64{"devices": 2}}, nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+	router := newTestServer(mockSvc, cfg)
+
+	tests := []struct {
+		name         string
+		token        string
+		query        string
+		body         string
+		expectedCode int
+		expectMerge  bool
+	}{
+		{"No token", "", "", `{"from":"alice","to":"alicia"}`, http.StatusUnauthorized, false},
+		{"Renamed", "admin-secret", "", `{"from":"alice","to":"alicia"}`, http.StatusOK, false},
+		{"Missing to", "admin-secret", "", `{"from":"alice"}`, http.StatusBadRequest, false},
+		{"Unchanged", "admin-secret", "", `{"from":"alice","to":"alice"}`, http.StatusUnprocessableEntity, false},
+		{"Unknown owner", "admin-secret", "", `{"from":"nobody","to":"alicia"}`, http.StatusNotFound, false},
+		{"Existing owner", "admin-secret", "", `{"from":"alice","to":"bob"}`, http.StatusConflict, false},
+		{"Merged", "admin-secret", "?merge=true", `{"from":"alice","to":"bob"}`, http.StatusOK, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			merged = false
+			req, _ := http.NewRequest("POST", "/api/admin/owners/rename"+tc.query, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if merged != tc.expectMerge {
+				t.Errorf("Expected merge %t, got %t", tc.expectMerge, merged)
+			}
+			if tc.expectedCode == http.StatusOK && !strings.Contains(recorder.Body.String(), `"rows":{"devices":2}`) {
+				t.Errorf("Expected the row counts in the body, got %s", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetDeviceByName(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		getByNameFunc: func(owner, name string) (*models.Device, error) {
+			switch {
+			case owner == "alice" && name == "FrontDoor":
+				return testutil.NewDevice().WithID(3).WithName(name).WithOwner(owner).WithType(models.DeviceTypeLock).Build(), nil
+			case name == "Twin":
+				return nil, fmt.Errorf("%w owner %q and name %q", models.ErrAmbiguousDevice, owner, name)
+			}
+			return nil, nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{"Found", "?owner=alice&name=FrontDoor", http.StatusOK},
+		{"Not found", "?owner=bob&name=FrontDoor", http.StatusNotFound},
+		{"Ambiguous", "?owner=alice&name=Twin", http.StatusConflict},
+		{"Missing owner", "?name=FrontDoor", http.StatusBadRequest},
+		{"Missing name", "?owner=alice", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/devices/by-name"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedCode == http.StatusOK && !strings.Contains(recorder.Body.String(), `"id":3`) {
+				t.Errorf("Expected device 3, got %s", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestDiffDevices(t *testing.T) {
+	var applied bool
+	mockSvc := &MockDeviceService{
+		diffFunc: func(manifest *models.Manifest, apply bool) (*models.DeviceDiff, error) {
+			if manifest.Devices[0].Name == "Stale" {
+				return nil, fmt.Errorf("%w: device 1", models.ErrDeviceChanged)
+			}
+			applied = apply
+			return &models.DeviceDiff{Missing: manifest.Devices, Unexpected: []*models.Device{}, Changed: []*models.DeviceDrift{}, Applied: apply}, nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+	router := newTestServer(mockSvc, cfg)
+
+	tests := []struct {
+		name         string
+		query        string
+		token        string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Dry run", "", "", `{"devices": [{"name": "Porch", "device_type": "CAMERA", "owned_by": "alice"}]}`, http.StatusOK, `"applied":false`},
+		{"Apply as admin", "?apply=true", "admin-secret", `{"devices": [{"name": "Porch", "device_type": "CAMERA", "owned_by": "alice"}]}`, http.StatusOK, `"applied":true`},
+		{"Apply without admin", "?apply=true", "", `{"devices": [{"name": "Porch", "device_type": "CAMERA", "owned_by": "alice"}]}`, http.StatusUnauthorized, `admin token required`},
+		{"Empty", "", "", `{"devices": []}`, http.StatusUnprocessableEntity, `"devices":`},
+		{"Invalid entry", "", "", `{"devices": [{"name": "Porch", "device_type": "TOASTER", "owned_by": "alice"}]}`, http.StatusUnprocessableEntity, `"devices[0].device_type":`},
+		{"Duplicate entry", "", "", `{"devices": [{"name": "Porch", "device_type": "CAMERA", "owned_by": "alice"}, {"name": "Porch", "device_type": "LOCK", "owned_by": "alice"}]}`, http.StatusUnprocessableEntity, `"devices[1].name":"duplicates devices[0]`},
+		{"Changed concurrently", "?apply=true", "admin-secret", `{"devices": [{"name": "Stale", "device_type": "CAMERA", "owned_by": "alice"}]}`, http.StatusConflict, `device changed concurrently`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			applied = false
+			req, _ := http.NewRequest("POST", "/api/devices/diff"+tc.query, bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body containing %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+			if applied && tc.token == "" {
+				t.Error("Expected nothing applied without admin access")
+			}
+		})
+	}
+}
+
+func TestGetRawDevice(t *testing.T) {
+	var readPrimary bool
+	createdAt := "2024-05-01T12:00:00Z"
+	mockSvc := &MockDeviceService{
+		rawFunc: func(id int64, primary bool) (*models.RawDeviceRow, error) {
+			readPrimary = primary
+			if id != 1 {
+				return nil, nil
+			}
+			return &models.RawDeviceRow{ID: 1, Columns: map[string]*string{"created_at": &createdAt, "last_alarm_reason": nil}}, nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+	router := newTestServer(mockSvc, cfg)
+
+	tests := []struct {
+		name         string
+		path         string
+		token        string
+		expectedCode int
+		expectedBody string
+	}{
+		{"No token", "/api/admin/devices/1/raw", "", http.StatusUnauthorized, `admin token required`},
+		{"Found", "/api/admin/devices/1/raw", "admin-secret", http.StatusOK, `"last_alarm_reason":null`},
+		{"From primary", "/api/admin/devices/1/raw?primary=true", "admin-secret", http.StatusOK, `"created_at":"2024-05-01T12:00:00Z"`},
+		{"Not found", "/api/admin/devices/2/raw", "admin-secret", http.StatusNotFound, `device not found`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			readPrimary = false
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body containing %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+			if readPrimary != strings.Contains(tc.path, "primary=true") {
+				t.Errorf("Expected primary %t to be passed on", !readPrimary)
+			}
+		})
+	}
+}
+
+func TestAlarmLevels(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		triggerAlarmFunc: func(id int64, alarm *models.AlarmRequest) error { return nil },
+		activeAlarmsFunc: func(filter *models.ActiveAlarmFilter) ([]*models.Device, error) { return nil, nil },
+	}
+	custom := testutil.NewConfig()
+	custom.AlarmLevels = []string{"INFO", "WARNING", "CRITICAL", "EMERGENCY"}
+
+	tests := []struct {
+		name         string
+		cfg          *config.Config
+		method       string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Built-in levels", testutil.NewConfig(), "GET", "/api/alarm-levels", "", http.StatusOK, `{"id":"CRITICAL","display_name":"Critical","severity":3,"built_in":true}]`},
+		{"Added level listed", custom, "GET", "/api/alarm-levels", "", http.StatusOK, `{"id":"EMERGENCY","display_name":"Emergency","severity":4,"built_in":false}]`},
+		{"Added level accepted", custom, "POST", "/api/devices/1/alarm", `{"reason":"Fire","level":"EMERGENCY"}`, http.StatusNoContent, ``},
+		{"Unknown level rejected", testutil.NewConfig(), "POST", "/api/devices/1/alarm", `{"reason":"Fire","level":"EMERGENCY"}`, http.StatusUnprocessableEntity, `must be one of: INFO, WARNING, CRITICAL`},
+		{"Added level filters", custom, "GET", "/api/alarms/active?level=EMERGENCY", "", http.StatusOK, ``},
+		{"Unknown level filter rejected", testutil.NewConfig(), "GET", "/api/alarms/active?level=EMERGENCY", "", http.StatusBadRequest, `EMERGENCY`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newTestServer(mockSvc, tc.cfg)
+			req, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body containing %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	cfg := testutil.NewConfig()
+	cfg.AllowedDeviceTypes = []models.DeviceType{models.DeviceTypeLock, models.DeviceTypeCamera}
+	cfg.AlarmLevels = []string{"INFO", "WARNING", "CRITICAL", "EMERGENCY"}
+	router := newTestServer(&MockDeviceService{}, cfg)
+
+	req, _ := http.NewRequest("GET", "/api/validation-rules", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var rules validation.Rules
+	if err := json.Unmarshal(recorder.Body.Bytes(), &rules); err != nil {
+		t.Fatalf("Failed to decode rules: %v", err)
+	}
+	if rules.Name.Max != validation.MaxDeviceNameLength || rules.Owner.Max != validation.MaxOwnerLength || rules.Description.Max != validation.MaxDescriptionLength {
+		t.Errorf("Expected the validation limits, got %+v", rules)
+	}
+	if strings.Join(rules.DeviceTypes, ",") != "CAMERA,LOCK" {
+		t.Errorf("Expected only the allowed types, got %v", rules.DeviceTypes)
+	}
+	if strings.Join(rules.AlarmLevels, ",") != "INFO,WARNING,CRITICAL,EMERGENCY" {
+		t.Errorf("Expected the configured levels, got %v", rules.AlarmLevels)
+	}
+}
+
+func TestFirmwareUpdate(t *testing.T) {
+	var target string
+	mockSvc := &MockDeviceService{
+		firmwareFunc: func(id int64, req *models.FirmwareUpdateRequest) error {
+			if id != 1 {
+				return fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+			}
+			target = req.Version
+			return nil
+		},
+		firmwareReportFunc: func(id int64, report *models.FirmwareReport) error {
+			if id != 1 {
+				return fmt.Errorf("%w for device with ID: %d", models.ErrNoFirmwareUpdate, id)
+			}
+			return nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Update pushed", "/api/devices/1/firmware", `{"version":"1.4.0"}`, http.StatusNoContent, ``},
+		{"Invalid version", "/api/devices/1/firmware", `{"version":"latest"}`, http.StatusUnprocessableEntity, `"version"`},
+		{"Missing version", "/api/devices/1/firmware", `{}`, http.StatusBadRequest, `Version`},
+		{"Unknown update field", "/api/devices/1/firmware", `{"version":"1.4.0","force":true}`, http.StatusBadRequest, `unknown field \"force\"`},
+		{"Unknown device", "/api/devices/2/firmware", `{"version":"1.4.0"}`, http.StatusNotFound, `device not found`},
+		{"Update reported", "/api/devices/1/firmware/status", `{"status":"success"}`, http.StatusNoContent, ``},
+		{"Invalid status", "/api/devices/1/firmware/status", `{"status":"pending"}`, http.StatusUnprocessableEntity, `"status"`},
+		{"Unknown report field", "/api/devices/1/firmware/status", `{"status":"success","version":"1.4.0"}`, http.StatusBadRequest, `unknown field \"version\"`},
+		{"No pending update", "/api/devices/2/firmware/status", `{"status":"failed"}`, http.StatusConflict, `no firmware update is pending`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body containing %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+	if target != "1.4.0" {
+		t.Errorf("Expected target 1.4.0 to be passed on, got %q", target)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestGetDeviceHealth(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+	}{
+		{"Healthy device", "/api/devices/1/health", http.StatusOK},
+		{"Unknown device", "/api/devices/2/health", http.StatusNotFound},
+		{"Invalid ID", "/api/devices/abc/health", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				healthFunc: func(id int64) (*models.DeviceHealth, error) {
+					if id != 1 {
+						return nil, fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+					}
+					return &models.DeviceHealth{DeviceID: 1, Score: 76, IsOnline: true, RecentAlarms: 3}, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedCode == http.StatusOK && !strings.Contains(recorder.Body.String(), `"score":76`) {
+				t.Errorf("Expected the score in the body, got %s", recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+const (
+	// importChunkSize is how many valid records are written per transaction during an import
+	importChunkSize = 100
+	// maxImportLineBytes caps the length of a single record in an import body
+	maxImportLineBytes = 16 << 10
+)
+
+// errLineTooLong is reported for import records longer than maxImportLineBytes
+var errLineTooLong = fmt.Errorf("line exceeds %d bytes", maxImportLineBytes)
+
+// readImportLine reads the next newline-terminated line from r without its line ending.
+// A line longer than maxLen is consumed in full but only errLineTooLong is returned for it,
+// so a single oversized record does not stop the rest of the import. io.EOF is returned
+// once r is exhausted, alongside any final unterminated line.
+func readImportLine(r *bufio.Reader, maxLen int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(bytes.TrimRight(line, "\r\n")) > maxLen {
+				tooLong, line = true, nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLong && (err == nil || err == io.EOF) {
+			return nil, errLineTooLong
+		}
+		return bytes.TrimRight(line, "\r\n"), err
+	}
+}
+
+// deviceImporter accumulates validated records and writes them in chunks
+type deviceImporter struct {
+	h      *Handler
+	result *models.ImportResult
+	chunk  []*models.DeviceCreate
+	lines  []int
+}
+
+// reject records a failed line in the import summary
+func (imp *deviceImporter) reject(failure *models.ImportError) {
+	imp.result.Rejected++
+	imp.result.Errors = append(imp.result.Errors, failure)
+}
+
+// warn records the validation warnings of an accepted line, if it raised any
+func (imp *deviceImporter) warn(line int, warnings validation.ValidationErrors) {
+	if len(warnings) > 0 {
+		imp.result.Warnings = append(imp.result.Warnings, &models.ImportWarning{Line: line, Warnings: warnings})
+	}
+}
+
+// add queues a valid record, writing the chunk once it is full
+func (imp *deviceImporter) add(line int, device *models.DeviceCreate) {
+	imp.chunk = append(imp.chunk, device)
+	imp.lines = append(imp.lines, line)
+	if len(imp.chunk) >= importChunkSize {
+		imp.flush()
+	}
+}
+
+// flush writes the queued records in one transaction. If the write fails every record in the
+// chunk is rejected with the error, since none of them were stored.
+func (imp *deviceImporter) flush() {
+	if len(imp.chunk) == 0 {
+		return
+	}
+
+	if imp.result.DryRun {
+		imp.result.Created += len(imp.chunk)
+	} else if err := imp.h.deviceService.ImportDevices(imp.chunk); err != nil {
+		for _, line := range imp.lines {
+			imp.reject(&models.ImportError{Line: line, Error: err.Error()})
+		}
+	} else {
+		imp.result.Created += len(imp.chunk)
+	}
+
+	imp.chunk, imp.lines = nil, nil
+}
+
+// importDevices handles POST /api/devices/import. The body is newline-delimited JSON with one
+// DeviceCreate per line and is read incrementally. Invalid lines are reported and skipped;
+// blank lines are ignored. With ?dry_run=true records are validated but nothing is written.
+// Validation warnings are reported per line, and reject the line in strict mode.
+func (h *Handler) importDevices(c *gin.Context) {
+	dryRun, ok := parseBoolQuery(c, "dry_run", false)
+	if !ok {
+		return
+	}
+	strict := c.GetHeader(strictValidationHeader) == "true"
+	lang := requestLanguage(c)
+
+	imp := &deviceImporter{
+		h:      h,
+		result: &models.ImportResult{DryRun: dryRun, Errors: []*models.ImportError{}},
+	}
+
+	reader := bufio.NewReader(c.Request.Body)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := readImportLine(reader, maxImportLineBytes)
+		if err != nil && err != io.EOF && !errors.Is(err, errLineTooLong) {
+			// Chunks already written stay written, so report how far the import got
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reading request body: " + err.Error(), "result": imp.result})
+			return
+		}
+
+		if errors.Is(err, errLineTooLong) {
+			imp.result.Total++
+			imp.reject(&models.ImportError{Line: lineNumber, Error: err.Error()})
+			continue
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			imp.result.Total++
+			var device models.DeviceCreate
+			if jsonErr := decodeStrictJSON(line, &device, h.config.JSONMaxDepth); jsonErr != nil {
+				imp.reject(&models.ImportError{Line: lineNumber, Error: jsonErr.Error()})
+			} else {
+				device.Name = h.normalizeName(device.Name)
+				result := validation.ValidateDeviceCreate(&device, h.allowedTypes)
+				ownerErr := h.checkOwner(c, result, "owned_by", device.OwnedBy)
+				if strict {
+					result.Strict()
+				}
+				result.Localize(lang)
+				if ownerErr != nil {
+					imp.reject(&models.ImportError{Line: lineNumber, Error: ownerErr.Error()})
+				} else if !result.Valid() {
+					imp.reject(&models.ImportError{Line: lineNumber, Errors: result.Errors})
+				} else {
+					imp.warn(lineNumber, result.Warnings)
+					imp.add(lineNumber, &device)
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	imp.flush()
+
+	// A failed chunk is reported after lines rejected later in the body, so restore line order
+	sort.Slice(imp.result.Errors, func(i, j int) bool { return imp.result.Errors[i].Line < imp.result.Errors[j].Line })
+
+	c.JSON(http.StatusOK, imp.result)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// importBody builds an NDJSON body of n valid device records
+func importBody(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `{"name": "Sensor%d", "device_type": "SMOKE_DETECTOR", "owned_by": "owner1"}`+"\n", i)
+	}
+	return b.String()
+}
+
+func postImport(t *testing.T, mockSvc *MockDeviceService, query, body string) (*httptest.ResponseRecorder, models.ImportResult) {
+	t.Helper()
+
+	router := newTestServer(mockSvc, testutil.NewConfig())
+	req, _ := http.NewRequest("POST", "/api/devices/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", ndjsonContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var result models.ImportResult
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return w, result
+}
+
+func TestImportDevices(t *testing.T) {
+	t.Run("Valid and invalid lines", func(t *testing.T) {
+		var imported []string
+		mockSvc := &MockDeviceService{
+			importFunc: func(devices []*models.DeviceCreate) error {
+				for _, device := range devices {
+					imported = append(imported, device.Name)
+				}
+				return nil
+			},
+		}
+
+		body := strings.Join([]string{
+			`{"name": "Kitchen", "device_type": "SMOKE_DETECTOR", "owned_by": "owner1"}`,
+			``,
+			`{"name": "Bad name!", "device_type": "SMOKE_DETECTOR", "owned_by": "owner1"}`,
+			`not json`,
+			`{"name": "Hallway", "device_type": "SMOKE_DETECTOR", "owned_by": "owner1"}` + "\r",
+			`{"name": "` + strings.Repeat("x", maxImportLineBytes) + `"}`,
+			`{"name": "Garage", "device_type": "SMOKE_DETECTOR", "owned_by": "owner1"}`,
+		}, "\n")
+
+		w, result := postImport(t, mockSvc, "", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if result.Total != 6 || result.Created != 3 || result.Rejected != 3 || result.DryRun {
+			t.Errorf("Unexpected summary: %+v", result)
+		}
+		if strings.Join(imported, ",") != "Kitchen,Hallway,Garage" {
+			t.Errorf("Unexpected devices imported: %v", imported)
+		}
+
+		if len(result.Errors) != 3 {
+			t.Fatalf("Expected 3 errors, got %+v", result.Errors)
+		}
+		if result.Errors[0].Line != 3 || result.Errors[0].Errors["name"] == "" {
+			t.Errorf("Expected a name validation error on line 3, got %+v", result.Errors[0])
+		}
+		if result.Errors[1].Line != 4 || !strings.HasPrefix(result.Errors[1].Error, "invalid JSON") {
+			t.Errorf("Expected a JSON error on line 4, got %+v", result.Errors[1])
+		}
+		if result.Errors[2].Line != 6 || result.Errors[2].Error != errLineTooLong.Error() {
+			t.Errorf("Expected a line length error on line 6, got %+v", result.Errors[2])
+		}
+	})
+
+	t.Run("Written in chunks", func(t *testing.T) {
+		var chunks []int
+		mockSvc := &MockDeviceService{
+			importFunc: func(devices []*models.DeviceCreate) error {
+				chunks = append(chunks, len(devices))
+				return nil
+			},
+		}
+
+		_, result := postImport(t, mockSvc, "", importBody(250))
+		if result.Created != 250 || result.Rejected != 0 {
+			t.Errorf("Unexpected summary: %+v", result)
+		}
+		if fmt.Sprint(chunks) != "[100 100 50]" {
+			t.Errorf("Expected chunks of [100 100 50], got %v", chunks)
+		}
+	})
+
+	t.Run("Failed chunk rejects its lines", func(t *testing.T) {
+		calls := 0
+		mockSvc := &MockDeviceService{
+			importFunc: func(devices []*models.DeviceCreate) error {
+				calls++
+				if calls == 1 {
+					return errors.New("database is locked")
+				}
+				return nil
+			},
+		}
+
+		_, result := postImport(t, mockSvc, "", importBody(150))
+		if result.Created != 50 || result.Rejected != 100 || len(result.Errors) != 100 {
+			t.Fatalf("Unexpected summary: %+v", result)
+		}
+		if result.Errors[0].Line != 1 || result.Errors[99].Line != 100 || result.Errors[0].Error != "database is locked" {
+			t.Errorf("Expected lines 1-100 rejected with the write error, got %+v ... %+v", result.Errors[0], result.Errors[99])
+		}
+	})
+
+	t.Run("Dry run writes nothing", func(t *testing.T) {
+		mockSvc := &MockDeviceService{
+			importFunc: func(devices []*models.DeviceCreate) error {
+				t.Error("ImportDevices must not be called on a dry run")
+				return nil
+			},
+		}
+
+		_, result := postImport(t, mockSvc, "?dry_run=true", importBody(3)+`{"name": "x"}`)
+		if !result.DryRun || result.Total != 4 || result.Created != 3 || result.Rejected != 1 {
+			t.Errorf("Unexpected summary: %+v", result)
+		}
+	})
+
+	t.Run("Invalid dry_run", func(t *testing.T) {
+		w, _ := postImport(t, &MockDeviceService{}, "?dry_run=maybe", importBody(1))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// getIncidents handles GET /api/incidents
+func (h *Handler) getIncidents(c *gin.Context) {
+	statuses, ok := parseEnumQuery(c, "status", models.IncidentStatusOpen, models.IncidentStatusResolved)
+	if !ok {
+		return
+	}
+
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+
+	incidents, err := h.incidentService.ListIncidents(&models.IncidentListOptions{
+		Statuses: statuses,
+		Limit:    page.Limit,
+		Offset:   page.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, incidents)
+}
+
+// resolveIncident handles POST /api/incidents/:id/resolve
+func (h *Handler) resolveIncident(c *gin.Context) {
+	id, ok := parseIncidentIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.incidentService.ResolveIncident(id)
+	if err != nil {
+		if errors.Is(err, models.ErrIncidentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
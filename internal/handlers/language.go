@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+// requestLanguage returns the language validation messages are given in for a request: the
+// Accept-Language entry with the highest weight that the catalog supports, or English when none is
+func requestLanguage(c *gin.Context) string {
+	type weighted struct {
+		tag    string
+		weight float64
+	}
+
+	var tags []weighted
+	for _, entry := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight > 0 {
+			tags = append(tags, weighted{tag: tag, weight: weight})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].weight > tags[j].weight })
+
+	for _, t := range tags {
+		if lang, ok := validation.MatchLanguage(t.tag); ok {
+			return lang
+		}
+	}
+	return validation.DefaultLanguage
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+)
+
+func TestRequestLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"No header", "", "en"},
+		{"Exact", "fr", "fr"},
+		{"Region", "de-CH", "de"},
+		{"Weighted", "en;q=0.5, es;q=0.9", "es"},
+		{"Unknown first", "pt-BR, fr;q=0.8", "fr"},
+		{"Refused", "es;q=0, de;q=0.1", "de"},
+		{"Only unknown", "pt-BR, ja", "en"},
+		{"Malformed weight", "fr;q=high, de;q=0.5", "de"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newParamContext("/", nil)
+			if tc.header != "" {
+				c.Request.Header.Set("Accept-Language", tc.header)
+			}
+			if lang := requestLanguage(c); lang != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, lang)
+			}
+		})
+	}
+}
+
+func TestLocalizedValidationErrors(t *testing.T) {
+	router := newTestServer(&MockDeviceService{}, testutil.NewConfig())
+
+	tests := []struct {
+		language     string
+		expectedBody string
+	}{
+		{"fr-FR,fr;q=0.9", `"name":"doit comporter entre 1 et 100 caractères`},
+		{"pt-BR", `"name":"must be between 1-100 characters`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.language, func(t *testing.T) {
+			body := `{"name": "Bad name!", "device_type": "CAMERA", "owned_by": "jane"}`
+			req, _ := http.NewRequest("POST", "/api/devices", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tc.language)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("Expected status code %d, got %d", http.StatusUnprocessableEntity, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body containing %s, got %s", tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
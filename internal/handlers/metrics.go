@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request metrics are kept per route over a sliding window made of fixed buckets. Each route
+// holds metricsBuckets buckets with a fixed-size latency histogram, and routes are keyed by their
+// pattern rather than the request path, so memory stays bounded however much traffic arrives.
+const (
+	metricsBuckets     = 10
+	metricsBucketWidth = 30 * time.Second
+	metricsWindow      = metricsBuckets * metricsBucketWidth
+)
+
+// unmatchedRoute groups requests that matched no route, whose paths are arbitrary
+const unmatchedRoute = "unmatched"
+
+// latencyBounds are the upper bounds of the latency histogram buckets. Latencies above the
+// last bound are counted in an overflow bucket.
+var latencyBounds = [...]time.Duration{
+	100 * time.Microsecond, 200 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+	10 * time.Second, 20 * time.Second, time.Minute,
+}
+
+// routeKey identifies a route by method and pattern
+type routeKey struct {
+	method string
+	path   string
+}
+
+// metricsBucket counts the requests to a route that finished in one slice of the window
+type metricsBucket struct {
+	// epoch is the slice the counts belong to; a bucket from an older slice is stale
+	epoch    int64
+	requests uint64
+	errors   uint64
+	latency  [len(latencyBounds) + 1]uint64
+}
+
+// routeMetrics is the ring of buckets for one route
+type routeMetrics struct {
+	buckets [metricsBuckets]metricsBucket
+}
+
+// requestMetrics collects per-route request counts and latencies in process
+type requestMetrics struct {
+	mu     sync.Mutex
+	routes map[routeKey]*routeMetrics
+	since  time.Time
+	now    func() time.Time
+}
+
+// RouteStats summarises the requests to one route over the metrics window.
+// Latency percentiles are the upper bound of the histogram bucket they fall in.
+type RouteStats struct {
+	Requests uint64  `json:"requests"`
+	Errors   uint64  `json:"errors"`
+	P50Ms    float64 `json:"p50_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+}
+
+// RequestStats is the body of GET /api/admin/stats
+type RequestStats struct {
+	WindowSeconds int                   `json:"window_seconds"`
+	Since         time.Time             `json:"since"`
+	Routes        map[string]RouteStats `json:"routes"`
+}
+
+func newRequestMetrics() *requestMetrics {
+	m := &requestMetrics{now: time.Now}
+	m.reset()
+	return m
+}
+
+// middleware records the outcome and latency of every request. A handler that panics is
+// recorded as a 500 before the panic carries on to gin's recovery.
+func (m *requestMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := m.now()
+		status := http.StatusInternalServerError
+		defer func() {
+			path := c.FullPath()
+			if path == "" {
+				path = unmatchedRoute
+			}
+			m.record(routeKey{method: c.Request.Method, path: path}, status, start, m.now())
+		}()
+
+		c.Next()
+		status = c.Writer.Status()
+	}
+}
+
+// record counts one request, in the bucket of the time it finished. Responses with a 5xx
+// status count as errors.
+func (m *requestMetrics) record(key routeKey, status int, start, end time.Time) {
+	elapsed := end.Sub(start)
+	latency := len(latencyBounds)
+	for i, bound := range latencyBounds {
+		if elapsed <= bound {
+			latency = i
+			break
+		}
+	}
+
+	epoch := end.UnixNano() / int64(metricsBucketWidth)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, ok := m.routes[key]
+	if !ok {
+		route = &routeMetrics{}
+		m.routes[key] = route
+	}
+
+	bucket := &route.buckets[epoch%metricsBuckets]
+	if bucket.epoch != epoch {
+		*bucket = metricsBucket{epoch: epoch}
+	}
+	bucket.requests++
+	if status >= http.StatusInternalServerError {
+		bucket.errors++
+	}
+	bucket.latency[latency]++
+}
+
+// snapshot summarises each route over the buckets still inside the window
+func (m *requestMetrics) snapshot() *RequestStats {
+	epoch := m.now().UnixNano() / int64(metricsBucketWidth)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := &RequestStats{
+		WindowSeconds: int(metricsWindow / time.Second),
+		Since:         m.since,
+		Routes:        make(map[string]RouteStats, len(m.routes)),
+	}
+	for key, route := range m.routes {
+		var summary RouteStats
+		var latency [len(latencyBounds) + 1]uint64
+		for i := range route.buckets {
+			bucket := &route.buckets[i]
+			if bucket.epoch <= epoch-metricsBuckets || bucket.requests == 0 {
+				continue
+			}
+			summary.Requests += bucket.requests
+			summary.Errors += bucket.errors
+			for j, count := range bucket.latency {
+				latency[j] += count
+			}
+		}
+		if summary.Requests == 0 {
+			continue
+		}
+
+		summary.P50Ms = latencyPercentile(&latency, summary.Requests, 0.50)
+		summary.P95Ms = latencyPercentile(&latency, summary.Requests, 0.95)
+		stats.Routes[key.method+" "+key.path] = summary
+	}
+
+	return stats
+}
+
+// reset drops all collected metrics
+func (m *requestMetrics) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.routes = make(map[routeKey]*routeMetrics)
+	m.since = m.now().UTC()
+}
+
+// latencyPercentile returns, in milliseconds, the upper bound of the histogram bucket holding the
+// q quantile of total requests. The overflow bucket reports the last bound.
+func latencyPercentile(latency *[len(latencyBounds) + 1]uint64, total uint64, q float64) float64 {
+	rank := uint64(q*float64(total-1)) + 1
+
+	var seen uint64
+	for i, count := range latency {
+		seen += count
+		if seen >= rank {
+			if i == len(latencyBounds) {
+				i--
+			}
+			return float64(latencyBounds[i]) / float64(time.Millisecond)
+		}
+	}
+
+	return 0
+}
+
+// getRequestStats handles GET /api/admin/stats
+func (h *Handler) getRequestStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.metrics.snapshot())
+}
+
+// resetRequestStats handles DELETE /api/admin/stats
+func (h *Handler) resetRequestStats(c *gin.Context) {
+	h.metrics.reset()
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestRequestMetricsSnapshot(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := newRequestMetrics()
+	m.now = func() time.Time { return now }
+
+	devices := routeKey{method: "GET", path: "/api/devices"}
+	for i := 0; i < 90; i++ {
+		m.record(devices, http.StatusOK, now.Add(-3*time.Millisecond), now)
+	}
+	for i := 0; i < 10; i++ {
+		m.record(devices, http.StatusInternalServerError, now.Add(-150*time.Millisecond), now)
+	}
+	m.record(routeKey{method: "POST", path: "/api/devices"}, http.StatusBadRequest, now.Add(-time.Hour), now)
+
+	stats := m.snapshot()
+	if got := stats.Routes["GET /api/devices"]; got != (RouteStats{Requests: 100, Errors: 10, P50Ms: 5, P95Ms: 200}) {
+		t.Errorf("Unexpected GET stats %+v", got)
+	}
+	// Client errors are not errors, and latencies past the last bound report that bound
+	if got := stats.Routes["POST /api/devices"]; got != (RouteStats{Requests: 1, P50Ms: 60000, P95Ms: 60000}) {
+		t.Errorf("Unexpected POST stats %+v", got)
+	}
+
+	// Requests drop out once their bucket leaves the window
+	now = now.Add(metricsWindow - metricsBucketWidth)
+	m.record(devices, http.StatusOK, now.Add(-time.Millisecond), now)
+	if got := m.snapshot().Routes["GET /api/devices"].Requests; got != 101 {
+		t.Errorf("Expected 101 requests inside the window, got %d", got)
+	}
+	now = now.Add(metricsBucketWidth)
+	stats = m.snapshot()
+	if got := stats.Routes["GET /api/devices"].Requests; got != 1 {
+		t.Errorf("Expected 1 request inside the window, got %d", got)
+	}
+	if _, ok := stats.Routes["POST /api/devices"]; ok {
+		t.Error("Expected a route without recent requests to be left out")
+	}
+}
+
+func TestRequestStatsEndpoint(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			if id == 2 {
+				panic("lost the database")
+			}
+			return testutil.NewDevice().WithID(id).Build(), nil
+		},
+	}
+	cfg := testutil.NewConfig()
+	cfg.AdminToken = "admin-secret"
+	router := newTestServer(mockSvc, cfg)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	stats := func() RequestStats {
+		var body RequestStats
+		if err := json.Unmarshal(serve("GET", "/api/admin/stats").Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body
+	}
+
+	serve("GET", "/api/devices/1")
+	serve("GET", "/api/devices/2")
+	serve("GET", "/no/such/path")
+
+	body := stats()
+	if body.WindowSeconds != 300 {
+		t.Errorf("Expected a 300 second window, got %d", body.WindowSeconds)
+	}
+	// Requests are grouped by route pattern, not by path
+	// and a handler that panicked counts as an error
+	if got := body.Routes["GET /api/devices/:id"]; got.Requests != 2 || got.Errors != 1 {
+		t.Errorf("Expected 2 requests and 1 error for GET /api/devices/:id, got %+v", got)
+	}
+	if got := body.Routes["GET "+unmatchedRoute].Requests; got != 1 {
+		t.Errorf("Expected 1 unmatched request, got %d", got)
+	}
+
+	// Reading or resetting the stats needs the admin token
+	for _, method := range []string{"GET", "DELETE"} {
+		for _, token := range []string{"", "guess"} {
+			req, _ := http.NewRequest(method, "/api/admin/stats", nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("%s with token %q: expected status code %d, got %d", method, token, http.StatusUnauthorized, recorder.Code)
+			}
+		}
+	}
+
+	if recorder := serve("DELETE", "/api/admin/stats"); recorder.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+	// Only the stats request made after the reset remains
+	if body := stats(); len(body.Routes) != 1 || body.Routes["DELETE /api/admin/stats"].Requests != 1 {
+		t.Errorf("Unexpected routes after reset: %+v", body.Routes)
+	}
+}
+
+// BenchmarkRequestMetrics compares a trivial route served with and without the metrics
+// middleware, so the difference is its per-request cost
+func BenchmarkRequestMetrics(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name    string
+		metrics bool
+	}{
+		{"Without", false},
+		{"With", true},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			router := gin.New()
+			if tc.metrics {
+				router.Use(newRequestMetrics().middleware())
+			}
+			router.GET("/api/devices/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req, _ := http.NewRequest("GET", "/api/devices/1", nil)
+			recorder := httptest.NewRecorder()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				router.ServeHTTP(recorder, req)
+			}
+		})
+	}
+}
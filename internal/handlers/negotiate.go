@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// formatKey is the context key holding the response format chosen by negotiate
+const formatKey = "response_format"
+
+// negotiate picks the response format for a route from the Accept header, defaulting to the
+// first offer when the header is absent or */*. Unsupported Accept values get a 406.
+func negotiate(offered ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.NegotiateFormat(offered...)
+		if format == "" {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"error": "Accept must allow one of: " + strings.Join(offered, ", ")})
+			return
+		}
+
+		c.Set(formatKey, format)
+		c.Next()
+	}
+}
+
+// wantsXML reports whether negotiate chose XML for the current request
+func wantsXML(c *gin.Context) bool {
+	format := c.GetString(formatKey)
+	return format == gin.MIMEXML || format == gin.MIMEXML2
+}
+
+// respond writes obj as XML when negotiated, and as JSON otherwise
+func respond(c *gin.Context, status int, obj interface{}) {
+	if wantsXML(c) {
+		c.XML(status, toXML(obj))
+		return
+	}
+	c.JSON(status, obj)
+}
+
+// respondError writes an {"error": msg} response, or <error><message>msg</message></error> when XML was negotiated
+func respondError(c *gin.Context, status int, msg string) {
+	if wantsXML(c) {
+		c.XML(status, errorXML{Message: msg})
+		return
+	}
+	c.JSON(status, gin.H{"error": msg})
+}
+
+// XML representations of the API models. They are kept apart from the models so the JSON
+// encoding is not affected by XML concerns such as root element names.
+
+type errorXML struct {
+	XMLName xml.Name `xml:"error"`
+	Message string   `xml:"message"`
+}
+
+type deviceXML struct {
+	XMLName               xml.Name          `xml:"device"`
+	ID                    int64             `xml:"id"`
+	OwnedBy               string            `xml:"owned_by"`
+	DeviceType            models.DeviceType `xml:"device_type"`
+	Name                  string            `xml:"name"`
+	Description           string            `xml:"description"`
+	IsOnline              bool              `xml:"is_online"`
+	LastAlarmTime         time.Time         `xml:"last_alarm_time"`
+	LastAlarmReason       string            `xml:"last_alarm_reason"`
+	LastAlarmTriggeredBy  string            `xml:"last_alarm_triggered_by"`
+	LastAlarmLevel        string            `xml:"last_alarm_level"`
+	AlarmActive           bool              `xml:"alarm_active"`
+	LastAlarmSuppressed   bool              `xml:"last_alarm_suppressed"`
+	AlarmAcknowledgedAt   time.Time         `xml:"alarm_acknowledged_at"`
+	MaintenanceMode       bool              `xml:"maintenance_mode"`
+	MaintenanceUntil      time.Time         `xml:"maintenance_until"`
+	NotifyOnAlarm         bool              `xml:"notify_on_alarm"`
+	FirmwareVersion       string            `xml:"firmware_version"`
+	FirmwareTargetVersion string            `xml:"firmware_target_version"`
+	FirmwareUpdateStatus  string            `xml:"firmware_update_status"`
+	Archived              bool              `xml:"archived"`
+	LastSeenAt            time.Time         `xml:"last_seen_at"`
+	Stale                 bool              `xml:"stale"`
+	ParentID              *int64            `xml:"parent_id,omitempty"`
+	PropagateAlarms       bool              `xml:"propagate_alarms"`
+	ChildCount            int64             `xml:"child_count"`
+	AlarmRetentionDays    *int64            `xml:"alarm_retention_days,omitempty"`
+	AlarmCount            *int64            `xml:"alarm_count,omitempty"`
+	Version               int64             `xml:"version"`
+	CreatedAt             time.Time         `xml:"created_at"`
+	UpdatedAt             time.Time         `xml:"updated_at"`
+}
+
+type deviceListXML struct {
+	XMLName xml.Name     `xml:"devices"`
+	Devices []*deviceXML `xml:"device"`
+}
+
+type deviceTypeXML struct {
+	XMLName     xml.Name `xml:"device_type"`
+	ID          string   `xml:"id"`
+	DisplayName string   `xml:"display_name"`
+	Description string   `xml:"description"`
+	Deprecated  bool     `xml:"deprecated"`
+}
+
+type deviceTypeListXML struct {
+	XMLName     xml.Name         `xml:"device_types"`
+	DeviceTypes []*deviceTypeXML `xml:"device_type"`
+}
+
+func newDeviceXML(d *models.Device) *deviceXML {
+	return &deviceXML{
+		ID:                    d.ID,
+		OwnedBy:               d.OwnedBy,
+		DeviceType:            d.DeviceType,
+		Name:                  d.Name,
+		Description:           d.Description,
+		IsOnline:              d.IsOnline,
+		LastAlarmTime:         d.LastAlarmTime,
+		LastAlarmReason:       d.LastAlarmReason,
+		LastAlarmTriggeredBy:  d.LastAlarmTriggeredBy,
+		LastAlarmLevel:        d.LastAlarmLevel,
+		AlarmActive:           d.AlarmActive,
+		LastAlarmSuppressed:   d.LastAlarmSuppressed,
+		AlarmAcknowledgedAt:   d.AlarmAcknowledgedAt,
+		MaintenanceMode:       d.MaintenanceMode,
+		MaintenanceUntil:      d.MaintenanceUntil,
+		NotifyOnAlarm:         d.NotifyOnAlarm,
+		FirmwareVersion:       d.FirmwareVersion,
+		FirmwareTargetVersion: d.FirmwareTargetVersion,
+		FirmwareUpdateStatus:  d.FirmwareUpdateStatus,
+		Archived:              d.Archived,
+		LastSeenAt:            d.LastSeenAt,
+		Stale:                 d.Stale,
+		ParentID:              d.ParentID,
+		PropagateAlarms:       d.PropagateAlarms,
+		ChildCount:            d.ChildCount,
+		AlarmRetentionDays:    d.AlarmRetentionDays,
+		AlarmCount:            d.AlarmCount,
+		Version:               d.Version,
+		CreatedAt:             d.CreatedAt,
+		UpdatedAt:             d.UpdatedAt,
+	}
+}
+
+// toXML converts a response body to its XML representation. Types without one are encoded as they are.
+func toXML(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case *models.Device:
+		return newDeviceXML(v)
+	case []*models.Device:
+		list := deviceListXML{Devices: make([]*deviceXML, 0, len(v))}
+		for _, device := range v {
+			list.Devices = append(list.Devices, newDeviceXML(device))
+		}
+		return list
+	case []models.DeviceTypeInfo:
+		list := deviceTypeListXML{DeviceTypes: make([]*deviceTypeXML, 0, len(v))}
+		for _, info := range v {
+			list.DeviceTypes = append(list.DeviceTypes, &deviceTypeXML{ID: info.ID, DisplayName: info.DisplayName, Description: info.Description, Deprecated: info.Deprecated})
+		}
+		return list
+	}
+	return obj
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			if id != 1 {
+				return nil, nil
+			}
+			return testutil.NewDevice().WithName("Kitchen").WithType(models.DeviceTypeCamera).WithOwner("owner1").Build(), nil
+		},
+		listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+			return []*models.Device{
+				testutil.NewDevice().WithID(1).WithName("Kitchen").Build(),
+				testutil.NewDevice().WithID(2).WithName("Hallway").Build(),
+			}, nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	tests := []struct {
+		name                string
+		url                 string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{"No Accept defaults to JSON", "/api/devices/1", "", http.StatusOK, gin.MIMEJSON, `"name":"Kitchen"`},
+		{"Wildcard defaults to JSON", "/api/devices/1", "*/*", http.StatusOK, gin.MIMEJSON, `"name":"Kitchen"`},
+		{"Device as XML", "/api/devices/1", "application/xml", http.StatusOK, gin.MIMEXML, `<device><id>1</id><owned_by>owner1</owned_by><device_type>CAMERA</device_type><name>Kitchen</name>`},
+		{"text/xml", "/api/devices/1", "text/xml", http.StatusOK, gin.MIMEXML, `<name>Kitchen</name>`},
+		{"List as XML", "/api/devices", "application/xml", http.StatusOK, gin.MIMEXML, `<devices><device><id>1</id>`},
+		{"Device types as XML", "/api/device-types", "application/xml", http.StatusOK, gin.MIMEXML, `<device_types><device_type><id>CAMERA</id><display_name>Camera</display_name>`},
+		{"Device types as JSON", "/api/device-types", "application/json", http.StatusOK, gin.MIMEJSON, `"id":"CAMERA"`},
+		{"Not found as XML", "/api/devices/2", "application/xml", http.StatusNotFound, gin.MIMEXML, `<error><message>device not found</message></error>`},
+		{"Bad ID as XML", "/api/devices/abc", "application/xml", http.StatusBadRequest, gin.MIMEXML, `<error><message>invalid device ID</message></error>`},
+		{"Bad query as XML", "/api/devices?order=up", "application/xml", http.StatusBadRequest, gin.MIMEXML, `<message>order must be one of: asc, desc</message>`},
+		{"Unsupported Accept", "/api/devices/1", "text/csv", http.StatusNotAcceptable, gin.MIMEJSON, `"error"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.expectedContentType) {
+				t.Errorf("Expected Content-Type %s, got %s", tc.expectedContentType, got)
+			}
+			if !strings.Contains(w.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body to contain %s, got %s", tc.expectedBody, w.Body.String())
+			}
+			if tc.expectedContentType == gin.MIMEXML {
+				if err := xml.Unmarshal(w.Body.Bytes(), new(interface{})); err != nil {
+					t.Errorf("Response is not well-formed XML: %v", err)
+				}
+			}
+		})
+	}
+}
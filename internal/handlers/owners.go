@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+// ownerRenameWithWarnings is an owner rename along with the validation warnings the new name raised
+type ownerRenameWithWarnings struct {
+	*models.OwnerRenameResult
+	Warnings validation.ValidationErrors `json:"warnings,omitempty"`
+}
+
+// checkOwner rejects owner in result's field when owners are strict and no device belongs to it
+// yet, suggesting similar known owners. Admin requests and owners that are already invalid are
+// not checked. It returns an error only when the check itself fails.
+func (h *Handler) checkOwner(c *gin.Context, result *validation.Result, field, owner string) error {
+	if !h.config.StrictOwners || h.isAdmin(c) || result.Errors[field] != "" {
+		return nil
+	}
+
+	known, suggestions, err := h.deviceService.CheckOwner(owner)
+	if err != nil {
+		return err
+	}
+	if !known {
+		result.RejectUnknownOwner(field, suggestions)
+	}
+	return nil
+}
+
+// getOwners handles GET /api/owners, listing the owners devices belong to, such as for an owner
+// filter. ?search= keeps those starting with it, ignoring case.
+func (h *Handler) getOwners(c *gin.Context) {
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+
+	owners, err := h.deviceService.GetOwners(&models.OwnerListOptions{
+		Search: c.Query("search"),
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, owners)
+}
+
+// renameOwner handles POST /api/admin/owners/rename, renaming an owner on every device and in
+// the alarms naming it as actor, such as after a username change. Renaming to an owner that
+// already has devices is refused with 409 unless ?merge=true.
+func (h *Handler) renameOwner(c *gin.Context) {
+	merge, ok := parseBoolQuery(c, "merge", false)
+	if !ok {
+		return
+	}
+
+	var req models.OwnerRename
+	if err := h.bindStrictJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := validation.ValidateOwnerRename(&req)
+	if !h.validated(c, result) {
+		return
+	}
+
+	renamed, err := h.deviceService.RenameOwner(&req, merge)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrOwnerNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrOwnerExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error() + "; pass merge=true to merge the two owners"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ownerRenameWithWarnings{OwnerRenameResult: renamed, Warnings: result.Warnings})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers reporting the page that was actually served
+const (
+	pageLimitHeader   = "X-Page-Limit"
+	pageOffsetHeader  = "X-Page-Offset"
+	pageClampedHeader = "X-Page-Clamped"
+)
+
+// pagination is the page a list request is served, after defaults and the maximum are applied
+type pagination struct {
+	Limit  int
+	Offset int
+	// Clamped is true when the requested limit was above the maximum page size and was reduced
+	Clamped bool
+}
+
+// parseLimit reads ?limit for a list endpoint. A missing limit uses defaultSize, and one above
+// maxSize is clamped to it rather than rejected, including a number too large to parse. The
+// limit served is reported in X-Page-Limit, with X-Page-Clamped: true when it was reduced. On an
+// invalid limit it writes a 400 response and returns false.
+func parseLimit(c *gin.Context, defaultSize, maxSize int) (limit int, clamped, ok bool) {
+	if _, err := strconv.ParseUint(c.Query("limit"), 10, 0); errors.Is(err, strconv.ErrRange) {
+		limit = math.MaxInt
+	} else if limit, ok = parseIntQuery(c, "limit", defaultSize, 1, math.MaxInt); !ok {
+		return 0, false, false
+	}
+	if limit > maxSize {
+		limit, clamped = maxSize, true
+	}
+
+	c.Header(pageLimitHeader, strconv.Itoa(limit))
+	if clamped {
+		c.Header(pageClampedHeader, "true")
+	}
+
+	return limit, clamped, true
+}
+
+// parsePagination reads ?limit and ?offset for a list endpoint as parseLimit does, also
+// reporting the offset in X-Page-Offset. On invalid values it writes a 400 response and returns false.
+func parsePagination(c *gin.Context, defaultSize, maxSize int) (pagination, bool) {
+	limit, clamped, ok := parseLimit(c, defaultSize, maxSize)
+	if !ok {
+		return pagination{}, false
+	}
+
+	offset, ok := parseIntQuery(c, "offset", 0, 0, math.MaxInt)
+	if !ok {
+		return pagination{}, false
+	}
+	c.Header(pageOffsetHeader, strconv.Itoa(offset))
+
+	return pagination{Limit: limit, Offset: offset, Clamped: clamped}, true
+}
+
+// parsePage parses the pagination of a list request using the configured page sizes
+func (h *Handler) parsePage(c *gin.Context) (pagination, bool) {
+	return parsePagination(c, h.config.DefaultPageSize, h.config.MaxPageSize)
+}
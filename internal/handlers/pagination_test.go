@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name            string
+		url             string
+		expected        pagination
+		expectedOK      bool
+		expectedHeaders map[string]string
+	}{
+		{"Defaults", "/", pagination{Limit: 20}, true,
+			map[string]string{"X-Page-Limit": "20", "X-Page-Offset": "0", "X-Page-Clamped": ""}},
+		{"Explicit", "/?limit=5&offset=10", pagination{Limit: 5, Offset: 10}, true,
+			map[string]string{"X-Page-Limit": "5", "X-Page-Offset": "10", "X-Page-Clamped": ""}},
+		{"At maximum", "/?limit=50", pagination{Limit: 50}, true,
+			map[string]string{"X-Page-Limit": "50", "X-Page-Clamped": ""}},
+		{"Clamped to maximum", "/?limit=51", pagination{Limit: 50, Clamped: true}, true,
+			map[string]string{"X-Page-Limit": "50", "X-Page-Clamped": "true"}},
+		{"Oversized limit clamped", "/?limit=99999999999999999999", pagination{Limit: 50, Clamped: true}, true,
+			map[string]string{"X-Page-Limit": "50", "X-Page-Clamped": "true"}},
+		{"Oversized negative limit", "/?limit=-99999999999999999999", pagination{}, false, nil},
+		{"Zero limit", "/?limit=0", pagination{}, false, nil},
+		{"Negative offset", "/?offset=-1", pagination{}, false, nil},
+		{"Non-numeric limit", "/?limit=ten", pagination{}, false, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext(tc.url, nil)
+
+			page, ok := parsePagination(c, 20, 50)
+			if ok != tc.expectedOK || page != tc.expected {
+				t.Fatalf("parsePagination(%q) = (%+v, %v); expected (%+v, %v)", tc.url, page, ok, tc.expected, tc.expectedOK)
+			}
+			if !ok {
+				if recorder.Code != http.StatusBadRequest {
+					t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+				}
+				return
+			}
+			for header, expected := range tc.expectedHeaders {
+				if got := recorder.Header().Get(header); got != expected {
+					t.Errorf("Expected %s %q, got %q", header, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLimitHasNoOffset(t *testing.T) {
+	c, recorder := newParamContext("/?limit=500&offset=3", nil)
+
+	limit, clamped, ok := parseLimit(c, 20, 100)
+	if !ok || limit != 100 || !clamped {
+		t.Fatalf("parseLimit = (%d, %v, %v); expected (100, true, true)", limit, clamped, ok)
+	}
+	if got := recorder.Header().Get("X-Page-Offset"); got != "" {
+		t.Errorf("Expected no X-Page-Offset header, got %q", got)
+	}
+}
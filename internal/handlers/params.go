@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseIDParam parses the :id path parameter of a device route.
+// On failure it writes a 400 response and returns false.
+func parseIDParam(c *gin.Context) (int64, bool) {
+	return parseResourceIDParam(c, "id", "device")
+}
+
+// parseIncidentIDParam parses the :id path parameter of an incident route.
+// On failure it writes a 400 response and returns false.
+func parseIncidentIDParam(c *gin.Context) (int64, bool) {
+	return parseResourceIDParam(c, "id", "incident")
+}
+
+// parseCommandIDParam parses the :command_id path parameter of a device command route.
+// On failure it writes a 400 response and returns false.
+func parseCommandIDParam(c *gin.Context) (int64, bool) {
+	return parseResourceIDParam(c, "command_id", "command")
+}
+
+// parseResourceIDParam parses the named path parameter, naming the resource in the error response
+func parseResourceIDParam(c *gin.Context, key, resource string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(key), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid %s ID", resource))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parseIntQuery parses an optional integer query parameter that must lie within [min, max],
+// returning def when the parameter is absent. On failure it writes a 400 response and returns false.
+func parseIntQuery(c *gin.Context, key string, def, min, max int) (int, bool) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, true
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		var msg string
+		if max == math.MaxInt {
+			msg = fmt.Sprintf("%s must be an integer of at least %d", key, min)
+		} else {
+			msg = fmt.Sprintf("%s must be an integer between %d and %d", key, min, max)
+		}
+		respondError(c, http.StatusBadRequest, msg)
+		return 0, false
+	}
+
+	return value, true
+}
+
+// parseBoolQuery parses an optional boolean query parameter, returning def when the parameter is absent.
+// A repeated parameter must not give conflicting values. On failure it writes a 400 response and returns false.
+func parseBoolQuery(c *gin.Context, key string, def bool) (value, ok bool) {
+	values, ok := parseBoolValues(c, key)
+	if !ok {
+		return false, false
+	}
+
+	switch len(values) {
+	case 0:
+		return def, true
+	case 1:
+		return values[0], true
+	}
+	respondError(c, http.StatusBadRequest, fmt.Sprintf("%s is given both true and false", key))
+	return false, false
+}
+
+// parseOptionalBoolQuery parses an optional boolean query parameter used as a filter, returning
+// nil when the parameter is absent. Like other filters, repeated values combine with OR, so
+// giving both true and false filters nothing. On failure it writes a 400 response and returns false.
+func parseOptionalBoolQuery(c *gin.Context, key string) (*bool, bool) {
+	values, ok := parseBoolValues(c, key)
+	if !ok || len(values) != 1 {
+		return nil, ok
+	}
+
+	return &values[0], true
+}
+
+// parseBoolValues parses the distinct values of a possibly repeated boolean query parameter.
+// On failure it writes a 400 response naming the invalid value and returns false.
+func parseBoolValues(c *gin.Context, key string) ([]bool, bool) {
+	var values []bool
+	for _, raw := range queryList(c, key) {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%s must be true or false, got %q", key, raw))
+			return nil, false
+		}
+		if len(values) == 0 || values[0] != value {
+			values = append(values, value)
+		}
+	}
+
+	return values, true
+}
+
+// parseDurationQuery parses an optional duration query parameter such as "24h" that must be
+// positive and at most max, returning def when the parameter is absent. On failure it writes a
+// 400 response and returns false.
+func parseDurationQuery(c *gin.Context, key string, def, max time.Duration) (time.Duration, bool) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, true
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 || value > max {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%s must be a positive duration such as 24h, at most %s", key, max))
+		return 0, false
+	}
+
+	return value, true
+}
+
+// parseTimeQuery parses an optional RFC 3339 timestamp query parameter, returning the zero time
+// when the parameter is absent. A bound cannot be a list, so it may only be given once.
+// On failure it writes a 400 response and returns false.
+func parseTimeQuery(c *gin.Context, key string) (time.Time, bool) {
+	values := queryList(c, key)
+	if len(values) == 0 {
+		return time.Time{}, true
+	}
+	if len(values) > 1 {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%s may only be given once, got %s", key, strings.Join(values, ", ")))
+		return time.Time{}, false
+	}
+
+	raw := values[0]
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%s must be an RFC 3339 timestamp, e.g. 2024-05-01T12:00:00Z, got %q", key, raw))
+		return time.Time{}, false
+	}
+
+	return value, true
+}
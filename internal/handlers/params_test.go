@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newParamContext creates a gin context for the given request URL and path parameters
+func newParamContext(url string, params gin.Params) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request, _ = http.NewRequest("GET", url, nil)
+	c.Params = params
+	return c, recorder
+}
+
+func TestParseIDParam(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		expectedID int64
+		expectedOK bool
+	}{
+		{"Valid ID", "42", 42, true},
+		{"Non-numeric", "abc", 0, false},
+		{"Empty", "", 0, false},
+		{"Overflow", "99999999999999999999", 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext("/", gin.Params{{Key: "id", Value: tc.id}})
+
+			id, ok := parseIDParam(c)
+			if ok != tc.expectedOK || id != tc.expectedID {
+				t.Errorf("parseIDParam(%q) = (%d, %v); expected (%d, %v)", tc.id, id, ok, tc.expectedID, tc.expectedOK)
+			}
+			if !ok && recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestParseIntQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		min, max      int
+		expectedValue int
+		expectedOK    bool
+	}{
+		{"Absent uses default", "", 1, 10, 5, true},
+		{"Within bounds", "?n=7", 1, 10, 7, true},
+		{"At lower bound", "?n=1", 1, 10, 1, true},
+		{"At upper bound", "?n=10", 1, 10, 10, true},
+		{"Below minimum", "?n=0", 1, 10, 0, false},
+		{"Above maximum", "?n=11", 1, 10, 0, false},
+		{"Unbounded maximum", "?n=100000", 1, math.MaxInt, 100000, true},
+		{"Non-numeric", "?n=abc", 1, 10, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext("/"+tc.query, nil)
+
+			value, ok := parseIntQuery(c, "n", 5, tc.min, tc.max)
+			if ok != tc.expectedOK || value != tc.expectedValue {
+				t.Errorf("parseIntQuery(%q) = (%d, %v); expected (%d, %v)", tc.query, value, ok, tc.expectedValue, tc.expectedOK)
+			}
+			if !ok && recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestParseBoolQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedValue bool
+		expectedOK    bool
+	}{
+		{"Absent uses default", "", true, true},
+		{"True", "?b=true", true, true},
+		{"False", "?b=false", false, true},
+		{"Numeric", "?b=0", false, true},
+		{"Invalid", "?b=maybe", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext("/"+tc.query, nil)
+
+			value, ok := parseBoolQuery(c, "b", true)
+			if ok != tc.expectedOK || value != tc.expectedValue {
+				t.Errorf("parseBoolQuery(%q) = (%v, %v); expected (%v, %v)", tc.query, value, ok, tc.expectedValue, tc.expectedOK)
+			}
+			if !ok && recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestParseTimeQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedValue time.Time
+		expectedOK    bool
+	}{
+		{"Absent is zero", "", time.Time{}, true},
+		{"UTC", "?at=2024-05-01T12:00:00Z", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), true},
+		{"Offset", "?at=2024-05-01T14:00:00%2B02:00", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), true},
+		{"Date only", "?at=2024-05-01", time.Time{}, false},
+		{"Garbage", "?at=yesterday", time.Time{}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, recorder := newParamContext("/"+tc.query, nil)
+
+			value, ok := parseTimeQuery(c, "at")
+			if ok != tc.expectedOK || !value.Equal(tc.expectedValue) {
+				t.Errorf("parseTimeQuery(%q) = (%s, %v); expected (%s, %v)", tc.query, value, ok, tc.expectedValue, tc.expectedOK)
+			}
+			if !ok && recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// prometheusContentType is the media type of the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// deviceGauges are the per-type gauges exported by GET /api/devices/metrics
+var deviceGauges = []struct {
+	name  string
+	help  string
+	value func(*models.DeviceTypeCounts) int
+}{
+	{"gohome_devices", "Number of devices.", func(c *models.DeviceTypeCounts) int { return c.Total }},
+	{"gohome_devices_online", "Number of devices that are online.", func(c *models.DeviceTypeCounts) int { return c.Online }},
+	{"gohome_devices_alarm_active", "Number of devices with an active alarm.", func(c *models.DeviceTypeCounts) int { return c.AlarmActive }},
+}
+
+// getDeviceMetrics handles GET /api/devices/metrics, writing device counts per type in the
+// Prometheus text exposition format for scrapers that need nothing more
+func (h *Handler) getDeviceMetrics(c *gin.Context) {
+	counts, err := h.deviceService.GetDeviceStateCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", prometheusContentType)
+	writeDeviceGauges(c.Writer, counts)
+}
+
+// writeDeviceGauges writes each gauge with one sample per device type. Device types are fixed
+// enum values, so they need no escaping as label values.
+func writeDeviceGauges(w io.Writer, counts []*models.DeviceTypeCounts) {
+	for _, gauge := range deviceGauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for _, typeCounts := range counts {
+			fmt.Fprintf(w, "%s{device_type=%q} %d\n", gauge.name, typeCounts.DeviceType, gauge.value(typeCounts))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestGetDeviceMetrics(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		stateCountsFunc: func() ([]*models.DeviceTypeCounts, error) {
+			return []*models.DeviceTypeCounts{
+				{DeviceType: models.DeviceTypeCamera, Total: 3, Online: 2, AlarmActive: 1},
+				{DeviceType: models.DeviceTypeLock},
+			}, nil
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/api/devices/metrics", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != prometheusContentType {
+		t.Errorf("Expected Content-Type %q, got %q", prometheusContentType, contentType)
+	}
+
+	expected := `# HELP gohome_devices Number of devices.
+# TYPE gohome_devices gauge
+gohome_devices{device_type="CAMERA"} 3
+gohome_devices{device_type="LOCK"} 0
+# HELP gohome_devices_online Number of devices that are online.
+# TYPE gohome_devices_online gauge
+gohome_devices_online{device_type="CAMERA"} 2
+gohome_devices_online{device_type="LOCK"} 0
+# HELP gohome_devices_alarm_active Number of devices with an active alarm.
+# TYPE gohome_devices_alarm_active gauge
+gohome_devices_alarm_active{device_type="CAMERA"} 1
+gohome_devices_alarm_active{device_type="LOCK"} 0
+`
+	if recorder.Body.String() != expected {
+		t.Errorf("Unexpected exposition:\n%s", recorder.Body.String())
+	}
+}
+
+func TestGetDeviceMetricsError(t *testing.T) {
+	mockSvc := &MockDeviceService{
+		stateCountsFunc: func() ([]*models.DeviceTypeCounts, error) {
+			return nil, errors.New("database is locked")
+		},
+	}
+	router := newTestServer(mockSvc, testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/api/devices/metrics", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/internal/testutil"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name         string
+		proxies      []string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{"Direct request", nil, "203.0.113.9:4000", "", "203.0.113.9"},
+		{"Forwarded header ignored with no proxies configured", nil, "10.0.0.1:4000", "198.51.100.7", "10.0.0.1"},
+		{"Forwarded by a trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"Forwarded by a trusted proxy address", []string{"10.0.0.1"}, "10.0.0.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"Forwarded by an untrusted address", []string{"10.0.0.0/8"}, "192.168.1.5:4000", "198.51.100.7", "192.168.1.5"},
+		{"Trusted proxy chain", []string{"10.0.0.0/8"}, "10.0.0.1:4000", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"Spoofed entry before a trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.1:4000", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"Trusted proxy without the header", []string{"10.0.0.0/8"}, "10.0.0.1:4000", "", "10.0.0.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			cfg := testutil.NewConfig()
+			cfg.TrustedProxies = tc.proxies
+			h := New(&MockDeviceService{}, &MockIncidentService{}, &MockCommandService{}, &MockChangeService{}, cfg)
+
+			var clientIP string
+			h.router.GET("/client-ip", func(c *gin.Context) {
+				clientIP = c.ClientIP()
+				c.Status(http.StatusNoContent)
+			})
+
+			req, _ := http.NewRequest("GET", "/client-ip", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			recorder := httptest.NewRecorder()
+			h.router.ServeHTTP(recorder, req)
+
+			if clientIP != tc.expectedIP {
+				t.Errorf("Expected client IP %s, got %s", tc.expectedIP, clientIP)
+			}
+		})
+	}
+}
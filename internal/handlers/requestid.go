@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// requestIDHeader carries the id a request is logged under, in both directions
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey is the context key holding the request id
+	requestIDKey = "request_id"
+	// maxRequestIDLength bounds the client-supplied ids that are reused
+	maxRequestIDLength = 64
+)
+
+// requestIDMiddleware gives every request an id, reusing the client's X-Request-ID when it is
+// reasonable, and echoes it in the response
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// isValidRequestID accepts short ids of printable ASCII, so they are safe to log
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the id requestIDMiddleware gave the current request
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
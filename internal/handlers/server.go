@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixAddrPrefix marks a server address as the path of a Unix domain socket
+const unixAddrPrefix = "unix:"
+
+// shutdownTimeout is how long in-flight requests get to finish once the server is stopping
+const shutdownTimeout = 10 * time.Second
+
+// ServeHTTP serves a single request, making the Handler usable with net/http servers and
+// httptest without listening itself
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// StartServer serves the API on every address in the comma-separated addr until ctx is cancelled,
+// then shuts down gracefully. Addresses are host:port for TCP or unix:/path/to.sock for a Unix
+// domain socket, which is removed again on shutdown. onReady, if not nil, is called once every
+// listener is accepting connections.
+func (h *Handler) StartServer(ctx context.Context, addr string, onReady func()) error {
+	var listeners []net.Listener
+	for _, address := range strings.Split(addr, ",") {
+		listener, err := h.listen(strings.TrimSpace(address))
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return err
+		}
+		log.Printf("Listening on %s", address)
+		listeners = append(listeners, listener)
+	}
+
+	server := &http.Server{Handler: h.router}
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			errs <- server.Serve(listener)
+		}(listener)
+	}
+	// Connections are queued by the kernel from Listen on, so the server is ready now
+	if onReady != nil {
+		onReady()
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errs:
+	}
+
+	// Shutdown closes the listeners, which removes Unix socket files
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		return nil
+	}
+	return serveErr
+}
+
+// listen opens a listener for one server address
+func (h *Handler) listen(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, unixAddrPrefix)
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, h.config.SocketMode); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("setting mode of %s: %w", path, err)
+	}
+
+	return listener, nil
+}
+
+// removeStaleSocket removes a socket file left behind by a server that did not shut down cleanly.
+// A socket something still answers on, or a file that is not a socket, is left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+
+	log.Printf("Removing stale socket %s", path)
+	return os.Remove(path)
+}
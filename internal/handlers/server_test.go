@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// unixClient returns an HTTP client that dials the socket at path whatever the request URL says
+func unixClient(path string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// startTestServer runs StartServer on addr in the background, returning a function that stops it
+// and reports its result, and a channel closed once it is ready
+func startTestServer(t *testing.T, addr string) (func() error, <-chan struct{}) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.NewConfig()
+	cfg.SocketMode = 0o600
+	mockSvc := &MockDeviceService{
+		getAllFunc: func() ([]*models.Device, error) { return nil, nil },
+	}
+	h := New(mockSvc, &MockIncidentService{}, &MockCommandService{}, &MockChangeService{}, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	ready := make(chan struct{})
+	go func() { done <- h.StartServer(ctx, addr, func() { close(ready) }) }()
+
+	stop := func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Server did not stop")
+			return nil
+		}
+	}
+
+	return stop, ready
+}
+
+func TestStartServerUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gohome.sock")
+
+	// A socket left behind by a server that crashed is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	// TCP and Unix listeners are served together
+	stop, ready := startTestServer(t, "127.0.0.1:0, unix:"+path)
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server never reported ready")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected socket mode 0600, got %#o", info.Mode().Perm())
+	}
+
+	resp, err := unixClient(path).Get("http://gohome/health")
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if err := stop(); err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestStartServerRefusesSocketPath(t *testing.T) {
+	dir := t.TempDir()
+
+	regular := filepath.Join(dir, "data.db")
+	if err := os.WriteFile(regular, []byte("keep me"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	live := filepath.Join(dir, "live.sock")
+	listener, err := net.Listen("unix", live)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"Regular file", regular, "is not a socket"},
+		{"Socket in use", live, "in use"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stop, ready := startTestServer(t, "unix:"+tc.path)
+			err := stop()
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+			select {
+			case <-ready:
+				t.Error("Expected a server that failed to listen never to report ready")
+			default:
+			}
+			if _, statErr := os.Lstat(tc.path); statErr != nil {
+				t.Errorf("Expected %s to be left in place, got %v", tc.path, statErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// formatTTL renders an alarm TTL, with zero meaning the alarm never expires
+func formatTTL(ttl time.Duration) string {
+	if ttl <= 0 {
+		return "never"
+	}
+	return ttl.String()
+}
+
+// getAlarmTTLs handles GET /api/settings/alarm-ttls, giving the TTL of every alarm level
+func (h *Handler) getAlarmTTLs(c *gin.Context) {
+	ttls := gin.H{}
+	for _, level := range h.alarmLevels.IDs() {
+		ttls[level] = formatTTL(h.config.AlarmTTL(level))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alarm_ttls":        ttls,
+		"sweep_interval":    h.config.AlarmSweepInterval.String(),
+		"skip_acknowledged": h.config.AlarmSweepSkipAcknowledged,
+	})
+}
+
+// getAlarmEscalation handles GET /api/settings/alarm-escalation
+func (h *Handler) getAlarmEscalation(c *gin.Context) {
+	rules := gin.H{}
+	for level, rule := range h.config.AlarmEscalationRules() {
+		rules[level] = gin.H{
+			"delay":    rule.Delay.String(),
+			"target":   rule.Target,
+			"renotify": rule.Renotify,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":    rules,
+		"interval": h.config.AlarmEscalationInterval.String(),
+	})
+}
+
+// getAlarmPresence handles GET /api/settings/alarm-presence, giving the alarm presence mode of
+// every device type
+func (h *Handler) getAlarmPresence(c *gin.Context) {
+	modes := gin.H{}
+	for _, info := range models.GetAllDeviceTypes() {
+		modes[info.ID] = h.config.AlarmPresenceByType.For(models.DeviceType(info.ID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alarm_presence": modes})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+const (
+	// ndjsonContentType is the media type of newline-delimited JSON responses
+	ndjsonContentType = "application/x-ndjson"
+	// streamFlushEvery is how many devices are written between flushes of a streamed response
+	streamFlushEvery = 100
+)
+
+// streamDevices writes every device matching opts as newline-delimited JSON, one object per line,
+// without loading the list into memory. Objects are limited to opts.Fields, timestamps are also
+// rendered in loc when it is set, and pagination is ignored. If reading fails part way, an {"error": ...} line is written and the connection is
+// dropped so the client cannot mistake the truncated stream for a complete one.
+func (h *Handler) streamDevices(c *gin.Context, opts *models.DeviceListOptions, loc *time.Location) {
+	w := unbufferedWriter(c)
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	projection := newDeviceProjection(opts.Fields)
+	written := 0
+	err := h.deviceService.StreamDevices(c.Request.Context(), opts, func(device *models.Device) error {
+		var row interface{} = device
+		if loc != nil {
+			row = localizeDevice(device, loc)
+		}
+		row, err := projection.apply(row)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+		written++
+		if written%streamFlushEvery == 0 {
+			w.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		w.Flush()
+		return
+	}
+
+	// A client that went away has nobody left to tell
+	if c.Request.Context().Err() != nil {
+		return
+	}
+
+	log.Printf("Error streaming devices after %d rows: %v", written, err)
+	if encodeErr := encoder.Encode(gin.H{"error": err.Error()}); encodeErr != nil {
+		log.Printf("Error writing stream trailer: %v", encodeErr)
+	}
+	w.Flush()
+	dropConnection(w)
+}
+
+// dropConnection ends a streamed response abnormally, so a client cannot mistake what it has
+// read so far for the whole response. Hijacking skips the final empty chunk.
+func dropConnection(w gin.ResponseWriter) {
+	if conn, _, err := w.Hijack(); err == nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			log.Printf("Error closing connection: %v", closeErr)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// streamingService returns a mock that streams count devices and then fails with failErr, if set
+func streamingService(count int, failErr error, gotOpts **models.DeviceListOptions) *MockDeviceService {
+	return &MockDeviceService{
+		listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+			return nil, errors.New("list should not be used when streaming")
+		},
+		streamFunc: func(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+			if gotOpts != nil {
+				*gotOpts = opts
+			}
+			for i := 1; i <= count; i++ {
+				if err := fn(testutil.NewDevice().WithID(int64(i)).WithName("Device").Build()); err != nil {
+					return err
+				}
+			}
+			return failErr
+		},
+	}
+}
+
+func TestStreamDevices(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		gzip   bool
+	}{
+		{"Stream query parameter", "?stream=true", "", false},
+		{"NDJSON Accept header", "", "application/x-ndjson", false},
+		{"Not buffered by gzip", "?stream=true", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			cfg := testutil.NewConfig()
+			cfg.GzipEnabled = tc.gzip
+			router := newTestServer(streamingService(250, nil, &gotOpts), cfg)
+
+			req, _ := http.NewRequest("GET", "/api/devices"+tc.query, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			req.Header.Set("Accept-Encoding", "gzip")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if got := recorder.Header().Get("Content-Type"); got != ndjsonContentType {
+				t.Errorf("Expected Content-Type %s, got %s", ndjsonContentType, got)
+			}
+			if recorder.Header().Get("Content-Encoding") != "" {
+				t.Errorf("Expected streamed response not to be compressed")
+			}
+
+			lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+			if len(lines) != 250 {
+				t.Fatalf("Expected 250 lines, got %d", len(lines))
+			}
+			var device models.Device
+			if err := json.Unmarshal([]byte(lines[249]), &device); err != nil || device.ID != 250 {
+				t.Errorf("Expected the last line to be device 250, got %q (%v)", lines[249], err)
+			}
+			if gotOpts.SortBy != "created_at" {
+				t.Errorf("Expected the default sort to apply, got %q", gotOpts.SortBy)
+			}
+		})
+	}
+}
+
+func TestStreamDevicesErrorMidStream(t *testing.T) {
+	server := httptest.NewServer(newTestServer(streamingService(3, errors.New("disk I/O error"), nil), testutil.NewConfig()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/devices?stream=true")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if len(lines) != 4 {
+		t.Fatalf("Expected 3 devices and a trailer, got %d lines: %v", len(lines), lines)
+	}
+	var trailer map[string]string
+	if err := json.Unmarshal([]byte(lines[3]), &trailer); err != nil || trailer["error"] != "disk I/O error" {
+		t.Errorf("Expected an error trailer, got %q", lines[3])
+	}
+
+	// The connection is dropped rather than ending the chunked body cleanly
+	if err := scanner.Err(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected the stream to end with an unexpected EOF, got %v", err)
+	}
+}
+
+func TestStreamDevicesInvalidParameter(t *testing.T) {
+	router := newTestServer(streamingService(0, nil, nil), testutil.NewConfig())
+
+	req, _ := http.NewRequest("GET", "/api/devices?stream=maybe", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindStrictJSON decodes the JSON request body into obj like ShouldBindJSON, including its binding
+// rules, but rejects unknown fields and nesting deeper than the configured maximum. Errors are
+// worded for the client.
+func (h *Handler) bindStrictJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("request body is empty")
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return fmt.Errorf("reading request body: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errors.New("request body is empty")
+	}
+
+	if err := decodeStrictJSON(body, obj, h.config.JSONMaxDepth); err != nil {
+		return err
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// decodeStrictJSON decodes a single JSON value from data into obj, rejecting unknown fields,
+// trailing data, and nesting deeper than maxDepth objects and arrays. A maxDepth of zero
+// allows any depth.
+func decodeStrictJSON(data []byte, obj interface{}, maxDepth int) error {
+	// Depth is checked on the tokens before decoding, so deep input is never built into values
+	if maxDepth > 0 {
+		if err := checkJSONDepth(data, maxDepth); err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		// encoding/json reports unknown fields as `json: unknown field "x"`
+		return fmt.Errorf("invalid JSON: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid JSON: unexpected data after the top-level value")
+	}
+
+	return nil
+}
+
+// checkJSONDepth fails when data nests objects and arrays more than maxDepth deep
+func checkJSONDepth(data []byte, maxDepth int) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %s", strings.TrimPrefix(err.Error(), "json: "))
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("invalid JSON: nested more than %d levels deep", maxDepth)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestStrictJSONBodies(t *testing.T) {
+	deep := `{"name":"Hall","device_type":"CAMERA","owned_by":"owner1","extra":` + strings.Repeat("[", 40) + strings.Repeat("]", 40) + `}`
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+		expectedError string
+	}{
+		{"Valid create", "POST", "/api/devices", `{"name":"Hall","device_type":"CAMERA","owned_by":"owner1"}`, http.StatusCreated, ""},
+		{"Unknown create field", "POST", "/api/devices", `{"name":"Hall","device_type":"CAMERA","owned_by":"owner1","colour":"red"}`, http.StatusBadRequest, `unknown field \"colour\"`},
+		{"Deeply nested create", "POST", "/api/devices", deep, http.StatusBadRequest, "nested more than 32 levels deep"},
+		{"Trailing data", "POST", "/api/devices", `{"name":"Hall","device_type":"CAMERA","owned_by":"owner1"} {}`, http.StatusBadRequest, "unexpected data after the top-level value"},
+		{"Empty body", "POST", "/api/devices", ``, http.StatusBadRequest, "request body is empty"},
+		{"Malformed", "POST", "/api/devices", `{"name":`, http.StatusBadRequest, "invalid JSON"},
+		{"Missing required field", "POST", "/api/devices", `{"name":"Hall","device_type":"CAMERA"}`, http.StatusBadRequest, "OwnedBy"},
+		{"Valid update", "PUT", "/api/devices/1", `{"name":"Hall"}`, http.StatusNoContent, ""},
+		{"Unknown update field", "PUT", "/api/devices/1", `{"nmae":"Hall"}`, http.StatusBadRequest, `unknown field \"nmae\"`},
+		{"Valid alarm", "POST", "/api/devices/1/alarm", `{"reason":"Smoke","level":"WARNING"}`, http.StatusNoContent, ""},
+		{"Unknown alarm field", "POST", "/api/devices/1/alarm", `{"reason":"Smoke","level":"WARNING","severity":9}`, http.StatusBadRequest, `unknown field \"severity\"`},
+		{"Unknown type alarm field", "POST", "/api/device-types/CAMERA/alarm", `{"reason":"Smoke","level":"WARNING","severity":9}`, http.StatusBadRequest, `unknown field \"severity\"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := &MockDeviceService{
+				createFunc: func(device *models.DeviceCreate) (*models.Device, error) {
+					return testutil.NewDevice().WithName(device.Name).Build(), nil
+				},
+				updateFunc:       func(id int64, device *models.DeviceUpdate) error { return nil },
+				getByIDFunc:      func(id int64) (*models.Device, error) { return testutil.NewDevice().WithID(id).Build(), nil },
+				triggerAlarmFunc: func(id int64, alarm *models.AlarmRequest) error { return nil },
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedError != "" && !strings.Contains(recorder.Body.String(), tc.expectedError) {
+				t.Errorf("Expected the error to mention %q, got %s", tc.expectedError, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestDecodeStrictJSONDepth(t *testing.T) {
+	var value map[string]interface{}
+	nested := `{"a":{"b":{"c":[1]}}}`
+
+	if err := decodeStrictJSON([]byte(nested), &value, 4); err != nil {
+		t.Errorf("Expected four levels to be allowed, got %v", err)
+	}
+	if err := decodeStrictJSON([]byte(nested), &value, 3); err == nil {
+		t.Error("Expected four levels to exceed a maximum of three")
+	}
+	if err := decodeStrictJSON([]byte(nested), &value, 0); err != nil {
+		t.Errorf("Expected no limit with a maximum of zero, got %v", err)
+	}
+}
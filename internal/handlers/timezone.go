@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// localTimeLayout is the human-readable layout of timestamps rendered in a display timezone
+const localTimeLayout = "Mon 2 Jan 2006 15:04:05 MST"
+
+// localTimes renders a device's timestamps in a display timezone
+type localTimes struct {
+	Timezone         string `json:"timezone"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+	LastAlarmTime    string `json:"last_alarm_time,omitempty"`
+	MaintenanceUntil string `json:"maintenance_until,omitempty"`
+	LastSeenAt       string `json:"last_seen_at,omitempty"`
+}
+
+// localizedDevice is a device response with its timestamps also rendered in a display timezone.
+// The device's own timestamps stay in UTC.
+type localizedDevice struct {
+	*models.Device
+	Local *localTimes `json:"local"`
+}
+
+// displayLocation resolves the timezone requested with ?tz, falling back to the configured
+// DisplayTimezone. A nil location means no local rendering was asked for. On an unknown
+// timezone it writes a 400 response and returns false.
+func (h *Handler) displayLocation(c *gin.Context) (*time.Location, bool) {
+	name := c.DefaultQuery("tz", h.config.DisplayTimezone)
+	if name == "" {
+		return nil, true
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "tz must be an IANA timezone such as Europe/London")
+		return nil, false
+	}
+
+	return loc, true
+}
+
+// formatLocal renders t in loc, leaving unset times empty
+func formatLocal(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format(localTimeLayout)
+}
+
+// localizeDevice adds a rendering of the device's timestamps in loc
+func localizeDevice(device *models.Device, loc *time.Location) *localizedDevice {
+	return &localizedDevice{
+		Device: device,
+		Local: &localTimes{
+			Timezone:         loc.String(),
+			CreatedAt:        formatLocal(device.CreatedAt, loc),
+			UpdatedAt:        formatLocal(device.UpdatedAt, loc),
+			LastAlarmTime:    formatLocal(device.LastAlarmTime, loc),
+			MaintenanceUntil: formatLocal(device.MaintenanceUntil, loc),
+			LastSeenAt:       formatLocal(device.LastSeenAt, loc),
+		},
+	}
+}
+
+// localizeDevices adds a rendering of each device's timestamps in loc
+func localizeDevices(devices []*models.Device, loc *time.Location) []*localizedDevice {
+	localized := make([]*localizedDevice, 0, len(devices))
+	for _, device := range devices {
+		localized = append(localized, localizeDevice(device, loc))
+	}
+	return localized
+}
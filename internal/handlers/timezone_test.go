@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestDeviceTimezoneRendering(t *testing.T) {
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	mockSvc := &MockDeviceService{
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			return testutil.NewDevice().WithID(id).WithCreatedAt(created).Build(), nil
+		},
+		getByNameFunc: func(owner, name string) (*models.Device, error) {
+			return testutil.NewDevice().WithOwner(owner).WithName(name).WithCreatedAt(created).Build(), nil
+		},
+		listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+			return []*models.Device{testutil.NewDevice().WithCreatedAt(created).Build()}, nil
+		},
+		streamFunc: func(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+			return fn(testutil.NewDevice().WithCreatedAt(created).Build())
+		},
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		defaultTimezone string
+		expectedStatus  int
+		expectedLocal   string
+	}{
+		{"UTC only by default", "/api/devices/1", "", http.StatusOK, ""},
+		{"Requested timezone", "/api/devices/1?tz=Europe/London", "", http.StatusOK, "Mon 1 Jul 2024 13:00:00 BST"},
+		{"Configured default", "/api/devices/1", "America/New_York", http.StatusOK, "Mon 1 Jul 2024 08:00:00 EDT"},
+		{"Request overrides default", "/api/devices/1?tz=UTC", "America/New_York", http.StatusOK, "Mon 1 Jul 2024 12:00:00 UTC"},
+		{"List", "/api/devices?tz=Asia/Tokyo", "", http.StatusOK, "Mon 1 Jul 2024 21:00:00 JST"},
+		{"Stream", "/api/devices?stream=true&tz=Asia/Tokyo", "", http.StatusOK, "Mon 1 Jul 2024 21:00:00 JST"},
+		{"By name", "/api/devices/by-name?owner=alice&name=Lamp&tz=Europe/London", "", http.StatusOK, "Mon 1 Jul 2024 13:00:00 BST"},
+		{"By name with configured default", "/api/devices/by-name?owner=alice&name=Lamp", "America/New_York", http.StatusOK, "Mon 1 Jul 2024 08:00:00 EDT"},
+		{"Unknown timezone", "/api/devices/1?tz=Mars/Olympus", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testutil.NewConfig()
+			cfg.DisplayTimezone = tc.defaultTimezone
+			router := newTestServer(mockSvc, cfg)
+
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			body := w.Body.Bytes()
+			if body[0] == '[' {
+				body = body[1 : len(body)-1]
+			}
+			var response struct {
+				CreatedAt time.Time   `json:"created_at"`
+				Local     *localTimes `json:"local"`
+			}
+			if err := json.Unmarshal(body, &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if !response.CreatedAt.Equal(created) || response.CreatedAt.Location() != time.UTC {
+				t.Errorf("Expected created_at to stay in UTC, got %v", response.CreatedAt)
+			}
+			if tc.expectedLocal == "" {
+				if response.Local != nil {
+					t.Errorf("Expected no local rendering, got %+v", response.Local)
+				}
+				return
+			}
+			if response.Local == nil || response.Local.CreatedAt != tc.expectedLocal || response.Local.LastAlarmTime != "" {
+				t.Errorf("Expected local created_at %q, got %+v", tc.expectedLocal, response.Local)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+const (
+	// defaultAlarmedWithin is how far back the recently alarmed view looks when no ?within is given
+	defaultAlarmedWithin = 24 * time.Hour
+	// defaultStaleOlderThan is how long a device must be quiet to appear in the stale view when no
+	// ?olderThan is given
+	defaultStaleOlderThan = 7 * 24 * time.Hour
+	// defaultRecentLimit is how many devices the recently updated view lists when no ?limit is given
+	defaultRecentLimit = 10
+)
+
+// getRecentlyAlarmedDevices handles GET /api/devices/recently-alarmed, listing devices whose
+// last alarm was within the ?within duration, most recent first
+func (h *Handler) getRecentlyAlarmedDevices(c *gin.Context) {
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+	within, ok := parseDurationQuery(c, "within", defaultAlarmedWithin, h.config.MaxViewWindow)
+	if !ok {
+		return
+	}
+
+	h.listDeviceView(c, &models.DeviceListOptions{
+		Limit:        page.Limit,
+		Offset:       page.Offset,
+		SortBy:       "last_alarm_time",
+		SortOrder:    models.SortDesc,
+		AlarmedSince: time.Now().Add(-within),
+	})
+}
+
+// getStaleDevices handles GET /api/devices/stale, listing devices neither updated nor seen for
+// longer than the ?olderThan duration, which are likely dead. The quietest are listed first.
+func (h *Handler) getStaleDevices(c *gin.Context) {
+	page, ok := h.parsePage(c)
+	if !ok {
+		return
+	}
+	olderThan, ok := parseDurationQuery(c, "olderThan", defaultStaleOlderThan, h.config.MaxViewWindow)
+	if !ok {
+		return
+	}
+
+	h.listDeviceView(c, &models.DeviceListOptions{
+		Limit:         page.Limit,
+		Offset:        page.Offset,
+		SortBy:        "updated_at",
+		SortOrder:     models.SortAsc,
+		InactiveSince: time.Now().Add(-olderThan),
+	})
+}
+
+// getRecentDevices handles GET /api/devices/recent, listing the devices changed most recently,
+// newest first. The ?limit is clamped to MaxRecentDevices as list limits are to MaxPageSize.
+func (h *Handler) getRecentDevices(c *gin.Context) {
+	maxLimit := h.config.MaxPageSize
+	if h.config.MaxRecentDevices > 0 && h.config.MaxRecentDevices < maxLimit {
+		maxLimit = h.config.MaxRecentDevices
+	}
+	limit, _, ok := parseLimit(c, min(defaultRecentLimit, maxLimit), maxLimit)
+	if !ok {
+		return
+	}
+
+	devices, err := h.deviceService.GetRecentDevices(limit)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if devices == nil {
+		devices = []*models.Device{}
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+// listDeviceView writes a page of a filtered device view in the shape of GET /api/devices.
+// Archived devices are left out: a sensor put away for the season is neither stale nor news.
+func (h *Handler) listDeviceView(c *gin.Context, opts *models.DeviceListOptions) {
+	opts.ExcludeArchived = true
+	devices, err := h.deviceService.ListDevices(opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if devices == nil {
+		devices = []*models.Device{}
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
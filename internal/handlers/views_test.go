@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestDeviceViews(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedAge  time.Duration
+		inactive     bool
+	}{
+		{"Recently alarmed default", "/api/devices/recently-alarmed", http.StatusOK, 24 * time.Hour, false},
+		{"Recently alarmed within", "/api/devices/recently-alarmed?within=2h&limit=5", http.StatusOK, 2 * time.Hour, false},
+		{"Stale default", "/api/devices/stale", http.StatusOK, 168 * time.Hour, true},
+		{"Stale older than", "/api/devices/stale?olderThan=720h", http.StatusOK, 720 * time.Hour, true},
+		{"Unparseable duration", "/api/devices/recently-alarmed?within=yesterday", http.StatusBadRequest, 0, false},
+		{"Negative duration", "/api/devices/stale?olderThan=-1h", http.StatusBadRequest, 0, false},
+		{"Duration over the maximum", "/api/devices/stale?olderThan=2161h", http.StatusBadRequest, 0, false},
+		{"Invalid page", "/api/devices/stale?limit=0", http.StatusBadRequest, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOpts *models.DeviceListOptions
+			mockSvc := &MockDeviceService{
+				listFunc: func(opts *models.DeviceListOptions) ([]*models.Device, error) {
+					gotOpts = opts
+					return nil, nil
+				},
+			}
+			router := newTestServer(mockSvc, testutil.NewConfig())
+
+			req, _ := http.NewRequest("GET", tc.query, nil)
+			recorder := httptest.NewRecorder()
+			before := time.Now()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if recorder.Body.String() != "[]" {
+				t.Errorf("Expected an empty list, got %s", recorder.Body.String())
+			}
+			if recorder.Header().Get("X-Page-Limit") == "" {
+				t.Error("Expected pagination headers")
+			}
+
+			cutoff := gotOpts.AlarmedSince
+			if tc.inactive {
+				cutoff = gotOpts.InactiveSince
+			}
+			if age := before.Sub(cutoff); age < tc.expectedAge-time.Second || age > tc.expectedAge+time.Second {
+				t.Errorf("Expected a cutoff %s ago, got %s", tc.expectedAge, age)
+			}
+		})
+	}
+}
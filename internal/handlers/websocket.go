@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/validation"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxFrameBytes caps the size of a single frame received from a device
+	maxFrameBytes = 64 << 10
+	// frameWriteTimeout bounds how long a send may block on a slow device
+	frameWriteTimeout = 10 * time.Second
+)
+
+// deviceConnections tracks the open WebSocket connection of each device.
+// A device has at most one connection; a reconnect replaces the previous one.
+type deviceConnections struct {
+	mu    sync.Mutex
+	conns map[int64]*websocket.Conn
+}
+
+func newDeviceConnections() *deviceConnections {
+	return &deviceConnections{conns: make(map[int64]*websocket.Conn)}
+}
+
+// add registers ws as the connection for a device, closing any connection it replaces
+func (d *deviceConnections) add(id int64, ws *websocket.Conn) {
+	d.mu.Lock()
+	previous := d.conns[id]
+	d.conns[id] = ws
+	d.mu.Unlock()
+
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			log.Printf("Error closing replaced connection for device %d: %v", id, err)
+		}
+	}
+}
+
+// remove unregisters ws, reporting false if it had already been replaced by a newer connection
+func (d *deviceConnections) remove(id int64, ws *websocket.Conn) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conns[id] != ws {
+		return false
+	}
+	delete(d.conns, id)
+	return true
+}
+
+// get returns the open connection of a device, or nil when it is not connected
+func (d *deviceConnections) get(id int64) *websocket.Conn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.conns[id]
+}
+
+// sendFrame writes a single frame to a device connection
+func sendFrame(ws *websocket.Conn, frame *models.DeviceFrame) error {
+	if err := ws.SetWriteDeadline(time.Now().Add(frameWriteTimeout)); err != nil {
+		return err
+	}
+	return websocket.JSON.Send(ws, frame)
+}
+
+// deviceWebSocket handles GET /api/devices/:id/ws
+func (h *Handler) deviceWebSocket(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	// Reject unknown devices before upgrading so the client gets a plain HTTP error
+	device, err := h.deviceService.GetDeviceByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if device == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if device.Archived {
+		respondArchived(c, fmt.Errorf("%w with ID: %d", models.ErrDeviceArchived, id))
+		return
+	}
+
+	server := websocket.Server{
+		// Devices are not browsers and usually send no Origin header, so skip the origin check
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			h.serveDeviceConn(id, ws)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveDeviceConn reads frames from a connected device until it disconnects or goes quiet.
+// The device is marked online while connected. Once its connection ends it is marked offline,
+// straight away or, when its type is debounced, after it has been quiet for a while.
+func (h *Handler) serveDeviceConn(id int64, ws *websocket.Conn) {
+	ws.MaxPayloadBytes = maxFrameBytes
+
+	h.conns.add(id, ws)
+	h.setDeviceConnected(id, true)
+	h.recordSeen(id)
+	h.deliverPendingCommands(id, ws)
+
+	defer func() {
+		// A replaced connection must not mark the device offline under its successor
+		if h.conns.remove(id, ws) {
+			h.setDeviceConnected(id, false)
+		}
+		if err := ws.Close(); err != nil {
+			log.Printf("Error closing connection for device %d: %v", id, err)
+		}
+	}()
+
+	for {
+		if timeout := h.config.WSHeartbeatTimeout; timeout > 0 {
+			if err := ws.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+				return
+			}
+		}
+
+		var frame models.DeviceFrame
+		if err := websocket.JSON.Receive(ws, &frame); err != nil {
+			if errors.Is(err, websocket.ErrFrameTooLarge) {
+				log.Printf("Device %d sent a frame over %d bytes, closing connection", id, maxFrameBytes)
+			} else if !errors.Is(err, io.EOF) {
+				log.Printf("Closing connection for device %d: %v", id, err)
+			}
+			return
+		}
+
+		// A device archived while connected is told so and disconnected
+		if err := h.recordSeen(id); errors.Is(err, models.ErrDeviceArchived) {
+			if err := sendFrame(ws, archivedFrame(frame.Type, err)); err != nil {
+				log.Printf("Error replying to device %d: %v", id, err)
+			}
+			return
+		}
+		reply := h.handleDeviceFrame(id, &frame)
+		if err := sendFrame(ws, reply); err != nil {
+			log.Printf("Error replying to device %d: %v", id, err)
+			return
+		}
+	}
+}
+
+// handleDeviceFrame applies a frame received from a device and returns the reply to send back
+func (h *Handler) handleDeviceFrame(id int64, frame *models.DeviceFrame) *models.DeviceFrame {
+	switch frame.Type {
+	case models.FrameTypeHeartbeat:
+		// The read deadline has already been extended; nothing else to record
+
+	case models.FrameTypeStatus:
+		if frame.IsOnline == nil {
+			return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type, Error: "is_online is required"}
+		}
+		if _, err := h.deviceService.UpdateDevice(id, &models.DeviceUpdate{IsOnline: frame.IsOnline}); err != nil {
+			return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type, Error: err.Error()}
+		}
+
+	case models.FrameTypeAlarm:
+		alarm := models.AlarmRequest{Level: frame.Level, Reason: frame.Reason, ReasonCode: frame.ReasonCode, TriggeredBy: frame.TriggeredBy}
+		if result := validation.ValidateAlarmRequest(&alarm, h.alarmLevels); !result.Valid() {
+			return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type, Errors: result.Errors}
+		}
+		if err := h.deviceService.TriggerAlarm(id, &alarm); err != nil {
+			if errors.Is(err, models.ErrDeviceArchived) {
+				return archivedFrame(frame.Type, err)
+			}
+			if refused := validation.ReasonCodeRefused(err); refused != nil {
+				return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type, Errors: refused.Errors}
+			}
+			return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type, Error: err.Error()}
+		}
+
+	case models.FrameTypeCommandAck:
+		if err := h.commandService.AckCommand(id, frame.CommandID); err != nil {
+			return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type, CommandID: frame.CommandID, Error: err.Error()}
+		}
+		return &models.DeviceFrame{Type: models.FrameTypeAck, Ref: frame.Type, CommandID: frame.CommandID}
+
+	default:
+		return &models.DeviceFrame{Type: models.FrameTypeError, Ref: frame.Type,
+			Error: "type must be one of: heartbeat, status, alarm, command_ack"}
+	}
+
+	return &models.DeviceFrame{Type: models.FrameTypeAck, Ref: frame.Type}
+}
+
+// deliverPendingCommands pushes the commands queued while a device was disconnected
+func (h *Handler) deliverPendingCommands(id int64, ws *websocket.Conn) {
+	commands, err := h.commandService.PendingCommands(id)
+	if err != nil {
+		log.Printf("Error loading pending commands for device %d: %v", id, err)
+		return
+	}
+
+	for _, command := range commands {
+		if err := h.pushCommand(ws, command); err != nil {
+			log.Printf("Error delivering command %d to device %d: %v", command.ID, id, err)
+			return
+		}
+	}
+}
+
+// pushCommand sends a queued command over a device connection and records it as delivered.
+// Delivery is at-least-once; devices acknowledge by command ID and may see a command twice.
+func (h *Handler) pushCommand(ws *websocket.Conn, command *models.DeviceCommand) error {
+	frame := models.DeviceFrame{Type: models.FrameTypeCommand, CommandID: command.ID, Command: command.Command, Payload: command.Payload}
+	if err := sendFrame(ws, &frame); err != nil {
+		return err
+	}
+
+	if err := h.commandService.MarkDelivered(command.DeviceID, command.ID); err != nil {
+		return err
+	}
+	command.Status = models.CommandStatusDelivered
+	command.DeliveredAt = time.Now().UTC().Truncate(time.Second)
+
+	return nil
+}
+
+// setDeviceConnected records a device's connection state, logging rather than failing on error
+func (h *Handler) setDeviceConnected(id int64, connected bool) {
+	if err := h.deviceService.SetDeviceConnected(id, connected); err != nil {
+		log.Printf("Error setting device %d connected=%t: %v", id, connected, err)
+	}
+}
+
+// recordSeen updates when a device was last heard from. A failure is logged and returned; only
+// the device having been archived costs it its connection.
+func (h *Handler) recordSeen(id int64) error {
+	err := h.deviceService.RecordDeviceSeen(id)
+	if err != nil {
+		log.Printf("Error recording device %d as seen: %v", id, err)
+	}
+	return err
+}
+
+// archivedFrame is the error frame refusing a frame from an archived device
+func archivedFrame(ref string, err error) *models.DeviceFrame {
+	return &models.DeviceFrame{Type: models.FrameTypeError, Ref: ref, Error: err.Error(), Code: codeDeviceArchived}
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"golang.org/x/net/websocket"
+)
+
+// onlineRecorder captures the is_online updates made through UpdateDevice
+type onlineRecorder struct {
+	mu      sync.Mutex
+	updates []bool
+	changed chan struct{}
+}
+
+func newOnlineRecorder() *onlineRecorder {
+	return &onlineRecorder{changed: make(chan struct{}, 10)}
+}
+
+func (r *onlineRecorder) update(id int64, device *models.DeviceUpdate) error {
+	if device.IsOnline != nil {
+		r.mu.Lock()
+		r.updates = append(r.updates, *device.IsOnline)
+		r.mu.Unlock()
+		r.changed <- struct{}{}
+	}
+	return nil
+}
+
+func (r *onlineRecorder) waitFor(t *testing.T, expected ...bool) {
+	t.Helper()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		r.mu.Lock()
+		got := append([]bool(nil), r.updates...)
+		r.mu.Unlock()
+		if len(got) >= len(expected) {
+			for i := range expected {
+				if got[i] != expected[i] {
+					t.Fatalf("Expected online updates %v, got %v", expected, got)
+				}
+			}
+			return
+		}
+
+		select {
+		case <-r.changed:
+		case <-deadline:
+			t.Fatalf("Timed out waiting for online updates %v, got %v", expected, got)
+		}
+	}
+}
+
+// dialDevice opens a WebSocket connection to the device endpoint of a test server
+func dialDevice(t *testing.T, server *httptest.Server, id string) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/devices/" + id + "/ws"
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+
+	return ws
+}
+
+func receiveFrame(t *testing.T, ws *websocket.Conn) models.DeviceFrame {
+	t.Helper()
+
+	if err := ws.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("Failed to set read deadline: %v", err)
+	}
+	var frame models.DeviceFrame
+	if err := websocket.JSON.Receive(ws, &frame); err != nil {
+		t.Fatalf("Failed to receive frame: %v", err)
+	}
+
+	return frame
+}
+
+// commandRecorder captures the command IDs marked delivered or acknowledged
+type commandRecorder struct {
+	mu        sync.Mutex
+	delivered []int64
+	acked     []int64
+}
+
+func (r *commandRecorder) service(pending ...*models.DeviceCommand) *MockCommandService {
+	return &MockCommandService{
+		enqueueFunc: func(deviceID int64, req *models.CommandRequest) (*models.DeviceCommand, error) {
+			return &models.DeviceCommand{ID: 6, DeviceID: deviceID, Command: req.Command, Payload: req.Payload, Status: models.CommandStatusPending}, nil
+		},
+		pendingFunc: func(deviceID int64) ([]*models.DeviceCommand, error) { return pending, nil },
+		deliveredFunc: func(deviceID, id int64) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.delivered = append(r.delivered, id)
+			return nil
+		},
+		ackFunc: func(deviceID, id int64) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.acked = append(r.acked, id)
+			return nil
+		},
+	}
+}
+
+func TestDeviceWebSocket(t *testing.T) {
+	online := newOnlineRecorder()
+	var gotAlarm *models.AlarmRequest
+	var seen atomic.Int32
+	mockSvc := &MockDeviceService{
+		seenFunc: func(id int64) error {
+			seen.Add(1)
+			return nil
+		},
+		getByIDFunc: func(id int64) (*models.Device, error) {
+			if id != 1 {
+				return nil, nil
+			}
+			return testutil.NewDevice().WithID(id).Build(), nil
+		},
+		updateFunc: online.update,
+		triggerAlarmFunc: func(id int64, alarm *models.AlarmRequest) error {
+			gotAlarm = alarm
+			return nil
+		},
+	}
+	commands := &commandRecorder{}
+	queued := &models.DeviceCommand{ID: 5, DeviceID: 1, Command: "reboot", Status: models.CommandStatusPending}
+	server := httptest.NewServer(newTestServerWithCommands(mockSvc, commands.service(queued), testutil.NewConfig()))
+	defer server.Close()
+
+	ws := dialDevice(t, server, "1")
+	online.waitFor(t, true)
+
+	t.Run("Queued commands delivered on connect", func(t *testing.T) {
+		frame := receiveFrame(t, ws)
+		if frame.Type != models.FrameTypeCommand || frame.CommandID != 5 || frame.Command != "reboot" {
+			t.Errorf("Expected queued command 5, got %+v", frame)
+		}
+	})
+
+	t.Run("Alarm frame", func(t *testing.T) {
+		frame := models.DeviceFrame{Type: models.FrameTypeAlarm, Level: "CRITICAL", Reason: "Smoke detected", TriggeredBy: "sensor:1"}
+		if err := websocket.JSON.Send(ws, frame); err != nil {
+			t.Fatalf("Failed to send frame: %v", err)
+		}
+
+		reply := receiveFrame(t, ws)
+		if reply.Type != models.FrameTypeAck || reply.Ref != models.FrameTypeAlarm {
+			t.Fatalf("Expected alarm ack, got %+v", reply)
+		}
+		if gotAlarm == nil || gotAlarm.Level != "CRITICAL" || gotAlarm.Reason != "Smoke detected" || gotAlarm.TriggeredBy != "sensor:1" {
+			t.Errorf("TriggerAlarm called with unexpected alarm: %+v", gotAlarm)
+		}
+	})
+
+	t.Run("Invalid alarm frame", func(t *testing.T) {
+		if err := websocket.JSON.Send(ws, models.DeviceFrame{Type: models.FrameTypeAlarm, Level: "LOUD"}); err != nil {
+			t.Fatalf("Failed to send frame: %v", err)
+		}
+
+		reply := receiveFrame(t, ws)
+		if reply.Type != models.FrameTypeError || reply.Errors["level"] == "" || reply.Errors["reason"] == "" {
+			t.Errorf("Expected validation errors, got %+v", reply)
+		}
+	})
+
+	t.Run("Unknown frame type", func(t *testing.T) {
+		if err := websocket.JSON.Send(ws, models.DeviceFrame{Type: "reboot"}); err != nil {
+			t.Fatalf("Failed to send frame: %v", err)
+		}
+
+		if reply := receiveFrame(t, ws); reply.Type != models.FrameTypeError || reply.Ref != "reboot" {
+			t.Errorf("Expected error frame, got %+v", reply)
+		}
+	})
+
+	t.Run("Enqueued command pushed to connected device", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", server.URL+"/api/devices/1/commands",
+			bytes.NewBufferString(`{"command": "silence", "payload": {"seconds": 30}}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Command request failed: %v", err)
+		}
+		var command models.DeviceCommand
+		if err := json.NewDecoder(resp.Body).Decode(&command); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+		if command.Status != models.CommandStatusDelivered {
+			t.Errorf("Expected the command to be reported as delivered, got %q", command.Status)
+		}
+
+		frame := receiveFrame(t, ws)
+		if frame.Type != models.FrameTypeCommand || frame.CommandID != 6 || frame.Command != "silence" || string(frame.Payload) != `{"seconds":30}` {
+			t.Errorf("Unexpected command frame: %+v (payload %s)", frame, frame.Payload)
+		}
+	})
+
+	t.Run("Command ack frame", func(t *testing.T) {
+		if err := websocket.JSON.Send(ws, models.DeviceFrame{Type: models.FrameTypeCommandAck, CommandID: 5}); err != nil {
+			t.Fatalf("Failed to send frame: %v", err)
+		}
+
+		reply := receiveFrame(t, ws)
+		if reply.Type != models.FrameTypeAck || reply.Ref != models.FrameTypeCommandAck || reply.CommandID != 5 {
+			t.Errorf("Expected command ack reply, got %+v", reply)
+		}
+	})
+
+	// Connecting and each of the four frames received count as seeing the device
+	if got := seen.Load(); got != 5 {
+		t.Errorf("Expected the device to be recorded as seen 5 times, got %d", got)
+	}
+
+	if err := ws.Close(); err != nil {
+		t.Fatalf("Failed to close WebSocket: %v", err)
+	}
+	online.waitFor(t, true, false)
+
+	commands.mu.Lock()
+	defer commands.mu.Unlock()
+	if len(commands.delivered) != 2 || commands.delivered[0] != 5 || commands.delivered[1] != 6 {
+		t.Errorf("Expected commands 5 and 6 to be marked delivered, got %v", commands.delivered)
+	}
+	if len(commands.acked) != 1 || commands.acked[0] != 5 {
+		t.Errorf("Expected command 5 to be acknowledged, got %v", commands.acked)
+	}
+}
+
+func TestDeviceWebSocketReconnectKeepsDeviceOnline(t *testing.T) {
+	online := newOnlineRecorder()
+	mockSvc := &MockDeviceService{
+		getByIDFunc: func(id int64) (*models.Device, error) { return testutil.NewDevice().WithID(id).Build(), nil },
+		updateFunc:  online.update,
+	}
+	server := httptest.NewServer(newTestServerWithCommands(mockSvc, (&commandRecorder{}).service(), testutil.NewConfig()))
+	defer server.Close()
+
+	first := dialDevice(t, server, "1")
+	defer first.Close()
+	online.waitFor(t, true)
+
+	second := dialDevice(t, server, "1")
+	defer second.Close()
+	online.waitFor(t, true, true)
+
+	// The replaced connection is closed by the server without marking the device offline
+	var frame models.DeviceFrame
+	if err := first.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("Failed to set read deadline: %v", err)
+	}
+	if err := websocket.JSON.Receive(first, &frame); err == nil {
+		t.Fatalf("Expected the replaced connection to be closed, got frame %+v", frame)
+	}
+
+	if err := websocket.JSON.Send(second, models.DeviceFrame{Type: models.FrameTypeHeartbeat}); err != nil {
+		t.Fatalf("Failed to send heartbeat: %v", err)
+	}
+	if reply := receiveFrame(t, second); reply.Type != models.FrameTypeAck {
+		t.Errorf("Expected heartbeat ack, got %+v", reply)
+	}
+
+	online.mu.Lock()
+	defer online.mu.Unlock()
+	for _, update := range online.updates {
+		if !update {
+			t.Errorf("Expected the device never to be marked offline, got updates %v", online.updates)
+		}
+	}
+}
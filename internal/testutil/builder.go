@@ -0,0 +1,132 @@
+// Package testutil provides the fixtures shared by tests: device builders with fixed defaults,
+// a controllable clock, and helpers that open and seed a test database. It does not import the
+// service or handlers packages, so their in-package tests can use it; package apitest wires the
+// whole HTTP stack on top.
+package testutil
+
+import (
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// Epoch is the fixed time fixtures are created at and clocks start from
+var Epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// DeviceBuilder builds devices for tests. Every device starts out the same: device 1, an offline
+// smoke detector named Device1 belonging to "owner", created at Epoch, with no alarm. Only the
+// fields a test cares about need setting.
+type DeviceBuilder struct {
+	device models.Device
+}
+
+// NewDevice starts building a device with the default fields
+func NewDevice() *DeviceBuilder {
+	return &DeviceBuilder{device: models.Device{
+		ID:            1,
+		Name:          "Device1",
+		DeviceType:    models.DeviceTypeSmokeDetector,
+		OwnedBy:       "owner",
+		NotifyOnAlarm: true,
+		Version:       1,
+		CreatedAt:     Epoch,
+		UpdatedAt:     Epoch,
+	}}
+}
+
+// WithID sets the device's ID
+func (b *DeviceBuilder) WithID(id int64) *DeviceBuilder {
+	b.device.ID = id
+	return b
+}
+
+// WithName sets the device's name
+func (b *DeviceBuilder) WithName(name string) *DeviceBuilder {
+	b.device.Name = name
+	return b
+}
+
+// WithType sets the device's type
+func (b *DeviceBuilder) WithType(deviceType models.DeviceType) *DeviceBuilder {
+	b.device.DeviceType = deviceType
+	return b
+}
+
+// WithOwner sets who owns the device
+func (b *DeviceBuilder) WithOwner(owner string) *DeviceBuilder {
+	b.device.OwnedBy = owner
+	return b
+}
+
+// WithDescription sets the device's description
+func (b *DeviceBuilder) WithDescription(description string) *DeviceBuilder {
+	b.device.Description = description
+	return b
+}
+
+// Online marks the device as connected
+func (b *DeviceBuilder) Online() *DeviceBuilder {
+	b.device.IsOnline = true
+	return b
+}
+
+// WithLastSeen sets when the device was last heard from
+func (b *DeviceBuilder) WithLastSeen(at time.Time) *DeviceBuilder {
+	b.device.LastSeenAt = at
+	return b
+}
+
+// WithAlarm gives the device an active alarm of level raised at at
+func (b *DeviceBuilder) WithAlarm(level, reason string, at time.Time) *DeviceBuilder {
+	b.device.AlarmActive = true
+	b.device.LastAlarmLevel = level
+	b.device.LastAlarmReason = reason
+	b.device.LastAlarmTime = at
+	return b
+}
+
+// WithAlarmCount sets the loaded number of alarms the device has had
+func (b *DeviceBuilder) WithAlarmCount(count int64) *DeviceBuilder {
+	b.device.AlarmCount = &count
+	return b
+}
+
+// WithCreatedAt sets when the device was created, and last updated
+func (b *DeviceBuilder) WithCreatedAt(at time.Time) *DeviceBuilder {
+	b.device.CreatedAt = at
+	b.device.UpdatedAt = at
+	return b
+}
+
+// WithUpdatedAt sets when the device was last updated
+func (b *DeviceBuilder) WithUpdatedAt(at time.Time) *DeviceBuilder {
+	b.device.UpdatedAt = at
+	return b
+}
+
+// WithVersion sets the device's version, as bumped by each update
+func (b *DeviceBuilder) WithVersion(version int64) *DeviceBuilder {
+	b.device.Version = version
+	return b
+}
+
+// Build returns the device. Each call returns a new copy, so a builder can be reused.
+func (b *DeviceBuilder) Build() *models.Device {
+	device := b.device
+	if device.AlarmCount != nil {
+		count := *device.AlarmCount
+		device.AlarmCount = &count
+	}
+	return &device
+}
+
+// BuildCreate returns the request that would create the device
+func (b *DeviceBuilder) BuildCreate() *models.DeviceCreate {
+	return &models.DeviceCreate{
+		Name:        b.device.Name,
+		Description: b.device.Description,
+		DeviceType:  b.device.DeviceType,
+		OwnedBy:     b.device.OwnedBy,
+		IsOnline:    b.device.IsOnline,
+	}
+}
@@ -0,0 +1,22 @@
+package testutil
+
+import (
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/config"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// NewConfig returns the settings tests start from: the defaults they rely on, without reading
+// the environment. Optional features stay off until a test turns them on.
+func NewConfig() *config.Config {
+	return &config.Config{
+		DefaultPageSize:        100,
+		MaxPageSize:            1000,
+		DefaultDeviceSortBy:    "created_at",
+		DefaultDeviceSortOrder: models.SortDesc,
+		MaxViewWindow:          90 * 24 * time.Hour,
+		MaxRecentDevices:       50,
+		JSONMaxDepth:           32,
+	}
+}
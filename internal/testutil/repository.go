@@ -0,0 +1,65 @@
+package testutil
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/database"
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// SeedOwners are the owners SeedDevices shares devices between
+var SeedOwners = []string{"alice", "bob", "carol"}
+
+// NewDB opens a migrated SQLite database in the test's temporary directory, closed when the test
+// ends. A file is used rather than :memory:, where each pooled connection would get its own
+// empty database.
+func NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close test database: %v", err)
+		}
+	})
+
+	return db
+}
+
+// NewDeviceRepository returns a device repository over a new test database
+func NewDeviceRepository(t testing.TB) repository.DeviceRepository {
+	t.Helper()
+	return repository.NewDeviceRepository(NewDB(t))
+}
+
+// SeedDevices creates n devices through repo, which may be a real repository or a test double,
+// and returns them as created. Device i, counting from 1, is named Device<i>; types go round
+// every device type and owners round SeedOwners, so the same n always gives the same devices.
+func SeedDevices(t testing.TB, repo repository.DeviceWriter, n int) []*models.Device {
+	t.Helper()
+
+	types := models.GetAllDeviceTypes()
+	devices := make([]*models.Device, 0, n)
+	for i := 0; i < n; i++ {
+		create := NewDevice().
+			WithName(fmt.Sprintf("Device%d", i+1)).
+			WithType(models.DeviceType(types[i%len(types)].ID)).
+			WithOwner(SeedOwners[i%len(SeedOwners)]).
+			BuildCreate()
+
+		device, err := repo.Create(create)
+		if err != nil {
+			t.Fatalf("Failed to seed device %s: %v", create.Name, err)
+		}
+		devices = append(devices, device)
+	}
+
+	return devices
+}
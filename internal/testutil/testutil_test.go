@@ -0,0 +1,60 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestDeviceBuilder(t *testing.T) {
+	builder := NewDevice().WithID(4).WithType(models.DeviceTypeLock).WithAlarmCount(2)
+
+	first := builder.Build()
+	if first.ID != 4 || first.DeviceType != models.DeviceTypeLock || first.OwnedBy != "owner" || !first.CreatedAt.Equal(Epoch) {
+		t.Errorf("Unexpected device %+v", first)
+	}
+
+	// Each build is independent of the others
+	*first.AlarmCount = 5
+	if second := builder.Build(); *second.AlarmCount != 2 {
+		t.Errorf("Expected a fresh alarm count of 2, got %d", *second.AlarmCount)
+	}
+}
+
+func TestSeedDevices(t *testing.T) {
+	repo := NewDeviceRepository(t)
+
+	devices := SeedDevices(t, repo, 4)
+	if len(devices) != 4 {
+		t.Fatalf("Expected 4 devices, got %d", len(devices))
+	}
+	if devices[0].Name != "Device1" || devices[0].OwnedBy != "alice" || devices[3].OwnedBy != "alice" || devices[1].OwnedBy != "bob" {
+		t.Errorf("Unexpected names or owners: %s/%s, %s, %s", devices[0].Name, devices[0].OwnedBy, devices[1].OwnedBy, devices[3].OwnedBy)
+	}
+	if devices[0].DeviceType == devices[1].DeviceType {
+		t.Errorf("Expected consecutive devices to have different types")
+	}
+
+	count, err := repo.Count(&models.DeviceListOptions{})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 stored devices, got %d", count)
+	}
+}
+
+func TestClock(t *testing.T) {
+	clock := NewClock(Epoch)
+
+	clock.Advance(time.Hour)
+	if got := clock.Now(); !got.Equal(Epoch.Add(time.Hour)) {
+		t.Errorf("Expected an hour after the epoch, got %s", got)
+	}
+
+	clock.Set(Epoch)
+	if got := clock.Now(); !got.Equal(Epoch) {
+		t.Errorf("Expected the epoch, got %s", got)
+	}
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reconnectBackoff is how long after a reconnect a query error may trigger another, so a
+// database that is really damaged is not reopened on every failing query
+const reconnectBackoff = 10 * time.Second
+
+// replacedFileErrors are the SQLite errors a pool gives when its file was replaced or restored
+// underneath it, as by a Litestream restore while the server runs
+var replacedFileErrors = []string{
+	"database disk image is malformed",
+	"file is not a database",
+	"attempt to write a readonly database",
+	"disk i/o error",
+	"unable to open database file",
+}
+
+// Manager holds the connection pool of a SQLite database file and replaces it when the file is
+// replaced while the server runs. An old pool keeps reading the deleted file, or fails on the
+// restored one, until it is reopened. Queries go through it like through *sql.DB.
+type Manager struct {
+	path string
+	opts []Option
+
+	mu sync.RWMutex
+	db *sql.DB
+	// file identifies the file db was opened on; nil when path is not a plain file path
+	file os.FileInfo
+
+	// reconnectMu serializes reconnects; lastReconnect is when the last one was attempted
+	reconnectMu   sync.Mutex
+	lastReconnect time.Time
+	// pending is set from the moment the pool is found broken until it has been reopened
+	pending atomic.Bool
+}
+
+// NewManager opens the database at path with NewSQLiteDB and opts, which reconnects reuse
+func NewManager(path string, opts ...Option) (*Manager, error) {
+	db, err := NewSQLiteDB(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{path: path, opts: opts, db: db, file: statFile(path)}, nil
+}
+
+// statFile returns the identity of the file at path, or nil when there is none, as for a DSN
+// with parameters or an in-memory database
+func statFile(path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return info
+}
+
+// DB returns the current pool. It is replaced on reconnect, so it must not be kept.
+func (m *Manager) DB() *sql.DB {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.db
+}
+
+// Reconnecting reports whether the pool is known to be broken and has not been reopened yet
+func (m *Manager) Reconnecting() bool {
+	return m.pending.Load()
+}
+
+// Reconnect closes the pool and opens a new one on the database file, bringing the schema of a
+// restored file up to date. Queries wait while the pools are swapped. When the file is missing,
+// as in the middle of a restore, or cannot be opened, the old pool is kept and an error returned.
+func (m *Manager) Reconnect() error {
+	m.reconnectMu.Lock()
+	defer m.reconnectMu.Unlock()
+	return m.reconnect()
+}
+
+func (m *Manager) reconnect() error {
+	m.pending.Store(true)
+	m.lastReconnect = time.Now()
+
+	m.mu.RLock()
+	watched := m.file != nil
+	m.mu.RUnlock()
+	// Opening a missing file would create an empty database in its place
+	if watched && statFile(m.path) == nil {
+		return fmt.Errorf("database file %s is missing", m.path)
+	}
+
+	db, err := NewSQLiteDB(m.path, m.opts...)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	old := m.db
+	m.db, m.file = db, statFile(m.path)
+	m.mu.Unlock()
+
+	// Close waits for the queries already running on the old pool
+	if err := old.Close(); err != nil {
+		log.Printf("Error closing replaced database pool: %v", err)
+	}
+	m.pending.Store(false)
+	log.Printf("Reconnected to the database")
+
+	return nil
+}
+
+// Check reopens the pool when the database file was replaced since it was opened, or when an
+// earlier reconnect is still pending. It waits for the file to come back when it is missing.
+func (m *Manager) Check() error {
+	m.mu.RLock()
+	file := m.file
+	m.mu.RUnlock()
+
+	replaced := false
+	if file != nil {
+		current := statFile(m.path)
+		if current == nil {
+			if !m.pending.Swap(true) {
+				log.Printf("Database file %s is missing; waiting for it to be restored", m.path)
+			}
+			return nil
+		}
+		replaced = !os.SameFile(file, current)
+	}
+	if !replaced && !m.pending.Load() {
+		return nil
+	}
+
+	if replaced {
+		log.Printf("Database file %s was replaced; reconnecting", m.path)
+	}
+	return m.Reconnect()
+}
+
+// Watch checks the database file on every interval until ctx is cancelled
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Check(); err != nil {
+				log.Printf("Error reconnecting to the database: %v", err)
+			}
+		}
+	}
+}
+
+// observe reconnects in the background when err says the file was replaced underneath the pool,
+// unless a reconnect is already running or was just attempted
+func (m *Manager) observe(err error) {
+	if err == nil || !isReplacedFileError(err) {
+		return
+	}
+	if !m.reconnectMu.TryLock() {
+		return
+	}
+	if time.Since(m.lastReconnect) < reconnectBackoff {
+		m.reconnectMu.Unlock()
+		return
+	}
+
+	log.Printf("Database query failed with %q; reconnecting", err)
+	go func() {
+		defer m.reconnectMu.Unlock()
+		if err := m.reconnect(); err != nil {
+			log.Printf("Error reconnecting to the database: %v", err)
+		}
+	}()
+}
+
+// isReplacedFileError reports whether err is one of replacedFileErrors
+func isReplacedFileError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, known := range replacedFileErrors {
+		if strings.Contains(message, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// Begin starts a transaction on the current pool
+func (m *Manager) Begin() (*sql.Tx, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tx, err := m.db.Begin()
+	m.observe(err)
+	return tx, err
+}
+
+// Exec runs a statement on the current pool
+func (m *Manager) Exec(query string, args ...interface{}) (sql.Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result, err := m.db.Exec(query, args...)
+	m.observe(err)
+	return result, err
+}
+
+// Query runs a query on the current pool
+func (m *Manager) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rows, err := m.db.Query(query, args...)
+	m.observe(err)
+	return rows, err
+}
+
+// QueryContext runs a query on the current pool
+func (m *Manager) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	m.observe(err)
+	return rows, err
+}
+
+// QueryRow runs a query expected to return at most one row on the current pool
+func (m *Manager) QueryRow(query string, args ...interface{}) *sql.Row {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	row := m.db.QueryRow(query, args...)
+	m.observe(row.Err())
+	return row
+}
+
+// QueryRowContext runs a query expected to return at most one row on the current pool
+func (m *Manager) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	row := m.db.QueryRowContext(ctx, query, args...)
+	m.observe(row.Err())
+	return row
+}
+
+// Close closes the current pool
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.Close()
+}
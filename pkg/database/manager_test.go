@@ -0,0 +1,106 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// seedOwner creates a database file at path holding one device owned by owner
+func seedOwner(t *testing.T, path, owner string) {
+	t.Helper()
+
+	db, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO devices (name, device_type, owned_by) VALUES ('Hall', 'LOCK', ?)`, owner); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+}
+
+// owners returns the owners of the devices m currently reads
+func owners(t *testing.T, m *Manager) string {
+	t.Helper()
+
+	var owner string
+	if err := m.QueryRow(`SELECT group_concat(owned_by) FROM devices`).Scan(&owner); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	return owner
+}
+
+func TestManager_ReopensReplacedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.db")
+	seedOwner(t, path, "alice")
+
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.Close()
+	if got := owners(t, m); got != "alice" {
+		t.Fatalf("Expected alice's device, got %q", got)
+	}
+
+	// A restore writes the new file elsewhere and renames it into place
+	restored := filepath.Join(dir, "restored.db")
+	seedOwner(t, restored, "bob")
+	if err := os.Rename(restored, path); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if got := owners(t, m); got != "alice" {
+		t.Fatalf("Expected the old pool to still read the deleted file, got %q", got)
+	}
+
+	if err := m.Check(); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if got := owners(t, m); got != "bob" || m.Reconnecting() {
+		t.Errorf("Expected the restored file read after reconnecting, got %q (reconnecting %t)", got, m.Reconnecting())
+	}
+}
+
+func TestManager_WaitsForMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	seedOwner(t, path, "alice")
+
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.Close()
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := m.Check(); err != nil || !m.Reconnecting() {
+		t.Fatalf("Expected to wait for the file, got %v (reconnecting %t)", err, m.Reconnecting())
+	}
+	if err := m.Reconnect(); err == nil {
+		t.Errorf("Expected reconnecting to a missing file to fail rather than create an empty one")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no file created in place of the missing one, got %v", err)
+	}
+
+	seedOwner(t, path, "carol")
+	if err := m.Check(); err != nil || m.Reconnecting() {
+		t.Fatalf("Expected to reconnect once the file is back, got %v (reconnecting %t)", err, m.Reconnecting())
+	}
+	if got := owners(t, m); got != "carol" {
+		t.Errorf("Expected the restored file read, got %q", got)
+	}
+}
+
+func TestIsReplacedFileError(t *testing.T) {
+	if !isReplacedFileError(errors.New("database disk image is malformed (11)")) {
+		t.Errorf("Expected a malformed image to point at a replaced file")
+	}
+	if isReplacedFileError(errors.New("UNIQUE constraint failed: devices.name")) {
+		t.Errorf("Expected a constraint failure not to point at a replaced file")
+	}
+}
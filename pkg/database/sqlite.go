@@ -2,12 +2,71 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
 	_ "github.com/glebarez/sqlite"
 )
 
+// timestampColumns lists every timestamp column by table. All of them hold UTC RFC 3339 text
+// such as 2024-05-01T12:00:00Z.
+var timestampColumns = map[string][]string{
+	"devices":           {"last_alarm_time", "maintenance_until", "alarm_acknowledged_at", "created_at", "updated_at"},
+	"incidents":         {"opened_at", "last_alarm_at", "resolved_at"},
+	"incident_alarms":   {"triggered_at"},
+	"alarm_history":     {"triggered_at"},
+	"device_commands":   {"created_at", "delivered_at", "acked_at"},
+	"device_presence":   {"last_seen_at"},
+	"alarm_reasons":     {"created_at", "updated_at"},
+	"alarm_events":      {"processed_at"},
+	"alarm_escalations": {"next_escalation_at"},
+	"device_changes":    {"changed_at"},
+}
+
+// SchemaVersion is recorded in the database's user_version once its schema is brought up to
+// date. Raise it with every change to the schema; opening a database recorded at an older
+// version also runs the one-off backfills.
+const SchemaVersion = 9
+
+// alarmActiveBackfillDays is how recent an existing alarm must be to be migrated as active
+const alarmActiveBackfillDays = 7
+
+// Option configures how NewSQLiteDB opens a database
+type Option func(*options)
+
+type options struct {
+	wal bool
+}
+
+// WithWAL opens the database in write-ahead logging mode with synchronous=NORMAL when enabled,
+// so reads no longer wait for a write in progress. WAL mode is recorded in the file, so leaving
+// it disabled does not switch a database that is already in WAL mode back.
+func WithWAL(enabled bool) Option {
+	return func(o *options) {
+		o.wal = enabled
+	}
+}
+
 // NewSQLiteDB creates and initializes a new SQLite database connection
-func NewSQLiteDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dbPath)
+func NewSQLiteDB(dbPath string, opts ...Option) (*sql.DB, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// synchronous applies per connection, so it is set through the DSN on every connection the
+	// pool opens rather than once
+	dsn := dbPath
+	if o.wal {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -17,10 +76,74 @@ func NewSQLiteDB(dbPath string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	if o.wal {
+		// In-memory databases, for one, cannot use WAL mode and keep their own
+		mode, _, err := JournalSettings(db)
+		if err != nil {
+			return nil, err
+		}
+		if mode != "wal" {
+			log.Printf("WAL mode was requested but the database uses journal_mode=%s", mode)
+		}
+	}
+
 	// Initialize database schema
 	if err := initSchema(db); err != nil {
 		return nil, err
 	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// AppliedSchemaVersion returns the schema version recorded in db, or 0 for a database whose
+// schema was never brought up to date
+func AppliedSchemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// synchronousModes names the values of PRAGMA synchronous
+var synchronousModes = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+
+// JournalSettings returns the journal mode of db, such as "delete" or "wal", and the
+// synchronous setting of one of its connections, such as "FULL" or "NORMAL"
+func JournalSettings(db *sql.DB) (string, string, error) {
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return "", "", err
+	}
+	var synchronous int
+	if err := db.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil {
+		return "", "", err
+	}
+	if synchronous < 0 || synchronous >= len(synchronousModes) {
+		return mode, fmt.Sprint(synchronous), nil
+	}
+	return mode, synchronousModes[synchronous], nil
+}
+
+// NewSQLiteReadDB opens a read-only connection to a replica of the database, such as one
+// maintained by Litestream or LiteFS. The schema is owned by the primary, so it is checked
+// rather than created; an error means the replica cannot serve reads yet.
+func NewSQLiteReadDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var found int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'devices'`).Scan(&found); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if found == 0 {
+		_ = db.Close()
+		return nil, fmt.Errorf("replica %s has no devices table", dsn)
+	}
 
 	return db, nil
 }
@@ -38,13 +161,396 @@ func initSchema(db *sql.DB) error {
 		is_online BOOLEAN DEFAULT FALSE,
 		last_alarm_reason TEXT,
 		last_alarm_time TIMESTAMP,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		last_alarm_triggered_by TEXT,
+		last_alarm_level TEXT,
+		alarm_active BOOLEAN DEFAULT FALSE,
+		last_alarm_suppressed BOOLEAN DEFAULT FALSE,
+		maintenance_mode BOOLEAN DEFAULT FALSE,
+		maintenance_until TIMESTAMP,
+		version INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
 	);`
 
 	if _, err := db.Exec(devicesTableDDL); err != nil {
 		return err
 	}
 
+	// Create incident tables
+	incidentsTableDDL := `
+	CREATE TABLE IF NOT EXISTS incidents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		level TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'open',
+		opened_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		last_alarm_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		resolved_at TIMESTAMP
+	);`
+
+	if _, err := db.Exec(incidentsTableDDL); err != nil {
+		return err
+	}
+
+	incidentAlarmsTableDDL := `
+	CREATE TABLE IF NOT EXISTS incident_alarms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		incident_id INTEGER NOT NULL REFERENCES incidents(id),
+		device_id INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		triggered_by TEXT,
+		triggered_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+	);
+	CREATE INDEX IF NOT EXISTS idx_incident_alarms_incident_id ON incident_alarms(incident_id);`
+
+	if _, err := db.Exec(incidentAlarmsTableDDL); err != nil {
+		return err
+	}
+
+	alarmHistoryTableDDL := `
+	CREATE TABLE IF NOT EXISTS alarm_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		device_id INTEGER NOT NULL,
+		level TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		triggered_by TEXT,
+		suppressed BOOLEAN DEFAULT FALSE,
+		triggered_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+	);
+	CREATE INDEX IF NOT EXISTS idx_alarm_history_device_triggered_at ON alarm_history(device_id, triggered_at);
+	CREATE INDEX IF NOT EXISTS idx_alarm_history_triggered_at ON alarm_history(triggered_at);`
+
+	if _, err := db.Exec(alarmHistoryTableDDL); err != nil {
+		return err
+	}
+
+	deviceCommandsTableDDL := `
+	CREATE TABLE IF NOT EXISTS device_commands (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		device_id INTEGER NOT NULL,
+		command TEXT NOT NULL,
+		payload TEXT,
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		delivered_at TIMESTAMP,
+		acked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_device_commands_device_status ON device_commands(device_id, status);`
+
+	if _, err := db.Exec(deviceCommandsTableDDL); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema, applied to existing databases
+	if _, err := addColumnIfMissing(db, "devices", "last_alarm_triggered_by", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "last_alarm_level", "TEXT"); err != nil {
+		return err
+	}
+	added, err := addColumnIfMissing(db, "devices", "alarm_active", "BOOLEAN DEFAULT FALSE")
+	if err != nil {
+		return err
+	}
+	if added {
+		if err := backfillActiveAlarms(db); err != nil {
+			return err
+		}
+	}
+	if _, err := addColumnIfMissing(db, "devices", "last_alarm_suppressed", "BOOLEAN DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "maintenance_mode", "BOOLEAN DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "maintenance_until", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "alarm_acknowledged_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+
+	if _, err := addColumnIfMissing(db, "devices", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "notify_on_alarm", "BOOLEAN NOT NULL DEFAULT TRUE"); err != nil {
+		return err
+	}
+	for _, column := range []string{"firmware_version", "firmware_target_version", "firmware_update_status"} {
+		if _, err := addColumnIfMissing(db, "devices", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+	if _, err := addColumnIfMissing(db, "devices", "archived", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "parent_id", "INTEGER"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "propagate_alarms", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "devices", "alarm_retention_days", "INTEGER"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "alarm_history", "client_event_id", "TEXT"); err != nil {
+		return err
+	}
+	// A device records each client event id once; alarms without one are not constrained
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_alarm_history_device_client_event_id
+		ON alarm_history(device_id, client_event_id) WHERE client_event_id IS NOT NULL`); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "alarm_history", "reason_code", "TEXT"); err != nil {
+		return err
+	}
+	// Serves filtering and counting the history by reason code
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_alarm_history_reason_code
+		ON alarm_history(reason_code) WHERE reason_code IS NOT NULL`); err != nil {
+		return err
+	}
+
+	// Existing history rows all record an alarm being raised
+	if _, err := addColumnIfMissing(db, "alarm_history", "event", "TEXT NOT NULL DEFAULT 'triggered'"); err != nil {
+		return err
+	}
+
+	// The alarm reason catalog. History rows keep their code and text, so an entry can be
+	// reworded or deleted without rewriting the alarms recorded with it.
+	alarmReasonsDDL := `
+	CREATE TABLE IF NOT EXISTS alarm_reasons (
+		code TEXT PRIMARY KEY,
+		text TEXT NOT NULL,
+		level_hint TEXT,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);`
+	if _, err := db.Exec(alarmReasonsDDL); err != nil {
+		return err
+	}
+
+	// Presence is kept apart from devices so frequent heartbeats neither bump a device's
+	// version and updated_at nor fill the change feed
+	presenceDDL := `
+	CREATE TABLE IF NOT EXISTS device_presence (
+		device_id INTEGER PRIMARY KEY,
+		last_seen_at TIMESTAMP NOT NULL
+	);`
+	if _, err := db.Exec(presenceDDL); err != nil {
+		return err
+	}
+
+	// Event ids of processed alarms, remembered for a while so retried requests are not recorded twice
+	alarmEventsDDL := `
+	CREATE TABLE IF NOT EXISTS alarm_events (
+		device_id INTEGER NOT NULL,
+		event_id TEXT NOT NULL,
+		suppressed BOOLEAN NOT NULL DEFAULT FALSE,
+		processed_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (device_id, event_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_alarm_events_processed_at ON alarm_events(processed_at);`
+	if _, err := db.Exec(alarmEventsDDL); err != nil {
+		return err
+	}
+
+	// When each active, unacknowledged alarm next escalates. Kept apart from devices so scheduling
+	// does not count as a change to the device; only the escalation itself does.
+	alarmEscalationsDDL := `
+	CREATE TABLE IF NOT EXISTS alarm_escalations (
+		device_id INTEGER PRIMARY KEY,
+		next_escalation_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_alarm_escalations_next ON alarm_escalations(next_escalation_at);
+	CREATE TRIGGER IF NOT EXISTS devices_delete_escalation AFTER DELETE ON devices
+	BEGIN
+		DELETE FROM alarm_escalations WHERE device_id = OLD.id;
+	END;`
+	if _, err := db.Exec(alarmEscalationsDDL); err != nil {
+		return err
+	}
+
+	// Serves the list's Last-Modified, which is the newest updated_at
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_updated_at ON devices(updated_at)`); err != nil {
+		return err
+	}
+	// Serves cursor pagination, which compares and sorts by (created_at, id)
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_created_at_id ON devices(created_at, id)`); err != nil {
+		return err
+	}
+	// Serve the recently alarmed and stale device views
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_last_alarm_time ON devices(last_alarm_time)`); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_device_presence_last_seen_at ON device_presence(last_seen_at)`); err != nil {
+		return err
+	}
+	// Serves lookups by name within an owner. Names are not unique, so neither is the index.
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_owned_by_name ON devices(owned_by, name)`); err != nil {
+		return err
+	}
+	// Serves owner lookups ignoring case, which LIKE prefix matches can use
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_owned_by_nocase ON devices(owned_by COLLATE NOCASE)`); err != nil {
+		return err
+	}
+
+	// Serves child lookups and counts
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_parent_id ON devices(parent_id)`); err != nil {
+		return err
+	}
+
+	if err := initChangeFeed(db); err != nil {
+		return err
+	}
+
+	// One-off backfills, run when the schema is brought up from an older version
+	applied, err := AppliedSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if applied < SchemaVersion {
+		return normalizeTimestamps(db)
+	}
 	return nil
 }
+
+// recordUpdateTriggerDDL creates the trigger recording device updates in the change feed
+const recordUpdateTriggerDDL = `CREATE TRIGGER IF NOT EXISTS devices_record_update AFTER UPDATE ON devices WHEN NEW.version = OLD.version
+	BEGIN
+		UPDATE devices SET version = OLD.version + 1 WHERE id = NEW.id;
+		INSERT INTO device_changes (entity, entity_id, operation, version) VALUES ('device', NEW.id, 'update', OLD.version + 1);
+	END;`
+
+// initChangeFeed creates the device_changes table and the triggers that fill it. Recording changes
+// in triggers keeps them in the same transaction as the mutation, whichever statement made it.
+// The update trigger also bumps the device's version; SQLite does not fire triggers recursively by
+// default, and the WHEN clause skips updates that set the version themselves.
+func initChangeFeed(db *sql.DB) error {
+	changeFeedDDL := `
+	CREATE TABLE IF NOT EXISTS device_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		operation TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		changed_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+	);
+	CREATE INDEX IF NOT EXISTS idx_device_changes_changed_at ON device_changes(changed_at);
+	CREATE INDEX IF NOT EXISTS idx_device_changes_deletes ON device_changes(changed_at) WHERE operation = 'delete';
+
+	CREATE TRIGGER IF NOT EXISTS devices_record_create AFTER INSERT ON devices
+	BEGIN
+		INSERT INTO device_changes (entity, entity_id, operation, version) VALUES ('device', NEW.id, 'create', NEW.version);
+	END;
+
+	` + recordUpdateTriggerDDL + `
+
+	CREATE TRIGGER IF NOT EXISTS devices_record_delete AFTER DELETE ON devices
+	BEGIN
+		INSERT INTO device_changes (entity, entity_id, operation, version) VALUES ('device', OLD.id, 'delete', OLD.version + 1);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS devices_delete_presence AFTER DELETE ON devices
+	BEGIN
+		DELETE FROM device_presence WHERE device_id = OLD.id;
+	END;`
+
+	_, err := db.Exec(changeFeedDDL)
+	return err
+}
+
+// normalizeTimestamps rewrites timestamps stored before writes were normalised, such as
+// CURRENT_TIMESTAMP text or values with an offset, as UTC RFC 3339. Rows already in that
+// layout, and text SQLite cannot read as a time, are left untouched. The device update trigger
+// is dropped for the rewrite, in the same transaction, so it bumps no versions and records
+// nothing in the change feed.
+func normalizeTimestamps(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	if _, err := tx.Exec(`DROP TRIGGER IF EXISTS devices_record_update`); err != nil {
+		return err
+	}
+	for table, columns := range timestampColumns {
+		for _, column := range columns {
+			query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = strftime('%%Y-%%m-%%dT%%H:%%M:%%SZ', %[2]s)
+				WHERE %[2]s IS NOT NULL
+				AND %[2]s NOT GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]T[0-9][0-9]:[0-9][0-9]:[0-9][0-9]Z'
+				AND strftime('%%Y-%%m-%%dT%%H:%%M:%%SZ', %[2]s) IS NOT NULL`, table, column)
+
+			result, err := tx.Exec(query)
+			if err != nil {
+				return fmt.Errorf("normalising %s.%s: %w", table, column, err)
+			}
+			if updated, err := result.RowsAffected(); err == nil && updated > 0 {
+				log.Printf("Normalised %d %s.%s timestamps to UTC RFC 3339", updated, table, column)
+			}
+		}
+	}
+	if _, err := tx.Exec(recordUpdateTriggerDDL); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// backfillActiveAlarms derives alarm state for rows that predate the alarm_active column.
+// Alarms older than alarmActiveBackfillDays are treated as inactive.
+func backfillActiveAlarms(db *sql.DB) error {
+	levelQuery := `UPDATE devices SET last_alarm_level = substr(last_alarm_reason, 2, instr(last_alarm_reason, ']') - 2)
+		WHERE last_alarm_level IS NULL AND last_alarm_reason LIKE '[%]%'`
+	if _, err := db.Exec(levelQuery); err != nil {
+		return err
+	}
+
+	activeQuery := `UPDATE devices SET alarm_active = TRUE
+		WHERE last_alarm_reason IS NOT NULL AND julianday(last_alarm_time) >= julianday('now', ?)`
+	_, err := db.Exec(activeQuery, fmt.Sprintf("-%d days", alarmActiveBackfillDays))
+	return err
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present,
+// reporting whether the column was added
+func addColumnIfMissing(db *sql.DB, table, column, definition string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, err
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSQLiteDB_WAL(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "data.db"), WithWAL(true))
+	if err != nil {
+		t.Fatalf("NewSQLiteDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if mode, synchronous, err := JournalSettings(db); err != nil || mode != "wal" || synchronous != "NORMAL" {
+		t.Fatalf("Expected WAL with synchronous=NORMAL, got %s and %s, %v", mode, synchronous, err)
+	}
+
+	// synchronous is per connection, so a second connection must have it too
+	ctx := context.Background()
+	first, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	defer first.Close()
+	second, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	defer second.Close()
+	var synchronous int
+	if err := second.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous); err != nil || synchronous != 1 {
+		t.Errorf("Expected synchronous=NORMAL on every connection, got %d, %v", synchronous, err)
+	}
+}
+
+func TestNewSQLiteDB_DefaultJournal(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if mode, synchronous, err := JournalSettings(db); err != nil || mode != "delete" || synchronous != "FULL" {
+		t.Errorf("Expected SQLite's defaults without WAL, got %s and %s, %v", mode, synchronous, err)
+	}
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// AlarmRecord is a single entry in a device's alarm history
+type AlarmRecord struct {
+	ID          int64     `json:"id"`
+	DeviceID    int64     `json:"device_id"`
+	Level       string    `json:"level"`
+	Reason      string    `json:"reason"`
+	TriggeredBy string    `json:"triggered_by"`
+	Suppressed  bool      `json:"suppressed"`
+	TriggeredAt time.Time `json:"triggered_at"`
+	// ClientEventID is the sender's id for the event the alarm reported, if it gave one
+	ClientEventID string `json:"client_event_id,omitempty"`
+	// ReasonCode is the catalog entry the reason was taken from, if the alarm gave one
+	ReasonCode string `json:"reason_code,omitempty"`
+	// Event is what the entry records: the alarm being raised, or it being cleared automatically
+	Event string `json:"event"`
+}
+
+// Alarm history events
+const (
+	AlarmEventTriggered = "triggered"
+	// AlarmEventAutoCleared records an active alarm cleared by the sweeper after its level's TTL
+	AlarmEventAutoCleared = "auto_cleared"
+)
+
+// AlarmHistoryFilter selects a page of alarm history. Zero values do not filter.
+type AlarmHistoryFilter struct {
+	// DeviceID limits the history to one device; zero includes every device
+	DeviceID int64
+	// Levels, when set, keeps only alarms of these levels
+	Levels []string
+	// ClientEventID, when set, keeps only the alarm reported with this client event id
+	ClientEventID string
+	// ReasonCodes, when set, keeps only alarms raised with these reason codes
+	ReasonCodes []string
+	// Events, when set, keeps only entries recording these events
+	Events []string
+	// After and Before bound triggered_at as a half-open range [After, Before)
+	After  time.Time
+	Before time.Time
+	Limit  int
+	Offset int
+	// Cursor, when set, continues the newest-first history from just after this position. Unlike
+	// Offset it reads no more rows however deep the page is, and skips no alarms recorded while
+	// paging.
+	Cursor *AlarmCursor
+}
+
+// AlarmCursor is a position in the alarm history sorted by triggered_at, with id breaking ties
+type AlarmCursor struct {
+	TriggeredAt time.Time
+	ID          int64
+}
+
+// AlarmReason is an entry of the alarm reason catalog. An alarm giving its code is recorded with
+// its text, so alarms raised for the same reason read the same however the sender spells it.
+type AlarmReason struct {
+	Code string `json:"code"`
+	Text string `json:"text"`
+	// LevelHint is the level alarms with this reason are usually raised at, for clients to offer.
+	// It does not change the level an alarm is raised at.
+	LevelHint string    `json:"level_hint,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlarmReasonRequest is the body of a request adding or rewording an alarm reason, whose code
+// is given in the path
+type AlarmReasonRequest struct {
+	Text      string `json:"text"`
+	LevelHint string `json:"level_hint"`
+}
+
+// EscalationRule raises active alarms of a level to a more severe one when they go unacknowledged
+// for Delay
+type EscalationRule struct {
+	Level  string
+	Target string
+	Delay  time.Duration
+	// Renotify treats the escalation as a new alarm of the target level, attaching it to an
+	// incident when incidents are grouped, rather than only recording it
+	Renotify bool
+}
+
+// AlarmPresence is what receiving an alarm from a device says about its presence. Some devices
+// only contact the server to raise alarms, so without it they would never be heard from.
+type AlarmPresence string
+
+const (
+	// AlarmPresenceOff leaves presence to heartbeats and connections
+	AlarmPresenceOff AlarmPresence = "off"
+	// AlarmPresenceSeen records the alarm as the device being heard from, so the online watchdog
+	// measures its quiet period from the alarm
+	AlarmPresenceSeen AlarmPresence = "seen"
+	// AlarmPresenceOnline also marks the device online
+	AlarmPresenceOnline AlarmPresence = "online"
+)
+
+// IsValid reports whether p is a known alarm presence mode
+func (p AlarmPresence) IsValid() bool {
+	switch p {
+	case AlarmPresenceOff, AlarmPresenceSeen, AlarmPresenceOnline:
+		return true
+	}
+	return false
+}
+
+// AlarmPresencePolicy sets the AlarmPresence of each device type; types not listed are off
+type AlarmPresencePolicy map[DeviceType]AlarmPresence
+
+// For returns the alarm presence mode of a device type
+func (p AlarmPresencePolicy) For(deviceType DeviceType) AlarmPresence {
+	if presence, ok := p[deviceType]; ok {
+		return presence
+	}
+	return AlarmPresenceOff
+}
+
+// TypesWith returns the device types set to presence, sorted
+func (p AlarmPresencePolicy) TypesWith(presence AlarmPresence) []DeviceType {
+	var types []DeviceType
+	for deviceType, mode := range p {
+		if mode == presence {
+			types = append(types, deviceType)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxAlarmLevelLength is the longest an alarm level identifier may be
+const MaxAlarmLevelLength = 32
+
+// alarmLevelPattern is the shape of an alarm level identifier, such as CRITICAL or SEV_1
+var alarmLevelPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// builtInAlarmLevels are the levels every registry holds, from least to most severe
+var builtInAlarmLevels = []string{AlarmLevelInfo, AlarmLevelWarning, AlarmLevelCritical}
+
+// AlarmLevelDefinition describes one alarm level. Severity orders the levels, with higher values
+// more severe.
+type AlarmLevelDefinition struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Severity    int    `json:"severity"`
+	BuiltIn     bool   `json:"built_in"`
+}
+
+// AlarmLevels is the ordered set of alarm levels alarms may be raised with. A nil registry holds
+// the built-in levels alone.
+type AlarmLevels struct {
+	levels   []AlarmLevelDefinition
+	severity map[string]int
+}
+
+// defaultAlarmLevels is the registry of the built-in levels
+var defaultAlarmLevels, _ = NewAlarmLevels(nil)
+
+// NewAlarmLevels creates a registry of the levels in ids, ordered from least to most severe. The
+// built-in levels must all appear, in their usual order, with added levels anywhere around them.
+// An empty ids gives the built-in levels alone.
+func NewAlarmLevels(ids []string) (*AlarmLevels, error) {
+	if len(ids) == 0 {
+		ids = builtInAlarmLevels
+	}
+
+	l := &AlarmLevels{severity: make(map[string]int, len(ids))}
+	next := 0
+	for _, id := range ids {
+		if len(id) > MaxAlarmLevelLength || !alarmLevelPattern.MatchString(id) {
+			return nil, fmt.Errorf("level %q must be at most %d upper-case letters, digits and underscores, starting with a letter", id, MaxAlarmLevelLength)
+		}
+		if _, ok := l.severity[id]; ok {
+			return nil, fmt.Errorf("level %s is listed more than once", id)
+		}
+
+		builtIn := isBuiltInAlarmLevel(id)
+		if builtIn {
+			if id != builtInAlarmLevels[next] {
+				return nil, fmt.Errorf("level %s must be listed before %s", builtInAlarmLevels[next], id)
+			}
+			next++
+		}
+
+		l.severity[id] = len(l.levels) + 1
+		l.levels = append(l.levels, AlarmLevelDefinition{ID: id, DisplayName: alarmLevelDisplayName(id), Severity: len(l.levels) + 1, BuiltIn: builtIn})
+	}
+	if next < len(builtInAlarmLevels) {
+		return nil, fmt.Errorf("built-in level %s is missing", builtInAlarmLevels[next])
+	}
+
+	return l, nil
+}
+
+// DefaultAlarmLevels returns the registry of the built-in levels
+func DefaultAlarmLevels() *AlarmLevels {
+	return defaultAlarmLevels
+}
+
+// IsValid checks if alarms may be raised with level
+func (l *AlarmLevels) IsValid(level string) bool {
+	return l.Severity(level) > 0
+}
+
+// Severity returns the position of level in the registry's order, from 1 for the least severe,
+// or 0 for a level the registry does not hold
+func (l *AlarmLevels) Severity(level string) int {
+	if l == nil {
+		l = defaultAlarmLevels
+	}
+	return l.severity[level]
+}
+
+// IsMoreSevere reports whether level a is more severe than level b. Unknown levels are less
+// severe than every known one.
+func (l *AlarmLevels) IsMoreSevere(a, b string) bool {
+	return l.Severity(a) > l.Severity(b)
+}
+
+// List returns the levels from least to most severe
+func (l *AlarmLevels) List() []AlarmLevelDefinition {
+	if l == nil {
+		l = defaultAlarmLevels
+	}
+	return append([]AlarmLevelDefinition(nil), l.levels...)
+}
+
+// IDs returns the level identifiers from least to most severe
+func (l *AlarmLevels) IDs() []string {
+	if l == nil {
+		l = defaultAlarmLevels
+	}
+	ids := make([]string, len(l.levels))
+	for i, level := range l.levels {
+		ids[i] = level.ID
+	}
+	return ids
+}
+
+// IsMoreSevere reports whether built-in alarm level a is more severe than level b. Unknown levels
+// are less severe than every known one.
+func IsMoreSevere(a, b string) bool {
+	return defaultAlarmLevels.IsMoreSevere(a, b)
+}
+
+func isBuiltInAlarmLevel(id string) bool {
+	for _, builtIn := range builtInAlarmLevels {
+		if id == builtIn {
+			return true
+		}
+	}
+	return false
+}
+
+// alarmLevelDisplayName turns an identifier such as SEV_1 into a name such as "Sev 1"
+func alarmLevelDisplayName(id string) string {
+	words := strings.Split(strings.ToLower(id), "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
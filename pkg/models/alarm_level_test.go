@@ -0,0 +1,58 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewAlarmLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		ids       []string
+		expectErr bool
+	}{
+		{"Built-in levels by default", nil, false},
+		{"Added levels around the built-in ones", []string{"DEBUG", "INFO", "NOTICE", "WARNING", "CRITICAL", "EMERGENCY"}, false},
+		{"Built-in level missing", []string{"INFO", "CRITICAL"}, true},
+		{"Built-in levels reordered", []string{"WARNING", "INFO", "CRITICAL"}, true},
+		{"Level listed twice", []string{"INFO", "WARNING", "CRITICAL", "INFO"}, true},
+		{"Lower-case level", []string{"INFO", "WARNING", "CRITICAL", "sev1"}, true},
+		{"Level starting with a digit", []string{"1", "INFO", "WARNING", "CRITICAL"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewAlarmLevels(tc.ids)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("NewAlarmLevels(%v) error = %v, expected error %v", tc.ids, err, tc.expectErr)
+			}
+		})
+	}
+}
+
+func TestAlarmLevels(t *testing.T) {
+	levels, err := NewAlarmLevels([]string{"INFO", "WARNING", "SEV_2", "CRITICAL", "EMERGENCY"})
+	if err != nil {
+		t.Fatalf("NewAlarmLevels failed: %v", err)
+	}
+
+	if !levels.IsMoreSevere("EMERGENCY", AlarmLevelCritical) || !levels.IsMoreSevere("SEV_2", AlarmLevelWarning) {
+		t.Error("Expected added levels to rank where they were listed")
+	}
+	if levels.IsValid("DEBUG") || levels.IsMoreSevere("DEBUG", AlarmLevelInfo) {
+		t.Error("Expected an unknown level to be invalid and least severe")
+	}
+
+	list := levels.List()
+	if len(list) != 5 || list[2] != (AlarmLevelDefinition{ID: "SEV_2", DisplayName: "Sev 2", Severity: 3}) {
+		t.Errorf("Unexpected level list %+v", list)
+	}
+	if !list[3].BuiltIn || list[4].BuiltIn {
+		t.Errorf("Expected only the built-in levels to be marked built-in, got %+v", list)
+	}
+
+	var unset *AlarmLevels
+	if ids := unset.IDs(); !slices.Equal(ids, []string{"INFO", "WARNING", "CRITICAL"}) {
+		t.Errorf("Expected a nil registry to hold the built-in levels, got %v", ids)
+	}
+}
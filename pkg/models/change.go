@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Change operations recorded in the change feed
+const (
+	ChangeOperationCreate = "create"
+	ChangeOperationUpdate = "update"
+	// ChangeOperationDelete is a tombstone: the entity no longer exists
+	ChangeOperationDelete = "delete"
+)
+
+// ChangeEntityDevice is the entity name of device changes
+const ChangeEntityDevice = "device"
+
+// ChangeEntityOwner is the entity name of owner renames. Owners have no id or version, so both
+// are zero; the change stands for every device the rename touched, which clients should resync.
+const ChangeEntityOwner = "owner"
+
+// ChangeOperationRename is the operation of an owner rename
+const ChangeOperationRename = "rename"
+
+// Change is a single entry in the change feed
+type Change struct {
+	// Seq increases with every change and is never reused
+	Seq       int64  `json:"seq"`
+	Entity    string `json:"entity"`
+	EntityID  int64  `json:"entity_id"`
+	Operation string `json:"operation"`
+	// Version is the entity's version after the change
+	Version   int64     `json:"version"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ChangeFeed is a page of changes after a sequence number
+type ChangeFeed struct {
+	Changes []*Change `json:"changes"`
+	// LatestSeq is the sequence number of the most recent change overall
+	LatestSeq int64 `json:"latest_seq"`
+	// NextSince is the since value that fetches the following page
+	NextSince int64 `json:"next_since"`
+	HasMore   bool  `json:"has_more"`
+}
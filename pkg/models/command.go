@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Command status values, in the order a command moves through them
+const (
+	CommandStatusPending   = "pending"
+	CommandStatusDelivered = "delivered"
+	CommandStatusAcked     = "acked"
+)
+
+// DeviceCommand is a command queued for a device until the device acknowledges it
+type DeviceCommand struct {
+	ID          int64           `json:"id"`
+	DeviceID    int64           `json:"device_id"`
+	Command     string          `json:"command"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Status      string          `json:"status"`
+	CreatedAt   time.Time       `json:"created_at"`
+	DeliveredAt time.Time       `json:"delivered_at"`
+	AckedAt     time.Time       `json:"acked_at"`
+}
+
+// CommandRequest represents a request to queue a command for a device
+type CommandRequest struct {
+	Command string          `json:"command" binding:"required"`
+	Payload json.RawMessage `json:"payload"`
+}
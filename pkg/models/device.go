@@ -0,0 +1,414 @@
+// Package models defines the devices, alarms and related types shared by the repository,
+// service and HTTP layers.
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Device database model
+type Device struct {
+	ID                   int64      `json:"id"`
+	OwnedBy              string     `json:"owned_by"`
+	DeviceType           DeviceType `json:"device_type"`
+	Name                 string     `json:"name"`
+	Description          string     `json:"description"`
+	IsOnline             bool       `json:"is_online"`
+	LastAlarmTime        time.Time  `json:"last_alarm_time"`
+	LastAlarmReason      string     `json:"last_alarm_reason"`
+	LastAlarmTriggeredBy string     `json:"last_alarm_triggered_by"`
+	LastAlarmLevel       string     `json:"last_alarm_level"`
+	AlarmActive          bool       `json:"alarm_active"`
+	LastAlarmSuppressed  bool       `json:"last_alarm_suppressed"`
+	AlarmAcknowledgedAt  time.Time  `json:"alarm_acknowledged_at"`
+	MaintenanceMode      bool       `json:"maintenance_mode"`
+	MaintenanceUntil     time.Time  `json:"maintenance_until"`
+	// NotifyOnAlarm is false for devices whose alarms should not be sent out as notifications
+	NotifyOnAlarm bool `json:"notify_on_alarm"`
+	// FirmwareVersion is the firmware the device last reported installing successfully.
+	// FirmwareTargetVersion is the firmware most recently pushed to it, and FirmwareUpdateStatus
+	// how that update went; both are empty until an update is first pushed.
+	FirmwareVersion       string `json:"firmware_version"`
+	FirmwareTargetVersion string `json:"firmware_target_version"`
+	FirmwareUpdateStatus  string `json:"firmware_update_status"`
+	// Archived devices, such as seasonal sensors put away for the winter, are kept with their
+	// history but left out of default lists, stats and background processing
+	Archived bool `json:"archived"`
+	// LastSeenAt is when the device last connected or sent a frame; zero if it never has
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// ParentID is the device this one is physically part of, such as the camera of a doorbell
+	// button, or nil. PropagateAlarms raises the child's alarms on the parent as well.
+	ParentID        *int64 `json:"parent_id"`
+	PropagateAlarms bool   `json:"propagate_alarms"`
+	// ChildCount is how many devices have this one as their parent
+	ChildCount int64 `json:"child_count"`
+	// AlarmRetentionDays overrides how long the device's alarm history is kept, for devices whose
+	// records must legally outlive the global policy. Zero keeps it forever; nil follows the policy.
+	AlarmRetentionDays *int64 `json:"alarm_retention_days"`
+	// Stale is computed when the device is read: it was last seen longer ago than the
+	// configured threshold. Devices never seen are not stale.
+	Stale bool `json:"stale"`
+	// AlarmCount is how many alarms the device has had. It is only loaded when asked for, and
+	// is nil otherwise.
+	AlarmCount *int64 `json:"alarm_count,omitempty"`
+	// Version increases by one with every change to the device
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// API models
+
+type DeviceCreate struct {
+	Name        string     `json:"name" binding:"required"`
+	Description string     `json:"description"`
+	DeviceType  DeviceType `json:"device_type" binding:"required"`
+	OwnedBy     string     `json:"owned_by" binding:"required"`
+	// IsOnline registers a device that is already connected; devices start offline by default
+	IsOnline bool `json:"is_online"`
+	// NotifyOnAlarm defaults to true when missing
+	NotifyOnAlarm *bool `json:"notify_on_alarm"`
+	// ParentID makes the device a child of an existing device
+	ParentID        *int64 `json:"parent_id"`
+	PropagateAlarms bool   `json:"propagate_alarms"`
+	// AlarmRetentionDays overrides the alarm history retention policy for the device
+	AlarmRetentionDays *int64 `json:"alarm_retention_days"`
+}
+
+// Notifies reports whether the device is created with alarm notifications on
+func (d *DeviceCreate) Notifies() bool {
+	return d.NotifyOnAlarm == nil || *d.NotifyOnAlarm
+}
+
+type DeviceUpdate struct {
+	Name        *string     `json:"name"`
+	Description *string     `json:"description"`
+	IsOnline    *bool       `json:"is_online"`
+	OwnedBy     *string     `json:"owned_by"`
+	DeviceType  *DeviceType `json:"device_type"`
+	// LastAlarmReason is cleared by an explicit null, unlike the other fields where null is ignored
+	LastAlarmReason  NullableString `json:"last_alarm_reason"`
+	MaintenanceMode  *bool          `json:"maintenance_mode"`
+	MaintenanceUntil *time.Time     `json:"maintenance_until"`
+	NotifyOnAlarm    *bool          `json:"notify_on_alarm"`
+	// ParentID is detached from its parent by an explicit null
+	ParentID        NullableInt64 `json:"parent_id"`
+	PropagateAlarms *bool         `json:"propagate_alarms"`
+	// AlarmRetentionDays goes back to the retention policy on an explicit null
+	AlarmRetentionDays NullableInt64 `json:"alarm_retention_days"`
+}
+
+// IsEmpty reports whether the update sets no fields at all
+func (u *DeviceUpdate) IsEmpty() bool {
+	return u.Name == nil && u.Description == nil && u.IsOnline == nil && u.OwnedBy == nil &&
+		u.DeviceType == nil && !u.LastAlarmReason.Set && u.MaintenanceMode == nil && u.MaintenanceUntil == nil &&
+		u.NotifyOnAlarm == nil && !u.ParentID.Set && u.PropagateAlarms == nil &&
+		!u.AlarmRetentionDays.Set
+}
+
+// ChangesNothing reports whether applying the update to current would leave every field as it
+// is, as when a client sends the same update again
+func (u *DeviceUpdate) ChangesNothing(current *Device) bool {
+	if (u.Name != nil && *u.Name != current.Name) ||
+		(u.Description != nil && *u.Description != current.Description) ||
+		(u.IsOnline != nil && *u.IsOnline != current.IsOnline) ||
+		(u.OwnedBy != nil && *u.OwnedBy != current.OwnedBy) ||
+		(u.DeviceType != nil && *u.DeviceType != current.DeviceType) ||
+		(u.LastAlarmReason.Set && u.LastAlarmReason.String != current.LastAlarmReason) ||
+		(u.MaintenanceMode != nil && *u.MaintenanceMode != current.MaintenanceMode) ||
+		(u.NotifyOnAlarm != nil && *u.NotifyOnAlarm != current.NotifyOnAlarm) ||
+		(u.PropagateAlarms != nil && *u.PropagateAlarms != current.PropagateAlarms) {
+		return false
+	}
+
+	// Leaving maintenance drops any scheduled end
+	until := current.MaintenanceUntil
+	if u.MaintenanceMode != nil && !*u.MaintenanceMode {
+		until = time.Time{}
+	}
+	if u.MaintenanceUntil != nil {
+		until = *u.MaintenanceUntil
+	}
+	// The end is stored to the second, so a resent end with a fraction of a second is the same end
+	if !until.Truncate(time.Second).Equal(current.MaintenanceUntil.Truncate(time.Second)) {
+		return false
+	}
+
+	return (!u.ParentID.Set || u.ParentID.Same(current.ParentID)) &&
+		(!u.AlarmRetentionDays.Set || u.AlarmRetentionDays.Same(current.AlarmRetentionDays))
+}
+
+// NullableString is an update field that tells a missing JSON field apart from an explicit null.
+// Set is false when the field was missing, and Valid is false when it was null.
+type NullableString struct {
+	Set    bool
+	Valid  bool
+	String string
+}
+
+// UnmarshalJSON records that the field was present, and whether it held a string or null
+func (n *NullableString) UnmarshalJSON(data []byte) error {
+	*n = NullableString{Set: true}
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.String); err != nil {
+		return err
+	}
+	n.Valid = true
+
+	return nil
+}
+
+// SetString returns a NullableString that sets the field to s
+func SetString(s string) NullableString {
+	return NullableString{Set: true, Valid: true, String: s}
+}
+
+// NullableInt64 is an update field that tells a missing JSON field apart from an explicit null,
+// like NullableString
+type NullableInt64 struct {
+	Set   bool
+	Valid bool
+	Int64 int64
+}
+
+// UnmarshalJSON records that the field was present, and whether it held a number or null
+func (n *NullableInt64) UnmarshalJSON(data []byte) error {
+	*n = NullableInt64{Set: true}
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.Int64); err != nil {
+		return err
+	}
+	n.Valid = true
+
+	return nil
+}
+
+// Same reports whether the field holds the value current does, with null as nil
+func (n NullableInt64) Same(current *int64) bool {
+	if !n.Valid || current == nil {
+		return !n.Valid && current == nil
+	}
+	return n.Int64 == *current
+}
+
+// SetInt64 returns a NullableInt64 that sets the field to i
+func SetInt64(i int64) NullableInt64 {
+	return NullableInt64{Set: true, Valid: true, Int64: i}
+}
+
+// ChildPolicy says what deleting a device does to the devices that have it as their parent
+type ChildPolicy string
+
+const (
+	// ChildrenRefuse refuses to delete a device that has children
+	ChildrenRefuse ChildPolicy = ""
+	// ChildrenDelete deletes the children, and theirs, along with the device
+	ChildrenDelete ChildPolicy = "delete"
+	// ChildrenDetach keeps the children, without a parent
+	ChildrenDetach ChildPolicy = "detach"
+)
+
+// MaintenanceRequest represents a request to enable or disable maintenance mode on a device.
+// While in maintenance, alarms are recorded as suppressed and do not become active.
+type MaintenanceRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+	// Until optionally ends maintenance automatically at the given time
+	Until *time.Time `json:"until"`
+}
+
+// Firmware update statuses
+const (
+	FirmwareUpdatePending = "pending"
+	FirmwareUpdateSuccess = "success"
+	FirmwareUpdateFailed  = "failed"
+)
+
+// FirmwareUpdateRequest records a firmware update pushed to a device
+type FirmwareUpdateRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// FirmwareReport is a device reporting how its pending firmware update ended
+type FirmwareReport struct {
+	// Status is success or failed
+	Status string `json:"status" binding:"required"`
+}
+
+// DeviceCounts summarises the number of devices by connection state
+type DeviceCounts struct {
+	Total   int `json:"total"`
+	Online  int `json:"online"`
+	Offline int `json:"offline"`
+}
+
+// DeviceTypeCounts summarises the devices of one type by connection and alarm state
+type DeviceTypeCounts struct {
+	DeviceType  DeviceType `json:"device_type"`
+	Total       int        `json:"total"`
+	Online      int        `json:"online"`
+	AlarmActive int        `json:"alarm_active"`
+}
+
+// DeviceStats summarises every device by connection and alarm state, in total and per type
+type DeviceStats struct {
+	Total       int                 `json:"total"`
+	Online      int                 `json:"online"`
+	Offline     int                 `json:"offline"`
+	AlarmActive int                 `json:"alarm_active"`
+	ByType      []*DeviceTypeCounts `json:"by_type"`
+	// ComputedAt is when the counts were taken; cached stats may trail recent changes slightly
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// Dashboard combines the device counts and recent alarms shown on a home screen
+type Dashboard struct {
+	TotalDevices  int                `json:"total_devices"`
+	DevicesByType map[DeviceType]int `json:"devices_by_type"`
+	Online        int                `json:"online"`
+	Offline       int                `json:"offline"`
+	RecentAlarms  []*AlarmRecord     `json:"recent_alarms"`
+}
+
+// Built-in alarm level values, ordered from most to least severe; see AlarmLevels for adding more
+const (
+	AlarmLevelCritical = "CRITICAL"
+	AlarmLevelWarning  = "WARNING"
+	AlarmLevelInfo     = "INFO"
+)
+
+// AlarmRequest represents a request to trigger a device alarm
+type AlarmRequest struct {
+	// Reason may be left out when ReasonCode is given
+	Reason string `json:"reason"`
+	Level  string `json:"level" binding:"required"`
+	// TriggeredBy optionally identifies the sensor, user or automation raising the alarm
+	TriggeredBy string `json:"triggered_by"`
+	// EventID optionally identifies the event being reported, so a retried request for the same
+	// device and event is recorded only once
+	EventID string `json:"event_id"`
+	// ClientEventID optionally carries the sender's own id for the event, such as a UUID from the
+	// sensor's log. It is kept with the alarm, and a device records each one once for good, so a
+	// repeat is answered with the alarm first recorded. It makes EventID redundant.
+	ClientEventID string `json:"client_event_id"`
+	// ReasonCode optionally names an entry of the alarm reason catalog, whose text then replaces
+	// Reason
+	ReasonCode string `json:"reason_code"`
+}
+
+// TriggeredAlarm records an alarm raised on one device as part of a batch
+type TriggeredAlarm struct {
+	DeviceID   int64
+	Suppressed bool
+}
+
+// TypeAlarmResult summarises an alarm triggered on every device of a type
+type TypeAlarmResult struct {
+	DeviceType DeviceType `json:"device_type"`
+	Triggered  int        `json:"triggered"`
+	// Suppressed counts the triggered devices in maintenance, whose alarms were recorded but not raised
+	Suppressed int `json:"suppressed"`
+}
+
+// ActiveAlarmFilter narrows the devices returned by an active alarm query. Each list keeps the
+// devices matching any of its values; empty lists match everything.
+type ActiveAlarmFilter struct {
+	Levels      []string
+	DeviceTypes []DeviceType
+	// LevelOrder lists the alarm levels from least to most severe, for ordering the devices;
+	// empty orders by the built-in levels
+	LevelOrder []string
+	Limit      int
+	Offset     int
+}
+
+// Sort orders accepted by list queries
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// DeviceSortFields lists the device fields a list query can be sorted by
+var DeviceSortFields = []string{"id", "name", "device_type", "owned_by", "is_online", "last_alarm_time", "created_at", "updated_at"}
+
+// IsValidDeviceSortField checks if a device list can be sorted by the given field
+func IsValidDeviceSortField(field string) bool {
+	for _, f := range DeviceSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceFields lists the device fields a response can be limited to, by their JSON names
+var DeviceFields = []string{"id", "owned_by", "device_type", "name", "description", "is_online", "last_alarm_time", "last_alarm_reason",
+	"last_alarm_triggered_by", "last_alarm_level", "alarm_active", "last_alarm_suppressed", "alarm_acknowledged_at", "maintenance_mode",
+	"maintenance_until", "notify_on_alarm", "firmware_version", "firmware_target_version", "firmware_update_status", "archived",
+	"last_seen_at", "stale", "parent_id", "propagate_alarms", "child_count", "alarm_retention_days", "alarm_count", "version", "created_at", "updated_at"}
+
+// IsValidDeviceField checks if a response can be limited to the given device field
+func IsValidDeviceField(field string) bool {
+	for _, f := range DeviceFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidSortOrder checks if the sort order is asc or desc
+func IsValidSortOrder(order string) bool {
+	return order == SortAsc || order == SortDesc
+}
+
+// DeviceListOptions controls which page of devices is returned by a list query and in what order.
+// Each list filter keeps the devices matching any of its values, and the filters that are set
+// combine with AND.
+type DeviceListOptions struct {
+	Limit     int
+	Offset    int
+	SortBy    string
+	SortOrder string
+	// Maintenance, when set, keeps only devices whose maintenance mode matches
+	Maintenance *bool
+	// Names, when set, keeps only devices whose name contains one of them, ignoring case
+	Names []string
+	// IDs, when set, keeps only the devices with these ids
+	IDs []int64
+	// DeviceTypes, when set, keeps only devices of these types
+	DeviceTypes []DeviceType
+	// ExcludeUnknown drops devices of type UNKNOWN
+	ExcludeUnknown bool
+	// ExcludeArchived drops archived devices
+	ExcludeArchived bool
+	// Owners, when set, keeps only devices owned by one of them
+	Owners []string
+	// Online, when set, keeps only devices whose online state matches
+	Online *bool
+	// AlarmActive, when set, keeps only devices whose alarm state matches
+	AlarmActive *bool
+	// After, when set, continues a list sorted by created_at from just after this position.
+	// Unlike Offset it neither skips nor repeats devices created while paging.
+	After *DeviceCursor
+	// WithAlarmCount loads each device's AlarmCount, which costs a join on the alarm history
+	WithAlarmCount bool
+	// Fields, when set, loads only these of DeviceFields and leaves the others zero. ID, CreatedAt
+	// and UpdatedAt are always loaded, since cursors and conditional requests depend on them;
+	// AlarmCount is loaded by WithAlarmCount alone.
+	Fields []string
+	// AlarmedSince, when set, keeps only devices whose last alarm was at or after it
+	AlarmedSince time.Time
+	// InactiveSince, when set, keeps only devices neither updated nor seen since it
+	InactiveSince time.Time
+}
+
+// DeviceCursor is a position in a device list sorted by created_at, with id breaking ties
+type DeviceCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeviceUpdate_LastAlarmReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected NullableString
+		empty    bool
+	}{
+		{"Missing leaves it", `{}`, NullableString{}, true},
+		{"Null clears it", `{"last_alarm_reason": null}`, NullableString{Set: true}, false},
+		{"String sets it", `{"last_alarm_reason": "Smoke"}`, SetString("Smoke"), false},
+		{"Empty string sets it", `{"last_alarm_reason": ""}`, SetString(""), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var update DeviceUpdate
+			if err := json.Unmarshal([]byte(tc.body), &update); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if update.LastAlarmReason != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, update.LastAlarmReason)
+			}
+			if update.IsEmpty() != tc.empty {
+				t.Errorf("Expected IsEmpty %v, got %v", tc.empty, update.IsEmpty())
+			}
+		})
+	}
+
+	var update DeviceUpdate
+	if err := json.Unmarshal([]byte(`{"last_alarm_reason": 5}`), &update); err == nil {
+		t.Error("Expected an error for a non-string reason")
+	}
+}
@@ -33,6 +33,9 @@ type DeviceTypeInfo struct {
 	ID          string `json:"id"`
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
+	// Deprecated types are being retired: existing devices keep them, but no device may be
+	// created with one or changed to one
+	Deprecated bool `json:"deprecated"`
 }
 
 // IsValidDeviceType checks if a given device type is valid
@@ -0,0 +1,74 @@
+package models
+
+import "errors"
+
+// ErrDeviceNotFound is returned when an operation targets a device that does not exist
+var ErrDeviceNotFound = errors.New("device not found")
+
+// ErrAmbiguousDevice is returned when a lookup meant to find a single device matches several
+var ErrAmbiguousDevice = errors.New("more than one device matches")
+
+// ErrDeviceChanged is returned when a device changes between being read and being written
+var ErrDeviceChanged = errors.New("device changed concurrently")
+
+// ErrAlarmLevelInUse is returned when an alarm level missing from the configured levels is still
+// used by alarms
+var ErrAlarmLevelInUse = errors.New("alarm level still in use")
+
+// ErrNoFirmwareUpdate is returned when a device reports on a firmware update it has not been sent
+var ErrNoFirmwareUpdate = errors.New("no firmware update is pending")
+
+// ErrDeviceArchived is returned when an archived device heartbeats or raises an alarm, which
+// points at hardware that should have been switched off or unarchived
+var ErrDeviceArchived = errors.New("device is archived")
+
+// ErrOwnerNotFound is returned when renaming an owner no device belongs to
+var ErrOwnerNotFound = errors.New("owner not found")
+
+// ErrOwnerExists is returned when renaming an owner to one that already owns devices, unless the
+// two are meant to be merged
+var ErrOwnerExists = errors.New("owner already exists")
+
+// ErrDeviceTypeLocked is returned when changing the type of a device while device types are locked
+var ErrDeviceTypeLocked = errors.New("device type is locked")
+
+// ErrParentNotFound is returned when making a device the child of one that does not exist
+var ErrParentNotFound = errors.New("parent device not found")
+
+// ErrParentCycle is returned when making a device the child of itself or of one of its descendants
+var ErrParentCycle = errors.New("parent would make the device its own ancestor")
+
+// ErrDeviceHasChildren is returned when deleting a device with children without saying what
+// becomes of them
+var ErrDeviceHasChildren = errors.New("device has children")
+
+// ErrIncidentNotFound is returned when an operation targets an incident that does not exist
+var ErrIncidentNotFound = errors.New("incident not found")
+
+// ErrCommandNotFound is returned when an operation targets a command that does not exist for the device
+var ErrCommandNotFound = errors.New("command not found")
+
+// ErrChangesCompacted is returned when changes after the requested sequence number have been
+// compacted away, so the client must resync from scratch
+var ErrChangesCompacted = errors.New("changes since the requested sequence have been compacted")
+
+// ErrNoActiveAlarm is returned when acknowledging the alarm of a device that has none active
+var ErrNoActiveAlarm = errors.New("device has no active alarm")
+
+// ErrAlarmReasonNotFound is returned when an operation targets an alarm reason the catalog does
+// not hold
+var ErrAlarmReasonNotFound = errors.New("alarm reason not found")
+
+// ErrUnknownReasonCode is returned when an alarm gives a reason code the catalog does not hold
+var ErrUnknownReasonCode = errors.New("unknown reason code")
+
+// ErrReasonCodeRequired is returned when an alarm gives no reason code while the catalog is strict
+var ErrReasonCodeRequired = errors.New("reason code required")
+
+// ErrAlarmReasonsUnsupported is returned when managing the alarm reason catalog of a service
+// that has none
+var ErrAlarmReasonsUnsupported = errors.New("alarm reason catalog is not configured")
+
+// ErrReconnectUnsupported is returned when asked to reconnect to a database that is not opened
+// through a connection manager able to reopen it
+var ErrReconnectUnsupported = errors.New("database reconnection is not supported")
@@ -0,0 +1,44 @@
+package models
+
+import "encoding/json"
+
+// Frame types exchanged over a device WebSocket connection
+const (
+	// Sent by devices
+	FrameTypeHeartbeat  = "heartbeat"
+	FrameTypeStatus     = "status"
+	FrameTypeAlarm      = "alarm"
+	FrameTypeCommandAck = "command_ack"
+
+	// Sent by the server
+	FrameTypeCommand = "command"
+	FrameTypeAck     = "ack"
+	FrameTypeError   = "error"
+)
+
+// DeviceFrame is a single JSON message on a device WebSocket connection.
+// Only the fields relevant to Type are set.
+type DeviceFrame struct {
+	Type string `json:"type"`
+
+	// Alarm frames
+	Level       string `json:"level,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	ReasonCode  string `json:"reason_code,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+
+	// Status frames
+	IsOnline *bool `json:"is_online,omitempty"`
+
+	// Command frames, and command_ack frames sent back by the device
+	CommandID int64           `json:"command_id,omitempty"`
+	Command   string          `json:"command,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+
+	// Ack and error frames refer back to the frame type they answer
+	Ref    string            `json:"ref,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+	// Code identifies errors a device is expected to act on, such as DEVICE_ARCHIVED
+	Code string `json:"code,omitempty"`
+}
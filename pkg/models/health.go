@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// DeviceHealth is a device's health score along with the inputs it was computed from
+type DeviceHealth struct {
+	DeviceID int64 `json:"device_id"`
+	// Score runs from 0, unhealthy, to 100, healthy
+	Score      int       `json:"score"`
+	IsOnline   bool      `json:"is_online"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// RecentAlarms is how many alarms the device raised within the scoring window
+	RecentAlarms int              `json:"recent_alarms"`
+	Components   HealthComponents `json:"components"`
+}
+
+// HealthComponents are the parts of a health score, each from 0 to 1 before weighting
+type HealthComponents struct {
+	Online    float64 `json:"online"`
+	Heartbeat float64 `json:"heartbeat"`
+	Alarms    float64 `json:"alarms"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// ImportResult summarises a bulk device import
+type ImportResult struct {
+	// DryRun is true when records were validated but nothing was written
+	DryRun   bool           `json:"dry_run"`
+	Total    int            `json:"total"`
+	Created  int            `json:"created"`
+	Rejected int            `json:"rejected"`
+	Errors   []*ImportError `json:"errors"`
+	// Warnings lists the accepted records whose values looked suspicious
+	Warnings []*ImportWarning `json:"warnings,omitempty"`
+}
+
+// ImportError describes a record rejected by a bulk import
+type ImportError struct {
+	// Line is the 1-based line number of the record in the request body
+	Line   int               `json:"line"`
+	Error  string            `json:"error,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ImportWarning describes the validation warnings raised by a record a bulk import accepted
+type ImportWarning struct {
+	Line     int               `json:"line"`
+	Warnings map[string]string `json:"warnings"`
+}
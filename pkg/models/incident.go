@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Incident status values
+const (
+	IncidentStatusOpen     = "open"
+	IncidentStatusResolved = "resolved"
+)
+
+// Incident groups related alarms raised within a short window
+type Incident struct {
+	ID          int64           `json:"id"`
+	Level       string          `json:"level"`
+	Status      string          `json:"status"`
+	OpenedAt    time.Time       `json:"opened_at"`
+	LastAlarmAt time.Time       `json:"last_alarm_at"`
+	ResolvedAt  time.Time       `json:"resolved_at"`
+	Alarms      []IncidentAlarm `json:"alarms"`
+}
+
+// IncidentAlarm is a single alarm attached to an incident
+type IncidentAlarm struct {
+	ID          int64     `json:"id"`
+	IncidentID  int64     `json:"incident_id"`
+	DeviceID    int64     `json:"device_id"`
+	Reason      string    `json:"reason"`
+	TriggeredBy string    `json:"triggered_by"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// IncidentListOptions controls which incidents are returned by a list query
+type IncidentListOptions struct {
+	// Statuses, when set, keeps only incidents in one of these statuses
+	Statuses []string
+	Limit    int
+	Offset   int
+}
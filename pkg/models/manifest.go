@@ -0,0 +1,44 @@
+package models
+
+// Manifest is the set of devices that should exist, as kept in a declarative manifest
+type Manifest struct {
+	Devices []*ManifestDevice `json:"devices"`
+}
+
+// ManifestDevice is a device a manifest declares. Devices are matched by owner and name; a
+// missing description is not compared.
+type ManifestDevice struct {
+	Name        string     `json:"name"`
+	DeviceType  DeviceType `json:"device_type"`
+	OwnedBy     string     `json:"owned_by"`
+	Description *string    `json:"description,omitempty"`
+}
+
+// FieldDrift is a field whose stored value differs from the manifest
+type FieldDrift struct {
+	Current interface{} `json:"current"`
+	Desired interface{} `json:"desired"`
+}
+
+// DeviceDrift is a stored device whose fields differ from its manifest entry
+type DeviceDrift struct {
+	ID      int64                 `json:"id"`
+	Name    string                `json:"name"`
+	OwnedBy string                `json:"owned_by"`
+	Version int64                 `json:"version"`
+	Fields  map[string]FieldDrift `json:"fields"`
+	// Desired is the manifest entry the device is brought in line with when applied
+	Desired *ManifestDevice `json:"-"`
+}
+
+// DeviceDiff compares a manifest with the stored devices of the owners it lists
+type DeviceDiff struct {
+	// Missing are in the manifest but not stored
+	Missing []*ManifestDevice `json:"missing"`
+	// Unexpected are stored for an owner in the manifest but not listed in it
+	Unexpected []*Device `json:"unexpected"`
+	// Changed are stored with fields differing from the manifest
+	Changed []*DeviceDrift `json:"changed"`
+	// Applied is true when missing devices were created and changed ones updated
+	Applied bool `json:"applied"`
+}
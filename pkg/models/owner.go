@@ -0,0 +1,24 @@
+package models
+
+// OwnerRename is a request to rename an owner across every device and record naming it
+type OwnerRename struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// OwnerRenameResult reports an owner rename
+type OwnerRenameResult struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Rows counts the rows changed in each table. Devices count once, whether their owner, their
+	// last alarm's actor or both were renamed.
+	Rows map[string]int64 `json:"rows"`
+}
+
+// OwnerListOptions controls which owners are returned by a list query
+type OwnerListOptions struct {
+	// Search, when set, keeps only owners starting with it, ignoring ASCII case
+	Search string
+	Limit  int
+	Offset int
+}
@@ -0,0 +1,8 @@
+package models
+
+// RawDeviceRow is a devices row exactly as stored, for diagnosing storage and replication
+// problems. Each column holds its stored text, or nil for NULL.
+type RawDeviceRow struct {
+	ID      int64              `json:"id"`
+	Columns map[string]*string `json:"columns"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// VacuumResult reports a database vacuum. Sizes are those of the main database file, in bytes.
+type VacuumResult struct {
+	// Vacuumed is false when the database does not support vacuuming; Message says why
+	Vacuumed   bool   `json:"vacuumed"`
+	SizeBefore int64  `json:"size_before_bytes"`
+	SizeAfter  int64  `json:"size_after_bytes"`
+	Message    string `json:"message,omitempty"`
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// AlarmReasonRepositoryImpl handles database operations for the alarm reason catalog
+type AlarmReasonRepositoryImpl struct {
+	db DB
+}
+
+// NewAlarmReasonRepository creates a new AlarmReasonRepository
+func NewAlarmReasonRepository(db DB) AlarmReasonRepository {
+	return &AlarmReasonRepositoryImpl{db: db}
+}
+
+// alarmReasonColumns lists the columns scanned by scanAlarmReason, in order
+const alarmReasonColumns = `code, text, level_hint, created_at, updated_at`
+
+// scanAlarmReason reads a single alarm reason row selected with alarmReasonColumns
+func scanAlarmReason(row rowScanner) (*models.AlarmReason, error) {
+	var reason models.AlarmReason
+	var levelHint sql.NullString
+	var createdAt, updatedAt string
+
+	if err := row.Scan(&reason.Code, &reason.Text, &levelHint, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	reason.LevelHint = levelHint.String
+	reason.CreatedAt = parseTimestamp(createdAt)
+	reason.UpdatedAt = parseTimestamp(updatedAt)
+
+	return &reason, nil
+}
+
+// List returns every reason in the catalog, ordered by code
+func (r *AlarmReasonRepositoryImpl) List() ([]*models.AlarmReason, error) {
+	rows, err := r.db.Query(`SELECT ` + alarmReasonColumns + ` FROM alarm_reasons ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	reasons := []*models.AlarmReason{}
+	for rows.Next() {
+		reason, err := scanAlarmReason(rows)
+		if err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reasons, nil
+}
+
+// Get returns the reason with the given code, or ErrAlarmReasonNotFound
+func (r *AlarmReasonRepositoryImpl) Get(code string) (*models.AlarmReason, error) {
+	reason, err := scanAlarmReason(r.db.QueryRow(`SELECT `+alarmReasonColumns+` FROM alarm_reasons WHERE code = ?`, code))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", models.ErrAlarmReasonNotFound, code)
+	}
+
+	return reason, err
+}
+
+// Save creates the reason, or replaces the text and level hint of the one with its code, keeping
+// when it was created. It returns the reason as stored and whether it was created.
+func (r *AlarmReasonRepositoryImpl) Save(reason *models.AlarmReason) (*models.AlarmReason, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	levelHint := sql.NullString{String: reason.LevelHint, Valid: reason.LevelHint != ""}
+	insertQuery := `INSERT INTO alarm_reasons (code, text, level_hint, created_at, updated_at) VALUES (?, ?, ?, ` + sqlNow + `, ` + sqlNow + `)
+		ON CONFLICT (code) DO NOTHING`
+	result, err := tx.Exec(insertQuery, reason.Code, reason.Text, levelHint)
+	if err != nil {
+		return nil, false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if inserted == 0 {
+		updateQuery := `UPDATE alarm_reasons SET text = ?, level_hint = ?, updated_at = ` + sqlNow + ` WHERE code = ?`
+		if _, err := tx.Exec(updateQuery, reason.Text, levelHint, reason.Code); err != nil {
+			return nil, false, err
+		}
+	}
+
+	saved, err := scanAlarmReason(tx.QueryRow(`SELECT `+alarmReasonColumns+` FROM alarm_reasons WHERE code = ?`, reason.Code))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	return saved, inserted == 1, nil
+}
+
+// Delete removes the reason with the given code. Alarms already recorded with it keep their code
+// and text.
+func (r *AlarmReasonRepositoryImpl) Delete(code string) error {
+	result, err := r.db.Exec(`DELETE FROM alarm_reasons WHERE code = ?`, code)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", models.ErrAlarmReasonNotFound, code)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestAlarmReasonRepository_Lifecycle(t *testing.T) {
+	reasons := NewAlarmReasonRepository(newTestDB(t))
+
+	smoke, created, err := reasons.Save(&models.AlarmReason{Code: "smoke", Text: "Smoke detected", LevelHint: models.AlarmLevelCritical})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if !created || smoke.Text != "Smoke detected" || smoke.LevelHint != models.AlarmLevelCritical || smoke.CreatedAt.IsZero() {
+		t.Errorf("Expected a new reason, got created=%t %+v", created, smoke)
+	}
+	if _, _, err := reasons.Save(&models.AlarmReason{Code: "battery.low", Text: "Battery low"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Saving an existing code rewords it, keeping when it was created
+	reworded, created, err := reasons.Save(&models.AlarmReason{Code: "smoke", Text: "Smoke detected in the room"})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if created || reworded.Text != "Smoke detected in the room" || reworded.LevelHint != "" || !reworded.CreatedAt.Equal(smoke.CreatedAt) {
+		t.Errorf("Expected the reason to be reworded in place, got created=%t %+v", created, reworded)
+	}
+
+	got, err := reasons.Get("smoke")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Text != reworded.Text {
+		t.Errorf("Expected Get to return the reworded reason, got %+v", got)
+	}
+	if _, err := reasons.Get("fire"); !errors.Is(err, models.ErrAlarmReasonNotFound) {
+		t.Errorf("Expected ErrAlarmReasonNotFound for an unknown code, got %v", err)
+	}
+
+	list, err := reasons.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Code != "battery.low" || list[1].Code != "smoke" {
+		t.Fatalf("Expected both reasons ordered by code, got %+v", list)
+	}
+
+	if err := reasons.Delete("smoke"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := reasons.Delete("smoke"); !errors.Is(err, models.ErrAlarmReasonNotFound) {
+		t.Errorf("Expected ErrAlarmReasonNotFound deleting twice, got %v", err)
+	}
+	if list, _ := reasons.List(); len(list) != 1 {
+		t.Errorf("Expected one reason left, got %d", len(list))
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// ChangeRepositoryImpl reads and compacts the change feed. Changes are recorded by
+// triggers on the tables they describe, not by this repository.
+type ChangeRepositoryImpl struct {
+	db DB
+}
+
+// NewChangeRepository creates a new ChangeRepository
+func NewChangeRepository(db DB) ChangeRepository {
+	return &ChangeRepositoryImpl{db: db}
+}
+
+// List returns up to limit changes with a sequence number greater than since, in order
+func (r *ChangeRepositoryImpl) List(since int64, limit int) ([]*models.Change, error) {
+	query := `SELECT seq, entity, entity_id, operation, version, changed_at FROM device_changes WHERE seq > ? ORDER BY seq LIMIT ?`
+	rows, err := r.db.Query(query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	changes := []*models.Change{}
+	for rows.Next() {
+		var change models.Change
+		var changedAt string
+		if err := rows.Scan(&change.Seq, &change.Entity, &change.EntityID, &change.Operation, &change.Version, &changedAt); err != nil {
+			return nil, err
+		}
+		change.ChangedAt = parseTimestamp(changedAt)
+
+		changes = append(changes, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Bounds returns the oldest retained and the latest sequence numbers. The latest comes from
+// sqlite_sequence so it survives compaction of every row.
+func (r *ChangeRepositoryImpl) Bounds() (int64, int64, error) {
+	query := `SELECT COALESCE((SELECT MIN(seq) FROM device_changes), 0),
+		COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'device_changes'), 0)`
+
+	var oldest, latest int64
+	if err := r.db.QueryRow(query).Scan(&oldest, &latest); err != nil {
+		return 0, 0, err
+	}
+
+	return oldest, latest, nil
+}
+
+// Compact deletes changes recorded before the given time, returning how many were removed
+func (r *ChangeRepositoryImpl) Compact(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM device_changes WHERE changed_at < ?`, formatTimestamp(before))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestChangeRepository_RecordsDeviceMutations(t *testing.T) {
+	db := newTestDB(t)
+	devices := NewDeviceRepository(db)
+	changes := NewChangeRepository(db)
+
+	id := createTestDevice(t, devices, "Detector")
+	name := "Renamed"
+	if _, err := devices.Update(id, &models.DeviceUpdate{Name: &name}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	device, err := devices.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if device.Version != 2 {
+		t.Errorf("Expected the update to bump the version to 2, got %d", device.Version)
+	}
+
+	if err := devices.Delete(id, models.ChildrenRefuse); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	feed, err := changes.List(0, 10)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	expected := []struct {
+		operation string
+		version   int64
+	}{
+		{models.ChangeOperationCreate, 1},
+		{models.ChangeOperationUpdate, 2},
+		{models.ChangeOperationDelete, 3},
+	}
+	if len(feed) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d", len(expected), len(feed))
+	}
+	for i, want := range expected {
+		got := feed[i]
+		if got.Entity != models.ChangeEntityDevice || got.EntityID != id || got.Operation != want.operation || got.Version != want.version {
+			t.Errorf("Change %d: expected %s at version %d, got %+v", i, want.operation, want.version, got)
+		}
+		if i > 0 && got.Seq <= feed[i-1].Seq {
+			t.Errorf("Expected increasing sequence numbers, got %d after %d", got.Seq, feed[i-1].Seq)
+		}
+		if got.ChangedAt.IsZero() {
+			t.Errorf("Change %d has no timestamp", i)
+		}
+	}
+
+	page, err := changes.List(feed[0].Seq, 1)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(page) != 1 || page[0].Seq != feed[1].Seq {
+		t.Errorf("Expected only the change after seq %d, got %+v", feed[0].Seq, page)
+	}
+}
+
+func TestChangeRepository_Compact(t *testing.T) {
+	db := newTestDB(t)
+	devices := NewDeviceRepository(db)
+	changes := NewChangeRepository(db)
+
+	createTestDevice(t, devices, "Old")
+	createTestDevice(t, devices, "New")
+	if _, err := db.Exec(`UPDATE device_changes SET changed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-2 days') WHERE seq = (SELECT MIN(seq) FROM device_changes)`); err != nil {
+		t.Fatalf("Failed to backdate change: %v", err)
+	}
+
+	deleted, err := changes.Compact(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 change compacted, got %d", deleted)
+	}
+
+	oldest, latest, err := changes.Bounds()
+	if err != nil {
+		t.Fatalf("Bounds failed: %v", err)
+	}
+	if oldest != 2 || latest != 2 {
+		t.Errorf("Expected bounds 2..2, got %d..%d", oldest, latest)
+	}
+
+	// The latest sequence number survives even when every change has been compacted
+	if _, err := changes.Compact(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	oldest, latest, err = changes.Bounds()
+	if err != nil {
+		t.Fatalf("Bounds failed: %v", err)
+	}
+	if oldest != 0 || latest != 2 {
+		t.Errorf("Expected bounds 0..2 after compacting everything, got %d..%d", oldest, latest)
+	}
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// CommandRepositoryImpl handles database operations for queued device commands
+type CommandRepositoryImpl struct {
+	db DB
+}
+
+// NewCommandRepository creates a new CommandRepository
+func NewCommandRepository(db DB) CommandRepository {
+	return &CommandRepositoryImpl{db: db}
+}
+
+// commandColumns lists the columns scanned by scanCommand, in order
+const commandColumns = `id, device_id, command, payload, status, created_at, delivered_at, acked_at`
+
+// scanCommand reads a single command row selected with commandColumns
+func scanCommand(row rowScanner) (*models.DeviceCommand, error) {
+	var command models.DeviceCommand
+	var payload, deliveredAt, ackedAt sql.NullString
+	var createdAt string
+
+	if err := row.Scan(
+		&command.ID,
+		&command.DeviceID,
+		&command.Command,
+		&payload,
+		&command.Status,
+		&createdAt,
+		&deliveredAt,
+		&ackedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if payload.Valid {
+		command.Payload = json.RawMessage(payload.String)
+	}
+	command.CreatedAt = parseTimestamp(createdAt)
+	command.DeliveredAt = parseTimestamp(deliveredAt.String)
+	command.AckedAt = parseTimestamp(ackedAt.String)
+
+	return &command, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryCommands runs a query selecting commandColumns and scans every returned row
+func queryCommands(q queryer, query string, args ...interface{}) ([]*models.DeviceCommand, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	commands := []*models.DeviceCommand{}
+	for rows.Next() {
+		command, err := scanCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return commands, nil
+}
+
+// Enqueue adds a pending command for a device and returns it as stored
+func (r *CommandRepositoryImpl) Enqueue(deviceID int64, command string, payload json.RawMessage) (*models.DeviceCommand, error) {
+	query := `INSERT INTO device_commands (device_id, command, payload, created_at) VALUES (?, ?, ?, ` + sqlNow + `) RETURNING ` + commandColumns
+	return scanCommand(r.db.QueryRow(query, deviceID, command, sql.NullString{String: string(payload), Valid: len(payload) > 0}))
+}
+
+// Poll returns a page of the unacknowledged commands for a device, oldest first, marking the
+// pending ones on the page as delivered
+func (r *CommandRepositoryImpl) Poll(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	// Delivered but unacknowledged commands are returned again so a device that lost a response can recover
+	page := `SELECT id FROM device_commands WHERE device_id = ? AND status != ? ORDER BY id LIMIT ? OFFSET ?`
+	pageArgs := []interface{}{deviceID, models.CommandStatusAcked, limit, offset}
+
+	deliverQuery := `UPDATE device_commands SET status = ?, delivered_at = ` + sqlNow + ` WHERE status = ? AND id IN (` + page + `)`
+	if _, err := tx.Exec(deliverQuery, append([]interface{}{models.CommandStatusDelivered, models.CommandStatusPending}, pageArgs...)...); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + commandColumns + ` FROM device_commands WHERE id IN (` + page + `) ORDER BY id`
+	commands, err := queryCommands(tx, query, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return commands, nil
+}
+
+// ListPending returns the commands for a device that have not been delivered yet, oldest first
+func (r *CommandRepositoryImpl) ListPending(deviceID int64) ([]*models.DeviceCommand, error) {
+	query := `SELECT ` + commandColumns + ` FROM device_commands WHERE device_id = ? AND status = ? ORDER BY id`
+	return queryCommands(r.db, query, deviceID, models.CommandStatusPending)
+}
+
+// MarkDelivered records that a pending command was delivered; commands already delivered or acked are left as they are
+func (r *CommandRepositoryImpl) MarkDelivered(deviceID, id int64) error {
+	query := `UPDATE device_commands SET status = ?, delivered_at = ` + sqlNow + ` WHERE id = ? AND device_id = ? AND status = ?`
+	_, err := r.db.Exec(query, models.CommandStatusDelivered, id, deviceID, models.CommandStatusPending)
+	return err
+}
+
+// Ack marks a command as acknowledged by its device. Acknowledging twice keeps the first ack time.
+func (r *CommandRepositoryImpl) Ack(deviceID, id int64) error {
+	query := `UPDATE device_commands SET status = ?, acked_at = COALESCE(acked_at, ` + sqlNow + `),
+		delivered_at = COALESCE(delivered_at, ` + sqlNow + `) WHERE id = ? AND device_id = ?`
+
+	result, err := r.db.Exec(query, models.CommandStatusAcked, id, deviceID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w with ID: %d", models.ErrCommandNotFound, id)
+	}
+
+	return nil
+}
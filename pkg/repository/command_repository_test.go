@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestCommandRepository_Lifecycle(t *testing.T) {
+	db := newTestDB(t)
+	commands := NewCommandRepository(db)
+	deviceID := createTestDevice(t, NewDeviceRepository(db), "Detector")
+
+	first, err := commands.Enqueue(deviceID, "silence", json.RawMessage(`{"seconds":30}`))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if first.Status != models.CommandStatusPending || first.CreatedAt.IsZero() || !first.DeliveredAt.IsZero() {
+		t.Errorf("Expected a new pending command, got %+v", first)
+	}
+	if string(first.Payload) != `{"seconds":30}` {
+		t.Errorf("Expected payload to round-trip, got %s", first.Payload)
+	}
+
+	second, err := commands.Enqueue(deviceID, "reboot", nil)
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if second.Payload != nil {
+		t.Errorf("Expected no payload, got %s", second.Payload)
+	}
+
+	pending, err := commands.ListPending(deviceID)
+	if err != nil {
+		t.Fatalf("ListPending failed: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != first.ID || pending[1].ID != second.ID {
+		t.Fatalf("Expected both commands pending in order, got %d", len(pending))
+	}
+
+	// Only the pending commands on the page polled are delivered
+	polled, err := commands.Poll(deviceID, 1, 0)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(polled) != 1 || polled[0].ID != first.ID {
+		t.Fatalf("Expected the oldest command alone, got %d", len(polled))
+	}
+	pending, err = commands.ListPending(deviceID)
+	if err != nil || len(pending) != 1 || pending[0].ID != second.ID {
+		t.Fatalf("Expected the second command still pending, got %d, %v", len(pending), err)
+	}
+
+	polled, err = commands.Poll(deviceID, 10, 0)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(polled) != 2 {
+		t.Fatalf("Expected 2 polled commands, got %d", len(polled))
+	}
+	for _, command := range polled {
+		if command.Status != models.CommandStatusDelivered || command.DeliveredAt.IsZero() {
+			t.Errorf("Expected polled command %d to be delivered, got %+v", command.ID, command)
+		}
+	}
+
+	if err := commands.Ack(deviceID, first.ID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+
+	// Unacknowledged commands are returned again; acknowledged ones are not
+	polled, err = commands.Poll(deviceID, 10, 0)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(polled) != 1 || polled[0].ID != second.ID {
+		t.Errorf("Expected only the unacknowledged command, got %d commands", len(polled))
+	}
+
+	pending, err = commands.ListPending(deviceID)
+	if err != nil {
+		t.Fatalf("ListPending failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending commands after polling, got %d", len(pending))
+	}
+}
+
+func TestCommandRepository_MarkDeliveredAndAck(t *testing.T) {
+	db := newTestDB(t)
+	commands := NewCommandRepository(db)
+	devices := NewDeviceRepository(db)
+	deviceID := createTestDevice(t, devices, "Detector")
+	otherID := createTestDevice(t, devices, "Other")
+
+	command, err := commands.Enqueue(deviceID, "silence", nil)
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	if err := commands.MarkDelivered(deviceID, command.ID); err != nil {
+		t.Fatalf("MarkDelivered failed: %v", err)
+	}
+	pending, err := commands.ListPending(deviceID)
+	if err != nil {
+		t.Fatalf("ListPending failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected the delivered command to leave the pending list")
+	}
+
+	if err := commands.Ack(otherID, command.ID); !errors.Is(err, models.ErrCommandNotFound) {
+		t.Errorf("Expected ErrCommandNotFound acking another device's command, got %v", err)
+	}
+	if err := commands.Ack(deviceID, 9999); !errors.Is(err, models.ErrCommandNotFound) {
+		t.Errorf("Expected ErrCommandNotFound for an unknown command, got %v", err)
+	}
+	if err := commands.Ack(deviceID, command.ID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if err := commands.Ack(deviceID, command.ID); err != nil {
+		t.Errorf("Expected acknowledging twice to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// conformanceRepo passes every call through to the backend under test, recording which methods
+// the conformance suite calls. It must implement DeviceRepository, so a method added to the
+// interface does not compile until it is added here, and TestDeviceRepositoryConformance fails
+// until one of the conformance cases calls it.
+type conformanceRepo struct {
+	repo DeviceRepository
+
+	mu     *sync.Mutex
+	called map[string]bool
+}
+
+var _ DeviceRepository = (*conformanceRepo)(nil)
+
+func (r *conformanceRepo) record(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.called[method] = true
+}
+
+func (r *conformanceRepo) GetByID(id int64) (*models.Device, error) {
+	r.record("GetByID")
+	return r.repo.GetByID(id)
+}
+
+func (r *conformanceRepo) GetByIDs(ids []int64) ([]*models.Device, error) {
+	r.record("GetByIDs")
+	return r.repo.GetByIDs(ids)
+}
+
+func (r *conformanceRepo) GetByIDWithAlarmCount(id int64) (*models.Device, error) {
+	r.record("GetByIDWithAlarmCount")
+	return r.repo.GetByIDWithAlarmCount(id)
+}
+
+func (r *conformanceRepo) GetByOwnerAndName(owner, name string) (*models.Device, error) {
+	r.record("GetByOwnerAndName")
+	return r.repo.GetByOwnerAndName(owner, name)
+}
+
+func (r *conformanceRepo) GetRawByID(id int64) (*models.RawDeviceRow, error) {
+	r.record("GetRawByID")
+	return r.repo.GetRawByID(id)
+}
+
+func (r *conformanceRepo) Exists(id int64) (bool, error) {
+	r.record("Exists")
+	return r.repo.Exists(id)
+}
+
+func (r *conformanceRepo) GetAll() ([]*models.Device, error) {
+	r.record("GetAll")
+	return r.repo.GetAll()
+}
+
+func (r *conformanceRepo) List(opts *models.DeviceListOptions) ([]*models.Device, error) {
+	r.record("List")
+	return r.repo.List(opts)
+}
+
+func (r *conformanceRepo) Count(opts *models.DeviceListOptions) (int, error) {
+	r.record("Count")
+	return r.repo.Count(opts)
+}
+
+func (r *conformanceRepo) EachDevice(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	r.record("EachDevice")
+	return r.repo.EachDevice(ctx, opts, fn)
+}
+
+func (r *conformanceRepo) StreamAll(ctx context.Context) (<-chan *models.Device, <-chan error) {
+	r.record("StreamAll")
+	return r.repo.StreamAll(ctx)
+}
+
+func (r *conformanceRepo) ListActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	r.record("ListActiveAlarms")
+	return r.repo.ListActiveAlarms(filter)
+}
+
+func (r *conformanceRepo) ListAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	r.record("ListAlarmHistory")
+	return r.repo.ListAlarmHistory(filter)
+}
+
+func (r *conformanceRepo) CountAlarmHistory(filter *models.AlarmHistoryFilter) (int, error) {
+	r.record("CountAlarmHistory")
+	return r.repo.CountAlarmHistory(filter)
+}
+
+func (r *conformanceRepo) CountAlarmHistoryByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	r.record("CountAlarmHistoryByReasonCode")
+	return r.repo.CountAlarmHistoryByReasonCode(filter)
+}
+
+func (r *conformanceRepo) EachAlarmRecord(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	r.record("EachAlarmRecord")
+	return r.repo.EachAlarmRecord(ctx, filter, fn)
+}
+
+func (r *conformanceRepo) ListAlarmLevelsInUse(since time.Time) ([]string, error) {
+	r.record("ListAlarmLevelsInUse")
+	return r.repo.ListAlarmLevelsInUse(since)
+}
+
+func (r *conformanceRepo) GetDistinctOwners(opts *models.OwnerListOptions) ([]string, error) {
+	r.record("GetDistinctOwners")
+	return r.repo.GetDistinctOwners(opts)
+}
+
+func (r *conformanceRepo) MatchOwners(owner string, limit int) ([]string, error) {
+	r.record("MatchOwners")
+	return r.repo.MatchOwners(owner, limit)
+}
+
+func (r *conformanceRepo) ListChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	r.record("ListChildren")
+	return r.repo.ListChildren(id, limit, offset)
+}
+
+func (r *conformanceRepo) ListRecentlyUpdated(limit int) ([]*models.Device, error) {
+	r.record("ListRecentlyUpdated")
+	return r.repo.ListRecentlyUpdated(limit)
+}
+
+func (r *conformanceRepo) CountDevices() (*models.DeviceCounts, error) {
+	r.record("CountDevices")
+	return r.repo.CountDevices()
+}
+
+func (r *conformanceRepo) CountDevicesByType() (map[models.DeviceType]int, error) {
+	r.record("CountDevicesByType")
+	return r.repo.CountDevicesByType()
+}
+
+func (r *conformanceRepo) CountDevicesByState() ([]*models.DeviceTypeCounts, error) {
+	r.record("CountDevicesByState")
+	return r.repo.CountDevicesByState()
+}
+
+func (r *conformanceRepo) LastModified() (time.Time, error) {
+	r.record("LastModified")
+	return r.repo.LastModified()
+}
+
+func (r *conformanceRepo) Create(device *models.DeviceCreate) (*models.Device, error) {
+	r.record("Create")
+	return r.repo.Create(device)
+}
+
+func (r *conformanceRepo) CreateBatch(devices []*models.DeviceCreate) error {
+	r.record("CreateBatch")
+	return r.repo.CreateBatch(devices)
+}
+
+func (r *conformanceRepo) ApplyManifest(creates []*models.DeviceCreate, changes []*models.DeviceDrift) error {
+	r.record("ApplyManifest")
+	return r.repo.ApplyManifest(creates, changes)
+}
+
+func (r *conformanceRepo) Update(id int64, device *models.DeviceUpdate, skipUnchanged bool) (bool, error) {
+	r.record("Update")
+	return r.repo.Update(id, device, skipUnchanged)
+}
+
+func (r *conformanceRepo) Delete(id int64, children models.ChildPolicy) error {
+	r.record("Delete")
+	return r.repo.Delete(id, children)
+}
+
+func (r *conformanceRepo) TriggerAlarm(id int64, level, reason, triggeredBy, reasonCode string) (bool, error) {
+	r.record("TriggerAlarm")
+	return r.repo.TriggerAlarm(id, level, reason, triggeredBy, reasonCode)
+}
+
+func (r *conformanceRepo) TriggerAlarmOnce(id int64, eventID, level, reason, triggeredBy, reasonCode string) (bool, bool, error) {
+	r.record("TriggerAlarmOnce")
+	return r.repo.TriggerAlarmOnce(id, eventID, level, reason, triggeredBy, reasonCode)
+}
+
+func (r *conformanceRepo) TriggerAlarmForClientEvent(id int64, clientEventID, level, reason, triggeredBy, reasonCode string) (*models.AlarmRecord, bool, error) {
+	r.record("TriggerAlarmForClientEvent")
+	return r.repo.TriggerAlarmForClientEvent(id, clientEventID, level, reason, triggeredBy, reasonCode)
+}
+
+func (r *conformanceRepo) PurgeAlarmEvents(before time.Time) (int64, error) {
+	r.record("PurgeAlarmEvents")
+	return r.repo.PurgeAlarmEvents(before)
+}
+
+func (r *conformanceRepo) PruneAlarmHistory(now time.Time, retention time.Duration) (int64, error) {
+	r.record("PruneAlarmHistory")
+	return r.repo.PruneAlarmHistory(now, retention)
+}
+
+func (r *conformanceRepo) TriggerAlarmByType(deviceType models.DeviceType, level, reason, triggeredBy, reasonCode string) ([]*models.TriggeredAlarm, error) {
+	r.record("TriggerAlarmByType")
+	return r.repo.TriggerAlarmByType(deviceType, level, reason, triggeredBy, reasonCode)
+}
+
+func (r *conformanceRepo) SetMaintenance(id int64, enabled bool, until time.Time) error {
+	r.record("SetMaintenance")
+	return r.repo.SetMaintenance(id, enabled, until)
+}
+
+func (r *conformanceRepo) SetArchived(id int64, archived bool) error {
+	r.record("SetArchived")
+	return r.repo.SetArchived(id, archived)
+}
+
+func (r *conformanceRepo) RenameOwner(from, to string, merge bool) (map[string]int64, error) {
+	r.record("RenameOwner")
+	return r.repo.RenameOwner(from, to, merge)
+}
+
+func (r *conformanceRepo) SetFirmwareTarget(id int64, version string) error {
+	r.record("SetFirmwareTarget")
+	return r.repo.SetFirmwareTarget(id, version)
+}
+
+func (r *conformanceRepo) CompleteFirmwareUpdate(id int64, status string) (bool, error) {
+	r.record("CompleteFirmwareUpdate")
+	return r.repo.CompleteFirmwareUpdate(id, status)
+}
+
+func (r *conformanceRepo) RecordSeen(id int64, at time.Time) error {
+	r.record("RecordSeen")
+	return r.repo.RecordSeen(id, at)
+}
+
+func (r *conformanceRepo) RecordSeenOnline(id int64, at time.Time) (bool, error) {
+	r.record("RecordSeenOnline")
+	return r.repo.RecordSeenOnline(id, at)
+}
+
+func (r *conformanceRepo) SetOnlineIfChanged(id int64, online bool) (bool, error) {
+	r.record("SetOnlineIfChanged")
+	return r.repo.SetOnlineIfChanged(id, online)
+}
+
+func (r *conformanceRepo) MarkUnseenOffline(deviceType models.DeviceType, before time.Time) (int64, error) {
+	r.record("MarkUnseenOffline")
+	return r.repo.MarkUnseenOffline(deviceType, before)
+}
+
+func (r *conformanceRepo) EndExpiredMaintenance(now time.Time) (int64, error) {
+	r.record("EndExpiredMaintenance")
+	return r.repo.EndExpiredMaintenance(now)
+}
+
+func (r *conformanceRepo) ClearAlarm(id int64) error {
+	r.record("ClearAlarm")
+	return r.repo.ClearAlarm(id)
+}
+
+func (r *conformanceRepo) Reset(id int64) (*models.Device, error) {
+	r.record("Reset")
+	return r.repo.Reset(id)
+}
+
+func (r *conformanceRepo) ClearExpiredAlarms(level string, before time.Time, skipAcknowledged bool) (int64, error) {
+	r.record("ClearExpiredAlarms")
+	return r.repo.ClearExpiredAlarms(level, before, skipAcknowledged)
+}
+
+func (r *conformanceRepo) AcknowledgeAlarm(id int64, at time.Time) (bool, error) {
+	r.record("AcknowledgeAlarm")
+	return r.repo.AcknowledgeAlarm(id, at)
+}
+
+func (r *conformanceRepo) ScheduleEscalations(level string, delay time.Duration) (int64, error) {
+	r.record("ScheduleEscalations")
+	return r.repo.ScheduleEscalations(level, delay)
+}
+
+func (r *conformanceRepo) ListDueEscalations(now time.Time) ([]*models.Device, error) {
+	r.record("ListDueEscalations")
+	return r.repo.ListDueEscalations(now)
+}
+
+func (r *conformanceRepo) EscalateAlarm(id int64, from, to, reason, triggeredBy string, now time.Time) (bool, error) {
+	r.record("EscalateAlarm")
+	return r.repo.EscalateAlarm(id, from, to, reason, triggeredBy, now)
+}
+
+func (r *conformanceRepo) PruneEscalations() (int64, error) {
+	r.record("PruneEscalations")
+	return r.repo.PruneEscalations()
+}
+
+func (r *conformanceRepo) Vacuum() (*models.VacuumResult, error) {
+	r.record("Vacuum")
+	return r.repo.Vacuum()
+}
+
+func (r *conformanceRepo) Ping(ctx context.Context) error {
+	r.record("Ping")
+	return r.repo.Ping(ctx)
+}
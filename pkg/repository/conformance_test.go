@@ -0,0 +1,1046 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// The conformance suite is one set of behavioural tests every DeviceRepository implementation
+// must pass, so that the service behaves the same whichever database it runs on. Each case gets a
+// fresh, empty repository; its subtest is named after the backend, so a divergence fails as, for
+// example, TestDeviceRepositoryConformance/postgres/not_found.
+//
+// A method added to DeviceRepository must be added to conformanceRepo to compile, and called by
+// a case here for the suite to pass.
+
+// conformanceBackend opens an empty device repository on one database implementation
+type conformanceBackend struct {
+	name string
+	open func(t *testing.T) DeviceRepository
+}
+
+// conformanceBackends lists the backends to run the suite against. SQLite always runs, on a
+// database file in the test's temporary directory since each pooled :memory: connection would
+// get a database of its own. Postgres runs when TEST_POSTGRES_DSN is set.
+func conformanceBackends() []conformanceBackend {
+	backends := []conformanceBackend{{
+		name: "sqlite",
+		open: func(t *testing.T) DeviceRepository { return NewDeviceRepository(newTestDB(t)) },
+	}}
+
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		backends = append(backends, conformanceBackend{
+			name: "postgres",
+			open: func(t *testing.T) DeviceRepository {
+				t.Skip("TEST_POSTGRES_DSN is set, but there is no Postgres device repository to test yet")
+				return nil
+			},
+		})
+	}
+
+	return backends
+}
+
+// conformanceCases are the behaviours every backend must share
+var conformanceCases = []struct {
+	name string
+	run  func(t *testing.T, repo DeviceRepository)
+}{
+	{"create and read back", conformCreateAndRead},
+	{"not found", conformNotFound},
+	{"lookup by ids", conformGetByIDs},
+	{"null handling", conformNullHandling},
+	{"uniqueness", conformUniqueness},
+	{"transaction rollback", conformRollback},
+	{"concurrent updates", conformConcurrentUpdates},
+	{"alarm lifecycle", conformAlarmLifecycle},
+	{"escalation", conformEscalation},
+	{"presence, maintenance and firmware", conformPresenceAndMaintenance},
+	{"counts and deletion", conformCountsAndDeletion},
+	{"archiving", conformArchiving},
+	{"owners", conformOwners},
+	{"parents and children", conformChildren},
+	{"alarm history retention", conformAlarmRetention},
+	{"reset", conformReset},
+}
+
+func TestDeviceRepositoryConformance(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			var mu sync.Mutex
+			called := make(map[string]bool)
+			ran := 0
+
+			for _, tc := range conformanceCases {
+				t.Run(strings.ReplaceAll(tc.name, " ", "_"), func(t *testing.T) {
+					repo := backend.open(t)
+					tc.run(t, &conformanceRepo{repo: repo, mu: &mu, called: called})
+					ran++
+				})
+			}
+
+			// Only a full run, not one narrowed with -run or skipped, shows what the suite covers
+			if ran < len(conformanceCases) {
+				return
+			}
+			var uncovered []string
+			methods := reflect.TypeOf((*DeviceRepository)(nil)).Elem()
+			for i := 0; i < methods.NumMethod(); i++ {
+				if name := methods.Method(i).Name; !called[name] {
+					uncovered = append(uncovered, name)
+				}
+			}
+			sort.Strings(uncovered)
+			if len(uncovered) > 0 {
+				t.Errorf("No conformance case calls %s", strings.Join(uncovered, ", "))
+			}
+		})
+	}
+}
+
+// conformDevice creates a device named name of type deviceType for the conformance cases
+func conformDevice(t *testing.T, repo DeviceRepository, name string, deviceType models.DeviceType) *models.Device {
+	t.Helper()
+
+	device, err := repo.Create(&models.DeviceCreate{Name: name, DeviceType: deviceType, OwnedBy: "owner"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return device
+}
+
+// conformHistory returns the alarm history of a device, newest first
+func conformHistory(t *testing.T, repo DeviceRepository, id int64) []*models.AlarmRecord {
+	t.Helper()
+
+	records, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{DeviceID: id, Limit: 100})
+	if err != nil {
+		t.Fatalf("ListAlarmHistory failed: %v", err)
+	}
+	return records
+}
+
+func conformCreateAndRead(t *testing.T, repo DeviceRepository) {
+	description := "By the back door"
+	created, err := repo.Create(&models.DeviceCreate{Name: "Hall", Description: description, DeviceType: models.DeviceTypeLock, OwnedBy: "alice"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.ID == 0 || created.Version != 1 || created.CreatedAt.IsZero() || !created.NotifyOnAlarm || created.IsOnline {
+		t.Errorf("Expected Create to return the stored device with its defaults, got %+v", created)
+	}
+	conformDevice(t, repo, "Attic", models.DeviceTypeCamera)
+
+	device, err := repo.GetByID(created.ID)
+	if err != nil || device == nil {
+		t.Fatalf("GetByID failed: %v, %v", device, err)
+	}
+	if device.Name != "Hall" || device.Description != description || device.DeviceType != models.DeviceTypeLock || device.OwnedBy != "alice" {
+		t.Errorf("Expected the device as created, got %+v", device)
+	}
+
+	withCount, err := repo.GetByIDWithAlarmCount(created.ID)
+	if err != nil || withCount == nil || withCount.AlarmCount == nil || *withCount.AlarmCount != 0 {
+		t.Errorf("Expected an alarm count of 0, got %+v, %v", withCount, err)
+	}
+	byName, err := repo.GetByOwnerAndName("alice", "Hall")
+	if err != nil || byName == nil || byName.ID != created.ID {
+		t.Errorf("Expected GetByOwnerAndName to find the device, got %+v, %v", byName, err)
+	}
+	raw, err := repo.GetRawByID(created.ID)
+	if err != nil || raw == nil || raw.Columns["name"] == nil || *raw.Columns["name"] != "Hall" {
+		t.Errorf("Expected the raw row to hold the name, got %+v, %v", raw, err)
+	}
+	if exists, err := repo.Exists(created.ID); err != nil || !exists {
+		t.Errorf("Expected the device to exist, got %t, %v", exists, err)
+	}
+
+	all, err := repo.GetAll()
+	if err != nil || len(all) != 2 {
+		t.Errorf("Expected GetAll to return 2 devices, got %d, %v", len(all), err)
+	}
+	opts := &models.DeviceListOptions{Owners: []string{"alice"}, Limit: 10}
+	listed, err := repo.List(opts)
+	if err != nil || len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("Expected List to filter by owner, got %d devices, %v", len(listed), err)
+	}
+	if count, err := repo.Count(opts); err != nil || count != 1 {
+		t.Errorf("Expected Count to match List, got %d, %v", count, err)
+	}
+
+	var visited []string
+	err = repo.EachDevice(context.Background(), &models.DeviceListOptions{SortBy: "name", SortOrder: models.SortAsc}, func(d *models.Device) error {
+		visited = append(visited, d.Name)
+		return nil
+	})
+	if err != nil || strings.Join(visited, ",") != "Attic,Hall" {
+		t.Errorf("Expected EachDevice to visit Attic,Hall, got %v, %v", visited, err)
+	}
+
+	devices, errs := repo.StreamAll(context.Background())
+	var streamed []int64
+	for d := range devices {
+		streamed = append(streamed, d.ID)
+	}
+	if err := <-errs; err != nil || len(streamed) != 2 || streamed[0] != created.ID {
+		t.Errorf("Expected StreamAll to send both devices in ID order, got %v, %v", streamed, err)
+	}
+
+	// An integration may register a device that is already connected
+	connected, err := repo.Create(&models.DeviceCreate{Name: "Porch", DeviceType: models.DeviceTypeCamera, OwnedBy: "bob", IsOnline: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if stored, err := repo.GetByID(connected.ID); err != nil || !connected.IsOnline || !stored.IsOnline {
+		t.Errorf("Expected the device created online, got %+v, %+v, %v", connected, stored, err)
+	}
+	if err := repo.CreateBatch([]*models.DeviceCreate{{Name: "Gate", DeviceType: models.DeviceTypeLock, OwnedBy: "bob", IsOnline: true}}); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if gate, err := repo.GetByOwnerAndName("bob", "Gate"); err != nil || gate == nil || !gate.IsOnline {
+		t.Errorf("Expected the batch-created device online, got %+v, %v", gate, err)
+	}
+}
+
+// conformNotFound pins what each operation does for a device that does not exist. Lookups
+// return nil without an error; alarm and presence writes return ErrDeviceNotFound; other writes
+// do nothing.
+func conformGetByIDs(t *testing.T, repo DeviceRepository) {
+	first := conformDevice(t, repo, "First", models.DeviceTypeCamera)
+	second := conformDevice(t, repo, "Second", models.DeviceTypeLock)
+	third := conformDevice(t, repo, "Third", models.DeviceTypeCamera)
+	const missing = 999
+
+	devices, err := repo.GetByIDs([]int64{third.ID, missing, first.ID, second.ID, third.ID})
+	if err != nil {
+		t.Fatalf("GetByIDs failed: %v", err)
+	}
+	var names []string
+	for _, device := range devices {
+		names = append(names, device.Name)
+	}
+	if strings.Join(names, ",") != "Third,First,Second" {
+		t.Errorf("Expected the devices in the order asked, once each and without the missing one, got %v", names)
+	}
+
+	devices, err = repo.GetByIDs(nil)
+	if err != nil || devices == nil || len(devices) != 0 {
+		t.Errorf("Expected an empty list for no ids, got %v, %v", devices, err)
+	}
+}
+
+func conformNotFound(t *testing.T, repo DeviceRepository) {
+	const missing = 999
+
+	if device, err := repo.GetByID(missing); device != nil || err != nil {
+		t.Errorf("GetByID: expected nil, nil, got %v, %v", device, err)
+	}
+	if device, err := repo.GetByIDWithAlarmCount(missing); device != nil || err != nil {
+		t.Errorf("GetByIDWithAlarmCount: expected nil, nil, got %v, %v", device, err)
+	}
+	if device, err := repo.GetByOwnerAndName("owner", "Missing"); device != nil || err != nil {
+		t.Errorf("GetByOwnerAndName: expected nil, nil, got %v, %v", device, err)
+	}
+	if raw, err := repo.GetRawByID(missing); raw != nil || err != nil {
+		t.Errorf("GetRawByID: expected nil, nil, got %v, %v", raw, err)
+	}
+	if exists, err := repo.Exists(missing); exists || err != nil {
+		t.Errorf("Exists: expected false, nil, got %t, %v", exists, err)
+	}
+
+	name := "Renamed"
+	for _, skipUnchanged := range []bool{false, true} {
+		if changed, err := repo.Update(missing, &models.DeviceUpdate{Name: &name}, skipUnchanged); changed || !errors.Is(err, models.ErrDeviceNotFound) {
+			t.Errorf("Update skipping unchanged %t: expected false, ErrDeviceNotFound, got %t, %v", skipUnchanged, changed, err)
+		}
+	}
+	if err := repo.Delete(missing, models.ChildrenRefuse); err != nil {
+		t.Errorf("Delete: expected no error, got %v", err)
+	}
+	if _, err := repo.TriggerAlarm(missing, models.AlarmLevelInfo, "Door open", "", ""); !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("TriggerAlarm: expected ErrDeviceNotFound, got %v", err)
+	}
+	if _, _, err := repo.TriggerAlarmOnce(missing, "evt-1", models.AlarmLevelInfo, "Door open", "", ""); !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("TriggerAlarmOnce: expected ErrDeviceNotFound, got %v", err)
+	}
+	if err := repo.ClearAlarm(missing); err != nil {
+		t.Errorf("ClearAlarm: expected no error, got %v", err)
+	}
+	if err := repo.RecordSeen(missing, time.Now()); !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("RecordSeen: expected ErrDeviceNotFound, got %v", err)
+	}
+	if err := repo.SetArchived(missing, true); err != nil {
+		t.Errorf("SetArchived: expected no error, got %v", err)
+	}
+	if changed, err := repo.SetOnlineIfChanged(missing, true); changed || err != nil {
+		t.Errorf("SetOnlineIfChanged: expected false, nil, got %t, %v", changed, err)
+	}
+	if acknowledged, err := repo.AcknowledgeAlarm(missing, time.Now()); acknowledged || err != nil {
+		t.Errorf("AcknowledgeAlarm: expected false, nil, got %t, %v", acknowledged, err)
+	}
+	if completed, err := repo.CompleteFirmwareUpdate(missing, models.FirmwareUpdateSuccess); completed || err != nil {
+		t.Errorf("CompleteFirmwareUpdate: expected false, nil, got %t, %v", completed, err)
+	}
+}
+
+// conformNullHandling checks that optional columns are stored as NULL when unset or cleared,
+// and read back as zero values
+func conformNullHandling(t *testing.T, repo DeviceRepository) {
+	device := conformDevice(t, repo, "Kitchen", models.DeviceTypeSmokeDetector)
+
+	if device.Description != "" || device.LastAlarmReason != "" || !device.LastAlarmTime.IsZero() || !device.LastSeenAt.IsZero() ||
+		!device.MaintenanceUntil.IsZero() || device.FirmwareVersion != "" {
+		t.Errorf("Expected a new device's optional fields to be zero, got %+v", device)
+	}
+	raw, err := repo.GetRawByID(device.ID)
+	if err != nil {
+		t.Fatalf("GetRawByID failed: %v", err)
+	}
+	for _, column := range []string{"last_alarm_reason", "last_alarm_time", "last_alarm_level", "maintenance_until", "firmware_version"} {
+		if value := raw.Columns[column]; value != nil {
+			t.Errorf("Expected %s to be NULL, got %q", column, *value)
+		}
+	}
+
+	// An alarm raised without an actor stores no actor
+	if _, err := repo.TriggerAlarm(device.ID, models.AlarmLevelWarning, "Smoke", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if raw, _ = repo.GetRawByID(device.ID); raw.Columns["last_alarm_triggered_by"] != nil {
+		t.Errorf("Expected last_alarm_triggered_by to be NULL, got %q", *raw.Columns["last_alarm_triggered_by"])
+	}
+	if history := conformHistory(t, repo, device.ID); len(history) != 1 || history[0].TriggeredBy != "" {
+		t.Errorf("Expected one alarm with no actor, got %+v", history)
+	}
+
+	// An explicit null clears the reason; an absent one keeps it
+	if _, err := repo.Update(device.ID, &models.DeviceUpdate{LastAlarmReason: models.NullableString{Set: true}}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if raw, _ = repo.GetRawByID(device.ID); raw.Columns["last_alarm_reason"] != nil {
+		t.Errorf("Expected a null reason to be stored as NULL, got %q", *raw.Columns["last_alarm_reason"])
+	}
+	if got, _ := repo.GetByID(device.ID); got.LastAlarmReason != "" || got.LastAlarmLevel != models.AlarmLevelWarning {
+		t.Errorf("Expected only the reason cleared, got %q at %q", got.LastAlarmReason, got.LastAlarmLevel)
+	}
+
+	// Maintenance without an end stores no end
+	if err := repo.SetMaintenance(device.ID, true, time.Time{}); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if got, _ := repo.GetByID(device.ID); !got.MaintenanceMode || !got.MaintenanceUntil.IsZero() {
+		t.Errorf("Expected open-ended maintenance, got %t until %s", got.MaintenanceMode, got.MaintenanceUntil)
+	}
+
+	// A device that never alarmed has no level in use
+	conformDevice(t, repo, "Porch", models.DeviceTypeCamera)
+	levels, err := repo.ListAlarmLevelsInUse(time.Now().Add(-time.Hour))
+	if err != nil || len(levels) != 1 || levels[0] != models.AlarmLevelWarning {
+		t.Errorf("Expected only WARNING in use, got %v, %v", levels, err)
+	}
+}
+
+// conformUniqueness checks the keys that must not repeat: an alarm event per device, a presence
+// row per device and an escalation schedule per device
+func conformUniqueness(t *testing.T, repo DeviceRepository) {
+	first := conformDevice(t, repo, "Front", models.DeviceTypeLock)
+	second := conformDevice(t, repo, "Back", models.DeviceTypeLock)
+
+	if _, duplicate, err := repo.TriggerAlarmOnce(first.ID, "evt-1", models.AlarmLevelInfo, "Opened", "", ""); err != nil || duplicate {
+		t.Fatalf("Expected the first event to be recorded, got duplicate=%t, %v", duplicate, err)
+	}
+	if _, duplicate, err := repo.TriggerAlarmOnce(first.ID, "evt-1", models.AlarmLevelInfo, "Opened", "", ""); err != nil || !duplicate {
+		t.Errorf("Expected the repeated event to be a duplicate, got duplicate=%t, %v", duplicate, err)
+	}
+	if _, duplicate, err := repo.TriggerAlarmOnce(second.ID, "evt-1", models.AlarmLevelInfo, "Opened", "", ""); err != nil || duplicate {
+		t.Errorf("Expected the same event id on another device to be recorded, got duplicate=%t, %v", duplicate, err)
+	}
+	if history := conformHistory(t, repo, first.ID); len(history) != 1 {
+		t.Errorf("Expected the duplicate not to reach the history, got %d alarms", len(history))
+	}
+
+	// A forgotten event id is recorded again
+	if purged, err := repo.PurgeAlarmEvents(time.Now().Add(time.Hour)); err != nil || purged != 2 {
+		t.Errorf("Expected 2 event ids purged, got %d, %v", purged, err)
+	}
+	if _, duplicate, err := repo.TriggerAlarmOnce(first.ID, "evt-1", models.AlarmLevelInfo, "Opened", "", ""); err != nil || duplicate {
+		t.Errorf("Expected a purged event id to be recorded again, got duplicate=%t, %v", duplicate, err)
+	}
+
+	// A client event id is kept with the alarm and answered with it when repeated, for good
+	const clientEventID = "3f2b8c1e-9a4d-4e6f-8b1a-2c3d4e5f6a7b"
+	recorded, duplicate, err := repo.TriggerAlarmForClientEvent(second.ID, clientEventID, models.AlarmLevelInfo, "Jammed", "lock:1", "")
+	if err != nil || duplicate {
+		t.Fatalf("Expected the client event to be recorded, got duplicate=%t, %v", duplicate, err)
+	}
+	if recorded.ID == 0 || recorded.DeviceID != second.ID || recorded.ClientEventID != clientEventID || recorded.Reason != "Jammed" {
+		t.Errorf("Expected the recorded alarm back, got %+v", recorded)
+	}
+	if _, err := repo.PurgeAlarmEvents(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PurgeAlarmEvents failed: %v", err)
+	}
+	repeated, duplicate, err := repo.TriggerAlarmForClientEvent(second.ID, clientEventID, models.AlarmLevelCritical, "Jammed again", "", "")
+	if err != nil || !duplicate {
+		t.Fatalf("Expected the repeated client event to be a duplicate, got duplicate=%t, %v", duplicate, err)
+	}
+	if repeated.ID != recorded.ID || repeated.Level != models.AlarmLevelInfo {
+		t.Errorf("Expected the first alarm for the client event, got %+v", repeated)
+	}
+	if device, _ := repo.GetByID(second.ID); device.LastAlarmLevel != models.AlarmLevelInfo {
+		t.Errorf("Expected the duplicate to leave the device's alarm alone, got level %q", device.LastAlarmLevel)
+	}
+	if _, duplicate, err := repo.TriggerAlarmForClientEvent(first.ID, clientEventID, models.AlarmLevelInfo, "Opened", "", ""); err != nil || duplicate {
+		t.Errorf("Expected the same client event id on another device to be recorded, got duplicate=%t, %v", duplicate, err)
+	}
+	found, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{DeviceID: second.ID, ClientEventID: clientEventID, Limit: 10})
+	if err != nil || len(found) != 1 || found[0].ID != recorded.ID {
+		t.Errorf("Expected the alarm looked up by client event id, got %+v, %v", found, err)
+	}
+
+	// Seeing a device again replaces when it was last seen
+	earlier := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	later := earlier.Add(30 * time.Minute)
+	for _, at := range []time.Time{earlier, later} {
+		if err := repo.RecordSeen(first.ID, at); err != nil {
+			t.Fatalf("RecordSeen failed: %v", err)
+		}
+	}
+	if got, _ := repo.GetByID(first.ID); !got.LastSeenAt.Equal(later) {
+		t.Errorf("Expected last seen at %s, got %s", later, got.LastSeenAt)
+	}
+
+	// An alarm is scheduled to escalate once
+	if scheduled, err := repo.ScheduleEscalations(models.AlarmLevelInfo, time.Minute); err != nil || scheduled != 2 {
+		t.Errorf("Expected 2 alarms scheduled, got %d, %v", scheduled, err)
+	}
+	if scheduled, err := repo.ScheduleEscalations(models.AlarmLevelInfo, time.Minute); err != nil || scheduled != 0 {
+		t.Errorf("Expected scheduling again to add nothing, got %d, %v", scheduled, err)
+	}
+}
+
+// conformRollback checks that writes spanning several rows are all or nothing
+func conformRollback(t *testing.T, repo DeviceRepository) {
+	existing := conformDevice(t, repo, "Garage", models.DeviceTypeCamera)
+
+	// A drifted device whose version has moved on fails the manifest, undoing its creates
+	stale := &models.DeviceDrift{ID: existing.ID, Version: existing.Version + 1, Desired: &models.ManifestDevice{DeviceType: models.DeviceTypeLock}}
+	creates := []*models.DeviceCreate{{Name: "Shed", DeviceType: models.DeviceTypeLock, OwnedBy: "owner"}}
+	if err := repo.ApplyManifest(creates, []*models.DeviceDrift{stale}); !errors.Is(err, models.ErrDeviceChanged) {
+		t.Fatalf("Expected ErrDeviceChanged, got %v", err)
+	}
+	if count, err := repo.Count(&models.DeviceListOptions{}); err != nil || count != 1 {
+		t.Errorf("Expected the manifest's creates rolled back, got %d devices, %v", count, err)
+	}
+	if got, _ := repo.GetByID(existing.ID); got.DeviceType != models.DeviceTypeCamera {
+		t.Errorf("Expected the device unchanged, got %s", got.DeviceType)
+	}
+
+	// The same manifest applies once the version matches
+	stale.Version = existing.Version
+	if err := repo.ApplyManifest(creates, []*models.DeviceDrift{stale}); err != nil {
+		t.Fatalf("ApplyManifest failed: %v", err)
+	}
+	if count, _ := repo.Count(&models.DeviceListOptions{}); count != 2 {
+		t.Errorf("Expected the manifest's device created, got %d devices", count)
+	}
+
+	// An alarm for a missing device leaves its event id unclaimed
+	if _, _, err := repo.TriggerAlarmOnce(999, "evt-1", models.AlarmLevelInfo, "Opened", "", ""); err == nil {
+		t.Fatalf("Expected TriggerAlarmOnce to fail for a missing device")
+	}
+	if purged, err := repo.PurgeAlarmEvents(time.Now().Add(time.Hour)); err != nil || purged != 0 {
+		t.Errorf("Expected the failed alarm's event id rolled back, got %d purged, %v", purged, err)
+	}
+
+	// A batch is created whole
+	batch := []*models.DeviceCreate{
+		{Name: "Loft", DeviceType: models.DeviceTypeThermostat, OwnedBy: "owner"},
+		{Name: "Cellar", DeviceType: models.DeviceTypeThermostat, OwnedBy: "owner"},
+	}
+	if err := repo.CreateBatch(batch); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if count, _ := repo.Count(&models.DeviceListOptions{}); count != 4 {
+		t.Errorf("Expected the batch created, got %d devices", count)
+	}
+}
+
+// conformConcurrentUpdates checks that conditional writes racing on one device take effect once,
+// and that concurrent updates are not lost
+func conformConcurrentUpdates(t *testing.T, repo DeviceRepository) {
+	const workers = 8
+	device := conformDevice(t, repo, "Hallway", models.DeviceTypeMotionSensor)
+
+	race := func(name string, fn func() (bool, error)) {
+		t.Helper()
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		won := 0
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := fn()
+				if err != nil {
+					t.Errorf("%s failed: %v", name, err)
+					return
+				}
+				if ok {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if won != 1 {
+			t.Errorf("Expected exactly one concurrent %s to take effect, got %d", name, won)
+		}
+	}
+
+	race("SetOnlineIfChanged", func() (bool, error) { return repo.SetOnlineIfChanged(device.ID, true) })
+	race("TriggerAlarmOnce", func() (bool, error) {
+		_, duplicate, err := repo.TriggerAlarmOnce(device.ID, "evt-1", models.AlarmLevelWarning, "Motion", "", "")
+		return !duplicate, err
+	})
+	race("AcknowledgeAlarm", func() (bool, error) { return repo.AcknowledgeAlarm(device.ID, time.Now()) })
+	// The same update sent concurrently is written once; the rest find it already applied
+	race("Update", func() (bool, error) {
+		description := "Raced"
+		return repo.Update(device.ID, &models.DeviceUpdate{Description: &description}, true)
+	})
+	if err := repo.SetFirmwareTarget(device.ID, "2.0.0"); err != nil {
+		t.Fatalf("SetFirmwareTarget failed: %v", err)
+	}
+	race("CompleteFirmwareUpdate", func() (bool, error) { return repo.CompleteFirmwareUpdate(device.ID, models.FirmwareUpdateSuccess) })
+
+	before, err := repo.GetByID(device.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			description := "Updated"
+			if _, err := repo.Update(device.ID, &models.DeviceUpdate{Description: &description}, false); err != nil {
+				t.Errorf("Update failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if after, _ := repo.GetByID(device.ID); after.Version != before.Version+workers {
+		t.Errorf("Expected every concurrent update to bump the version, from %d to %d, got %d", before.Version, before.Version+workers, after.Version)
+	}
+}
+
+func conformAlarmLifecycle(t *testing.T, repo DeviceRepository) {
+	smoke := conformDevice(t, repo, "Landing", models.DeviceTypeSmokeDetector)
+	other := conformDevice(t, repo, "Nursery", models.DeviceTypeSmokeDetector)
+	camera := conformDevice(t, repo, "Drive", models.DeviceTypeCamera)
+
+	if _, err := repo.TriggerAlarm(camera.ID, models.AlarmLevelInfo, "Motion", "sensor:drive", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	triggered, err := repo.TriggerAlarmByType(models.DeviceTypeSmokeDetector, models.AlarmLevelCritical, "Fire drill", "admin", "drill")
+	if err != nil || len(triggered) != 2 {
+		t.Fatalf("Expected both smoke detectors alarmed, got %d, %v", len(triggered), err)
+	}
+
+	// Most severe first
+	active, err := repo.ListActiveAlarms(&models.ActiveAlarmFilter{Limit: 10})
+	if err != nil || len(active) != 3 || active[2].ID != camera.ID {
+		t.Fatalf("Expected 3 active alarms with INFO last, got %d, %v", len(active), err)
+	}
+	if count, err := repo.CountAlarmHistory(&models.AlarmHistoryFilter{Levels: []string{models.AlarmLevelCritical}}); err != nil || count != 2 {
+		t.Errorf("Expected 2 CRITICAL alarms in the history, got %d, %v", count, err)
+	}
+	// Alarms without a reason code are grouped under ""
+	if byCode, err := repo.CountAlarmHistoryByReasonCode(&models.AlarmHistoryFilter{}); err != nil || !reflect.DeepEqual(byCode, map[string]int{"drill": 2, "": 1}) {
+		t.Errorf("Expected 2 drill alarms and 1 without a code, got %v, %v", byCode, err)
+	}
+	if byCode, err := repo.CountAlarmHistoryByReasonCode(&models.AlarmHistoryFilter{Levels: []string{models.AlarmLevelInfo}}); err != nil || !reflect.DeepEqual(byCode, map[string]int{"": 1}) {
+		t.Errorf("Expected only the INFO alarm without a code, got %v, %v", byCode, err)
+	}
+	if withCount, _ := repo.GetByIDWithAlarmCount(camera.ID); withCount.AlarmCount == nil || *withCount.AlarmCount != 1 {
+		t.Errorf("Expected the camera's alarm counted")
+	}
+	levels, err := repo.ListAlarmLevelsInUse(time.Now().Add(-time.Hour))
+	sort.Strings(levels)
+	if err != nil || strings.Join(levels, ",") != "CRITICAL,INFO" {
+		t.Errorf("Expected CRITICAL and INFO in use, got %v, %v", levels, err)
+	}
+
+	if err := repo.ClearAlarm(smoke.ID); err != nil {
+		t.Fatalf("ClearAlarm failed: %v", err)
+	}
+	if got, _ := repo.GetByID(smoke.ID); got.AlarmActive || got.LastAlarmReason != "Fire drill" {
+		t.Errorf("Expected the alarm cleared with its details kept, got active=%t reason=%q", got.AlarmActive, got.LastAlarmReason)
+	}
+	if cleared, err := repo.ClearExpiredAlarms(models.AlarmLevelCritical, time.Now().Add(time.Hour), false); err != nil || cleared != 1 {
+		t.Errorf("Expected the other CRITICAL alarm to expire, got %d, %v", cleared, err)
+	}
+	if got, _ := repo.GetByID(other.ID); got.AlarmActive {
+		t.Errorf("Expected the expired alarm cleared")
+	}
+
+	if acknowledged, err := repo.AcknowledgeAlarm(camera.ID, time.Now()); err != nil || !acknowledged {
+		t.Errorf("Expected the camera's alarm acknowledged, got %t, %v", acknowledged, err)
+	}
+	if acknowledged, err := repo.AcknowledgeAlarm(smoke.ID, time.Now()); err != nil || acknowledged {
+		t.Errorf("Expected a cleared alarm not to be acknowledged, got %t, %v", acknowledged, err)
+	}
+}
+
+func conformEscalation(t *testing.T, repo DeviceRepository) {
+	device := conformDevice(t, repo, "Boiler", models.DeviceTypeThermostat)
+	acknowledged := conformDevice(t, repo, "Radiator", models.DeviceTypeThermostat)
+
+	if _, err := repo.TriggerAlarmByType(models.DeviceTypeThermostat, models.AlarmLevelWarning, "Overheating", "", ""); err != nil {
+		t.Fatalf("TriggerAlarmByType failed: %v", err)
+	}
+	if scheduled, err := repo.ScheduleEscalations(models.AlarmLevelWarning, 0); err != nil || scheduled != 2 {
+		t.Fatalf("Expected 2 alarms scheduled, got %d, %v", scheduled, err)
+	}
+	if _, err := repo.AcknowledgeAlarm(acknowledged.ID, time.Now()); err != nil {
+		t.Fatalf("AcknowledgeAlarm failed: %v", err)
+	}
+
+	due := time.Now().Add(time.Minute)
+	devices, err := repo.ListDueEscalations(due)
+	if err != nil || len(devices) != 1 || devices[0].ID != device.ID {
+		t.Fatalf("Expected only the unacknowledged alarm due, got %d, %v", len(devices), err)
+	}
+	if escalated, err := repo.EscalateAlarm(device.ID, models.AlarmLevelWarning, models.AlarmLevelCritical, "Escalated", "", due); err != nil || !escalated {
+		t.Fatalf("Expected the alarm escalated, got %t, %v", escalated, err)
+	}
+	if escalated, err := repo.EscalateAlarm(device.ID, models.AlarmLevelWarning, models.AlarmLevelCritical, "Escalated", "", due); err != nil || escalated {
+		t.Errorf("Expected escalating again to do nothing, got %t, %v", escalated, err)
+	}
+	if got, _ := repo.GetByID(device.ID); got.LastAlarmLevel != models.AlarmLevelCritical {
+		t.Errorf("Expected the alarm raised to CRITICAL, got %s", got.LastAlarmLevel)
+	}
+	if history := conformHistory(t, repo, device.ID); len(history) != 2 || history[0].Level != models.AlarmLevelCritical {
+		t.Errorf("Expected the escalation recorded as an alarm of its own, got %d alarms", len(history))
+	}
+
+	if _, err := repo.ScheduleEscalations(models.AlarmLevelCritical, time.Hour); err != nil {
+		t.Fatalf("ScheduleEscalations failed: %v", err)
+	}
+	if err := repo.ClearAlarm(device.ID); err != nil {
+		t.Fatalf("ClearAlarm failed: %v", err)
+	}
+	if pruned, err := repo.PruneEscalations(); err != nil || pruned != 1 {
+		t.Errorf("Expected the cleared alarm's schedule pruned, got %d, %v", pruned, err)
+	}
+}
+
+func conformPresenceAndMaintenance(t *testing.T, repo DeviceRepository) {
+	camera := conformDevice(t, repo, "Gate", models.DeviceTypeCamera)
+	lock := conformDevice(t, repo, "Door", models.DeviceTypeLock)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if cameOnline, err := repo.RecordSeenOnline(camera.ID, now.Add(-time.Hour)); err != nil || !cameOnline {
+		t.Fatalf("Expected the camera to come online, got %t, %v", cameOnline, err)
+	}
+	if cameOnline, err := repo.RecordSeenOnline(camera.ID, now.Add(-time.Hour)); err != nil || cameOnline {
+		t.Errorf("Expected an online camera not to come online again, got %t, %v", cameOnline, err)
+	}
+	if _, err := repo.RecordSeenOnline(lock.ID, now); err != nil {
+		t.Fatalf("RecordSeenOnline failed: %v", err)
+	}
+	if marked, err := repo.MarkUnseenOffline(models.DeviceTypeCamera, now.Add(-time.Minute)); err != nil || marked != 1 {
+		t.Errorf("Expected the quiet camera marked offline, got %d, %v", marked, err)
+	}
+	if marked, err := repo.MarkUnseenOffline(models.DeviceTypeLock, now.Add(-time.Minute)); err != nil || marked != 0 {
+		t.Errorf("Expected the recently seen lock left online, got %d, %v", marked, err)
+	}
+
+	// An alarm raised during maintenance is suppressed
+	if err := repo.SetMaintenance(lock.ID, true, now.Add(-time.Second)); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if err := repo.SetMaintenance(camera.ID, true, now.Add(time.Hour)); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if suppressed, err := repo.TriggerAlarm(camera.ID, models.AlarmLevelInfo, "Motion", "", ""); err != nil || !suppressed {
+		t.Errorf("Expected the alarm suppressed, got %t, %v", suppressed, err)
+	}
+	if ended, err := repo.EndExpiredMaintenance(now); err != nil || ended != 1 {
+		t.Errorf("Expected the lock's expired maintenance ended, got %d, %v", ended, err)
+	}
+	if got, _ := repo.GetByID(lock.ID); got.MaintenanceMode {
+		t.Errorf("Expected the lock out of maintenance")
+	}
+
+	// Only a successful update changes the firmware version
+	if err := repo.SetFirmwareTarget(lock.ID, "1.1.0"); err != nil {
+		t.Fatalf("SetFirmwareTarget failed: %v", err)
+	}
+	if completed, err := repo.CompleteFirmwareUpdate(lock.ID, models.FirmwareUpdateFailed); err != nil || !completed {
+		t.Fatalf("Expected the update completed as failed, got %t, %v", completed, err)
+	}
+	if got, _ := repo.GetByID(lock.ID); got.FirmwareVersion != "" || got.FirmwareUpdateStatus != models.FirmwareUpdateFailed {
+		t.Errorf("Expected a failed update to keep the version, got %q (%s)", got.FirmwareVersion, got.FirmwareUpdateStatus)
+	}
+}
+
+func conformCountsAndDeletion(t *testing.T, repo DeviceRepository) {
+	if modified, err := repo.LastModified(); err != nil || !modified.IsZero() {
+		t.Errorf("Expected an empty repository never modified, got %s, %v", modified, err)
+	}
+
+	first := conformDevice(t, repo, "Study", models.DeviceTypeCamera)
+	conformDevice(t, repo, "Lounge", models.DeviceTypeCamera)
+	conformDevice(t, repo, "Porch", models.DeviceTypeLock)
+	if _, err := repo.SetOnlineIfChanged(first.ID, true); err != nil {
+		t.Fatalf("SetOnlineIfChanged failed: %v", err)
+	}
+	if _, err := repo.TriggerAlarm(first.ID, models.AlarmLevelWarning, "Tamper", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	counts, err := repo.CountDevices()
+	if err != nil || counts.Total != 3 || counts.Online != 1 || counts.Offline != 2 {
+		t.Errorf("Expected 3 devices with 1 online, got %+v, %v", counts, err)
+	}
+	byType, err := repo.CountDevicesByType()
+	if err != nil || byType[models.DeviceTypeCamera] != 2 || byType[models.DeviceTypeLock] != 1 || len(byType) != 2 {
+		t.Errorf("Expected 2 cameras and 1 lock, got %v, %v", byType, err)
+	}
+	byState, err := repo.CountDevicesByState()
+	if err != nil || len(byState) != 2 || byState[0].DeviceType != models.DeviceTypeCamera || byState[0].Online != 1 || byState[0].AlarmActive != 1 {
+		t.Errorf("Expected cameras first with 1 online and 1 alarming, got %v, %v", byState, err)
+	}
+
+	if err := repo.Delete(first.ID, models.ChildrenRefuse); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if exists, _ := repo.Exists(first.ID); exists {
+		t.Errorf("Expected the device deleted")
+	}
+	if modified, err := repo.LastModified(); err != nil || modified.IsZero() {
+		t.Errorf("Expected the deletion to count as a modification, got %s, %v", modified, err)
+	}
+
+	if result, err := repo.Vacuum(); err != nil || result == nil {
+		t.Errorf("Expected Vacuum to succeed or report it is unsupported, got %+v, %v", result, err)
+	}
+
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Errorf("Expected Ping to succeed, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := repo.Ping(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Ping to give up on a cancelled context, got %v", err)
+	}
+}
+
+// conformArchiving pins that archived devices keep their history and stay readable by id, but are
+// left out of stats and filtered lists and refuse heartbeats and alarms
+func conformArchiving(t *testing.T, repo DeviceRepository) {
+	pool := conformDevice(t, repo, "Pool", models.DeviceTypeSmokeDetector)
+	conformDevice(t, repo, "Hall", models.DeviceTypeSmokeDetector)
+	if _, err := repo.TriggerAlarm(pool.ID, models.AlarmLevelInfo, "Cold", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	if err := repo.SetArchived(pool.ID, true); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	archived, err := repo.GetByID(pool.ID)
+	if err != nil || archived == nil || !archived.Archived {
+		t.Fatalf("Expected the archived device readable by id, got %v, %v", archived, err)
+	}
+	// Archiving again is not a change
+	if err := repo.SetArchived(pool.ID, true); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	if again, _ := repo.GetByID(pool.ID); again.Version != archived.Version {
+		t.Errorf("Expected archiving twice to keep version %d, got %d", archived.Version, again.Version)
+	}
+
+	listed, err := repo.List(&models.DeviceListOptions{Limit: 10, ExcludeArchived: true})
+	if err != nil || len(listed) != 1 || listed[0].Name != "Hall" {
+		t.Errorf("Expected only Hall listed, got %v, %v", listed, err)
+	}
+	if all, err := repo.List(&models.DeviceListOptions{Limit: 10}); err != nil || len(all) != 2 {
+		t.Errorf("Expected both devices without the filter, got %d, %v", len(all), err)
+	}
+	if counts, err := repo.CountDevices(); err != nil || counts.Total != 1 {
+		t.Errorf("Expected the archived device left out of counts, got %+v, %v", counts, err)
+	}
+	if recent, err := repo.ListRecentlyUpdated(10); err != nil || len(recent) != 1 || recent[0].Name != "Hall" {
+		t.Errorf("Expected only Hall recently updated, got %v, %v", recent, err)
+	}
+	if history, err := repo.CountAlarmHistory(&models.AlarmHistoryFilter{DeviceID: pool.ID}); err != nil || history != 1 {
+		t.Errorf("Expected the alarm history kept, got %d, %v", history, err)
+	}
+
+	if _, err := repo.TriggerAlarm(pool.ID, models.AlarmLevelWarning, "Frozen", "", ""); !errors.Is(err, models.ErrDeviceArchived) {
+		t.Errorf("TriggerAlarm: expected ErrDeviceArchived, got %v", err)
+	}
+	if _, _, err := repo.TriggerAlarmOnce(pool.ID, "evt-1", models.AlarmLevelWarning, "Frozen", "", ""); !errors.Is(err, models.ErrDeviceArchived) {
+		t.Errorf("TriggerAlarmOnce: expected ErrDeviceArchived, got %v", err)
+	}
+	if err := repo.RecordSeen(pool.ID, time.Now()); !errors.Is(err, models.ErrDeviceArchived) {
+		t.Errorf("RecordSeen: expected ErrDeviceArchived, got %v", err)
+	}
+	if _, err := repo.RecordSeenOnline(pool.ID, time.Now()); !errors.Is(err, models.ErrDeviceArchived) {
+		t.Errorf("RecordSeenOnline: expected ErrDeviceArchived, got %v", err)
+	}
+
+	// The refused event id was not claimed, so it still works once the device is unarchived
+	if err := repo.SetArchived(pool.ID, false); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	if _, duplicate, err := repo.TriggerAlarmOnce(pool.ID, "evt-1", models.AlarmLevelWarning, "Frozen", "", ""); err != nil || duplicate {
+		t.Errorf("Expected the alarm raised once unarchived, got duplicate %t, %v", duplicate, err)
+	}
+}
+
+func conformOwners(t *testing.T, repo DeviceRepository) {
+	for _, owner := range []string{"bob", "alice", "alice", "Albert", "a_b"} {
+		if _, err := repo.Create(&models.DeviceCreate{Name: "Sensor", DeviceType: models.DeviceTypeSmokeDetector, OwnedBy: owner}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	owners := func(opts *models.OwnerListOptions) string {
+		t.Helper()
+		listed, err := repo.GetDistinctOwners(opts)
+		if err != nil {
+			t.Fatalf("GetDistinctOwners failed: %v", err)
+		}
+		return strings.Join(listed, ",")
+	}
+
+	if listed := owners(&models.OwnerListOptions{Limit: 10}); listed != "Albert,a_b,alice,bob" {
+		t.Errorf("Expected each owner once and in order, got %s", listed)
+	}
+	if listed := owners(&models.OwnerListOptions{Limit: 2, Offset: 1}); listed != "a_b,alice" {
+		t.Errorf("Expected the second page of owners, got %s", listed)
+	}
+	// A prefix ignoring case, with LIKE wildcards matched literally
+	if listed := owners(&models.OwnerListOptions{Search: "al", Limit: 10}); listed != "Albert,alice" {
+		t.Errorf("Expected the owners starting with al, got %s", listed)
+	}
+	if listed := owners(&models.OwnerListOptions{Search: "a_", Limit: 10}); listed != "a_b" {
+		t.Errorf("Expected only a_b, got %s", listed)
+	}
+
+	// The owner itself, then case variants, then owners sharing its first characters
+	for owner, expected := range map[string]string{
+		"alice":  "alice,Albert",
+		"ALICE":  "alice,Albert",
+		"Albert": "Albert,alice",
+		"alcie":  "Albert,alice",
+		"zed":    "",
+	} {
+		matched, err := repo.MatchOwners(owner, 5)
+		if err != nil {
+			t.Fatalf("MatchOwners failed: %v", err)
+		}
+		if strings.Join(matched, ",") != expected {
+			t.Errorf("MatchOwners(%q): expected %q, got %v", owner, expected, matched)
+		}
+	}
+	owned := func(owner string) int {
+		t.Helper()
+		n, err := repo.Count(&models.DeviceListOptions{Owners: []string{owner}})
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		return n
+	}
+
+	if _, err := repo.RenameOwner("nobody", "somebody", false); !errors.Is(err, models.ErrOwnerNotFound) {
+		t.Errorf("Expected ErrOwnerNotFound, got %v", err)
+	}
+	if _, err := repo.RenameOwner("alice", "bob", false); !errors.Is(err, models.ErrOwnerExists) {
+		t.Errorf("Expected ErrOwnerExists, got %v", err)
+	}
+	if owned("alice") != 2 {
+		t.Errorf("Expected a refused rename to change nothing")
+	}
+
+	rows, err := repo.RenameOwner("alice", "alicia", false)
+	if err != nil {
+		t.Fatalf("RenameOwner failed: %v", err)
+	}
+	if rows["devices"] != 2 || owned("alicia") != 2 || owned("alice") != 0 {
+		t.Errorf("Expected both devices renamed, got rows %v", rows)
+	}
+
+	if _, err := repo.RenameOwner("alicia", "bob", true); err != nil {
+		t.Fatalf("RenameOwner with merge failed: %v", err)
+	}
+	if owned("bob") != 3 {
+		t.Errorf("Expected the owners merged, got %d devices owned by bob", owned("bob"))
+	}
+}
+
+func conformChildren(t *testing.T, repo DeviceRepository) {
+	camera := conformDevice(t, repo, "Camera", models.DeviceTypeCamera)
+	button, err := repo.Create(&models.DeviceCreate{Name: "Button", DeviceType: models.DeviceTypeMotionSensor, OwnedBy: "owner",
+		ParentID: &camera.ID, PropagateAlarms: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if button.ParentID == nil || *button.ParentID != camera.ID || !button.PropagateAlarms {
+		t.Errorf("Expected the button created under the camera, got parent %v", button.ParentID)
+	}
+	chime := conformDevice(t, repo, "Chime", models.DeviceTypeLock)
+	if _, err := repo.Update(chime.ID, &models.DeviceUpdate{ParentID: models.SetInt64(button.ID)}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	missing := int64(999999)
+	if _, err := repo.Create(&models.DeviceCreate{Name: "Orphan", DeviceType: models.DeviceTypeLock, OwnedBy: "owner", ParentID: &missing}); !errors.Is(err, models.ErrParentNotFound) {
+		t.Errorf("Create under a missing parent: expected ErrParentNotFound, got %v", err)
+	}
+	for _, parent := range []int64{camera.ID, chime.ID} {
+		if _, err := repo.Update(camera.ID, &models.DeviceUpdate{ParentID: models.SetInt64(parent)}, false); !errors.Is(err, models.ErrParentCycle) {
+			t.Errorf("Update under %d: expected ErrParentCycle, got %v", parent, err)
+		}
+	}
+
+	if device, _ := repo.GetByID(camera.ID); device.ChildCount != 1 || device.ParentID != nil {
+		t.Errorf("Expected the camera to have one child and no parent, got %d and %v", device.ChildCount, device.ParentID)
+	}
+	children, err := repo.ListChildren(button.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("ListChildren failed: %v", err)
+	}
+	if len(children) != 1 || children[0].ID != chime.ID {
+		t.Errorf("Expected the chime as the button's only child, got %v", children)
+	}
+
+	// The button propagates to the camera, the chime does not propagate to the button
+	if _, err := repo.TriggerAlarm(chime.ID, models.AlarmLevelInfo, "Rang", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if len(conformHistory(t, repo, button.ID)) != 0 {
+		t.Errorf("Expected no alarm on the button from the chime")
+	}
+	if _, err := repo.TriggerAlarm(button.ID, models.AlarmLevelWarning, "Tampered", "button", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if history := conformHistory(t, repo, camera.ID); len(history) != 1 || history[0].Reason != "Tampered" || history[0].TriggeredBy != "button" {
+		t.Errorf("Expected the button's alarm on the camera, got %v", history)
+	}
+
+	if err := repo.Delete(camera.ID, models.ChildrenRefuse); !errors.Is(err, models.ErrDeviceHasChildren) {
+		t.Errorf("Delete with children: expected ErrDeviceHasChildren, got %v", err)
+	}
+	if err := repo.Delete(button.ID, models.ChildrenDetach); err != nil {
+		t.Fatalf("Delete detaching children failed: %v", err)
+	}
+	if device, _ := repo.GetByID(chime.ID); device == nil || device.ParentID != nil {
+		t.Errorf("Expected the chime kept without a parent, got %v", device)
+	}
+
+	if _, err := repo.Update(chime.ID, &models.DeviceUpdate{ParentID: models.SetInt64(camera.ID)}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	grandchild, err := repo.Create(&models.DeviceCreate{Name: "Light", DeviceType: models.DeviceTypeThermostat, OwnedBy: "owner", ParentID: &chime.ID})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.Delete(camera.ID, models.ChildrenDelete); err != nil {
+		t.Fatalf("Delete with children failed: %v", err)
+	}
+	for _, id := range []int64{camera.ID, chime.ID, grandchild.ID} {
+		if exists, _ := repo.Exists(id); exists {
+			t.Errorf("Expected device %d deleted with its parent", id)
+		}
+	}
+}
+
+func conformAlarmRetention(t *testing.T, repo DeviceRepository) {
+	forever, oneDay := int64(0), int64(1)
+	policy := conformDevice(t, repo, "Porch", models.DeviceTypeCamera)
+	kept, err := repo.Create(&models.DeviceCreate{Name: "Vault", DeviceType: models.DeviceTypeLock, OwnedBy: "owner", AlarmRetentionDays: &forever})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	short, err := repo.Create(&models.DeviceCreate{Name: "Shed", DeviceType: models.DeviceTypeLock, OwnedBy: "owner", AlarmRetentionDays: &oneDay})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if kept.AlarmRetentionDays == nil || *kept.AlarmRetentionDays != 0 || policy.AlarmRetentionDays != nil {
+		t.Errorf("Expected the retention override stored as given, got %v and %v", kept.AlarmRetentionDays, policy.AlarmRetentionDays)
+	}
+	for _, device := range []*models.Device{policy, kept, short} {
+		for _, reason := range []string{"First", "Second"} {
+			if _, err := repo.TriggerAlarm(device.ID, models.AlarmLevelWarning, reason, "sensor", ""); err != nil {
+				t.Fatalf("TriggerAlarm failed: %v", err)
+			}
+		}
+	}
+
+	// Oldest first
+	var reasons []string
+	err = repo.EachAlarmRecord(context.Background(), &models.AlarmHistoryFilter{DeviceID: kept.ID}, func(record *models.AlarmRecord) error {
+		reasons = append(reasons, record.Reason)
+		return nil
+	})
+	if err != nil || strings.Join(reasons, ",") != "First,Second" {
+		t.Errorf("Expected the history oldest first, got %v, %v", reasons, err)
+	}
+
+	later := time.Now().Add(48 * time.Hour)
+	if pruned, err := repo.PruneAlarmHistory(later, 0); err != nil || pruned != 2 {
+		t.Errorf("Expected only the one-day device's alarms pruned without a policy, got %d, %v", pruned, err)
+	}
+	if pruned, err := repo.PruneAlarmHistory(later, 24*time.Hour); err != nil || pruned != 2 {
+		t.Errorf("Expected the policy to prune the device without an override, got %d, %v", pruned, err)
+	}
+	if records := conformHistory(t, repo, kept.ID); len(records) != 2 {
+		t.Errorf("Expected the device kept forever to keep its history, got %d alarms", len(records))
+	}
+}
+
+func conformReset(t *testing.T, repo DeviceRepository) {
+	created, err := repo.Create(&models.DeviceCreate{Name: "Gate", Description: "Old tenant's gate", DeviceType: models.DeviceTypeLock, OwnedBy: "alice", IsOnline: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := repo.TriggerAlarm(created.ID, models.AlarmLevelWarning, "Forced", "sensor", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if _, err := repo.ScheduleEscalations(models.AlarmLevelWarning, 0); err != nil {
+		t.Fatalf("ScheduleEscalations failed: %v", err)
+	}
+
+	reset, err := repo.Reset(created.ID)
+	if err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if reset.AlarmActive || reset.LastAlarmReason != "" || reset.LastAlarmLevel != "" || !reset.LastAlarmTime.IsZero() || reset.IsOnline || reset.Description != "" {
+		t.Errorf("Expected the mutable state wiped, got %+v", reset)
+	}
+	if reset.Name != created.Name || reset.DeviceType != created.DeviceType || reset.OwnedBy != created.OwnedBy || reset.Version <= created.Version {
+		t.Errorf("Expected the identity kept and the version bumped, got %+v", reset)
+	}
+	if due, err := repo.ListDueEscalations(time.Now().Add(time.Minute)); err != nil || len(due) != 0 {
+		t.Errorf("Expected the pending escalation dropped, got %d, %v", len(due), err)
+	}
+	if records := conformHistory(t, repo, created.ID); len(records) != 1 {
+		t.Errorf("Expected the alarm history kept, got %d alarms", len(records))
+	}
+
+	if _, err := repo.Reset(created.ID + 1000); !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("Expected ErrDeviceNotFound resetting an unknown device, got %v", err)
+	}
+}
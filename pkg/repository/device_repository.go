@@ -0,0 +1,2109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// DeviceRepositoryImpl handles database operations for devices
+type DeviceRepositoryImpl struct {
+	db DB
+	// alarmPresence sets which device types are heard from by alarming
+	alarmPresence models.AlarmPresencePolicy
+}
+
+// DeviceRepositoryOption configures a DeviceRepositoryImpl
+type DeviceRepositoryOption func(*DeviceRepositoryImpl)
+
+// WithAlarmPresence makes an alarm reported by a device count as the device being heard from,
+// for the device types policy sets: their last_seen is updated, and those set to online are
+// marked online, in the same transaction as the alarm. Devices raising an alarm through
+// propagation or by type were not heard from and are left alone.
+func WithAlarmPresence(policy models.AlarmPresencePolicy) DeviceRepositoryOption {
+	return func(r *DeviceRepositoryImpl) {
+		r.alarmPresence = policy
+	}
+}
+
+// NewDeviceRepository creates a new DeviceRepository
+func NewDeviceRepository(db DB, opts ...DeviceRepositoryOption) DeviceRepository {
+	r := &DeviceRepositoryImpl{db: db}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// insertDeviceQuery inserts a device from a DeviceCreate, with the arguments of insertDeviceArgs
+const insertDeviceQuery = `INSERT INTO devices (name, description, device_type, owned_by, is_online, notify_on_alarm, parent_id, propagate_alarms,
+		alarm_retention_days, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ` + sqlNow + `, ` + sqlNow + `)`
+
+// insertDeviceArgs returns the arguments of insertDeviceQuery for device
+func insertDeviceArgs(device *models.DeviceCreate) []interface{} {
+	return []interface{}{device.Name, device.Description, device.DeviceType, device.OwnedBy, device.IsOnline, device.Notifies(),
+		device.ParentID, device.PropagateAlarms, device.AlarmRetentionDays}
+}
+
+// insertDevice inserts device within q once its parent, if any, is known to exist
+func insertDevice(q execQuerier, device *models.DeviceCreate) (sql.Result, error) {
+	if device.ParentID != nil {
+		if err := checkParent(q, 0, *device.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	return q.Exec(insertDeviceQuery, insertDeviceArgs(device)...)
+}
+
+// checkParent checks that the device with the given id can be made a child of parentID: the
+// parent exists and is neither the device nor one of its descendants. A new device has id 0.
+func checkParent(q execQuerier, id, parentID int64) error {
+	// UNION rather than UNION ALL stops the walk should a cycle ever have been stored
+	query := `WITH RECURSIVE ancestors(id) AS (
+			SELECT id FROM devices WHERE id = ?
+			UNION SELECT devices.parent_id FROM devices JOIN ancestors ON devices.id = ancestors.id WHERE devices.parent_id IS NOT NULL
+		)
+		SELECT COUNT(*), COALESCE(MAX(id = ?), FALSE) FROM ancestors`
+
+	var ancestors int
+	var cycle bool
+	if err := q.QueryRow(query, parentID, id).Scan(&ancestors, &cycle); err != nil {
+		return err
+	}
+	if ancestors == 0 {
+		return fmt.Errorf("%w with ID: %d", models.ErrParentNotFound, parentID)
+	}
+	if cycle {
+		return fmt.Errorf("%w: device %d under %d", models.ErrParentCycle, id, parentID)
+	}
+	return nil
+}
+
+// Create adds a new device to the database and returns it as stored, including defaults
+// Parameterised
+func (r *DeviceRepositoryImpl) Create(device *models.DeviceCreate) (*models.Device, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	result, err := insertDevice(tx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read back within the transaction so the response reflects the insert
+	created, err := scanDevice(tx.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// CreateBatch inserts several devices in a single transaction; either all are created or none are
+func (r *DeviceRepositoryImpl) CreateBatch(devices []*models.DeviceCreate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	stmt, err := tx.Prepare(insertDeviceQuery)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Printf("Error closing statement: %v", err)
+		}
+	}()
+
+	for _, device := range devices {
+		if device.ParentID != nil {
+			if err := checkParent(tx, 0, *device.ParentID); err != nil {
+				return err
+			}
+		}
+		if _, err := stmt.Exec(insertDeviceArgs(device)...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ApplyManifest creates the missing devices of a manifest and brings drifted ones in line with it,
+// all in one transaction. A drifted device whose version has moved on since it was compared
+// fails the whole transaction with ErrDeviceChanged.
+func (r *DeviceRepositoryImpl) ApplyManifest(creates []*models.DeviceCreate, changes []*models.DeviceDrift) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	for _, device := range creates {
+		if _, err := insertDevice(tx, device); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range changes {
+		var description sql.NullString
+		if change.Desired.Description != nil {
+			description = sql.NullString{String: *change.Desired.Description, Valid: true}
+		}
+		result, err := tx.Exec(`UPDATE devices SET device_type = ?, description = COALESCE(?, description), updated_at = `+sqlNow+` WHERE id = ? AND version = ?`,
+			change.Desired.DeviceType, description, change.ID, change.Version)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return fmt.Errorf("%w: device %d", models.ErrDeviceChanged, change.ID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// deviceColumnList lists the columns scanned by scanDevice, in order, each with the device field
+// it fills and the constant selected in its place when that field is not loaded. Columns without
+// a placeholder are always loaded.
+var deviceColumnList = []struct{ field, column, placeholder string }{
+	{"id", "id", ""},
+	{"name", "name", "''"},
+	{"description", "description", "''"},
+	{"device_type", "device_type", "''"},
+	{"owned_by", "owned_by", "''"},
+	{"is_online", "is_online", "FALSE"},
+	{"last_alarm_reason", "last_alarm_reason", "NULL"},
+	{"last_alarm_time", "last_alarm_time", "NULL"},
+	{"last_alarm_triggered_by", "last_alarm_triggered_by", "NULL"},
+	{"last_alarm_level", "last_alarm_level", "NULL"},
+	{"alarm_active", "alarm_active", "FALSE"},
+	{"last_alarm_suppressed", "last_alarm_suppressed", "FALSE"},
+	{"alarm_acknowledged_at", "alarm_acknowledged_at", "NULL"},
+	{"maintenance_mode", "maintenance_mode", "FALSE"},
+	{"maintenance_until", "maintenance_until", "NULL"},
+	{"notify_on_alarm", "notify_on_alarm", "FALSE"},
+	{"firmware_version", "firmware_version", "NULL"},
+	{"firmware_target_version", "firmware_target_version", "NULL"},
+	{"firmware_update_status", "firmware_update_status", "NULL"},
+	{"archived", "archived", "FALSE"},
+	{"last_seen_at", "(SELECT last_seen_at FROM device_presence WHERE device_presence.device_id = devices.id)", "NULL"},
+	{"parent_id", "parent_id", "NULL"},
+	{"propagate_alarms", "propagate_alarms", "FALSE"},
+	{"child_count", "(SELECT COUNT(*) FROM devices AS children WHERE children.parent_id = devices.id)", "0"},
+	{"alarm_retention_days", "alarm_retention_days", "NULL"},
+	{"version", "version", "0"},
+	{"created_at", "created_at", ""},
+	{"updated_at", "updated_at", ""},
+}
+
+// deviceColumns lists the columns scanned by scanDevice, in order
+var deviceColumns = projectDeviceColumns(nil)
+
+// projectDeviceColumns returns deviceColumns loading only the given device fields, or every field
+// when there are none. The columns of other fields are replaced by constants, so the row still
+// scans with scanDevice.
+func projectDeviceColumns(fields []string) string {
+	load := make(map[string]bool, len(fields)+1)
+	for _, field := range fields {
+		load[field] = true
+	}
+	// stale is computed from when the device was last seen
+	if load["stale"] {
+		load["last_seen_at"] = true
+	}
+
+	columns := make([]string, len(deviceColumnList))
+	for i, col := range deviceColumnList {
+		if len(fields) == 0 || col.placeholder == "" || load[col.field] {
+			columns[i] = col.column
+		} else {
+			columns[i] = col.placeholder
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// alarmCountJoin joins each device's number of alarms raised, selected with alarmCountColumn. The
+// history is aggregated first so its columns cannot clash with the unqualified deviceColumns.
+const alarmCountJoin = ` LEFT JOIN (SELECT device_id, COUNT(*) AS alarm_count FROM alarm_history
+	WHERE event = '` + models.AlarmEventTriggered + `' GROUP BY device_id) AS alarm_counts
+	ON alarm_counts.device_id = devices.id`
+
+// alarmCountColumn is the column scanned by scanDeviceWithAlarmCount after deviceColumns
+const alarmCountColumn = `, COALESCE(alarm_counts.alarm_count, 0)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execQuerier is satisfied by both *sql.DB and *sql.Tx
+type execQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// scanDevice reads a single device row selected with deviceColumns, followed by any extra columns
+func scanDevice(row rowScanner, extra ...interface{}) (*models.Device, error) {
+	var device models.Device
+	var lastAlarmReason, lastAlarmTime, lastAlarmTriggeredBy, lastAlarmLevel, acknowledgedAt, maintenanceUntil, lastSeenAt sql.NullString
+	var firmwareVersion, firmwareTargetVersion, firmwareUpdateStatus sql.NullString
+	var parentID, alarmRetentionDays sql.NullInt64
+	var createdAt, updatedAt string
+
+	dest := []interface{}{
+		&device.ID,
+		&device.Name,
+		&device.Description,
+		&device.DeviceType,
+		&device.OwnedBy,
+		&device.IsOnline,
+		&lastAlarmReason,
+		&lastAlarmTime,
+		&lastAlarmTriggeredBy,
+		&lastAlarmLevel,
+		&device.AlarmActive,
+		&device.LastAlarmSuppressed,
+		&acknowledgedAt,
+		&device.MaintenanceMode,
+		&maintenanceUntil,
+		&device.NotifyOnAlarm,
+		&firmwareVersion,
+		&firmwareTargetVersion,
+		&firmwareUpdateStatus,
+		&device.Archived,
+		&lastSeenAt,
+		&parentID,
+		&device.PropagateAlarms,
+		&device.ChildCount,
+		&alarmRetentionDays,
+		&device.Version,
+		&createdAt,
+		&updatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	// Alarm columns stay NULL until the first alarm is triggered
+	device.LastAlarmReason = lastAlarmReason.String
+	device.LastAlarmTriggeredBy = lastAlarmTriggeredBy.String
+	device.LastAlarmLevel = lastAlarmLevel.String
+	device.FirmwareVersion = firmwareVersion.String
+	device.FirmwareTargetVersion = firmwareTargetVersion.String
+	device.FirmwareUpdateStatus = firmwareUpdateStatus.String
+	if parentID.Valid {
+		device.ParentID = &parentID.Int64
+	}
+	if alarmRetentionDays.Valid {
+		device.AlarmRetentionDays = &alarmRetentionDays.Int64
+	}
+
+	// Parse time strings
+	device.LastAlarmTime = parseTimestamp(lastAlarmTime.String)
+	device.AlarmAcknowledgedAt = parseTimestamp(acknowledgedAt.String)
+	device.MaintenanceUntil = parseTimestamp(maintenanceUntil.String)
+	device.LastSeenAt = parseTimestamp(lastSeenAt.String)
+	device.CreatedAt = parseTimestamp(createdAt)
+	device.UpdatedAt = parseTimestamp(updatedAt)
+
+	return &device, nil
+}
+
+// scanDeviceWithAlarmCount reads a single device row selected with deviceColumns and alarmCountColumn
+func scanDeviceWithAlarmCount(row rowScanner) (*models.Device, error) {
+	var count int64
+	device, err := scanDevice(row, &count)
+	if err != nil {
+		return nil, err
+	}
+	device.AlarmCount = &count
+
+	return device, nil
+}
+
+// deviceScanner returns the scan function matching the columns listQuery selects for opts
+func deviceScanner(opts *models.DeviceListOptions) func(rowScanner) (*models.Device, error) {
+	if opts.WithAlarmCount {
+		return scanDeviceWithAlarmCount
+	}
+	return func(row rowScanner) (*models.Device, error) { return scanDevice(row) }
+}
+
+// GetByID retrieves a device by its ID
+func (r *DeviceRepositoryImpl) GetByID(id int64) (*models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id = ?`
+
+	device, err := scanDevice(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found
+		}
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// GetByIDs retrieves the devices with the given IDs in the order the IDs are given, which the
+// query itself does not keep. IDs with no device are left out, and a repeated ID yields its
+// device once.
+func (r *DeviceRepositoryImpl) GetByIDs(ids []int64) ([]*models.Device, error) {
+	if len(ids) == 0 {
+		return []*models.Device{}, nil
+	}
+
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id IN (` + placeholders(len(ids)) + `)`
+	found, err := r.queryDevices(query, appendArgs(nil, ids)...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*models.Device, len(found))
+	for _, device := range found {
+		byID[device.ID] = device
+	}
+	devices := make([]*models.Device, 0, len(found))
+	for _, id := range ids {
+		if device, ok := byID[id]; ok {
+			devices = append(devices, device)
+			delete(byID, id)
+		}
+	}
+
+	return devices, nil
+}
+
+// GetRawByID reads a device's row as stored, without parsing any column, or nil when there is
+// no such device. Columns are whatever the table holds, including any added since this code.
+func (r *DeviceRepositoryImpl) GetRawByID(id int64) (*models.RawDeviceRow, error) {
+	columns, err := r.tableColumns("devices")
+	if err != nil {
+		return nil, err
+	}
+
+	// The driver parses columns declared as timestamps; a cast has no declared type, so each
+	// value comes back as the text stored
+	selected := make([]string, len(columns))
+	for i, column := range columns {
+		selected[i] = `CAST("` + column + `" AS TEXT)`
+	}
+
+	rows, err := r.db.Query(`SELECT `+strings.Join(selected, ", ")+` FROM devices WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	// RawBytes are only valid until the next scan, so they are copied out
+	raw := &models.RawDeviceRow{ID: id, Columns: make(map[string]*string, len(columns))}
+	for i, column := range columns {
+		if values[i] == nil {
+			raw.Columns[column] = nil
+			continue
+		}
+		value := string(values[i])
+		raw.Columns[column] = &value
+	}
+
+	return raw, rows.Err()
+}
+
+// tableColumns lists the columns of a table in their declared order
+func (r *DeviceRepositoryImpl) tableColumns(table string) ([]string, error) {
+	rows, err := r.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// GetByOwnerAndName retrieves the device of an owner with the given name, or nil when there is
+// none. Names are not unique within an owner, so several matches return ErrAmbiguousDevice.
+func (r *DeviceRepositoryImpl) GetByOwnerAndName(owner, name string) (*models.Device, error) {
+	devices, err := r.queryDevices(`SELECT `+deviceColumns+` FROM devices WHERE owned_by = ? AND name = ? LIMIT 2`, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(devices) {
+	case 0:
+		return nil, nil
+	case 1:
+		return devices[0], nil
+	default:
+		return nil, fmt.Errorf("%w owner %q and name %q", models.ErrAmbiguousDevice, owner, name)
+	}
+}
+
+// GetByIDWithAlarmCount retrieves a device by its ID along with its number of alarms
+func (r *DeviceRepositoryImpl) GetByIDWithAlarmCount(id int64) (*models.Device, error) {
+	query := `SELECT ` + deviceColumns + alarmCountColumn + ` FROM devices` + alarmCountJoin + ` WHERE id = ?`
+
+	device, err := scanDeviceWithAlarmCount(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found
+		}
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// Exists reports whether a device with the given ID exists without loading the row
+func (r *DeviceRepositoryImpl) Exists(id int64) (bool, error) {
+	query := `SELECT 1 FROM devices WHERE id = ? LIMIT 1`
+
+	var found int
+	err := r.db.QueryRow(query, id).Scan(&found)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// queryDevices runs a query selecting deviceColumns and scans every returned row
+func (r *DeviceRepositoryImpl) queryDevices(query string, args ...interface{}) ([]*models.Device, error) {
+	return r.queryDevicesWith(func(row rowScanner) (*models.Device, error) { return scanDevice(row) }, query, args...)
+}
+
+// queryDevicesWith runs a query and scans every returned row with scan
+func (r *DeviceRepositoryImpl) queryDevicesWith(scan func(rowScanner) (*models.Device, error), query string, args ...interface{}) ([]*models.Device, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	var devices []*models.Device
+
+	for rows.Next() {
+		device, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		devices = append(devices, device)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// GetAll retrieves all devices
+func (r *DeviceRepositoryImpl) GetAll() ([]*models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices ORDER BY created_at DESC`
+	return r.queryDevices(query)
+}
+
+// List retrieves a page of devices
+func (r *DeviceRepositoryImpl) List(opts *models.DeviceListOptions) ([]*models.Device, error) {
+	query, args := listQuery(opts)
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, opts.Limit, opts.Offset)
+
+	return r.queryDevicesWith(deviceScanner(opts), query, args...)
+}
+
+// EachDevice calls fn for every device matching the filters and ordering of opts, one row at a time,
+// so arbitrarily large tables can be exported without holding them in memory. Limit and Offset are
+// ignored. Iteration stops at the first error from fn or when ctx is cancelled.
+func (r *DeviceRepositoryImpl) EachDevice(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	query, args := listQuery(opts)
+	scan := deviceScanner(opts)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		device, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(device); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// StreamAll sends every device, in ID order, on the returned device channel without loading
+// them all. See streamDevices.
+func (r *DeviceRepositoryImpl) StreamAll(ctx context.Context) (<-chan *models.Device, <-chan error) {
+	return streamDevices(ctx, r.EachDevice)
+}
+
+// streamDevices adapts each, a reader's EachDevice, to channels for StreamAll. Devices are sent
+// one at a time as the rows are read, so a slow receiver holds the query open rather than
+// letting devices pile up. Once every device is sent, or the query fails, or ctx is done, the
+// device channel is closed, then the error channel, after receiving the error if there was one.
+func streamDevices(ctx context.Context, each func(context.Context, *models.DeviceListOptions, func(*models.Device) error) error) (<-chan *models.Device, <-chan error) {
+	devices := make(chan *models.Device)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(devices)
+
+		opts := &models.DeviceListOptions{SortBy: "id", SortOrder: models.SortAsc}
+		err := each(ctx, opts, func(device *models.Device) error {
+			// Checked first, since select picks at random when the receiver is also ready
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			select {
+			case devices <- device:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return devices, errs
+}
+
+// listQuery builds the filtered and ordered device query shared by List and EachDevice
+func listQuery(opts *models.DeviceListOptions) (string, []interface{}) {
+	columns := projectDeviceColumns(opts.Fields)
+	query := `SELECT ` + columns + ` FROM devices`
+	if opts.WithAlarmCount {
+		query = `SELECT ` + columns + alarmCountColumn + ` FROM devices` + alarmCountJoin
+	}
+
+	where, args := deviceConditions(opts)
+	query += where + ` ORDER BY ` + orderByClause(opts.SortBy, opts.SortOrder)
+
+	return query, args
+}
+
+// deviceConditions builds the WHERE clause for the filters of opts, or an empty string when none
+// are set. List and Count share it so a count always matches the list it summarises.
+func deviceConditions(opts *models.DeviceListOptions) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if opts.Maintenance != nil {
+		conditions = append(conditions, `maintenance_mode = ?`)
+		args = append(args, *opts.Maintenance)
+	}
+	if len(opts.Names) > 0 {
+		matches := make([]string, 0, len(opts.Names))
+		for _, name := range opts.Names {
+			matches = append(matches, `name LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(name)+"%")
+		}
+		conditions = append(conditions, `(`+strings.Join(matches, ` OR `)+`)`)
+	}
+	if len(opts.IDs) > 0 {
+		conditions = append(conditions, `id IN (`+placeholders(len(opts.IDs))+`)`)
+		args = appendArgs(args, opts.IDs)
+	}
+	if len(opts.DeviceTypes) > 0 {
+		conditions = append(conditions, `device_type IN (`+placeholders(len(opts.DeviceTypes))+`)`)
+		args = appendArgs(args, opts.DeviceTypes)
+	}
+	if opts.ExcludeUnknown {
+		conditions = append(conditions, `device_type != ?`)
+		args = append(args, models.DeviceTypeUnknown)
+	}
+	if opts.ExcludeArchived {
+		conditions = append(conditions, `archived = FALSE`)
+	}
+	if len(opts.Owners) > 0 {
+		conditions = append(conditions, `owned_by IN (`+placeholders(len(opts.Owners))+`)`)
+		args = appendArgs(args, opts.Owners)
+	}
+	if opts.Online != nil {
+		conditions = append(conditions, `is_online = ?`)
+		args = append(args, *opts.Online)
+	}
+	if opts.AlarmActive != nil {
+		conditions = append(conditions, `alarm_active = ?`)
+		args = append(args, *opts.AlarmActive)
+	}
+	if !opts.AlarmedSince.IsZero() {
+		conditions = append(conditions, `last_alarm_time >= ?`)
+		args = append(args, formatTimestamp(opts.AlarmedSince))
+	}
+	if !opts.InactiveSince.IsZero() {
+		// Written as two indexed range checks rather than comparing the later of the two times
+		conditions = append(conditions, `updated_at < ? AND id NOT IN (SELECT device_id FROM device_presence WHERE last_seen_at >= ?)`)
+		args = append(args, formatTimestamp(opts.InactiveSince), formatTimestamp(opts.InactiveSince))
+	}
+	if opts.After != nil {
+		// Matches orderByClause, which sorts descending unless asked for ascending
+		comparison := `<`
+		if opts.SortOrder == models.SortAsc {
+			comparison = `>`
+		}
+		conditions = append(conditions, `(created_at, id) `+comparison+` (?, ?)`)
+		args = append(args, formatTimestamp(opts.After.CreatedAt), opts.After.ID)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// placeholders returns n comma-separated query placeholders for an IN list
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// appendArgs appends values to the query arguments args
+func appendArgs[T any](args []interface{}, values []T) []interface{} {
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+// Count counts the devices matching the filters of opts; paging, ordering and cursors are ignored
+func (r *DeviceRepositoryImpl) Count(opts *models.DeviceListOptions) (int, error) {
+	filters := *opts
+	filters.After = nil
+	where, args := deviceConditions(&filters)
+
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM devices`+where, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ownerMatchPrefixLength is how many leading characters MatchOwners requires owners to share
+const ownerMatchPrefixLength = 2
+
+// matchOwnersQuery is MatchOwners' query. The LIKE prefix is case-insensitive, so SQLite serves it
+// from the owner index with NOCASE collation rather than by scanning devices.
+const matchOwnersQuery = `SELECT owned_by FROM devices WHERE owned_by LIKE ? ESCAPE '\'
+	GROUP BY owned_by ORDER BY owned_by = ? DESC, owned_by = ? COLLATE NOCASE DESC, owned_by LIMIT ?`
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// orderByClause builds an ORDER BY clause from a whitelisted sort field, tie-breaking on id.
+// Unknown fields fall back to created_at so user input never reaches the SQL text.
+func orderByClause(sortBy, sortOrder string) string {
+	if !models.IsValidDeviceSortField(sortBy) {
+		sortBy = "created_at"
+	}
+	direction := "DESC"
+	if sortOrder == models.SortAsc {
+		direction = "ASC"
+	}
+
+	if sortBy == "id" {
+		return "id " + direction
+	}
+	return sortBy + " " + direction + ", id " + direction
+}
+
+// Update updates a device in the database, reporting whether it wrote anything. With
+// skipUnchanged an update that would leave every field as it is is not written. The device is
+// read, compared, merged and written in one transaction, so a concurrent update is never
+// overwritten with stale fields. A missing device is ErrDeviceNotFound.
+func (r *DeviceRepositoryImpl) Update(id int64, device *models.DeviceUpdate, skipUnchanged bool) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	// First, get the current device data
+	currentDevice, err := scanDevice(tx.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+		}
+		return false, err
+	}
+	if skipUnchanged && device.ChangesNothing(currentDevice) {
+		return false, nil
+	}
+
+	// Apply updates to fields that are present
+	name := currentDevice.Name
+	description := currentDevice.Description
+	isOnline := currentDevice.IsOnline
+	ownedBy := currentDevice.OwnedBy
+	deviceType := currentDevice.DeviceType
+	lastAlarmReason := sql.NullString{String: currentDevice.LastAlarmReason, Valid: currentDevice.LastAlarmReason != ""}
+	maintenanceMode := currentDevice.MaintenanceMode
+	maintenanceUntil := currentDevice.MaintenanceUntil
+	notifyOnAlarm := currentDevice.NotifyOnAlarm
+	parentID := currentDevice.ParentID
+	propagateAlarms := currentDevice.PropagateAlarms
+	alarmRetentionDays := currentDevice.AlarmRetentionDays
+
+	if device.Name != nil {
+		name = *device.Name
+	}
+	if device.Description != nil {
+		description = *device.Description
+	}
+	if device.IsOnline != nil {
+		isOnline = *device.IsOnline
+	}
+	if device.OwnedBy != nil {
+		ownedBy = *device.OwnedBy
+	}
+	if device.DeviceType != nil {
+		deviceType = *device.DeviceType
+	}
+	if device.LastAlarmReason.Set {
+		// An explicit null clears the reason
+		lastAlarmReason = sql.NullString{String: device.LastAlarmReason.String, Valid: device.LastAlarmReason.Valid}
+	}
+	if device.MaintenanceMode != nil {
+		maintenanceMode = *device.MaintenanceMode
+		if !maintenanceMode {
+			// Leaving maintenance drops any scheduled end
+			maintenanceUntil = time.Time{}
+		}
+	}
+	if device.MaintenanceUntil != nil {
+		maintenanceUntil = *device.MaintenanceUntil
+	}
+	if device.NotifyOnAlarm != nil {
+		notifyOnAlarm = *device.NotifyOnAlarm
+	}
+	if device.ParentID.Set {
+		// An explicit null detaches the device from its parent
+		parentID = nil
+		if device.ParentID.Valid {
+			if err := checkParent(tx, id, device.ParentID.Int64); err != nil {
+				return false, err
+			}
+			parentID = &device.ParentID.Int64
+		}
+	}
+	if device.PropagateAlarms != nil {
+		propagateAlarms = *device.PropagateAlarms
+	}
+	if device.AlarmRetentionDays.Set {
+		alarmRetentionDays = nil
+		if device.AlarmRetentionDays.Valid {
+			alarmRetentionDays = &device.AlarmRetentionDays.Int64
+		}
+	}
+
+	query := `UPDATE devices SET name = ?, description = ?, device_type = ?, is_online = ?, owned_by = ?, last_alarm_reason = ?, maintenance_mode = ?, maintenance_until = ?, notify_on_alarm = ?,
+		parent_id = ?, propagate_alarms = ?, alarm_retention_days = ?, updated_at = ` + sqlNow + ` WHERE id = ?`
+	_, err = tx.Exec(query, name, description, deviceType, isOnline, ownedBy, lastAlarmReason, maintenanceMode, nullTimestamp(maintenanceUntil), notifyOnAlarm,
+		parentID, propagateAlarms, alarmRetentionDays, id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// nullTimestamp formats t for storage, or NULL for the zero time
+func nullTimestamp(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTimestamp(t), Valid: true}
+}
+
+// Delete removes a device from the database. Its children are dealt with as children says, in the
+// same transaction; with ChildrenRefuse a device that has any is left alone and
+// ErrDeviceHasChildren returned.
+func (r *DeviceRepositoryImpl) Delete(id int64, children models.ChildPolicy) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	switch children {
+	case models.ChildrenDelete:
+		// Grandchildren go too, rather than being left with a parent that no longer exists
+		query := `DELETE FROM devices WHERE id IN (
+			WITH RECURSIVE descendants(id) AS (
+				SELECT id FROM devices WHERE parent_id = ?
+				UNION SELECT devices.id FROM devices JOIN descendants ON devices.parent_id = descendants.id
+			)
+			SELECT id FROM descendants)`
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	case models.ChildrenDetach:
+		if _, err := tx.Exec(`UPDATE devices SET parent_id = NULL, updated_at = `+sqlNow+` WHERE parent_id = ?`, id); err != nil {
+			return err
+		}
+	default:
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM devices WHERE parent_id = ?`, id).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: device %d has %d", models.ErrDeviceHasChildren, id, count)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM devices WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListChildren returns a page of the devices whose parent is the given device, oldest first
+func (r *DeviceRepositoryImpl) ListChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	return r.queryDevices(`SELECT `+deviceColumns+` FROM devices WHERE parent_id = ? ORDER BY id LIMIT ? OFFSET ?`, id, limit, offset)
+}
+
+// inMaintenance is true for devices whose maintenance window is currently open
+const inMaintenance = `(maintenance_mode = TRUE AND (maintenance_until IS NULL OR maintenance_until > ` + sqlNow + `))`
+
+// TriggerAlarm updates a device's alarm information, records who triggered it and appends the
+// alarm to the device's history, with the catalog code its reason was taken from if it has one.
+// The alarm is marked active unless the device is in maintenance, in which case it is recorded
+// as suppressed; the returned flag reports which happened.
+func (r *DeviceRepositoryImpl) TriggerAlarm(id int64, level, reason, triggeredBy, reasonCode string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	suppressed, err := recordAlarm(tx, id, level, reason, triggeredBy, reasonCode, "", r.alarmPresence)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return suppressed, nil
+}
+
+// TriggerAlarmOnce triggers an alarm like TriggerAlarm, unless the device has already processed
+// eventID. A repeated event records nothing and returns the first one's outcome with duplicate set.
+func (r *DeviceRepositoryImpl) TriggerAlarmOnce(id int64, eventID, level, reason, triggeredBy, reasonCode string) (suppressed, duplicate bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	// Claiming the event id first means concurrent retries wait on the write lock, then find it taken
+	claim := `INSERT INTO alarm_events (device_id, event_id, processed_at) VALUES (?, ?, ` + sqlNow + `)
+		ON CONFLICT (device_id, event_id) DO NOTHING`
+	result, err := tx.Exec(claim, id, eventID)
+	if err != nil {
+		return false, false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, false, err
+	}
+	if claimed == 0 {
+		query := `SELECT suppressed FROM alarm_events WHERE device_id = ? AND event_id = ?`
+		if err := tx.QueryRow(query, id, eventID).Scan(&suppressed); err != nil {
+			return false, false, err
+		}
+		return suppressed, true, nil
+	}
+
+	if suppressed, err = recordAlarm(tx, id, level, reason, triggeredBy, reasonCode, "", r.alarmPresence); err != nil {
+		return false, false, err
+	}
+	if _, err := tx.Exec(`UPDATE alarm_events SET suppressed = ? WHERE device_id = ? AND event_id = ?`, suppressed, id, eventID); err != nil {
+		return false, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, false, err
+	}
+
+	return suppressed, false, nil
+}
+
+// TriggerAlarmForClientEvent triggers an alarm like TriggerAlarm, keeping clientEventID with its
+// history entry, and returns that entry. A device records each client event id once: when it
+// already has, nothing is recorded and the alarm first recorded is returned with duplicate set.
+// Unlike the event ids of TriggerAlarmOnce, client event ids are never forgotten while the alarm
+// stays in the history.
+func (r *DeviceRepositoryImpl) TriggerAlarmForClientEvent(id int64, clientEventID, level, reason, triggeredBy, reasonCode string) (record *models.AlarmRecord, duplicate bool, err error) {
+	record, err = r.recordClientAlarm(id, clientEventID, level, reason, triggeredBy, reasonCode)
+	if err == nil || !isUniqueViolation(err) {
+		return record, false, err
+	}
+
+	// A concurrent or earlier request recorded the event first; its alarm is the answer
+	record, err = r.alarmByClientEventID(r.db.QueryRow, id, clientEventID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, true, nil
+}
+
+// recordClientAlarm records an alarm with its client event id in a transaction of its own, so
+// a clash with the unique index rolls back the device's alarm fields too
+func (r *DeviceRepositoryImpl) recordClientAlarm(id int64, clientEventID, level, reason, triggeredBy, reasonCode string) (*models.AlarmRecord, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	if _, err := recordAlarm(tx, id, level, reason, triggeredBy, reasonCode, clientEventID, r.alarmPresence); err != nil {
+		return nil, err
+	}
+	record, err := r.alarmByClientEventID(tx.QueryRow, id, clientEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// alarmByClientEventID reads the alarm a device recorded for clientEventID with queryRow
+func (r *DeviceRepositoryImpl) alarmByClientEventID(queryRow func(string, ...interface{}) *sql.Row, id int64, clientEventID string) (*models.AlarmRecord, error) {
+	query := `SELECT ` + alarmRecordColumns + ` FROM alarm_history WHERE device_id = ? AND client_event_id = ?`
+	return scanAlarmRecord(queryRow(query, id, clientEventID))
+}
+
+// isUniqueViolation reports whether err is SQLite refusing a write that breaks a unique constraint
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// PurgeAlarmEvents forgets the event ids of alarms processed before the given time, returning how
+// many were forgotten
+func (r *DeviceRepositoryImpl) PurgeAlarmEvents(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM alarm_events WHERE processed_at < ?`, formatTimestamp(before))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// recordAlarm sets a device's last alarm and appends it to the alarm history within tx, reporting
+// whether maintenance mode suppressed it. A device whose link to its parent propagates alarms
+// raises the same alarm on the parent, unless the parent is archived. The device is recorded as
+// heard from as its type's presence mode says; the parent only relays the alarm, so it is not.
+func recordAlarm(tx *sql.Tx, id int64, level, reason, triggeredBy, reasonCode, clientEventID string, presence models.AlarmPresencePolicy) (bool, error) {
+	actor := sql.NullString{String: triggeredBy, Valid: triggeredBy != ""}
+	args := []interface{}{reason, level, actor}
+
+	// Coming online is set by the same update, so it changes the device's version only once
+	setOnline := ""
+	if online := presence.TypesWith(models.AlarmPresenceOnline); len(online) > 0 {
+		setOnline = `is_online = is_online OR device_type IN (` + placeholders(len(online)) + `), `
+		for _, deviceType := range online {
+			args = append(args, deviceType)
+		}
+	}
+
+	query := `UPDATE devices SET last_alarm_reason = ?, last_alarm_level = ?, last_alarm_time = ` + sqlNow + `, last_alarm_triggered_by = ?,
+		last_alarm_suppressed = ` + inMaintenance + `, alarm_active = NOT ` + inMaintenance + `, alarm_acknowledged_at = NULL, ` + setOnline + `updated_at = ` + sqlNow + `
+		WHERE id = ? AND archived = FALSE RETURNING last_alarm_suppressed, CASE WHEN propagate_alarms THEN parent_id END, device_type`
+
+	var suppressed bool
+	var propagateTo sql.NullInt64
+	var deviceType models.DeviceType
+	if err := tx.QueryRow(query, append(args, id)...).Scan(&suppressed, &propagateTo, &deviceType); err != nil {
+		if err == sql.ErrNoRows {
+			return false, refusedWrite(tx, id)
+		}
+		return false, err
+	}
+
+	if presence.For(deviceType) != models.AlarmPresenceOff {
+		if err := recordPresence(tx, id, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	historyQuery := `INSERT INTO alarm_history (device_id, level, reason, triggered_by, suppressed, triggered_at, client_event_id, reason_code)
+		VALUES (?, ?, ?, ?, ?, ` + sqlNow + `, ?, ?)`
+	clientEvent := sql.NullString{String: clientEventID, Valid: clientEventID != ""}
+	code := sql.NullString{String: reasonCode, Valid: reasonCode != ""}
+	if _, err := tx.Exec(historyQuery, id, level, reason, actor, suppressed, clientEvent, code); err != nil {
+		return false, err
+	}
+
+	// A new alarm is escalated on its own level's schedule, not the one it replaced
+	if _, err := tx.Exec(`DELETE FROM alarm_escalations WHERE device_id = ?`, id); err != nil {
+		return false, err
+	}
+
+	// Parents are checked against cycles when assigned, so this ends at the first non-propagating
+	// link. The client event id belongs to the device that reported the event.
+	if propagateTo.Valid {
+		if _, err := recordAlarm(tx, propagateTo.Int64, level, reason, triggeredBy, reasonCode, "", nil); err != nil && !errors.Is(err, models.ErrDeviceArchived) {
+			return false, err
+		}
+	}
+
+	return suppressed, nil
+}
+
+// TriggerAlarmByType triggers the same alarm on every device of a type in one transaction,
+// appending a history entry for each. Devices in maintenance are recorded as suppressed,
+// as with TriggerAlarm. The alarm addresses each device directly, so it does not propagate to
+// parents.
+func (r *DeviceRepositoryImpl) TriggerAlarmByType(deviceType models.DeviceType, level, reason, triggeredBy, reasonCode string) ([]*models.TriggeredAlarm, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	actor := sql.NullString{String: triggeredBy, Valid: triggeredBy != ""}
+
+	query := `UPDATE devices SET last_alarm_reason = ?, last_alarm_level = ?, last_alarm_time = ` + sqlNow + `, last_alarm_triggered_by = ?,
+		last_alarm_suppressed = ` + inMaintenance + `, alarm_active = NOT ` + inMaintenance + `, alarm_acknowledged_at = NULL, updated_at = ` + sqlNow + `
+		WHERE device_type = ? AND archived = FALSE RETURNING id, last_alarm_suppressed`
+
+	rows, err := tx.Query(query, reason, level, actor, deviceType)
+	if err != nil {
+		return nil, err
+	}
+	triggered := []*models.TriggeredAlarm{}
+	for rows.Next() {
+		var alarm models.TriggeredAlarm
+		if err := rows.Scan(&alarm.DeviceID, &alarm.Suppressed); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		triggered = append(triggered, &alarm)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	historyQuery := `INSERT INTO alarm_history (device_id, level, reason, triggered_by, suppressed, triggered_at, reason_code)
+		SELECT id, ?, ?, ?, last_alarm_suppressed, ` + sqlNow + `, ? FROM devices WHERE device_type = ? AND archived = FALSE`
+	code := sql.NullString{String: reasonCode, Valid: reasonCode != ""}
+	if _, err := tx.Exec(historyQuery, level, reason, actor, code, deviceType); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM alarm_escalations WHERE device_id IN (SELECT id FROM devices WHERE device_type = ? AND archived = FALSE)`, deviceType); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return triggered, nil
+}
+
+// RecordSeen records that a device was heard from at the given time. It writes only to
+// device_presence, so the device's version and updated_at are unchanged.
+func (r *DeviceRepositoryImpl) RecordSeen(id int64, at time.Time) error {
+	return recordPresence(r.db, id, at)
+}
+
+// recordPresence sets when a device was last seen. Archived devices are refused with
+// ErrDeviceArchived, so hardware that should be switched off is noticed.
+func recordPresence(q execQuerier, id int64, at time.Time) error {
+	query := `INSERT INTO device_presence (device_id, last_seen_at) SELECT id, ? FROM devices WHERE id = ? AND archived = FALSE
+		ON CONFLICT(device_id) DO UPDATE SET last_seen_at = excluded.last_seen_at`
+	result, err := q.Exec(query, formatTimestamp(at), id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return refusedWrite(q, id)
+	}
+	return nil
+}
+
+// refusedWrite explains why a write limited to unarchived devices matched no row: the device is
+// archived, or there is none
+func refusedWrite(q execQuerier, id int64) error {
+	var archived bool
+	if err := q.QueryRow(`SELECT archived FROM devices WHERE id = ?`, id).Scan(&archived); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+		}
+		return err
+	}
+	if archived {
+		return fmt.Errorf("%w with ID: %d", models.ErrDeviceArchived, id)
+	}
+	return fmt.Errorf("device %d was not written", id)
+}
+
+// RecordSeenOnline records that a device was heard from at the given time and marks it online if it
+// was offline, reporting whether it came online. An online device's row is left untouched, so only
+// the transition changes its version and reaches the change feed.
+func (r *DeviceRepositoryImpl) RecordSeenOnline(id int64, at time.Time) (cameOnline bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	if err := recordPresence(tx, id, at); err != nil {
+		return false, err
+	}
+
+	result, err := tx.Exec(`UPDATE devices SET is_online = TRUE, updated_at = `+sqlNow+` WHERE id = ? AND is_online = FALSE`, id)
+	if err != nil {
+		return false, err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return changed > 0, nil
+}
+
+// SetOnlineIfChanged sets a device's online state only if it differs, reporting whether it
+// changed. An unchanged device keeps its updated_at and version, so repeated reports of the same
+// state do not reach the change feed. A missing device reports no change.
+func (r *DeviceRepositoryImpl) SetOnlineIfChanged(id int64, online bool) (changed bool, err error) {
+	query := `UPDATE devices SET is_online = ?, updated_at = ` + sqlNow + ` WHERE id = ? AND is_online != ?`
+	result, err := r.db.Exec(query, online, id, online)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// MarkUnseenOffline marks online devices of a type offline when they were last seen before the
+// given time, returning how many were changed. Devices never seen are left alone, as their state
+// is managed through the API rather than by heartbeats.
+func (r *DeviceRepositoryImpl) MarkUnseenOffline(deviceType models.DeviceType, before time.Time) (int64, error) {
+	query := `UPDATE devices SET is_online = FALSE, updated_at = ` + sqlNow + `
+		WHERE is_online = TRUE AND archived = FALSE AND device_type = ?
+		AND id IN (SELECT device_id FROM device_presence WHERE last_seen_at < ?)`
+
+	result, err := r.db.Exec(query, deviceType, formatTimestamp(before))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// SetArchived archives or unarchives a device. Archiving keeps the device and its alarm history
+// but leaves it out of default lists, stats, the online watchdog and escalation.
+func (r *DeviceRepositoryImpl) SetArchived(id int64, archived bool) error {
+	// Setting the state a device is already in is not a change
+	query := `UPDATE devices SET archived = ?, updated_at = ` + sqlNow + ` WHERE id = ? AND archived != ?`
+	_, err := r.db.Exec(query, archived, id, archived)
+	return err
+}
+
+// RenameOwner renames owner from to to on every device, and in the actor recorded on devices'
+// last alarms, in alarm history and in incident alarms, returning the rows changed per table. It
+// returns ErrOwnerNotFound when no device belongs to from, and ErrOwnerExists when a device already
+// belongs to to, unless merge is set. Each renamed device's version is bumped, but the change feed
+// records the rename once, as an owner change, rather than once per device.
+func (r *DeviceRepositoryImpl) RenameOwner(from, to string, merge bool) (map[string]int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	var owned, existing int64
+	if err := tx.QueryRow(`SELECT COUNT(CASE WHEN owned_by = ? THEN 1 END), COUNT(CASE WHEN owned_by = ? THEN 1 END) FROM devices`,
+		from, to).Scan(&owned, &existing); err != nil {
+		return nil, err
+	}
+	if owned == 0 {
+		return nil, fmt.Errorf("%w: %s", models.ErrOwnerNotFound, from)
+	}
+	if existing > 0 && !merge {
+		return nil, fmt.Errorf("%w: %s", models.ErrOwnerExists, to)
+	}
+
+	rows := make(map[string]int64)
+	// Setting the version skips the devices_record_update trigger, so no per-device changes are recorded
+	statements := []struct {
+		table, query string
+		args         []interface{}
+	}{
+		{"devices", `UPDATE devices SET
+			owned_by = CASE WHEN owned_by = ? THEN ? ELSE owned_by END,
+			last_alarm_triggered_by = CASE WHEN last_alarm_triggered_by = ? THEN ? ELSE last_alarm_triggered_by END,
+			version = version + 1, updated_at = ` + sqlNow + `
+			WHERE owned_by = ? OR last_alarm_triggered_by = ?`, []interface{}{from, to, from, to, from, from}},
+		{"alarm_history", `UPDATE alarm_history SET triggered_by = ? WHERE triggered_by = ?`, []interface{}{to, from}},
+		{"incident_alarms", `UPDATE incident_alarms SET triggered_by = ? WHERE triggered_by = ?`, []interface{}{to, from}},
+	}
+	for _, statement := range statements {
+		result, err := tx.Exec(statement.query, statement.args...)
+		if err != nil {
+			return nil, err
+		}
+		if rows[statement.table], err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO device_changes (entity, entity_id, operation, version) VALUES (?, 0, ?, 0)`,
+		models.ChangeEntityOwner, models.ChangeOperationRename); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SetMaintenance turns maintenance mode on or off. A non-zero until ends maintenance
+// automatically at that time; turning maintenance off always clears it.
+func (r *DeviceRepositoryImpl) SetMaintenance(id int64, enabled bool, until time.Time) error {
+	if !enabled {
+		until = time.Time{}
+	}
+
+	query := `UPDATE devices SET maintenance_mode = ?, maintenance_until = ?, updated_at = ` + sqlNow + ` WHERE id = ?`
+	_, err := r.db.Exec(query, enabled, nullTimestamp(until), id)
+	return err
+}
+
+// SetFirmwareTarget records that firmware version has been pushed to a device, leaving the update
+// pending until the device reports on it
+func (r *DeviceRepositoryImpl) SetFirmwareTarget(id int64, version string) error {
+	query := `UPDATE devices SET firmware_target_version = ?, firmware_update_status = ?, updated_at = ` + sqlNow + ` WHERE id = ?`
+	_, err := r.db.Exec(query, version, models.FirmwareUpdatePending, id)
+	return err
+}
+
+// CompleteFirmwareUpdate ends a device's pending firmware update with status. A successful update
+// makes the target the device's firmware version. It returns false when no update is pending.
+func (r *DeviceRepositoryImpl) CompleteFirmwareUpdate(id int64, status string) (bool, error) {
+	query := `UPDATE devices SET firmware_update_status = ?,
+		firmware_version = CASE WHEN ? = ? THEN firmware_target_version ELSE firmware_version END,
+		updated_at = ` + sqlNow + `
+		WHERE id = ? AND firmware_update_status = ?`
+	result, err := r.db.Exec(query, status, status, models.FirmwareUpdateSuccess, id, models.FirmwareUpdatePending)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// EndExpiredMaintenance turns off maintenance for devices whose window ended at or before now,
+// returning how many were changed
+func (r *DeviceRepositoryImpl) EndExpiredMaintenance(now time.Time) (int64, error) {
+	query := `UPDATE devices SET maintenance_mode = FALSE, maintenance_until = NULL, updated_at = ` + sqlNow + `
+		WHERE maintenance_mode = TRUE AND maintenance_until IS NOT NULL AND maintenance_until <= ?`
+
+	result, err := r.db.Exec(query, formatTimestamp(now))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClearAlarm marks a device's alarm as no longer active, keeping the last alarm details
+func (r *DeviceRepositoryImpl) ClearAlarm(id int64) error {
+	query := `UPDATE devices SET alarm_active = FALSE, updated_at = ` + sqlNow + ` WHERE id = ?`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// Reset wipes a device's mutable state for reprovisioning in one transaction: its last alarm and
+// any pending escalation of it, its online state and its description. Its identity, settings and
+// alarm history are kept. It returns the reset device.
+func (r *DeviceRepositoryImpl) Reset(id int64) (*models.Device, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	query := `UPDATE devices SET description = '', is_online = FALSE,
+		last_alarm_reason = NULL, last_alarm_time = NULL, last_alarm_triggered_by = NULL, last_alarm_level = NULL,
+		alarm_active = FALSE, last_alarm_suppressed = FALSE, alarm_acknowledged_at = NULL,
+		updated_at = ` + sqlNow + ` WHERE id = ?`
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return nil, err
+	}
+	if reset, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if reset == 0 {
+		return nil, fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+	}
+	if _, err := tx.Exec(`DELETE FROM alarm_escalations WHERE device_id = ?`, id); err != nil {
+		return nil, err
+	}
+
+	device, err := scanDevice(tx.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// ClearExpiredAlarms clears active alarms of the given level triggered before the cutoff,
+// recording an auto_cleared entry in the history of each device cleared, and returns how many
+// were cleared. With skipAcknowledged, alarms someone has acknowledged are left active.
+func (r *DeviceRepositoryImpl) ClearExpiredAlarms(level string, before time.Time, skipAcknowledged bool) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	expired := `alarm_active = TRUE AND last_alarm_level = ? AND last_alarm_time < ?`
+	if skipAcknowledged {
+		expired += ` AND alarm_acknowledged_at IS NULL`
+	}
+	args := []interface{}{level, formatTimestamp(before)}
+
+	historyQuery := `INSERT INTO alarm_history (device_id, level, reason, suppressed, triggered_at, event)
+		SELECT id, last_alarm_level, COALESCE(last_alarm_reason, ''), FALSE, ` + sqlNow + `, ?
+		FROM devices WHERE ` + expired
+	if _, err := tx.Exec(historyQuery, append([]interface{}{models.AlarmEventAutoCleared}, args...)...); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`UPDATE devices SET alarm_active = FALSE, updated_at = `+sqlNow+` WHERE `+expired, args...)
+	if err != nil {
+		return 0, err
+	}
+	cleared, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return cleared, nil
+}
+
+// AcknowledgeAlarm records that someone has seen a device's active alarm, which stops it
+// escalating. It reports false when the device has no active, unacknowledged alarm.
+func (r *DeviceRepositoryImpl) AcknowledgeAlarm(id int64, at time.Time) (bool, error) {
+	query := `UPDATE devices SET alarm_acknowledged_at = ?, updated_at = ` + sqlNow + `
+		WHERE id = ? AND alarm_active = TRUE AND alarm_acknowledged_at IS NULL`
+
+	result, err := r.db.Exec(query, formatTimestamp(at), id)
+	if err != nil {
+		return false, err
+	}
+	acknowledged, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if acknowledged > 0 {
+		if _, err := r.db.Exec(`DELETE FROM alarm_escalations WHERE device_id = ?`, id); err != nil {
+			return false, err
+		}
+	}
+
+	return acknowledged > 0, nil
+}
+
+// escalatable is true for devices whose alarm may still escalate
+const escalatable = `alarm_active = TRUE AND alarm_acknowledged_at IS NULL AND archived = FALSE`
+
+// ScheduleEscalations sets when the active, unacknowledged alarms of a level escalate, delay after
+// they were raised, for those not yet scheduled. It returns how many were scheduled.
+func (r *DeviceRepositoryImpl) ScheduleEscalations(level string, delay time.Duration) (int64, error) {
+	query := `INSERT INTO alarm_escalations (device_id, next_escalation_at)
+		SELECT id, strftime('%Y-%m-%dT%H:%M:%SZ', last_alarm_time, ?) FROM devices
+		WHERE ` + escalatable + ` AND last_alarm_level = ? AND last_alarm_time IS NOT NULL
+		ON CONFLICT(device_id) DO NOTHING`
+
+	result, err := r.db.Exec(query, fmt.Sprintf("+%d seconds", int64(delay/time.Second)), level)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ListDueEscalations returns the devices whose alarm is due to escalate at now
+func (r *DeviceRepositoryImpl) ListDueEscalations(now time.Time) ([]*models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE ` + escalatable + `
+		AND id IN (SELECT device_id FROM alarm_escalations WHERE next_escalation_at <= ?) ORDER BY id`
+
+	return r.queryDevices(query, formatTimestamp(now))
+}
+
+// EscalateAlarm raises a device's alarm from one level to another, recording the escalation in the
+// alarm history as an alarm of its own. It does nothing, reporting false, unless the alarm is still
+// active, unacknowledged, at the from level and due at now, so repeating it is harmless.
+func (r *DeviceRepositoryImpl) EscalateAlarm(id int64, from, to, reason, triggeredBy string, now time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	actor := sql.NullString{String: triggeredBy, Valid: triggeredBy != ""}
+	query := `UPDATE devices SET last_alarm_level = ?, last_alarm_reason = ?, last_alarm_time = ` + sqlNow + `, last_alarm_triggered_by = ?, updated_at = ` + sqlNow + `
+		WHERE id = ? AND ` + escalatable + ` AND last_alarm_level = ?
+		AND id IN (SELECT device_id FROM alarm_escalations WHERE next_escalation_at <= ?)`
+	result, err := tx.Exec(query, to, reason, actor, id, from, formatTimestamp(now))
+	if err != nil {
+		return false, err
+	}
+	escalated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if escalated == 0 {
+		return false, nil
+	}
+
+	historyQuery := `INSERT INTO alarm_history (device_id, level, reason, triggered_by, suppressed, triggered_at) VALUES (?, ?, ?, ?, FALSE, ` + sqlNow + `)`
+	if _, err := tx.Exec(historyQuery, id, to, reason, actor); err != nil {
+		return false, err
+	}
+	// The escalated alarm is scheduled afresh under the rule for its new level, if there is one
+	if _, err := tx.Exec(`DELETE FROM alarm_escalations WHERE device_id = ?`, id); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PruneEscalations forgets the schedules of alarms that were cleared or acknowledged, returning
+// how many were forgotten
+func (r *DeviceRepositoryImpl) PruneEscalations() (int64, error) {
+	query := `DELETE FROM alarm_escalations WHERE device_id NOT IN (SELECT id FROM devices WHERE ` + escalatable + `)`
+
+	result, err := r.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ListRecentlyUpdated returns the unarchived devices changed most recently, newest first, reading
+// only as far down idx_devices_updated_at as the limit
+func (r *DeviceRepositoryImpl) ListRecentlyUpdated(limit int) ([]*models.Device, error) {
+	return r.queryDevices(`SELECT `+deviceColumns+` FROM devices WHERE archived = FALSE ORDER BY updated_at DESC, id DESC LIMIT ?`, limit)
+}
+
+// CountDevices counts all devices and how many of them are online
+func (r *DeviceRepositoryImpl) CountDevices() (*models.DeviceCounts, error) {
+	var counts models.DeviceCounts
+	query := `SELECT COUNT(*), COALESCE(SUM(CASE WHEN is_online THEN 1 ELSE 0 END), 0) FROM devices WHERE archived = FALSE`
+	if err := r.db.QueryRow(query).Scan(&counts.Total, &counts.Online); err != nil {
+		return nil, err
+	}
+	counts.Offline = counts.Total - counts.Online
+
+	return &counts, nil
+}
+
+// LastModified returns when the device list last changed: the newest updated_at, or the latest
+// delete recorded in the change feed if that is newer. It is zero when neither exists.
+func (r *DeviceRepositoryImpl) LastModified() (time.Time, error) {
+	query := `SELECT MAX(t) FROM (
+		SELECT MAX(updated_at) AS t FROM devices
+		UNION ALL
+		SELECT MAX(changed_at) FROM device_changes WHERE operation = 'delete'
+	)`
+
+	var lastModified sql.NullString
+	if err := r.db.QueryRow(query).Scan(&lastModified); err != nil {
+		return time.Time{}, err
+	}
+
+	return parseTimestamp(lastModified.String), nil
+}
+
+// CountDevicesByType counts devices per device type. Types with no devices are absent.
+func (r *DeviceRepositoryImpl) CountDevicesByType() (map[models.DeviceType]int, error) {
+	rows, err := r.db.Query(`SELECT device_type, COUNT(*) FROM devices WHERE archived = FALSE GROUP BY device_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	counts := make(map[models.DeviceType]int)
+	for rows.Next() {
+		var deviceType models.DeviceType
+		var count int
+		if err := rows.Scan(&deviceType, &count); err != nil {
+			return nil, err
+		}
+		counts[deviceType] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// CountDevicesByState counts devices per device type, with how many of each are online and
+// alarming, in a single aggregate query. Types with no devices are absent.
+func (r *DeviceRepositoryImpl) CountDevicesByState() ([]*models.DeviceTypeCounts, error) {
+	rows, err := r.db.Query(`SELECT device_type, COUNT(*),
+		COALESCE(SUM(CASE WHEN is_online THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN alarm_active THEN 1 ELSE 0 END), 0)
+		FROM devices WHERE archived = FALSE GROUP BY device_type ORDER BY device_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	var counts []*models.DeviceTypeCounts
+	for rows.Next() {
+		var typeCounts models.DeviceTypeCounts
+		if err := rows.Scan(&typeCounts.DeviceType, &typeCounts.Total, &typeCounts.Online, &typeCounts.AlarmActive); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &typeCounts)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// ListActiveAlarms retrieves a page of the devices with an active alarm, most severe and then
+// most recent first
+func (r *DeviceRepositoryImpl) ListActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE alarm_active = TRUE`
+	var args []interface{}
+
+	if len(filter.Levels) > 0 {
+		query += ` AND last_alarm_level IN (` + placeholders(len(filter.Levels)) + `)`
+		args = appendArgs(args, filter.Levels)
+	}
+	if len(filter.DeviceTypes) > 0 {
+		query += ` AND device_type IN (` + placeholders(len(filter.DeviceTypes)) + `)`
+		args = appendArgs(args, filter.DeviceTypes)
+	}
+
+	order := filter.LevelOrder
+	if len(order) == 0 {
+		order = models.DefaultAlarmLevels().IDs()
+	}
+	// Levels outside the order, such as ones no longer configured, sort after every known level
+	query += ` ORDER BY CASE last_alarm_level`
+	for i := range order {
+		query += ` WHEN ? THEN ` + strconv.Itoa(len(order)-1-i)
+	}
+	query += ` ELSE ` + strconv.Itoa(len(order)) + ` END, last_alarm_time DESC, id LIMIT ? OFFSET ?`
+	args = append(appendArgs(args, order), filter.Limit, filter.Offset)
+
+	return r.queryDevices(query, args...)
+}
+
+// alarmHistoryConditions builds the WHERE condition for the filters of filter, combined with AND
+func alarmHistoryConditions(filter *models.AlarmHistoryFilter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	if filter.DeviceID != 0 {
+		conditions = append(conditions, "device_id = ?")
+		args = append(args, filter.DeviceID)
+	}
+	if len(filter.Levels) > 0 {
+		conditions = append(conditions, "level IN ("+placeholders(len(filter.Levels))+")")
+		args = appendArgs(args, filter.Levels)
+	}
+	if filter.ClientEventID != "" {
+		conditions = append(conditions, "client_event_id = ?")
+		args = append(args, filter.ClientEventID)
+	}
+	if len(filter.ReasonCodes) > 0 {
+		conditions = append(conditions, "reason_code IN ("+placeholders(len(filter.ReasonCodes))+")")
+		args = appendArgs(args, filter.ReasonCodes)
+	}
+	if len(filter.Events) > 0 {
+		conditions = append(conditions, "event IN ("+placeholders(len(filter.Events))+")")
+		args = appendArgs(args, filter.Events)
+	}
+	if !filter.After.IsZero() {
+		conditions = append(conditions, "triggered_at >= ?")
+		args = append(args, formatTimestamp(filter.After))
+	}
+	if !filter.Before.IsZero() {
+		conditions = append(conditions, "triggered_at < ?")
+		args = append(args, formatTimestamp(filter.Before))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// alarmHistoryPageQuery builds the query for a page of the alarm history matching filter, newest
+// first. A cursor is compared as a row value, so SQLite seeks to it on the (device_id,
+// triggered_at) index, whose entries end with the id, instead of stepping over the newer alarms.
+func alarmHistoryPageQuery(filter *models.AlarmHistoryFilter) (string, []interface{}) {
+	where, args := alarmHistoryConditions(filter)
+	if filter.Cursor != nil {
+		where += ` AND (triggered_at, id) < (?, ?)`
+		args = append(args, formatTimestamp(filter.Cursor.TriggeredAt), filter.Cursor.ID)
+	}
+
+	query := `SELECT ` + alarmRecordColumns + ` FROM alarm_history
+		WHERE ` + where + ` ORDER BY triggered_at DESC, id DESC LIMIT ? OFFSET ?`
+	return query, append(args, filter.Limit, filter.Offset)
+}
+
+// ListAlarmHistory retrieves a page of alarm history, newest first.
+// Every filter that is set is combined with AND.
+func (r *DeviceRepositoryImpl) ListAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	query, args := alarmHistoryPageQuery(filter)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	records := []*models.AlarmRecord{}
+	for rows.Next() {
+		record, err := scanAlarmRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// alarmRecordColumns are the alarm_history columns scanAlarmRecord reads, in order
+const alarmRecordColumns = `id, device_id, level, reason, triggered_by, suppressed, triggered_at, client_event_id, reason_code, event`
+
+// scanAlarmRecord reads a single alarm_history row selected as alarmRecordColumns
+func scanAlarmRecord(row rowScanner) (*models.AlarmRecord, error) {
+	var record models.AlarmRecord
+	var triggeredBy, clientEventID, reasonCode sql.NullString
+	var triggeredAt string
+
+	if err := row.Scan(&record.ID, &record.DeviceID, &record.Level, &record.Reason, &triggeredBy, &record.Suppressed, &triggeredAt, &clientEventID, &reasonCode, &record.Event); err != nil {
+		return nil, err
+	}
+	record.TriggeredBy = triggeredBy.String
+	record.ClientEventID = clientEventID.String
+	record.ReasonCode = reasonCode.String
+	record.TriggeredAt = parseTimestamp(triggeredAt)
+
+	return &record, nil
+}
+
+// EachAlarmRecord calls fn for every alarm matching filter, oldest first, reading them from the
+// database as it goes rather than loading them all. Limit, Offset and Cursor are ignored.
+func (r *DeviceRepositoryImpl) EachAlarmRecord(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	where, args := alarmHistoryConditions(filter)
+	query := `SELECT ` + alarmRecordColumns + ` FROM alarm_history
+		WHERE ` + where + ` ORDER BY triggered_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		record, err := scanAlarmRecord(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// PruneAlarmHistory deletes the alarms that have outlived their device's retention as of now,
+// returning how many were deleted. A device's alarm_retention_days applies when set, else
+// retention does; zero keeps alarms forever in either case. Alarms of deleted devices follow
+// retention.
+func (r *DeviceRepositoryImpl) PruneAlarmHistory(now time.Time, retention time.Duration) (int64, error) {
+	query := `DELETE FROM alarm_history WHERE id IN (
+		SELECT alarm_history.id FROM alarm_history LEFT JOIN devices ON devices.id = alarm_history.device_id
+		WHERE CASE
+			WHEN devices.alarm_retention_days IS NULL THEN ? AND alarm_history.triggered_at < ?
+			WHEN devices.alarm_retention_days = 0 THEN FALSE
+			ELSE alarm_history.triggered_at < strftime('%Y-%m-%dT%H:%M:%SZ', ?, '-' || devices.alarm_retention_days || ' days')
+		END)`
+
+	result, err := r.db.Exec(query, retention > 0, formatTimestamp(now.Add(-retention)), formatTimestamp(now))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// CountAlarmHistory counts the alarm history matching filter; Limit, Offset and Cursor are ignored
+func (r *DeviceRepositoryImpl) CountAlarmHistory(filter *models.AlarmHistoryFilter) (int, error) {
+	where, args := alarmHistoryConditions(filter)
+
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM alarm_history WHERE `+where, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CountAlarmHistoryByReasonCode counts the alarm history matching filter per reason code. Alarms
+// given without a code are counted under "". Limit, Offset and Cursor are ignored.
+func (r *DeviceRepositoryImpl) CountAlarmHistoryByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	where, args := alarmHistoryConditions(filter)
+
+	rows, err := r.db.Query(`SELECT COALESCE(reason_code, ''), COUNT(*) FROM alarm_history WHERE `+where+`
+		GROUP BY COALESCE(reason_code, '')`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var code string
+		var count int
+		if err := rows.Scan(&code, &count); err != nil {
+			return nil, err
+		}
+		counts[code] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// ListAlarmLevelsInUse returns the distinct levels of the active alarms and of the alarm history
+// recorded since since, in no particular order
+func (r *DeviceRepositoryImpl) ListAlarmLevelsInUse(since time.Time) ([]string, error) {
+	rows, err := r.db.Query(`SELECT last_alarm_level FROM devices WHERE alarm_active = TRUE AND last_alarm_level IS NOT NULL
+		UNION SELECT level FROM alarm_history WHERE triggered_at >= ?`, formatTimestamp(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []string
+	for rows.Next() {
+		var level string
+		if err := rows.Scan(&level); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+// GetDistinctOwners returns the owners of devices, each once and in order. Archived devices'
+// owners are included.
+func (r *DeviceRepositoryImpl) GetDistinctOwners(opts *models.OwnerListOptions) ([]string, error) {
+	query := `SELECT DISTINCT owned_by FROM devices`
+	var args []interface{}
+	if opts.Search != "" {
+		query += ` WHERE owned_by LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(opts.Search)+"%")
+	}
+	query += ` ORDER BY owned_by LIMIT ? OFFSET ?`
+	args = append(args, opts.Limit, opts.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := []string{}
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+
+	return owners, rows.Err()
+}
+
+// MatchOwners returns up to limit owners sharing the first characters of owner, ignoring ASCII
+// case: first owner itself if any device belongs to it, then owners equal to it ignoring case,
+// then the rest in order. It is a single range scan of the case-insensitive owner index.
+func (r *DeviceRepositoryImpl) MatchOwners(owner string, limit int) ([]string, error) {
+	prefix := owner
+	if runes := []rune(owner); len(runes) > ownerMatchPrefixLength {
+		prefix = string(runes[:ownerMatchPrefixLength])
+	}
+
+	rows, err := r.db.Query(matchOwnersQuery, escapeLike(prefix)+"%", owner, owner, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := []string{}
+	for rows.Next() {
+		var match string
+		if err := rows.Scan(&match); err != nil {
+			return nil, err
+		}
+		owners = append(owners, match)
+	}
+
+	return owners, rows.Err()
+}
+
+// Vacuum rebuilds the database file to reclaim the space left by deleted rows, then refreshes
+// the query planner statistics. On a database other than SQLite it does nothing and says so.
+func (r *DeviceRepositoryImpl) Vacuum() (*models.VacuumResult, error) {
+	var version string
+	if err := r.db.QueryRow(`SELECT sqlite_version()`).Scan(&version); err != nil {
+		return &models.VacuumResult{Message: "vacuum is only supported on SQLite databases"}, nil
+	}
+
+	before, err := r.databaseSize()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec(`VACUUM`); err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec(`PRAGMA optimize`); err != nil {
+		return nil, err
+	}
+	after, err := r.databaseSize()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.VacuumResult{Vacuumed: true, SizeBefore: before, SizeAfter: after}, nil
+}
+
+// Ping checks that the database answers a query reading the devices table, which is cheap
+// however many devices there are. It gives up with ctx's error once ctx is done, including while
+// waiting for a connection.
+func (r *DeviceRepositoryImpl) Ping(ctx context.Context) error {
+	var exists bool
+	return r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM devices)`).Scan(&exists)
+}
+
+// databaseSize returns the size of the main database file from its page count, which also
+// holds for in-memory databases
+func (r *DeviceRepositoryImpl) databaseSize() (int64, error) {
+	var pages, pageSize int64
+	if err := r.db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := r.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
+}
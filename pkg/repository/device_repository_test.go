@@ -0,0 +1,2032 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/database"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// newTestDB opens a fresh SQLite database in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close test database: %v", err)
+		}
+	})
+
+	return db
+}
+
+// createTestDevice inserts a device and returns its ID
+func createTestDevice(t *testing.T, repo DeviceRepository, name string) int64 {
+	t.Helper()
+
+	device, err := repo.Create(&models.DeviceCreate{Name: name, DeviceType: models.DeviceTypeSmokeDetector, OwnedBy: "owner"})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	return device.ID
+}
+
+func TestDeviceRepository_CreateReturnsStoredDevice(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	device, err := repo.Create(&models.DeviceCreate{
+		Name:        "FrontDoor",
+		Description: "Front door camera",
+		DeviceType:  models.DeviceTypeCamera,
+		OwnedBy:     "owner1",
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if device.ID == 0 {
+		t.Errorf("Expected a non-zero ID")
+	}
+	if device.Name != "FrontDoor" || device.Description != "Front door camera" ||
+		device.DeviceType != models.DeviceTypeCamera || device.OwnedBy != "owner1" {
+		t.Errorf("Created device does not match input: %+v", device)
+	}
+	if device.IsOnline || device.AlarmActive {
+		t.Errorf("Expected default offline, inactive device: %+v", device)
+	}
+	if device.CreatedAt.IsZero() || device.UpdatedAt.IsZero() {
+		t.Errorf("Expected timestamps to be populated: %+v", device)
+	}
+
+	stored, err := repo.GetByID(device.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if stored == nil || stored.Name != device.Name {
+		t.Errorf("Expected created device to be readable, got %+v", stored)
+	}
+}
+
+func TestDeviceRepository_ClearExpiredAlarms(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	staleInfo := createTestDevice(t, repo, "Stale")
+	freshInfo := createTestDevice(t, repo, "Fresh")
+	staleCritical := createTestDevice(t, repo, "Critical")
+
+	for _, id := range []int64{staleInfo, freshInfo} {
+		if _, err := repo.TriggerAlarm(id, models.AlarmLevelInfo, "[INFO] Motion", "", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	if _, err := repo.TriggerAlarm(staleCritical, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	// Backdate two of the alarms by two hours
+	if _, err := db.Exec(`UPDATE devices SET last_alarm_time = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-2 hours') WHERE id IN (?, ?)`, staleInfo, staleCritical); err != nil {
+		t.Fatalf("Failed to backdate alarms: %v", err)
+	}
+
+	cleared, err := repo.ClearExpiredAlarms(models.AlarmLevelInfo, time.Now().Add(-time.Hour), false)
+	if err != nil {
+		t.Fatalf("ClearExpiredAlarms failed: %v", err)
+	}
+	if cleared != 1 {
+		t.Errorf("Expected 1 cleared alarm, got %d", cleared)
+	}
+
+	expectedActive := map[int64]bool{staleInfo: false, freshInfo: true, staleCritical: true}
+	for id, expected := range expectedActive {
+		device, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if device.AlarmActive != expected {
+			t.Errorf("Device %s alarm_active = %v; expected %v", device.Name, device.AlarmActive, expected)
+		}
+	}
+
+	records, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{Events: []string{models.AlarmEventAutoCleared}, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAlarmHistory failed: %v", err)
+	}
+	if len(records) != 1 || records[0].DeviceID != staleInfo || records[0].Level != models.AlarmLevelInfo || records[0].Reason != "[INFO] Motion" {
+		t.Fatalf("Expected one auto_cleared entry for the stale INFO alarm, got %+v", records)
+	}
+	devices, err := repo.List(&models.DeviceListOptions{Limit: 10, SortBy: "id", SortOrder: models.SortAsc, WithAlarmCount: true})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if count := devices[0].AlarmCount; count == nil || *count != 1 {
+		t.Errorf("Expected the auto_cleared entry not to count as an alarm, got %v", count)
+	}
+}
+
+func TestDeviceRepository_ClearExpiredAlarmsSkipAcknowledged(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	acknowledged := createTestDevice(t, repo, "Acknowledged")
+	unacknowledged := createTestDevice(t, repo, "Unacknowledged")
+	for _, id := range []int64{acknowledged, unacknowledged} {
+		if _, err := repo.TriggerAlarm(id, models.AlarmLevelInfo, "[INFO] Motion", "", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	if _, err := repo.AcknowledgeAlarm(acknowledged, time.Now()); err != nil {
+		t.Fatalf("AcknowledgeAlarm failed: %v", err)
+	}
+
+	cleared, err := repo.ClearExpiredAlarms(models.AlarmLevelInfo, time.Now().Add(time.Hour), true)
+	if err != nil {
+		t.Fatalf("ClearExpiredAlarms failed: %v", err)
+	}
+	if cleared != 1 {
+		t.Errorf("Expected 1 cleared alarm, got %d", cleared)
+	}
+	if device, _ := repo.GetByID(acknowledged); !device.AlarmActive {
+		t.Errorf("Expected the acknowledged alarm to stay active")
+	}
+	if device, _ := repo.GetByID(unacknowledged); device.AlarmActive {
+		t.Errorf("Expected the unacknowledged alarm to be cleared")
+	}
+
+	count, err := repo.CountAlarmHistory(&models.AlarmHistoryFilter{Events: []string{models.AlarmEventAutoCleared}})
+	if err != nil || count != 1 {
+		t.Errorf("Expected one auto_cleared entry, got %d, %v", count, err)
+	}
+}
+
+func TestDeviceRepository_ListSorting(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	for _, name := range []string{"Bravo", "Alpha", "Charlie"} {
+		createTestDevice(t, repo, name)
+	}
+
+	tests := []struct {
+		name     string
+		sortBy   string
+		order    string
+		expected []string
+	}{
+		{"Name ascending", "name", models.SortAsc, []string{"Alpha", "Bravo", "Charlie"}},
+		{"Name descending", "name", models.SortDesc, []string{"Charlie", "Bravo", "Alpha"}},
+		{"ID ascending", "id", models.SortAsc, []string{"Bravo", "Alpha", "Charlie"}},
+		{"Unknown field falls back to created_at", "password", models.SortAsc, []string{"Bravo", "Alpha", "Charlie"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := repo.List(&models.DeviceListOptions{Limit: 10, SortBy: tc.sortBy, SortOrder: tc.order})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(devices) != len(tc.expected) {
+				t.Fatalf("Expected %d devices, got %d", len(tc.expected), len(devices))
+			}
+			for i, device := range devices {
+				if device.Name != tc.expected[i] {
+					t.Errorf("Position %d: expected %s, got %s", i, tc.expected[i], device.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceRepository_MaintenanceSuppressesAlarms(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	normal := createTestDevice(t, repo, "Normal")
+	indefinite := createTestDevice(t, repo, "Indefinite")
+	scheduled := createTestDevice(t, repo, "Scheduled")
+	expired := createTestDevice(t, repo, "Expired")
+
+	if err := repo.SetMaintenance(indefinite, true, time.Time{}); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if err := repo.SetMaintenance(scheduled, true, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if err := repo.SetMaintenance(expired, true, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+
+	expectedSuppressed := map[int64]bool{normal: false, indefinite: true, scheduled: true, expired: false}
+	for id, expected := range expectedSuppressed {
+		suppressed, err := repo.TriggerAlarm(id, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", "")
+		if err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+		if suppressed != expected {
+			t.Errorf("Device %d suppressed = %v; expected %v", id, suppressed, expected)
+		}
+
+		device, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if device.AlarmActive == expected || device.LastAlarmSuppressed != expected {
+			t.Errorf("Device %s alarm_active = %v, last_alarm_suppressed = %v; expected suppressed %v",
+				device.Name, device.AlarmActive, device.LastAlarmSuppressed, expected)
+		}
+		if device.LastAlarmReason != "[CRITICAL] Smoke" {
+			t.Errorf("Expected suppressed alarms to still be recorded, got reason %q", device.LastAlarmReason)
+		}
+	}
+
+	active, err := repo.ListActiveAlarms(&models.ActiveAlarmFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListActiveAlarms failed: %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("Expected 2 active alarms, got %d", len(active))
+	}
+	paged, err := repo.ListActiveAlarms(&models.ActiveAlarmFilter{Limit: 1, Offset: 1})
+	if err != nil || len(paged) != 1 || len(active) == 2 && paged[0].ID != active[1].ID {
+		t.Errorf("Expected the second active alarm alone, got %d, %v", len(paged), err)
+	}
+}
+
+func TestDeviceRepository_EndExpiredMaintenance(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	indefinite := createTestDevice(t, repo, "Indefinite")
+	scheduled := createTestDevice(t, repo, "Scheduled")
+	expired := createTestDevice(t, repo, "Expired")
+
+	if err := repo.SetMaintenance(indefinite, true, time.Time{}); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if err := repo.SetMaintenance(scheduled, true, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if err := repo.SetMaintenance(expired, true, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+
+	ended, err := repo.EndExpiredMaintenance(time.Now())
+	if err != nil {
+		t.Fatalf("EndExpiredMaintenance failed: %v", err)
+	}
+	if ended != 1 {
+		t.Errorf("Expected 1 device to leave maintenance, got %d", ended)
+	}
+
+	inMaintenance := true
+	devices, err := repo.List(&models.DeviceListOptions{Limit: 10, SortBy: "name", SortOrder: models.SortAsc, Maintenance: &inMaintenance})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(devices) != 2 || devices[0].Name != "Indefinite" || devices[1].Name != "Scheduled" {
+		t.Fatalf("Expected Indefinite and Scheduled to remain in maintenance, got %d devices", len(devices))
+	}
+	if !devices[0].MaintenanceUntil.IsZero() || devices[1].MaintenanceUntil.IsZero() {
+		t.Errorf("Unexpected maintenance_until values: %s, %s", devices[0].MaintenanceUntil, devices[1].MaintenanceUntil)
+	}
+}
+
+func TestDeviceRepository_EachDevice(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	for _, name := range []string{"Bravo", "Alpha", "Charlie"} {
+		createTestDevice(t, repo, name)
+	}
+
+	var names []string
+	opts := &models.DeviceListOptions{Limit: 1, SortBy: "name", SortOrder: models.SortAsc}
+	err := repo.EachDevice(context.Background(), opts, func(device *models.Device) error {
+		names = append(names, device.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachDevice failed: %v", err)
+	}
+	// Limit is ignored: every device is visited in the requested order
+	if strings.Join(names, ",") != "Alpha,Bravo,Charlie" {
+		t.Errorf("Expected Alpha,Bravo,Charlie, got %v", names)
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	err = repo.EachDevice(context.Background(), opts, func(*models.Device) error {
+		visited++
+		return stop
+	})
+	if !errors.Is(err, stop) || visited != 1 {
+		t.Errorf("Expected iteration to stop at the first callback error, got %v after %d devices", err, visited)
+	}
+}
+
+func TestDeviceRepository_StreamAll(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	for _, name := range []string{"Bravo", "Alpha", "Charlie"} {
+		createTestDevice(t, repo, name)
+	}
+
+	devices, errs := repo.StreamAll(context.Background())
+	var names []string
+	for device := range devices {
+		names = append(names, device.Name)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("StreamAll failed: %v", err)
+	}
+	// Devices come in ID order, the order they were created
+	if strings.Join(names, ",") != "Bravo,Alpha,Charlie" {
+		t.Errorf("Expected Bravo,Alpha,Charlie, got %v", names)
+	}
+
+	// Cancelling stops the stream and closes both channels
+	ctx, cancel := context.WithCancel(context.Background())
+	devices, errs = repo.StreamAll(ctx)
+	<-devices
+	cancel()
+	for range devices {
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the stream to end with context.Canceled, got %v", err)
+	}
+	if _, open := <-errs; open {
+		t.Errorf("Expected the error channel to be closed")
+	}
+}
+
+func TestDeviceRepository_EachDeviceMemoryStaysFlat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("seeds a large table")
+	}
+
+	const seeded = 20000
+
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	seed := `WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO devices (name, description, device_type, owned_by)
+		SELECT 'Device' || n, 'Seeded device with a reasonably long description', ?, 'owner' FROM seq`
+	if _, err := db.Exec(seed, seeded, models.DeviceTypeCamera); err != nil {
+		t.Fatalf("Failed to seed devices: %v", err)
+	}
+
+	liveHeap := func() uint64 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	var baseline, peak uint64
+	count := 0
+	err := repo.EachDevice(context.Background(), &models.DeviceListOptions{}, func(*models.Device) error {
+		count++
+		switch {
+		case count == 1000:
+			baseline = liveHeap()
+		case count%5000 == 0:
+			if heap := liveHeap(); heap > peak {
+				peak = heap
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachDevice failed: %v", err)
+	}
+	if count != seeded {
+		t.Fatalf("Expected %d devices, got %d", seeded, count)
+	}
+
+	// Holding every device would add several megabytes; streaming should stay near the baseline
+	if peak > baseline && peak-baseline > 1<<20 {
+		t.Errorf("Live heap grew by %d bytes while streaming %d devices", peak-baseline, seeded)
+	}
+}
+
+func TestDeviceRepository_AlarmHistory(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	deviceID := createTestDevice(t, repo, "Detector")
+	otherID := createTestDevice(t, repo, "Other")
+
+	alarms := []struct {
+		level, reason string
+		age           string
+	}{
+		{models.AlarmLevelInfo, "[INFO] Low battery", "-3 days"},
+		{models.AlarmLevelCritical, "[CRITICAL] Smoke", "-2 days"},
+		{models.AlarmLevelInfo, "[INFO] Test press", "-1 hours"},
+	}
+	for _, alarm := range alarms {
+		if _, err := repo.TriggerAlarm(deviceID, alarm.level, alarm.reason, "sensor:1", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE alarm_history SET triggered_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?) WHERE id = (SELECT MAX(id) FROM alarm_history)`, alarm.age); err != nil {
+			t.Fatalf("Failed to backdate alarm: %v", err)
+		}
+	}
+	if _, err := repo.TriggerAlarm(otherID, models.AlarmLevelInfo, "[INFO] Other device", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name     string
+		filter   models.AlarmHistoryFilter
+		expected []string
+	}{
+		{"All, newest first", models.AlarmHistoryFilter{}, []string{"[INFO] Test press", "[CRITICAL] Smoke", "[INFO] Low battery"}},
+		{"By level", models.AlarmHistoryFilter{Levels: []string{models.AlarmLevelInfo}}, []string{"[INFO] Test press", "[INFO] Low battery"}},
+		{"After", models.AlarmHistoryFilter{After: now.Add(-50 * time.Hour)}, []string{"[INFO] Test press", "[CRITICAL] Smoke"}},
+		{"Before", models.AlarmHistoryFilter{Before: now.Add(-2 * time.Hour)}, []string{"[CRITICAL] Smoke", "[INFO] Low battery"}},
+		{"Level and range combined", models.AlarmHistoryFilter{Levels: []string{models.AlarmLevelInfo}, After: now.Add(-4 * 24 * time.Hour), Before: now.Add(-2 * time.Hour)}, []string{"[INFO] Low battery"}},
+		{"Paged", models.AlarmHistoryFilter{Limit: 1, Offset: 1}, []string{"[CRITICAL] Smoke"}},
+	}
+
+	t.Run("All devices", func(t *testing.T) {
+		records, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{Limit: 2})
+		if err != nil {
+			t.Fatalf("ListAlarmHistory failed: %v", err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 records, got %d", len(records))
+		}
+		if records[0].DeviceID != otherID || records[1].Reason != "[INFO] Test press" {
+			t.Errorf("Expected the latest alarms across devices, got %+v, %+v", records[0], records[1])
+		}
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		filter := models.AlarmHistoryFilter{DeviceID: deviceID, Limit: 1}
+		var reasons []string
+		for pages := 0; pages <= len(alarms); pages++ {
+			records, err := repo.ListAlarmHistory(&filter)
+			if err != nil {
+				t.Fatalf("ListAlarmHistory failed: %v", err)
+			}
+			if len(records) == 0 {
+				break
+			}
+			reasons = append(reasons, records[0].Reason)
+			filter.Cursor = &models.AlarmCursor{TriggeredAt: records[0].TriggeredAt, ID: records[0].ID}
+		}
+		if strings.Join(reasons, "|") != "[INFO] Test press|[CRITICAL] Smoke|[INFO] Low battery" {
+			t.Errorf("Expected each alarm once, newest first, got %v", reasons)
+		}
+	})
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filter := tc.filter
+			filter.DeviceID = deviceID
+			if filter.Limit == 0 {
+				filter.Limit = 100
+			}
+
+			records, err := repo.ListAlarmHistory(&filter)
+			if err != nil {
+				t.Fatalf("ListAlarmHistory failed: %v", err)
+			}
+
+			var reasons []string
+			for _, record := range records {
+				reasons = append(reasons, record.Reason)
+				if record.DeviceID != deviceID || record.TriggeredBy != "sensor:1" || record.TriggeredAt.IsZero() {
+					t.Errorf("Unexpected record: %+v", record)
+				}
+			}
+			if strings.Join(reasons, "|") != strings.Join(tc.expected, "|") {
+				t.Errorf("Expected %v, got %v", tc.expected, reasons)
+			}
+		})
+	}
+}
+
+func TestDeviceRepository_TriggerAlarmForClientEventConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	deviceID := createTestDevice(t, repo, "Doorbell")
+
+	const senders = 8
+	var wg sync.WaitGroup
+	records := make([]*models.AlarmRecord, senders)
+	duplicates := make([]bool, senders)
+	errs := make([]error, senders)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			records[i], duplicates[i], errs[i] = repo.TriggerAlarmForClientEvent(deviceID, "evt-42", models.AlarmLevelInfo, "[INFO] Ring", "", "")
+		}(i)
+	}
+	wg.Wait()
+
+	recorded := 0
+	for i := 0; i < senders; i++ {
+		if errs[i] != nil {
+			t.Fatalf("TriggerAlarmForClientEvent failed: %v", errs[i])
+		}
+		if !duplicates[i] {
+			recorded++
+		}
+		if records[i].ID != records[0].ID {
+			t.Errorf("Expected every sender to get the same alarm, got %d and %d", records[0].ID, records[i].ID)
+		}
+	}
+	if recorded != 1 {
+		t.Errorf("Expected the alarm recorded once, got %d", recorded)
+	}
+	if count, err := repo.CountAlarmHistory(&models.AlarmHistoryFilter{DeviceID: deviceID}); err != nil || count != 1 {
+		t.Errorf("Expected a single alarm in the history, got %d, %v", count, err)
+	}
+}
+
+func TestDeviceRepository_AlarmHistoryPageScales(t *testing.T) {
+	if testing.Short() {
+		t.Skip("seeds a large table")
+	}
+
+	const seeded = 100000
+
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	deviceID := createTestDevice(t, repo, "Busy")
+	otherID := createTestDevice(t, repo, "Neighbour")
+
+	// One alarm a minute, alternating between the two devices and between two levels
+	seed := `WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO alarm_history (device_id, level, reason, triggered_by, triggered_at)
+		SELECT CASE n % 2 WHEN 0 THEN ? ELSE ? END, CASE n % 4 WHEN 0 THEN ? ELSE ? END, 'Seeded alarm', 'sensor:1',
+			strftime('%Y-%m-%dT%H:%M:%SZ', '2024-01-01', '+' || n || ' minutes') FROM seq`
+	if _, err := db.Exec(seed, seeded, deviceID, otherID, models.AlarmLevelCritical, models.AlarmLevelInfo); err != nil {
+		t.Fatalf("Failed to seed alarm history: %v", err)
+	}
+
+	// The plan is what keeps pages fast at any depth: a seek on the device's index to the cursor,
+	// walked in order, with no sort of the matching rows
+	filters := map[string]*models.AlarmHistoryFilter{
+		"Device":           {DeviceID: deviceID},
+		"Device and level": {DeviceID: deviceID, Levels: []string{models.AlarmLevelCritical}},
+		"Device and range": {DeviceID: deviceID, After: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Before: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for name, filter := range filters {
+		filter.Limit = 50
+		filter.Cursor = &models.AlarmCursor{TriggeredAt: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC), ID: seeded / 2}
+
+		query, args := alarmHistoryPageQuery(filter)
+		plan := explainQueryPlan(t, db, query, args...)
+		if !strings.Contains(plan, "USING INDEX idx_alarm_history_device_triggered_at (device_id=? AND triggered_at") {
+			t.Errorf("%s: expected a search of the device's history index, got plan:\n%s", name, plan)
+		}
+		if strings.Contains(plan, "TEMP B-TREE") {
+			t.Errorf("%s: expected no sort, got plan:\n%s", name, plan)
+		}
+	}
+
+	// Walking back through a device's whole history, the last pages take about as long as the
+	// first. The bound is generous so only a query that scans the skipped rows fails it.
+	filter := models.AlarmHistoryFilter{DeviceID: deviceID, Limit: 100}
+	var first, slowest time.Duration
+	read := 0
+	for {
+		start := time.Now()
+		records, err := repo.ListAlarmHistory(&filter)
+		if err != nil {
+			t.Fatalf("ListAlarmHistory failed: %v", err)
+		}
+		elapsed := time.Since(start)
+		if read == 0 {
+			first = elapsed
+		}
+		slowest = max(slowest, elapsed)
+
+		if len(records) == 0 {
+			break
+		}
+		read += len(records)
+		last := records[len(records)-1]
+		filter.Cursor = &models.AlarmCursor{TriggeredAt: last.TriggeredAt, ID: last.ID}
+	}
+	if read != seeded/2 {
+		t.Fatalf("Expected %d alarms, got %d", seeded/2, read)
+	}
+	if slowest > 20*first+50*time.Millisecond {
+		t.Errorf("Expected every page about as fast as the first (%v), the slowest took %v", first, slowest)
+	}
+}
+
+// explainQueryPlan returns the details of the query plan of query, one step per line
+func explainQueryPlan(t *testing.T, db *sql.DB, query string, args ...interface{}) string {
+	t.Helper()
+
+	rows, err := db.Query(`EXPLAIN QUERY PLAN `+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+
+	return strings.Join(plan, "\n")
+}
+
+func TestDeviceRepository_CreateBatch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	batch := []*models.DeviceCreate{
+		{Name: "Kitchen", DeviceType: models.DeviceTypeSmokeDetector, OwnedBy: "owner1"},
+		{Name: "Hallway", DeviceType: models.DeviceTypeSmokeDetector, OwnedBy: "owner1"},
+	}
+	if err := repo.CreateBatch(batch); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	devices, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 devices, got %d", len(devices))
+	}
+
+	// A failing insert rolls back the whole batch
+	if _, err := db.Exec(`CREATE TRIGGER reject_garage BEFORE INSERT ON devices WHEN NEW.name = 'Garage'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	batch = []*models.DeviceCreate{
+		{Name: "Attic", DeviceType: models.DeviceTypeSmokeDetector, OwnedBy: "owner1"},
+		{Name: "Garage", DeviceType: models.DeviceTypeSmokeDetector, OwnedBy: "owner1"},
+	}
+	if err := repo.CreateBatch(batch); err == nil {
+		t.Fatal("Expected CreateBatch to fail")
+	}
+
+	devices, err = repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Errorf("Expected the failed batch to be rolled back, got %d devices", len(devices))
+	}
+}
+
+func TestDeviceRepository_CountDevices(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	counts, err := repo.CountDevices()
+	if err != nil {
+		t.Fatalf("CountDevices failed: %v", err)
+	}
+	if *counts != (models.DeviceCounts{}) {
+		t.Errorf("Expected zero counts for an empty table, got %+v", counts)
+	}
+
+	batch := []*models.DeviceCreate{
+		{Name: "Porch", DeviceType: models.DeviceTypeCamera, OwnedBy: "owner1"},
+		{Name: "Garden", DeviceType: models.DeviceTypeCamera, OwnedBy: "owner1"},
+		{Name: "Door", DeviceType: models.DeviceTypeLock, OwnedBy: "owner1"},
+	}
+	if err := repo.CreateBatch(batch); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	online := true
+	if _, err := repo.Update(1, &models.DeviceUpdate{IsOnline: &online}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	counts, err = repo.CountDevices()
+	if err != nil {
+		t.Fatalf("CountDevices failed: %v", err)
+	}
+	if *counts != (models.DeviceCounts{Total: 3, Online: 1, Offline: 2}) {
+		t.Errorf("Unexpected counts: %+v", counts)
+	}
+
+	byType, err := repo.CountDevicesByType()
+	if err != nil {
+		t.Fatalf("CountDevicesByType failed: %v", err)
+	}
+	if len(byType) != 2 || byType[models.DeviceTypeCamera] != 2 || byType[models.DeviceTypeLock] != 1 {
+		t.Errorf("Unexpected type counts: %v", byType)
+	}
+
+	if _, err := repo.TriggerAlarm(3, models.AlarmLevelWarning, "Jammed", "lock", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	byState, err := repo.CountDevicesByState()
+	if err != nil {
+		t.Fatalf("CountDevicesByState failed: %v", err)
+	}
+	expected := []models.DeviceTypeCounts{
+		{DeviceType: models.DeviceTypeCamera, Total: 2, Online: 1},
+		{DeviceType: models.DeviceTypeLock, Total: 1, AlarmActive: 1},
+	}
+	if len(byState) != len(expected) {
+		t.Fatalf("Expected %d types, got %d", len(expected), len(byState))
+	}
+	for i, typeCounts := range byState {
+		if *typeCounts != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], *typeCounts)
+		}
+	}
+}
+
+func TestDeviceRepository_TriggerAlarmByType(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	kitchen := createTestDevice(t, repo, "Kitchen")
+	hallway := createTestDevice(t, repo, "Hallway")
+	camera, err := repo.Create(&models.DeviceCreate{Name: "Porch", DeviceType: models.DeviceTypeCamera, OwnedBy: "owner"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.SetMaintenance(hallway, true, time.Time{}); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+
+	triggered, err := repo.TriggerAlarmByType(models.DeviceTypeSmokeDetector, models.AlarmLevelCritical, "[CRITICAL] Fire", "panel", "")
+	if err != nil {
+		t.Fatalf("TriggerAlarmByType failed: %v", err)
+	}
+	suppressed := map[int64]bool{}
+	for _, alarm := range triggered {
+		suppressed[alarm.DeviceID] = alarm.Suppressed
+	}
+	if len(triggered) != 2 || suppressed[kitchen] || !suppressed[hallway] {
+		t.Fatalf("Expected both smoke detectors triggered with the hallway suppressed, got %v", suppressed)
+	}
+
+	device, err := repo.GetByID(kitchen)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !device.AlarmActive || device.LastAlarmReason != "[CRITICAL] Fire" || device.LastAlarmTriggeredBy != "panel" {
+		t.Errorf("Expected an active alarm on the kitchen detector, got %+v", device)
+	}
+
+	device, err = repo.GetByID(camera.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if device.AlarmActive || device.LastAlarmReason != "" {
+		t.Errorf("Expected the camera to be untouched, got %+v", device)
+	}
+
+	history, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAlarmHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected a history entry per triggered device, got %d", len(history))
+	}
+}
+
+func TestDeviceRepository_ListRecentlyUpdated(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	var ids []int64
+	for i, name := range []string{"Kitchen", "Hallway", "Garage"} {
+		id := createTestDevice(t, repo, name)
+		ids = append(ids, id)
+		if _, err := db.Exec(`UPDATE devices SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?) WHERE id = ?`,
+			fmt.Sprintf("-%d hours", 3-i), id); err != nil {
+			t.Fatalf("Failed to backdate %s: %v", name, err)
+		}
+	}
+	description := "Renovated"
+	if _, err := repo.Update(ids[0], &models.DeviceUpdate{Description: &description}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	recent, err := repo.ListRecentlyUpdated(2)
+	if err != nil {
+		t.Fatalf("ListRecentlyUpdated failed: %v", err)
+	}
+	var names []string
+	for _, device := range recent {
+		names = append(names, device.Name)
+	}
+	if strings.Join(names, ",") != "Kitchen,Garage" {
+		t.Errorf("Expected the updated device first, then the newest, got %v", names)
+	}
+}
+
+func TestDeviceRepository_LastModified(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	lastModified, err := repo.LastModified()
+	if err != nil {
+		t.Fatalf("LastModified failed: %v", err)
+	}
+	if !lastModified.IsZero() {
+		t.Errorf("Expected no last modification without devices, got %s", lastModified)
+	}
+
+	kitchen := createTestDevice(t, repo, "Kitchen")
+	createTestDevice(t, repo, "Hallway")
+	if _, err := db.Exec(`UPDATE devices SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-1 hour')`); err != nil {
+		t.Fatalf("Failed to backdate devices: %v", err)
+	}
+
+	lastModified, err = repo.LastModified()
+	if err != nil {
+		t.Fatalf("LastModified failed: %v", err)
+	}
+	if age := time.Since(lastModified); age < 59*time.Minute || age > 61*time.Minute {
+		t.Errorf("Expected the newest updated_at an hour ago, got %s", lastModified)
+	}
+
+	// Deleting a device changes the list even though no remaining device was updated
+	if err := repo.Delete(kitchen, models.ChildrenRefuse); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	lastModified, err = repo.LastModified()
+	if err != nil {
+		t.Fatalf("LastModified failed: %v", err)
+	}
+	if time.Since(lastModified) > time.Minute {
+		t.Errorf("Expected the delete to count as a modification, got %s", lastModified)
+	}
+}
+
+func TestDeviceRepository_ListByName(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	createTestDevice(t, repo, "KitchenSmoke")
+	createTestDevice(t, repo, "Kitchen")
+	createTestDevice(t, repo, "Hallway")
+	createTestDevice(t, repo, "Kitchen_2")
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected int
+	}{
+		{"Substring ignoring case", "kitchen", 3},
+		{"No match", "garage", 0},
+		// Wildcards in the filter match literally
+		{"Underscore", "n_", 1},
+		{"Percent", "%", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := repo.List(&models.DeviceListOptions{Names: []string{tc.filter}, Limit: 10, SortBy: "id", SortOrder: models.SortAsc})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(devices) != tc.expected {
+				t.Errorf("Expected %d devices, got %d", tc.expected, len(devices))
+			}
+		})
+	}
+}
+
+func TestDeviceRepository_RecordSeen(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Detector")
+
+	device, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !device.LastSeenAt.IsZero() {
+		t.Errorf("Expected a new device never to have been seen, got %s", device.LastSeenAt)
+	}
+
+	seenAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{seenAt.Add(-time.Minute), seenAt} {
+		if err := repo.RecordSeen(id, at); err != nil {
+			t.Fatalf("RecordSeen failed: %v", err)
+		}
+	}
+
+	device, err = repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !device.LastSeenAt.Equal(seenAt) {
+		t.Errorf("Expected last seen at %s, got %s", seenAt, device.LastSeenAt)
+	}
+	// Presence is not a change to the device itself
+	if device.Version != 1 {
+		t.Errorf("Expected the version to stay at 1, got %d", device.Version)
+	}
+	changes, err := NewChangeRepository(db).List(0, 10)
+	if err != nil {
+		t.Fatalf("List changes failed: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("Expected only the create in the change feed, got %d changes", len(changes))
+	}
+
+	if err := repo.Delete(id, models.ChildrenRefuse); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	var remaining int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM device_presence`).Scan(&remaining); err != nil {
+		t.Fatalf("Failed to count presence rows: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Expected presence to be removed with the device, got %d rows", remaining)
+	}
+}
+
+func TestDeviceRepository_OnlineTransitions(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Detector")
+	unseen := createTestDevice(t, repo, "Unseen")
+	online := true
+	if _, err := repo.Update(unseen, &models.DeviceUpdate{IsOnline: &online}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	seenAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, expected := range []bool{true, false, false} {
+		cameOnline, err := repo.RecordSeenOnline(id, seenAt.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("RecordSeenOnline failed: %v", err)
+		}
+		if cameOnline != expected {
+			t.Errorf("Heartbeat %d: expected came online %t, got %t", i, expected, cameOnline)
+		}
+	}
+
+	device, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !device.IsOnline || device.Version != 2 || !device.LastSeenAt.Equal(seenAt.Add(2*time.Minute)) {
+		t.Errorf("Expected one transition to online and the last heartbeat recorded, got %+v", device)
+	}
+
+	// Not yet quiet for long enough
+	marked, err := repo.MarkUnseenOffline(models.DeviceTypeSmokeDetector, seenAt.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("MarkUnseenOffline failed: %v", err)
+	}
+	if marked != 0 {
+		t.Errorf("Expected no device marked offline, got %d", marked)
+	}
+
+	marked, err = repo.MarkUnseenOffline(models.DeviceTypeSmokeDetector, seenAt.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("MarkUnseenOffline failed: %v", err)
+	}
+	// The device never seen keeps the state it was given through the API
+	if marked != 1 {
+		t.Errorf("Expected only the quiet device marked offline, got %d", marked)
+	}
+	if device, err = repo.GetByID(id); err != nil || device.IsOnline {
+		t.Errorf("Expected the quiet device offline, got %+v (%v)", device, err)
+	}
+	if device, err = repo.GetByID(unseen); err != nil || !device.IsOnline {
+		t.Errorf("Expected the unseen device to stay online, got %+v (%v)", device, err)
+	}
+
+	// create, create, update of unseen, then one change each way for the quiet device
+	changes, err := NewChangeRepository(db).List(0, 10)
+	if err != nil {
+		t.Fatalf("List changes failed: %v", err)
+	}
+	if len(changes) != 5 {
+		t.Errorf("Expected 5 changes, got %d", len(changes))
+	}
+}
+
+func TestDeviceRepository_AlarmPresence(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db, WithAlarmPresence(models.AlarmPresencePolicy{
+		models.DeviceTypeSmokeDetector: models.AlarmPresenceOnline,
+		models.DeviceTypeLock:          models.AlarmPresenceSeen,
+	}))
+	// The detector's parent relays its alarms without having been heard from
+	parent := createTestDevice(t, repo, "Hub")
+	devices := map[models.DeviceType]int64{}
+	for _, deviceType := range []models.DeviceType{models.DeviceTypeSmokeDetector, models.DeviceTypeLock, models.DeviceTypeCamera} {
+		create := &models.DeviceCreate{Name: string(deviceType), DeviceType: deviceType, OwnedBy: "owner"}
+		if deviceType == models.DeviceTypeSmokeDetector {
+			create.ParentID, create.PropagateAlarms = &parent, true
+		}
+		device, err := repo.Create(create)
+		if err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		devices[deviceType] = device.ID
+	}
+	detector := devices[models.DeviceTypeSmokeDetector]
+
+	before := time.Now().Add(-time.Second)
+	for _, id := range devices {
+		if _, err := repo.TriggerAlarm(id, "WARNING", "[WARNING] Tampered", "sensor", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+
+	expected := map[models.DeviceType]struct{ online, seen bool }{
+		models.DeviceTypeSmokeDetector: {online: true, seen: true},
+		models.DeviceTypeLock:          {seen: true},
+		models.DeviceTypeCamera:        {},
+	}
+	for deviceType, want := range expected {
+		device, err := repo.GetByID(devices[deviceType])
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if device.IsOnline != want.online || device.LastSeenAt.After(before) != want.seen {
+			t.Errorf("%s: expected online %t and seen %t, got online %t and last seen %s",
+				deviceType, want.online, want.seen, device.IsOnline, device.LastSeenAt)
+		}
+	}
+	if device, err := repo.GetByID(parent); err != nil || device.IsOnline || !device.LastSeenAt.IsZero() {
+		t.Errorf("Expected the parent's presence untouched, got %+v (%v)", device, err)
+	}
+	// Coming online is part of the alarm's update, not a change of its own
+	if device, err := repo.GetByID(detector); err != nil || device.Version != 2 {
+		t.Errorf("Expected version 2 after the alarm, got %+v (%v)", device, err)
+	}
+
+	// The watchdog then takes a device that goes quiet offline again
+	marked, err := repo.MarkUnseenOffline(models.DeviceTypeSmokeDetector, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("MarkUnseenOffline failed: %v", err)
+	}
+	if marked != 1 {
+		t.Errorf("Expected the detector marked offline, got %d", marked)
+	}
+}
+
+func TestDeviceRepository_Escalation(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Detector")
+	acked := createTestDevice(t, repo, "Acked")
+	for _, device := range []int64{id, acked} {
+		if _, err := repo.TriggerAlarm(device, "WARNING", "[WARNING] Smoke", "sensor", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	if ok, err := repo.AcknowledgeAlarm(acked, time.Now()); err != nil || !ok {
+		t.Fatalf("Expected the alarm to be acknowledged, got %t (%v)", ok, err)
+	}
+	if ok, err := repo.AcknowledgeAlarm(acked, time.Now()); err != nil || ok {
+		t.Errorf("Expected a second acknowledgement to change nothing, got %t (%v)", ok, err)
+	}
+
+	scheduled, err := repo.ScheduleEscalations("WARNING", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("ScheduleEscalations failed: %v", err)
+	}
+	if scheduled != 1 {
+		t.Errorf("Expected only the unacknowledged alarm scheduled, got %d", scheduled)
+	}
+	// Scheduling again keeps the persisted time
+	if scheduled, err = repo.ScheduleEscalations("WARNING", time.Minute); err != nil || scheduled != 0 {
+		t.Errorf("Expected nothing rescheduled, got %d (%v)", scheduled, err)
+	}
+
+	now := time.Now().UTC()
+	due, err := repo.ListDueEscalations(now)
+	if err != nil {
+		t.Fatalf("ListDueEscalations failed: %v", err)
+	}
+	if len(due) != 0 {
+		t.Errorf("Expected nothing due yet, got %d devices", len(due))
+	}
+	later := now.Add(16 * time.Minute)
+	if due, err = repo.ListDueEscalations(later); err != nil || len(due) != 1 || due[0].ID != id {
+		t.Fatalf("Expected the device due after the delay, got %d devices (%v)", len(due), err)
+	}
+
+	for i, expected := range []bool{true, false} {
+		escalated, err := repo.EscalateAlarm(id, "WARNING", "CRITICAL", "[CRITICAL] Escalated from WARNING: Smoke", "escalation", later)
+		if err != nil {
+			t.Fatalf("EscalateAlarm failed: %v", err)
+		}
+		if escalated != expected {
+			t.Errorf("Attempt %d: expected escalated %t, got %t", i, expected, escalated)
+		}
+	}
+
+	device, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if device.LastAlarmLevel != "CRITICAL" || !device.AlarmActive || device.LastAlarmTriggeredBy != "escalation" {
+		t.Errorf("Expected an active CRITICAL alarm raised by escalation, got %+v", device)
+	}
+	history, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{DeviceID: id, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAlarmHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected the escalation as a second history entry, got %d entries", len(history))
+	}
+
+	// Acknowledged alarms lose their schedule and are never due
+	if _, err := repo.TriggerAlarm(acked, "WARNING", "[WARNING] Smoke", "sensor", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if _, err := repo.ScheduleEscalations("WARNING", time.Minute); err != nil {
+		t.Fatalf("ScheduleEscalations failed: %v", err)
+	}
+	if _, err := repo.AcknowledgeAlarm(acked, time.Now()); err != nil {
+		t.Fatalf("AcknowledgeAlarm failed: %v", err)
+	}
+	if due, err = repo.ListDueEscalations(later); err != nil || len(due) != 0 {
+		t.Errorf("Expected no escalations due, got %d (%v)", len(due), err)
+	}
+	if err := repo.ClearAlarm(id); err != nil {
+		t.Fatalf("ClearAlarm failed: %v", err)
+	}
+	if _, err := repo.ScheduleEscalations("CRITICAL", time.Minute); err != nil {
+		t.Fatalf("ScheduleEscalations failed: %v", err)
+	}
+	if pruned, err := repo.PruneEscalations(); err != nil || pruned != 0 {
+		t.Errorf("Expected no schedules left to prune, got %d (%v)", pruned, err)
+	}
+}
+
+func TestDeviceRepository_ListAfterCursor(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	var expected []int64
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		expected = append([]int64{createTestDevice(t, repo, name)}, expected...)
+	}
+
+	// Devices created while paging newest first sort before the cursor, so they must neither
+	// shift later pages nor show up in them. The devices share a created_at second, so id breaks ties.
+	var got []int64
+	opts := &models.DeviceListOptions{Limit: 2, SortBy: "created_at", SortOrder: models.SortDesc}
+	for page := 0; page < 5; page++ {
+		devices, err := repo.List(opts)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(devices) == 0 {
+			break
+		}
+		for _, device := range devices {
+			got = append(got, device.ID)
+		}
+		last := devices[len(devices)-1]
+		opts.After = &models.DeviceCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+
+		createTestDevice(t, repo, fmt.Sprintf("Added %d", page))
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected devices %v, got %v", expected, got)
+	}
+}
+
+func TestDeviceRepository_UpdateClearsLastAlarmReason(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Detector")
+
+	name := "Renamed"
+	steps := []struct {
+		name     string
+		update   models.DeviceUpdate
+		expected string
+	}{
+		{"Set", models.DeviceUpdate{LastAlarmReason: models.SetString("Smoke")}, "Smoke"},
+		{"Left alone", models.DeviceUpdate{Name: &name}, "Smoke"},
+		{"Cleared", models.DeviceUpdate{LastAlarmReason: models.NullableString{Set: true}}, ""},
+	}
+
+	for _, step := range steps {
+		if _, err := repo.Update(id, &step.update, false); err != nil {
+			t.Fatalf("%s: Update failed: %v", step.name, err)
+		}
+		device, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("%s: GetByID failed: %v", step.name, err)
+		}
+		if device.LastAlarmReason != step.expected {
+			t.Errorf("%s: expected reason %q, got %q", step.name, step.expected, device.LastAlarmReason)
+		}
+	}
+
+	var isNull bool
+	if err := db.QueryRow(`SELECT last_alarm_reason IS NULL FROM devices WHERE id = ?`, id).Scan(&isNull); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !isNull {
+		t.Error("Expected a cleared reason to be stored as NULL")
+	}
+}
+
+func TestDeviceRepository_AlarmCount(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	alarmed := createTestDevice(t, repo, "Alarmed")
+	quiet := createTestDevice(t, repo, "Quiet")
+	for i := 0; i < 3; i++ {
+		if _, err := repo.TriggerAlarm(alarmed, "WARNING", "Smoke", "sensor", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	expected := map[int64]int64{alarmed: 3, quiet: 0}
+
+	device, err := repo.GetByIDWithAlarmCount(alarmed)
+	if err != nil {
+		t.Fatalf("GetByIDWithAlarmCount failed: %v", err)
+	}
+	if device.AlarmCount == nil || *device.AlarmCount != 3 {
+		t.Errorf("Expected 3 alarms, got %v", device.AlarmCount)
+	}
+	if device, err := repo.GetByIDWithAlarmCount(99); err != nil || device != nil {
+		t.Errorf("Expected no device for an unknown ID, got %v, %v", device, err)
+	}
+
+	opts := &models.DeviceListOptions{Limit: 10, SortBy: "id", SortOrder: models.SortAsc, WithAlarmCount: true}
+	devices, err := repo.List(opts)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var streamed []*models.Device
+	if err := repo.EachDevice(context.Background(), opts, func(d *models.Device) error {
+		streamed = append(streamed, d)
+		return nil
+	}); err != nil {
+		t.Fatalf("EachDevice failed: %v", err)
+	}
+	for _, listed := range [][]*models.Device{devices, streamed} {
+		if len(listed) != 2 {
+			t.Fatalf("Expected 2 devices, got %d", len(listed))
+		}
+		for _, d := range listed {
+			if d.AlarmCount == nil || *d.AlarmCount != expected[d.ID] {
+				t.Errorf("Device %d: expected %d alarms, got %v", d.ID, expected[d.ID], d.AlarmCount)
+			}
+		}
+	}
+
+	// Without the option the history is not joined and no count is loaded
+	opts.WithAlarmCount = false
+	devices, err = repo.List(opts)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if devices[0].AlarmCount != nil {
+		t.Errorf("Expected no alarm count, got %d", *devices[0].AlarmCount)
+	}
+}
+
+func TestDeviceRepository_ListFields(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	id := createTestDevice(t, repo, "Selected")
+	if err := repo.RecordSeen(id, time.Now()); err != nil {
+		t.Fatalf("RecordSeen failed: %v", err)
+	}
+	if _, err := repo.TriggerAlarm(id, "WARNING", "Smoke", "sensor", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	opts := &models.DeviceListOptions{Limit: 10, Fields: []string{"name", "stale"}, WithAlarmCount: true}
+	devices, err := repo.List(opts)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("Expected 1 device, got %d", len(devices))
+	}
+	device := devices[0]
+
+	// Selected fields, those stale is computed from and those always loaded are read
+	if device.ID != id || device.Name != "Selected" || device.LastSeenAt.IsZero() {
+		t.Errorf("Expected id, name and last_seen_at to be loaded, got %+v", device)
+	}
+	if device.CreatedAt.IsZero() || device.UpdatedAt.IsZero() {
+		t.Errorf("Expected created_at and updated_at to always be loaded, got %+v", device)
+	}
+	if device.AlarmCount == nil || *device.AlarmCount != 1 {
+		t.Errorf("Expected the alarm count to be loaded, got %v", device.AlarmCount)
+	}
+
+	// Everything else is left zero
+	if device.OwnedBy != "" || device.DeviceType != "" || device.LastAlarmReason != "" || device.AlarmActive ||
+		!device.LastAlarmTime.IsZero() || device.Version != 0 {
+		t.Errorf("Expected unselected fields to be zero, got %+v", device)
+	}
+}
+
+func TestDeviceRepository_ListActivityViews(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	recent := createTestDevice(t, repo, "Recent")
+	old := createTestDevice(t, repo, "Old")
+	seen := createTestDevice(t, repo, "Seen")
+	for _, id := range []int64{recent, old} {
+		if _, err := repo.TriggerAlarm(id, "WARNING", "Smoke", "sensor", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	backdate := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE devices SET last_alarm_time = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-3 days') WHERE id = ?`, []interface{}{old}},
+		// Every device was last updated ten days ago, but one has been seen since
+		{`UPDATE devices SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-10 days')`, nil},
+		{`INSERT INTO device_presence (device_id, last_seen_at) VALUES (?, strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-1 hour'))`, []interface{}{seen}},
+	}
+	for _, step := range backdate {
+		if _, err := db.Exec(step.query, step.args...); err != nil {
+			t.Fatalf("Backdating failed: %v", err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE devices SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`, recent); err != nil {
+		t.Fatalf("Backdating failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     models.DeviceListOptions
+		expected []int64
+	}{
+		{"Alarmed within a day", models.DeviceListOptions{AlarmedSince: time.Now().Add(-24 * time.Hour)}, []int64{recent}},
+		{"Alarmed within a week", models.DeviceListOptions{AlarmedSince: time.Now().Add(-7 * 24 * time.Hour)}, []int64{recent, old}},
+		{"Quiet for a week", models.DeviceListOptions{InactiveSince: time.Now().Add(-7 * 24 * time.Hour)}, []int64{old}},
+		{"Quiet for a month", models.DeviceListOptions{InactiveSince: time.Now().Add(-30 * 24 * time.Hour)}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Limit, tc.opts.SortBy, tc.opts.SortOrder = 10, "id", models.SortAsc
+			devices, err := repo.List(&tc.opts)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			var ids []int64
+			for _, device := range devices {
+				ids = append(ids, device.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+				t.Errorf("Expected devices %v, got %v", tc.expected, ids)
+			}
+		})
+	}
+}
+
+func TestDeviceRepository_ExcludeUnknown(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	createTestDevice(t, repo, "Hall")
+	for _, owner := range []string{"owner", "other"} {
+		if _, err := repo.Create(&models.DeviceCreate{Name: "Mystery", DeviceType: models.DeviceTypeUnknown, OwnedBy: owner}); err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+	}
+
+	devices, err := repo.List(&models.DeviceListOptions{Limit: 10, ExcludeUnknown: true})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(devices) != 1 || devices[0].Name != "Hall" {
+		t.Errorf("Expected only Hall, got %d devices", len(devices))
+	}
+
+	count, err := repo.Count(&models.DeviceListOptions{ExcludeUnknown: true, Owners: []string{"other"}})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no known devices owned by other, got %d", count)
+	}
+}
+
+func TestDeviceRepository_CountMatchesList(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	alarmed := createTestDevice(t, repo, "Hall")
+	kitchen := createTestDevice(t, repo, "Kitchen")
+	if _, err := repo.Create(&models.DeviceCreate{Name: "Porch", DeviceType: models.DeviceTypeCamera, OwnedBy: "other"}); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	if _, err := repo.TriggerAlarm(alarmed, "CRITICAL", "Smoke", "sensor", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if _, err := repo.TriggerAlarm(alarmed, "WARNING", "Low battery", "sensor", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		opts     models.DeviceListOptions
+		expected int
+	}{
+		{"No filters", models.DeviceListOptions{}, 3},
+		{"Device type", models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeCamera}}, 1},
+		{"Either device type", models.DeviceListOptions{DeviceTypes: []models.DeviceType{models.DeviceTypeCamera, models.DeviceTypeSmokeDetector}}, 3},
+		{"Owner", models.DeviceListOptions{Owners: []string{"owner"}}, 2},
+		{"Either owner", models.DeviceListOptions{Owners: []string{"owner", "other"}}, 3},
+		{"Either name", models.DeviceListOptions{Names: []string{"kit", "porch"}}, 2},
+		{"IDs", models.DeviceListOptions{IDs: []int64{alarmed, kitchen}}, 2},
+		{"Alarm active", models.DeviceListOptions{AlarmActive: &yes}, 1},
+		{"Alarm inactive", models.DeviceListOptions{AlarmActive: &no}, 2},
+		{"Offline", models.DeviceListOptions{Online: &no}, 3},
+		{"Combined", models.DeviceListOptions{Owners: []string{"owner"}, Names: []string{"kit"}}, 1},
+		{"Lists combined", models.DeviceListOptions{Owners: []string{"owner", "other"}, Names: []string{"kit", "porch"}, IDs: []int64{kitchen}}, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := repo.Count(&tc.opts)
+			if err != nil {
+				t.Fatalf("Count failed: %v", err)
+			}
+			if count != tc.expected {
+				t.Errorf("Expected %d devices, got %d", tc.expected, count)
+			}
+
+			tc.opts.Limit = 10
+			devices, err := repo.List(&tc.opts)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(devices) != count {
+				t.Errorf("Expected the list to hold %d devices, got %d", count, len(devices))
+			}
+		})
+	}
+
+	history := []struct {
+		name     string
+		filter   models.AlarmHistoryFilter
+		expected int
+	}{
+		{"All alarms", models.AlarmHistoryFilter{}, 2},
+		{"By level", models.AlarmHistoryFilter{Levels: []string{"CRITICAL"}}, 1},
+		{"Either level", models.AlarmHistoryFilter{Levels: []string{"CRITICAL", "WARNING"}}, 2},
+		{"Future range", models.AlarmHistoryFilter{After: time.Now().Add(time.Hour)}, 0},
+		{"Past range", models.AlarmHistoryFilter{After: time.Now().Add(-time.Hour), Before: time.Now().Add(time.Hour)}, 2},
+	}
+
+	for _, tc := range history {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := repo.CountAlarmHistory(&tc.filter)
+			if err != nil {
+				t.Fatalf("CountAlarmHistory failed: %v", err)
+			}
+			if count != tc.expected {
+				t.Errorf("Expected %d alarms, got %d", tc.expected, count)
+			}
+		})
+	}
+}
+
+func TestDeviceRepository_TriggerAlarmOnce(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	kitchen := createTestDevice(t, repo, "Kitchen")
+	hallway := createTestDevice(t, repo, "Hallway")
+	if err := repo.SetMaintenance(hallway, true, time.Time{}); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+
+	steps := []struct {
+		name               string
+		id                 int64
+		eventID            string
+		expectedSuppressed bool
+		expectedDuplicate  bool
+	}{
+		{"First delivery", kitchen, "evt-1", false, false},
+		{"Retry", kitchen, "evt-1", false, true},
+		{"Next event", kitchen, "evt-2", false, false},
+		{"Same event on another device", hallway, "evt-1", true, false},
+		{"Retry of a suppressed alarm", hallway, "evt-1", true, true},
+	}
+	for _, step := range steps {
+		suppressed, duplicate, err := repo.TriggerAlarmOnce(step.id, step.eventID, models.AlarmLevelWarning, "[WARNING] Smoke", "sensor", "")
+		if err != nil {
+			t.Fatalf("%s: TriggerAlarmOnce failed: %v", step.name, err)
+		}
+		if suppressed != step.expectedSuppressed || duplicate != step.expectedDuplicate {
+			t.Errorf("%s: expected suppressed=%t duplicate=%t, got %t %t",
+				step.name, step.expectedSuppressed, step.expectedDuplicate, suppressed, duplicate)
+		}
+	}
+
+	for id, expected := range map[int64]int{kitchen: 2, hallway: 1} {
+		records, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{DeviceID: id, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListAlarmHistory failed: %v", err)
+		}
+		if len(records) != expected {
+			t.Errorf("Expected %d history rows for device %d, got %d", expected, id, len(records))
+		}
+	}
+
+	if _, _, err := repo.TriggerAlarmOnce(999, "evt-9", models.AlarmLevelWarning, "[WARNING] Smoke", "", ""); !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+	}
+
+	// Forgotten event ids are processed again
+	if _, err := db.Exec(`UPDATE alarm_events SET processed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-2 days') WHERE event_id = 'evt-1'`); err != nil {
+		t.Fatalf("Backdating failed: %v", err)
+	}
+	purged, err := repo.PurgeAlarmEvents(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeAlarmEvents failed: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 event ids purged, got %d", purged)
+	}
+	if _, duplicate, err := repo.TriggerAlarmOnce(kitchen, "evt-1", models.AlarmLevelWarning, "[WARNING] Smoke", "", ""); err != nil || duplicate {
+		t.Errorf("Expected a purged event id to be processed again, got duplicate=%t err=%v", duplicate, err)
+	}
+}
+
+func TestDeviceRepository_SetOnlineIfChanged(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Detector")
+
+	changed, err := repo.SetOnlineIfChanged(id, true)
+	if err != nil {
+		t.Fatalf("SetOnlineIfChanged failed: %v", err)
+	}
+	if !changed {
+		t.Error("Expected coming online to report a change")
+	}
+
+	// Backdate so an unwanted bump of updated_at would show
+	if _, err := db.Exec(`UPDATE devices SET updated_at = '2024-01-01T00:00:00Z' WHERE id = ?`, id); err != nil {
+		t.Fatalf("Backdating failed: %v", err)
+	}
+	before, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	changed, err = repo.SetOnlineIfChanged(id, true)
+	if err != nil {
+		t.Fatalf("SetOnlineIfChanged failed: %v", err)
+	}
+	if changed {
+		t.Error("Expected a repeated report to change nothing")
+	}
+	after, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if after.Version != before.Version || !after.UpdatedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the device untouched, got version %d updated at %v", after.Version, after.UpdatedAt)
+	}
+
+	if changed, err = repo.SetOnlineIfChanged(id, false); err != nil || !changed {
+		t.Errorf("Expected going offline to report a change, got %t (%v)", changed, err)
+	}
+	if changed, err = repo.SetOnlineIfChanged(9999, true); err != nil || changed {
+		t.Errorf("Expected a missing device to report no change, got %t (%v)", changed, err)
+	}
+}
+
+func TestDeviceRepository_Vacuum(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	for i := 0; i < 200; i++ {
+		id := createTestDevice(t, repo, fmt.Sprintf("Device%d", i))
+		if err := repo.Delete(id, models.ChildrenRefuse); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM device_changes`); err != nil {
+		t.Fatalf("Clearing changes failed: %v", err)
+	}
+
+	result, err := repo.Vacuum()
+	if err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	if !result.Vacuumed || result.SizeBefore == 0 || result.SizeAfter >= result.SizeBefore {
+		t.Errorf("Expected the file to shrink, got %+v", result)
+	}
+}
+
+func TestDeviceRepository_GetByOwnerAndName(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Kitchen")
+	createTestDevice(t, repo, "Hall")
+	createTestDevice(t, repo, "Hall")
+
+	device, err := repo.GetByOwnerAndName("owner", "Kitchen")
+	if err != nil || device == nil || device.ID != id {
+		t.Errorf("Expected device %d, got %+v (%v)", id, device, err)
+	}
+
+	if device, err = repo.GetByOwnerAndName("someone", "Kitchen"); err != nil || device != nil {
+		t.Errorf("Expected no device for another owner, got %+v (%v)", device, err)
+	}
+	if device, err = repo.GetByOwnerAndName("owner", "kitchen"); err != nil || device != nil {
+		t.Errorf("Expected names to match exactly, got %+v (%v)", device, err)
+	}
+	if _, err = repo.GetByOwnerAndName("owner", "Hall"); !errors.Is(err, models.ErrAmbiguousDevice) {
+		t.Errorf("Expected ErrAmbiguousDevice for a duplicated name, got %v", err)
+	}
+}
+
+func TestDeviceRepository_ApplyManifest(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Kitchen")
+	device, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+
+	description := "Back door"
+	change := &models.DeviceDrift{ID: id, Version: device.Version,
+		Desired: &models.ManifestDevice{Name: "Kitchen", DeviceType: models.DeviceTypeLock, OwnedBy: "owner", Description: &description}}
+	creates := []*models.DeviceCreate{{Name: "Porch", DeviceType: models.DeviceTypeCamera, OwnedBy: "owner"}}
+
+	if err := repo.ApplyManifest(creates, []*models.DeviceDrift{change}); err != nil {
+		t.Fatalf("ApplyManifest failed: %v", err)
+	}
+	if device, err = repo.GetByID(id); err != nil || device.DeviceType != models.DeviceTypeLock || device.Description != description {
+		t.Errorf("Expected the drifted device updated, got %+v (%v)", device, err)
+	}
+	if porch, err := repo.GetByOwnerAndName("owner", "Porch"); err != nil || porch == nil {
+		t.Errorf("Expected the missing device created, got %+v (%v)", porch, err)
+	}
+
+	// The change was compared against a version that is now stale
+	creates[0].Name = "Garage"
+	if err := repo.ApplyManifest(creates, []*models.DeviceDrift{change}); !errors.Is(err, models.ErrDeviceChanged) {
+		t.Fatalf("Expected ErrDeviceChanged, got %v", err)
+	}
+	if garage, err := repo.GetByOwnerAndName("owner", "Garage"); err != nil || garage != nil {
+		t.Errorf("Expected the failed apply to create nothing, got %+v (%v)", garage, err)
+	}
+}
+
+func TestDeviceRepository_NotifyOnAlarm(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	quiet := false
+	notifying, err := repo.Create(&models.DeviceCreate{Name: "Door", DeviceType: models.DeviceTypeLock, OwnedBy: "owner"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	silenced, err := repo.Create(&models.DeviceCreate{Name: "Cam", DeviceType: models.DeviceTypeCamera, OwnedBy: "owner", NotifyOnAlarm: &quiet})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !notifying.NotifyOnAlarm || silenced.NotifyOnAlarm {
+		t.Errorf("Expected notifications on by default and off when asked, got %t and %t", notifying.NotifyOnAlarm, silenced.NotifyOnAlarm)
+	}
+
+	if _, err := repo.Update(notifying.ID, &models.DeviceUpdate{NotifyOnAlarm: &quiet}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	// Updating other fields leaves the preference alone
+	name := "Camera"
+	if _, err := repo.Update(silenced.ID, &models.DeviceUpdate{Name: &name}, false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	for _, id := range []int64{notifying.ID, silenced.ID} {
+		if device, err := repo.GetByID(id); err != nil || device.NotifyOnAlarm {
+			t.Errorf("Expected device %d not to notify, got %+v (%v)", id, device, err)
+		}
+	}
+}
+
+func TestDeviceRepository_GetRawByID(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Kitchen")
+	if _, err := db.Exec(`UPDATE devices SET created_at = '2024-05-01 12:00:00' WHERE id = ?`, id); err != nil {
+		t.Fatalf("Rewriting created_at failed: %v", err)
+	}
+
+	raw, err := repo.GetRawByID(id)
+	if err != nil {
+		t.Fatalf("GetRawByID failed: %v", err)
+	}
+	if name := raw.Columns["name"]; name == nil || *name != "Kitchen" {
+		t.Errorf("Expected the stored name, got %v", name)
+	}
+	// Text in another format is shown as stored, not parsed
+	if createdAt := raw.Columns["created_at"]; createdAt == nil || *createdAt != "2024-05-01 12:00:00" {
+		t.Errorf("Expected the stored created_at text, got %q", *createdAt)
+	}
+	if reason, present := raw.Columns["last_alarm_reason"]; !present || reason != nil {
+		t.Errorf("Expected last_alarm_reason present and NULL, got %v", reason)
+	}
+
+	if raw, err = repo.GetRawByID(9999); err != nil || raw != nil {
+		t.Errorf("Expected no row for a missing device, got %+v (%v)", raw, err)
+	}
+}
+
+func TestDeviceRepository_AlarmLevels(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	cleared := createTestDevice(t, repo, "Cleared")
+	custom := createTestDevice(t, repo, "Custom")
+	critical := createTestDevice(t, repo, "Critical")
+	for id, level := range map[int64]string{cleared: models.AlarmLevelInfo, custom: "SEV_5", critical: models.AlarmLevelCritical} {
+		if _, err := repo.TriggerAlarm(id, level, "Smoke", "", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	if err := repo.ClearAlarm(cleared); err != nil {
+		t.Fatalf("ClearAlarm failed: %v", err)
+	}
+
+	levelsInUse := func(since time.Time) []string {
+		levels, err := repo.ListAlarmLevelsInUse(since)
+		if err != nil {
+			t.Fatalf("ListAlarmLevelsInUse failed: %v", err)
+		}
+		slices.Sort(levels)
+		return levels
+	}
+	if levels := levelsInUse(time.Time{}); !slices.Equal(levels, []string{"CRITICAL", "INFO", "SEV_5"}) {
+		t.Errorf("Expected the levels of the whole history, got %v", levels)
+	}
+	if levels := levelsInUse(time.Now().Add(time.Hour)); !slices.Equal(levels, []string{"CRITICAL", "SEV_5"}) {
+		t.Errorf("Expected only the levels of active alarms, got %v", levels)
+	}
+
+	tests := []struct {
+		name     string
+		order    []string
+		expected []int64
+	}{
+		{"Built-in order puts unknown levels last", nil, []int64{critical, custom}},
+		{"Configured order ranks added levels", []string{"INFO", "WARNING", "CRITICAL", "SEV_5"}, []int64{custom, critical}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			active, err := repo.ListActiveAlarms(&models.ActiveAlarmFilter{LevelOrder: tc.order, Limit: 10})
+			if err != nil {
+				t.Fatalf("ListActiveAlarms failed: %v", err)
+			}
+			var ids []int64
+			for _, device := range active {
+				ids = append(ids, device.ID)
+			}
+			if !slices.Equal(ids, tc.expected) {
+				t.Errorf("Expected devices %v, got %v", tc.expected, ids)
+			}
+		})
+	}
+}
+
+func TestDeviceRepository_Firmware(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Camera")
+
+	if completed, err := repo.CompleteFirmwareUpdate(id, models.FirmwareUpdateSuccess); err != nil || completed {
+		t.Fatalf("Expected no pending update to complete, got %v, %v", completed, err)
+	}
+
+	steps := []struct {
+		target  string
+		status  string
+		version string
+	}{
+		{"1.2.0", models.FirmwareUpdateSuccess, "1.2.0"},
+		{"1.3.0", models.FirmwareUpdateFailed, "1.2.0"},
+	}
+	for _, step := range steps {
+		if err := repo.SetFirmwareTarget(id, step.target); err != nil {
+			t.Fatalf("SetFirmwareTarget failed: %v", err)
+		}
+		device, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if device.FirmwareTargetVersion != step.target || device.FirmwareUpdateStatus != models.FirmwareUpdatePending {
+			t.Errorf("Expected a pending update to %s, got %q %q", step.target, device.FirmwareTargetVersion, device.FirmwareUpdateStatus)
+		}
+
+		if completed, err := repo.CompleteFirmwareUpdate(id, step.status); err != nil || !completed {
+			t.Fatalf("Expected the update to complete, got %v, %v", completed, err)
+		}
+		device, err = repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if device.FirmwareUpdateStatus != step.status || device.FirmwareVersion != step.version {
+			t.Errorf("Expected status %s and version %s, got %q and %q", step.status, step.version, device.FirmwareUpdateStatus, device.FirmwareVersion)
+		}
+	}
+
+	if completed, err := repo.CompleteFirmwareUpdate(id, models.FirmwareUpdateSuccess); err != nil || completed {
+		t.Errorf("Expected a finished update not to complete again, got %v, %v", completed, err)
+	}
+}
+
+func TestDeviceRepository_ArchivedSkipsBackgroundProcessing(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	archived := createTestDevice(t, repo, "Pool")
+	active := createTestDevice(t, repo, "Hall")
+
+	// Both are online, long quiet and alarmed before one is archived
+	seen := time.Now().Add(-time.Hour)
+	for _, id := range []int64{archived, active} {
+		if _, err := repo.RecordSeenOnline(id, seen); err != nil {
+			t.Fatalf("RecordSeenOnline failed: %v", err)
+		}
+		if _, err := repo.TriggerAlarm(id, "WARNING", "[WARNING] Smoke", "sensor", ""); err != nil {
+			t.Fatalf("TriggerAlarm failed: %v", err)
+		}
+	}
+	if err := repo.SetArchived(archived, true); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+
+	if marked, err := repo.MarkUnseenOffline(models.DeviceTypeSmokeDetector, time.Now()); err != nil || marked != 1 {
+		t.Errorf("Expected only the active device marked offline, got %d (%v)", marked, err)
+	}
+	if scheduled, err := repo.ScheduleEscalations("WARNING", 0); err != nil || scheduled != 1 {
+		t.Errorf("Expected only the active device's alarm scheduled, got %d (%v)", scheduled, err)
+	}
+
+	triggered, err := repo.TriggerAlarmByType(models.DeviceTypeSmokeDetector, "CRITICAL", "[CRITICAL] Fire", "sensor", "")
+	if err != nil {
+		t.Fatalf("TriggerAlarmByType failed: %v", err)
+	}
+	if len(triggered) != 1 || triggered[0].DeviceID != active {
+		t.Errorf("Expected only the active device alarmed, got %+v", triggered)
+	}
+	if history, err := repo.CountAlarmHistory(&models.AlarmHistoryFilter{DeviceID: archived}); err != nil || history != 1 {
+		t.Errorf("Expected the archived device's history unchanged, got %d (%v)", history, err)
+	}
+}
+
+func TestDeviceRepository_RenameOwnerCascades(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Detector")
+	if _, err := repo.TriggerAlarm(id, models.AlarmLevelWarning, "Smoke", "owner", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if _, err := NewIncidentRepository(db).AttachAlarm(id, models.AlarmLevelWarning, "Smoke", "owner", time.Hour); err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+	before, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	changes := NewChangeRepository(db)
+	_, seq, err := changes.Bounds()
+	if err != nil {
+		t.Fatalf("Bounds failed: %v", err)
+	}
+
+	rows, err := repo.RenameOwner("owner", "keeper", false)
+	if err != nil {
+		t.Fatalf("RenameOwner failed: %v", err)
+	}
+	expected := map[string]int64{"devices": 1, "alarm_history": 1, "incident_alarms": 1}
+	if !maps.Equal(rows, expected) {
+		t.Errorf("Expected rows %v, got %v", expected, rows)
+	}
+
+	device, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if device.OwnedBy != "keeper" || device.LastAlarmTriggeredBy != "keeper" || device.Version != before.Version+1 {
+		t.Errorf("Expected the owner and actor renamed and the version bumped, got %+v", device)
+	}
+	if history := conformHistory(t, repo, id); history[0].TriggeredBy != "keeper" {
+		t.Errorf("Expected the alarm history actor renamed, got %q", history[0].TriggeredBy)
+	}
+
+	// One owner change rather than one per device
+	recorded, err := changes.List(seq, 10)
+	if err != nil {
+		t.Fatalf("List changes failed: %v", err)
+	}
+	if len(recorded) != 1 || recorded[0].Entity != models.ChangeEntityOwner || recorded[0].Operation != models.ChangeOperationRename {
+		t.Errorf("Expected a single owner rename change, got %+v", recorded)
+	}
+}
+
+func TestDeviceRepository_MatchOwnersUsesIndex(t *testing.T) {
+	db := newTestDB(t)
+
+	plan := explainQueryPlan(t, db, matchOwnersQuery, "al%", "alice", "alice", 5)
+	if !strings.Contains(plan, "SEARCH devices USING COVERING INDEX idx_devices_owned_by_nocase") {
+		t.Errorf("Expected a search of the case-insensitive owner index, got plan:\n%s", plan)
+	}
+}
+
+func TestDeviceRepository_ReasonCodes(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+
+	kitchen := createTestDevice(t, repo, "Kitchen")
+	hallway := createTestDevice(t, repo, "Hallway")
+	if _, err := repo.TriggerAlarm(kitchen, models.AlarmLevelWarning, "[WARNING] Smoke detected", "", "smoke"); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	if _, err := repo.TriggerAlarm(kitchen, models.AlarmLevelInfo, "[INFO] Door left open", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	record, _, err := repo.TriggerAlarmForClientEvent(hallway, "evt-1", models.AlarmLevelCritical, "[CRITICAL] Smoke detected", "", "smoke")
+	if err != nil {
+		t.Fatalf("TriggerAlarmForClientEvent failed: %v", err)
+	}
+	if record.ReasonCode != "smoke" {
+		t.Errorf("Expected the recorded alarm to keep its reason code, got %q", record.ReasonCode)
+	}
+	if _, err := repo.TriggerAlarmByType(models.DeviceTypeSmokeDetector, models.AlarmLevelWarning, "[WARNING] Test alarm", "panel", "test"); err != nil {
+		t.Fatalf("TriggerAlarmByType failed: %v", err)
+	}
+
+	count := func(codes ...string) int {
+		n, err := repo.CountAlarmHistory(&models.AlarmHistoryFilter{ReasonCodes: codes})
+		if err != nil {
+			t.Fatalf("CountAlarmHistory failed: %v", err)
+		}
+		return n
+	}
+	if n := count("smoke"); n != 2 {
+		t.Errorf("Expected 2 smoke alarms, got %d", n)
+	}
+	if n := count("test"); n != 2 {
+		t.Errorf("Expected a test alarm on each device, got %d", n)
+	}
+	if n := count("smoke", "test"); n != 4 {
+		t.Errorf("Expected 4 alarms with either code, got %d", n)
+	}
+
+	history, err := repo.ListAlarmHistory(&models.AlarmHistoryFilter{DeviceID: kitchen, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAlarmHistory failed: %v", err)
+	}
+	var codes []string
+	for _, alarm := range history {
+		codes = append(codes, alarm.ReasonCode)
+	}
+	if !slices.Equal(codes, []string{"test", "", "smoke"}) {
+		t.Errorf("Expected the history to keep each alarm's code, newest first, got %q", codes)
+	}
+}
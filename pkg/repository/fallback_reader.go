@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// FallbackDeviceReader serves reads from a replica, retrying against the primary
+// whenever the replica returns an error
+type FallbackDeviceReader struct {
+	replica DeviceReader
+	primary DeviceReader
+}
+
+// NewFallbackDeviceReader creates a DeviceReader that prefers replica and falls back to primary
+func NewFallbackDeviceReader(replica, primary DeviceReader) DeviceReader {
+	return &FallbackDeviceReader{replica: replica, primary: primary}
+}
+
+// fallback logs a failed replica read before it is retried on the primary
+func (r *FallbackDeviceReader) fallback(op string, err error) {
+	log.Printf("Replica %s failed, falling back to primary: %v", op, err)
+}
+
+// GetByID retrieves a device by its ID
+func (r *FallbackDeviceReader) GetByID(id int64) (*models.Device, error) {
+	device, err := r.replica.GetByID(id)
+	if err != nil {
+		r.fallback("GetByID", err)
+		return r.primary.GetByID(id)
+	}
+
+	return device, nil
+}
+
+// GetByIDs retrieves the devices with the given IDs in the order given
+func (r *FallbackDeviceReader) GetByIDs(ids []int64) ([]*models.Device, error) {
+	devices, err := r.replica.GetByIDs(ids)
+	if err != nil {
+		r.fallback("GetByIDs", err)
+		return r.primary.GetByIDs(ids)
+	}
+
+	return devices, nil
+}
+
+// GetRawByID reads a device's row as stored
+func (r *FallbackDeviceReader) GetRawByID(id int64) (*models.RawDeviceRow, error) {
+	row, err := r.replica.GetRawByID(id)
+	if err != nil {
+		r.fallback("GetRawByID", err)
+		return r.primary.GetRawByID(id)
+	}
+
+	return row, nil
+}
+
+// GetByOwnerAndName retrieves the device of an owner with the given name
+func (r *FallbackDeviceReader) GetByOwnerAndName(owner, name string) (*models.Device, error) {
+	device, err := r.replica.GetByOwnerAndName(owner, name)
+	if err != nil && !errors.Is(err, models.ErrAmbiguousDevice) {
+		r.fallback("GetByOwnerAndName", err)
+		return r.primary.GetByOwnerAndName(owner, name)
+	}
+
+	return device, err
+}
+
+// GetByIDWithAlarmCount retrieves a device by its ID along with its number of alarms
+func (r *FallbackDeviceReader) GetByIDWithAlarmCount(id int64) (*models.Device, error) {
+	device, err := r.replica.GetByIDWithAlarmCount(id)
+	if err != nil {
+		r.fallback("GetByIDWithAlarmCount", err)
+		return r.primary.GetByIDWithAlarmCount(id)
+	}
+
+	return device, nil
+}
+
+// Exists reports whether a device with the given ID exists
+func (r *FallbackDeviceReader) Exists(id int64) (bool, error) {
+	exists, err := r.replica.Exists(id)
+	if err != nil {
+		r.fallback("Exists", err)
+		return r.primary.Exists(id)
+	}
+
+	return exists, nil
+}
+
+// GetAll retrieves all devices
+func (r *FallbackDeviceReader) GetAll() ([]*models.Device, error) {
+	devices, err := r.replica.GetAll()
+	if err != nil {
+		r.fallback("GetAll", err)
+		return r.primary.GetAll()
+	}
+
+	return devices, nil
+}
+
+// List retrieves a page of devices
+func (r *FallbackDeviceReader) List(opts *models.DeviceListOptions) ([]*models.Device, error) {
+	devices, err := r.replica.List(opts)
+	if err != nil {
+		r.fallback("List", err)
+		return r.primary.List(opts)
+	}
+
+	return devices, nil
+}
+
+// EachDevice streams devices from the replica. It falls back to the primary only if the replica
+// fails before yielding any device, since rows already passed to fn cannot be taken back.
+func (r *FallbackDeviceReader) EachDevice(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	yielded := false
+	err := r.replica.EachDevice(ctx, opts, func(device *models.Device) error {
+		yielded = true
+		return fn(device)
+	})
+	if err != nil && !yielded && ctx.Err() == nil {
+		r.fallback("EachDevice", err)
+		return r.primary.EachDevice(ctx, opts, fn)
+	}
+
+	return err
+}
+
+// StreamAll streams every device through EachDevice, so it falls back to the primary the same way
+func (r *FallbackDeviceReader) StreamAll(ctx context.Context) (<-chan *models.Device, <-chan error) {
+	return streamDevices(ctx, r.EachDevice)
+}
+
+// ListActiveAlarms retrieves devices with an active alarm
+func (r *FallbackDeviceReader) ListActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	devices, err := r.replica.ListActiveAlarms(filter)
+	if err != nil {
+		r.fallback("ListActiveAlarms", err)
+		return r.primary.ListActiveAlarms(filter)
+	}
+
+	return devices, nil
+}
+
+// ListAlarmHistory retrieves a page of a device's alarm history
+func (r *FallbackDeviceReader) ListAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	records, err := r.replica.ListAlarmHistory(filter)
+	if err != nil {
+		r.fallback("ListAlarmHistory", err)
+		return r.primary.ListAlarmHistory(filter)
+	}
+
+	return records, nil
+}
+
+// CountAlarmHistory counts the alarm history matching a filter
+func (r *FallbackDeviceReader) CountAlarmHistory(filter *models.AlarmHistoryFilter) (int, error) {
+	count, err := r.replica.CountAlarmHistory(filter)
+	if err != nil {
+		r.fallback("CountAlarmHistory", err)
+		return r.primary.CountAlarmHistory(filter)
+	}
+
+	return count, nil
+}
+
+// CountAlarmHistoryByReasonCode counts the alarm history matching a filter per reason code
+func (r *FallbackDeviceReader) CountAlarmHistoryByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	counts, err := r.replica.CountAlarmHistoryByReasonCode(filter)
+	if err != nil {
+		r.fallback("CountAlarmHistoryByReasonCode", err)
+		return r.primary.CountAlarmHistoryByReasonCode(filter)
+	}
+
+	return counts, nil
+}
+
+// ListAlarmLevelsInUse returns the levels of active alarms and of alarm history since a time
+func (r *FallbackDeviceReader) ListAlarmLevelsInUse(since time.Time) ([]string, error) {
+	levels, err := r.replica.ListAlarmLevelsInUse(since)
+	if err != nil {
+		r.fallback("ListAlarmLevelsInUse", err)
+		return r.primary.ListAlarmLevelsInUse(since)
+	}
+
+	return levels, nil
+}
+
+// GetDistinctOwners returns the owners of devices, each once
+func (r *FallbackDeviceReader) GetDistinctOwners(opts *models.OwnerListOptions) ([]string, error) {
+	owners, err := r.replica.GetDistinctOwners(opts)
+	if err != nil {
+		r.fallback("GetDistinctOwners", err)
+		return r.primary.GetDistinctOwners(opts)
+	}
+
+	return owners, nil
+}
+
+// MatchOwners returns the owners similar to owner, owner itself first
+func (r *FallbackDeviceReader) MatchOwners(owner string, limit int) ([]string, error) {
+	owners, err := r.replica.MatchOwners(owner, limit)
+	if err != nil {
+		r.fallback("MatchOwners", err)
+		return r.primary.MatchOwners(owner, limit)
+	}
+
+	return owners, nil
+}
+
+// ListChildren returns the devices whose parent is the given device
+func (r *FallbackDeviceReader) ListChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	children, err := r.replica.ListChildren(id, limit, offset)
+	if err != nil {
+		r.fallback("ListChildren", err)
+		return r.primary.ListChildren(id, limit, offset)
+	}
+
+	return children, nil
+}
+
+// ListRecentlyUpdated returns the devices changed most recently
+func (r *FallbackDeviceReader) ListRecentlyUpdated(limit int) ([]*models.Device, error) {
+	devices, err := r.replica.ListRecentlyUpdated(limit)
+	if err != nil {
+		r.fallback("ListRecentlyUpdated", err)
+		return r.primary.ListRecentlyUpdated(limit)
+	}
+
+	return devices, nil
+}
+
+// EachAlarmRecord streams alarms from the replica, falling back to the primary only if the
+// replica fails before yielding any alarm, like EachDevice
+func (r *FallbackDeviceReader) EachAlarmRecord(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	yielded := false
+	err := r.replica.EachAlarmRecord(ctx, filter, func(record *models.AlarmRecord) error {
+		yielded = true
+		return fn(record)
+	})
+	if err != nil && !yielded && ctx.Err() == nil {
+		r.fallback("EachAlarmRecord", err)
+		return r.primary.EachAlarmRecord(ctx, filter, fn)
+	}
+
+	return err
+}
+
+// Count counts the devices matching a set of filters
+func (r *FallbackDeviceReader) Count(opts *models.DeviceListOptions) (int, error) {
+	count, err := r.replica.Count(opts)
+	if err != nil {
+		r.fallback("Count", err)
+		return r.primary.Count(opts)
+	}
+
+	return count, nil
+}
+
+// CountDevices counts all devices and how many of them are online
+func (r *FallbackDeviceReader) CountDevices() (*models.DeviceCounts, error) {
+	counts, err := r.replica.CountDevices()
+	if err != nil {
+		r.fallback("CountDevices", err)
+		return r.primary.CountDevices()
+	}
+
+	return counts, nil
+}
+
+// CountDevicesByState counts devices per device type by connection and alarm state
+func (r *FallbackDeviceReader) CountDevicesByState() ([]*models.DeviceTypeCounts, error) {
+	counts, err := r.replica.CountDevicesByState()
+	if err != nil {
+		r.fallback("CountDevicesByState", err)
+		return r.primary.CountDevicesByState()
+	}
+
+	return counts, nil
+}
+
+// CountDevicesByType counts devices per device type
+func (r *FallbackDeviceReader) CountDevicesByType() (map[models.DeviceType]int, error) {
+	counts, err := r.replica.CountDevicesByType()
+	if err != nil {
+		r.fallback("CountDevicesByType", err)
+		return r.primary.CountDevicesByType()
+	}
+
+	return counts, nil
+}
+
+// LastModified returns when the device list last changed
+func (r *FallbackDeviceReader) LastModified() (time.Time, error) {
+	lastModified, err := r.replica.LastModified()
+	if err != nil {
+		r.fallback("LastModified", err)
+		return r.primary.LastModified()
+	}
+
+	return lastModified, nil
+}
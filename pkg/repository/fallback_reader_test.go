@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestFallbackDeviceReader(t *testing.T) {
+	primaryDB := newTestDB(t)
+	replicaDB := newTestDB(t)
+	primary := NewDeviceRepository(primaryDB)
+	replica := NewDeviceRepository(replicaDB)
+
+	// Seed each database differently so the test can tell which one served a read
+	createTestDevice(t, primary, "Primary")
+	createTestDevice(t, replica, "Replica")
+
+	reader := NewFallbackDeviceReader(replica, primary)
+
+	readName := func() string {
+		t.Helper()
+		devices, err := reader.List(&models.DeviceListOptions{Limit: 10})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(devices) != 1 {
+			t.Fatalf("Expected 1 device, got %d", len(devices))
+		}
+		return devices[0].Name
+	}
+
+	if name := readName(); name != "Replica" {
+		t.Errorf("Expected the read to be served by the replica, got %s", name)
+	}
+
+	// A replica that cannot answer, e.g. one that has not synced yet, falls back to the primary
+	if _, err := replicaDB.Exec(`DROP TABLE devices`); err != nil {
+		t.Fatalf("Failed to break replica: %v", err)
+	}
+	if name := readName(); name != "Primary" {
+		t.Errorf("Expected the read to fall back to the primary, got %s", name)
+	}
+
+	device, err := reader.GetByID(1)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if device == nil || device.Name != "Primary" {
+		t.Errorf("Expected GetByID to fall back to the primary, got %+v", device)
+	}
+}
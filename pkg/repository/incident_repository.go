@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// IncidentRepositoryImpl handles database operations for incidents
+type IncidentRepositoryImpl struct {
+	db DB
+}
+
+// NewIncidentRepository creates a new IncidentRepository
+func NewIncidentRepository(db DB) IncidentRepository {
+	return &IncidentRepositoryImpl{db: db}
+}
+
+// AttachAlarm records an alarm against the open incident of the same level that received
+// an alarm within window, opening a new incident when there is none. It returns the incident ID.
+func (r *IncidentRepositoryImpl) AttachAlarm(deviceID int64, level, reason, triggeredBy string, window time.Duration) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	var incidentID int64
+	findQuery := `SELECT id FROM incidents WHERE status = ? AND level = ? AND last_alarm_at >= ` + sqlNowOffset + ` ORDER BY last_alarm_at DESC LIMIT 1`
+	err = tx.QueryRow(findQuery, models.IncidentStatusOpen, level, fmt.Sprintf("-%d seconds", int(window.Seconds()))).Scan(&incidentID)
+	switch {
+	case err == sql.ErrNoRows:
+		result, insertErr := tx.Exec(`INSERT INTO incidents (level, status, opened_at, last_alarm_at) VALUES (?, ?, `+sqlNow+`, `+sqlNow+`)`, level, models.IncidentStatusOpen)
+		if insertErr != nil {
+			return 0, insertErr
+		}
+		if incidentID, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	default:
+		if _, err = tx.Exec(`UPDATE incidents SET last_alarm_at = `+sqlNow+` WHERE id = ?`, incidentID); err != nil {
+			return 0, err
+		}
+	}
+
+	memberQuery := `INSERT INTO incident_alarms (incident_id, device_id, reason, triggered_by, triggered_at) VALUES (?, ?, ?, ?, ` + sqlNow + `)`
+	if _, err = tx.Exec(memberQuery, incidentID, deviceID, reason, sql.NullString{String: triggeredBy, Valid: triggeredBy != ""}); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return incidentID, nil
+}
+
+// List retrieves a page of incidents, most recently opened first, together with their member alarms
+func (r *IncidentRepositoryImpl) List(opts *models.IncidentListOptions) ([]*models.Incident, error) {
+	query := `SELECT id, level, status, opened_at, last_alarm_at, resolved_at FROM incidents`
+	var args []interface{}
+
+	if len(opts.Statuses) > 0 {
+		query += ` WHERE status IN (` + placeholders(len(opts.Statuses)) + `)`
+		args = appendArgs(args, opts.Statuses)
+	}
+	query += ` ORDER BY opened_at DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, opts.Limit, opts.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	var incidents []*models.Incident
+	byID := make(map[int64]*models.Incident)
+
+	for rows.Next() {
+		var incident models.Incident
+		var openedAt, lastAlarmAt string
+		var resolvedAt sql.NullString
+
+		if err := rows.Scan(&incident.ID, &incident.Level, &incident.Status, &openedAt, &lastAlarmAt, &resolvedAt); err != nil {
+			return nil, err
+		}
+
+		// Parse time strings
+		incident.OpenedAt = parseTimestamp(openedAt)
+		incident.LastAlarmAt = parseTimestamp(lastAlarmAt)
+		incident.ResolvedAt = parseTimestamp(resolvedAt.String)
+		incident.Alarms = []models.IncidentAlarm{}
+
+		incidents = append(incidents, &incident)
+		byID[incident.ID] = &incident
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(incidents) == 0 {
+		return incidents, nil
+	}
+
+	if err := r.loadAlarms(byID); err != nil {
+		return nil, err
+	}
+
+	return incidents, nil
+}
+
+// loadAlarms fills in the member alarms of the given incidents with a single query
+func (r *IncidentRepositoryImpl) loadAlarms(incidents map[int64]*models.Incident) error {
+	placeholders := make([]string, 0, len(incidents))
+	args := make([]interface{}, 0, len(incidents))
+	for id := range incidents {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+
+	query := `SELECT id, incident_id, device_id, reason, triggered_by, triggered_at FROM incident_alarms
+		WHERE incident_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY triggered_at, id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		var alarm models.IncidentAlarm
+		var triggeredBy sql.NullString
+		var triggeredAt string
+
+		if err := rows.Scan(&alarm.ID, &alarm.IncidentID, &alarm.DeviceID, &alarm.Reason, &triggeredBy, &triggeredAt); err != nil {
+			return err
+		}
+
+		alarm.TriggeredBy = triggeredBy.String
+		alarm.TriggeredAt = parseTimestamp(triggeredAt)
+
+		incident := incidents[alarm.IncidentID]
+		incident.Alarms = append(incident.Alarms, alarm)
+	}
+
+	return rows.Err()
+}
+
+// Resolve closes an incident and clears the active alarm flag on its member devices
+func (r *IncidentRepositoryImpl) Resolve(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
+
+	result, err := tx.Exec(`UPDATE incidents SET status = ?, resolved_at = `+sqlNow+` WHERE id = ?`, models.IncidentStatusResolved, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w with ID: %d", models.ErrIncidentNotFound, id)
+	}
+
+	clearQuery := `UPDATE devices SET alarm_active = FALSE, updated_at = ` + sqlNow + `
+		WHERE id IN (SELECT device_id FROM incident_alarms WHERE incident_id = ?)`
+	if _, err := tx.Exec(clearQuery, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestIncidentRepository_AttachAlarmGroupsByLevel(t *testing.T) {
+	db := newTestDB(t)
+	devices := NewDeviceRepository(db)
+	incidents := NewIncidentRepository(db)
+
+	first := createTestDevice(t, devices, "Kitchen")
+	second := createTestDevice(t, devices, "Hallway")
+	third := createTestDevice(t, devices, "Garage")
+
+	criticalA, err := incidents.AttachAlarm(first, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", time.Minute)
+	if err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+	criticalB, err := incidents.AttachAlarm(second, models.AlarmLevelCritical, "[CRITICAL] Smoke", "sensor:hall", time.Minute)
+	if err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+	warning, err := incidents.AttachAlarm(third, models.AlarmLevelWarning, "[WARNING] Smoke", "", time.Minute)
+	if err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+
+	if criticalA != criticalB {
+		t.Errorf("Expected alarms of the same level to share an incident, got %d and %d", criticalA, criticalB)
+	}
+	if warning == criticalA {
+		t.Errorf("Expected alarms of different levels to open separate incidents")
+	}
+
+	list, err := incidents.List(&models.IncidentListOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 incidents, got %d", len(list))
+	}
+	for _, incident := range list {
+		expectedMembers := 1
+		if incident.ID == criticalA {
+			expectedMembers = 2
+		}
+		if len(incident.Alarms) != expectedMembers {
+			t.Errorf("Incident %d has %d alarms; expected %d", incident.ID, len(incident.Alarms), expectedMembers)
+		}
+	}
+}
+
+func TestIncidentRepository_AttachAlarmOutsideWindow(t *testing.T) {
+	db := newTestDB(t)
+	devices := NewDeviceRepository(db)
+	incidents := NewIncidentRepository(db)
+
+	deviceID := createTestDevice(t, devices, "Kitchen")
+
+	first, err := incidents.AttachAlarm(deviceID, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", time.Minute)
+	if err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+
+	// Age the incident beyond the grouping window
+	if _, err := db.Exec(`UPDATE incidents SET last_alarm_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-1 hour') WHERE id = ?`, first); err != nil {
+		t.Fatalf("Failed to age incident: %v", err)
+	}
+
+	second, err := incidents.AttachAlarm(deviceID, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", time.Minute)
+	if err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+	if first == second {
+		t.Errorf("Expected an alarm outside the window to open a new incident")
+	}
+}
+
+func TestIncidentRepository_Resolve(t *testing.T) {
+	db := newTestDB(t)
+	devices := NewDeviceRepository(db)
+	incidents := NewIncidentRepository(db)
+
+	deviceID := createTestDevice(t, devices, "Kitchen")
+	if _, err := devices.TriggerAlarm(deviceID, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", ""); err != nil {
+		t.Fatalf("TriggerAlarm failed: %v", err)
+	}
+	incidentID, err := incidents.AttachAlarm(deviceID, models.AlarmLevelCritical, "[CRITICAL] Smoke", "", time.Minute)
+	if err != nil {
+		t.Fatalf("AttachAlarm failed: %v", err)
+	}
+
+	if err := incidents.Resolve(incidentID); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	device, err := devices.GetByID(deviceID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if device.AlarmActive {
+		t.Errorf("Expected member device alarm to be cleared")
+	}
+
+	open, err := incidents.List(&models.IncidentListOptions{Statuses: []string{models.IncidentStatusOpen}, Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(open) != 0 {
+		t.Errorf("Expected no open incidents, got %d", len(open))
+	}
+
+	if err := incidents.Resolve(999); !errors.Is(err, models.ErrIncidentNotFound) {
+		t.Errorf("Expected ErrIncidentNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package repository stores devices, alarms, incidents, commands and the change feed in SQLite.
+// Open the database with database.NewSQLiteDB, which also migrates the schema, or with
+// database.NewManager to have it reopened when the file is replaced.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// DB is the connection pool the repositories run on, satisfied by both *sql.DB and
+// *database.Manager
+type DB interface {
+	Begin() (*sql.Tx, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DeviceReader defines the read-only device data operations, which may be served by a replica
+type DeviceReader interface {
+	GetByID(id int64) (*models.Device, error)
+	GetByIDs(ids []int64) ([]*models.Device, error)
+	GetByIDWithAlarmCount(id int64) (*models.Device, error)
+	GetByOwnerAndName(owner, name string) (*models.Device, error)
+	GetRawByID(id int64) (*models.RawDeviceRow, error)
+	Exists(id int64) (bool, error)
+	GetAll() ([]*models.Device, error)
+	List(opts *models.DeviceListOptions) ([]*models.Device, error)
+	Count(opts *models.DeviceListOptions) (int, error)
+	EachDevice(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error
+	StreamAll(ctx context.Context) (<-chan *models.Device, <-chan error)
+	ListActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error)
+	ListAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error)
+	CountAlarmHistory(filter *models.AlarmHistoryFilter) (int, error)
+	CountAlarmHistoryByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error)
+	EachAlarmRecord(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error
+	ListAlarmLevelsInUse(since time.Time) ([]string, error)
+	GetDistinctOwners(opts *models.OwnerListOptions) ([]string, error)
+	MatchOwners(owner string, limit int) ([]string, error)
+	ListChildren(id int64, limit, offset int) ([]*models.Device, error)
+	ListRecentlyUpdated(limit int) ([]*models.Device, error)
+	CountDevices() (*models.DeviceCounts, error)
+	CountDevicesByType() (map[models.DeviceType]int, error)
+	CountDevicesByState() ([]*models.DeviceTypeCounts, error)
+	LastModified() (time.Time, error)
+}
+
+// DeviceWriter defines the device data operations that modify the primary database
+type DeviceWriter interface {
+	Create(device *models.DeviceCreate) (*models.Device, error)
+	CreateBatch(devices []*models.DeviceCreate) error
+	ApplyManifest(creates []*models.DeviceCreate, changes []*models.DeviceDrift) error
+	Update(id int64, device *models.DeviceUpdate, skipUnchanged bool) (changed bool, err error)
+	Delete(id int64, children models.ChildPolicy) error
+	TriggerAlarm(id int64, level, reason, triggeredBy, reasonCode string) (suppressed bool, err error)
+	TriggerAlarmOnce(id int64, eventID, level, reason, triggeredBy, reasonCode string) (suppressed, duplicate bool, err error)
+	TriggerAlarmForClientEvent(id int64, clientEventID, level, reason, triggeredBy, reasonCode string) (record *models.AlarmRecord, duplicate bool, err error)
+	PurgeAlarmEvents(before time.Time) (int64, error)
+	PruneAlarmHistory(now time.Time, retention time.Duration) (int64, error)
+	TriggerAlarmByType(deviceType models.DeviceType, level, reason, triggeredBy, reasonCode string) ([]*models.TriggeredAlarm, error)
+	SetMaintenance(id int64, enabled bool, until time.Time) error
+	SetArchived(id int64, archived bool) error
+	RenameOwner(from, to string, merge bool) (rows map[string]int64, err error)
+	SetFirmwareTarget(id int64, version string) error
+	CompleteFirmwareUpdate(id int64, status string) (bool, error)
+	RecordSeen(id int64, at time.Time) error
+	RecordSeenOnline(id int64, at time.Time) (cameOnline bool, err error)
+	SetOnlineIfChanged(id int64, online bool) (changed bool, err error)
+	MarkUnseenOffline(deviceType models.DeviceType, before time.Time) (int64, error)
+	EndExpiredMaintenance(now time.Time) (int64, error)
+	ClearAlarm(id int64) error
+	Reset(id int64) (*models.Device, error)
+	ClearExpiredAlarms(level string, before time.Time, skipAcknowledged bool) (int64, error)
+	AcknowledgeAlarm(id int64, at time.Time) (bool, error)
+	ScheduleEscalations(level string, delay time.Duration) (int64, error)
+	ListDueEscalations(now time.Time) ([]*models.Device, error)
+	EscalateAlarm(id int64, from, to, reason, triggeredBy string, now time.Time) (bool, error)
+	PruneEscalations() (int64, error)
+	Vacuum() (*models.VacuumResult, error)
+	Ping(ctx context.Context) error
+}
+
+// DeviceRepository defines the interface for device data operations. Every implementation must
+// pass the conformance suite in conformance_test.go, which each new method must be added to.
+type DeviceRepository interface {
+	DeviceReader
+	DeviceWriter
+}
+
+// IncidentRepository defines the interface for incident data operations
+type IncidentRepository interface {
+	AttachAlarm(deviceID int64, level, reason, triggeredBy string, window time.Duration) (int64, error)
+	List(opts *models.IncidentListOptions) ([]*models.Incident, error)
+	Resolve(id int64) error
+}
+
+// AlarmReasonRepository defines the interface for alarm reason catalog operations
+type AlarmReasonRepository interface {
+	List() ([]*models.AlarmReason, error)
+	Get(code string) (*models.AlarmReason, error)
+	// Save creates the reason or replaces the text and level hint of the one with its code,
+	// reporting whether it was created
+	Save(reason *models.AlarmReason) (*models.AlarmReason, bool, error)
+	Delete(code string) error
+}
+
+// CommandRepository defines the interface for device command queue operations
+type CommandRepository interface {
+	Enqueue(deviceID int64, command string, payload json.RawMessage) (*models.DeviceCommand, error)
+	Poll(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error)
+	ListPending(deviceID int64) ([]*models.DeviceCommand, error)
+	MarkDelivered(deviceID, id int64) error
+	Ack(deviceID, id int64) error
+}
+
+// ChangeRepository defines the interface for change feed operations
+type ChangeRepository interface {
+	List(since int64, limit int) ([]*models.Change, error)
+	// Bounds returns the oldest retained and latest sequence numbers; oldest is zero when none are retained
+	Bounds() (oldest, latest int64, err error)
+	Compact(before time.Time) (int64, error)
+}
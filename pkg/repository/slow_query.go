@@ -0,0 +1,502 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// slowQueryLogger logs repository operations that take at least threshold
+type slowQueryLogger struct {
+	threshold time.Duration
+}
+
+// observe logs op if it has been running for at least the threshold since start.
+// Call it deferred, as in: defer r.observe("GetByID", time.Now())
+func (l slowQueryLogger) observe(op string, start time.Time) {
+	if elapsed := time.Since(start); elapsed >= l.threshold {
+		log.Printf("WARN slow query: %s took %s (threshold %s)", op, elapsed.Round(time.Microsecond), l.threshold)
+	}
+}
+
+// SlowQueryDeviceRepository logs device repository operations slower than a threshold
+type SlowQueryDeviceRepository struct {
+	slowQueryLogger
+	repo DeviceRepository
+}
+
+// NewSlowQueryDeviceRepository wraps repo so operations taking at least threshold are logged
+func NewSlowQueryDeviceRepository(repo DeviceRepository, threshold time.Duration) DeviceRepository {
+	return &SlowQueryDeviceRepository{slowQueryLogger: slowQueryLogger{threshold: threshold}, repo: repo}
+}
+
+// GetByID retrieves a device by its ID
+func (r *SlowQueryDeviceRepository) GetByID(id int64) (*models.Device, error) {
+	defer r.observe("devices.GetByID", time.Now())
+	return r.repo.GetByID(id)
+}
+
+// GetByIDs retrieves the devices with the given IDs in the order given
+func (r *SlowQueryDeviceRepository) GetByIDs(ids []int64) ([]*models.Device, error) {
+	defer r.observe("devices.GetByIDs", time.Now())
+	return r.repo.GetByIDs(ids)
+}
+
+// ApplyManifest creates missing devices and updates drifted ones in one transaction
+func (r *SlowQueryDeviceRepository) ApplyManifest(creates []*models.DeviceCreate, changes []*models.DeviceDrift) error {
+	defer r.observe("devices.ApplyManifest", time.Now())
+	return r.repo.ApplyManifest(creates, changes)
+}
+
+// GetRawByID reads a device's row as stored
+func (r *SlowQueryDeviceRepository) GetRawByID(id int64) (*models.RawDeviceRow, error) {
+	defer r.observe("devices.GetRawByID", time.Now())
+	return r.repo.GetRawByID(id)
+}
+
+// GetByOwnerAndName retrieves the device of an owner with the given name
+func (r *SlowQueryDeviceRepository) GetByOwnerAndName(owner, name string) (*models.Device, error) {
+	defer r.observe("devices.GetByOwnerAndName", time.Now())
+	return r.repo.GetByOwnerAndName(owner, name)
+}
+
+// GetByIDWithAlarmCount retrieves a device by its ID along with its number of alarms
+func (r *SlowQueryDeviceRepository) GetByIDWithAlarmCount(id int64) (*models.Device, error) {
+	defer r.observe("devices.GetByIDWithAlarmCount", time.Now())
+	return r.repo.GetByIDWithAlarmCount(id)
+}
+
+// Exists reports whether a device with the given ID exists
+func (r *SlowQueryDeviceRepository) Exists(id int64) (bool, error) {
+	defer r.observe("devices.Exists", time.Now())
+	return r.repo.Exists(id)
+}
+
+// GetAll retrieves all devices
+func (r *SlowQueryDeviceRepository) GetAll() ([]*models.Device, error) {
+	defer r.observe("devices.GetAll", time.Now())
+	return r.repo.GetAll()
+}
+
+// List retrieves a page of devices
+func (r *SlowQueryDeviceRepository) List(opts *models.DeviceListOptions) ([]*models.Device, error) {
+	defer r.observe("devices.List", time.Now())
+	return r.repo.List(opts)
+}
+
+// EachDevice streams devices to fn. It is not timed: its duration is dominated by fn,
+// which is usually writing to a client, rather than by the query.
+func (r *SlowQueryDeviceRepository) EachDevice(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	return r.repo.EachDevice(ctx, opts, fn)
+}
+
+// StreamAll streams every device. Like EachDevice it is not timed.
+func (r *SlowQueryDeviceRepository) StreamAll(ctx context.Context) (<-chan *models.Device, <-chan error) {
+	return r.repo.StreamAll(ctx)
+}
+
+// ListActiveAlarms retrieves devices with an active alarm
+func (r *SlowQueryDeviceRepository) ListActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	defer r.observe("devices.ListActiveAlarms", time.Now())
+	return r.repo.ListActiveAlarms(filter)
+}
+
+// ListAlarmHistory retrieves a page of alarm history
+func (r *SlowQueryDeviceRepository) ListAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	defer r.observe("devices.ListAlarmHistory", time.Now())
+	return r.repo.ListAlarmHistory(filter)
+}
+
+// CountAlarmHistory counts the alarm history matching a filter
+func (r *SlowQueryDeviceRepository) CountAlarmHistory(filter *models.AlarmHistoryFilter) (int, error) {
+	defer r.observe("devices.CountAlarmHistory", time.Now())
+	return r.repo.CountAlarmHistory(filter)
+}
+
+// CountAlarmHistoryByReasonCode counts the alarm history matching a filter per reason code
+func (r *SlowQueryDeviceRepository) CountAlarmHistoryByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	defer r.observe("devices.CountAlarmHistoryByReasonCode", time.Now())
+	return r.repo.CountAlarmHistoryByReasonCode(filter)
+}
+
+// ListAlarmLevelsInUse returns the levels of active alarms and of alarm history since a time
+func (r *SlowQueryDeviceRepository) ListAlarmLevelsInUse(since time.Time) ([]string, error) {
+	defer r.observe("devices.ListAlarmLevelsInUse", time.Now())
+	return r.repo.ListAlarmLevelsInUse(since)
+}
+
+// GetDistinctOwners returns the owners of devices, each once
+func (r *SlowQueryDeviceRepository) GetDistinctOwners(opts *models.OwnerListOptions) ([]string, error) {
+	defer r.observe("devices.GetDistinctOwners", time.Now())
+	return r.repo.GetDistinctOwners(opts)
+}
+
+// MatchOwners returns the owners similar to owner, owner itself first
+func (r *SlowQueryDeviceRepository) MatchOwners(owner string, limit int) ([]string, error) {
+	defer r.observe("devices.MatchOwners", time.Now())
+	return r.repo.MatchOwners(owner, limit)
+}
+
+// ListChildren returns the devices whose parent is the given device
+func (r *SlowQueryDeviceRepository) ListChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	defer r.observe("devices.ListChildren", time.Now())
+	return r.repo.ListChildren(id, limit, offset)
+}
+
+// ListRecentlyUpdated returns the devices changed most recently
+func (r *SlowQueryDeviceRepository) ListRecentlyUpdated(limit int) ([]*models.Device, error) {
+	defer r.observe("devices.ListRecentlyUpdated", time.Now())
+	return r.repo.ListRecentlyUpdated(limit)
+}
+
+// EachAlarmRecord streams alarms to fn. Like EachDevice it is not timed.
+func (r *SlowQueryDeviceRepository) EachAlarmRecord(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	return r.repo.EachAlarmRecord(ctx, filter, fn)
+}
+
+// Count counts the devices matching a set of filters
+func (r *SlowQueryDeviceRepository) Count(opts *models.DeviceListOptions) (int, error) {
+	defer r.observe("devices.Count", time.Now())
+	return r.repo.Count(opts)
+}
+
+// CountDevices counts all devices and how many of them are online
+func (r *SlowQueryDeviceRepository) CountDevices() (*models.DeviceCounts, error) {
+	defer r.observe("devices.CountDevices", time.Now())
+	return r.repo.CountDevices()
+}
+
+// CountDevicesByState counts devices per device type by connection and alarm state
+func (r *SlowQueryDeviceRepository) CountDevicesByState() ([]*models.DeviceTypeCounts, error) {
+	defer r.observe("devices.CountDevicesByState", time.Now())
+	return r.repo.CountDevicesByState()
+}
+
+// LastModified returns when the device list last changed
+func (r *SlowQueryDeviceRepository) LastModified() (time.Time, error) {
+	defer r.observe("devices.LastModified", time.Now())
+	return r.repo.LastModified()
+}
+
+// CountDevicesByType counts devices per device type
+func (r *SlowQueryDeviceRepository) CountDevicesByType() (map[models.DeviceType]int, error) {
+	defer r.observe("devices.CountDevicesByType", time.Now())
+	return r.repo.CountDevicesByType()
+}
+
+// Create creates a new device
+func (r *SlowQueryDeviceRepository) Create(device *models.DeviceCreate) (*models.Device, error) {
+	defer r.observe("devices.Create", time.Now())
+	return r.repo.Create(device)
+}
+
+// CreateBatch inserts several devices in a single transaction
+func (r *SlowQueryDeviceRepository) CreateBatch(devices []*models.DeviceCreate) error {
+	defer r.observe("devices.CreateBatch", time.Now())
+	return r.repo.CreateBatch(devices)
+}
+
+// Update updates a device
+func (r *SlowQueryDeviceRepository) Update(id int64, device *models.DeviceUpdate, skipUnchanged bool) (bool, error) {
+	defer r.observe("devices.Update", time.Now())
+	return r.repo.Update(id, device, skipUnchanged)
+}
+
+// Delete deletes a device
+func (r *SlowQueryDeviceRepository) Delete(id int64, children models.ChildPolicy) error {
+	defer r.observe("devices.Delete", time.Now())
+	return r.repo.Delete(id, children)
+}
+
+// TriggerAlarm records an alarm on a device
+func (r *SlowQueryDeviceRepository) TriggerAlarm(id int64, level, reason, triggeredBy, reasonCode string) (bool, error) {
+	defer r.observe("devices.TriggerAlarm", time.Now())
+	return r.repo.TriggerAlarm(id, level, reason, triggeredBy, reasonCode)
+}
+
+// TriggerAlarmOnce records an alarm on a device unless its event was already processed
+func (r *SlowQueryDeviceRepository) TriggerAlarmOnce(id int64, eventID, level, reason, triggeredBy, reasonCode string) (bool, bool, error) {
+	defer r.observe("devices.TriggerAlarmOnce", time.Now())
+	return r.repo.TriggerAlarmOnce(id, eventID, level, reason, triggeredBy, reasonCode)
+}
+
+// TriggerAlarmForClientEvent records an alarm on a device unless it has one for the client event
+func (r *SlowQueryDeviceRepository) TriggerAlarmForClientEvent(id int64, clientEventID, level, reason, triggeredBy, reasonCode string) (*models.AlarmRecord, bool, error) {
+	defer r.observe("devices.TriggerAlarmForClientEvent", time.Now())
+	return r.repo.TriggerAlarmForClientEvent(id, clientEventID, level, reason, triggeredBy, reasonCode)
+}
+
+// PurgeAlarmEvents forgets the event ids of alarms processed before the given time
+func (r *SlowQueryDeviceRepository) PurgeAlarmEvents(before time.Time) (int64, error) {
+	defer r.observe("devices.PurgeAlarmEvents", time.Now())
+	return r.repo.PurgeAlarmEvents(before)
+}
+
+// PruneAlarmHistory deletes the alarms that have outlived their retention
+func (r *SlowQueryDeviceRepository) PruneAlarmHistory(now time.Time, retention time.Duration) (int64, error) {
+	defer r.observe("devices.PruneAlarmHistory", time.Now())
+	return r.repo.PruneAlarmHistory(now, retention)
+}
+
+// TriggerAlarmByType records an alarm on every device of a type
+func (r *SlowQueryDeviceRepository) TriggerAlarmByType(deviceType models.DeviceType, level, reason, triggeredBy, reasonCode string) ([]*models.TriggeredAlarm, error) {
+	defer r.observe("devices.TriggerAlarmByType", time.Now())
+	return r.repo.TriggerAlarmByType(deviceType, level, reason, triggeredBy, reasonCode)
+}
+
+// RecordSeen records that a device was heard from
+func (r *SlowQueryDeviceRepository) RecordSeen(id int64, at time.Time) error {
+	defer r.observe("devices.RecordSeen", time.Now())
+	return r.repo.RecordSeen(id, at)
+}
+
+// RecordSeenOnline records that a device was heard from and marks it online
+func (r *SlowQueryDeviceRepository) RecordSeenOnline(id int64, at time.Time) (bool, error) {
+	defer r.observe("devices.RecordSeenOnline", time.Now())
+	return r.repo.RecordSeenOnline(id, at)
+}
+
+// SetOnlineIfChanged sets a device's online state if it differs
+func (r *SlowQueryDeviceRepository) SetOnlineIfChanged(id int64, online bool) (bool, error) {
+	defer r.observe("devices.SetOnlineIfChanged", time.Now())
+	return r.repo.SetOnlineIfChanged(id, online)
+}
+
+// MarkUnseenOffline marks devices of a type not seen since before offline
+func (r *SlowQueryDeviceRepository) MarkUnseenOffline(deviceType models.DeviceType, before time.Time) (int64, error) {
+	defer r.observe("devices.MarkUnseenOffline", time.Now())
+	return r.repo.MarkUnseenOffline(deviceType, before)
+}
+
+// SetMaintenance turns maintenance mode on or off for a device
+func (r *SlowQueryDeviceRepository) SetMaintenance(id int64, enabled bool, until time.Time) error {
+	defer r.observe("devices.SetMaintenance", time.Now())
+	return r.repo.SetMaintenance(id, enabled, until)
+}
+
+// SetArchived archives or unarchives a device
+func (r *SlowQueryDeviceRepository) SetArchived(id int64, archived bool) error {
+	defer r.observe("devices.SetArchived", time.Now())
+	return r.repo.SetArchived(id, archived)
+}
+
+// RenameOwner renames an owner on every device and record naming it
+func (r *SlowQueryDeviceRepository) RenameOwner(from, to string, merge bool) (map[string]int64, error) {
+	defer r.observe("devices.RenameOwner", time.Now())
+	return r.repo.RenameOwner(from, to, merge)
+}
+
+// SetFirmwareTarget records a firmware update pushed to a device
+func (r *SlowQueryDeviceRepository) SetFirmwareTarget(id int64, version string) error {
+	defer r.observe("devices.SetFirmwareTarget", time.Now())
+	return r.repo.SetFirmwareTarget(id, version)
+}
+
+// CompleteFirmwareUpdate ends a device's pending firmware update
+func (r *SlowQueryDeviceRepository) CompleteFirmwareUpdate(id int64, status string) (bool, error) {
+	defer r.observe("devices.CompleteFirmwareUpdate", time.Now())
+	return r.repo.CompleteFirmwareUpdate(id, status)
+}
+
+// EndExpiredMaintenance ends maintenance windows that have passed
+func (r *SlowQueryDeviceRepository) EndExpiredMaintenance(now time.Time) (int64, error) {
+	defer r.observe("devices.EndExpiredMaintenance", time.Now())
+	return r.repo.EndExpiredMaintenance(now)
+}
+
+// ClearAlarm clears the active alarm on a device
+func (r *SlowQueryDeviceRepository) ClearAlarm(id int64) error {
+	defer r.observe("devices.ClearAlarm", time.Now())
+	return r.repo.ClearAlarm(id)
+}
+
+// Reset wipes a device's mutable state
+func (r *SlowQueryDeviceRepository) Reset(id int64) (*models.Device, error) {
+	defer r.observe("devices.Reset", time.Now())
+	return r.repo.Reset(id)
+}
+
+// ClearExpiredAlarms clears active alarms of a level raised before the given time
+func (r *SlowQueryDeviceRepository) ClearExpiredAlarms(level string, before time.Time, skipAcknowledged bool) (int64, error) {
+	defer r.observe("devices.ClearExpiredAlarms", time.Now())
+	return r.repo.ClearExpiredAlarms(level, before, skipAcknowledged)
+}
+
+// AcknowledgeAlarm records that a device's active alarm has been seen
+func (r *SlowQueryDeviceRepository) AcknowledgeAlarm(id int64, at time.Time) (bool, error) {
+	defer r.observe("devices.AcknowledgeAlarm", time.Now())
+	return r.repo.AcknowledgeAlarm(id, at)
+}
+
+// ScheduleEscalations sets when unacknowledged alarms of a level escalate
+func (r *SlowQueryDeviceRepository) ScheduleEscalations(level string, delay time.Duration) (int64, error) {
+	defer r.observe("devices.ScheduleEscalations", time.Now())
+	return r.repo.ScheduleEscalations(level, delay)
+}
+
+// ListDueEscalations returns the devices whose alarm is due to escalate
+func (r *SlowQueryDeviceRepository) ListDueEscalations(now time.Time) ([]*models.Device, error) {
+	defer r.observe("devices.ListDueEscalations", time.Now())
+	return r.repo.ListDueEscalations(now)
+}
+
+// EscalateAlarm raises a device's alarm to a more severe level
+func (r *SlowQueryDeviceRepository) EscalateAlarm(id int64, from, to, reason, triggeredBy string, now time.Time) (bool, error) {
+	defer r.observe("devices.EscalateAlarm", time.Now())
+	return r.repo.EscalateAlarm(id, from, to, reason, triggeredBy, now)
+}
+
+// PruneEscalations forgets the schedules of alarms that can no longer escalate
+func (r *SlowQueryDeviceRepository) PruneEscalations() (int64, error) {
+	defer r.observe("devices.PruneEscalations", time.Now())
+	return r.repo.PruneEscalations()
+}
+
+// Vacuum reclaims the space left by deleted rows
+func (r *SlowQueryDeviceRepository) Vacuum() (*models.VacuumResult, error) {
+	defer r.observe("devices.Vacuum", time.Now())
+	return r.repo.Vacuum()
+}
+
+// Ping checks that the database answers
+func (r *SlowQueryDeviceRepository) Ping(ctx context.Context) error {
+	defer r.observe("devices.Ping", time.Now())
+	return r.repo.Ping(ctx)
+}
+
+// SlowQueryIncidentRepository logs incident repository operations slower than a threshold
+type SlowQueryIncidentRepository struct {
+	slowQueryLogger
+	repo IncidentRepository
+}
+
+// NewSlowQueryIncidentRepository wraps repo so operations taking at least threshold are logged
+func NewSlowQueryIncidentRepository(repo IncidentRepository, threshold time.Duration) IncidentRepository {
+	return &SlowQueryIncidentRepository{slowQueryLogger: slowQueryLogger{threshold: threshold}, repo: repo}
+}
+
+// AttachAlarm attaches an alarm to an open incident, opening one if needed
+func (r *SlowQueryIncidentRepository) AttachAlarm(deviceID int64, level, reason, triggeredBy string, window time.Duration) (int64, error) {
+	defer r.observe("incidents.AttachAlarm", time.Now())
+	return r.repo.AttachAlarm(deviceID, level, reason, triggeredBy, window)
+}
+
+// List retrieves incidents
+func (r *SlowQueryIncidentRepository) List(opts *models.IncidentListOptions) ([]*models.Incident, error) {
+	defer r.observe("incidents.List", time.Now())
+	return r.repo.List(opts)
+}
+
+// Resolve resolves an incident
+func (r *SlowQueryIncidentRepository) Resolve(id int64) error {
+	defer r.observe("incidents.Resolve", time.Now())
+	return r.repo.Resolve(id)
+}
+
+// SlowQueryAlarmReasonRepository logs alarm reason catalog operations slower than a threshold
+type SlowQueryAlarmReasonRepository struct {
+	slowQueryLogger
+	repo AlarmReasonRepository
+}
+
+// NewSlowQueryAlarmReasonRepository wraps repo so operations taking at least threshold are logged
+func NewSlowQueryAlarmReasonRepository(repo AlarmReasonRepository, threshold time.Duration) AlarmReasonRepository {
+	return &SlowQueryAlarmReasonRepository{slowQueryLogger: slowQueryLogger{threshold: threshold}, repo: repo}
+}
+
+// List returns every reason in the catalog
+func (r *SlowQueryAlarmReasonRepository) List() ([]*models.AlarmReason, error) {
+	defer r.observe("alarm_reasons.List", time.Now())
+	return r.repo.List()
+}
+
+// Get returns the reason with the given code
+func (r *SlowQueryAlarmReasonRepository) Get(code string) (*models.AlarmReason, error) {
+	defer r.observe("alarm_reasons.Get", time.Now())
+	return r.repo.Get(code)
+}
+
+// Save creates or replaces a reason
+func (r *SlowQueryAlarmReasonRepository) Save(reason *models.AlarmReason) (*models.AlarmReason, bool, error) {
+	defer r.observe("alarm_reasons.Save", time.Now())
+	return r.repo.Save(reason)
+}
+
+// Delete removes a reason
+func (r *SlowQueryAlarmReasonRepository) Delete(code string) error {
+	defer r.observe("alarm_reasons.Delete", time.Now())
+	return r.repo.Delete(code)
+}
+
+// SlowQueryCommandRepository logs command repository operations slower than a threshold
+type SlowQueryCommandRepository struct {
+	slowQueryLogger
+	repo CommandRepository
+}
+
+// NewSlowQueryCommandRepository wraps repo so operations taking at least threshold are logged
+func NewSlowQueryCommandRepository(repo CommandRepository, threshold time.Duration) CommandRepository {
+	return &SlowQueryCommandRepository{slowQueryLogger: slowQueryLogger{threshold: threshold}, repo: repo}
+}
+
+// Enqueue adds a pending command for a device
+func (r *SlowQueryCommandRepository) Enqueue(deviceID int64, command string, payload json.RawMessage) (*models.DeviceCommand, error) {
+	defer r.observe("commands.Enqueue", time.Now())
+	return r.repo.Enqueue(deviceID, command, payload)
+}
+
+// Poll returns a page of the unacknowledged commands for a device
+func (r *SlowQueryCommandRepository) Poll(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error) {
+	defer r.observe("commands.Poll", time.Now())
+	return r.repo.Poll(deviceID, limit, offset)
+}
+
+// ListPending returns the commands for a device that have not been delivered yet
+func (r *SlowQueryCommandRepository) ListPending(deviceID int64) ([]*models.DeviceCommand, error) {
+	defer r.observe("commands.ListPending", time.Now())
+	return r.repo.ListPending(deviceID)
+}
+
+// MarkDelivered records that a pending command was delivered
+func (r *SlowQueryCommandRepository) MarkDelivered(deviceID, id int64) error {
+	defer r.observe("commands.MarkDelivered", time.Now())
+	return r.repo.MarkDelivered(deviceID, id)
+}
+
+// Ack marks a command as acknowledged by its device
+func (r *SlowQueryCommandRepository) Ack(deviceID, id int64) error {
+	defer r.observe("commands.Ack", time.Now())
+	return r.repo.Ack(deviceID, id)
+}
+
+// SlowQueryChangeRepository logs change feed operations slower than a threshold
+type SlowQueryChangeRepository struct {
+	slowQueryLogger
+	repo ChangeRepository
+}
+
+// NewSlowQueryChangeRepository wraps repo so operations taking at least threshold are logged
+func NewSlowQueryChangeRepository(repo ChangeRepository, threshold time.Duration) ChangeRepository {
+	return &SlowQueryChangeRepository{slowQueryLogger: slowQueryLogger{threshold: threshold}, repo: repo}
+}
+
+// List returns changes after a sequence number
+func (r *SlowQueryChangeRepository) List(since int64, limit int) ([]*models.Change, error) {
+	defer r.observe("changes.List", time.Now())
+	return r.repo.List(since, limit)
+}
+
+// Bounds returns the oldest retained and latest sequence numbers
+func (r *SlowQueryChangeRepository) Bounds() (int64, int64, error) {
+	defer r.observe("changes.Bounds", time.Now())
+	return r.repo.Bounds()
+}
+
+// Compact deletes changes recorded before the given time
+func (r *SlowQueryChangeRepository) Compact(before time.Time) (int64, error) {
+	defer r.observe("changes.Compact", time.Now())
+	return r.repo.Compact(before)
+}
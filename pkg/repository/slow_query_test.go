@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLog redirects the standard logger into a buffer for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	return &buf
+}
+
+func TestSlowQueryDeviceRepository(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestDevice(t, NewDeviceRepository(db), "Kitchen")
+
+	t.Run("Logs operations over the threshold", func(t *testing.T) {
+		logs := captureLog(t)
+		repo := NewSlowQueryDeviceRepository(NewDeviceRepository(db), time.Nanosecond)
+
+		device, err := repo.GetByID(id)
+		if err != nil || device == nil || device.Name != "Kitchen" {
+			t.Fatalf("GetByID through the logger returned %+v, %v", device, err)
+		}
+
+		if !strings.Contains(logs.String(), "WARN slow query: devices.GetByID took") {
+			t.Errorf("Expected a slow query warning, got %q", logs.String())
+		}
+	})
+
+	t.Run("Quiet under the threshold", func(t *testing.T) {
+		logs := captureLog(t)
+		repo := NewSlowQueryDeviceRepository(NewDeviceRepository(db), time.Hour)
+
+		if _, err := repo.GetByID(id); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+
+		if logs.Len() != 0 {
+			t.Errorf("Expected no log output, got %q", logs.String())
+		}
+	})
+}
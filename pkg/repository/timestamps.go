@@ -0,0 +1,44 @@
+package repository
+
+import "time"
+
+// timestampLayout is the UTC RFC 3339 layout every timestamp column is written in.
+// The text is fixed-width, so stored timestamps sort and compare chronologically as strings.
+const timestampLayout = "2006-01-02T15:04:05Z"
+
+// sqlNow renders the current time in timestampLayout inside SQL statements
+const sqlNow = `strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`
+
+// sqlNowOffset is sqlNow shifted by a modifier bound as its argument, such as '-5 minutes'
+const sqlNowOffset = `strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?)`
+
+// legacyTimestampLayouts are tried when reading rows written before timestamps were normalised,
+// such as SQLite's CURRENT_TIMESTAMP text or values stored with an offset
+var legacyTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// formatTimestamp formats t for storage or comparison against a timestamp column
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// parseTimestamp reads a stored timestamp as UTC, returning the zero time for NULL or unparseable
+// text. Text without an offset was written in UTC, never in the server's local zone.
+func parseTimestamp(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range legacyTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/database"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	expected := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Time
+	}{
+		{"Normalised", "2024-05-01T12:00:00Z", expected},
+		{"RFC 3339 with offset", "2024-05-01T14:00:00+02:00", expected},
+		{"CURRENT_TIMESTAMP text", "2024-05-01 12:00:00", expected},
+		{"Driver time text", "2024-05-01 14:00:00+02:00", expected},
+		{"Fractional seconds", "2024-05-01 12:00:00.000", expected},
+		{"Empty", "", time.Time{}},
+		{"Garbage", "yesterday", time.Time{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseTimestamp(tc.input)
+			if !got.Equal(tc.expected) || got.Location() != time.UTC {
+				t.Errorf("parseTimestamp(%q) = %v; expected %v in UTC", tc.input, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseTimestampIgnoresLocalZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+10", 10*60*60)
+	defer func() { time.Local = local }()
+
+	got := parseTimestamp("2024-05-01 12:00:00")
+	if expected := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC); !got.Equal(expected) || got.Location() != time.UTC {
+		t.Errorf("Expected %v whatever the local zone, got %v", expected, got)
+	}
+}
+
+func TestTimestampsWrittenAsUTCRFC3339(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDeviceRepository(db)
+	id := createTestDevice(t, repo, "Kitchen")
+
+	var createdAt, updatedAt string
+	if err := db.QueryRow(`SELECT CAST(created_at AS TEXT), CAST(updated_at AS TEXT) FROM devices WHERE id = ?`, id).Scan(&createdAt, &updatedAt); err != nil {
+		t.Fatalf("Failed to read timestamps: %v", err)
+	}
+	for _, stored := range []string{createdAt, updatedAt} {
+		if _, err := time.Parse(timestampLayout, stored); err != nil {
+			t.Errorf("Expected a UTC RFC 3339 timestamp, got %q", stored)
+		}
+	}
+}
+
+func TestLegacyTimestampsNormalisedOnStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := database.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	legacy := []string{"2024-05-01 12:00:00", "2024-05-01 14:00:00+02:00", "2024-05-01T12:00:00Z", "not a time"}
+	for _, stored := range legacy {
+		if _, err := db.Exec(`INSERT INTO devices (name, device_type, owned_by, created_at, alarm_acknowledged_at) VALUES ('Legacy', 'LOCK', 'owner', ?, ?)`, stored, stored); err != nil {
+			t.Fatalf("Failed to insert legacy row: %v", err)
+		}
+	}
+	changes := func() (count int) {
+		if err := db.QueryRow(`SELECT COUNT(*) FROM device_changes`).Scan(&count); err != nil {
+			t.Fatalf("Failed to count changes: %v", err)
+		}
+		return count
+	}
+	recorded := changes()
+	// Backfills run when the schema is brought up from an older version
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", database.SchemaVersion-1)); err != nil {
+		t.Fatalf("Failed to set the schema version: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	db, err = database.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT CAST(created_at AS TEXT), CAST(alarm_acknowledged_at AS TEXT), version FROM devices ORDER BY id`)
+	if err != nil {
+		t.Fatalf("Failed to read timestamps: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var created, acknowledged string
+		var version int64
+		if err := rows.Scan(&created, &acknowledged, &version); err != nil {
+			t.Fatalf("Failed to scan timestamp: %v", err)
+		}
+		if created != acknowledged {
+			t.Errorf("Expected alarm_acknowledged_at normalised like created_at, got %q and %q", acknowledged, created)
+		}
+		if version != 1 {
+			t.Errorf("Expected the rewrite not to bump the version, got %d", version)
+		}
+		got = append(got, created)
+	}
+
+	expected := []string{"2024-05-01T12:00:00Z", "2024-05-01T12:00:00Z", "2024-05-01T12:00:00Z", "not a time"}
+	for i := range expected {
+		if i >= len(got) || got[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+	}
+	if count := changes(); count != recorded {
+		t.Errorf("Expected the rewrite to record no changes, got %d more", count-recorded)
+	}
+
+	// Updates are recorded again once the backfill is done
+	if _, err := db.Exec(`UPDATE devices SET name = 'Renamed' WHERE id = 1`); err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+	if count := changes(); count != recorded+1 {
+		t.Errorf("Expected the update recorded, got %d more changes", count-recorded)
+	}
+}
+
+func TestTimestampsNotRewrittenAtCurrentVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "current.db")
+	db, err := database.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO devices (name, device_type, owned_by, created_at) VALUES ('Kitchen', 'LOCK', 'owner', '2024-05-01 12:00:00')`); err != nil {
+		t.Fatalf("Failed to insert row: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	db, err = database.NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	var created string
+	var version int64
+	if err := db.QueryRow(`SELECT CAST(created_at AS TEXT), version FROM devices`).Scan(&created, &version); err != nil {
+		t.Fatalf("Failed to read device: %v", err)
+	}
+	if created != "2024-05-01 12:00:00" || version != 1 {
+		t.Errorf("Expected a database already at the current version left alone, got %q at version %d", created, version)
+	}
+}
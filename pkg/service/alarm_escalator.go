@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// escalationActor is recorded as the triggered_by of escalated alarms
+const escalationActor = "escalation"
+
+// AlarmEscalator periodically raises active alarms that go unacknowledged past their level's
+// escalation delay. When each alarm escalates is stored with it rather than kept in timers, so a
+// restart neither loses nor repeats an escalation, and an alarm that is acknowledged, cleared or
+// replaced stops escalating.
+type AlarmEscalator struct {
+	repo  repository.DeviceWriter
+	rules map[string]models.EscalationRule
+
+	// incidents, when set, receives escalations whose rule renotifies
+	incidents      repository.IncidentRepository
+	incidentWindow time.Duration
+
+	now func() time.Time
+}
+
+// NewAlarmEscalator creates an AlarmEscalator applying rules, keyed by the level they escalate.
+// Escalations whose rule renotifies are attached to incidents of their new level opened within
+// incidentWindow; incidents may be nil when alarms are not grouped.
+func NewAlarmEscalator(repo repository.DeviceWriter, rules map[string]models.EscalationRule,
+	incidents repository.IncidentRepository, incidentWindow time.Duration) *AlarmEscalator {
+	return &AlarmEscalator{repo: repo, rules: rules, incidents: incidents, incidentWindow: incidentWindow, now: time.Now}
+}
+
+// Evaluate escalates every alarm that is due once, returning how many were escalated
+func (e *AlarmEscalator) Evaluate() (int, error) {
+	if _, err := e.repo.PruneEscalations(); err != nil {
+		return 0, err
+	}
+	for level, rule := range e.rules {
+		if _, err := e.repo.ScheduleEscalations(level, rule.Delay); err != nil {
+			return 0, err
+		}
+	}
+
+	now := e.now()
+	due, err := e.repo.ListDueEscalations(now)
+	if err != nil {
+		return 0, err
+	}
+
+	escalated := 0
+	for _, device := range due {
+		rule, ok := e.rules[device.LastAlarmLevel]
+		if !ok {
+			continue
+		}
+
+		reason := fmt.Sprintf("[%s] Escalated from %s: %s", rule.Target, rule.Level,
+			strings.TrimPrefix(device.LastAlarmReason, "["+rule.Level+"] "))
+		ok, err := e.repo.EscalateAlarm(device.ID, rule.Level, rule.Target, reason, escalationActor, now)
+		if err != nil {
+			return escalated, err
+		}
+		if !ok {
+			// Acknowledged, cleared or escalated elsewhere since it was listed
+			continue
+		}
+		escalated++
+		log.Printf("Escalated device %d alarm from %s to %s", device.ID, rule.Level, rule.Target)
+
+		if rule.Renotify && e.incidents != nil {
+			if _, err := e.incidents.AttachAlarm(device.ID, rule.Target, reason, escalationActor, e.incidentWindow); err != nil {
+				return escalated, fmt.Errorf("failed to attach escalation to incident: %w", err)
+			}
+		}
+	}
+
+	return escalated, nil
+}
+
+// Run evaluates on every interval until ctx is cancelled
+func (e *AlarmEscalator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := e.Evaluate(); err != nil {
+				log.Printf("Error escalating alarms: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestAlarmEscalator_Evaluate(t *testing.T) {
+	clock := testutil.NewClock(testutil.Epoch)
+
+	repo := &MockDeviceRepo{
+		dueDevices: []*models.Device{
+			testutil.NewDevice().WithID(1).WithAlarm(models.AlarmLevelWarning, "[WARNING] Smoke", testutil.Epoch).Build(),
+			testutil.NewDevice().WithID(2).WithAlarm(models.AlarmLevelInfo, "[INFO] Door open", testutil.Epoch).Build(),
+			testutil.NewDevice().WithID(3).WithAlarm(models.AlarmLevelCritical, "[CRITICAL] Fire", testutil.Epoch).Build(),
+			// Acknowledged between being listed and escalated
+			testutil.NewDevice().WithID(4).WithAlarm(models.AlarmLevelWarning, "[WARNING] Low battery", testutil.Epoch).Build(),
+		},
+		escalations: map[int64]bool{1: true, 2: true, 3: true},
+	}
+	incidents := &MockIncidentRepo{}
+	escalator := NewAlarmEscalator(repo, map[string]models.EscalationRule{
+		models.AlarmLevelWarning: {Level: models.AlarmLevelWarning, Target: models.AlarmLevelCritical, Delay: 15 * time.Minute, Renotify: true},
+		models.AlarmLevelInfo:    {Level: models.AlarmLevelInfo, Target: models.AlarmLevelWarning, Delay: time.Hour},
+	}, incidents, time.Hour)
+	escalator.now = clock.Now
+
+	escalated, err := escalator.Evaluate()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if escalated != 2 {
+		t.Errorf("Expected 2 escalations, got %d", escalated)
+	}
+	if !repo.pruned {
+		t.Errorf("Expected schedules of finished alarms to be pruned")
+	}
+	if repo.scheduled[models.AlarmLevelWarning] != 15*time.Minute || repo.scheduled[models.AlarmLevelInfo] != time.Hour {
+		t.Errorf("Expected each rule's level scheduled with its delay, got %v", repo.scheduled)
+	}
+	if got := repo.escalated[1]; got != "[CRITICAL] Escalated from WARNING: Smoke" {
+		t.Errorf("Unexpected escalation reason %q", got)
+	}
+	if got := repo.escalated[2]; got != "[WARNING] Escalated from INFO: Door open" {
+		t.Errorf("Unexpected escalation reason %q", got)
+	}
+	if _, ok := repo.escalated[3]; ok {
+		t.Errorf("Expected CRITICAL alarms, which have no rule, not to escalate")
+	}
+	if incidents.attachCount != 1 || incidents.attachDeviceID != 1 || incidents.attachLevel != models.AlarmLevelCritical {
+		t.Errorf("Expected only the renotifying escalation attached as CRITICAL, got %d attachments (device %d, level %s)",
+			incidents.attachCount, incidents.attachDeviceID, incidents.attachLevel)
+	}
+}
+
+func TestAcknowledgeAlarm(t *testing.T) {
+	tests := []struct {
+		name         string
+		acknowledged bool
+		device       *models.Device
+		expectedErr  error
+	}{
+		{"Acknowledged", true, nil, nil},
+		{"Already acknowledged", false, testutil.NewDevice().WithAlarm(models.AlarmLevelWarning, "[WARNING] Smoke", testutil.Epoch).Build(), nil},
+		{"No active alarm", false, testutil.NewDevice().Build(), models.ErrNoActiveAlarm},
+		{"Device not found", false, nil, models.ErrDeviceNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockDeviceRepo{acknowledged: tc.acknowledged, getByIDOutput: tc.device}
+			service := NewDeviceService(repo)
+
+			err := service.AcknowledgeAlarm(1)
+			if tc.expectedErr == nil && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// AlarmHistoryPruner periodically deletes alarm history that has outlived its retention: the
+// device's own alarm_retention_days when set, else the global retention
+type AlarmHistoryPruner struct {
+	repo      repository.DeviceWriter
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewAlarmHistoryPruner creates an AlarmHistoryPruner. A zero retention keeps the history of
+// devices without an override forever, so only overrides are pruned.
+func NewAlarmHistoryPruner(repo repository.DeviceWriter, retention time.Duration) *AlarmHistoryPruner {
+	return &AlarmHistoryPruner{repo: repo, retention: retention, now: time.Now}
+}
+
+// Prune deletes expired alarm history once, returning how many alarms were deleted
+func (p *AlarmHistoryPruner) Prune() (int64, error) {
+	deleted, err := p.repo.PruneAlarmHistory(p.now(), p.retention)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d alarm history record(s)", deleted)
+	}
+
+	return deleted, nil
+}
+
+// Run prunes on every interval until ctx is cancelled
+func (p *AlarmHistoryPruner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.Prune(); err != nil {
+				log.Printf("Error pruning alarm history: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+)
+
+func TestAlarmHistoryPruner_Prune(t *testing.T) {
+	clock := testutil.NewClock(testutil.Epoch)
+
+	repo := &MockDeviceRepo{}
+	pruner := NewAlarmHistoryPruner(repo, 90*24*time.Hour)
+	pruner.now = clock.Now
+
+	if _, err := pruner.Prune(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !repo.historyPrunedAt.Equal(testutil.Epoch) {
+		t.Errorf("Expected the history pruned as of %s, got %s", testutil.Epoch, repo.historyPrunedAt)
+	}
+	if repo.historyRetention != 90*24*time.Hour {
+		t.Errorf("Expected the global retention passed through, got %s", repo.historyRetention)
+	}
+}
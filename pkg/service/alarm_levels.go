@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// WithAlarmLevels sets the alarm levels in use, whose order ranks active alarms by severity.
+// Without it the built-in levels are used.
+func WithAlarmLevels(levels *models.AlarmLevels) Option {
+	return func(s *DeviceService) {
+		s.alarmLevels = levels
+	}
+}
+
+// CheckAlarmLevels makes sure no level missing from the configured alarm levels is still in use:
+// raised by an active alarm, or recorded in the alarm history within retention. A zero retention
+// checks the whole history. Stored alarms keep rendering with a removed level, but it could no
+// longer be filtered on or escalated, so removing a level has to wait until it falls out of use.
+func (s *DeviceService) CheckAlarmLevels(retention time.Duration) error {
+	var since time.Time
+	if retention > 0 {
+		since = s.now().Add(-retention)
+	}
+
+	used, err := s.repo.ListAlarmLevelsInUse(since)
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, level := range used {
+		if !s.alarmLevels.IsValid(level) {
+			removed = append(removed, level)
+		}
+	}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		return fmt.Errorf("%w: %s", models.ErrAlarmLevelInUse, strings.Join(removed, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestCheckAlarmLevels(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	levels, err := models.NewAlarmLevels([]string{"INFO", "WARNING", "CRITICAL", "EMERGENCY"})
+	if err != nil {
+		t.Fatalf("NewAlarmLevels failed: %v", err)
+	}
+
+	repo := &MockDeviceRepo{levelsInUse: []string{"CRITICAL", "SEV_2", "EMERGENCY", "DEBUG"}}
+	service := NewDeviceService(repo, WithAlarmLevels(levels))
+	service.now = func() time.Time { return now }
+
+	err = service.CheckAlarmLevels(24 * time.Hour)
+	if !errors.Is(err, models.ErrAlarmLevelInUse) || !strings.HasSuffix(err.Error(), ": DEBUG, SEV_2") {
+		t.Errorf("Expected the removed levels in use to be reported, got %v", err)
+	}
+	if !repo.levelsSince.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("Expected levels used since a day ago to be checked, got %s", repo.levelsSince)
+	}
+
+	repo.levelsInUse = []string{"INFO", "EMERGENCY"}
+	if err := service.CheckAlarmLevels(0); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+	if !repo.levelsSince.IsZero() {
+		t.Errorf("Expected a zero retention to check the whole history, got %s", repo.levelsSince)
+	}
+}
+
+func TestGetActiveAlarmsLevelOrder(t *testing.T) {
+	levels, err := models.NewAlarmLevels([]string{"INFO", "WARNING", "CRITICAL", "EMERGENCY"})
+	if err != nil {
+		t.Fatalf("NewAlarmLevels failed: %v", err)
+	}
+
+	repo := &MockDeviceRepo{}
+	if _, err := NewDeviceService(repo, WithAlarmLevels(levels)).GetActiveAlarms(&models.ActiveAlarmFilter{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !slices.Equal(repo.activeFilter.LevelOrder, levels.IDs()) {
+		t.Errorf("Expected active alarms ordered by the configured levels, got %v", repo.activeFilter.LevelOrder)
+	}
+}
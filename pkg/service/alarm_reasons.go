@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// WithAlarmReasons resolves the reason codes alarms give against the catalog in reasons, recording
+// the catalog's text as their reason. With strict, alarms giving no code are refused with
+// ErrReasonCodeRequired; otherwise free-text reasons are still accepted.
+func WithAlarmReasons(reasons repository.AlarmReasonRepository, strict bool) Option {
+	return func(s *DeviceService) {
+		s.reasons = reasons
+		s.strictReasons = strict
+	}
+}
+
+// resolveReason returns the reason an alarm is recorded with, and the catalog code it was taken
+// from, if any. A code the catalog does not hold is refused with ErrUnknownReasonCode.
+func (s *DeviceService) resolveReason(alarm *models.AlarmRequest) (reason, code string, err error) {
+	if alarm.ReasonCode == "" {
+		if s.strictReasons {
+			return "", "", models.ErrReasonCodeRequired
+		}
+		return alarm.Reason, "", nil
+	}
+	if s.reasons == nil {
+		return "", "", fmt.Errorf("%w: %s", models.ErrUnknownReasonCode, alarm.ReasonCode)
+	}
+
+	entry, err := s.reasons.Get(alarm.ReasonCode)
+	if errors.Is(err, models.ErrAlarmReasonNotFound) {
+		return "", "", fmt.Errorf("%w: %s", models.ErrUnknownReasonCode, alarm.ReasonCode)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return entry.Text, entry.Code, nil
+}
+
+// ListAlarmReasons returns the alarm reason catalog, ordered by code. Without WithAlarmReasons it
+// returns ErrAlarmReasonsUnsupported, as do the other catalog methods.
+func (s *DeviceService) ListAlarmReasons() ([]*models.AlarmReason, error) {
+	if s.reasons == nil {
+		return nil, models.ErrAlarmReasonsUnsupported
+	}
+	return s.reasons.List()
+}
+
+// SaveAlarmReason adds a reason to the catalog, or rewords the one with its code, reporting
+// whether it was added. Alarms already recorded keep the text they were recorded with.
+func (s *DeviceService) SaveAlarmReason(reason *models.AlarmReason) (*models.AlarmReason, bool, error) {
+	if s.reasons == nil {
+		return nil, false, models.ErrAlarmReasonsUnsupported
+	}
+	return s.reasons.Save(reason)
+}
+
+// DeleteAlarmReason removes a reason from the catalog. Alarms giving its code are refused from
+// then on; those already recorded keep it.
+func (s *DeviceService) DeleteAlarmReason(code string) error {
+	if s.reasons == nil {
+		return models.ErrAlarmReasonsUnsupported
+	}
+	return s.reasons.Delete(code)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// MockAlarmReasonRepo holds an alarm reason catalog in memory
+type MockAlarmReasonRepo struct {
+	reasons map[string]*models.AlarmReason
+}
+
+func (m *MockAlarmReasonRepo) List() ([]*models.AlarmReason, error) { return nil, nil }
+
+func (m *MockAlarmReasonRepo) Get(code string) (*models.AlarmReason, error) {
+	reason, ok := m.reasons[code]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", models.ErrAlarmReasonNotFound, code)
+	}
+	return reason, nil
+}
+
+func (m *MockAlarmReasonRepo) Save(reason *models.AlarmReason) (*models.AlarmReason, bool, error) {
+	return reason, true, nil
+}
+
+func (m *MockAlarmReasonRepo) Delete(string) error { return nil }
+
+func TestTriggerAlarmReasonCodes(t *testing.T) {
+	catalog := &MockAlarmReasonRepo{reasons: map[string]*models.AlarmReason{
+		"smoke": {Code: "smoke", Text: "Smoke detected"},
+	}}
+
+	tests := []struct {
+		name         string
+		strict       bool
+		alarm        models.AlarmRequest
+		expectErr    error
+		expectReason string
+		expectCode   string
+	}{
+		{"Code replaces the reason", false, models.AlarmRequest{Reason: "smoek", ReasonCode: "smoke", Level: "WARNING"}, nil, "[WARNING] Smoke detected", "smoke"},
+		{"Code alone", false, models.AlarmRequest{ReasonCode: "smoke", Level: "CRITICAL"}, nil, "[CRITICAL] Smoke detected", "smoke"},
+		{"Free text", false, models.AlarmRequest{Reason: "Door open", Level: "INFO"}, nil, "[INFO] Door open", ""},
+		{"Unknown code", false, models.AlarmRequest{ReasonCode: "fire", Level: "CRITICAL"}, models.ErrUnknownReasonCode, "", ""},
+		{"Strict refuses free text", true, models.AlarmRequest{Reason: "Door open", Level: "INFO"}, models.ErrReasonCodeRequired, "", ""},
+		{"Strict accepts a code", true, models.AlarmRequest{ReasonCode: "smoke", Level: "WARNING"}, nil, "[WARNING] Smoke detected", "smoke"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockDeviceRepo{existsOutput: true}
+			service := NewDeviceService(repo, WithAlarmReasons(catalog, tc.strict))
+
+			err := service.TriggerAlarm(1, &tc.alarm)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			if tc.expectErr != nil {
+				if repo.triggerAlarmCalled {
+					t.Error("Expected a refused alarm not to be recorded")
+				}
+				return
+			}
+			if repo.triggerAlarmReason != tc.expectReason || repo.triggerAlarmCode != tc.expectCode {
+				t.Errorf("Expected reason %q with code %q, got %q with %q", tc.expectReason, tc.expectCode, repo.triggerAlarmReason, repo.triggerAlarmCode)
+			}
+		})
+	}
+
+	t.Run("Type alarms", func(t *testing.T) {
+		repo := &MockDeviceRepo{}
+		service := NewDeviceService(repo, WithAlarmReasons(catalog, false))
+
+		if _, err := service.TriggerAlarmByType(models.DeviceTypeSmokeDetector, &models.AlarmRequest{ReasonCode: "smoke", Level: "CRITICAL"}); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if repo.typeAlarmReason != "[CRITICAL] Smoke detected" || repo.triggerAlarmCode != "smoke" {
+			t.Errorf("Expected the catalog's reason with its code, got %q with %q", repo.typeAlarmReason, repo.triggerAlarmCode)
+		}
+	})
+
+	t.Run("Without a catalog", func(t *testing.T) {
+		service := NewDeviceService(&MockDeviceRepo{existsOutput: true})
+
+		if err := service.TriggerAlarm(1, &models.AlarmRequest{ReasonCode: "smoke", Level: "CRITICAL"}); !errors.Is(err, models.ErrUnknownReasonCode) {
+			t.Errorf("Expected ErrUnknownReasonCode, got %v", err)
+		}
+		if _, err := service.ListAlarmReasons(); !errors.Is(err, models.ErrAlarmReasonsUnsupported) {
+			t.Errorf("Expected ErrAlarmReasonsUnsupported, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// AlarmSweeper periodically clears active alarms that have outlived their level's TTL
+// and takes devices out of maintenance once their maintenance window has ended. It also
+// forgets the event ids of alarms processed longer ago than eventTTL.
+type AlarmSweeper struct {
+	repo     repository.DeviceWriter
+	ttls     map[string]time.Duration
+	eventTTL time.Duration
+	// skipAcknowledged leaves acknowledged alarms active however long ago they were raised
+	skipAcknowledged bool
+	now              func() time.Time
+}
+
+// NewAlarmSweeper creates an AlarmSweeper using the given per-level TTLs.
+// Levels without a TTL never expire; a zero eventTTL keeps alarm event ids forever.
+// With skipAcknowledged, acknowledged alarms are only ever cleared by hand.
+func NewAlarmSweeper(repo repository.DeviceWriter, ttls map[string]time.Duration, eventTTL time.Duration, skipAcknowledged bool) *AlarmSweeper {
+	return &AlarmSweeper{repo: repo, ttls: ttls, eventTTL: eventTTL, skipAcknowledged: skipAcknowledged, now: time.Now}
+}
+
+// Sweep clears every expired active alarm once, recording each in the alarm history as
+// auto_cleared, and returns how many were cleared
+func (s *AlarmSweeper) Sweep() (int64, error) {
+	now := s.now()
+
+	ended, err := s.repo.EndExpiredMaintenance(now)
+	if err != nil {
+		return 0, err
+	}
+	if ended > 0 {
+		log.Printf("Ended maintenance for %d device(s)", ended)
+	}
+
+	if s.eventTTL > 0 {
+		if _, err := s.repo.PurgeAlarmEvents(now.Add(-s.eventTTL)); err != nil {
+			return 0, err
+		}
+	}
+
+	var total int64
+	for level, ttl := range s.ttls {
+		cleared, err := s.repo.ClearExpiredAlarms(level, now.Add(-ttl), s.skipAcknowledged)
+		if err != nil {
+			return total, err
+		}
+		if cleared > 0 {
+			log.Printf("Auto-cleared %d expired %s alarm(s)", cleared, level)
+		}
+		total += cleared
+	}
+
+	return total, nil
+}
+
+// Run sweeps on every interval until ctx is cancelled
+func (s *AlarmSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Sweep(); err != nil {
+				log.Printf("Error clearing expired alarms: %v", err)
+			}
+		}
+	}
+}
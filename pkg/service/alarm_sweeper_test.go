@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+)
+
+func TestAlarmSweeper_Sweep(t *testing.T) {
+	clock := testutil.NewClock(testutil.Epoch)
+
+	repo := &MockDeviceRepo{clearExpiredOutput: 2}
+	sweeper := NewAlarmSweeper(repo, map[string]time.Duration{
+		"INFO":    time.Hour,
+		"WARNING": 24 * time.Hour,
+	}, 6*time.Hour, true)
+	sweeper.now = clock.Now
+
+	cleared, err := sweeper.Sweep()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if cleared != 4 {
+		t.Errorf("Expected 4 cleared alarms, got %d", cleared)
+	}
+
+	expectedCutoffs := map[string]time.Time{
+		"INFO":    testutil.Epoch.Add(-time.Hour),
+		"WARNING": testutil.Epoch.Add(-24 * time.Hour),
+	}
+	if len(repo.clearExpiredCalls) != len(expectedCutoffs) {
+		t.Fatalf("Expected %d levels swept, got %d", len(expectedCutoffs), len(repo.clearExpiredCalls))
+	}
+	for level, expected := range expectedCutoffs {
+		if got := repo.clearExpiredCalls[level]; !got.Equal(expected) {
+			t.Errorf("Level %s swept with cutoff %s; expected %s", level, got, expected)
+		}
+	}
+	if !repo.clearExpiredSkipAck {
+		t.Errorf("Expected acknowledged alarms to be skipped")
+	}
+	if _, swept := repo.clearExpiredCalls["CRITICAL"]; swept {
+		t.Errorf("Expected CRITICAL alarms never to be swept")
+	}
+	if expected := testutil.Epoch.Add(-6 * time.Hour); !repo.eventsPurgedBefore.Equal(expected) {
+		t.Errorf("Expected alarm event ids processed before %s to be purged, got %s", expected, repo.eventsPurgedBefore)
+	}
+	if !repo.maintenanceEndedAt.Equal(testutil.Epoch) {
+		t.Errorf("Expected expired maintenance to be ended as of %s, got %s", testutil.Epoch, repo.maintenanceEndedAt)
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// ChangeCompactor periodically deletes change feed entries older than the retention period
+type ChangeCompactor struct {
+	repo      repository.ChangeRepository
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewChangeCompactor creates a ChangeCompactor that keeps changes for retention
+func NewChangeCompactor(repo repository.ChangeRepository, retention time.Duration) *ChangeCompactor {
+	return &ChangeCompactor{repo: repo, retention: retention, now: time.Now}
+}
+
+// Compact deletes expired changes once, returning how many were deleted
+func (c *ChangeCompactor) Compact() (int64, error) {
+	deleted, err := c.repo.Compact(c.now().Add(-c.retention))
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Printf("Compacted %d change feed entries", deleted)
+	}
+
+	return deleted, nil
+}
+
+// Run compacts on every interval until ctx is cancelled
+func (c *ChangeCompactor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Compact(); err != nil {
+				log.Printf("Error compacting change feed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// ChangeService handles business logic for the change feed
+type ChangeService struct {
+	repo repository.ChangeRepository
+}
+
+// NewChangeService creates a new ChangeService
+func NewChangeService(repo repository.ChangeRepository) *ChangeService {
+	return &ChangeService{repo: repo}
+}
+
+// GetChanges returns up to limit changes after since. It returns ErrChangesCompacted when some
+// of those changes are no longer retained, since the client would otherwise silently miss them.
+func (s *ChangeService) GetChanges(since int64, limit int) (*models.ChangeFeed, error) {
+	oldest, latest, err := s.repo.Bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	// Changes up to oldest-1 have been compacted; if everything has been, oldest is zero
+	if since < latest && (oldest == 0 || since < oldest-1) {
+		return nil, models.ErrChangesCompacted
+	}
+
+	// Fetch one extra change to learn whether there is another page
+	changes, err := s.repo.List(since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &models.ChangeFeed{LatestSeq: latest, NextSince: since}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		feed.HasMore = true
+	}
+	if len(changes) > 0 {
+		feed.NextSince = changes[len(changes)-1].Seq
+	}
+	// Changes recorded after Bounds was read are still returned, so keep LatestSeq consistent
+	if feed.NextSince > feed.LatestSeq {
+		feed.LatestSeq = feed.NextSince
+	}
+	feed.Changes = changes
+
+	return feed, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// stubChangeRepo serves changes from memory; changes must be in seq order
+type stubChangeRepo struct {
+	changes []*models.Change
+	latest  int64
+}
+
+func (r *stubChangeRepo) List(since int64, limit int) ([]*models.Change, error) {
+	var page []*models.Change
+	for _, change := range r.changes {
+		if change.Seq > since && len(page) < limit {
+			page = append(page, change)
+		}
+	}
+	return page, nil
+}
+
+func (r *stubChangeRepo) Bounds() (int64, int64, error) {
+	if len(r.changes) == 0 {
+		return 0, r.latest, nil
+	}
+	return r.changes[0].Seq, r.latest, nil
+}
+
+func (r *stubChangeRepo) Compact(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestChangeService_GetChanges(t *testing.T) {
+	// Changes 1 and 2 have been compacted
+	repo := &stubChangeRepo{
+		changes: []*models.Change{{Seq: 3}, {Seq: 4}, {Seq: 5}},
+		latest:  5,
+	}
+	svc := NewChangeService(repo)
+
+	tests := []struct {
+		name         string
+		since        int64
+		limit        int
+		expectedErr  error
+		expectedSeqs []int64
+		expectedNext int64
+		expectedMore bool
+	}{
+		{"Compacted range", 1, 10, models.ErrChangesCompacted, nil, 0, false},
+		{"Just before oldest retained", 2, 10, nil, []int64{3, 4, 5}, 5, false},
+		{"Paged", 2, 2, nil, []int64{3, 4}, 4, true},
+		{"Up to date", 5, 10, nil, nil, 5, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			feed, err := svc.GetChanges(tc.since, tc.limit)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var seqs []int64
+			for _, change := range feed.Changes {
+				seqs = append(seqs, change.Seq)
+			}
+			if len(seqs) != len(tc.expectedSeqs) {
+				t.Fatalf("Expected changes %v, got %v", tc.expectedSeqs, seqs)
+			}
+			for i := range seqs {
+				if seqs[i] != tc.expectedSeqs[i] {
+					t.Fatalf("Expected changes %v, got %v", tc.expectedSeqs, seqs)
+				}
+			}
+			if feed.NextSince != tc.expectedNext || feed.HasMore != tc.expectedMore || feed.LatestSeq != 5 {
+				t.Errorf("Unexpected feed metadata: %+v", feed)
+			}
+		})
+	}
+
+	// Once everything has been compacted only an up-to-date client can continue
+	repo.changes = nil
+	if _, err := svc.GetChanges(4, 10); !errors.Is(err, models.ErrChangesCompacted) {
+		t.Errorf("Expected ErrChangesCompacted, got %v", err)
+	}
+	if _, err := svc.GetChanges(5, 10); err != nil {
+		t.Errorf("Expected an up-to-date client to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// CommandService handles business logic for the device command queue
+type CommandService struct {
+	repo    repository.CommandRepository
+	devices repository.DeviceReader
+}
+
+// NewCommandService creates a new CommandService. devices is used to reject commands for
+// unknown devices and should read from the primary database.
+func NewCommandService(repo repository.CommandRepository, devices repository.DeviceReader) *CommandService {
+	return &CommandService{repo: repo, devices: devices}
+}
+
+// EnqueueCommand queues a command for a device, whether or not it is currently connected
+func (s *CommandService) EnqueueCommand(deviceID int64, req *models.CommandRequest) (*models.DeviceCommand, error) {
+	if err := ensureDeviceExists(s.devices, deviceID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.Enqueue(deviceID, req.Command, req.Payload)
+}
+
+// PollCommands returns a page of a device's unacknowledged commands, marking the pending ones
+// returned as delivered
+func (s *CommandService) PollCommands(deviceID int64, limit, offset int) ([]*models.DeviceCommand, error) {
+	if err := ensureDeviceExists(s.devices, deviceID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.Poll(deviceID, limit, offset)
+}
+
+// PendingCommands returns the commands that have not yet been delivered to a device
+func (s *CommandService) PendingCommands(deviceID int64) ([]*models.DeviceCommand, error) {
+	return s.repo.ListPending(deviceID)
+}
+
+// MarkDelivered records that a command was pushed to its device
+func (s *CommandService) MarkDelivered(deviceID, id int64) error {
+	return s.repo.MarkDelivered(deviceID, id)
+}
+
+// AckCommand marks a command as acknowledged by its device
+func (s *CommandService) AckCommand(deviceID, id int64) error {
+	return s.repo.Ack(deviceID, id)
+}
@@ -0,0 +1,782 @@
+// Package service holds the device management logic. It can be embedded in another program by
+// wrapping a repository, as the HTTP server in internal/handlers does.
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+	"github.com/tyrese-r/go-home/pkg/validation"
+)
+
+// dashboardRecentAlarms is how many recent alarms the dashboard includes
+const dashboardRecentAlarms = 5
+
+// DeviceService handles business logic for devices
+type DeviceService struct {
+	repo repository.DeviceRepository
+	// reader serves list and lookup reads; it is repo unless a replica is configured
+	reader repository.DeviceReader
+
+	// incidents is set when alarms should be grouped into incidents
+	incidents      repository.IncidentRepository
+	incidentWindow time.Duration
+
+	// staleAfter marks devices last seen longer ago than this as stale; zero never does
+	staleAfter time.Duration
+	now        func() time.Time
+
+	// health configures GetDeviceHealth
+	health HealthPolicy
+	// debounce delays devices going offline until they have been quiet for a while
+	debounce OnlineDebounce
+	// normalizeNames normalizes device names before they are stored or searched for
+	normalizeNames bool
+	// lockTypes refuses changes to the type of existing devices
+	lockTypes bool
+	// alwaysWrite writes updates that change nothing instead of skipping them
+	alwaysWrite bool
+	// stats caches GetDeviceStats when set
+	stats *DeviceStatsCache
+	// alarmLevels are the alarm levels in use; nil holds the built-in levels
+	alarmLevels *models.AlarmLevels
+	// reconnector reopens the database behind the repositories when set
+	reconnector Reconnector
+	// reasons is the catalog alarm reason codes are resolved against; strictReasons requires
+	// every alarm to give a code
+	reasons       repository.AlarmReasonRepository
+	strictReasons bool
+}
+
+// Option configures optional DeviceService behaviour
+type Option func(*DeviceService)
+
+// WithIncidentGrouping attaches triggered alarms to incidents of the same level opened within window
+func WithIncidentGrouping(incidents repository.IncidentRepository, window time.Duration) Option {
+	return func(s *DeviceService) {
+		s.incidents = incidents
+		s.incidentWindow = window
+	}
+}
+
+// WithReader serves device reads from reader, typically a read replica, instead of the primary.
+// Existence checks that guard writes still use the primary so replica lag cannot hide a device.
+func WithReader(reader repository.DeviceReader) Option {
+	return func(s *DeviceService) {
+		s.reader = reader
+	}
+}
+
+// WithStaleThreshold flags devices that have not been seen for longer than threshold as stale
+// whenever they are read. Nothing is stored; the flag is recomputed on every read.
+func WithStaleThreshold(threshold time.Duration) Option {
+	return func(s *DeviceService) {
+		s.staleAfter = threshold
+	}
+}
+
+// WithNameNormalization trims device names and collapses their internal whitespace before they
+// are stored, and does the same to names searched for. Name matching is already case-insensitive.
+func WithNameNormalization() Option {
+	return func(s *DeviceService) {
+		s.normalizeNames = true
+	}
+}
+
+// WithDeviceTypeLock refuses to change the type of an existing device, through an update or an
+// applied manifest, with ErrDeviceTypeLocked. Devices are still created with any allowed type.
+func WithDeviceTypeLock() Option {
+	return func(s *DeviceService) {
+		s.lockTypes = true
+	}
+}
+
+// WithAlwaysWriteUpdates writes every update, even one that restates a device's current values,
+// bumping its updated_at and version and adding to the change feed as any other
+func WithAlwaysWriteUpdates() Option {
+	return func(s *DeviceService) {
+		s.alwaysWrite = true
+	}
+}
+
+// NewDeviceService creates a new DeviceService
+func NewDeviceService(repo repository.DeviceRepository, opts ...Option) *DeviceService {
+	s := &DeviceService{repo: repo, reader: repo, now: time.Now, health: DefaultHealthPolicy}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// CreateDevice creates a new device and returns it as stored
+func (s *DeviceService) CreateDevice(device *models.DeviceCreate) (*models.Device, error) {
+	defer s.invalidateStats()
+	device.Name = s.normalizeName(device.Name)
+	return s.repo.Create(device)
+}
+
+// ImportDevices creates a batch of already validated devices in one transaction
+func (s *DeviceService) ImportDevices(devices []*models.DeviceCreate) error {
+	defer s.invalidateStats()
+	for _, device := range devices {
+		device.Name = s.normalizeName(device.Name)
+	}
+	return s.repo.CreateBatch(devices)
+}
+
+// normalizeName normalizes name when name normalization is on
+func (s *DeviceService) normalizeName(name string) string {
+	if !s.normalizeNames {
+		return name
+	}
+	return validation.NormalizeDeviceName(name)
+}
+
+// normalizeSearch normalizes the names searched for by opts, leaving the caller's options untouched
+func (s *DeviceService) normalizeSearch(opts *models.DeviceListOptions) *models.DeviceListOptions {
+	if !s.normalizeNames || opts == nil || len(opts.Names) == 0 {
+		return opts
+	}
+	normalized := *opts
+	normalized.Names = make([]string, len(opts.Names))
+	for i, name := range opts.Names {
+		normalized.Names[i] = validation.NormalizeDeviceName(name)
+	}
+	return &normalized
+}
+
+// GetDeviceByID retrieves a device by its ID
+func (s *DeviceService) GetDeviceByID(id int64) (*models.Device, error) {
+	device, err := s.reader.GetByID(id)
+	if err != nil || device == nil {
+		return device, err
+	}
+	s.markStale(device)
+
+	return device, nil
+}
+
+// GetDevicesByIDs retrieves the devices with the given IDs in the order given, leaving out IDs
+// with no device
+func (s *DeviceService) GetDevicesByIDs(ids []int64) ([]*models.Device, error) {
+	devices, err := s.reader.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		s.markStale(device)
+	}
+
+	return devices, nil
+}
+
+// GetRawDevice reads a device's row as stored, for diagnostics, or nil when there is none. With
+// primary it is read from the primary database even when reads are served by a replica.
+func (s *DeviceService) GetRawDevice(id int64, primary bool) (*models.RawDeviceRow, error) {
+	if primary {
+		return s.repo.GetRawByID(id)
+	}
+	return s.reader.GetRawByID(id)
+}
+
+// GetDeviceByName retrieves the device of an owner with the given name, or nil when there is none
+func (s *DeviceService) GetDeviceByName(owner, name string) (*models.Device, error) {
+	device, err := s.reader.GetByOwnerAndName(owner, s.normalizeName(name))
+	if err != nil || device == nil {
+		return device, err
+	}
+	s.markStale(device)
+
+	return device, nil
+}
+
+// GetDeviceWithAlarmCount retrieves a device by ID along with its number of alarms
+func (s *DeviceService) GetDeviceWithAlarmCount(id int64) (*models.Device, error) {
+	device, err := s.reader.GetByIDWithAlarmCount(id)
+	if err != nil || device == nil {
+		return device, err
+	}
+	s.markStale(device)
+
+	return device, nil
+}
+
+// GetAllDevices retrieves all devices
+func (s *DeviceService) GetAllDevices() ([]*models.Device, error) {
+	devices, err := s.reader.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	s.markStale(devices...)
+
+	return devices, nil
+}
+
+// ListDevices retrieves a page of devices
+func (s *DeviceService) ListDevices(opts *models.DeviceListOptions) ([]*models.Device, error) {
+	devices, err := s.reader.List(s.normalizeSearch(opts))
+	if err != nil {
+		return nil, err
+	}
+	s.markStale(devices...)
+
+	return devices, nil
+}
+
+// FuzzySearchDevices returns a page of devices whose names resemble any of opts.Names, most similar
+// first, tolerating typos. Similarity is computed here over every device matching the other
+// filters, so it is slower than the exact name filter of ListDevices; sort options are ignored.
+func (s *DeviceService) FuzzySearchDevices(ctx context.Context, opts *models.DeviceListOptions) ([]*models.Device, error) {
+	type match struct {
+		device         *models.Device
+		partial, whole float64
+	}
+
+	opts = s.normalizeSearch(opts)
+	candidates := *opts
+	candidates.Names = nil
+	// Ranking compares names, so they are loaded whichever fields were asked for
+	if len(opts.Fields) > 0 && !slices.Contains(opts.Fields, "name") {
+		candidates.Fields = append(slices.Clip(opts.Fields), "name")
+	}
+
+	var matches []match
+	err := s.reader.EachDevice(ctx, &candidates, func(device *models.Device) error {
+		var partial, whole float64
+		for _, name := range opts.Names {
+			p, w := nameSimilarity(name, device.Name)
+			if p > partial || (p == partial && w > whole) {
+				partial, whole = p, w
+			}
+		}
+		if partial >= fuzzyMinSimilarity {
+			matches = append(matches, match{device: device, partial: partial, whole: whole})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].partial != matches[j].partial {
+			return matches[i].partial > matches[j].partial
+		}
+		if matches[i].whole != matches[j].whole {
+			return matches[i].whole > matches[j].whole
+		}
+		return matches[i].device.ID < matches[j].device.ID
+	})
+
+	devices := []*models.Device{}
+	for i := opts.Offset; i < len(matches) && len(devices) < opts.Limit; i++ {
+		devices = append(devices, matches[i].device)
+	}
+	s.markStale(devices...)
+
+	return devices, nil
+}
+
+// GetDevicesLastModified returns when any device was last created, updated or deleted
+func (s *DeviceService) GetDevicesLastModified() (time.Time, error) {
+	return s.reader.LastModified()
+}
+
+// StreamDevices calls fn for every device matching opts without loading the whole list
+func (s *DeviceService) StreamDevices(ctx context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	return s.reader.EachDevice(ctx, s.normalizeSearch(opts), func(device *models.Device) error {
+		s.markStale(device)
+		return fn(device)
+	})
+}
+
+// markStale sets the computed Stale flag on devices read from the repository
+func (s *DeviceService) markStale(devices ...*models.Device) {
+	if s.staleAfter <= 0 {
+		return
+	}
+
+	cutoff := s.now().Add(-s.staleAfter)
+	for _, device := range devices {
+		device.Stale = !device.LastSeenAt.IsZero() && device.LastSeenAt.Before(cutoff)
+	}
+}
+
+// UpdateDevice updates a device, reporting whether it changed. An update that would leave every
+// field as it is, such as one sent again by a client retrying, is not written: the device's
+// updated_at and version stay as they are and nothing reaches the change feed.
+func (s *DeviceService) UpdateDevice(id int64, device *models.DeviceUpdate) (bool, error) {
+	if device.Name != nil {
+		name := s.normalizeName(*device.Name)
+		device.Name = &name
+	}
+
+	if s.lockTypes {
+		current, err := s.repo.GetByID(id)
+		if err != nil {
+			return false, err
+		}
+		if current == nil {
+			return false, fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+		}
+		// Restating the current type is not a change
+		if device.DeviceType != nil && current.DeviceType != *device.DeviceType {
+			return false, fmt.Errorf("%w: device %d is a %s", models.ErrDeviceTypeLocked, id, current.DeviceType)
+		}
+	} else if err := s.ensureExists(id); err != nil {
+		return false, err
+	}
+
+	// The repository compares the update with the device in the same transaction as the write
+	changed, err := s.repo.Update(id, device, !s.alwaysWrite)
+	if changed {
+		s.invalidateStats()
+	}
+	return changed, err
+}
+
+// ResetDevice wipes a device's mutable state for reprovisioning, keeping its identity, and
+// returns the reset device
+func (s *DeviceService) ResetDevice(id int64) (*models.Device, error) {
+	defer s.invalidateStats()
+	device, err := s.repo.Reset(id)
+	if err != nil {
+		return nil, err
+	}
+	s.markStale(device)
+
+	return device, nil
+}
+
+// DeleteDevice deletes a device, dealing with its children as children says
+func (s *DeviceService) DeleteDevice(id int64, children models.ChildPolicy) error {
+	defer s.invalidateStats()
+	if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(id, children)
+}
+
+// GetRecentDevices returns up to limit devices, newest change first
+func (s *DeviceService) GetRecentDevices(limit int) ([]*models.Device, error) {
+	return s.reader.ListRecentlyUpdated(limit)
+}
+
+// GetChildren returns a page of the devices whose parent is the given device
+func (s *DeviceService) GetChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	if err := s.ensureExists(id); err != nil {
+		return nil, err
+	}
+
+	return s.reader.ListChildren(id, limit, offset)
+}
+
+// TriggerAlarm triggers an alarm on a device
+func (s *DeviceService) TriggerAlarm(id int64, alarm *models.AlarmRequest) error {
+	_, err := s.TriggerAlarmIdempotent(id, alarm)
+	return err
+}
+
+// TriggerAlarmIdempotent triggers an alarm on a device like TriggerAlarm. When the request repeats
+// a client event id the device has already recorded, nothing is recorded and the alarm first
+// recorded for it is returned; otherwise the returned alarm is nil. A reason code is recorded with
+// the catalog's text in place of the request's reason.
+func (s *DeviceService) TriggerAlarmIdempotent(id int64, alarm *models.AlarmRequest) (*models.AlarmRecord, error) {
+	defer s.invalidateStats()
+	// First check if device exists
+	if err := s.ensureExists(id); err != nil {
+		return nil, err
+	}
+
+	// A reason code stands for the catalog's wording, whatever the request says
+	reason, reasonCode, err := s.resolveReason(alarm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Format reason with alarm level and timestamp
+	formattedReason := fmt.Sprintf("[%s] %s", alarm.Level, reason)
+
+	// Trigger the alarm, recording the actor alongside the last alarm fields. An event id makes a
+	// retried request a no-op that succeeds like the original; a client event id, kept with the
+	// alarm, does so for good and takes precedence.
+	var suppressed, duplicate bool
+	switch {
+	case alarm.ClientEventID != "":
+		var record *models.AlarmRecord
+		record, duplicate, err = s.repo.TriggerAlarmForClientEvent(id, alarm.ClientEventID, alarm.Level, formattedReason, alarm.TriggeredBy, reasonCode)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate {
+			return record, nil
+		}
+		suppressed = record.Suppressed
+	case alarm.EventID != "":
+		suppressed, duplicate, err = s.repo.TriggerAlarmOnce(id, alarm.EventID, alarm.Level, formattedReason, alarm.TriggeredBy, reasonCode)
+	default:
+		suppressed, err = s.repo.TriggerAlarm(id, alarm.Level, formattedReason, alarm.TriggeredBy, reasonCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if duplicate {
+		return nil, nil
+	}
+
+	// Alarms suppressed by maintenance are recorded on the device but never open incidents
+	if s.incidents != nil && !suppressed {
+		if _, err := s.incidents.AttachAlarm(id, alarm.Level, formattedReason, alarm.TriggeredBy, s.incidentWindow); err != nil {
+			return nil, fmt.Errorf("failed to attach alarm to incident: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// TriggerAlarmByType triggers the same alarm on every device of a type at once
+func (s *DeviceService) TriggerAlarmByType(deviceType models.DeviceType, alarm *models.AlarmRequest) (*models.TypeAlarmResult, error) {
+	defer s.invalidateStats()
+	reason, reasonCode, err := s.resolveReason(alarm)
+	if err != nil {
+		return nil, err
+	}
+	formattedReason := fmt.Sprintf("[%s] %s", alarm.Level, reason)
+
+	triggered, err := s.repo.TriggerAlarmByType(deviceType, alarm.Level, formattedReason, alarm.TriggeredBy, reasonCode)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TypeAlarmResult{DeviceType: deviceType, Triggered: len(triggered)}
+	for _, t := range triggered {
+		if t.Suppressed {
+			result.Suppressed++
+			continue
+		}
+		if s.incidents != nil {
+			if _, err := s.incidents.AttachAlarm(t.DeviceID, alarm.Level, formattedReason, alarm.TriggeredBy, s.incidentWindow); err != nil {
+				return nil, fmt.Errorf("failed to attach alarm to incident: %w", err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ClearAlarm marks the active alarm on a device as cleared
+func (s *DeviceService) ClearAlarm(id int64) error {
+	defer s.invalidateStats()
+	if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	return s.repo.ClearAlarm(id)
+}
+
+// AcknowledgeAlarm marks the active alarm on a device as seen, which stops it escalating.
+// Acknowledging an alarm twice is not an error; acknowledging a device without an active alarm is.
+func (s *DeviceService) AcknowledgeAlarm(id int64) error {
+	acknowledged, err := s.repo.AcknowledgeAlarm(id, s.now())
+	if err != nil || acknowledged {
+		return err
+	}
+
+	device, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+	}
+	if !device.AlarmActive {
+		return fmt.Errorf("%w with ID: %d", models.ErrNoActiveAlarm, id)
+	}
+
+	return nil
+}
+
+// SetMaintenance turns maintenance mode on or off for a device
+func (s *DeviceService) SetMaintenance(id int64, req *models.MaintenanceRequest) error {
+	if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	var until time.Time
+	if req.Until != nil {
+		until = *req.Until
+	}
+
+	return s.repo.SetMaintenance(id, *req.Enabled, until)
+}
+
+// SetArchived archives or unarchives a device. An archived device keeps its alarm history and
+// can still be read by id, but is left out of default lists, stats and background processing,
+// and its heartbeats and alarms are refused with ErrDeviceArchived.
+func (s *DeviceService) SetArchived(id int64, archived bool) error {
+	defer s.invalidateStats()
+	if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	return s.repo.SetArchived(id, archived)
+}
+
+// GetOwners returns the owners devices belong to, each once and in order
+func (s *DeviceService) GetOwners(opts *models.OwnerListOptions) ([]string, error) {
+	return s.reader.GetDistinctOwners(opts)
+}
+
+// ownerSuggestions is how many similar owners CheckOwner suggests for an unknown one
+const ownerSuggestions = 3
+
+// CheckOwner reports whether any device belongs to owner and, when none does, suggests up to a
+// few owners that do: those equal to it ignoring case first, then those sharing its first
+// characters. It reads the primary, since it guards writes.
+func (s *DeviceService) CheckOwner(owner string) (bool, []string, error) {
+	matches, err := s.repo.MatchOwners(owner, ownerSuggestions+1)
+	if err != nil {
+		return false, nil, err
+	}
+	if len(matches) > 0 && matches[0] == owner {
+		return true, nil, nil
+	}
+	if len(matches) > ownerSuggestions {
+		matches = matches[:ownerSuggestions]
+	}
+
+	return false, matches, nil
+}
+
+// RenameOwner renames an owner across every device and the alarms they name as actor, in one
+// transaction, and records a single owner change in the change feed. It returns ErrOwnerNotFound
+// when no device belongs to the old name, and ErrOwnerExists when one belongs to the new name,
+// unless merge is set to combine the two owners.
+func (s *DeviceService) RenameOwner(req *models.OwnerRename, merge bool) (*models.OwnerRenameResult, error) {
+	rows, err := s.repo.RenameOwner(req.From, req.To, merge)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateStats()
+
+	return &models.OwnerRenameResult{From: req.From, To: req.To, Rows: rows}, nil
+}
+
+// StartFirmwareUpdate records a firmware update pushed to a device as pending
+func (s *DeviceService) StartFirmwareUpdate(id int64, req *models.FirmwareUpdateRequest) error {
+	if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	return s.repo.SetFirmwareTarget(id, req.Version)
+}
+
+// ReportFirmwareUpdate records how a device's pending firmware update ended
+func (s *DeviceService) ReportFirmwareUpdate(id int64, report *models.FirmwareReport) error {
+	if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	completed, err := s.repo.CompleteFirmwareUpdate(id, report.Status)
+	if err != nil {
+		return err
+	}
+	if !completed {
+		return fmt.Errorf("%w for device with ID: %d", models.ErrNoFirmwareUpdate, id)
+	}
+
+	return nil
+}
+
+// GetActiveAlarms retrieves a page of the devices that currently have an active alarm
+func (s *DeviceService) GetActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	filter.LevelOrder = s.alarmLevels.IDs()
+	devices, err := s.reader.ListActiveAlarms(filter)
+	if err != nil {
+		return nil, err
+	}
+	s.markStale(devices...)
+
+	return devices, nil
+}
+
+// CountDevices counts the devices matching the filters of opts
+func (s *DeviceService) CountDevices(opts *models.DeviceListOptions) (int, error) {
+	return s.reader.Count(s.normalizeSearch(opts))
+}
+
+// CountOnlineDevices counts the devices online and offline, leaving out archived ones
+func (s *DeviceService) CountOnlineDevices() (*models.DeviceCounts, error) {
+	return s.reader.CountDevices()
+}
+
+// CountAlarms counts the alarm history matching filter, across all devices unless it names one
+func (s *DeviceService) CountAlarms(filter *models.AlarmHistoryFilter) (int, error) {
+	if filter.DeviceID != 0 {
+		if err := s.ensureExists(filter.DeviceID); err != nil {
+			return 0, err
+		}
+	}
+
+	return s.reader.CountAlarmHistory(filter)
+}
+
+// CountAlarmsByReasonCode counts the alarm history matching filter per reason code, as CountAlarms
+// does, with the alarms given without a code counted under ""
+func (s *DeviceService) CountAlarmsByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	if filter.DeviceID != 0 {
+		if err := s.ensureExists(filter.DeviceID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.reader.CountAlarmHistoryByReasonCode(filter)
+}
+
+// GetAlarmHistory retrieves a page of a device's alarm history
+func (s *DeviceService) GetAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	if err := s.ensureExists(filter.DeviceID); err != nil {
+		return nil, err
+	}
+
+	return s.reader.ListAlarmHistory(filter)
+}
+
+// ExportAlarmHistory calls fn for every alarm of filter.DeviceID matching filter, oldest first,
+// without loading the whole history. fn is not called at all when the device does not exist.
+func (s *DeviceService) ExportAlarmHistory(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	if err := s.ensureExists(filter.DeviceID); err != nil {
+		return err
+	}
+
+	return s.reader.EachAlarmRecord(ctx, filter, fn)
+}
+
+// GetDeviceStateCounts counts the devices of every known type by connection and alarm state.
+// Types are in the order of models.GetAllDeviceTypes, including those with no devices, so
+// scrapers see a stable set of series.
+func (s *DeviceService) GetDeviceStateCounts() ([]*models.DeviceTypeCounts, error) {
+	return deviceStateCounts(s.reader)
+}
+
+// deviceStateCounts counts the devices of every known type by state, including types with none
+func deviceStateCounts(reader repository.DeviceReader) ([]*models.DeviceTypeCounts, error) {
+	counts, err := reader.CountDevicesByState()
+	if err != nil {
+		return nil, err
+	}
+
+	byType := make(map[models.DeviceType]*models.DeviceTypeCounts, len(counts))
+	for _, typeCounts := range counts {
+		byType[typeCounts.DeviceType] = typeCounts
+	}
+
+	allTypes := models.GetAllDeviceTypes()
+	result := make([]*models.DeviceTypeCounts, 0, len(allTypes))
+	for _, info := range allTypes {
+		typeCounts, ok := byType[models.DeviceType(info.ID)]
+		if !ok {
+			typeCounts = &models.DeviceTypeCounts{DeviceType: models.DeviceType(info.ID)}
+		}
+		result = append(result, typeCounts)
+	}
+
+	return result, nil
+}
+
+// GetDashboard summarises device counts and the most recent alarms across all devices
+func (s *DeviceService) GetDashboard() (*models.Dashboard, error) {
+	counts, err := s.reader.CountDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	byType, err := s.reader.CountDevicesByType()
+	if err != nil {
+		return nil, err
+	}
+	// Every known type is listed so clients can render a fixed set of tiles
+	for _, info := range models.GetAllDeviceTypes() {
+		if _, ok := byType[models.DeviceType(info.ID)]; !ok {
+			byType[models.DeviceType(info.ID)] = 0
+		}
+	}
+
+	// Automatic clears are history bookkeeping, not alarms
+	alarms, err := s.reader.ListAlarmHistory(&models.AlarmHistoryFilter{Events: []string{models.AlarmEventTriggered}, Limit: dashboardRecentAlarms})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Dashboard{
+		TotalDevices:  counts.Total,
+		DevicesByType: byType,
+		Online:        counts.Online,
+		Offline:       counts.Offline,
+		RecentAlarms:  alarms,
+	}, nil
+}
+
+// VacuumDatabase reclaims the space deleted rows leave in the database file
+func (s *DeviceService) VacuumDatabase() (*models.VacuumResult, error) {
+	return s.repo.Vacuum()
+}
+
+// PingDatabase checks that the primary database answers, giving up once ctx is done
+func (s *DeviceService) PingDatabase(ctx context.Context) error {
+	return s.repo.Ping(ctx)
+}
+
+// Reconnector reopens the connection pool the repositories run on, such as database.Manager
+type Reconnector interface {
+	Reconnect() error
+	Reconnecting() bool
+}
+
+// WithReconnector lets ReconnectDatabase reopen the database through r, and reports its state
+// through DatabaseReconnecting
+func WithReconnector(r Reconnector) Option {
+	return func(s *DeviceService) {
+		s.reconnector = r
+	}
+}
+
+// ReconnectDatabase closes the database's connection pool and opens a new one, as after its file
+// was restored from a backup. Without WithReconnector it returns ErrReconnectUnsupported.
+func (s *DeviceService) ReconnectDatabase() error {
+	if s.reconnector == nil {
+		return models.ErrReconnectUnsupported
+	}
+	return s.reconnector.Reconnect()
+}
+
+// DatabaseReconnecting reports whether the database's connection pool is broken and waiting to
+// be reopened
+func (s *DeviceService) DatabaseReconnecting() bool {
+	return s.reconnector != nil && s.reconnector.Reconnecting()
+}
+
+// ensureExists returns ErrDeviceNotFound when no device has the given ID
+func (s *DeviceService) ensureExists(id int64) error {
+	return ensureDeviceExists(s.repo, id)
+}
+
+// ensureDeviceExists returns ErrDeviceNotFound when reader has no device with the given ID
+func ensureDeviceExists(reader repository.DeviceReader, id int64) error {
+	exists, err := reader.Exists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,1050 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// MockDeviceRepo is a mock implementation of repository.DeviceRepository
+type MockDeviceRepo struct {
+	getByIDCalled       bool
+	getByIDInput        int64
+	getByIDOutput       *models.Device
+	getByIDError        error
+	existsCalled        bool
+	existsInput         int64
+	existsOutput        bool
+	existsError         error
+	triggerAlarmCalled  bool
+	triggerAlarmID      int64
+	triggerAlarmLevel   string
+	triggerAlarmReason  string
+	triggerAlarmActor   string
+	triggerAlarmCode    string
+	triggerAlarmError   error
+	suppressed          bool
+	clearExpiredCalls   map[string]time.Time
+	clearExpiredSkipAck bool
+	clearExpiredOutput  int64
+	maintenanceEndedAt  time.Time
+	setMaintenanceID    int64
+	setMaintenanceOn    bool
+	setMaintenanceEnd   time.Time
+	setArchivedID       int64
+	setArchivedTo       bool
+	firmwareTarget      string
+	firmwareStatus      string
+	firmwarePending     bool
+	deviceCounts        *models.DeviceCounts
+	typeCounts          map[models.DeviceType]int
+	historyFilter       *models.AlarmHistoryFilter
+	historyOutput       []*models.AlarmRecord
+	activeFilter        *models.ActiveAlarmFilter
+	levelsInUse         []string
+	levelsSince         time.Time
+	matchedOwners       []string
+	typeAlarmType       models.DeviceType
+	typeAlarmReason     string
+	typeAlarmOutput     []*models.TriggeredAlarm
+	devices             []*models.Device
+	stateCounts         []*models.DeviceTypeCounts
+	eachDeviceOpts      *models.DeviceListOptions
+	seenID              int64
+	seenAt              time.Time
+
+	// triggerAlarmEventID is the event id TriggerAlarmOnce was called with; processedEvents are
+	// the ids it treats as duplicates
+	triggerAlarmEventID string
+	processedEvents     map[string]bool
+	eventsPurgedBefore  time.Time
+	// clientAlarms are the alarms recorded by client event id, which TriggerAlarmForClientEvent
+	// answers repeats with
+	clientAlarms map[string]*models.AlarmRecord
+	// historyPrunedAt and historyRetention are what PruneAlarmHistory was called with
+	historyPrunedAt  time.Time
+	historyRetention time.Duration
+
+	// cameOnline is what RecordSeenOnline reports; markedOffline records the cutoff
+	// MarkUnseenOffline was called with for each device type
+	cameOnline    bool
+	markedOffline map[models.DeviceType]time.Time
+	updateID      int64
+	resetID       int64
+	updateInput   *models.DeviceUpdate
+	// setOnline records the state SetOnlineIfChanged was called with; onlineChanged is its report
+	setOnline     *bool
+	onlineChanged bool
+
+	// acknowledged is what AcknowledgeAlarm reports; scheduled, dueDevices and escalations back
+	// the escalation methods, with escalated holding the reasons of escalated alarms by device
+	acknowledged bool
+	scheduled    map[string]time.Duration
+	dueDevices   []*models.Device
+	escalations  map[int64]bool
+	escalated    map[int64]string
+	pruned       bool
+
+	// appliedCreates and appliedChanges record what ApplyManifest was called with
+	appliedCreates []*models.DeviceCreate
+	appliedChanges []*models.DeviceDrift
+}
+
+// Implement the DeviceRepository interface methods
+func (m *MockDeviceRepo) GetByID(id int64) (*models.Device, error) {
+	m.getByIDCalled = true
+	m.getByIDInput = id
+	return m.getByIDOutput, m.getByIDError
+}
+
+func (m *MockDeviceRepo) GetByIDs(ids []int64) ([]*models.Device, error) {
+	return m.devices, nil
+}
+
+func (m *MockDeviceRepo) ApplyManifest(creates []*models.DeviceCreate, changes []*models.DeviceDrift) error {
+	m.appliedCreates, m.appliedChanges = creates, changes
+	return nil
+}
+
+func (m *MockDeviceRepo) GetRawByID(id int64) (*models.RawDeviceRow, error) {
+	return nil, nil
+}
+
+func (m *MockDeviceRepo) GetByOwnerAndName(owner, name string) (*models.Device, error) {
+	return m.getByIDOutput, m.getByIDError
+}
+
+func (m *MockDeviceRepo) GetByIDWithAlarmCount(id int64) (*models.Device, error) {
+	m.getByIDCalled = true
+	m.getByIDInput = id
+	return m.getByIDOutput, m.getByIDError
+}
+
+func (m *MockDeviceRepo) Exists(id int64) (bool, error) {
+	m.existsCalled = true
+	m.existsInput = id
+	return m.existsOutput, m.existsError
+}
+
+func (m *MockDeviceRepo) TriggerAlarm(id int64, level, reason, triggeredBy, reasonCode string) (bool, error) {
+	m.triggerAlarmCalled = true
+	m.triggerAlarmID = id
+	m.triggerAlarmLevel = level
+	m.triggerAlarmReason = reason
+	m.triggerAlarmActor = triggeredBy
+	m.triggerAlarmCode = reasonCode
+	return m.suppressed, m.triggerAlarmError
+}
+
+func (m *MockDeviceRepo) TriggerAlarmOnce(id int64, eventID, level, reason, triggeredBy, reasonCode string) (bool, bool, error) {
+	m.triggerAlarmEventID = eventID
+	if m.processedEvents[eventID] {
+		return m.suppressed, true, m.triggerAlarmError
+	}
+	suppressed, err := m.TriggerAlarm(id, level, reason, triggeredBy, reasonCode)
+	return suppressed, false, err
+}
+
+func (m *MockDeviceRepo) TriggerAlarmForClientEvent(id int64, clientEventID, level, reason, triggeredBy, reasonCode string) (*models.AlarmRecord, bool, error) {
+	if record, ok := m.clientAlarms[clientEventID]; ok {
+		return record, true, nil
+	}
+	suppressed, err := m.TriggerAlarm(id, level, reason, triggeredBy, reasonCode)
+	if err != nil {
+		return nil, false, err
+	}
+	record := &models.AlarmRecord{ID: int64(len(m.clientAlarms) + 1), DeviceID: id, Level: level, Reason: reason,
+		TriggeredBy: triggeredBy, Suppressed: suppressed, ClientEventID: clientEventID, ReasonCode: reasonCode}
+	if m.clientAlarms == nil {
+		m.clientAlarms = make(map[string]*models.AlarmRecord)
+	}
+	m.clientAlarms[clientEventID] = record
+	return record, false, nil
+}
+
+func (m *MockDeviceRepo) PurgeAlarmEvents(before time.Time) (int64, error) {
+	m.eventsPurgedBefore = before
+	return 0, nil
+}
+
+func (m *MockDeviceRepo) PruneAlarmHistory(now time.Time, retention time.Duration) (int64, error) {
+	m.historyPrunedAt, m.historyRetention = now, retention
+	return 0, nil
+}
+
+func (m *MockDeviceRepo) TriggerAlarmByType(deviceType models.DeviceType, level, reason, triggeredBy, reasonCode string) ([]*models.TriggeredAlarm, error) {
+	m.typeAlarmType = deviceType
+	m.typeAlarmReason = reason
+	m.triggerAlarmCode = reasonCode
+	return m.typeAlarmOutput, m.triggerAlarmError
+}
+
+// Stub implementations of other repository methods
+func (m *MockDeviceRepo) Create(*models.DeviceCreate) (*models.Device, error) { return nil, nil }
+func (m *MockDeviceRepo) CreateBatch([]*models.DeviceCreate) error            { return nil }
+func (m *MockDeviceRepo) GetAll() ([]*models.Device, error)                   { return nil, nil }
+func (m *MockDeviceRepo) List(*models.DeviceListOptions) ([]*models.Device, error) {
+	return nil, nil
+}
+func (m *MockDeviceRepo) EachDevice(_ context.Context, opts *models.DeviceListOptions, fn func(*models.Device) error) error {
+	m.eachDeviceOpts = opts
+	for _, device := range m.devices {
+		if err := fn(device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *MockDeviceRepo) StreamAll(context.Context) (<-chan *models.Device, <-chan error) {
+	devices := make(chan *models.Device, len(m.devices))
+	for _, device := range m.devices {
+		devices <- device
+	}
+	close(devices)
+	errs := make(chan error)
+	close(errs)
+	return devices, errs
+}
+func (m *MockDeviceRepo) Update(id int64, device *models.DeviceUpdate, skipUnchanged bool) (bool, error) {
+	if skipUnchanged && m.getByIDOutput != nil && device.ChangesNothing(m.getByIDOutput) {
+		return false, nil
+	}
+	m.updateID, m.updateInput = id, device
+	return true, nil
+}
+func (m *MockDeviceRepo) Delete(int64, models.ChildPolicy) error { return nil }
+func (m *MockDeviceRepo) ClearAlarm(int64) error                 { return nil }
+func (m *MockDeviceRepo) Reset(id int64) (*models.Device, error) {
+	m.resetID = id
+	return testutil.NewDevice().WithID(id).Build(), nil
+}
+func (m *MockDeviceRepo) ClearExpiredAlarms(level string, before time.Time, skipAcknowledged bool) (int64, error) {
+	if m.clearExpiredCalls == nil {
+		m.clearExpiredCalls = make(map[string]time.Time)
+	}
+	m.clearExpiredCalls[level] = before
+	m.clearExpiredSkipAck = skipAcknowledged
+	return m.clearExpiredOutput, nil
+}
+func (m *MockDeviceRepo) SetMaintenance(id int64, enabled bool, until time.Time) error {
+	m.setMaintenanceID = id
+	m.setMaintenanceOn = enabled
+	m.setMaintenanceEnd = until
+	return nil
+}
+func (m *MockDeviceRepo) SetArchived(id int64, archived bool) error {
+	m.setArchivedID = id
+	m.setArchivedTo = archived
+	return nil
+}
+func (m *MockDeviceRepo) RenameOwner(from, to string, merge bool) (map[string]int64, error) {
+	return nil, nil
+}
+func (m *MockDeviceRepo) EndExpiredMaintenance(now time.Time) (int64, error) {
+	m.maintenanceEndedAt = now
+	return 0, nil
+}
+func (m *MockDeviceRepo) SetFirmwareTarget(id int64, version string) error {
+	m.firmwareTarget = version
+	return nil
+}
+func (m *MockDeviceRepo) CompleteFirmwareUpdate(id int64, status string) (bool, error) {
+	if !m.firmwarePending {
+		return false, nil
+	}
+	m.firmwareStatus = status
+	return true, nil
+}
+func (m *MockDeviceRepo) ListActiveAlarms(filter *models.ActiveAlarmFilter) ([]*models.Device, error) {
+	m.activeFilter = filter
+	return nil, nil
+}
+func (m *MockDeviceRepo) ListAlarmHistory(filter *models.AlarmHistoryFilter) ([]*models.AlarmRecord, error) {
+	m.historyFilter = filter
+	return m.historyOutput, nil
+}
+func (m *MockDeviceRepo) CountAlarmHistory(filter *models.AlarmHistoryFilter) (int, error) {
+	m.historyFilter = filter
+	return len(m.historyOutput), nil
+}
+func (m *MockDeviceRepo) CountAlarmHistoryByReasonCode(filter *models.AlarmHistoryFilter) (map[string]int, error) {
+	m.historyFilter = filter
+	counts := make(map[string]int)
+	for _, record := range m.historyOutput {
+		counts[record.ReasonCode]++
+	}
+	return counts, nil
+}
+func (m *MockDeviceRepo) EachAlarmRecord(ctx context.Context, filter *models.AlarmHistoryFilter, fn func(*models.AlarmRecord) error) error {
+	m.historyFilter = filter
+	for _, record := range m.historyOutput {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *MockDeviceRepo) ListAlarmLevelsInUse(since time.Time) ([]string, error) {
+	m.levelsSince = since
+	return m.levelsInUse, nil
+}
+func (m *MockDeviceRepo) GetDistinctOwners(opts *models.OwnerListOptions) ([]string, error) {
+	return nil, nil
+}
+func (m *MockDeviceRepo) MatchOwners(owner string, limit int) ([]string, error) {
+	return m.matchedOwners, nil
+}
+func (m *MockDeviceRepo) ListChildren(id int64, limit, offset int) ([]*models.Device, error) {
+	return nil, nil
+}
+func (m *MockDeviceRepo) ListRecentlyUpdated(limit int) ([]*models.Device, error) {
+	return nil, nil
+}
+func (m *MockDeviceRepo) Count(opts *models.DeviceListOptions) (int, error) {
+	return len(m.devices), nil
+}
+func (m *MockDeviceRepo) CountDevices() (*models.DeviceCounts, error) {
+	return m.deviceCounts, nil
+}
+func (m *MockDeviceRepo) CountDevicesByType() (map[models.DeviceType]int, error) {
+	return m.typeCounts, nil
+}
+func (m *MockDeviceRepo) CountDevicesByState() ([]*models.DeviceTypeCounts, error) {
+	return m.stateCounts, nil
+}
+func (m *MockDeviceRepo) LastModified() (time.Time, error) { return time.Time{}, nil }
+func (m *MockDeviceRepo) RecordSeen(id int64, at time.Time) error {
+	m.seenID, m.seenAt = id, at
+	return nil
+}
+
+func (m *MockDeviceRepo) AcknowledgeAlarm(id int64, at time.Time) (bool, error) {
+	return m.acknowledged, nil
+}
+
+func (m *MockDeviceRepo) ScheduleEscalations(level string, delay time.Duration) (int64, error) {
+	if m.scheduled == nil {
+		m.scheduled = make(map[string]time.Duration)
+	}
+	m.scheduled[level] = delay
+	return 0, nil
+}
+
+func (m *MockDeviceRepo) ListDueEscalations(now time.Time) ([]*models.Device, error) {
+	return m.dueDevices, nil
+}
+
+// EscalateAlarm escalates the devices listed in escalations, reporting false for any other
+func (m *MockDeviceRepo) EscalateAlarm(id int64, from, to, reason, triggeredBy string, now time.Time) (bool, error) {
+	if !m.escalations[id] {
+		return false, nil
+	}
+	if m.escalated == nil {
+		m.escalated = make(map[int64]string)
+	}
+	m.escalated[id] = reason
+	return true, nil
+}
+
+func (m *MockDeviceRepo) PruneEscalations() (int64, error) {
+	m.pruned = true
+	return 0, nil
+}
+
+func (m *MockDeviceRepo) Vacuum() (*models.VacuumResult, error) {
+	return &models.VacuumResult{}, nil
+}
+func (m *MockDeviceRepo) Ping(ctx context.Context) error { return ctx.Err() }
+
+func (m *MockDeviceRepo) RecordSeenOnline(id int64, at time.Time) (bool, error) {
+	m.seenID, m.seenAt = id, at
+	return m.cameOnline, nil
+}
+
+func (m *MockDeviceRepo) SetOnlineIfChanged(id int64, online bool) (bool, error) {
+	m.setOnline = &online
+	return m.onlineChanged, nil
+}
+
+func (m *MockDeviceRepo) MarkUnseenOffline(deviceType models.DeviceType, before time.Time) (int64, error) {
+	if m.markedOffline == nil {
+		m.markedOffline = make(map[models.DeviceType]time.Time)
+	}
+	m.markedOffline[deviceType] = before
+	return 1, nil
+}
+
+func TestTriggerAlarm(t *testing.T) {
+	tests := []struct {
+		name                     string
+		deviceID                 int64
+		alarm                    *models.AlarmRequest
+		mockExistsOutput         bool
+		mockExistsError          error
+		mockTriggerAlarmError    error
+		expectError              bool
+		expectTriggerAlarmCalled bool
+	}{
+		{
+			name:     "Successful alarm trigger",
+			deviceID: 1,
+			alarm: &models.AlarmRequest{
+				Reason: "Smoke detected",
+				Level:  "CRITICAL",
+			},
+			mockExistsOutput:         true,
+			expectError:              false,
+			expectTriggerAlarmCalled: true,
+		},
+		{
+			name:     "Alarm with actor",
+			deviceID: 1,
+			alarm: &models.AlarmRequest{
+				Reason:      "Smoke detected",
+				Level:       "CRITICAL",
+				TriggeredBy: "sensor:kitchen",
+			},
+			mockExistsOutput:         true,
+			expectError:              false,
+			expectTriggerAlarmCalled: true,
+		},
+		{
+			name:     "Device not found",
+			deviceID: 99,
+			alarm: &models.AlarmRequest{
+				Reason: "Smoke detected",
+				Level:  "CRITICAL",
+			},
+			mockExistsOutput:         false, // No device found
+			expectError:              true,
+			expectTriggerAlarmCalled: false,
+		},
+		{
+			name:     "Exists database error",
+			deviceID: 1,
+			alarm: &models.AlarmRequest{
+				Reason: "Smoke detected",
+				Level:  "CRITICAL",
+			},
+			mockExistsError:          errors.New("database error"),
+			expectError:              true,
+			expectTriggerAlarmCalled: false,
+		},
+		{
+			name:     "TriggerAlarm database error",
+			deviceID: 1,
+			alarm: &models.AlarmRequest{
+				Reason: "Smoke detected",
+				Level:  "CRITICAL",
+			},
+			mockExistsOutput:         true,
+			mockTriggerAlarmError:    errors.New("database error"),
+			expectError:              true,
+			expectTriggerAlarmCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create the mock repository
+			mockRepo := &MockDeviceRepo{
+				existsOutput:      tc.mockExistsOutput,
+				existsError:       tc.mockExistsError,
+				triggerAlarmError: tc.mockTriggerAlarmError,
+			}
+
+			// Create service with the mock repository
+			service := NewDeviceService(mockRepo)
+
+			// Call the method being tested
+			err := service.TriggerAlarm(tc.deviceID, tc.alarm)
+
+			// Check error expectations
+			if tc.expectError && err == nil {
+				t.Errorf("Expected an error but got nil")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+
+			// Check if Exists was called with correct ID
+			if !mockRepo.existsCalled {
+				t.Errorf("Expected Exists to be called")
+			}
+			if mockRepo.existsInput != tc.deviceID {
+				t.Errorf("Exists called with wrong ID, expected %d, got %d", tc.deviceID, mockRepo.existsInput)
+			}
+			if mockRepo.getByIDCalled {
+				t.Errorf("Expected GetByID not to be called")
+			}
+
+			// Check if TriggerAlarm was called when expected
+			if tc.expectTriggerAlarmCalled && !mockRepo.triggerAlarmCalled {
+				t.Errorf("Expected TriggerAlarm to be called but it wasn't")
+			}
+			if !tc.expectTriggerAlarmCalled && mockRepo.triggerAlarmCalled {
+				t.Errorf("Expected TriggerAlarm not to be called but it was")
+			}
+
+			// If TriggerAlarm was called, check the parameters
+			if mockRepo.triggerAlarmCalled {
+				if mockRepo.triggerAlarmID != tc.deviceID {
+					t.Errorf("TriggerAlarm called with wrong ID, expected %d, got %d", tc.deviceID, mockRepo.triggerAlarmID)
+				}
+
+				expectedReason := fmt.Sprintf("[%s] %s", tc.alarm.Level, tc.alarm.Reason)
+				if mockRepo.triggerAlarmReason != expectedReason {
+					t.Errorf("TriggerAlarm called with wrong reason, expected %q, got %q", expectedReason, mockRepo.triggerAlarmReason)
+				}
+
+				if mockRepo.triggerAlarmLevel != tc.alarm.Level {
+					t.Errorf("TriggerAlarm called with wrong level, expected %q, got %q", tc.alarm.Level, mockRepo.triggerAlarmLevel)
+				}
+
+				if mockRepo.triggerAlarmActor != tc.alarm.TriggeredBy {
+					t.Errorf("TriggerAlarm called with wrong actor, expected %q, got %q", tc.alarm.TriggeredBy, mockRepo.triggerAlarmActor)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteDeviceNotFound(t *testing.T) {
+	mockRepo := &MockDeviceRepo{existsOutput: false}
+	service := NewDeviceService(mockRepo)
+
+	err := service.DeleteDevice(42, models.ChildrenRefuse)
+	if !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+	}
+	if mockRepo.existsInput != 42 {
+		t.Errorf("Exists called with wrong ID, expected 42, got %d", mockRepo.existsInput)
+	}
+}
+
+func TestUpdateDeviceNotFound(t *testing.T) {
+	mockRepo := &MockDeviceRepo{existsOutput: false}
+	service := NewDeviceService(mockRepo)
+
+	_, err := service.UpdateDevice(42, &models.DeviceUpdate{})
+	if !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+	}
+}
+
+func TestUpdateDeviceTypeLock(t *testing.T) {
+	camera, lock := models.DeviceTypeCamera, models.DeviceTypeLock
+	name := "Porch"
+
+	tests := []struct {
+		name     string
+		update   *models.DeviceUpdate
+		expected error
+	}{
+		{"Type changed", &models.DeviceUpdate{DeviceType: &lock}, models.ErrDeviceTypeLocked},
+		{"Type restated", &models.DeviceUpdate{DeviceType: &camera, Name: &name}, nil},
+		{"Other fields", &models.DeviceUpdate{Name: &name}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockDeviceRepo{existsOutput: true, getByIDOutput: testutil.NewDevice().WithType(camera).Build()}
+			service := NewDeviceService(repo, WithDeviceTypeLock())
+
+			_, err := service.UpdateDevice(7, tc.update)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+			if updated := repo.updateInput != nil; updated != (tc.expected == nil) {
+				t.Errorf("Expected the update written: %t, got %t", tc.expected == nil, updated)
+			}
+		})
+	}
+
+	// Unlocked, the type changes like any other field
+	repo := &MockDeviceRepo{existsOutput: true, getByIDOutput: testutil.NewDevice().WithType(camera).Build()}
+	if _, err := NewDeviceService(repo).UpdateDevice(7, &models.DeviceUpdate{DeviceType: &lock}); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+}
+
+func TestCheckOwner(t *testing.T) {
+	tests := []struct {
+		owner       string
+		matched     []string
+		known       bool
+		suggestions []string
+	}{
+		{"alice", []string{"alice", "Alice"}, true, nil},
+		{"ALICE", []string{"alice", "Alice", "albert", "alfred"}, false, []string{"alice", "Alice", "albert"}},
+		{"zed", []string{}, false, []string{}},
+	}
+
+	for _, tc := range tests {
+		service := NewDeviceService(&MockDeviceRepo{matchedOwners: tc.matched})
+		known, suggestions, err := service.CheckOwner(tc.owner)
+		if err != nil {
+			t.Fatalf("CheckOwner failed: %v", err)
+		}
+		if known != tc.known || !slices.Equal(suggestions, tc.suggestions) {
+			t.Errorf("%s: expected known %t with %v, got %t with %v", tc.owner, tc.known, tc.suggestions, known, suggestions)
+		}
+	}
+}
+
+func TestDeviceServiceWithReader(t *testing.T) {
+	primary := &MockDeviceRepo{existsOutput: true}
+	replica := &MockDeviceRepo{getByIDOutput: testutil.NewDevice().WithName("Replica").Build()}
+	service := NewDeviceService(primary, WithReader(replica))
+
+	device, err := service.GetDeviceByID(1)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if device == nil || device.Name != "Replica" {
+		t.Errorf("Expected the read to be served by the reader, got %+v", device)
+	}
+	if primary.getByIDCalled {
+		t.Errorf("Expected GetByID not to reach the primary")
+	}
+
+	if err := service.TriggerAlarm(1, &models.AlarmRequest{Reason: "Smoke detected", Level: "CRITICAL"}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !primary.existsCalled || !primary.triggerAlarmCalled {
+		t.Errorf("Expected the write and its existence check to go to the primary")
+	}
+	if replica.existsCalled || replica.triggerAlarmCalled {
+		t.Errorf("Expected the reader not to be used for writes")
+	}
+}
+
+// MockIncidentRepo is a mock implementation of repository.IncidentRepository
+type MockIncidentRepo struct {
+	attachCalled   bool
+	attachCount    int
+	attachDeviceID int64
+	attachLevel    string
+	attachWindow   time.Duration
+}
+
+func (m *MockIncidentRepo) AttachAlarm(deviceID int64, level, reason, triggeredBy string, window time.Duration) (int64, error) {
+	m.attachCalled = true
+	m.attachCount++
+	m.attachDeviceID = deviceID
+	m.attachLevel = level
+	m.attachWindow = window
+	return 1, nil
+}
+
+func (m *MockIncidentRepo) List(*models.IncidentListOptions) ([]*models.Incident, error) {
+	return nil, nil
+}
+
+func (m *MockIncidentRepo) Resolve(int64) error { return nil }
+
+func TestTriggerAlarmIncidentGrouping(t *testing.T) {
+	alarm := &models.AlarmRequest{Reason: "Smoke detected", Level: "CRITICAL"}
+
+	t.Run("Grouping enabled", func(t *testing.T) {
+		incidents := &MockIncidentRepo{}
+		service := NewDeviceService(&MockDeviceRepo{existsOutput: true}, WithIncidentGrouping(incidents, 5*time.Minute))
+
+		if err := service.TriggerAlarm(1, alarm); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !incidents.attachCalled {
+			t.Fatalf("Expected AttachAlarm to be called")
+		}
+		if incidents.attachDeviceID != 1 || incidents.attachLevel != "CRITICAL" || incidents.attachWindow != 5*time.Minute {
+			t.Errorf("AttachAlarm called with unexpected arguments: device %d, level %q, window %s",
+				incidents.attachDeviceID, incidents.attachLevel, incidents.attachWindow)
+		}
+	})
+
+	t.Run("Alarm suppressed by maintenance", func(t *testing.T) {
+		incidents := &MockIncidentRepo{}
+		repo := &MockDeviceRepo{existsOutput: true, suppressed: true}
+		service := NewDeviceService(repo, WithIncidentGrouping(incidents, 5*time.Minute))
+
+		if err := service.TriggerAlarm(1, alarm); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !repo.triggerAlarmCalled {
+			t.Errorf("Expected the suppressed alarm to still be recorded")
+		}
+		if incidents.attachCalled {
+			t.Errorf("Expected AttachAlarm not to be called for a suppressed alarm")
+		}
+	})
+
+	t.Run("Alarm not recorded", func(t *testing.T) {
+		incidents := &MockIncidentRepo{}
+		repo := &MockDeviceRepo{existsOutput: true, triggerAlarmError: errors.New("database error")}
+		service := NewDeviceService(repo, WithIncidentGrouping(incidents, 5*time.Minute))
+
+		if err := service.TriggerAlarm(1, alarm); err == nil {
+			t.Fatalf("Expected an error but got nil")
+		}
+		if incidents.attachCalled {
+			t.Errorf("Expected AttachAlarm not to be called when the alarm was not recorded")
+		}
+	})
+
+	t.Run("Retried event", func(t *testing.T) {
+		incidents := &MockIncidentRepo{}
+		repo := &MockDeviceRepo{existsOutput: true, processedEvents: map[string]bool{"evt-1": true}}
+		service := NewDeviceService(repo, WithIncidentGrouping(incidents, 5*time.Minute))
+
+		retry := *alarm
+		retry.EventID = "evt-1"
+		if err := service.TriggerAlarm(1, &retry); err != nil {
+			t.Fatalf("Expected a retry to succeed but got: %v", err)
+		}
+		if repo.triggerAlarmEventID != "evt-1" {
+			t.Errorf("Expected the event id to be checked, got %q", repo.triggerAlarmEventID)
+		}
+		if repo.triggerAlarmCalled || incidents.attachCalled {
+			t.Errorf("Expected a retried event to record nothing")
+		}
+	})
+
+	t.Run("Repeated client event", func(t *testing.T) {
+		incidents := &MockIncidentRepo{}
+		repo := &MockDeviceRepo{existsOutput: true}
+		service := NewDeviceService(repo, WithIncidentGrouping(incidents, 5*time.Minute))
+
+		request := *alarm
+		request.ClientEventID = "3f2b8c1e-9a4d-4e6f-8b1a-2c3d4e5f6a7b"
+		existing, err := service.TriggerAlarmIdempotent(1, &request)
+		if err != nil || existing != nil {
+			t.Fatalf("Expected the first request to be recorded as new, got %+v, %v", existing, err)
+		}
+		if !incidents.attachCalled {
+			t.Errorf("Expected the new alarm attached to an incident")
+		}
+
+		incidents.attachCalled, repo.triggerAlarmCalled = false, false
+		existing, err = service.TriggerAlarmIdempotent(1, &request)
+		if err != nil || existing == nil || existing.ClientEventID != request.ClientEventID {
+			t.Fatalf("Expected the repeat answered with the first alarm, got %+v, %v", existing, err)
+		}
+		if repo.triggerAlarmCalled || incidents.attachCalled {
+			t.Errorf("Expected a repeated client event to record nothing")
+		}
+	})
+}
+
+func TestTriggerAlarmByType(t *testing.T) {
+	incidents := &MockIncidentRepo{}
+	repo := &MockDeviceRepo{typeAlarmOutput: []*models.TriggeredAlarm{
+		{DeviceID: 1},
+		{DeviceID: 2, Suppressed: true},
+		{DeviceID: 3},
+	}}
+	service := NewDeviceService(repo, WithIncidentGrouping(incidents, 5*time.Minute))
+
+	result, err := service.TriggerAlarmByType(models.DeviceTypeSmokeDetector, &models.AlarmRequest{Reason: "Fire", Level: "CRITICAL"})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.DeviceType != models.DeviceTypeSmokeDetector || result.Triggered != 3 || result.Suppressed != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if repo.typeAlarmType != models.DeviceTypeSmokeDetector || repo.typeAlarmReason != "[CRITICAL] Fire" {
+		t.Errorf("TriggerAlarmByType called with unexpected arguments: type %q, reason %q", repo.typeAlarmType, repo.typeAlarmReason)
+	}
+	// Suppressed alarms never open incidents
+	if incidents.attachCount != 2 {
+		t.Errorf("Expected 2 alarms attached to incidents, got %d", incidents.attachCount)
+	}
+}
+
+func TestSetMaintenance(t *testing.T) {
+	enabled := true
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Device exists", func(t *testing.T) {
+		repo := &MockDeviceRepo{existsOutput: true}
+		service := NewDeviceService(repo)
+
+		if err := service.SetMaintenance(7, &models.MaintenanceRequest{Enabled: &enabled, Until: &until}); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if repo.setMaintenanceID != 7 || !repo.setMaintenanceOn || !repo.setMaintenanceEnd.Equal(until) {
+			t.Errorf("SetMaintenance called with unexpected arguments: id %d, enabled %v, until %s",
+				repo.setMaintenanceID, repo.setMaintenanceOn, repo.setMaintenanceEnd)
+		}
+	})
+
+	t.Run("Device not found", func(t *testing.T) {
+		repo := &MockDeviceRepo{existsOutput: false}
+		service := NewDeviceService(repo)
+
+		err := service.SetMaintenance(7, &models.MaintenanceRequest{Enabled: &enabled})
+		if !errors.Is(err, models.ErrDeviceNotFound) {
+			t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+		}
+		if repo.setMaintenanceID != 0 {
+			t.Errorf("Expected SetMaintenance not to be called")
+		}
+	})
+}
+
+func TestSetArchived(t *testing.T) {
+	t.Run("Device exists", func(t *testing.T) {
+		repo := &MockDeviceRepo{existsOutput: true}
+		service := NewDeviceService(repo)
+
+		if err := service.SetArchived(7, true); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if repo.setArchivedID != 7 || !repo.setArchivedTo {
+			t.Errorf("SetArchived called with unexpected arguments: id %d, archived %v", repo.setArchivedID, repo.setArchivedTo)
+		}
+	})
+
+	t.Run("Device not found", func(t *testing.T) {
+		repo := &MockDeviceRepo{existsOutput: false}
+		service := NewDeviceService(repo)
+
+		if err := service.SetArchived(7, true); !errors.Is(err, models.ErrDeviceNotFound) {
+			t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+		}
+		if repo.setArchivedID != 0 {
+			t.Errorf("Expected SetArchived not to be called")
+		}
+	})
+}
+
+func TestFirmwareUpdate(t *testing.T) {
+	repo := &MockDeviceRepo{existsOutput: true}
+	service := NewDeviceService(repo)
+
+	if err := service.StartFirmwareUpdate(7, &models.FirmwareUpdateRequest{Version: "1.4.0"}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if repo.firmwareTarget != "1.4.0" {
+		t.Errorf("Expected target 1.4.0 to be recorded, got %q", repo.firmwareTarget)
+	}
+
+	err := service.ReportFirmwareUpdate(7, &models.FirmwareReport{Status: models.FirmwareUpdateSuccess})
+	if !errors.Is(err, models.ErrNoFirmwareUpdate) {
+		t.Errorf("Expected ErrNoFirmwareUpdate without a pending update, got %v", err)
+	}
+
+	repo.firmwarePending = true
+	if err := service.ReportFirmwareUpdate(7, &models.FirmwareReport{Status: models.FirmwareUpdateFailed}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if repo.firmwareStatus != models.FirmwareUpdateFailed {
+		t.Errorf("Expected status failed to be recorded, got %q", repo.firmwareStatus)
+	}
+
+	repo.existsOutput = false
+	if err := service.StartFirmwareUpdate(8, &models.FirmwareUpdateRequest{Version: "1.4.0"}); !errors.Is(err, models.ErrDeviceNotFound) {
+		t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+	}
+}
+
+func TestGetDashboard(t *testing.T) {
+	alarms := []*models.AlarmRecord{{ID: 9, DeviceID: 2, Level: models.AlarmLevelCritical, Reason: "[CRITICAL] Smoke"}}
+	mockRepo := &MockDeviceRepo{
+		deviceCounts:  &models.DeviceCounts{Total: 3, Online: 2, Offline: 1},
+		typeCounts:    map[models.DeviceType]int{models.DeviceTypeCamera: 2, models.DeviceTypeLock: 1},
+		historyOutput: alarms,
+	}
+	service := NewDeviceService(mockRepo)
+
+	dashboard, err := service.GetDashboard()
+	if err != nil {
+		t.Fatalf("GetDashboard failed: %v", err)
+	}
+
+	if dashboard.TotalDevices != 3 || dashboard.Online != 2 || dashboard.Offline != 1 {
+		t.Errorf("Unexpected counts: %+v", dashboard)
+	}
+	if dashboard.DevicesByType[models.DeviceTypeCamera] != 2 || dashboard.DevicesByType[models.DeviceTypeLock] != 1 {
+		t.Errorf("Unexpected type counts: %v", dashboard.DevicesByType)
+	}
+	if count, ok := dashboard.DevicesByType[models.DeviceTypeThermostat]; !ok || count != 0 {
+		t.Errorf("Expected types without devices to be listed with zero, got %v", dashboard.DevicesByType)
+	}
+	if len(dashboard.RecentAlarms) != 1 || dashboard.RecentAlarms[0].ID != 9 {
+		t.Errorf("Unexpected recent alarms: %+v", dashboard.RecentAlarms)
+	}
+	if mockRepo.historyFilter == nil || mockRepo.historyFilter.DeviceID != 0 || mockRepo.historyFilter.Limit != dashboardRecentAlarms {
+		t.Errorf("Expected the latest %d alarms across all devices, got filter %+v", dashboardRecentAlarms, mockRepo.historyFilter)
+	}
+}
+
+func TestGetDeviceStateCounts(t *testing.T) {
+	mockRepo := &MockDeviceRepo{
+		stateCounts: []*models.DeviceTypeCounts{
+			{DeviceType: models.DeviceTypeLock, Total: 2, Online: 1, AlarmActive: 1},
+		},
+	}
+	service := NewDeviceService(mockRepo)
+
+	counts, err := service.GetDeviceStateCounts()
+	if err != nil {
+		t.Fatalf("GetDeviceStateCounts failed: %v", err)
+	}
+
+	allTypes := models.GetAllDeviceTypes()
+	if len(counts) != len(allTypes) {
+		t.Fatalf("Expected every type to be listed, got %d of %d", len(counts), len(allTypes))
+	}
+	for i, typeCounts := range counts {
+		if string(typeCounts.DeviceType) != allTypes[i].ID {
+			t.Errorf("Expected %s at position %d, got %s", allTypes[i].ID, i, typeCounts.DeviceType)
+		}
+		expected := models.DeviceTypeCounts{DeviceType: typeCounts.DeviceType}
+		if typeCounts.DeviceType == models.DeviceTypeLock {
+			expected = models.DeviceTypeCounts{DeviceType: models.DeviceTypeLock, Total: 2, Online: 1, AlarmActive: 1}
+		}
+		if *typeCounts != expected {
+			t.Errorf("Expected %+v, got %+v", expected, *typeCounts)
+		}
+	}
+}
+
+func TestStaleFlag(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	devices := func() []*models.Device {
+		return []*models.Device{
+			testutil.NewDevice().WithID(1).WithLastSeen(now.Add(-time.Minute)).Build(),
+			testutil.NewDevice().WithID(2).WithLastSeen(now.Add(-10 * time.Minute)).Build(),
+			testutil.NewDevice().WithID(3).Build(),
+		}
+	}
+
+	t.Run("Threshold set", func(t *testing.T) {
+		repo := &MockDeviceRepo{devices: devices()}
+		service := NewDeviceService(repo, WithStaleThreshold(5*time.Minute))
+		service.now = func() time.Time { return now }
+
+		var stale []int64
+		err := service.StreamDevices(context.Background(), &models.DeviceListOptions{}, func(device *models.Device) error {
+			if device.Stale {
+				stale = append(stale, device.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		// Device 3 has never been seen, which is not the same as having gone quiet
+		if len(stale) != 1 || stale[0] != 2 {
+			t.Errorf("Expected only device 2 to be stale, got %v", stale)
+		}
+	})
+
+	t.Run("No threshold", func(t *testing.T) {
+		repo := &MockDeviceRepo{devices: devices()}
+		service := NewDeviceService(repo)
+		service.now = func() time.Time { return now }
+
+		err := service.StreamDevices(context.Background(), &models.DeviceListOptions{}, func(device *models.Device) error {
+			if device.Stale {
+				t.Errorf("Expected device %d not to be stale without a threshold", device.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+	})
+}
+
+func TestRecordDeviceSeen(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &MockDeviceRepo{}
+	service := NewDeviceService(repo)
+	service.now = func() time.Time { return now }
+
+	if err := service.RecordDeviceSeen(7); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if repo.seenID != 7 || !repo.seenAt.Equal(now) {
+		t.Errorf("RecordSeen called with device %d at %s", repo.seenID, repo.seenAt)
+	}
+}
+
+func TestUpdateDeviceSkipsNoOp(t *testing.T) {
+	name, spaced, renamed := "Front Door", " Front  Door", "Back Door"
+	description, online := "Porch camera", false
+	current := testutil.NewDevice().WithName(name).WithDescription(description).Build()
+	current.MaintenanceMode, current.MaintenanceUntil = true, testutil.Epoch.Add(time.Hour)
+	sameEnd, laterEnd := testutil.Epoch.Add(time.Hour+400*time.Millisecond), testutil.Epoch.Add(2*time.Hour)
+
+	tests := []struct {
+		name    string
+		update  *models.DeviceUpdate
+		opts    []Option
+		changed bool
+	}{
+		{"Identical", &models.DeviceUpdate{Name: &name, Description: &description, IsOnline: &online}, nil, false},
+		{"Whitespace differs", &models.DeviceUpdate{Name: &spaced}, nil, true},
+		{"Whitespace normalized away", &models.DeviceUpdate{Name: &spaced}, []Option{WithNameNormalization()}, false},
+		{"Changed", &models.DeviceUpdate{Name: &renamed, Description: &description}, nil, true},
+		{"Parent detached", &models.DeviceUpdate{ParentID: models.NullableInt64{Set: true}}, nil, false},
+		{"Maintenance end within the second", &models.DeviceUpdate{MaintenanceUntil: &sameEnd}, nil, false},
+		{"Maintenance end moved", &models.DeviceUpdate{MaintenanceUntil: &laterEnd}, nil, true},
+		{"Always written", &models.DeviceUpdate{Name: &name}, []Option{WithAlwaysWriteUpdates()}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockDeviceRepo{existsOutput: true, getByIDOutput: current}
+			changed, err := NewDeviceService(repo, tc.opts...).UpdateDevice(1, tc.update)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if changed != tc.changed {
+				t.Errorf("Expected changed %t, got %t", tc.changed, changed)
+			}
+			if written := repo.updateInput != nil; written != tc.changed {
+				t.Errorf("Expected the update written: %t, got %t", tc.changed, written)
+			}
+		})
+	}
+}
+
+func TestNameNormalization(t *testing.T) {
+	repo := &MockDeviceRepo{existsOutput: true, getByIDOutput: testutil.NewDevice().Build()}
+	service := NewDeviceService(repo, WithNameNormalization())
+
+	name := "  Front   Door "
+	if _, err := service.UpdateDevice(1, &models.DeviceUpdate{Name: &name}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if got := *repo.updateInput.Name; got != "Front Door" {
+		t.Errorf("Expected the stored name to be normalized, got %q", got)
+	}
+
+	opts := &models.DeviceListOptions{Names: []string{" front  door"}, Limit: 10}
+	if err := service.StreamDevices(context.Background(), opts, func(*models.Device) error { return nil }); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if repo.eachDeviceOpts.Names[0] != "front door" {
+		t.Errorf("Expected the searched name to be normalized, got %q", repo.eachDeviceOpts.Names[0])
+	}
+	if opts.Names[0] != " front  door" {
+		t.Errorf("Expected the caller's options to be left alone, got %q", opts.Names[0])
+	}
+
+	// Off by default
+	if _, err := NewDeviceService(repo).UpdateDevice(1, &models.DeviceUpdate{Name: &name}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if got := *repo.updateInput.Name; got != name {
+		t.Errorf("Expected the name stored as given without normalization, got %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import "strings"
+
+// fuzzyMinSimilarity is the lowest similarity a name needs to be included in fuzzy results
+const fuzzyMinSimilarity = 0.6
+
+// nameSimilarity scores how well name matches query, from 0 to 1 and ignoring case. partial
+// compares the query with the closest matching part of the name, so "kitchn" matches
+// "KitchenSmoke" well; whole compares the full strings and is used to break ties in favour of
+// names that are close to the query in their entirety.
+func nameSimilarity(query, name string) (partial, whole float64) {
+	q := []rune(strings.ToLower(query))
+	n := []rune(strings.ToLower(name))
+	if len(q) == 0 {
+		return 0, 0
+	}
+
+	partial = 1 - float64(substringDistance(q, n))/float64(len(q))
+	longest := len(q)
+	if len(n) > longest {
+		longest = len(n)
+	}
+	whole = 1 - float64(levenshtein(q, n))/float64(longest)
+
+	return partial, whole
+}
+
+// levenshtein returns the number of single-rune insertions, deletions and substitutions
+// needed to turn a into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			curr[j] = editStep(prev, curr, j, a[i-1] == b[j-1])
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// substringDistance returns the smallest edit distance between query and any substring of s.
+// It is levenshtein with skipping the start and end of s made free.
+func substringDistance(query, s []rune) int {
+	prev := make([]int, len(s)+1)
+	curr := make([]int, len(s)+1)
+
+	for i := 1; i <= len(query); i++ {
+		curr[0] = i
+		for j := 1; j <= len(s); j++ {
+			curr[j] = editStep(prev, curr, j, query[i-1] == s[j-1])
+		}
+		prev, curr = curr, prev
+	}
+
+	best := prev[0]
+	for _, d := range prev[1:] {
+		if d < best {
+			best = d
+		}
+	}
+
+	return best
+}
+
+// editStep computes one cell of an edit distance table from its neighbours
+func editStep(prev, curr []int, j int, same bool) int {
+	substitution := prev[j-1]
+	if !same {
+		substitution++
+	}
+
+	return min(substitution, prev[j]+1, curr[j-1]+1)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"kitchen", "kitchen", 0},
+		{"kitchen", "kitchn", 1},
+		{"kitchen", "ktichen", 2},
+		{"", "abc", 3},
+		{"garage", "kitchen", 6},
+	}
+
+	for _, tc := range tests {
+		if got := levenshtein([]rune(tc.a), []rune(tc.b)); got != tc.expected {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", tc.a, tc.b, got, tc.expected)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		deviceName      string
+		expectedPartial float64
+	}{
+		{"Exact", "Kitchen", "Kitchen", 1},
+		{"Ignores case", "kitchen", "KITCHEN", 1},
+		{"Contained in longer name", "kitchen", "KitchenSmoke", 1},
+		{"Typo in contained word", "kitchn", "KitchenSmoke", 5.0 / 6},
+		{"Unrelated", "garage", "KitchenSmoke", 1.0 / 6},
+		{"Empty query", "", "Kitchen", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			partial, _ := nameSimilarity(tc.query, tc.deviceName)
+			if diff := partial - tc.expectedPartial; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Expected partial similarity %.3f, got %.3f", tc.expectedPartial, partial)
+			}
+		})
+	}
+
+	// Between two names containing the query, the closer one overall wins the tie
+	_, short := nameSimilarity("kitchen", "Kitchen1")
+	_, long := nameSimilarity("kitchen", "KitchenSmokeDetector")
+	if short <= long {
+		t.Errorf("Expected the shorter name to be more similar overall, got %.3f <= %.3f", short, long)
+	}
+}
+
+func TestFuzzySearchDevices(t *testing.T) {
+	maintenance := true
+	repo := &MockDeviceRepo{devices: []*models.Device{
+		testutil.NewDevice().WithID(1).WithName("Garage").Build(),
+		testutil.NewDevice().WithID(2).WithName("KitchenSmokeDetector").Build(),
+		testutil.NewDevice().WithID(3).WithName("Kitchen").Build(),
+		testutil.NewDevice().WithID(4).WithName("Kitchn").Build(),
+		testutil.NewDevice().WithID(5).WithName("Hallway").Build(),
+	}}
+	service := NewDeviceService(repo)
+
+	devices, err := service.FuzzySearchDevices(context.Background(), &models.DeviceListOptions{Names: []string{"kitchen"}, Limit: 10, Maintenance: &maintenance})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	var ids []int64
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+	expected := []int64{3, 2, 4}
+	if len(ids) != len(expected) {
+		t.Fatalf("Expected devices %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("Expected devices %v, got %v", expected, ids)
+		}
+	}
+
+	// Other filters still narrow the candidates, but the name filter itself is not applied exactly
+	if repo.eachDeviceOpts.Names != nil || repo.eachDeviceOpts.Maintenance != &maintenance {
+		t.Errorf("Unexpected candidate options: %+v", repo.eachDeviceOpts)
+	}
+
+	devices, err = service.FuzzySearchDevices(context.Background(), &models.DeviceListOptions{Names: []string{"kitchen"}, Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(devices) != 1 || devices[0].ID != 2 {
+		t.Errorf("Expected the second match only, got %v", devices)
+	}
+}
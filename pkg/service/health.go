@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// HealthPolicy configures device health scores. A score is the weighted average of three
+// components, each from 0 to 1, scaled to 0-100 and rounded:
+//
+//	online    = 1 if the device is online, otherwise 0
+//	heartbeat = 1 - age/HeartbeatWindow, clamped to [0, 1], where age is the time since the
+//	            device was last seen; 0 if it never has been
+//	alarms    = 1 - count/AlarmLimit, clamped to [0, 1], where count is the number of alarms
+//	            raised within AlarmWindow, suppressed ones included
+//
+//	score = 100 * (OnlineWeight*online + HeartbeatWeight*heartbeat + AlarmWeight*alarms)
+//	            / (OnlineWeight + HeartbeatWeight + AlarmWeight)
+//
+// A weight of zero leaves its component out of the score, as does a zero HeartbeatWindow for the
+// heartbeat and a zero AlarmWindow or AlarmLimit for alarms. With every component left out the
+// score is zero.
+type HealthPolicy struct {
+	OnlineWeight    float64
+	HeartbeatWeight float64
+	AlarmWeight     float64
+
+	HeartbeatWindow time.Duration
+	AlarmWindow     time.Duration
+	AlarmLimit      int
+}
+
+// DefaultHealthPolicy is used unless WithHealthPolicy configures another
+var DefaultHealthPolicy = HealthPolicy{
+	OnlineWeight:    40,
+	HeartbeatWeight: 30,
+	AlarmWeight:     30,
+	HeartbeatWindow: time.Hour,
+	AlarmWindow:     24 * time.Hour,
+	AlarmLimit:      10,
+}
+
+// WithHealthPolicy computes device health scores with policy instead of DefaultHealthPolicy
+func WithHealthPolicy(policy HealthPolicy) Option {
+	return func(s *DeviceService) {
+		s.health = policy
+	}
+}
+
+// GetDeviceHealth computes the health score of a device from its state and recent alarm history
+func (s *DeviceService) GetDeviceHealth(id int64) (*models.DeviceHealth, error) {
+	device, err := s.reader.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, fmt.Errorf("%w with ID: %d", models.ErrDeviceNotFound, id)
+	}
+
+	now := s.now()
+	var alarms int
+	if s.health.AlarmWindow > 0 {
+		alarms, err = s.reader.CountAlarmHistory(&models.AlarmHistoryFilter{
+			DeviceID: id,
+			Events:   []string{models.AlarmEventTriggered},
+			After:    now.Add(-s.health.AlarmWindow),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.health.score(device, alarms, now), nil
+}
+
+// score applies the policy to a device that raised alarms alarms within the alarm window
+func (p HealthPolicy) score(device *models.Device, alarms int, now time.Time) *models.DeviceHealth {
+	var components models.HealthComponents
+	onlineWeight, heartbeatWeight, alarmWeight := p.OnlineWeight, p.HeartbeatWeight, p.AlarmWeight
+	if device.IsOnline {
+		components.Online = 1
+	}
+	if p.HeartbeatWindow > 0 {
+		if !device.LastSeenAt.IsZero() {
+			age := now.Sub(device.LastSeenAt)
+			components.Heartbeat = clampUnit(1 - float64(age)/float64(p.HeartbeatWindow))
+		}
+	} else {
+		heartbeatWeight = 0
+	}
+	if p.AlarmWindow > 0 && p.AlarmLimit > 0 {
+		components.Alarms = clampUnit(1 - float64(alarms)/float64(p.AlarmLimit))
+	} else {
+		alarmWeight = 0
+	}
+
+	health := &models.DeviceHealth{
+		DeviceID:     device.ID,
+		IsOnline:     device.IsOnline,
+		LastSeenAt:   device.LastSeenAt,
+		RecentAlarms: alarms,
+		Components:   components,
+	}
+	if total := onlineWeight + heartbeatWeight + alarmWeight; total > 0 {
+		weighted := onlineWeight*components.Online + heartbeatWeight*components.Heartbeat +
+			alarmWeight*components.Alarms
+		health.Score = int(math.Round(100 * weighted / total))
+	}
+
+	return health
+}
+
+func clampUnit(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestHealthPolicyScore(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   HealthPolicy
+		device   *models.Device
+		alarms   int
+		expected int
+	}{
+		{"Online, just seen, no alarms", DefaultHealthPolicy, testutil.NewDevice().Online().WithLastSeen(now).Build(), 0, 100},
+		{"Offline, never seen, many alarms", DefaultHealthPolicy, testutil.NewDevice().Build(), 25, 0},
+		// 40*1 + 30*0.5 + 30*0.7 = 76
+		{"Online, seen half a window ago, some alarms", DefaultHealthPolicy, testutil.NewDevice().Online().WithLastSeen(now.Add(-30 * time.Minute)).Build(), 3, 76},
+		{"Offline, seen long ago, no alarms", DefaultHealthPolicy, testutil.NewDevice().WithLastSeen(now.Add(-48 * time.Hour)).Build(), 0, 30},
+		{"Only the online weight", HealthPolicy{OnlineWeight: 1, HeartbeatWindow: time.Hour, AlarmWindow: time.Hour, AlarmLimit: 1}, testutil.NewDevice().Online().Build(), 5, 100},
+		// The heartbeat and alarm components are left out, so the online component is the score
+		{"Zero windows leave components out", HealthPolicy{OnlineWeight: 1, HeartbeatWeight: 1, AlarmWeight: 1}, testutil.NewDevice().Online().Build(), 5, 100},
+		{"No weights", HealthPolicy{HeartbeatWindow: time.Hour}, testutil.NewDevice().Online().WithLastSeen(now).Build(), 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			health := tc.policy.score(tc.device, tc.alarms, now)
+			if health.Score != tc.expected {
+				t.Errorf("Expected score %d, got %d (components %+v)", tc.expected, health.Score, health.Components)
+			}
+			if health.RecentAlarms != tc.alarms {
+				t.Errorf("Expected %d recent alarms, got %d", tc.alarms, health.RecentAlarms)
+			}
+		})
+	}
+}
+
+func TestGetDeviceHealth(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Counts alarms within the window", func(t *testing.T) {
+		mockRepo := &MockDeviceRepo{
+			getByIDOutput: testutil.NewDevice().WithID(3).Online().WithLastSeen(now).Build(),
+			historyOutput: []*models.AlarmRecord{{}, {}},
+		}
+		service := NewDeviceService(mockRepo, WithHealthPolicy(HealthPolicy{
+			AlarmWeight: 1, AlarmWindow: 6 * time.Hour, AlarmLimit: 4,
+		}))
+		service.now = func() time.Time { return now }
+
+		health, err := service.GetDeviceHealth(3)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if health.DeviceID != 3 || health.RecentAlarms != 2 || health.Score != 50 {
+			t.Errorf("Unexpected health %+v", health)
+		}
+		if mockRepo.historyFilter.DeviceID != 3 || !mockRepo.historyFilter.After.Equal(now.Add(-6*time.Hour)) {
+			t.Errorf("Expected alarms of device 3 since 6h ago, got %+v", mockRepo.historyFilter)
+		}
+	})
+
+	t.Run("Device not found", func(t *testing.T) {
+		service := NewDeviceService(&MockDeviceRepo{})
+
+		if _, err := service.GetDeviceHealth(9); !errors.Is(err, models.ErrDeviceNotFound) {
+			t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+		}
+	})
+}
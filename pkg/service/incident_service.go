@@ -0,0 +1,26 @@
+package service
+
+import (
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// IncidentService handles business logic for incidents
+type IncidentService struct {
+	repo repository.IncidentRepository
+}
+
+// NewIncidentService creates a new IncidentService
+func NewIncidentService(repo repository.IncidentRepository) *IncidentService {
+	return &IncidentService{repo: repo}
+}
+
+// ListIncidents retrieves a page of incidents with their member alarms
+func (s *IncidentService) ListIncidents(opts *models.IncidentListOptions) ([]*models.Incident, error) {
+	return s.repo.List(opts)
+}
+
+// ResolveIncident closes an incident and clears the active alarms of its member devices
+func (s *IncidentService) ResolveIncident(id int64) error {
+	return s.repo.Resolve(id)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// DiffDevices compares a validated manifest with the stored devices of the owners it lists,
+// matching devices by owner and name. Only the device type, and the description when the
+// manifest gives one, are compared. When several stored devices share an owner and name, the
+// oldest is matched and the others are unexpected.
+//
+// Without apply nothing is written. With apply, missing devices are created and changed ones
+// updated in one transaction; unexpected devices are never deleted. The comparison is then read
+// from the primary, and a device modified since fails the whole apply with ErrDeviceChanged.
+// While device types are locked, an apply that would change a device's type fails with
+// ErrDeviceTypeLocked.
+func (s *DeviceService) DiffDevices(ctx context.Context, manifest *models.Manifest, apply bool) (*models.DeviceDiff, error) {
+	type key struct{ owner, name string }
+
+	desired := make(map[key]*models.ManifestDevice, len(manifest.Devices))
+	var owners []string
+	for _, device := range manifest.Devices {
+		device.Name = s.normalizeName(device.Name)
+		if !slices.Contains(owners, device.OwnedBy) {
+			owners = append(owners, device.OwnedBy)
+		}
+		desired[key{device.OwnedBy, device.Name}] = device
+	}
+
+	reader := s.reader
+	if apply {
+		reader = s.repo
+	}
+
+	diff := &models.DeviceDiff{
+		Missing:    []*models.ManifestDevice{},
+		Unexpected: []*models.Device{},
+		Changed:    []*models.DeviceDrift{},
+	}
+	matched := make(map[key]bool, len(desired))
+	opts := &models.DeviceListOptions{Owners: owners, SortBy: "id", SortOrder: models.SortAsc}
+	err := reader.EachDevice(ctx, opts, func(device *models.Device) error {
+		k := key{device.OwnedBy, device.Name}
+		want, listed := desired[k]
+		if !listed || matched[k] {
+			diff.Unexpected = append(diff.Unexpected, device)
+			return nil
+		}
+		matched[k] = true
+
+		if drift := deviceDrift(device, want); drift != nil {
+			diff.Changed = append(diff.Changed, drift)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Missing devices are reported in manifest order
+	for _, device := range manifest.Devices {
+		if !matched[key{device.OwnedBy, device.Name}] {
+			diff.Missing = append(diff.Missing, device)
+		}
+	}
+
+	if !apply || (len(diff.Missing) == 0 && len(diff.Changed) == 0) {
+		diff.Applied = apply
+		return diff, nil
+	}
+
+	if s.lockTypes {
+		for _, drift := range diff.Changed {
+			if _, changed := drift.Fields["device_type"]; changed {
+				return nil, fmt.Errorf("%w: device %d is a %s", models.ErrDeviceTypeLocked, drift.ID, drift.Fields["device_type"].Current)
+			}
+		}
+	}
+
+	creates := make([]*models.DeviceCreate, 0, len(diff.Missing))
+	for _, device := range diff.Missing {
+		create := &models.DeviceCreate{Name: device.Name, DeviceType: device.DeviceType, OwnedBy: device.OwnedBy}
+		if device.Description != nil {
+			create.Description = *device.Description
+		}
+		creates = append(creates, create)
+	}
+	if err := s.repo.ApplyManifest(creates, diff.Changed); err != nil {
+		return nil, err
+	}
+	s.invalidateStats()
+	diff.Applied = true
+
+	return diff, nil
+}
+
+// deviceDrift lists the fields of device that differ from want, or returns nil if none do
+func deviceDrift(device *models.Device, want *models.ManifestDevice) *models.DeviceDrift {
+	fields := make(map[string]models.FieldDrift)
+	if device.DeviceType != want.DeviceType {
+		fields["device_type"] = models.FieldDrift{Current: device.DeviceType, Desired: want.DeviceType}
+	}
+	if want.Description != nil && device.Description != *want.Description {
+		fields["description"] = models.FieldDrift{Current: device.Description, Desired: *want.Description}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &models.DeviceDrift{
+		ID:      device.ID,
+		Name:    device.Name,
+		OwnedBy: device.OwnedBy,
+		Version: device.Version,
+		Fields:  fields,
+		Desired: want,
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestDiffDevices(t *testing.T) {
+	camera := "Porch camera"
+	newManifest := func() *models.Manifest {
+		return &models.Manifest{Devices: []*models.ManifestDevice{
+			{Name: "Kitchen", DeviceType: models.DeviceTypeLock, OwnedBy: "alice"},
+			{Name: "Hall", DeviceType: models.DeviceTypeLock, OwnedBy: "alice", Description: &camera},
+			{Name: "Porch", DeviceType: models.DeviceTypeCamera, OwnedBy: "alice", Description: &camera},
+		}}
+	}
+	newRepo := func() *MockDeviceRepo {
+		return &MockDeviceRepo{devices: []*models.Device{
+			testutil.NewDevice().WithID(1).WithName("Kitchen").WithType(models.DeviceTypeCamera).WithOwner("alice").WithDescription("Old").WithVersion(4).Build(),
+			testutil.NewDevice().WithID(2).WithName("Hall").WithType(models.DeviceTypeLock).WithOwner("alice").WithDescription(camera).Build(),
+			testutil.NewDevice().WithID(3).WithName("Hall").WithType(models.DeviceTypeLock).WithOwner("alice").Build(),
+		}}
+	}
+
+	repo := newRepo()
+	diff, err := NewDeviceService(repo).DiffDevices(context.Background(), newManifest(), false)
+	if err != nil {
+		t.Fatalf("DiffDevices failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(repo.eachDeviceOpts.Owners, []string{"alice"}) {
+		t.Errorf("Expected only the manifest's owners read, got %v", repo.eachDeviceOpts.Owners)
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0].Name != "Porch" {
+		t.Errorf("Expected Porch missing, got %+v", diff.Missing)
+	}
+	// The second Hall is not listed, as the first matched its entry
+	if len(diff.Unexpected) != 1 || diff.Unexpected[0].ID != 3 {
+		t.Errorf("Expected the duplicate Hall unexpected, got %+v", diff.Unexpected)
+	}
+	// Kitchen's description is not in the manifest, so only its type drifted
+	expected := map[string]models.FieldDrift{"device_type": {Current: models.DeviceTypeCamera, Desired: models.DeviceTypeLock}}
+	if len(diff.Changed) != 1 || diff.Changed[0].ID != 1 || diff.Changed[0].Version != 4 || !reflect.DeepEqual(diff.Changed[0].Fields, expected) {
+		t.Errorf("Expected Kitchen's type changed, got %+v", diff.Changed)
+	}
+	if diff.Applied || repo.appliedCreates != nil || repo.appliedChanges != nil {
+		t.Error("Expected a dry run to write nothing")
+	}
+
+	repo = newRepo()
+	diff, err = NewDeviceService(repo).DiffDevices(context.Background(), newManifest(), true)
+	if err != nil {
+		t.Fatalf("DiffDevices failed: %v", err)
+	}
+	if !diff.Applied {
+		t.Error("Expected the diff applied")
+	}
+	if len(repo.appliedCreates) != 1 || repo.appliedCreates[0].Name != "Porch" || repo.appliedCreates[0].Description != camera {
+		t.Errorf("Expected Porch created, got %+v", repo.appliedCreates)
+	}
+	if len(repo.appliedChanges) != 1 || repo.appliedChanges[0].ID != 1 {
+		t.Errorf("Expected Kitchen updated, got %+v", repo.appliedChanges)
+	}
+
+	// With types locked the same apply is refused, while a dry run still reports the drift
+	repo = newRepo()
+	locked := NewDeviceService(repo, WithDeviceTypeLock())
+	if _, err := locked.DiffDevices(context.Background(), newManifest(), true); !errors.Is(err, models.ErrDeviceTypeLocked) {
+		t.Errorf("Expected ErrDeviceTypeLocked, got %v", err)
+	}
+	if repo.appliedCreates != nil || repo.appliedChanges != nil {
+		t.Error("Expected a refused apply to write nothing")
+	}
+	if diff, err := locked.DiffDevices(context.Background(), newManifest(), false); err != nil || len(diff.Changed) != 1 {
+		t.Errorf("Expected the dry run to report Kitchen's type, got %+v, %v", diff, err)
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// OnlineDebounce is how long a device must go unheard from before it is marked offline. A device
+// heard from comes back online straight away, so a marginal connection that drops and returns
+// within the period never changes state. A zero period turns debouncing off for a type: its
+// devices go offline as soon as their connection ends, and heartbeats do not bring them back.
+type OnlineDebounce struct {
+	Default time.Duration
+	// ByType overrides Default for the listed device types
+	ByType map[models.DeviceType]time.Duration
+}
+
+// For returns the debounce period of a device type
+func (d OnlineDebounce) For(deviceType models.DeviceType) time.Duration {
+	if period, ok := d.ByType[deviceType]; ok {
+		return period
+	}
+	return d.Default
+}
+
+// enabled reports whether any device type is debounced
+func (d OnlineDebounce) enabled() bool {
+	if d.Default > 0 {
+		return true
+	}
+	for _, period := range d.ByType {
+		if period > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WithOnlineDebounce derives devices' online state from when they were last heard from, with
+// debounce setting how long they may go quiet before being marked offline. An OnlineWatchdog
+// using the same debounce marks them offline.
+func WithOnlineDebounce(debounce OnlineDebounce) Option {
+	return func(s *DeviceService) {
+		s.debounce = debounce
+	}
+}
+
+// RecordDeviceSeen notes that a device has just been heard from. With online debouncing, a device
+// heard from while offline is marked online again.
+func (s *DeviceService) RecordDeviceSeen(id int64) error {
+	if !s.debounce.enabled() {
+		return s.repo.RecordSeen(id, s.now())
+	}
+
+	cameOnline, err := s.repo.RecordSeenOnline(id, s.now())
+	if cameOnline {
+		s.invalidateStats()
+	}
+	return err
+}
+
+// SetDeviceConnected records a device connecting or disconnecting. Connecting marks it online;
+// with debouncing, only if it was offline, so reconnecting within the period changes nothing.
+// Disconnecting marks it offline unless its type is debounced, in which case the OnlineWatchdog
+// does once it has been unheard from for the debounce period. A device already in the reported
+// state is left untouched.
+func (s *DeviceService) SetDeviceConnected(id int64, connected bool) error {
+	if connected && s.debounce.enabled() {
+		cameOnline, err := s.repo.RecordSeenOnline(id, s.now())
+		if cameOnline {
+			s.invalidateStats()
+		}
+		return err
+	}
+	if !connected {
+		device, err := s.repo.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if device == nil || s.debounce.For(device.DeviceType) > 0 {
+			return nil
+		}
+	} else if err := s.ensureExists(id); err != nil {
+		return err
+	}
+
+	changed, err := s.repo.SetOnlineIfChanged(id, connected)
+	if changed {
+		s.invalidateStats()
+	}
+	return err
+}
+
+// OnlineWatchdog periodically marks devices offline once they have not been heard from for their
+// type's debounce period
+type OnlineWatchdog struct {
+	repo     repository.DeviceWriter
+	debounce OnlineDebounce
+	now      func() time.Time
+}
+
+// NewOnlineWatchdog creates an OnlineWatchdog. Device types with no debounce period are skipped.
+func NewOnlineWatchdog(repo repository.DeviceWriter, debounce OnlineDebounce) *OnlineWatchdog {
+	return &OnlineWatchdog{repo: repo, debounce: debounce, now: time.Now}
+}
+
+// Check marks every device that has gone quiet for too long offline once, returning how many were
+func (w *OnlineWatchdog) Check() (int64, error) {
+	now := w.now()
+
+	var total int64
+	for _, info := range models.GetAllDeviceTypes() {
+		deviceType := models.DeviceType(info.ID)
+		period := w.debounce.For(deviceType)
+		if period <= 0 {
+			continue
+		}
+
+		marked, err := w.repo.MarkUnseenOffline(deviceType, now.Add(-period))
+		if err != nil {
+			return total, err
+		}
+		if marked > 0 {
+			log.Printf("Marked %d %s device(s) offline after %s without contact", marked, deviceType, period)
+		}
+		total += marked
+	}
+
+	return total, nil
+}
+
+// Run checks on every interval until ctx is cancelled
+func (w *OnlineWatchdog) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Check(); err != nil {
+				log.Printf("Error marking quiet devices offline: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestOnlineDebounceFor(t *testing.T) {
+	debounce := OnlineDebounce{
+		Default: time.Minute,
+		ByType:  map[models.DeviceType]time.Duration{models.DeviceTypeCamera: 5 * time.Minute, models.DeviceTypeLock: 0},
+	}
+
+	if got := debounce.For(models.DeviceTypeCamera); got != 5*time.Minute {
+		t.Errorf("Expected the camera override, got %s", got)
+	}
+	if got := debounce.For(models.DeviceTypeLock); got != 0 {
+		t.Errorf("Expected locks not to be debounced, got %s", got)
+	}
+	if got := debounce.For(models.DeviceTypeThermostat); got != time.Minute {
+		t.Errorf("Expected the default, got %s", got)
+	}
+}
+
+func TestRecordDeviceSeen_Debounced(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &MockDeviceRepo{cameOnline: true}
+	service := NewDeviceService(repo, WithOnlineDebounce(OnlineDebounce{Default: time.Minute}))
+	service.now = func() time.Time { return now }
+
+	if err := service.RecordDeviceSeen(7); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if repo.seenID != 7 || !repo.seenAt.Equal(now) {
+		t.Errorf("RecordSeenOnline called with device %d at %s", repo.seenID, repo.seenAt)
+	}
+}
+
+func TestSetDeviceConnected(t *testing.T) {
+	debounce := OnlineDebounce{ByType: map[models.DeviceType]time.Duration{models.DeviceTypeCamera: time.Minute}}
+	no := false
+
+	tests := []struct {
+		name       string
+		deviceType models.DeviceType
+		connected  bool
+		expected   *bool
+	}{
+		{"Connect", models.DeviceTypeCamera, true, nil},
+		{"Disconnect undebounced type", models.DeviceTypeLock, false, &no},
+		{"Disconnect debounced type", models.DeviceTypeCamera, false, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &MockDeviceRepo{
+				existsOutput:  true,
+				getByIDOutput: testutil.NewDevice().WithID(3).WithType(tc.deviceType).Build(),
+			}
+			service := NewDeviceService(repo, WithOnlineDebounce(debounce))
+
+			if err := service.SetDeviceConnected(3, tc.connected); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if tc.connected && repo.seenID != 3 {
+				t.Errorf("Expected connecting to go through RecordSeenOnline")
+			}
+			if tc.expected == nil {
+				if repo.setOnline != nil {
+					t.Errorf("Expected no direct online update, got %t", *repo.setOnline)
+				}
+				return
+			}
+			if repo.setOnline == nil || *repo.setOnline != *tc.expected {
+				t.Errorf("Expected is_online set to %t, got %v", *tc.expected, repo.setOnline)
+			}
+		})
+	}
+}
+
+func TestOnlineWatchdog_Check(t *testing.T) {
+	clock := testutil.NewClock(testutil.Epoch)
+	repo := &MockDeviceRepo{}
+	watchdog := NewOnlineWatchdog(repo, OnlineDebounce{
+		Default: 2 * time.Minute,
+		ByType:  map[models.DeviceType]time.Duration{models.DeviceTypeCamera: 10 * time.Minute, models.DeviceTypeLock: 0},
+	})
+	watchdog.now = clock.Now
+
+	marked, err := watchdog.Check()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if expected := int64(len(models.GetAllDeviceTypes()) - 1); marked != expected {
+		t.Errorf("Expected %d devices marked offline, got %d", expected, marked)
+	}
+	if got := repo.markedOffline[models.DeviceTypeCamera]; !got.Equal(testutil.Epoch.Add(-10 * time.Minute)) {
+		t.Errorf("Expected cameras checked against %s, got %s", testutil.Epoch.Add(-10*time.Minute), got)
+	}
+	if got := repo.markedOffline[models.DeviceTypeThermostat]; !got.Equal(testutil.Epoch.Add(-2 * time.Minute)) {
+		t.Errorf("Expected thermostats checked against the default, got %s", got)
+	}
+	if _, checked := repo.markedOffline[models.DeviceTypeLock]; checked {
+		t.Errorf("Expected locks never to be marked offline")
+	}
+}
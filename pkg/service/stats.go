@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+	"github.com/tyrese-r/go-home/pkg/repository"
+)
+
+// DeviceStatsCache keeps the latest device stats in memory so they can be served without
+// running the aggregate queries. Run recomputes them on an interval and soon after Invalidate
+// is called, which the DeviceService does whenever it changes a device.
+type DeviceStatsCache struct {
+	reader repository.DeviceReader
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	stats *models.DeviceStats
+
+	// invalidated holds at most one pending refresh, so a burst of changes costs one recompute
+	invalidated chan struct{}
+}
+
+// NewDeviceStatsCache creates an empty DeviceStatsCache reading counts from reader
+func NewDeviceStatsCache(reader repository.DeviceReader) *DeviceStatsCache {
+	return &DeviceStatsCache{reader: reader, now: time.Now, invalidated: make(chan struct{}, 1)}
+}
+
+// WithStatsCache serves GetDeviceStats from cache and invalidates it whenever a device is changed
+func WithStatsCache(cache *DeviceStatsCache) Option {
+	return func(s *DeviceService) {
+		s.stats = cache
+	}
+}
+
+// Get returns the cached stats, computing them first if they have never been computed
+func (c *DeviceStatsCache) Get() (*models.DeviceStats, error) {
+	c.mu.RLock()
+	stats := c.stats
+	c.mu.RUnlock()
+	if stats != nil {
+		return stats, nil
+	}
+
+	return c.Refresh()
+}
+
+// Refresh recomputes the stats now and caches them
+func (c *DeviceStatsCache) Refresh() (*models.DeviceStats, error) {
+	stats, err := computeDeviceStats(c.reader, c.now())
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.stats = stats
+	c.mu.Unlock()
+
+	return stats, nil
+}
+
+// Invalidate asks Run to recompute the stats without waiting for the next interval. It never
+// blocks, so it is safe to call on every write.
+func (c *DeviceStatsCache) Invalidate() {
+	select {
+	case c.invalidated <- struct{}{}:
+	default:
+	}
+}
+
+// Run recomputes the stats on every interval, and when invalidated, until ctx is cancelled
+func (c *DeviceStatsCache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	refresh := func() {
+		if _, err := c.Refresh(); err != nil {
+			log.Printf("Error computing device stats: %v", err)
+		}
+	}
+	refresh()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		case <-c.invalidated:
+			refresh()
+		}
+	}
+}
+
+// GetDeviceStats returns device counts by connection and alarm state. With a stats cache they
+// are served from memory and may trail the latest changes by the time a recompute takes.
+func (s *DeviceService) GetDeviceStats() (*models.DeviceStats, error) {
+	if s.stats != nil {
+		return s.stats.Get()
+	}
+
+	return computeDeviceStats(s.reader, s.now())
+}
+
+// invalidateStats marks cached stats out of date after a device changes
+func (s *DeviceService) invalidateStats() {
+	if s.stats != nil {
+		s.stats.Invalidate()
+	}
+}
+
+// computeDeviceStats runs the per-type aggregate and totals it. Every known type is listed,
+// including those with no devices.
+func computeDeviceStats(reader repository.DeviceReader, now time.Time) (*models.DeviceStats, error) {
+	byType, err := deviceStateCounts(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.DeviceStats{ByType: byType, ComputedAt: now.UTC()}
+	for _, counts := range byType {
+		stats.Total += counts.Total
+		stats.Online += counts.Online
+		stats.AlarmActive += counts.AlarmActive
+	}
+	stats.Offline = stats.Total - stats.Online
+
+	return stats, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/tyrese-r/go-home/internal/testutil"
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestGetDeviceStats(t *testing.T) {
+	repo := &MockDeviceRepo{
+		stateCounts: []*models.DeviceTypeCounts{
+			{DeviceType: models.DeviceTypeLock, Total: 3, Online: 2, AlarmActive: 1},
+			{DeviceType: models.DeviceTypeCamera, Total: 2, Online: 2, AlarmActive: 1},
+		},
+	}
+	service := NewDeviceService(repo)
+
+	stats, err := service.GetDeviceStats()
+	if err != nil {
+		t.Fatalf("GetDeviceStats failed: %v", err)
+	}
+	if stats.Total != 5 || stats.Online != 4 || stats.Offline != 1 || stats.AlarmActive != 2 {
+		t.Errorf("Unexpected totals %+v", stats)
+	}
+	if len(stats.ByType) != len(models.GetAllDeviceTypes()) {
+		t.Errorf("Expected every type to be listed, got %d", len(stats.ByType))
+	}
+}
+
+func TestDeviceStatsCache(t *testing.T) {
+	clock := testutil.NewClock(testutil.Epoch)
+	repo := &MockDeviceRepo{
+		existsOutput: true,
+		stateCounts:  []*models.DeviceTypeCounts{{DeviceType: models.DeviceTypeLock, Total: 1}},
+	}
+	cache := NewDeviceStatsCache(repo)
+	cache.now = clock.Now
+	service := NewDeviceService(repo, WithStatsCache(cache))
+
+	stats, err := service.GetDeviceStats()
+	if err != nil {
+		t.Fatalf("GetDeviceStats failed: %v", err)
+	}
+	if stats.Total != 1 || !stats.ComputedAt.Equal(testutil.Epoch) {
+		t.Fatalf("Expected stats computed on first use, got %+v", stats)
+	}
+
+	// Served from memory until refreshed
+	repo.stateCounts = []*models.DeviceTypeCounts{{DeviceType: models.DeviceTypeLock, Total: 2}}
+	if stats, _ = service.GetDeviceStats(); stats.Total != 1 {
+		t.Errorf("Expected the cached total 1, got %d", stats.Total)
+	}
+
+	// Changes coalesce into a single pending refresh
+	if err := service.DeleteDevice(1, models.ChildrenRefuse); err != nil {
+		t.Fatalf("DeleteDevice failed: %v", err)
+	}
+	if err := service.ClearAlarm(1); err != nil {
+		t.Fatalf("ClearAlarm failed: %v", err)
+	}
+	if pending := len(cache.invalidated); pending != 1 {
+		t.Errorf("Expected one pending refresh, got %d", pending)
+	}
+
+	if _, err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if stats, _ = service.GetDeviceStats(); stats.Total != 2 {
+		t.Errorf("Expected the refreshed total 2, got %d", stats.Total)
+	}
+}
@@ -0,0 +1,86 @@
+// Package systemd implements the parts of the sd_notify protocol the server uses: readiness,
+// stopping and watchdog notifications. Everything is a no-op when not running under systemd.
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notification states understood by systemd
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to the service manager over $NOTIFY_SOCKET. It reports false, with no
+// error, when the socket is not set because the process is not a Type=notify service.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout systemd expects pings within, from WatchdogSec=
+// in the unit file. It is zero when the watchdog is off or meant for another process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+
+	return time.Duration(value) * time.Microsecond, nil
+}
+
+// RunWatchdog pings the watchdog every interval until ctx is cancelled, but only while healthy
+// returns nil, so systemd restarts a process that is running but wedged
+func RunWatchdog(ctx context.Context, interval time.Duration, healthy func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := healthy(ctx); err != nil {
+				log.Printf("Health check failed, withholding watchdog ping: %v", err)
+				continue
+			}
+			if _, err := Notify(Watchdog); err != nil {
+				log.Printf("Error pinging watchdog: %v", err)
+			}
+		}
+	}
+}
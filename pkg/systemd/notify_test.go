@@ -0,0 +1,123 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenNotify stands in for systemd's notification socket, returning the received datagrams
+func listenNotify(t *testing.T) <-chan string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("ListenUnixgram failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	received := make(chan string, 10)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			received <- string(buf[:n])
+		}
+	}()
+
+	return received
+}
+
+func expectNotification(t *testing.T, received <-chan string, expected string) {
+	t.Helper()
+
+	select {
+	case state := <-received:
+		if state != expected {
+			t.Errorf("Expected %q, got %q", expected, state)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected %q to be sent", expected)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	received := listenNotify(t)
+
+	sent, err := Notify(Ready)
+	if err != nil || !sent {
+		t.Fatalf("Expected the notification to be sent, got sent=%t err=%v", sent, err)
+	}
+	expectNotification(t, received, Ready)
+}
+
+func TestNotifyWithoutSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	sent, err := Notify(Ready)
+	if err != nil || sent {
+		t.Errorf("Expected a silent no-op, got sent=%t err=%v", sent, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name        string
+		usec        string
+		pid         string
+		expected    time.Duration
+		expectError bool
+	}{
+		{"Not set", "", "", 0, false},
+		{"Set for this process", "30000000", pid, 30 * time.Second, false},
+		{"Set without a pid", "5000000", "", 5 * time.Second, false},
+		{"Set for another process", "30000000", "1", 0, false},
+		{"Malformed", "soon", "", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tc.usec)
+			t.Setenv("WATCHDOG_PID", tc.pid)
+
+			interval, err := WatchdogInterval()
+			if (err != nil) != tc.expectError {
+				t.Fatalf("Expected error %t, got %v", tc.expectError, err)
+			}
+			if interval != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, interval)
+			}
+		})
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	received := listenNotify(t)
+
+	healthy := make(chan error, 1)
+	healthy <- errors.New("database is locked")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunWatchdog(ctx, 10*time.Millisecond, func(context.Context) error {
+		select {
+		case err := <-healthy:
+			return err
+		default:
+			return nil
+		}
+	})
+
+	// The first tick fails its health check and is skipped; the next is pinged
+	expectNotification(t, received, Watchdog)
+}
@@ -0,0 +1,544 @@
+// Package validation checks device, alarm and maintenance input before it reaches the service.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+// Validation constants
+const (
+	MaxDeviceNameLength      = 100
+	MinDeviceNameLength      = 1
+	MaxOwnerLength           = 50
+	MinOwnerLength           = 1
+	MaxDescriptionLength     = 500
+	MaxLastAlarmReasonLength = 200
+	MinAlarmReasonLength     = 1
+	MaxTriggeredByLength     = 50
+	MaxEventIDLength         = 64
+	MaxReasonCodeLength      = 64
+	MaxFirmwareVersionLength = 64
+	// MaxAlarmRetentionDays is the longest alarm history retention a device can be given; zero,
+	// which keeps the history forever, covers anything longer
+	MaxAlarmRetentionDays = 3650
+	// MinCriticalReasonLength is the shortest reason a CRITICAL alarm is accepted with unflagged
+	MinCriticalReasonLength = 2
+)
+
+// Regex patterns
+var (
+	// Matches alphanumeric characters only (A-Z, a-z, 0-9)
+	alphanumericPattern = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+	// Matches actor identifiers such as "sensor:kitchen-1" or "admin@home"
+	actorPattern = regexp.MustCompile(`^[a-zA-Z0-9_.:@-]+$`)
+
+	// Matches event identifiers such as UUIDs or "boot-3:seq-1042"
+	eventIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+$`)
+
+	// Matches alarm reason codes such as "smoke" or "battery.low". Only lowercase is allowed, so
+	// one reason cannot be catalogued under two spellings.
+	reasonCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+	// Matches firmware versions such as "2.1", "v1.4.0" or "1.4.0-rc.1+build.7"
+	firmwareVersionPattern = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){0,3}(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+	// Matches strings shaped like an email address, where a username is expected
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// ValidationErrors holds validation error messages for each field
+type ValidationErrors map[string]string
+
+// Result is the outcome of validating input. Errors reject it; warnings flag values that are
+// legal but suspicious, and only reject it once promoted by Strict. Messages are in English
+// until the result is localized.
+type Result struct {
+	Errors   ValidationErrors
+	Warnings ValidationErrors
+
+	// errorMessages and warningMessages keep each field's message unrendered, for Localize
+	errorMessages   map[string]message
+	warningMessages map[string]message
+}
+
+func newResult() *Result {
+	return &Result{
+		Errors:          make(ValidationErrors),
+		Warnings:        make(ValidationErrors),
+		errorMessages:   make(map[string]message),
+		warningMessages: make(map[string]message),
+	}
+}
+
+func (r *Result) addError(field string, code Code, args ...any) {
+	r.Errors[field] = Message(DefaultLanguage, code, args...)
+	r.errorMessages[field] = message{code: code, args: args}
+}
+
+func (r *Result) addWarning(field string, code Code, args ...any) {
+	r.Warnings[field] = Message(DefaultLanguage, code, args...)
+	r.warningMessages[field] = message{code: code, args: args}
+}
+
+// RejectUnknownOwner records that field names an owner no device belongs to, suggesting the
+// known owners it may have meant. Whether an owner is known is up to the caller.
+func (r *Result) RejectUnknownOwner(field string, suggestions []string) {
+	if len(suggestions) == 0 {
+		r.addError(field, CodeUnknownOwner)
+		return
+	}
+	r.addError(field, CodeUnknownOwnerSuggested, strings.Join(suggestions, ", "))
+}
+
+// Localize renders the errors and warnings in lang, or in English where the catalog has no
+// translation
+func (r *Result) Localize(lang string) {
+	for field, m := range r.errorMessages {
+		r.Errors[field] = Message(lang, m.code, m.args...)
+	}
+	for field, m := range r.warningMessages {
+		r.Warnings[field] = Message(lang, m.code, m.args...)
+	}
+}
+
+// Valid reports whether the input holds no errors
+func (r *Result) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// nest adds the errors and warnings of other, with each field name prefixed, such as
+// "devices[2].name" for a field of the third item of a list
+func (r *Result) nest(prefix string, other *Result) {
+	for field, m := range other.errorMessages {
+		r.addError(prefix+"."+field, m.code, m.args...)
+	}
+	for field, m := range other.warningMessages {
+		r.addWarning(prefix+"."+field, m.code, m.args...)
+	}
+}
+
+// Strict promotes every warning to an error. A field with both keeps its error.
+func (r *Result) Strict() {
+	for field, warning := range r.Warnings {
+		if _, exists := r.Errors[field]; !exists {
+			r.Errors[field] = warning
+			r.errorMessages[field] = r.warningMessages[field]
+		}
+	}
+	r.Warnings = make(ValidationErrors)
+	r.warningMessages = make(map[string]message)
+}
+
+// AllowedDeviceTypes is the set of device types that devices may be created with or changed to.
+// A nil set allows every type. A type mapped to false is deprecated: existing devices of it are
+// still read, but no device may be created with it or changed to it.
+type AllowedDeviceTypes map[models.DeviceType]bool
+
+// NewAllowedDeviceTypes returns the set holding types, every type when types is empty, less the
+// deprecated ones. It is nil, allowing every type, when both are empty.
+func NewAllowedDeviceTypes(types, deprecated []models.DeviceType) AllowedDeviceTypes {
+	if len(types) == 0 && len(deprecated) == 0 {
+		return nil
+	}
+
+	allowed := make(AllowedDeviceTypes, len(types))
+	if len(types) == 0 {
+		for _, t := range models.GetAllDeviceTypes() {
+			allowed[models.DeviceType(t.ID)] = true
+		}
+	}
+	for _, dt := range types {
+		allowed[dt] = true
+	}
+	for _, dt := range deprecated {
+		allowed[dt] = false
+	}
+
+	return allowed
+}
+
+// Allows checks that dt is a valid device type and in the set
+func (a AllowedDeviceTypes) Allows(dt models.DeviceType) bool {
+	return dt.IsValid() && (a == nil || a[dt])
+}
+
+// Deprecated checks whether dt is a deprecated device type
+func (a AllowedDeviceTypes) Deprecated(dt models.DeviceType) bool {
+	allowed, listed := a[dt]
+	return listed && !allowed
+}
+
+// checkDeviceType adds the error for a device type devices may not be given to result
+func (a AllowedDeviceTypes) checkDeviceType(result *Result, dt models.DeviceType) {
+	if a.Deprecated(dt) {
+		result.addError("device_type", CodeDeviceTypeDeprecated, dt, a.list())
+	} else if !a.Allows(dt) {
+		result.addError("device_type", CodeDeviceTypeNotAllowed, a.list())
+	}
+}
+
+// Types returns the device types the set allows, in the order of GetAllDeviceTypes
+func (a AllowedDeviceTypes) Types() []string {
+	allTypes := models.GetAllDeviceTypes()
+	typeNames := make([]string, 0, len(allTypes))
+	for _, t := range allTypes {
+		if a.Allows(models.DeviceType(t.ID)) {
+			typeNames = append(typeNames, t.ID)
+		}
+	}
+
+	return typeNames
+}
+
+// list names the device types the set allows, for the error given for one it does not
+func (a AllowedDeviceTypes) list() string {
+	return strings.Join(a.Types(), ", ")
+}
+
+// IsValidDeviceName checks if the device name meets criteria
+func IsValidDeviceName(name string) bool {
+	// Check length
+	if len(name) < MinDeviceNameLength || len(name) > MaxDeviceNameLength {
+		return false
+	}
+
+	return alphanumericPattern.MatchString(name)
+}
+
+// NormalizeDeviceName trims a device name and collapses each run of whitespace inside it to a
+// single space, so names differing only in spacing compare equal
+func NormalizeDeviceName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// IsValidOwner checks if the owner field is valid
+func IsValidOwner(owner string) bool {
+	return len(owner) >= MinOwnerLength && len(owner) <= MaxOwnerLength
+}
+
+// IsValidAlarmRetention checks if a device's alarm history retention, in days, is within range
+func IsValidAlarmRetention(days int64) bool {
+	return days >= 0 && days <= MaxAlarmRetentionDays
+}
+
+// IsValidAlarmLevel checks if the alarm level is one of the built-in levels
+func IsValidAlarmLevel(level string) bool {
+	return models.DefaultAlarmLevels().IsValid(level)
+}
+
+// IsValidActor checks if an actor identifier is valid
+func IsValidActor(actor string) bool {
+	if len(actor) > MaxTriggeredByLength {
+		return false
+	}
+
+	return actorPattern.MatchString(actor)
+}
+
+// IsValidEventID checks if a client-supplied alarm event identifier is valid
+func IsValidEventID(eventID string) bool {
+	if len(eventID) > MaxEventIDLength {
+		return false
+	}
+
+	return eventIDPattern.MatchString(eventID)
+}
+
+// IsValidReasonCode checks if an alarm reason code is valid
+func IsValidReasonCode(code string) bool {
+	if len(code) > MaxReasonCodeLength {
+		return false
+	}
+
+	return reasonCodePattern.MatchString(code)
+}
+
+// IsSafeText checks that text which is stored and later displayed holds no markup or control
+// characters: it must be valid UTF-8 without angle brackets, newlines, tabs or other controls
+func IsSafeText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return r == '<' || r == '>' || unicode.IsControl(r)
+	})
+}
+
+// IsBlank checks if text is non-empty but holds nothing except whitespace
+func IsBlank(s string) bool {
+	return s != "" && strings.TrimSpace(s) == ""
+}
+
+// LooksLikeEmail checks if s is shaped like an email address
+func LooksLikeEmail(s string) bool {
+	return emailPattern.MatchString(s)
+}
+
+// ValidateDeviceCreate performs all validations on device creation data
+func ValidateDeviceCreate(device *models.DeviceCreate, allowedTypes AllowedDeviceTypes) *Result {
+	result := newResult()
+
+	if !IsValidDeviceName(device.Name) {
+		result.addError("name", CodeNameInvalid, MinDeviceNameLength, MaxDeviceNameLength)
+	}
+
+	allowedTypes.checkDeviceType(result, device.DeviceType)
+
+	if !IsValidOwner(device.OwnedBy) {
+		result.addError("owned_by", CodeOwnerLength, MinOwnerLength, MaxOwnerLength)
+	} else if LooksLikeEmail(device.OwnedBy) {
+		result.addWarning("owned_by", CodeOwnerLooksLikeEmail)
+	}
+
+	if len(device.Description) > MaxDescriptionLength {
+		result.addError("description", CodeDescriptionTooLong, MaxDescriptionLength)
+	} else if IsBlank(device.Description) {
+		result.addWarning("description", CodeDescriptionBlank)
+	}
+
+	if device.ParentID != nil && *device.ParentID <= 0 {
+		result.addError("parent_id", CodeParentIDInvalid)
+	}
+
+	if device.AlarmRetentionDays != nil && !IsValidAlarmRetention(*device.AlarmRetentionDays) {
+		result.addError("alarm_retention_days", CodeAlarmRetentionInvalid, MaxAlarmRetentionDays)
+	}
+
+	return result
+}
+
+// ValidateAlarmRequest performs all validations on device alarm trigger request, accepting the
+// alarm levels in levels; nil accepts the built-in levels
+func ValidateAlarmRequest(alarm *models.AlarmRequest, levels *models.AlarmLevels) *Result {
+	result := newResult()
+
+	// Validate reason, which may be left out when a reason code gives the catalog's text instead
+	if alarm.ReasonCode == "" {
+		if checkReasonText(result, "reason", alarm.Reason) && levels.Severity(alarm.Level) >= levels.Severity(models.AlarmLevelCritical) &&
+			utf8.RuneCountInString(strings.TrimSpace(alarm.Reason)) < MinCriticalReasonLength {
+			result.addWarning("reason", CodeCriticalReasonTooShort, MinCriticalReasonLength)
+		}
+	} else if alarm.Reason != "" {
+		checkReasonText(result, "reason", alarm.Reason)
+	}
+
+	// Validate level
+	if !levels.IsValid(alarm.Level) {
+		result.addError("level", CodeLevelInvalid, strings.Join(levels.IDs(), ", "))
+	}
+
+	// Validate triggered_by (optional)
+	if alarm.TriggeredBy != "" && !IsValidActor(alarm.TriggeredBy) {
+		result.addError("triggered_by", CodeTriggeredByInvalid, MaxTriggeredByLength)
+	}
+
+	// Validate event_id (optional)
+	if alarm.EventID != "" && !IsValidEventID(alarm.EventID) {
+		result.addError("event_id", CodeEventIDInvalid, MaxEventIDLength)
+	}
+
+	// Validate client_event_id (optional), which takes the same form, UUIDs included
+	if alarm.ClientEventID != "" && !IsValidEventID(alarm.ClientEventID) {
+		result.addError("client_event_id", CodeClientEventIDInvalid, MaxEventIDLength)
+	}
+
+	// Validate reason_code (optional); whether the catalog holds it is up to the service
+	if alarm.ReasonCode != "" && !IsValidReasonCode(alarm.ReasonCode) {
+		result.addError("reason_code", CodeReasonCodeInvalid, MaxReasonCodeLength)
+	}
+
+	return result
+}
+
+// checkReasonText rejects in result's field an alarm reason that is empty, too long or unsafe,
+// and reports whether it was accepted
+func checkReasonText(result *Result, field, reason string) bool {
+	switch {
+	case len(reason) < MinAlarmReasonLength:
+		result.addError(field, CodeReasonEmpty)
+	case len(reason) > MaxLastAlarmReasonLength:
+		result.addError(field, CodeReasonTooLong, MaxLastAlarmReasonLength)
+	case !IsSafeText(reason):
+		result.addError(field, CodeReasonUnsafe)
+	default:
+		return true
+	}
+	return false
+}
+
+// ValidateAlarmReason performs all validations on an entry of the alarm reason catalog, accepting
+// the alarm levels in levels as its level hint
+func ValidateAlarmReason(reason *models.AlarmReason, levels *models.AlarmLevels) *Result {
+	result := newResult()
+
+	if !IsValidReasonCode(reason.Code) {
+		result.addError("code", CodeReasonCodeInvalid, MaxReasonCodeLength)
+	}
+	checkReasonText(result, "text", reason.Text)
+	if reason.LevelHint != "" && !levels.IsValid(reason.LevelHint) {
+		result.addError("level_hint", CodeLevelInvalid, strings.Join(levels.IDs(), ", "))
+	}
+
+	return result
+}
+
+// ReasonCodeRefused returns the result of an alarm the service refused with ErrUnknownReasonCode
+// or ErrReasonCodeRequired, or nil when err is neither
+func ReasonCodeRefused(err error) *Result {
+	result := newResult()
+	switch {
+	case errors.Is(err, models.ErrUnknownReasonCode):
+		result.addError("reason_code", CodeReasonCodeUnknown)
+	case errors.Is(err, models.ErrReasonCodeRequired):
+		result.addError("reason_code", CodeReasonCodeRequired)
+	default:
+		return nil
+	}
+	return result
+}
+
+// ValidateDeviceUpdate performs all validations on device update data
+func ValidateDeviceUpdate(device *models.DeviceUpdate, allowedTypes AllowedDeviceTypes) *Result {
+	result := newResult()
+
+	if device.Name != nil && !IsValidDeviceName(*device.Name) {
+		result.addError("name", CodeNameInvalid, MinDeviceNameLength, MaxDeviceNameLength)
+	}
+
+	if device.OwnedBy != nil && !IsValidOwner(*device.OwnedBy) {
+		result.addError("owned_by", CodeOwnerLength, MinOwnerLength, MaxOwnerLength)
+	} else if device.OwnedBy != nil && LooksLikeEmail(*device.OwnedBy) {
+		result.addWarning("owned_by", CodeOwnerLooksLikeEmail)
+	}
+
+	if device.Description != nil && len(*device.Description) > MaxDescriptionLength {
+		result.addError("description", CodeDescriptionTooLong, MaxDescriptionLength)
+	} else if device.Description != nil && IsBlank(*device.Description) {
+		result.addWarning("description", CodeDescriptionBlank)
+	}
+
+	if device.LastAlarmReason.Valid && len(device.LastAlarmReason.String) > MaxLastAlarmReasonLength {
+		result.addError("last_alarm_reason", CodeLastAlarmReasonTooLong, MaxLastAlarmReasonLength)
+	} else if device.LastAlarmReason.Valid && !IsSafeText(device.LastAlarmReason.String) {
+		result.addError("last_alarm_reason", CodeUnsafeText)
+	}
+
+	if device.DeviceType != nil {
+		allowedTypes.checkDeviceType(result, *device.DeviceType)
+	}
+
+	if device.MaintenanceUntil != nil && !device.MaintenanceUntil.After(time.Now()) {
+		result.addError("maintenance_until", CodeMustBeFuture)
+	}
+
+	if device.ParentID.Valid && device.ParentID.Int64 <= 0 {
+		result.addError("parent_id", CodeParentIDInvalid)
+	}
+
+	if device.AlarmRetentionDays.Valid && !IsValidAlarmRetention(device.AlarmRetentionDays.Int64) {
+		result.addError("alarm_retention_days", CodeAlarmRetentionInvalid, MaxAlarmRetentionDays)
+	}
+
+	return result
+}
+
+// ValidateMaintenanceRequest performs all validations on a maintenance mode request
+func ValidateMaintenanceRequest(req *models.MaintenanceRequest) *Result {
+	result := newResult()
+
+	if req.Until != nil {
+		if !*req.Enabled {
+			result.addError("until", CodeUntilRequiresEnabled)
+		} else if !req.Until.After(time.Now()) {
+			result.addError("until", CodeMustBeFuture)
+		}
+	}
+
+	return result
+}
+
+// ValidateFirmwareUpdateRequest validates the version of a firmware update pushed to a device
+func ValidateFirmwareUpdateRequest(req *models.FirmwareUpdateRequest) *Result {
+	result := newResult()
+
+	if len(req.Version) > MaxFirmwareVersionLength || !firmwareVersionPattern.MatchString(req.Version) {
+		result.addError("version", CodeFirmwareVersionInvalid, MaxFirmwareVersionLength)
+	}
+
+	return result
+}
+
+// ValidateFirmwareReport validates a device's report on its pending firmware update
+func ValidateFirmwareReport(report *models.FirmwareReport) *Result {
+	result := newResult()
+
+	if report.Status != models.FirmwareUpdateSuccess && report.Status != models.FirmwareUpdateFailed {
+		result.addError("status", CodeFirmwareStatusInvalid)
+	}
+
+	return result
+}
+
+// ValidateOwnerRename validates renaming an owner: the new name must be a valid owner other than
+// the old one
+func ValidateOwnerRename(req *models.OwnerRename) *Result {
+	result := newResult()
+
+	switch {
+	case !IsValidOwner(req.To):
+		result.addError("to", CodeOwnerLength, MinOwnerLength, MaxOwnerLength)
+	case req.To == req.From:
+		result.addError("to", CodeOwnerUnchanged)
+	case LooksLikeEmail(req.To):
+		result.addWarning("to", CodeOwnerLooksLikeEmail)
+	}
+
+	return result
+}
+
+// ValidateManifest validates every device of a manifest as it would be created, and rejects
+// entries naming the same device as an earlier one. Fields are reported as "devices[i].field".
+func ValidateManifest(manifest *models.Manifest, allowedTypes AllowedDeviceTypes) *Result {
+	result := newResult()
+
+	if len(manifest.Devices) == 0 {
+		result.addError("devices", CodeManifestEmpty)
+		return result
+	}
+
+	type key struct{ owner, name string }
+	seen := make(map[key]int, len(manifest.Devices))
+	for i, device := range manifest.Devices {
+		prefix := fmt.Sprintf("devices[%d]", i)
+		if device == nil {
+			// A null entry is reported like one with every field missing
+			device = &models.ManifestDevice{}
+			manifest.Devices[i] = device
+		}
+
+		create := models.DeviceCreate{Name: device.Name, DeviceType: device.DeviceType, OwnedBy: device.OwnedBy}
+		if device.Description != nil {
+			create.Description = *device.Description
+		}
+		result.nest(prefix, ValidateDeviceCreate(&create, allowedTypes))
+
+		k := key{device.OwnedBy, device.Name}
+		if first, exists := seen[k]; exists {
+			result.addError(prefix+".name", CodeManifestDuplicate, fmt.Sprintf("devices[%d]", first))
+		} else {
+			seen[k] = i
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,775 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestIsValidDeviceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{"Empty", "", false},
+		{"Valid alphanumeric", "Device123", true},
+		{"Valid letters only", "DeviceName", true},
+		{"Valid numbers only", "123456", true},
+		{"Valid min length", "a", true},
+		{"Valid max length", generateString(MaxDeviceNameLength, 'a'), true},
+		{"Invalid exceeds max length", generateString(MaxDeviceNameLength+1, 'a'), false},
+		{"Invalid with space", "Device Name", false},
+		{"Invalid with hyphen", "Device-123", false},
+		{"Invalid with underscore", "Device_123", false},
+		{"Invalid with special chars", "Device@123", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := IsValidDeviceName(tc.input)
+			if result != tc.expected {
+				t.Errorf("IsValidDeviceName(%q) = %v; expected %v", tc.input, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeDeviceName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Kitchen", "Kitchen"},
+		{"  Kitchen\t", "Kitchen"},
+		{"Front   Door", "Front Door"},
+		{" Front \t\n Door ", "Front Door"},
+		{"   ", ""},
+	}
+
+	for _, tc := range tests {
+		if got := NormalizeDeviceName(tc.input); got != tc.expected {
+			t.Errorf("NormalizeDeviceName(%q) = %q; expected %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestIsValidOwner(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{"Empty", "", false},
+		{"Valid", "owner123", true},
+		{"Valid min length", "a", true},
+		{"Valid max length", generateString(MaxOwnerLength, 'a'), true},
+		{"Invalid exceeds max length", generateString(MaxOwnerLength+1, 'a'), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := IsValidOwner(tc.input)
+			if result != tc.expected {
+				t.Errorf("IsValidOwner(%q) = %v; expected %v", tc.input, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestValidateDeviceCreate(t *testing.T) {
+	// Setup valid device type
+	validDeviceType := models.DeviceTypeCamera
+	invalidDeviceType := models.DeviceType("INVALID_TYPE")
+
+	tests := []struct {
+		name         string
+		deviceCreate models.DeviceCreate
+		expectValid  bool
+		expectErrors []string
+	}{
+		{
+			name: "Valid device",
+			deviceCreate: models.DeviceCreate{
+				Name:        "Device123",
+				Description: "Test device",
+				DeviceType:  validDeviceType,
+				OwnedBy:     "owner1",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Invalid name with special character",
+			deviceCreate: models.DeviceCreate{
+				Name:        "Device@123",
+				Description: "Test device",
+				DeviceType:  validDeviceType,
+				OwnedBy:     "owner1",
+			},
+			expectValid:  false,
+			expectErrors: []string{"name"},
+		},
+		{
+			name: "Invalid device type",
+			deviceCreate: models.DeviceCreate{
+				Name:        "Device123",
+				Description: "Test device",
+				DeviceType:  invalidDeviceType,
+				OwnedBy:     "owner1",
+			},
+			expectValid:  false,
+			expectErrors: []string{"device_type"},
+		},
+		{
+			name: "Invalid owner (empty)",
+			deviceCreate: models.DeviceCreate{
+				Name:        "Device123",
+				Description: "Test device",
+				DeviceType:  validDeviceType,
+				OwnedBy:     "",
+			},
+			expectValid:  false,
+			expectErrors: []string{"owned_by"},
+		},
+		{
+			name: "Invalid description (too long)",
+			deviceCreate: models.DeviceCreate{
+				Name:        "Device123",
+				Description: generateString(MaxDescriptionLength+1, 'a'),
+				DeviceType:  validDeviceType,
+				OwnedBy:     "owner1",
+			},
+			expectValid:  false,
+			expectErrors: []string{"description"},
+		},
+		{
+			name: "Multiple validation errors",
+			deviceCreate: models.DeviceCreate{
+				Name:        "Device@123",
+				Description: generateString(MaxDescriptionLength+1, 'a'),
+				DeviceType:  invalidDeviceType,
+				OwnedBy:     "",
+			},
+			expectValid:  false,
+			expectErrors: []string{"name", "device_type", "owned_by", "description"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ValidateDeviceCreate(&tc.deviceCreate, nil)
+			valid, errors := result.Valid(), result.Errors
+
+			if valid != tc.expectValid {
+				t.Errorf("ValidateDeviceCreate() valid = %v, expected %v", valid, tc.expectValid)
+			}
+
+			if !tc.expectValid {
+				for _, field := range tc.expectErrors {
+					if _, exists := errors[field]; !exists {
+						t.Errorf("Expected error for field %q but none was found", field)
+					}
+				}
+
+				if len(errors) != len(tc.expectErrors) {
+					t.Errorf("Got %d errors, expected %d", len(errors), len(tc.expectErrors))
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedDeviceTypes(t *testing.T) {
+	allowed := NewAllowedDeviceTypes([]models.DeviceType{models.DeviceTypeCamera, models.DeviceTypeLock}, nil)
+
+	device := models.DeviceCreate{Name: "Device123", DeviceType: models.DeviceTypeThermostat, OwnedBy: "owner1"}
+	result := ValidateDeviceCreate(&device, allowed)
+	if result.Valid() {
+		t.Fatal("Expected a type outside the allowed set to be rejected")
+	}
+	if result.Errors["device_type"] != "must be one of: CAMERA, LOCK" {
+		t.Errorf("Expected the error to list the allowed types, got %q", result.Errors["device_type"])
+	}
+
+	device.DeviceType = models.DeviceTypeLock
+	if result := ValidateDeviceCreate(&device, allowed); !result.Valid() {
+		t.Errorf("Expected an allowed type to be valid, got %v", result.Errors)
+	}
+
+	thermostat := models.DeviceTypeThermostat
+	if ValidateDeviceUpdate(&models.DeviceUpdate{DeviceType: &thermostat}, allowed).Valid() {
+		t.Error("Expected changing to a type outside the allowed set to be rejected")
+	}
+
+	if NewAllowedDeviceTypes(nil, nil) != nil || !NewAllowedDeviceTypes(nil, nil).Allows(thermostat) {
+		t.Error("Expected an empty set to allow every type")
+	}
+	if NewAllowedDeviceTypes(nil, nil).Allows("TOASTER") {
+		t.Error("Expected an unknown type never to be allowed")
+	}
+
+	retiring := NewAllowedDeviceTypes(nil, []models.DeviceType{models.DeviceTypeUnknown})
+	if !retiring.Allows(thermostat) || retiring.Allows(models.DeviceTypeUnknown) || !retiring.Deprecated(models.DeviceTypeUnknown) {
+		t.Error("Expected every type but the deprecated one to be allowed")
+	}
+	unknown := models.DeviceTypeUnknown
+	result = ValidateDeviceUpdate(&models.DeviceUpdate{DeviceType: &unknown}, retiring)
+	if result.Valid() || !strings.HasPrefix(result.Errors["device_type"], "UNKNOWN is deprecated; must be one of: CAMERA") {
+		t.Errorf("Expected changing to a deprecated type to be rejected, got %v", result.Errors)
+	}
+}
+
+func TestValidateDeviceUpdate(t *testing.T) {
+	// Setup valid device type
+	validDeviceType := models.DeviceTypeCamera
+	invalidDeviceType := models.DeviceType("INVALID_TYPE")
+
+	// Helper to create pointer values
+	strPtr := func(s string) *string { return &s }
+	boolPtr := func(b bool) *bool { return &b }
+	deviceTypePtr := func(dt models.DeviceType) *models.DeviceType { return &dt }
+
+	tests := []struct {
+		name         string
+		deviceUpdate models.DeviceUpdate
+		expectValid  bool
+		expectErrors []string
+	}{
+		{
+			name: "Valid update (all fields)",
+			deviceUpdate: models.DeviceUpdate{
+				Name:            strPtr("Device123"),
+				Description:     strPtr("Updated description"),
+				IsOnline:        boolPtr(true),
+				OwnedBy:         strPtr("newowner"),
+				DeviceType:      deviceTypePtr(validDeviceType),
+				LastAlarmReason: models.SetString("Test alarm"),
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Valid update (partial)",
+			deviceUpdate: models.DeviceUpdate{
+				Name:     strPtr("Device123"),
+				IsOnline: boolPtr(false),
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Invalid name",
+			deviceUpdate: models.DeviceUpdate{
+				Name: strPtr("Invalid Name!"),
+			},
+			expectValid:  false,
+			expectErrors: []string{"name"},
+		},
+		{
+			name: "Invalid owner",
+			deviceUpdate: models.DeviceUpdate{
+				OwnedBy: strPtr(generateString(MaxOwnerLength+1, 'a')),
+			},
+			expectValid:  false,
+			expectErrors: []string{"owned_by"},
+		},
+		{
+			name: "Invalid description",
+			deviceUpdate: models.DeviceUpdate{
+				Description: strPtr(generateString(MaxDescriptionLength+1, 'a')),
+			},
+			expectValid:  false,
+			expectErrors: []string{"description"},
+		},
+		{
+			name: "Invalid device type",
+			deviceUpdate: models.DeviceUpdate{
+				DeviceType: deviceTypePtr(invalidDeviceType),
+			},
+			expectValid:  false,
+			expectErrors: []string{"device_type"},
+		},
+		{
+			name: "Invalid last alarm reason",
+			deviceUpdate: models.DeviceUpdate{
+				LastAlarmReason: models.SetString(generateString(MaxLastAlarmReasonLength+1, 'a')),
+			},
+			expectValid:  false,
+			expectErrors: []string{"last_alarm_reason"},
+		},
+		{
+			name: "Markup in last alarm reason",
+			deviceUpdate: models.DeviceUpdate{
+				LastAlarmReason: models.SetString("<img src=x onerror=alert(1)>"),
+			},
+			expectValid:  false,
+			expectErrors: []string{"last_alarm_reason"},
+		},
+		{
+			name:         "Detaching from the parent",
+			deviceUpdate: models.DeviceUpdate{ParentID: models.NullableInt64{Set: true}},
+			expectValid:  true,
+		},
+		{
+			name:         "Invalid parent",
+			deviceUpdate: models.DeviceUpdate{ParentID: models.SetInt64(0)},
+			expectValid:  false,
+			expectErrors: []string{"parent_id"},
+		},
+		{
+			name:         "Keeping alarms forever",
+			deviceUpdate: models.DeviceUpdate{AlarmRetentionDays: models.SetInt64(0)},
+			expectValid:  true,
+		},
+		{
+			name:         "Alarm retention out of range",
+			deviceUpdate: models.DeviceUpdate{AlarmRetentionDays: models.SetInt64(MaxAlarmRetentionDays + 1)},
+			expectValid:  false,
+			expectErrors: []string{"alarm_retention_days"},
+		},
+		{
+			name: "Multiple validation errors",
+			deviceUpdate: models.DeviceUpdate{
+				Name:            strPtr("Invalid Name!"),
+				Description:     strPtr(generateString(MaxDescriptionLength+1, 'a')),
+				OwnedBy:         strPtr(generateString(MaxOwnerLength+1, 'a')),
+				DeviceType:      deviceTypePtr(invalidDeviceType),
+				LastAlarmReason: models.SetString(generateString(MaxLastAlarmReasonLength+1, 'a')),
+			},
+			expectValid:  false,
+			expectErrors: []string{"name", "description", "owned_by", "device_type", "last_alarm_reason"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ValidateDeviceUpdate(&tc.deviceUpdate, nil)
+			valid, errors := result.Valid(), result.Errors
+
+			if valid != tc.expectValid {
+				t.Errorf("ValidateDeviceUpdate() valid = %v, expected %v", valid, tc.expectValid)
+			}
+
+			if !tc.expectValid {
+				for _, field := range tc.expectErrors {
+					if _, exists := errors[field]; !exists {
+						t.Errorf("Expected error for field %q but none was found", field)
+					}
+				}
+
+				if len(errors) != len(tc.expectErrors) {
+					t.Errorf("Got %d errors, expected %d", len(errors), len(tc.expectErrors))
+				}
+			}
+		})
+	}
+}
+
+// Helper function to generate strings of specified length
+func generateString(length int, char rune) string {
+	runes := make([]rune, length)
+	for i := range runes {
+		runes[i] = char
+	}
+	return string(runes)
+}
+
+func TestValidateAlarmRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		alarmRequest models.AlarmRequest
+		expectValid  bool
+		expectErrors []string
+	}{
+		{
+			name: "Valid alarm request",
+			alarmRequest: models.AlarmRequest{
+				Reason: "Smoke detected",
+				Level:  "WARNING",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Empty reason",
+			alarmRequest: models.AlarmRequest{
+				Reason: "",
+				Level:  "WARNING",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason"},
+		},
+		{
+			name: "UUID event id",
+			alarmRequest: models.AlarmRequest{
+				Reason:  "Smoke detected",
+				Level:   "WARNING",
+				EventID: "3f2b8c1e-9a4d-4e6f-8b1a-2c3d4e5f6a7b",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Event id with spaces",
+			alarmRequest: models.AlarmRequest{
+				Reason:  "Smoke detected",
+				Level:   "WARNING",
+				EventID: "event 1",
+			},
+			expectValid:  false,
+			expectErrors: []string{"event_id"},
+		},
+		{
+			name: "Event id too long",
+			alarmRequest: models.AlarmRequest{
+				Reason:  "Smoke detected",
+				Level:   "WARNING",
+				EventID: generateString(MaxEventIDLength+1, 'e'),
+			},
+			expectValid:  false,
+			expectErrors: []string{"event_id"},
+		},
+		{
+			name: "UUID client event id",
+			alarmRequest: models.AlarmRequest{
+				Reason:        "Smoke detected",
+				Level:         "WARNING",
+				ClientEventID: "3f2b8c1e-9a4d-4e6f-8b1a-2c3d4e5f6a7b",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Client event id with a slash",
+			alarmRequest: models.AlarmRequest{
+				Reason:        "Smoke detected",
+				Level:         "WARNING",
+				ClientEventID: "log/1042",
+			},
+			expectValid:  false,
+			expectErrors: []string{"client_event_id"},
+		},
+		{
+			name: "Client event id too long",
+			alarmRequest: models.AlarmRequest{
+				Reason:        "Smoke detected",
+				Level:         "WARNING",
+				ClientEventID: generateString(MaxEventIDLength+1, 'c'),
+			},
+			expectValid:  false,
+			expectErrors: []string{"client_event_id"},
+		},
+		{
+			name: "Reason code without a reason",
+			alarmRequest: models.AlarmRequest{
+				Level:      "CRITICAL",
+				ReasonCode: "battery.low",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Reason code in uppercase",
+			alarmRequest: models.AlarmRequest{
+				Level:      "WARNING",
+				ReasonCode: "Smoke",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason_code"},
+		},
+		{
+			name: "Reason code with an unsafe reason",
+			alarmRequest: models.AlarmRequest{
+				Reason:     "<b>smoke</b>",
+				Level:      "WARNING",
+				ReasonCode: "smoke",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason"},
+		},
+		{
+			name: "Script in reason",
+			alarmRequest: models.AlarmRequest{
+				Reason: "<script>alert(1)</script>",
+				Level:  "WARNING",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason"},
+		},
+		{
+			name: "Newlines in reason",
+			alarmRequest: models.AlarmRequest{
+				Reason: "Smoke detected\nFAKE: all clear\r\n",
+				Level:  "WARNING",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason"},
+		},
+		{
+			name: "Punctuation and accents in reason",
+			alarmRequest: models.AlarmRequest{
+				Reason: "Température au-dessus du seuil: 21°C & \"stable\"",
+				Level:  "INFO",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Reason too long",
+			alarmRequest: models.AlarmRequest{
+				Reason: generateString(MaxLastAlarmReasonLength+1, 'a'),
+				Level:  "CRITICAL",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason"},
+		},
+		{
+			name: "Invalid level",
+			alarmRequest: models.AlarmRequest{
+				Reason: "Motion detected",
+				Level:  "LOW", // Not a valid level
+			},
+			expectValid:  false,
+			expectErrors: []string{"level"},
+		},
+		{
+			name: "Valid triggered_by",
+			alarmRequest: models.AlarmRequest{
+				Reason:      "Smoke detected",
+				Level:       "WARNING",
+				TriggeredBy: "sensor:hallway-1",
+			},
+			expectValid:  true,
+			expectErrors: nil,
+		},
+		{
+			name: "Invalid triggered_by characters",
+			alarmRequest: models.AlarmRequest{
+				Reason:      "Smoke detected",
+				Level:       "WARNING",
+				TriggeredBy: "sensor kitchen",
+			},
+			expectValid:  false,
+			expectErrors: []string{"triggered_by"},
+		},
+		{
+			name: "triggered_by too long",
+			alarmRequest: models.AlarmRequest{
+				Reason:      "Smoke detected",
+				Level:       "WARNING",
+				TriggeredBy: generateString(MaxTriggeredByLength+1, 'a'),
+			},
+			expectValid:  false,
+			expectErrors: []string{"triggered_by"},
+		},
+		{
+			name: "Multiple validation errors",
+			alarmRequest: models.AlarmRequest{
+				Reason: "",
+				Level:  "INVALID_LEVEL",
+			},
+			expectValid:  false,
+			expectErrors: []string{"reason", "level"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ValidateAlarmRequest(&tc.alarmRequest, nil)
+			valid, errors := result.Valid(), result.Errors
+
+			if valid != tc.expectValid {
+				t.Errorf("ValidateAlarmRequest() valid = %v, expected %v", valid, tc.expectValid)
+			}
+
+			if !tc.expectValid {
+				for _, field := range tc.expectErrors {
+					if _, exists := errors[field]; !exists {
+						t.Errorf("Expected error for field %q but none was found", field)
+					}
+				}
+
+				if len(errors) != len(tc.expectErrors) {
+					t.Errorf("Got %d errors, expected %d", len(errors), len(tc.expectErrors))
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAlarmReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		reason       models.AlarmReason
+		expectErrors []string
+	}{
+		{"Valid", models.AlarmReason{Code: "smoke", Text: "Smoke detected", LevelHint: "CRITICAL"}, nil},
+		{"No level hint", models.AlarmReason{Code: "door_open", Text: "Door left open"}, nil},
+		{"Code starting with punctuation", models.AlarmReason{Code: "-smoke", Text: "Smoke detected"}, []string{"code"}},
+		{"Code too long", models.AlarmReason{Code: generateString(MaxReasonCodeLength+1, 'c'), Text: "Smoke detected"}, []string{"code"}},
+		{"Empty text", models.AlarmReason{Code: "smoke"}, []string{"text"}},
+		{"Unknown level hint", models.AlarmReason{Code: "smoke", Text: "Smoke detected", LevelHint: "LOUD"}, []string{"level_hint"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ValidateAlarmReason(&tc.reason, nil)
+			if len(result.Errors) != len(tc.expectErrors) {
+				t.Errorf("Expected errors for %v, got %v", tc.expectErrors, result.Errors)
+			}
+			for _, field := range tc.expectErrors {
+				if _, exists := result.Errors[field]; !exists {
+					t.Errorf("Expected error for field %q but none was found", field)
+				}
+			}
+		})
+	}
+}
+
+func TestIsSafeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{"Plain", "Smoke detected in kitchen", true},
+		{"Punctuation and accents", "Température: 21°C & \"stable\"", true},
+		{"Script tag", "<script>alert(1)</script>", false},
+		{"Lone angle bracket", "temp > 50", false},
+		{"Newline", "line one\nline two", false},
+		{"Carriage return", "done\r", false},
+		{"Tab", "a\tb", false},
+		{"Escape sequence", "\x1b[31mred", false},
+		{"Unicode control", "a\u0085b", false},
+		{"Invalid UTF-8", "bad\xffbyte", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsSafeText(tc.text); got != tc.expected {
+				t.Errorf("IsSafeText(%q) = %v; expected %v", tc.text, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestValidateFirmwareUpdateRequest(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.4.0", true},
+		{"v2.1", true},
+		{"10", true},
+		{"1.4.0-rc.1+build.7", true},
+		{"", false},
+		{"latest", false},
+		{"1.4.0.1.2", false},
+		{"1..4", false},
+		{"1.4.0 ", false},
+		{"1." + strings.Repeat("0", MaxFirmwareVersionLength), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.version, func(t *testing.T) {
+			if valid := ValidateFirmwareUpdateRequest(&models.FirmwareUpdateRequest{Version: tc.version}).Valid(); valid != tc.expected {
+				t.Errorf("Version %q valid = %v; expected %v", tc.version, valid, tc.expected)
+			}
+		})
+	}
+
+	for status, expected := range map[string]bool{"success": true, "failed": true, "pending": false, "SUCCESS": false} {
+		if valid := ValidateFirmwareReport(&models.FirmwareReport{Status: status}).Valid(); valid != expected {
+			t.Errorf("Status %q valid = %v; expected %v", status, valid, expected)
+		}
+	}
+}
+
+func TestValidateOwnerRename(t *testing.T) {
+	tests := []struct {
+		from, to string
+		valid    bool
+		warned   bool
+	}{
+		{"alice", "alicia", true, false},
+		{"alice", "alicia@example.com", true, true},
+		{"alice", "alice", false, false},
+		{"alice", "", false, false},
+		{"alice", strings.Repeat("a", MaxOwnerLength+1), false, false},
+	}
+
+	for _, tc := range tests {
+		result := ValidateOwnerRename(&models.OwnerRename{From: tc.from, To: tc.to})
+		if result.Valid() != tc.valid {
+			t.Errorf("%q -> %q valid = %v; expected %v", tc.from, tc.to, result.Valid(), tc.valid)
+		}
+		if warned := result.Warnings["to"] != ""; warned != tc.warned {
+			t.Errorf("%q -> %q warned = %v; expected %v", tc.from, tc.to, warned, tc.warned)
+		}
+	}
+}
+
+func TestValidationWarnings(t *testing.T) {
+	blank := "   "
+	email := "jane@example.com"
+
+	tests := []struct {
+		name           string
+		result         *Result
+		expectWarnings []string
+	}{
+		{
+			name:           "Create with blank description and email owner",
+			result:         ValidateDeviceCreate(&models.DeviceCreate{Name: "Device1", DeviceType: models.DeviceTypeLock, OwnedBy: email, Description: blank}, nil),
+			expectWarnings: []string{"owned_by", "description"},
+		},
+		{
+			name:   "Create with username owner and empty description",
+			result: ValidateDeviceCreate(&models.DeviceCreate{Name: "Device1", DeviceType: models.DeviceTypeLock, OwnedBy: "jane"}, nil),
+		},
+		{
+			name:           "Update with blank description and email owner",
+			result:         ValidateDeviceUpdate(&models.DeviceUpdate{OwnedBy: &email, Description: &blank}, nil),
+			expectWarnings: []string{"owned_by", "description"},
+		},
+		{
+			name:           "Critical alarm with one-character reason",
+			result:         ValidateAlarmRequest(&models.AlarmRequest{Level: models.AlarmLevelCritical, Reason: "x"}, nil),
+			expectWarnings: []string{"reason"},
+		},
+		{
+			name:   "Info alarm with one-character reason",
+			result: ValidateAlarmRequest(&models.AlarmRequest{Level: models.AlarmLevelInfo, Reason: "x"}, nil),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.result.Valid() {
+				t.Fatalf("Expected warnings alone to leave the input valid, got errors %v", tc.result.Errors)
+			}
+			if len(tc.result.Warnings) != len(tc.expectWarnings) {
+				t.Errorf("Got warnings %v, expected %v", tc.result.Warnings, tc.expectWarnings)
+			}
+			for _, field := range tc.expectWarnings {
+				if _, exists := tc.result.Warnings[field]; !exists {
+					t.Errorf("Expected warning for field %q but none was found", field)
+				}
+			}
+		})
+	}
+}
+
+func TestResultStrict(t *testing.T) {
+	result := ValidateDeviceCreate(&models.DeviceCreate{Name: "", DeviceType: models.DeviceTypeLock, OwnedBy: "jane@example.com"}, nil)
+	result.Strict()
+
+	if result.Valid() || len(result.Warnings) != 0 {
+		t.Fatalf("Expected warnings promoted to errors, got %+v", result)
+	}
+	if result.Errors["owned_by"] != Message(DefaultLanguage, CodeOwnerLooksLikeEmail) || result.Errors["name"] == "" {
+		t.Errorf("Expected both the error and the promoted warning, got %v", result.Errors)
+	}
+}
@@ -0,0 +1,219 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code identifies a validation message independently of the language it is shown in
+type Code string
+
+// Validation message codes
+const (
+	CodeNameInvalid            Code = "name_invalid"
+	CodeDeviceTypeNotAllowed   Code = "device_type_not_allowed"
+	CodeDeviceTypeDeprecated   Code = "device_type_deprecated"
+	CodeOwnerLength            Code = "owner_length"
+	CodeDescriptionTooLong     Code = "description_too_long"
+	CodeLastAlarmReasonTooLong Code = "last_alarm_reason_too_long"
+	CodeUnsafeText             Code = "unsafe_text"
+	CodeMustBeFuture           Code = "must_be_future"
+	CodeUntilRequiresEnabled   Code = "until_requires_enabled"
+	CodeReasonEmpty            Code = "reason_empty"
+	CodeReasonTooLong          Code = "reason_too_long"
+	CodeReasonUnsafe           Code = "reason_unsafe"
+	CodeLevelInvalid           Code = "level_invalid"
+	CodeTriggeredByInvalid     Code = "triggered_by_invalid"
+	CodeEventIDInvalid         Code = "event_id_invalid"
+	CodeClientEventIDInvalid   Code = "client_event_id_invalid"
+	CodeDescriptionBlank       Code = "description_blank"
+	CodeOwnerLooksLikeEmail    Code = "owner_looks_like_email"
+	CodeCriticalReasonTooShort Code = "critical_reason_too_short"
+	CodeManifestEmpty          Code = "manifest_empty"
+	CodeManifestDuplicate      Code = "manifest_duplicate"
+	CodeFirmwareVersionInvalid Code = "firmware_version_invalid"
+	CodeFirmwareStatusInvalid  Code = "firmware_status_invalid"
+	CodeOwnerUnchanged         Code = "owner_unchanged"
+	CodeUnknownOwner           Code = "unknown_owner"
+	CodeUnknownOwnerSuggested  Code = "unknown_owner_suggested"
+	CodeParentIDInvalid        Code = "parent_id_invalid"
+	CodeAlarmRetentionInvalid  Code = "alarm_retention_invalid"
+	CodeReasonCodeInvalid      Code = "reason_code_invalid"
+	CodeReasonCodeUnknown      Code = "reason_code_unknown"
+	CodeReasonCodeRequired     Code = "reason_code_required"
+)
+
+// DefaultLanguage is the language messages are given in when no requested language is known,
+// and the one a catalog missing a message falls back to
+const DefaultLanguage = "en"
+
+// catalogs holds the message formats of each supported language, keyed by code. Formats take
+// the same arguments in every language.
+var catalogs = map[string]map[Code]string{
+	"en": {
+		CodeNameInvalid:            "must be between %d-%d characters and contain only alphanumeric characters (A-Z, a-z, 0-9)",
+		CodeDeviceTypeNotAllowed:   "must be one of: %s",
+		CodeDeviceTypeDeprecated:   "%s is deprecated; must be one of: %s",
+		CodeOwnerLength:            "must be between %d-%d characters",
+		CodeDescriptionTooLong:     "must not exceed %d characters",
+		CodeLastAlarmReasonTooLong: "must not exceed %d characters",
+		CodeUnsafeText:             "must not contain angle brackets (< >) or control characters such as newlines",
+		CodeMustBeFuture:           "must be in the future",
+		CodeUntilRequiresEnabled:   "can only be set when enabling maintenance",
+		CodeReasonEmpty:            "reason cannot be empty",
+		CodeReasonTooLong:          "reason must not exceed %d characters",
+		CodeReasonUnsafe:           "reason must not contain angle brackets (< >) or control characters such as newlines",
+		CodeLevelInvalid:           "level must be one of: %s",
+		CodeTriggeredByInvalid:     "triggered_by must not exceed %d characters and contain only letters, digits and _ . : @ -",
+		CodeEventIDInvalid:         "event_id must not exceed %d characters and contain only letters, digits and _ . : -",
+		CodeClientEventIDInvalid:   "client_event_id must not exceed %d characters and contain only letters, digits and _ . : -",
+		CodeDescriptionBlank:       "is only whitespace",
+		CodeOwnerLooksLikeEmail:    "looks like an email address; owners are usually usernames",
+		CodeCriticalReasonTooShort: "reason is shorter than %d characters for a CRITICAL alarm",
+		CodeManifestEmpty:          "must list at least one device",
+		CodeManifestDuplicate:      "duplicates %s, with the same owner and name",
+		CodeFirmwareVersionInvalid: "must be a version such as 1.4.0 of at most %d characters",
+		CodeFirmwareStatusInvalid:  "must be one of: success, failed",
+		CodeOwnerUnchanged:         "must differ from the owner being renamed",
+		CodeUnknownOwner:           "is not a known owner",
+		CodeUnknownOwnerSuggested:  "is not a known owner; did you mean: %s",
+		CodeParentIDInvalid:        "must be the positive ID of a device",
+		CodeAlarmRetentionInvalid:  "must be a number of days between 0, to keep alarms forever, and %d",
+		CodeReasonCodeInvalid:      "must not exceed %d characters and contain only lowercase letters, digits and _ . -, starting with a letter or digit",
+		CodeReasonCodeUnknown:      "is not in the alarm reason catalog",
+		CodeReasonCodeRequired:     "is required; free-text reasons are not accepted",
+	},
+	"es": {
+		CodeNameInvalid:            "debe tener entre %d y %d caracteres y contener solo caracteres alfanuméricos (A-Z, a-z, 0-9)",
+		CodeDeviceTypeNotAllowed:   "debe ser uno de: %s",
+		CodeDeviceTypeDeprecated:   "%s está obsoleto; debe ser uno de: %s",
+		CodeOwnerLength:            "debe tener entre %d y %d caracteres",
+		CodeDescriptionTooLong:     "no debe superar los %d caracteres",
+		CodeLastAlarmReasonTooLong: "no debe superar los %d caracteres",
+		CodeUnsafeText:             "no debe contener corchetes angulares (< >) ni caracteres de control como saltos de línea",
+		CodeMustBeFuture:           "debe estar en el futuro",
+		CodeUntilRequiresEnabled:   "solo se puede indicar al activar el mantenimiento",
+		CodeReasonEmpty:            "el motivo no puede estar vacío",
+		CodeReasonTooLong:          "el motivo no debe superar los %d caracteres",
+		CodeReasonUnsafe:           "el motivo no debe contener corchetes angulares (< >) ni caracteres de control como saltos de línea",
+		CodeLevelInvalid:           "el nivel debe ser uno de: %s",
+		CodeTriggeredByInvalid:     "triggered_by no debe superar los %d caracteres y solo puede contener letras, dígitos y _ . : @ -",
+		CodeEventIDInvalid:         "event_id no debe superar los %d caracteres y solo puede contener letras, dígitos y _ . : -",
+		CodeClientEventIDInvalid:   "client_event_id no debe superar los %d caracteres y solo puede contener letras, dígitos y _ . : -",
+		CodeDescriptionBlank:       "solo contiene espacios en blanco",
+		CodeOwnerLooksLikeEmail:    "parece una dirección de correo electrónico; los propietarios suelen ser nombres de usuario",
+		CodeCriticalReasonTooShort: "el motivo tiene menos de %d caracteres para una alarma CRITICAL",
+		CodeManifestEmpty:          "debe incluir al menos un dispositivo",
+		CodeManifestDuplicate:      "duplica %s, con el mismo propietario y nombre",
+		CodeFirmwareVersionInvalid: "debe ser una versión como 1.4.0 de %d caracteres como máximo",
+		CodeFirmwareStatusInvalid:  "debe ser uno de: success, failed",
+		CodeOwnerUnchanged:         "debe ser distinto del propietario que se renombra",
+		CodeUnknownOwner:           "no es un propietario conocido",
+		CodeUnknownOwnerSuggested:  "no es un propietario conocido; ¿quiso decir: %s?",
+		CodeParentIDInvalid:        "debe ser el ID positivo de un dispositivo",
+		CodeAlarmRetentionInvalid:  "debe ser un número de días entre 0, para conservar las alarmas siempre, y %d",
+		CodeReasonCodeInvalid:      "no debe superar los %d caracteres y solo puede contener letras minúsculas, dígitos y _ . -, empezando por una letra o un dígito",
+		CodeReasonCodeUnknown:      "no está en el catálogo de motivos de alarma",
+		CodeReasonCodeRequired:     "es obligatorio; no se aceptan motivos de texto libre",
+	},
+	"fr": {
+		CodeNameInvalid:            "doit comporter entre %d et %d caractères et ne contenir que des caractères alphanumériques (A-Z, a-z, 0-9)",
+		CodeDeviceTypeNotAllowed:   "doit être l'un de : %s",
+		CodeDeviceTypeDeprecated:   "%s est obsolète ; doit être l'un de : %s",
+		CodeOwnerLength:            "doit comporter entre %d et %d caractères",
+		CodeDescriptionTooLong:     "ne doit pas dépasser %d caractères",
+		CodeLastAlarmReasonTooLong: "ne doit pas dépasser %d caractères",
+		CodeUnsafeText:             "ne doit pas contenir de chevrons (< >) ni de caractères de contrôle comme des retours à la ligne",
+		CodeMustBeFuture:           "doit être dans le futur",
+		CodeUntilRequiresEnabled:   "ne peut être indiqué qu'à l'activation de la maintenance",
+		CodeReasonEmpty:            "le motif ne peut pas être vide",
+		CodeReasonTooLong:          "le motif ne doit pas dépasser %d caractères",
+		CodeReasonUnsafe:           "le motif ne doit pas contenir de chevrons (< >) ni de caractères de contrôle comme des retours à la ligne",
+		CodeLevelInvalid:           "le niveau doit être l'un de : %s",
+		CodeTriggeredByInvalid:     "triggered_by ne doit pas dépasser %d caractères et ne contenir que des lettres, des chiffres et _ . : @ -",
+		CodeEventIDInvalid:         "event_id ne doit pas dépasser %d caractères et ne contenir que des lettres, des chiffres et _ . : -",
+		CodeClientEventIDInvalid:   "client_event_id ne doit pas dépasser %d caractères et ne contenir que des lettres, des chiffres et _ . : -",
+		CodeDescriptionBlank:       "ne contient que des espaces",
+		CodeOwnerLooksLikeEmail:    "ressemble à une adresse e-mail ; les propriétaires sont généralement des noms d'utilisateur",
+		CodeCriticalReasonTooShort: "le motif fait moins de %d caractères pour une alarme CRITICAL",
+		CodeManifestEmpty:          "doit lister au moins un appareil",
+		CodeManifestDuplicate:      "fait double emploi avec %s, avec le même propriétaire et le même nom",
+		CodeFirmwareVersionInvalid: "doit être une version comme 1.4.0 d'au plus %d caractères",
+		CodeFirmwareStatusInvalid:  "doit être l'un de : success, failed",
+		CodeOwnerUnchanged:         "doit être différent du propriétaire renommé",
+		CodeUnknownOwner:           "n'est pas un propriétaire connu",
+		CodeUnknownOwnerSuggested:  "n'est pas un propriétaire connu ; vouliez-vous dire : %s ?",
+		CodeParentIDInvalid:        "doit être l'identifiant positif d'un appareil",
+		CodeAlarmRetentionInvalid:  "doit être un nombre de jours entre 0, pour conserver les alarmes indéfiniment, et %d",
+		CodeReasonCodeInvalid:      "ne doit pas dépasser %d caractères et ne contenir que des lettres minuscules, des chiffres et _ . -, en commençant par une lettre ou un chiffre",
+		CodeReasonCodeUnknown:      "ne figure pas dans le catalogue des motifs d'alarme",
+		CodeReasonCodeRequired:     "est obligatoire ; les motifs en texte libre ne sont pas acceptés",
+	},
+	"de": {
+		CodeNameInvalid:            "muss zwischen %d und %d Zeichen lang sein und darf nur alphanumerische Zeichen (A-Z, a-z, 0-9) enthalten",
+		CodeDeviceTypeNotAllowed:   "muss einer der folgenden Werte sein: %s",
+		CodeDeviceTypeDeprecated:   "%s ist veraltet; muss einer der folgenden Werte sein: %s",
+		CodeOwnerLength:            "muss zwischen %d und %d Zeichen lang sein",
+		CodeDescriptionTooLong:     "darf höchstens %d Zeichen lang sein",
+		CodeLastAlarmReasonTooLong: "darf höchstens %d Zeichen lang sein",
+		CodeUnsafeText:             "darf keine spitzen Klammern (< >) oder Steuerzeichen wie Zeilenumbrüche enthalten",
+		CodeMustBeFuture:           "muss in der Zukunft liegen",
+		CodeUntilRequiresEnabled:   "kann nur beim Aktivieren der Wartung gesetzt werden",
+		CodeReasonEmpty:            "der Grund darf nicht leer sein",
+		CodeReasonTooLong:          "der Grund darf höchstens %d Zeichen lang sein",
+		CodeReasonUnsafe:           "der Grund darf keine spitzen Klammern (< >) oder Steuerzeichen wie Zeilenumbrüche enthalten",
+		CodeLevelInvalid:           "die Stufe muss einer der folgenden Werte sein: %s",
+		CodeTriggeredByInvalid:     "triggered_by darf höchstens %d Zeichen lang sein und nur Buchstaben, Ziffern und _ . : @ - enthalten",
+		CodeEventIDInvalid:         "event_id darf höchstens %d Zeichen lang sein und nur Buchstaben, Ziffern und _ . : - enthalten",
+		CodeClientEventIDInvalid:   "client_event_id darf höchstens %d Zeichen lang sein und nur Buchstaben, Ziffern und _ . : - enthalten",
+		CodeDescriptionBlank:       "besteht nur aus Leerzeichen",
+		CodeOwnerLooksLikeEmail:    "sieht wie eine E-Mail-Adresse aus; Eigentümer sind normalerweise Benutzernamen",
+		CodeCriticalReasonTooShort: "der Grund ist für einen CRITICAL-Alarm kürzer als %d Zeichen",
+		CodeManifestEmpty:          "muss mindestens ein Gerät enthalten",
+		CodeManifestDuplicate:      "ist ein Duplikat von %s mit demselben Eigentümer und Namen",
+		CodeFirmwareVersionInvalid: "muss eine Version wie 1.4.0 mit höchstens %d Zeichen sein",
+		CodeFirmwareStatusInvalid:  "muss einer der folgenden Werte sein: success, failed",
+		CodeOwnerUnchanged:         "muss sich vom umzubenennenden Eigentümer unterscheiden",
+		CodeUnknownOwner:           "ist kein bekannter Eigentümer",
+		CodeUnknownOwnerSuggested:  "ist kein bekannter Eigentümer; meinten Sie: %s?",
+		CodeParentIDInvalid:        "muss die positive ID eines Geräts sein",
+		CodeAlarmRetentionInvalid:  "muss eine Anzahl von Tagen zwischen 0, um Alarme dauerhaft aufzubewahren, und %d sein",
+		CodeReasonCodeInvalid:      "darf höchstens %d Zeichen lang sein und nur Kleinbuchstaben, Ziffern und _ . - enthalten, beginnend mit einem Buchstaben oder einer Ziffer",
+		CodeReasonCodeUnknown:      "ist nicht im Katalog der Alarmgründe",
+		CodeReasonCodeRequired:     "ist erforderlich; Freitextgründe werden nicht akzeptiert",
+	},
+}
+
+// message is a validation message not yet rendered in a language
+type message struct {
+	code Code
+	args []any
+}
+
+// Message renders the message for code in lang, falling back to English when the language or
+// the message is not in the catalog
+func Message(lang string, code Code, args ...any) string {
+	format, ok := catalogs[lang][code]
+	if !ok {
+		format = catalogs[DefaultLanguage][code]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// MatchLanguage returns the supported language closest to tag, such as "fr" for "fr-CA", and
+// whether there is one
+func MatchLanguage(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := catalogs[tag]; ok {
+		return tag, true
+	}
+	if base, _, found := strings.Cut(tag, "-"); found {
+		if _, ok := catalogs[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,66 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for code := range catalogs[DefaultLanguage] {
+			if _, ok := catalog[code]; !ok {
+				t.Errorf("Catalog %q is missing %q", lang, code)
+			}
+		}
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+		ok       bool
+	}{
+		{"fr", "fr", true},
+		{"fr-CA", "fr", true},
+		{"DE-at", "de", true},
+		{" es ", "es", true},
+		{"pt-BR", "", false},
+		{"*", "", false},
+	}
+
+	for _, tc := range tests {
+		lang, ok := MatchLanguage(tc.tag)
+		if lang != tc.expected || ok != tc.ok {
+			t.Errorf("MatchLanguage(%q) = %q, %t, expected %q, %t", tc.tag, lang, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestResultLocalize(t *testing.T) {
+	result := ValidateDeviceCreate(&models.DeviceCreate{Name: "", DeviceType: models.DeviceTypeLock, OwnedBy: "jane@example.com"}, nil)
+	if result.Errors["name"] != "must be between 1-100 characters and contain only alphanumeric characters (A-Z, a-z, 0-9)" {
+		t.Errorf("Expected English messages by default, got %q", result.Errors["name"])
+	}
+
+	result.Localize("de")
+	if result.Errors["name"] != "muss zwischen 1 und 100 Zeichen lang sein und darf nur alphanumerische Zeichen (A-Z, a-z, 0-9) enthalten" {
+		t.Errorf("Expected the error in German, got %q", result.Errors["name"])
+	}
+	if result.Warnings["owned_by"] != catalogs["de"][CodeOwnerLooksLikeEmail] {
+		t.Errorf("Expected the warning in German, got %q", result.Warnings["owned_by"])
+	}
+
+	// A promoted warning is still localized
+	result.Strict()
+	result.Localize("es")
+	if result.Errors["owned_by"] != catalogs["es"][CodeOwnerLooksLikeEmail] {
+		t.Errorf("Expected the promoted warning in Spanish, got %q", result.Errors["owned_by"])
+	}
+
+	result.Localize("xx")
+	if result.Errors["owned_by"] != catalogs[DefaultLanguage][CodeOwnerLooksLikeEmail] {
+		t.Errorf("Expected an unknown language to fall back to English, got %q", result.Errors["owned_by"])
+	}
+}
@@ -0,0 +1,48 @@
+package validation
+
+import "github.com/tyrese-r/go-home/pkg/models"
+
+// LengthRule bounds the length of a field, in bytes. A zero Min leaves the field optional.
+type LengthRule struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+	// Pattern is the regular expression the value must match, when there is one
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Rules describes the limits input is validated against, so that clients can check input before
+// sending it. It is built from the same constants and settings the validators use.
+type Rules struct {
+	Name        LengthRule `json:"name"`
+	Owner       LengthRule `json:"owned_by"`
+	Description LengthRule `json:"description"`
+	AlarmReason LengthRule `json:"alarm_reason"`
+	TriggeredBy LengthRule `json:"triggered_by"`
+	EventID     LengthRule `json:"event_id"`
+	// ClientEventID takes the same form as EventID
+	ClientEventID LengthRule `json:"client_event_id"`
+	// ReasonCode names an entry of the alarm reason catalog
+	ReasonCode LengthRule `json:"reason_code"`
+	// DeviceTypes are the types devices may be created with or changed to
+	DeviceTypes []string `json:"device_types"`
+	// AlarmLevels are the levels alarms may be raised with, from least to most severe
+	AlarmLevels []string `json:"alarm_levels"`
+}
+
+// DescribeRules returns the rules input is validated against when devices are limited to
+// allowed and alarms to levels
+func DescribeRules(allowed AllowedDeviceTypes, levels *models.AlarmLevels) *Rules {
+	return &Rules{
+		Name:        LengthRule{Min: MinDeviceNameLength, Max: MaxDeviceNameLength, Pattern: alphanumericPattern.String()},
+		Owner:       LengthRule{Min: MinOwnerLength, Max: MaxOwnerLength},
+		Description: LengthRule{Max: MaxDescriptionLength},
+		AlarmReason: LengthRule{Min: MinAlarmReasonLength, Max: MaxLastAlarmReasonLength},
+		TriggeredBy: LengthRule{Max: MaxTriggeredByLength, Pattern: actorPattern.String()},
+		EventID:     LengthRule{Max: MaxEventIDLength, Pattern: eventIDPattern.String()},
+		DeviceTypes: allowed.Types(),
+		AlarmLevels: levels.IDs(),
+
+		ClientEventID: LengthRule{Max: MaxEventIDLength, Pattern: eventIDPattern.String()},
+		ReasonCode:    LengthRule{Max: MaxReasonCodeLength, Pattern: reasonCodePattern.String()},
+	}
+}
@@ -0,0 +1,35 @@
+package validation
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/tyrese-r/go-home/pkg/models"
+)
+
+func TestDescribeRules(t *testing.T) {
+	rules := DescribeRules(nil, nil)
+
+	if rules.Name.Min != MinDeviceNameLength || rules.Name.Max != MaxDeviceNameLength {
+		t.Errorf("Unexpected name rule %+v", rules.Name)
+	}
+	if len(rules.DeviceTypes) != len(models.GetAllDeviceTypes()) {
+		t.Errorf("Expected every type allowed, got %v", rules.DeviceTypes)
+	}
+	if len(rules.AlarmLevels) != 3 || rules.AlarmLevels[2] != models.AlarmLevelCritical {
+		t.Errorf("Expected the built-in levels, got %v", rules.AlarmLevels)
+	}
+
+	// The described pattern is the one names are checked against
+	pattern := regexp.MustCompile(rules.Name.Pattern)
+	for _, name := range []string{"Kitchen1", "Front Door", "Hall-Light"} {
+		if pattern.MatchString(name) != IsValidDeviceName(name) {
+			t.Errorf("Pattern and validator disagree about %q", name)
+		}
+	}
+
+	allowed := NewAllowedDeviceTypes([]models.DeviceType{models.DeviceTypeLock}, nil)
+	if rules := DescribeRules(allowed, nil); len(rules.DeviceTypes) != 1 || rules.DeviceTypes[0] != string(models.DeviceTypeLock) {
+		t.Errorf("Expected only LOCK allowed, got %v", rules.DeviceTypes)
+	}
+}